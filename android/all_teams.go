@@ -22,10 +22,12 @@ func registerAllTeamBuildComponents(ctx RegistrationContext) {
 	ctx.RegisterParallelSingletonType("all_teams", AllTeamsFactory)
 }
 
-// For each module, list the team or the bpFile the module is defined in.
+// For each module, list the teams of all its variants, the bpFile the module is defined in and its
+// stable_id if it sets one.
 type moduleTeamInfo struct {
-	teamName string
-	bpFile   string
+	teamNames []string
+	bpFile    string
+	stableId  *string
 }
 
 type allTeamsSingleton struct {
@@ -36,6 +38,10 @@ type allTeamsSingleton struct {
 	packages map[string]packageProperties
 	// Map of all team modules we visit during GenerateBuildActions
 	teams map[string]teamProperties
+	// Map of the directories the team modules are defined in.
+	teamDirs map[string]string
+	// The directories team modules may be defined in, from TeamDirectoryAllowlist.
+	teamDirectoryAllowlist []string
 	// Keeps track of team information or bp file for each module we visit.
 	teams_for_mods map[string]moduleTeamInfo
 }
@@ -65,6 +71,8 @@ func (this *allTeamsSingleton) lookupDefaultTeam(bpFilePath string) (teamPropert
 func (this *allTeamsSingleton) GenerateBuildActions(ctx SingletonContext) {
 	this.packages = make(map[string]packageProperties)
 	this.teams = make(map[string]teamProperties)
+	this.teamDirs = make(map[string]string)
+	this.teamDirectoryAllowlist = ctx.DeviceConfig().TeamDirectoryAllowlist()
 	this.teams_for_mods = make(map[string]moduleTeamInfo)
 
 	ctx.VisitAllModules(func(module Module) {
@@ -80,16 +88,17 @@ func (this *allTeamsSingleton) GenerateBuildActions(ctx SingletonContext) {
 		}
 		if team, ok := module.(*teamModule); ok {
 			this.teams[team.Name()] = team.properties
+			this.teamDirs[team.Name()] = ctx.ModuleDir(module)
 			return
 		}
 
-		// If a team name is given for a module, store it.
-		// Otherwise store the bpFile so we can do a package walk later.
-		if module.base().Team() != "" {
-			this.teams_for_mods[module.Name()] = moduleTeamInfo{teamName: module.base().Team(), bpFile: bpFile}
-		} else {
-			this.teams_for_mods[module.Name()] = moduleTeamInfo{bpFile: bpFile}
-		}
+		// Store the team names given for any variant of the module, along with the bpFile so we can
+		// do a package walk later if there are none.
+		info := this.teams_for_mods[module.Name()]
+		info.teamNames = FirstUniqueStrings(append(info.teamNames, module.base().Teams()...))
+		info.bpFile = bpFile
+		info.stableId = module.base().commonProperties.Stable_id
+		this.teams_for_mods[module.Name()] = info
 	})
 
 	// Visit all modules again and lookup the team name in the package or parent package if the team
@@ -112,42 +121,52 @@ func (this *allTeamsSingleton) MakeVars(ctx MakeVarsContext) {
 
 // Visit every (non-package, non-team) module and write out a proto containing
 // either the declared team data for that module or the package default team data for that module.
+// A module owned by multiple teams has an entry for each team.
 func (this *allTeamsSingleton) lookupTeamForAllModules() *team_proto.AllTeams {
-	teamsProto := make([]*team_proto.Team, len(this.teams_for_mods))
-	for i, moduleName := range SortedKeys(this.teams_for_mods) {
+	var teamsProto []*team_proto.Team
+	for _, moduleName := range SortedKeys(this.teams_for_mods) {
 		m, _ := this.teams_for_mods[moduleName]
-		teamName := m.teamName
-		var teamProperties teamProperties
-		found := false
-		if teamName != "" {
-			teamProperties, found = this.teams[teamName]
-		} else {
-			teamProperties, found = this.lookupDefaultTeam(m.bpFile)
+		var trendyTeamIds []string
+		if len(m.teamNames) > 0 {
+			for _, teamName := range m.teamNames {
+				if teamProperties, found := this.teams[teamName]; found {
+					trendyTeamIds = append(trendyTeamIds, String(teamProperties.Trendy_team_id))
+				}
+			}
+		} else if teamProperties, found := this.lookupDefaultTeam(m.bpFile); found {
+			trendyTeamIds = append(trendyTeamIds, String(teamProperties.Trendy_team_id))
 		}
+		trendyTeamIds = FirstUniqueStrings(RemoveListFromList(trendyTeamIds, []string{""}))
 
-		trendy_team_id := ""
-		if found {
-			trendy_team_id = *teamProperties.Trendy_team_id
+		// Teams defined outside of the TeamDirectoryAllowlist are listed even in report-only mode.
+		var disallowedTeams []string
+		for _, teamName := range m.teamNames {
+			if dir, found := this.teamDirs[teamName]; found && !isTeamDirectoryAllowed(this.teamDirectoryAllowlist, dir) {
+				disallowedTeams = append(disallowedTeams, teamName)
+			}
 		}
 
 		var files []string
-		teamData := new(team_proto.Team)
-		if trendy_team_id != "" {
-			*teamData = team_proto.Team{
-				TrendyTeamId: proto.String(trendy_team_id),
-				TargetName:   proto.String(moduleName),
-				Path:         proto.String(m.bpFile),
-				File:         files,
-			}
-		} else {
+		if len(trendyTeamIds) == 0 {
 			// Clients rely on the TrendyTeamId optional field not being set.
-			*teamData = team_proto.Team{
-				TargetName: proto.String(moduleName),
-				Path:       proto.String(m.bpFile),
-				File:       files,
-			}
+			teamsProto = append(teamsProto, &team_proto.Team{
+				TargetName:     proto.String(moduleName),
+				Path:           proto.String(m.bpFile),
+				File:           files,
+				StableId:       m.stableId,
+				DisallowedTeam: disallowedTeams,
+			})
+		}
+		for _, trendyTeamId := range trendyTeamIds {
+			teamsProto = append(teamsProto, &team_proto.Team{
+				TrendyTeamId:   proto.String(trendyTeamId),
+				TargetName:     proto.String(moduleName),
+				Path:           proto.String(m.bpFile),
+				File:           files,
+				StableId:       m.stableId,
+				DisallowedTeam: disallowedTeams,
+			})
 		}
-		teamsProto[i] = teamData
 	}
 	return &team_proto.AllTeams{Teams: teamsProto}
 }
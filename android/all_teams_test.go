@@ -71,6 +71,65 @@ func TestAllTeams(t *testing.T) {
 	AssertDeepEquals(t, "compare maps", expectedTeams, actualTeams)
 }
 
+func TestAllTeamsMultipleTeams(t *testing.T) {
+	t.Parallel()
+	ctx := GroupFixturePreparers(
+		prepareForModuleTests,
+		PrepareForTestWithArchMutator,
+		PrepareForTestWithTeamBuildComponents,
+		FixtureRegisterWithContext(func(ctx RegistrationContext) {
+			ctx.RegisterParallelSingletonType("all_teams", AllTeamsFactory)
+		}),
+	).RunTestWithBp(t, `
+		team {
+			name: "team1",
+			trendy_team_id: "11111",
+		}
+
+		team {
+			name: "team2",
+			trendy_team_id: "22222",
+		}
+
+		team {
+			name: "host_team",
+			trendy_team_id: "33333",
+		}
+
+		deps {
+			name: "shared",
+			teams: ["team1", "team2"],
+		}
+
+		deps {
+			name: "ported",
+			team: "team1",
+			target: {
+				host: {
+					team: "host_team",
+				},
+			},
+		}
+	`)
+
+	teams := getTeamProtoOutput(t, ctx)
+
+	// map of module name -> trendy team names.
+	actualTeams := make(map[string][]string)
+	for _, teamProto := range teams.Teams {
+		actualTeams[teamProto.GetTargetName()] = append(actualTeams[teamProto.GetTargetName()], teamProto.GetTrendyTeamId())
+	}
+	for name, ids := range actualTeams {
+		actualTeams[name] = SortedUniqueStrings(ids)
+	}
+	expectedTeams := map[string][]string{
+		"shared": {"11111", "22222"},
+		"ported": {"11111", "33333"},
+	}
+
+	AssertDeepEquals(t, "compare maps", expectedTeams, actualTeams)
+}
+
 func getTeamProtoOutput(t *testing.T, ctx *TestResult) *team_proto.AllTeams {
 	teams := new(team_proto.AllTeams)
 	config := ctx.SingletonForTests("all_teams")
@@ -206,3 +265,95 @@ func TestPackageLookup(t *testing.T) {
 	}
 	AssertDeepEquals(t, "compare maps", expectedTeams, actualTeams)
 }
+
+func TestTeamDirectoryAllowlist(t *testing.T) {
+	t.Parallel()
+	teamsBp := `
+		team {
+			name: "allowed_team",
+			trendy_team_id: "11111",
+		}
+	`
+	fixturesBp := `
+		team {
+			name: "fixture_team",
+			trendy_team_id: "22222",
+		}
+	`
+	modulesBp := `
+		fake {
+			name: "allowed",
+			team: "allowed_team",
+		}
+
+		fake {
+			name: "disallowed",
+			team: "fixture_team",
+		}
+
+		fake {
+			name: "mixed",
+			teams: ["allowed_team", "fixture_team"],
+		}
+	`
+	preparer := GroupFixturePreparers(
+		PrepareForTestWithTeamBuildComponents,
+		FixtureRegisterWithContext(func(ctx RegistrationContext) {
+			ctx.RegisterModuleType("fake", fakeModuleFactory)
+			ctx.RegisterParallelSingletonType("all_teams", AllTeamsFactory)
+		}),
+		FixtureAddTextFile("teams/Android.bp", teamsBp),
+		FixtureAddTextFile("test/fixtures/Android.bp", fixturesBp),
+		FixtureAddTextFile("modules/Android.bp", modulesBp),
+		FixtureModifyProductVariables(func(variables FixtureProductVariables) {
+			variables.TeamDirectoryAllowlist = []string{"teams/"}
+		}),
+	)
+
+	t.Run("errors", func(t *testing.T) {
+		t.Parallel()
+		preparer.ExtendWithErrorHandler(FixtureExpectsAllErrorsToMatchAPattern([]string{
+			`module "fixture_team": team "fixture_team" is defined in "test/fixtures", which is not in TeamDirectoryAllowlist`,
+			`module "disallowed": team: team "fixture_team" is defined in "test/fixtures", which is not in TeamDirectoryAllowlist`,
+			`module "mixed": teams: team "fixture_team" is defined in "test/fixtures", which is not in TeamDirectoryAllowlist`,
+		})).RunTest(t)
+	})
+
+	t.Run("report only", func(t *testing.T) {
+		t.Parallel()
+		ctx := GroupFixturePreparers(
+			preparer,
+			FixtureModifyProductVariables(func(variables FixtureProductVariables) {
+				variables.TeamDirectoryAllowlistReportOnly = proto.Bool(true)
+			}),
+		).RunTest(t)
+
+		// map of module name -> disallowed team names.
+		actualTeams := make(map[string][]string)
+		for _, teamProto := range getTeamProtoOutput(t, ctx).Teams {
+			actualTeams[teamProto.GetTargetName()] = teamProto.GetDisallowedTeam()
+		}
+		expectedTeams := map[string][]string{
+			"allowed":    nil,
+			"disallowed": {"fixture_team"},
+			"mixed":      {"fixture_team"},
+		}
+		AssertDeepEquals(t, "disallowed teams", expectedTeams, actualTeams)
+
+		msg := `team "fixture_team" is defined in "test/fixtures", which is not in TeamDirectoryAllowlist`
+		AssertDeepEquals(t, "warnings", []BuildWarning{
+			{File: "modules/Android.bp", Module: "disallowed", Property: "team", Message: msg},
+			{File: "modules/Android.bp", Module: "mixed", Property: "teams", Message: msg},
+			{File: "test/fixtures/Android.bp", Module: "fixture_team", Message: msg},
+		}, ctx.Config.BuildWarnings())
+	})
+}
+
+func TestIsTeamDirectoryAllowed(t *testing.T) {
+	allowlist := []string{"teams", "vendor/teams/"}
+	AssertBoolEquals(t, "empty allowlist", true, isTeamDirectoryAllowed(nil, "anywhere"))
+	AssertBoolEquals(t, "listed directory", true, isTeamDirectoryAllowed(allowlist, "teams"))
+	AssertBoolEquals(t, "subdirectory", true, isTeamDirectoryAllowed(allowlist, "vendor/teams/foo"))
+	AssertBoolEquals(t, "directory prefix", false, isTeamDirectoryAllowed(allowlist, "teams_fixtures"))
+	AssertBoolEquals(t, "other directory", false, isTeamDirectoryAllowed(allowlist, "test/fixtures"))
+}
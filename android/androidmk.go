@@ -29,6 +29,7 @@ import (
 	"path/filepath"
 	"reflect"
 	"runtime"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -52,6 +53,7 @@ func RegisterAndroidMkBuildComponents(ctx RegistrationContext) {
 var PrepareForTestWithAndroidMk = GroupFixturePreparers(
 	FixtureRegisterWithContext(RegisterAndroidMkBuildComponents),
 	FixtureModifyConfig(SetKatiEnabledForTests),
+	FixtureModifyConfig(SetVerifyAndroidMkEntriesForTests),
 )
 
 // Deprecated: Use AndroidMkEntriesProvider instead, especially if you're not going to use the
@@ -139,6 +141,11 @@ type AndroidMkEntries struct {
 	// Make statements directly to the final Android-*.mk file.
 	// Primarily used to call macros or declare/update Make targets.
 	ExtraFooters []AndroidMkExtraFootersFunc
+	// Funcs to add extra lines to the module's Android.mk output like ExtraFooters, that can read
+	// the providers of the module from their context instead of capturing the ModuleContext.  They
+	// are called after the funcs in ExtraFooters.  New code should use these rather than
+	// ExtraFooters, which will be removed once all of its users have been converted.
+	ExtraFootersWithContext []AndroidMkExtraFootersWithContextFunc
 
 	// A map that holds the up-to-date Make variable values. Can be accessed from tests.
 	EntryMap map[string][]string
@@ -150,6 +157,10 @@ type AndroidMkEntries struct {
 	// 3. Makes troubleshooting and spotting errors easier.
 	entryOrder []string
 
+	// The contributions to the dist computed by fillInEntries, used to check for dist copies that
+	// overwrite each other.
+	distContributions *distContributions
+
 	// Provides data typically stored by Context objects that are commonly needed by
 	//AndroidMkEntries objects.
 	entryContext AndroidMkEntriesContext
@@ -174,6 +185,7 @@ func (a *androidMkExtraEntriesContext) Provider(provider blueprint.AnyProviderKe
 
 type AndroidMkExtraEntriesFunc func(ctx AndroidMkExtraEntriesContext, entries *AndroidMkEntries)
 type AndroidMkExtraFootersFunc func(w io.Writer, name, prefix, moduleDir string)
+type AndroidMkExtraFootersWithContextFunc func(ctx AndroidMkExtraEntriesContext, w io.Writer, name, prefix, moduleDir string)
 
 // Utility funcs to manipulate Android.mk variable entries.
 
@@ -323,6 +335,19 @@ func (d *copiesForGoals) addCopyInstruction(from Path, dest string) {
 	d.copies = append(d.copies, distCopy{from, dest})
 }
 
+// Adds copy instructions for the license metadata file and the license texts of the artifact
+// copied to dest, into the licenses subdirectory of the directory containing it.
+func (d *copiesForGoals) addLicenseCopyInstructions(licenseMetadataFile Path, licenseFiles Paths, dest string) {
+	dir, base := filepath.Split(dest)
+	licensesDir := filepath.Join(dir, "licenses")
+	if licenseMetadataFile != nil {
+		d.addCopyInstruction(licenseMetadataFile, filepath.Join(licensesDir, base+".meta_lic"))
+	}
+	for i, licenseFile := range licenseFiles {
+		d.addCopyInstruction(licenseFile, filepath.Join(licensesDir, fmt.Sprintf("%s.%d.%s", base, i, licenseFile.Base())))
+	}
+}
+
 // Instruction on a path that must be copied into the dist.
 type distCopy struct {
 	// The path to copy from.
@@ -332,11 +357,22 @@ type distCopy struct {
 	dest string
 }
 
+// distsHiddenFromMake returns true if the dists of the module are dropped because it is hidden from
+// Make, e.g. because it was replaced by its prebuilt, and it doesn't set dist_when_hidden.
+func distsHiddenFromMake(module *ModuleBase) bool {
+	return (module.IsHideFromMake() || module.IsReplacedByPrebuilt()) &&
+		!Bool(module.distProperties.Dist_when_hidden)
+}
+
 // Compute the contributions that the module makes to the dist.
 func (a *AndroidMkEntries) getDistContributions(mod blueprint.Module) *distContributions {
 	amod := mod.(Module).base()
 	name := amod.BaseModuleName()
 
+	if distsHiddenFromMake(amod) {
+		return nil
+	}
+
 	// Collate the set of associated tag/paths available for copying to the dist.
 	// Start with an empty (nil) set.
 	var availableTaggedDists TaggedDistFiles
@@ -375,9 +411,14 @@ func (a *AndroidMkEntries) getDistContributions(mod blueprint.Module) *distContr
 	}
 
 	// Iterate over this module's dist structs, merged from the dist and dists properties.
-	for _, dist := range amod.Dists() {
+	for _, dist := range amod.distsWithModuleTypeDists(a.entryContext.Config()) {
 		// Get the list of goals this dist should be enabled for. e.g. sdk, droidcore
-		goals := strings.Join(dist.Targets, " ")
+		targets := filterDistGoals(a.entryContext.Config(), name, dist.Targets)
+		if len(targets) == 0 && len(dist.Targets) > 0 {
+			// All the goals of this dist were excluded by the product.
+			continue
+		}
+		goals := strings.Join(targets, " ")
 
 		// Get the tag representing the output files to be dist'd. e.g. ".jar", ".proguard_map"
 		var tag string
@@ -432,13 +473,25 @@ func (a *AndroidMkEntries) getDistContributions(mod blueprint.Module) *distContr
 				suffix = *dist.Suffix
 			}
 
+			// The product directory already names the product, so the artifact isn't appended
+			// with it as well.
+			inProductDir := a.entryContext.Config().DistInProductDir() && BoolDefault(dist.Product_dir, true)
+
 			productString := ""
-			if dist.Append_artifact_with_product != nil && *dist.Append_artifact_with_product {
+			if dist.Append_artifact_with_product != nil && *dist.Append_artifact_with_product && !inProductDir {
 				productString = fmt.Sprintf("_%s", a.entryContext.Config().DeviceProduct())
 			}
 
-			if suffix != "" || productString != "" {
-				dest = strings.TrimSuffix(dest, ext) + suffix + productString + ext
+			osString := ""
+			if Bool(dist.Append_os) {
+				osString = "_" + amod.Os().Name
+				if amod.commonProperties.CompileMultipleArchVariants {
+					osString += "_" + amod.Arch().ArchType.Name
+				}
+			}
+
+			if suffix != "" || productString != "" || osString != "" {
+				dest = strings.TrimSuffix(dest, ext) + suffix + productString + osString + ext
 			}
 
 			if dist.Dir != nil {
@@ -449,7 +502,19 @@ func (a *AndroidMkEntries) getDistContributions(mod blueprint.Module) *distContr
 				}
 			}
 
+			if inProductDir {
+				dest = filepath.Join(a.entryContext.Config().DeviceProduct(), dest)
+			}
+
 			copiesForGoals.addCopyInstruction(path, dest)
+			if Bool(dist.With_license) {
+				var licenseFiles Paths
+				if info, ok := a.entryContext.moduleProvider(mod, LicenseInfoProvider); ok {
+					licenseFiles = info.(LicenseInfo).LicenseTexts.Paths()
+				}
+				copiesForGoals.addLicenseCopyInstructions(distContributions.licenseMetadataFile,
+					licenseFiles, dest)
+			}
 		}
 	}
 
@@ -479,6 +544,59 @@ func generateDistContributionsForMake(distContributions *distContributions) []st
 	return ret
 }
 
+// distDestSource is the module variant that makes a dist copy.
+type distDestSource struct {
+	module blueprint.Module
+	os     OsType
+}
+
+// distDestChecker finds dist copies to the same destination for the same goal that are made by
+// host variants of different oses, e.g. linux_glibc and darwin, and would overwrite each other.
+// It also collects the dist copies of each module for its -dist phony target.
+type distDestChecker struct {
+	sources map[string]distDestSource
+
+	moduleCopies *moduleDistCopies
+}
+
+func newDistDestChecker() *distDestChecker {
+	return &distDestChecker{
+		sources:      make(map[string]distDestSource),
+		moduleCopies: newModuleDistCopies(),
+	}
+}
+
+// check reports an error for each copy in the contributions of the host module variant that has the
+// same destination for the same goal as a copy made by a host variant of a different os.
+func (c *distDestChecker) check(ctx SingletonContext, mod blueprint.Module, contributions *distContributions) {
+	if contributions == nil {
+		return
+	}
+	c.moduleCopies.add(ctx, mod, contributions)
+
+	source := distDestSource{module: mod, os: mod.(Module).Os()}
+	if source.os.Class != Host {
+		return
+	}
+	for _, copies := range contributions.copiesForGoals {
+		for _, goal := range strings.Fields(copies.goals) {
+			for _, cp := range copies.copies {
+				key := goal + ":" + cp.dest
+				other, ok := c.sources[key]
+				if !ok {
+					c.sources[key] = source
+					continue
+				}
+				if other.os != source.os {
+					ctx.ModuleErrorf(mod, "dist of %q for goal %q overwrites the dist of the %s variant of %q, "+
+						"set append_os: true in the dist properties to append the os to the dist file name",
+						cp.dest, goal, other.os.Name, ctx.ModuleName(other.module))
+				}
+			}
+		}
+	}
+}
+
 // Compute the list of Make strings to declare phony goals and dist-for-goals
 // calls from the module's dist and dists properties.
 func (a *AndroidMkEntries) GetDistForGoals(mod blueprint.Module) []string {
@@ -498,9 +616,63 @@ type fillInEntriesContext interface {
 	Config() Config
 	moduleProvider(module blueprint.Module, provider blueprint.AnyProviderKey) (any, bool)
 	ModuleType(module blueprint.Module) string
+	Errorf(format string, args ...interface{})
 }
 
 func (a *AndroidMkEntries) fillInEntries(ctx fillInEntriesContext, mod blueprint.Module) {
+	// Compute the entries of a copy first when verifying that they are deterministic, as the entries
+	// can't be computed twice in place.
+	var verification *AndroidMkEntries
+	if ctx.Config().VerifyAndroidMkEntries() {
+		verification = a.copyForVerification()
+		verification.computeEntries(ctx, mod)
+	}
+
+	a.computeEntries(ctx, mod)
+
+	if verification != nil {
+		if diffs := diffAndroidMkEntries(verification, a); len(diffs) > 0 {
+			ctx.Errorf("non-deterministic Android.mk entries for module %q variant %q, these differ"+
+				" when computed twice: %s", mod.Name(), ctx.ModuleSubDir(mod), strings.Join(diffs, ", "))
+		}
+	}
+}
+
+// copyForVerification returns a copy of the entries that fillInEntries has not been called on, which
+// can be filled in without affecting the original.
+func (a *AndroidMkEntries) copyForVerification() *AndroidMkEntries {
+	c := *a
+	c.Required = CopyOf(a.Required)
+	c.Host_required = CopyOf(a.Host_required)
+	c.Target_required = CopyOf(a.Target_required)
+	c.header = bytes.Buffer{}
+	c.header.Write(a.header.Bytes())
+	c.footer = bytes.Buffer{}
+	c.footer.Write(a.footer.Bytes())
+	return &c
+}
+
+// diffAndroidMkEntries returns the names of the variables whose values or positions differ between
+// the two filled in entries, and "<header>" or "<footer>" if the preamble or the footer differs.
+func diffAndroidMkEntries(a, b *AndroidMkEntries) []string {
+	var diffs []string
+	for _, name := range SortedUniqueStrings(append(CopyOf(a.entryOrder), b.entryOrder...)) {
+		if IndexList(name, a.entryOrder) != IndexList(name, b.entryOrder) ||
+			!slices.Equal(a.EntryMap[name], b.EntryMap[name]) {
+			diffs = append(diffs, name)
+		}
+	}
+	if a.header.String() != b.header.String() {
+		diffs = append(diffs, "<header>")
+	}
+	if a.footer.String() != b.footer.String() {
+		diffs = append(diffs, "<footer>")
+	}
+	return diffs
+}
+
+// computeEntries does the work of fillInEntries.
+func (a *AndroidMkEntries) computeEntries(ctx fillInEntriesContext, mod blueprint.Module) {
 	a.entryContext = ctx
 	a.EntryMap = make(map[string][]string)
 	amod := mod.(Module)
@@ -513,12 +685,15 @@ func (a *AndroidMkEntries) fillInEntries(ctx fillInEntriesContext, mod blueprint
 	if a.Include == "" {
 		a.Include = "$(BUILD_PREBUILT)"
 	}
-	a.Required = append(a.Required, amod.RequiredModuleNames()...)
+	a.Required = append(a.Required, RemoveListFromList(amod.RequiredModuleNames(), base.skippedRequired)...)
 	a.Host_required = append(a.Host_required, amod.HostRequiredModuleNames()...)
 	a.Target_required = append(a.Target_required, amod.TargetRequiredModuleNames()...)
 
-	for _, distString := range a.GetDistForGoals(mod) {
-		fmt.Fprintf(&a.header, distString)
+	a.distContributions = a.getDistContributions(mod)
+	if a.distContributions != nil {
+		for _, distString := range generateDistContributionsForMake(a.distContributions) {
+			fmt.Fprintf(&a.header, distString)
+		}
 	}
 
 	fmt.Fprintf(&a.header, "\ninclude $(CLEAR_VARS)  # type: %s, name: %s, variant: %s\n", ctx.ModuleType(mod), base.BaseModuleName(), ctx.ModuleSubDir(mod))
@@ -533,6 +708,12 @@ func (a *AndroidMkEntries) fillInEntries(ctx fillInEntriesContext, mod blueprint
 	a.AddStrings("LOCAL_TARGET_REQUIRED_MODULES", a.Target_required...)
 	a.AddStrings("LOCAL_SOONG_MODULE_TYPE", ctx.ModuleType(amod))
 
+	// Export the modules overridden by the local variant of an override module, unless the module
+	// type has its own overrides property, which it exports itself.
+	if om, ok := mod.(OverridableModule); ok && om.moduleBase().overridesProperty == nil {
+		a.AddStrings("LOCAL_OVERRIDES_MODULES", om.moduleBase().GetOverriddenModules()...)
+	}
+
 	// If the install rule was generated by Soong tell Make about it.
 	if len(base.katiInstalls) > 0 {
 		// Assume the primary install file is last since it probably needs to depend on any other
@@ -593,8 +774,8 @@ func (a *AndroidMkEntries) fillInEntries(ctx fillInEntriesContext, mod blueprint
 		a.SetBoolIfTrue("LOCAL_ODM_MODULE", Bool(base.commonProperties.Device_specific))
 		a.SetBoolIfTrue("LOCAL_PRODUCT_MODULE", Bool(base.commonProperties.Product_specific))
 		a.SetBoolIfTrue("LOCAL_SYSTEM_EXT_MODULE", Bool(base.commonProperties.System_ext_specific))
-		if base.commonProperties.Owner != nil {
-			a.SetString("LOCAL_MODULE_OWNER", *base.commonProperties.Owner)
+		if owner := base.Owner(); owner != "" {
+			a.SetString("LOCAL_MODULE_OWNER", owner)
 		}
 	}
 
@@ -649,6 +830,9 @@ func (a *AndroidMkEntries) fillInEntries(ctx fillInEntriesContext, mod blueprint
 	for _, footerFunc := range a.ExtraFooters {
 		footerFunc(&a.footer, name, prefix, blueprintDir)
 	}
+	for _, footerFunc := range a.ExtraFootersWithContext {
+		footerFunc(extraCtx, &a.footer, name, prefix, blueprintDir)
+	}
 }
 
 func (a *AndroidMkEntries) disabled() bool {
@@ -682,8 +866,14 @@ func AndroidMkSingleton() Singleton {
 type androidMkSingleton struct{}
 
 func (c *androidMkSingleton) GenerateBuildActions(ctx SingletonContext) {
-	// Skip if Soong wasn't invoked from Make.
+	// Skip if Soong wasn't invoked from Make, but still write the module type statistics that are
+	// otherwise computed while translating the modules.
 	if !ctx.Config().KatiEnabled() {
+		typeStats := make(moduleTypeStatsCollector)
+		ctx.VisitAllModulesBlueprint(func(module blueprint.Module) {
+			typeStats.add(ctx, module)
+		})
+		writeModuleTypeStats(ctx, typeStats)
 		return
 	}
 
@@ -706,78 +896,148 @@ func (c *androidMkSingleton) GenerateBuildActions(ctx SingletonContext) {
 
 	moduleInfoJSON := PathForOutput(ctx, "module-info"+String(ctx.Config().productVariables.Make_suffix)+".json")
 
-	err := translateAndroidMk(ctx, absolutePath(transMk.String()), moduleInfoJSON, androidMkModulesList)
+	phase := BeginTailPhase(ctx.Config(), "androidmk", "modules")
+	err := translateAndroidMk(ctx, transMk.String(), moduleInfoJSON, androidMkModulesList, phase)
+	phase.End(ctx.Config())
 	if err != nil {
 		ctx.Errorf(err.Error())
 	}
 
+	writeExcludedDistGoalsReport(ctx)
+
 	ctx.Build(pctx, BuildParams{
 		Rule:   blueprint.Phony,
 		Output: transMk,
 	})
 }
 
-func translateAndroidMk(ctx SingletonContext, absMkFile string, moduleInfoJSONPath WritablePath, mods []blueprint.Module) error {
+// The Android.mk entries are sharded into one file per top level directory of the modules, named
+// Android-<product>-<dir>.mk, which are included by Android-<product>.mk.  Each shard is only
+// rewritten when its contents change, so that its timestamp is kept when an incremental run of
+// Soong doesn't change any of the modules in the directory.
+
+// androidMkShardName returns the name of the shard that contains the Android.mk entries of the
+// modules in the directory.
+func androidMkShardName(moduleDir string) string {
+	dir := strings.SplitN(filepath.Clean(moduleDir), "/", 2)[0]
+	if dir == "." || dir == "" {
+		return "root"
+	}
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-' || r == '.' {
+			return r
+		}
+		return '_'
+	}, dir)
+}
+
+// androidMkShardFile returns the path of the shard included by the Android.mk file.
+func androidMkShardFile(mkFile, shard string) string {
+	return strings.TrimSuffix(mkFile, ".mk") + "-" + shard + ".mk"
+}
+
+// androidMkMasterFile returns the contents of the Android.mk file that includes the shards, which
+// only changes when shards are added or removed or the module type statistics change.
+func androidMkMasterFile(shardFiles []string, typeStats map[string]int) []byte {
 	buf := &bytes.Buffer{}
+	for _, shardFile := range shardFiles {
+		fmt.Fprintln(buf, "include", shardFile)
+	}
 
-	var moduleInfoJSONs []*ModuleInfoJSON
+	fmt.Fprintln(buf, "\nSTATS.SOONG_MODULE_TYPE :=")
+	for _, mod_type := range SortedKeys(typeStats) {
+		fmt.Fprintln(buf, "STATS.SOONG_MODULE_TYPE +=", mod_type)
+		fmt.Fprintf(buf, "STATS.SOONG_MODULE_TYPE.%s := %d\n", mod_type, typeStats[mod_type])
+	}
+	return buf.Bytes()
+}
 
-	fmt.Fprintln(buf, "LOCAL_MODULE_MAKEFILE := $(lastword $(MAKEFILE_LIST))")
+func translateAndroidMk(ctx SingletonContext, mkFile string, moduleInfoJSONPath WritablePath, mods []blueprint.Module, phase *TailPhase) error {
+	absMkFile := absolutePath(mkFile)
 
-	typeStats := make(map[string]int)
+	var moduleInfoJSONs []*ModuleInfoJSON
+
+	shards := make(map[string]*bytes.Buffer)
+	typeStats := make(moduleTypeStatsCollector)
+	dists := newDistDestChecker()
+	testSuites := make(testSuitesJSON)
 	for _, mod := range mods {
-		err := translateAndroidMkModule(ctx, buf, &moduleInfoJSONs, mod)
+		shard := androidMkShardName(ctx.ModuleDir(mod))
+		buf := shards[shard]
+		if buf == nil {
+			buf = &bytes.Buffer{}
+			fmt.Fprintln(buf, "LOCAL_MODULE_MAKEFILE := $(lastword $(MAKEFILE_LIST))")
+			shards[shard] = buf
+		}
+
+		err := translateAndroidMkModule(ctx, buf, &moduleInfoJSONs, dists, testSuites, mod)
 		if err != nil {
 			os.Remove(absMkFile)
 			return err
 		}
 
-		if amod, ok := mod.(Module); ok && ctx.PrimaryModule(amod) == amod {
-			typeStats[ctx.ModuleType(amod)] += 1
-		}
+		typeStats.add(ctx, mod)
+		phase.Add(1)
 	}
 
-	keys := []string{}
-	fmt.Fprintln(buf, "\nSTATS.SOONG_MODULE_TYPE :=")
-	for k := range typeStats {
-		keys = append(keys, k)
+	// Make copies the dists of the goals being built, so only the copies of the -dist phony targets
+	// of the modules are built by Soong.
+	dists.moduleCopies.build(ctx, nil)
+
+	// The shards are independent, so they are written by a pool of workers.
+	shardNames := SortedKeys(shards)
+	shardFiles := make([]string, len(shardNames))
+	for i, shard := range shardNames {
+		shardFiles[i] = androidMkShardFile(mkFile, shard)
 	}
-	sort.Strings(keys)
-	for _, mod_type := range keys {
-		fmt.Fprintln(buf, "STATS.SOONG_MODULE_TYPE +=", mod_type)
-		fmt.Fprintf(buf, "STATS.SOONG_MODULE_TYPE.%s := %d\n", mod_type, typeStats[mod_type])
+	shardsPhase := BeginTailPhase(ctx.Config(), "androidmk_shards", "files")
+	err := runTailJobs(ctx.Config(), len(shardNames), func(i int) error {
+		defer shardsPhase.Add(1)
+		return pathtools.WriteFileIfChanged(absolutePath(shardFiles[i]), shards[shardNames[i]].Bytes(), 0666)
+	})
+	shardsPhase.End(ctx.Config())
+	if err != nil {
+		return err
 	}
 
-	err := pathtools.WriteFileIfChanged(absMkFile, buf.Bytes(), 0666)
+	err = pathtools.WriteFileIfChanged(absMkFile, androidMkMasterFile(shardFiles, typeStats.makeTypeStats()), 0666)
 	if err != nil {
 		return err
 	}
+	writeModuleTypeStats(ctx, typeStats)
+
+	if err := testSuites.write(ctx); err != nil {
+		return fmt.Errorf("failed to write test_suites.json: %w", err)
+	}
 
 	return writeModuleInfoJSON(ctx, moduleInfoJSONs, moduleInfoJSONPath)
 }
 
+// writeModuleInfoJSON writes the module-info.json of the modules, which can be hundreds of MB, so
+// it is streamed to the raw file instead of being built in memory.
 func writeModuleInfoJSON(ctx SingletonContext, moduleInfoJSONs []*ModuleInfoJSON, moduleInfoJSONPath WritablePath) error {
-	moduleInfoJSONBuf := &strings.Builder{}
-	moduleInfoJSONBuf.WriteString("[")
-	for i, moduleInfoJSON := range moduleInfoJSONs {
-		if i != 0 {
-			moduleInfoJSONBuf.WriteString(",\n")
-		}
-		moduleInfoJSONBuf.WriteString("{")
-		moduleInfoJSONBuf.WriteString(strconv.Quote(moduleInfoJSON.core.RegisterName))
-		moduleInfoJSONBuf.WriteString(":")
-		err := encodeModuleInfoJSON(moduleInfoJSONBuf, moduleInfoJSON)
-		moduleInfoJSONBuf.WriteString("}")
-		if err != nil {
-			return err
+	return WriteFileRuleStreaming(ctx, moduleInfoJSONPath, func(w io.Writer) error {
+		io.WriteString(w, "[")
+		for i, moduleInfoJSON := range moduleInfoJSONs {
+			if i != 0 {
+				io.WriteString(w, ",\n")
+			}
+			io.WriteString(w, "{")
+			io.WriteString(w, strconv.Quote(moduleInfoJSON.core.RegisterName))
+			io.WriteString(w, ":")
+			err := encodeModuleInfoJSON(w, moduleInfoJSON)
+			io.WriteString(w, "}")
+			if err != nil {
+				return err
+			}
 		}
-	}
-	moduleInfoJSONBuf.WriteString("]")
-	WriteFileRule(ctx, moduleInfoJSONPath, moduleInfoJSONBuf.String())
-	return nil
+		io.WriteString(w, "]\n")
+		return nil
+	})
 }
 
-func translateAndroidMkModule(ctx SingletonContext, w io.Writer, moduleInfoJSONs *[]*ModuleInfoJSON, mod blueprint.Module) error {
+func translateAndroidMkModule(ctx SingletonContext, w io.Writer, moduleInfoJSONs *[]*ModuleInfoJSON,
+	dists *distDestChecker, testSuites testSuitesJSON, mod blueprint.Module) error {
 	defer func() {
 		if r := recover(); r != nil {
 			panic(fmt.Errorf("%s in translateAndroidMkModule for module %s variant %s",
@@ -789,11 +1049,11 @@ func translateAndroidMkModule(ctx SingletonContext, w io.Writer, moduleInfoJSONs
 	var err error
 	switch x := mod.(type) {
 	case AndroidMkDataProvider:
-		err = translateAndroidModule(ctx, w, moduleInfoJSONs, mod, x)
+		err = translateAndroidModule(ctx, w, moduleInfoJSONs, dists, mod, x)
 	case bootstrap.GoBinaryTool:
 		err = translateGoBinaryModule(ctx, w, mod, x)
 	case AndroidMkEntriesProvider:
-		err = translateAndroidMkEntriesModule(ctx, w, moduleInfoJSONs, mod, x)
+		err = translateAndroidMkEntriesModule(ctx, w, moduleInfoJSONs, dists, testSuites, mod, x)
 	default:
 		// Not exported to make so no make variables to set.
 	}
@@ -846,10 +1106,11 @@ func (data *AndroidMkData) fillInData(ctx fillInEntriesContext, mod blueprint.Mo
 // A support func for the deprecated AndroidMkDataProvider interface. Use AndroidMkEntryProvider
 // instead.
 func translateAndroidModule(ctx SingletonContext, w io.Writer, moduleInfoJSONs *[]*ModuleInfoJSON,
-	mod blueprint.Module, provider AndroidMkDataProvider) error {
+	dists *distDestChecker, mod blueprint.Module, provider AndroidMkDataProvider) error {
 
 	amod := mod.(Module).base()
 	if shouldSkipAndroidMkProcessing(amod) {
+		translateDistsOfHiddenModule(ctx, w, dists, mod)
 		return nil
 	}
 
@@ -860,6 +1121,9 @@ func translateAndroidModule(ctx SingletonContext, w io.Writer, moduleInfoJSONs *
 
 	data.fillInData(ctx, mod)
 	aconfigUpdateAndroidMkData(ctx, mod.(Module), &data)
+	if !data.Entries.disabled() {
+		dists.check(ctx, mod, data.Entries.distContributions)
+	}
 
 	prefix := ""
 	if amod.ArchSpecific() {
@@ -937,9 +1201,23 @@ func WriteAndroidMkData(w io.Writer, data AndroidMkData) {
 	fmt.Fprintln(w, "include "+data.Include)
 }
 
+// translateDistsOfHiddenModule writes the dist-for-goals calls of a module that is hidden from Make
+// but sets dist_when_hidden, without the rest of its Android.mk entries.
+func translateDistsOfHiddenModule(ctx SingletonContext, w io.Writer, dists *distDestChecker, mod blueprint.Module) {
+	contributions := moduleDistContributions(ctx, mod)
+	if contributions == nil {
+		return
+	}
+	dists.check(ctx, mod, contributions)
+	for _, distString := range generateDistContributionsForMake(contributions) {
+		fmt.Fprint(w, distString)
+	}
+}
+
 func translateAndroidMkEntriesModule(ctx SingletonContext, w io.Writer, moduleInfoJSONs *[]*ModuleInfoJSON,
-	mod blueprint.Module, provider AndroidMkEntriesProvider) error {
+	dists *distDestChecker, testSuites testSuitesJSON, mod blueprint.Module, provider AndroidMkEntriesProvider) error {
 	if shouldSkipAndroidMkProcessing(mod.(Module).base()) {
+		translateDistsOfHiddenModule(ctx, w, dists, mod)
 		return nil
 	}
 
@@ -950,6 +1228,10 @@ func translateAndroidMkEntriesModule(ctx SingletonContext, w io.Writer, moduleIn
 	for _, entries := range entriesList {
 		entries.fillInEntries(ctx, mod)
 		entries.write(w)
+		if !entries.disabled() {
+			dists.check(ctx, mod, entries.distContributions)
+			testSuites.add(ctx, mod, &entries)
+		}
 	}
 
 	if len(entriesList) > 0 && !entriesList[0].disabled() {
@@ -966,6 +1248,13 @@ func ShouldSkipAndroidMkProcessing(module Module) bool {
 }
 
 func shouldSkipAndroidMkProcessing(module *ModuleBase) bool {
+	return module.commonProperties.HideFromMake || isExcludedFromAndroidMk(module)
+}
+
+// isExcludedFromAndroidMk returns true if the module is skipped by the Android.mk processing for
+// any reason other than being hidden from Make, in which case it doesn't contribute to the dist
+// either.
+func isExcludedFromAndroidMk(module *ModuleBase) bool {
 	if !module.commonProperties.NamespaceExportedToMake {
 		// TODO(jeffrygaston) do we want to validate that there are no modules being
 		// exported to Kati that depend on this module?
@@ -985,7 +1274,6 @@ func shouldSkipAndroidMkProcessing(module *ModuleBase) bool {
 	}
 
 	return !module.Enabled() ||
-		module.commonProperties.HideFromMake ||
 		// Make does not understand LinuxBionic
 		module.Os() == LinuxBionic ||
 		// Make does not understand LinuxMusl, except when we are building with USE_HOST_MUSL=true
@@ -17,11 +17,13 @@ package android
 import (
 	"fmt"
 	"io"
+	"os"
 	"reflect"
 	"runtime"
 	"strings"
 	"testing"
 
+	"github.com/google/blueprint"
 	"github.com/google/blueprint/proptools"
 )
 
@@ -140,11 +142,101 @@ func customModuleFactory() Module {
 	return module
 }
 
-// buildContextAndCustomModuleFoo creates a config object, processes the supplied
-// bp module and then returns the config and the custom module called "foo".
-func buildContextAndCustomModuleFoo(t *testing.T, bp string) (*TestContext, *customModule) {
+// prebuiltCustomModule is a prebuilt of a customModule, which hides the source module from Make
+// when it is preferred.
+type prebuiltCustomModule struct {
+	ModuleBase
+	prebuilt Prebuilt
+
+	properties struct {
+		Srcs []string `android:"path"`
+	}
+}
+
+func (m *prebuiltCustomModule) Name() string {
+	return m.prebuilt.Name(m.ModuleBase.Name())
+}
+
+func (m *prebuiltCustomModule) Prebuilt() *Prebuilt {
+	return &m.prebuilt
+}
+
+func (m *prebuiltCustomModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+}
+
+func prebuiltCustomModuleFactory() Module {
+	module := &prebuiltCustomModule{}
+
+	module.AddProperties(&module.properties)
+
+	InitPrebuiltModule(module, &module.properties.Srcs)
+	InitAndroidModule(module)
+	return module
+}
+
+// prepareForTestWithPreferredCustomPrebuilts registers the custom_prebuilt module type along with
+// the prebuilt mutators that hide a source module from Make when its prebuilt is preferred.
+var prepareForTestWithPreferredCustomPrebuilts = GroupFixturePreparers(
+	PrepareForTestWithPrebuilts,
+	FixtureRegisterWithContext(func(ctx RegistrationContext) {
+		ctx.RegisterModuleType("custom_prebuilt", prebuiltCustomModuleFactory)
+	}),
+	FixtureMergeMockFs(MockFS{
+		"prebuilt.out": nil,
+	}),
+)
+
+// preferredCustomPrebuiltsBp defines two source modules that are replaced by preferred prebuilts,
+// one of which keeps distributing its outputs while hidden.
+const preferredCustomPrebuiltsBp = `
+	custom {
+		name: "foo",
+		dists: [
+			{
+				targets: ["droidcore"],
+				dest: "foo.out",
+			},
+		],
+	}
+
+	custom_prebuilt {
+		name: "foo",
+		prefer: true,
+		srcs: ["prebuilt.out"],
+	}
+
+	custom {
+		name: "bar",
+		dist_when_hidden: true,
+		dists: [
+			{
+				targets: ["droidcore"],
+				dest: "bar.out",
+			},
+		],
+	}
+
+	custom_prebuilt {
+		name: "bar",
+		prefer: true,
+		srcs: ["prebuilt.out"],
+	}
+`
+
+func customArchModuleFactory() Module {
+	module := &customModule{}
+
+	module.AddProperties(&module.properties)
+
+	InitAndroidArchModule(module, HostAndDeviceSupported, MultilibBoth)
+	return module
+}
+
+// buildResultWithCustomModuleFoo processes the supplied bp module, which must contain a custom
+// module called "foo", and returns the result.
+func buildResultWithCustomModuleFoo(t *testing.T, bp string) *TestResult {
 	t.Helper()
-	result := GroupFixturePreparers(
+	return GroupFixturePreparers(
 		// Enable androidmk Singleton
 		PrepareForTestWithAndroidMk,
 		FixtureRegisterWithContext(func(ctx RegistrationContext) {
@@ -155,7 +247,13 @@ func buildContextAndCustomModuleFoo(t *testing.T, bp string) (*TestContext, *cus
 		}),
 		FixtureWithRootAndroidBp(bp),
 	).RunTest(t)
+}
 
+// buildContextAndCustomModuleFoo creates a config object, processes the supplied
+// bp module and then returns the config and the custom module called "foo".
+func buildContextAndCustomModuleFoo(t *testing.T, bp string) (*TestContext, *customModule) {
+	t.Helper()
+	result := buildResultWithCustomModuleFoo(t, bp)
 	module := result.ModuleForTests("foo", "").Module().(*customModule)
 	return result.TestContext, module
 }
@@ -246,134 +344,74 @@ func TestGetDistForGoals(t *testing.T) {
 			}
 			`
 
-	expectedAndroidMkLines := []string{
-		".PHONY: my_second_goal\n",
-		"$(if $(strip $(ALL_TARGETS.two.out.META_LIC)),,$(eval ALL_TARGETS.two.out.META_LIC := meta_lic))\n",
-		"$(call dist-for-goals,my_second_goal,two.out:two.out)\n",
-		"$(if $(strip $(ALL_TARGETS.three/four.out.META_LIC)),,$(eval ALL_TARGETS.three/four.out.META_LIC := meta_lic))\n",
-		"$(call dist-for-goals,my_second_goal,three/four.out:four.out)\n",
-		".PHONY: my_third_goal\n",
-		"$(if $(strip $(ALL_TARGETS.one.out.META_LIC)),,$(eval ALL_TARGETS.one.out.META_LIC := meta_lic))\n",
-		"$(call dist-for-goals,my_third_goal,one.out:test/dir/one.out)\n",
-		".PHONY: my_fourth_goal\n",
-		"$(if $(strip $(ALL_TARGETS.one.out.META_LIC)),,$(eval ALL_TARGETS.one.out.META_LIC := meta_lic))\n",
-		"$(call dist-for-goals,my_fourth_goal,one.out:one.suffix.out)\n",
-		".PHONY: my_fifth_goal\n",
-		"$(if $(strip $(ALL_TARGETS.one.out.META_LIC)),,$(eval ALL_TARGETS.one.out.META_LIC := meta_lic))\n",
-		"$(call dist-for-goals,my_fifth_goal,one.out:new-name)\n",
-		".PHONY: my_sixth_goal\n",
-		"$(if $(strip $(ALL_TARGETS.one.out.META_LIC)),,$(eval ALL_TARGETS.one.out.META_LIC := meta_lic))\n",
-		"$(call dist-for-goals,my_sixth_goal,one.out:some/dir/new-name.suffix)\n",
-		".PHONY: my_goal my_other_goal\n",
-		"$(if $(strip $(ALL_TARGETS.two.out.META_LIC)),,$(eval ALL_TARGETS.two.out.META_LIC := meta_lic))\n",
-		"$(call dist-for-goals,my_goal my_other_goal,two.out:two.out)\n",
-		"$(if $(strip $(ALL_TARGETS.three/four.out.META_LIC)),,$(eval ALL_TARGETS.three/four.out.META_LIC := meta_lic))\n",
-		"$(call dist-for-goals,my_goal my_other_goal,three/four.out:four.out)\n",
-	}
-
-	ctx, module := buildContextAndCustomModuleFoo(t, bp)
-	entries := AndroidMkEntriesForTest(t, ctx, module)
-	if len(entries) != 1 {
-		t.Errorf("Expected a single AndroidMk entry, got %d", len(entries))
-	}
-	androidMkLines := entries[0].GetDistForGoals(module)
-
-	if len(androidMkLines) != len(expectedAndroidMkLines) {
-		t.Errorf(
-			"Expected %d AndroidMk lines, got %d:\n%v",
-			len(expectedAndroidMkLines),
-			len(androidMkLines),
-			androidMkLines,
-		)
-	}
-	for idx, line := range androidMkLines {
-		expectedLine := strings.ReplaceAll(expectedAndroidMkLines[idx], "meta_lic", module.base().licenseMetadataFile.String())
-		if line != expectedLine {
-			t.Errorf(
-				"Expected AndroidMk line to be '%s', got '%s'",
-				expectedLine,
-				line,
-			)
-		}
+	expected := &TestingDistContributions{
+		LicenseMetadataFile: "out/soong/meta_lic",
+		CopiesForGoals: []TestingDistCopiesForGoals{
+			{
+				Goals: []string{"my_second_goal"},
+				Copies: []TestingDistCopy{
+					{From: "two.out", Dest: "two.out"},
+					{From: "three/four.out", Dest: "four.out"},
+				},
+			},
+			{
+				Goals:  []string{"my_third_goal"},
+				Copies: []TestingDistCopy{{From: "one.out", Dest: "test/dir/one.out"}},
+			},
+			{
+				Goals:  []string{"my_fourth_goal"},
+				Copies: []TestingDistCopy{{From: "one.out", Dest: "one.suffix.out"}},
+			},
+			{
+				Goals:  []string{"my_fifth_goal"},
+				Copies: []TestingDistCopy{{From: "one.out", Dest: "new-name"}},
+			},
+			{
+				Goals:  []string{"my_sixth_goal"},
+				Copies: []TestingDistCopy{{From: "one.out", Dest: "some/dir/new-name.suffix"}},
+			},
+			{
+				Goals: []string{"my_goal", "my_other_goal"},
+				Copies: []TestingDistCopy{
+					{From: "two.out", Dest: "two.out"},
+					{From: "three/four.out", Dest: "four.out"},
+				},
+			},
+		},
 	}
+
+	result := buildResultWithCustomModuleFoo(t, bp)
+	AssertDeepEquals(t, "dist contributions", expected, DistContributionsForTest(t, result, "foo", ""))
 }
 
 func distCopyForTest(from, to string) distCopy {
 	return distCopy{PathForTesting(from), to}
 }
 
-func TestGetDistContributions(t *testing.T) {
-	compareContributions := func(d1 *distContributions, d2 *distContributions) error {
-		if d1 == nil || d2 == nil {
-			if d1 != d2 {
-				return fmt.Errorf("pointer mismatch, expected both to be nil but they were %p and %p", d1, d2)
-			} else {
-				return nil
-			}
-		}
-		if expected, actual := len(d1.copiesForGoals), len(d2.copiesForGoals); expected != actual {
-			return fmt.Errorf("length mismatch, expected %d found %d", expected, actual)
-		}
-
-		for i, copies1 := range d1.copiesForGoals {
-			copies2 := d2.copiesForGoals[i]
-			if expected, actual := copies1.goals, copies2.goals; expected != actual {
-				return fmt.Errorf("goals mismatch at position %d: expected %q found %q", i, expected, actual)
-			}
-
-			if expected, actual := len(copies1.copies), len(copies2.copies); expected != actual {
-				return fmt.Errorf("length mismatch in copy instructions at position %d, expected %d found %d", i, expected, actual)
-			}
-
-			for j, c1 := range copies1.copies {
-				c2 := copies2.copies[j]
-				if expected, actual := NormalizePathForTesting(c1.from), NormalizePathForTesting(c2.from); expected != actual {
-					return fmt.Errorf("paths mismatch at position %d.%d: expected %q found %q", i, j, expected, actual)
-				}
-
-				if expected, actual := c1.dest, c2.dest; expected != actual {
-					return fmt.Errorf("dest mismatch at position %d.%d: expected %q found %q", i, j, expected, actual)
-				}
-			}
-		}
-
-		return nil
-	}
+func TestAddLicenseCopyInstructions(t *testing.T) {
+	copies := &copiesForGoals{goals: "my_goal"}
+	copies.addLicenseCopyInstructions(PathForTesting("meta_lic"),
+		PathsForTesting("a/NOTICE", "b/NOTICE", "LICENSE"), "foo.jar")
+	copies.addLicenseCopyInstructions(nil, PathsForTesting("LICENSE"), "dir/bar.jar")
 
-	formatContributions := func(d *distContributions) string {
-		buf := &strings.Builder{}
-		if d == nil {
-			fmt.Fprint(buf, "nil")
-		} else {
-			for _, copiesForGoals := range d.copiesForGoals {
-				fmt.Fprintf(buf, "    Goals: %q {\n", copiesForGoals.goals)
-				for _, c := range copiesForGoals.copies {
-					fmt.Fprintf(buf, "        %s -> %s\n", NormalizePathForTesting(c.from), c.dest)
-				}
-				fmt.Fprint(buf, "    }\n")
-			}
-		}
-		return buf.String()
-	}
+	AssertDeepEquals(t, "copies", []distCopy{
+		distCopyForTest("meta_lic", "licenses/foo.jar.meta_lic"),
+		distCopyForTest("a/NOTICE", "licenses/foo.jar.0.NOTICE"),
+		distCopyForTest("b/NOTICE", "licenses/foo.jar.1.NOTICE"),
+		distCopyForTest("LICENSE", "licenses/foo.jar.2.LICENSE"),
+		distCopyForTest("LICENSE", "dir/licenses/bar.jar.0.LICENSE"),
+	}, copies.copies)
+}
 
-	testHelper := func(t *testing.T, name, bp string, expectedContributions *distContributions) {
+func TestGetDistContributions(t *testing.T) {
+	testHelper := func(t *testing.T, name, bp string, expectedContributions *TestingDistContributions) {
 		t.Helper()
 		t.Run(name, func(t *testing.T) {
 			t.Helper()
 
-			ctx, module := buildContextAndCustomModuleFoo(t, bp)
-			entries := AndroidMkEntriesForTest(t, ctx, module)
-			if len(entries) != 1 {
-				t.Errorf("Expected a single AndroidMk entry, got %d", len(entries))
-			}
-			distContributions := entries[0].getDistContributions(module)
-
-			if err := compareContributions(expectedContributions, distContributions); err != nil {
-				t.Errorf("%s\nExpected Contributions\n%sActualContributions\n%s",
-					err,
-					formatContributions(expectedContributions),
-					formatContributions(distContributions))
-			}
+			result := buildResultWithCustomModuleFoo(t, bp)
+			AssertDeepEquals(t, "dist contributions", expectedContributions,
+				DistContributionsForTest(t, result, "foo", ""))
 		})
 	}
 
@@ -385,12 +423,13 @@ func TestGetDistContributions(t *testing.T) {
 				}
 			}
 `,
-		&distContributions{
-			copiesForGoals: []*copiesForGoals{
+		&TestingDistContributions{
+			LicenseMetadataFile: "out/soong/meta_lic",
+			CopiesForGoals: []TestingDistCopiesForGoals{
 				{
-					goals: "my_goal",
-					copies: []distCopy{
-						distCopyForTest("one.out", "one.out"),
+					Goals: []string{"my_goal"},
+					Copies: []TestingDistCopy{
+						{From: "one.out", Dest: "one.out"},
 					},
 				},
 			},
@@ -405,12 +444,36 @@ func TestGetDistContributions(t *testing.T) {
 				}
 			}
 `,
-		&distContributions{
-			copiesForGoals: []*copiesForGoals{
+		&TestingDistContributions{
+			LicenseMetadataFile: "out/soong/meta_lic",
+			CopiesForGoals: []TestingDistCopiesForGoals{
 				{
-					goals: "my_goal",
-					copies: []distCopy{
-						distCopyForTest("another.out", "another.out"),
+					Goals: []string{"my_goal"},
+					Copies: []TestingDistCopy{
+						{From: "another.out", Dest: "another.out"},
+					},
+				},
+			},
+		})
+
+	testHelper(t, "dist-with-license", `
+			custom {
+				name: "foo",
+				dist: {
+					targets: ["my_goal"],
+					dir: "some/dir",
+					with_license: true,
+				}
+			}
+`,
+		&TestingDistContributions{
+			LicenseMetadataFile: "out/soong/meta_lic",
+			CopiesForGoals: []TestingDistCopiesForGoals{
+				{
+					Goals: []string{"my_goal"},
+					Copies: []TestingDistCopy{
+						{From: "one.out", Dest: "some/dir/one.out"},
+						{From: "meta_lic", Dest: "some/dir/licenses/one.out.meta_lic"},
 					},
 				},
 			},
@@ -424,12 +487,13 @@ func TestGetDistContributions(t *testing.T) {
 					append_artifact_with_product: true,
 				}
 			}
-`, &distContributions{
-		copiesForGoals: []*copiesForGoals{
+`, &TestingDistContributions{
+		LicenseMetadataFile: "out/soong/meta_lic",
+		CopiesForGoals: []TestingDistCopiesForGoals{
 			{
-				goals: "my_goal",
-				copies: []distCopy{
-					distCopyForTest("one.out", "one_bar.out"),
+				Goals: []string{"my_goal"},
+				Copies: []TestingDistCopy{
+					{From: "one.out", Dest: "one_bar.out"},
 				},
 			},
 		},
@@ -446,12 +510,13 @@ func TestGetDistContributions(t *testing.T) {
 				],
 			}
 `,
-		&distContributions{
-			copiesForGoals: []*copiesForGoals{
+		&TestingDistContributions{
+			LicenseMetadataFile: "out/soong/meta_lic",
+			CopiesForGoals: []TestingDistCopiesForGoals{
 				{
-					goals: "my_goal",
-					copies: []distCopy{
-						distCopyForTest("another.out", "another.out"),
+					Goals: []string{"my_goal"},
+					Copies: []TestingDistCopy{
+						{From: "another.out", Dest: "another.out"},
 					},
 				},
 			},
@@ -470,18 +535,19 @@ func TestGetDistContributions(t *testing.T) {
 				],
 			}
 `,
-		&distContributions{
-			copiesForGoals: []*copiesForGoals{
+		&TestingDistContributions{
+			LicenseMetadataFile: "out/soong/meta_lic",
+			CopiesForGoals: []TestingDistCopiesForGoals{
 				{
-					goals: "my_goal",
-					copies: []distCopy{
-						distCopyForTest("one.out", "one.out"),
+					Goals: []string{"my_goal"},
+					Copies: []TestingDistCopy{
+						{From: "one.out", Dest: "one.out"},
 					},
 				},
 				{
-					goals: "my_second_goal my_third_goal",
-					copies: []distCopy{
-						distCopyForTest("one.out", "one.out"),
+					Goals: []string{"my_second_goal", "my_third_goal"},
+					Copies: []TestingDistCopy{
+						{From: "one.out", Dest: "one.out"},
 					},
 				},
 			},
@@ -500,18 +566,19 @@ func TestGetDistContributions(t *testing.T) {
 				],
 			}
 `,
-		&distContributions{
-			copiesForGoals: []*copiesForGoals{
+		&TestingDistContributions{
+			LicenseMetadataFile: "out/soong/meta_lic",
+			CopiesForGoals: []TestingDistCopiesForGoals{
 				{
-					goals: "my_second_goal my_third_goal",
-					copies: []distCopy{
-						distCopyForTest("one.out", "one.out"),
+					Goals: []string{"my_second_goal", "my_third_goal"},
+					Copies: []TestingDistCopy{
+						{From: "one.out", Dest: "one.out"},
 					},
 				},
 				{
-					goals: "my_goal",
-					copies: []distCopy{
-						distCopyForTest("one.out", "one.out"),
+					Goals: []string{"my_goal"},
+					Copies: []TestingDistCopy{
+						{From: "one.out", Dest: "one.out"},
 					},
 				},
 			},
@@ -550,44 +617,45 @@ func TestGetDistContributions(t *testing.T) {
 				],
 			}
 `,
-		&distContributions{
-			copiesForGoals: []*copiesForGoals{
+		&TestingDistContributions{
+			LicenseMetadataFile: "out/soong/meta_lic",
+			CopiesForGoals: []TestingDistCopiesForGoals{
 				{
-					goals: "my_second_goal",
-					copies: []distCopy{
-						distCopyForTest("two.out", "two.out"),
-						distCopyForTest("three/four.out", "four.out"),
+					Goals: []string{"my_second_goal"},
+					Copies: []TestingDistCopy{
+						{From: "two.out", Dest: "two.out"},
+						{From: "three/four.out", Dest: "four.out"},
 					},
 				},
 				{
-					goals: "my_third_goal",
-					copies: []distCopy{
-						distCopyForTest("one.out", "test/dir/one.out"),
+					Goals: []string{"my_third_goal"},
+					Copies: []TestingDistCopy{
+						{From: "one.out", Dest: "test/dir/one.out"},
 					},
 				},
 				{
-					goals: "my_fourth_goal",
-					copies: []distCopy{
-						distCopyForTest("one.out", "one.suffix.out"),
+					Goals: []string{"my_fourth_goal"},
+					Copies: []TestingDistCopy{
+						{From: "one.out", Dest: "one.suffix.out"},
 					},
 				},
 				{
-					goals: "my_fifth_goal",
-					copies: []distCopy{
-						distCopyForTest("one.out", "new-name"),
+					Goals: []string{"my_fifth_goal"},
+					Copies: []TestingDistCopy{
+						{From: "one.out", Dest: "new-name"},
 					},
 				},
 				{
-					goals: "my_sixth_goal",
-					copies: []distCopy{
-						distCopyForTest("one.out", "some/dir/new-name.suffix"),
+					Goals: []string{"my_sixth_goal"},
+					Copies: []TestingDistCopy{
+						{From: "one.out", Dest: "some/dir/new-name.suffix"},
 					},
 				},
 				{
-					goals: "my_goal my_other_goal",
-					copies: []distCopy{
-						distCopyForTest("two.out", "two.out"),
-						distCopyForTest("three/four.out", "four.out"),
+					Goals: []string{"my_goal", "my_other_goal"},
+					Copies: []TestingDistCopy{
+						{From: "two.out", Dest: "two.out"},
+						{From: "three/four.out", Dest: "four.out"},
 					},
 				},
 			},
@@ -611,19 +679,20 @@ func TestGetDistContributions(t *testing.T) {
 					},
 				],
 			}
-`, &distContributions{
-		copiesForGoals: []*copiesForGoals{
+`, &TestingDistContributions{
+		LicenseMetadataFile: "out/soong/meta_lic",
+		CopiesForGoals: []TestingDistCopiesForGoals{
 			{
-				goals: "my_goal",
-				copies: []distCopy{
-					distCopyForTest("one.out", "one.out"),
+				Goals: []string{"my_goal"},
+				Copies: []TestingDistCopy{
+					{From: "one.out", Dest: "one.out"},
 				},
 			},
 			{
-				goals: "my_goal",
-				copies: []distCopy{
-					distCopyForTest("two.out", "two.out"),
-					distCopyForTest("three/four.out", "four.out"),
+				Goals: []string{"my_goal"},
+				Copies: []TestingDistCopy{
+					{From: "two.out", Dest: "two.out"},
+					{From: "three/four.out", Dest: "four.out"},
 				},
 			},
 		},
@@ -644,19 +713,20 @@ func TestGetDistContributions(t *testing.T) {
 					},
 				],
 			}
-`, &distContributions{
-		copiesForGoals: []*copiesForGoals{
+`, &TestingDistContributions{
+		LicenseMetadataFile: "out/soong/meta_lic",
+		CopiesForGoals: []TestingDistCopiesForGoals{
 			{
-				goals: "my_goal",
-				copies: []distCopy{
-					distCopyForTest("default-dist.out", "default-dist.out"),
+				Goals: []string{"my_goal"},
+				Copies: []TestingDistCopy{
+					{From: "default-dist.out", Dest: "default-dist.out"},
 				},
 			},
 			{
-				goals: "my_goal",
-				copies: []distCopy{
-					distCopyForTest("two.out", "two.out"),
-					distCopyForTest("three/four.out", "four.out"),
+				Goals: []string{"my_goal"},
+				Copies: []TestingDistCopy{
+					{From: "two.out", Dest: "two.out"},
+					{From: "three/four.out", Dest: "four.out"},
 				},
 			},
 		},
@@ -679,13 +749,14 @@ func TestGetDistContributions(t *testing.T) {
 					},
 				],
 			}
-`, &distContributions{
-		copiesForGoals: []*copiesForGoals{
+`, &TestingDistContributions{
+		LicenseMetadataFile: "out/soong/meta_lic",
+		CopiesForGoals: []TestingDistCopiesForGoals{
 			{
-				goals: "my_goal",
-				copies: []distCopy{
-					distCopyForTest("two.out", "two.out"),
-					distCopyForTest("three/four.out", "four.out"),
+				Goals: []string{"my_goal"},
+				Copies: []TestingDistCopy{
+					{From: "two.out", Dest: "two.out"},
+					{From: "three/four.out", Dest: "four.out"},
 				},
 			},
 		},
@@ -706,19 +777,20 @@ func TestGetDistContributions(t *testing.T) {
 					},
 				],
 			}
-`, &distContributions{
-		copiesForGoals: []*copiesForGoals{
+`, &TestingDistContributions{
+		LicenseMetadataFile: "out/soong/meta_lic",
+		CopiesForGoals: []TestingDistCopiesForGoals{
 			{
-				goals: "my_goal",
-				copies: []distCopy{
-					distCopyForTest("one.out", "one.out"),
+				Goals: []string{"my_goal"},
+				Copies: []TestingDistCopy{
+					{From: "one.out", Dest: "one.out"},
 				},
 			},
 			{
-				goals: "my_goal",
-				copies: []distCopy{
-					distCopyForTest("two.out", "two.out"),
-					distCopyForTest("three/four.out", "four.out"),
+				Goals: []string{"my_goal"},
+				Copies: []TestingDistCopy{
+					{From: "two.out", Dest: "two.out"},
+					{From: "three/four.out", Dest: "four.out"},
 				},
 			},
 		},
@@ -739,19 +811,20 @@ func TestGetDistContributions(t *testing.T) {
 					},
 				],
 			}
-`, &distContributions{
-		copiesForGoals: []*copiesForGoals{
+`, &TestingDistContributions{
+		LicenseMetadataFile: "out/soong/meta_lic",
+		CopiesForGoals: []TestingDistCopiesForGoals{
 			{
-				goals: "my_goal",
-				copies: []distCopy{
-					distCopyForTest("default-dist.out", "default-dist.out"),
+				Goals: []string{"my_goal"},
+				Copies: []TestingDistCopy{
+					{From: "default-dist.out", Dest: "default-dist.out"},
 				},
 			},
 			{
-				goals: "my_goal",
-				copies: []distCopy{
-					distCopyForTest("two.out", "two.out"),
-					distCopyForTest("three/four.out", "four.out"),
+				Goals: []string{"my_goal"},
+				Copies: []TestingDistCopy{
+					{From: "two.out", Dest: "two.out"},
+					{From: "three/four.out", Dest: "four.out"},
 				},
 			},
 		},
@@ -772,21 +845,819 @@ func TestGetDistContributions(t *testing.T) {
 					},
 				],
 			}
-`, &distContributions{
-		copiesForGoals: []*copiesForGoals{
+`, &TestingDistContributions{
+		LicenseMetadataFile: "out/soong/meta_lic",
+		CopiesForGoals: []TestingDistCopiesForGoals{
 			{
-				goals: "my_goal",
-				copies: []distCopy{
-					distCopyForTest("dist-output-file.out", "dist-output-file.out"),
+				Goals: []string{"my_goal"},
+				Copies: []TestingDistCopy{
+					{From: "dist-output-file.out", Dest: "dist-output-file.out"},
 				},
 			},
 			{
-				goals: "my_goal",
-				copies: []distCopy{
-					distCopyForTest("two.out", "two.out"),
-					distCopyForTest("three/four.out", "four.out"),
+				Goals: []string{"my_goal"},
+				Copies: []TestingDistCopy{
+					{From: "two.out", Dest: "two.out"},
+					{From: "three/four.out", Dest: "four.out"},
+				},
+			},
+		},
+	})
+}
+
+func TestDistContributionsForTest(t *testing.T) {
+	result := buildResultWithCustomModuleFoo(t, `
+		custom {
+			name: "foo",
+			dists: [
+				{
+					targets: ["my_goal", "my_other_goal"],
+				},
+				{
+					targets: ["my_goal"],
+					tag: ".multiple",
+					dir: "dir",
+				},
+			],
+		}
+	`)
+
+	contributions := DistContributionsForTest(t, result, "foo", "")
+	module := result.ModuleForTests("foo", "").Module()
+	AssertStringEquals(t, "license metadata", PathRelativeToTop(module.base().licenseMetadataFile),
+		contributions.LicenseMetadataFile)
+	AssertDeepEquals(t, "copies", []TestingDistCopiesForGoals{
+		{
+			Goals:  []string{"my_goal", "my_other_goal"},
+			Copies: []TestingDistCopy{{From: "one.out", Dest: "one.out"}},
+		},
+		{
+			Goals: []string{"my_goal"},
+			Copies: []TestingDistCopy{
+				{From: "two.out", Dest: "dir/two.out"},
+				{From: "three/four.out", Dest: "dir/four.out"},
+			},
+		},
+	}, contributions.CopiesForGoals)
+
+	AssertDeepEquals(t, "my_goal dests", []string{"one.out", "dir/two.out", "dir/four.out"},
+		contributions.DestsForGoal("my_goal"))
+	AssertDeepEquals(t, "my_other_goal dests", []string{"one.out"}, contributions.DestsForGoal("my_other_goal"))
+	AssertDeepEquals(t, "unknown goal dests", []string(nil), contributions.DestsForGoal("my"))
+
+	AssertDistsTo(t, result, "foo", "", "my_goal", "dir/four.out")
+	AssertDistsTo(t, result, "foo", "", "my_other_goal", "one.out")
+	AssertDoesNotDistTo(t, result, "foo", "", "my_other_goal", "dir/four.out")
+	AssertDoesNotDistTo(t, result, "foo", "", "my_goal", "four.out")
+
+	var nilContributions *TestingDistContributions
+	AssertDeepEquals(t, "nil dests", []string(nil), nilContributions.DestsForGoal("my_goal"))
+}
+
+func TestModuleDistPhony(t *testing.T) {
+	result := GroupFixturePreparers(
+		PrepareForTestWithAndroidMk,
+		FixtureRegisterWithContext(func(ctx RegistrationContext) {
+			ctx.RegisterModuleType("custom", customModuleFactory)
+		}),
+		FixtureModifyConfig(SetKatiEnabledForTests),
+	).RunTestWithBp(t, `
+		custom {
+			name: "foo",
+			dists: [
+				{
+					targets: ["my_goal", "my_other_goal"],
+				},
+				{
+					targets: ["my_goal"],
+					tag: ".multiple",
+					dir: "dir",
+					suffix: "_suffix",
+				},
+				{
+					targets: ["my_other_goal"],
+					dest: "one.out",
+				},
+			],
+		}
+	`)
+
+	// When Kati runs, the -dist phony targets are created while translating the modules.
+	phonies := getPhonyMap(result.Config)
+	AssertPathsRelativeToTopEquals(t, "foo-dist", []string{"out/soong/module_dist/foo-dist.stamp"}, phonies["foo-dist"])
+
+	singleton := result.SingletonForTests("androidmk")
+	for _, dest := range []string{"one.out", "dir/two_suffix.out", "dir/four_suffix.out"} {
+		AssertStringEquals(t, "rule", Cp.String(), singleton.Output("dist/"+dest).Rule.String())
+	}
+	AssertStringEquals(t, "input", "three/four.out", singleton.Output("dist/dir/four_suffix.out").Input.String())
+
+	// The staged files are copied to the dist directory, which is out/dist without DIST_DIR.
+	stamp := singleton.Output("module_dist/foo-dist.stamp")
+	AssertPathsRelativeToTopEquals(t, "staged", []string{
+		"out/soong/dist/one.out",
+		"out/soong/dist/dir/two_suffix.out",
+		"out/soong/dist/dir/four_suffix.out",
+	}, stamp.Implicits)
+	AssertStringEquals(t, "distDir", "out/dist", StringRelativeToTop(result.Config, stamp.Args["distDir"]))
+	AssertStringEquals(t, "dests", "one.out dir/two_suffix.out dir/four_suffix.out", stamp.Args["dests"])
+}
+
+func TestGetDistContributionsExcludeFromDistGoals(t *testing.T) {
+	bp := `
+		custom {
+			name: "foo",
+			dists: [
+				{
+					targets: ["droidcore", "my_goal"],
+				},
+				{
+					targets: ["sdk", "sdk_addon"],
+					tag: ".another-tag",
+				},
+				{
+					targets: ["other_goal"],
+					tag: ".another-tag",
+				},
+			],
+		}
+	`
+
+	result := GroupFixturePreparers(
+		PrepareForTestWithAndroidMk,
+		FixtureRegisterWithContext(func(ctx RegistrationContext) {
+			ctx.RegisterModuleType("custom", customModuleFactory)
+		}),
+		FixtureModifyProductVariables(func(variables FixtureProductVariables) {
+			variables.Exclude_from_dist_goals = []string{
+				"foo:droidcore",
+				"foo:sdk*",
+				"bar:other_goal",
+			}
+		}),
+		FixtureWithRootAndroidBp(bp),
+	).RunTest(t)
+
+	var goals []string
+	for _, copiesForGoals := range DistContributionsForTest(t, result, "foo", "").CopiesForGoals {
+		goals = append(goals, strings.Join(copiesForGoals.Goals, " "))
+	}
+	AssertDeepEquals(t, "remaining goals", []string{"my_goal", "other_goal"}, goals)
+
+	report := result.SingletonForTests("androidmk").Output("excluded_dist_goals.txt")
+	AssertTrimmedStringEquals(t, "excluded_dist_goals.txt", "foo:droidcore\nfoo:sdk\nfoo:sdk_addon",
+		ContentFromFileRuleForTests(t, result.TestContext, report))
+}
+
+func TestGetDistContributionsInProductDir(t *testing.T) {
+	bp := `
+		custom {
+			name: "foo",
+			dists: [
+				{
+					targets: ["my_goal"],
+					dir: "some/dir",
+				},
+				{
+					targets: ["my_goal"],
+					tag: ".another-tag",
+					append_artifact_with_product: true,
+				},
+				{
+					targets: ["my_goal"],
+					tag: ".multiple",
+					append_artifact_with_product: true,
+					product_dir: false,
+				},
+			],
+		}
+	`
+
+	run := func(t *testing.T, distInProductDir bool) *TestResult {
+		return GroupFixturePreparers(
+			PrepareForTestWithAndroidMk,
+			FixtureRegisterWithContext(func(ctx RegistrationContext) {
+				ctx.RegisterModuleType("custom", customModuleFactory)
+			}),
+			FixtureModifyProductVariables(func(variables FixtureProductVariables) {
+				variables.DeviceProduct = proptools.StringPtr("bar")
+				variables.Dist_in_product_dir = proptools.BoolPtr(distInProductDir)
+			}),
+			FixtureWithRootAndroidBp(bp),
+		).RunTest(t)
+	}
+
+	t.Run("on", func(t *testing.T) {
+		result := run(t, true)
+		AssertDistsTo(t, result, "foo", "", "my_goal", "bar/some/dir/one.out")
+		// The product is not appended to the artifact in the product directory.
+		AssertDistsTo(t, result, "foo", "", "my_goal", "bar/another.out")
+		// The dist that opts out is still appended with the product.
+		AssertDistsTo(t, result, "foo", "", "my_goal", "two_bar.out")
+		AssertDistsTo(t, result, "foo", "", "my_goal", "four_bar.out")
+	})
+
+	t.Run("off", func(t *testing.T) {
+		result := run(t, false)
+		AssertDistsTo(t, result, "foo", "", "my_goal", "some/dir/one.out")
+		AssertDistsTo(t, result, "foo", "", "my_goal", "another_bar.out")
+		AssertDistsTo(t, result, "foo", "", "my_goal", "two_bar.out")
+		AssertDistsTo(t, result, "foo", "", "my_goal", "four_bar.out")
+	})
+}
+
+// makeVarsDistModule dists files through its MakeVarsContext.
+type makeVarsDistModule struct {
+	ModuleBase
+}
+
+func (m *makeVarsDistModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+}
+
+func (m *makeVarsDistModule) MakeVars(ctx MakeVarsModuleContext) {
+	ctx.DistForGoal("my_goal", PathForTesting("one.out"), PathForTesting("dir/two.out"))
+	ctx.DistForGoalWithFilename("my_goal", PathForTesting("three.out"), "renamed.out")
+}
+
+func TestMakeVarsDistsInProductDir(t *testing.T) {
+	run := func(t *testing.T, distInProductDir bool) []dist {
+		result := GroupFixturePreparers(
+			PrepareForTestAccessingMakeVars,
+			FixtureRegisterWithContext(func(ctx RegistrationContext) {
+				ctx.RegisterModuleType("makevars_dist", func() Module {
+					module := &makeVarsDistModule{}
+					InitAndroidModule(module)
+					return module
+				})
+			}),
+			FixtureModifyProductVariables(func(variables FixtureProductVariables) {
+				variables.DeviceProduct = proptools.StringPtr("bar")
+				variables.Dist_in_product_dir = proptools.BoolPtr(distInProductDir)
+			}),
+			FixtureWithRootAndroidBp(`
+				makevars_dist {
+					name: "foo",
+				}
+			`),
+		).RunTest(t)
+		return result.SingletonForTests("makevars").Singleton().(*makeVarsSingleton).distsForTesting
+	}
+
+	t.Run("on", func(t *testing.T) {
+		AssertDeepEquals(t, "dists", []dist{
+			{goals: []string{"my_goal"}, paths: []string{"three.out:bar/renamed.out"}},
+			{goals: []string{"my_goal"}, paths: []string{"one.out:bar/one.out", "dir/two.out:bar/two.out"}},
+		}, run(t, true))
+	})
+
+	t.Run("off", func(t *testing.T) {
+		AssertDeepEquals(t, "dists", []dist{
+			{goals: []string{"my_goal"}, paths: []string{"three.out:renamed.out"}},
+			{goals: []string{"my_goal"}, paths: []string{"one.out", "dir/two.out"}},
+		}, run(t, false))
+	})
+}
+
+func TestGetDistContributionsModuleTypeDists(t *testing.T) {
+	bp := `
+		custom_with_type_dists {
+			name: "foo",
+		}
+
+		custom_with_type_dists {
+			name: "bar",
+			dist: {
+				targets: ["my_goal"],
+				tag: ".another-tag",
+				dest: "overridden.out",
+			},
+		}
+
+		custom_with_type_dists {
+			name: "baz",
+		}
+	`
+
+	run := func(t *testing.T, disableModuleTypeDists bool) *TestResult {
+		return GroupFixturePreparers(
+			PrepareForTestWithAndroidMk,
+			FixtureRegisterWithContext(func(ctx RegistrationContext) {
+				ctx.RegisterModuleType("custom_with_type_dists", func() Module {
+					module := customModuleFactory()
+					InitModuleTypeDists(module,
+						Dist{Targets: []string{"type_goal"}},
+						Dist{Targets: []string{"type_goal"}, Tag: proptools.StringPtr(".another-tag")})
+					return module
+				})
+			}),
+			FixtureModifyProductVariables(func(variables FixtureProductVariables) {
+				variables.Exclude_from_dist_goals = []string{"baz:type_goal"}
+				variables.Disable_module_type_dists = proptools.BoolPtr(disableModuleTypeDists)
+			}),
+			FixtureWithRootAndroidBp(bp),
+		).RunTest(t)
+	}
+
+	t.Run("enabled", func(t *testing.T) {
+		result := run(t, false)
+		AssertDistsTo(t, result, "foo", "", "type_goal", "one.out")
+		AssertDistsTo(t, result, "foo", "", "type_goal", "another.out")
+
+		// The dist of the module takes precedence over the dist of the module type with the same
+		// tag, but the dists of the module type with other tags are still used.
+		AssertDistsTo(t, result, "bar", "", "my_goal", "overridden.out")
+		AssertDoesNotDistTo(t, result, "bar", "", "type_goal", "another.out")
+		AssertDistsTo(t, result, "bar", "", "type_goal", "one.out")
+
+		// The dists of the module type are excluded from goals like the dists of the module.
+		AssertDeepEquals(t, "baz type_goal dists", []string(nil),
+			DistContributionsForTest(t, result, "baz", "").DestsForGoal("type_goal"))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		result := run(t, true)
+		AssertDeepEquals(t, "foo type_goal dists", []string(nil),
+			DistContributionsForTest(t, result, "foo", "").DestsForGoal("type_goal"))
+		AssertDistsTo(t, result, "bar", "", "my_goal", "overridden.out")
+		AssertDeepEquals(t, "bar type_goal dists", []string(nil),
+			DistContributionsForTest(t, result, "bar", "").DestsForGoal("type_goal"))
+	})
+}
+
+func TestGetDistForGoalsLicenseMetadataTag(t *testing.T) {
+	bp := `
+		custom {
+			name: "foo",
+			dist: {
+				targets: ["my_goal"],
+				tag: ".meta_lic",
+			},
+		}
+	`
+
+	result := buildResultWithCustomModuleFoo(t, bp)
+	AssertDeepEquals(t, "copies", []TestingDistCopiesForGoals{
+		{
+			Goals:  []string{"my_goal"},
+			Copies: []TestingDistCopy{{From: "out/soong/meta_lic", Dest: "meta_lic"}},
+		},
+	}, DistContributionsForTest(t, result, "foo", "").CopiesForGoals)
+}
+
+func TestGetDistForGoalsArchVariants(t *testing.T) {
+	bp := `
+		custom_arch {
+			name: "foo",
+			dist: {
+				targets: ["my_goal"],
+			},
+			dists: [
+				{
+					targets: ["my_other_goal"],
+				},
+			],
+			arch: {
+				arm: {
+					dist: {
+						suffix: "_arm",
+					},
+				},
+			},
+			target: {
+				android: {
+					dist: {
+						dir: "device",
+					},
+				},
+				android_arm64: {
+					dist: {
+						suffix: "_arm64",
+					},
+					dists: [
+						{
+							targets: ["my_arm64_goal"],
+							dest: "arm64.out",
+						},
+					],
+				},
+				host: {
+					dist: {
+						dir: "host",
+					},
+				},
+			},
+		}
+	`
+
+	result := GroupFixturePreparers(
+		PrepareForTestWithAndroidMk,
+		PrepareForTestWithArchMutator,
+		FixtureRegisterWithContext(func(ctx RegistrationContext) {
+			ctx.RegisterModuleType("custom_arch", customArchModuleFactory)
+		}),
+		FixtureWithRootAndroidBp(bp),
+	).RunTest(t)
+
+	arm64 := "android_arm64_armv8-a"
+	AssertDistsTo(t, result, "foo", arm64, "my_goal", "device/one_arm64.out")
+	AssertDistsTo(t, result, "foo", arm64, "my_other_goal", "one.out")
+	AssertDistsTo(t, result, "foo", arm64, "my_arm64_goal", "arm64.out")
+
+	arm := "android_arm_armv7-a-neon"
+	AssertDistsTo(t, result, "foo", arm, "my_goal", "device/one_arm.out")
+	AssertDistsTo(t, result, "foo", arm, "my_other_goal", "one.out")
+	AssertDoesNotDistTo(t, result, "foo", arm, "my_arm64_goal", "arm64.out")
+
+	host := result.Config.BuildOSTarget.String()
+	AssertDistsTo(t, result, "foo", host, "my_goal", "host/one.out")
+	AssertDistsTo(t, result, "foo", host, "my_other_goal", "one.out")
+}
+
+func TestGetDistForGoalsAppendOs(t *testing.T) {
+	if runtime.GOOS == "darwin" {
+		// Only the darwin variants are exported on Mac, so this test doesn't work.
+		t.SkipNow()
+	}
+
+	prepareForTestWithDarwinHost := FixtureModifyConfig(func(config Config) {
+		config.Targets[Darwin] = []Target{
+			{Darwin, Arch{ArchType: X86_64}, NativeBridgeDisabled, "", "", false},
+		}
+	})
+
+	preparers := func(bp string) FixturePreparer {
+		return GroupFixturePreparers(
+			PrepareForTestWithAndroidMk,
+			PrepareForTestWithArchMutator,
+			prepareForTestWithDarwinHost,
+			FixtureRegisterWithContext(func(ctx RegistrationContext) {
+				ctx.RegisterModuleType("custom_arch", customArchModuleFactory)
+			}),
+			FixtureWithRootAndroidBp(bp),
+		)
+	}
+
+	t.Run("append_os", func(t *testing.T) {
+		result := preparers(`
+			custom_arch {
+				name: "foo",
+				dist: {
+					targets: ["my_goal"],
+					append_os: true,
 				},
+			}
+		`).RunTest(t)
+
+		AssertDistsTo(t, result, "foo", "linux_glibc_x86_64", "my_goal", "one_linux_glibc_x86_64.out")
+		AssertDistsTo(t, result, "foo", "linux_glibc_x86", "my_goal", "one_linux_glibc_x86.out")
+		AssertDistsTo(t, result, "foo", "darwin_x86_64", "my_goal", "one_darwin.out")
+	})
+
+	t.Run("without append_os", func(t *testing.T) {
+		preparers(`
+			custom_arch {
+				name: "foo",
+				dist: {
+					targets: ["my_goal"],
+				},
+			}
+		`).ExtendWithErrorHandler(FixtureExpectsAtLeastOneErrorMatchingPattern(
+			`dist of "one.out" for goal "my_goal" overwrites the dist of the (linux_glibc|darwin) variant of "foo", set append_os: true`,
+		)).RunTest(t)
+	})
+}
+
+// nondeterministicModule sets a different LOCAL_CALLS value each time its Android.mk entries are
+// computed.
+type nondeterministicModule struct {
+	ModuleBase
+
+	calls int
+}
+
+func (m *nondeterministicModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+}
+
+func (m *nondeterministicModule) AndroidMkEntries() []AndroidMkEntries {
+	return []AndroidMkEntries{{
+		Class:      "FAKE",
+		OutputFile: OptionalPathForPath(PathForTesting("out")),
+		ExtraEntries: []AndroidMkExtraEntriesFunc{
+			func(ctx AndroidMkExtraEntriesContext, entries *AndroidMkEntries) {
+				m.calls++
+				entries.SetString("LOCAL_CALLS", fmt.Sprint(m.calls))
 			},
 		},
+	}}
+}
+
+func nondeterministicModuleFactory() Module {
+	module := &nondeterministicModule{}
+	InitAndroidModule(module)
+	return module
+}
+
+func TestVerifyAndroidMkEntries(t *testing.T) {
+	if runtime.GOOS == "darwin" {
+		// Device modules are not exported on Mac, so this test doesn't work.
+		t.SkipNow()
+	}
+
+	GroupFixturePreparers(
+		PrepareForTestWithAndroidMk,
+		FixtureRegisterWithContext(func(ctx RegistrationContext) {
+			ctx.RegisterModuleType("nondeterministic", nondeterministicModuleFactory)
+		}),
+		FixtureWithRootAndroidBp(`
+			nondeterministic {
+				name: "foo",
+			}
+		`),
+	).ExtendWithErrorHandler(FixtureExpectsAtLeastOneErrorMatchingPattern(
+		`non-deterministic Android.mk entries for module "foo" variant "", these differ when computed twice: LOCAL_CALLS`,
+	)).RunTest(t)
+}
+
+func TestDiffAndroidMkEntries(t *testing.T) {
+	entries := func(order []string, values map[string][]string) *AndroidMkEntries {
+		return &AndroidMkEntries{entryOrder: order, EntryMap: values}
+	}
+
+	a := entries([]string{"LOCAL_A", "LOCAL_B", "LOCAL_C"}, map[string][]string{
+		"LOCAL_A": {"a"},
+		"LOCAL_B": {"b1", "b2"},
+		"LOCAL_C": {"c"},
+	})
+	AssertDeepEquals(t, "same entries", []string(nil), diffAndroidMkEntries(a, a))
+
+	b := entries([]string{"LOCAL_A", "LOCAL_C", "LOCAL_B"}, map[string][]string{
+		"LOCAL_A": {"a"},
+		"LOCAL_B": {"b2", "b1"},
+		"LOCAL_C": {"c"},
+	})
+	AssertDeepEquals(t, "reordered entries", []string{"LOCAL_B", "LOCAL_C"}, diffAndroidMkEntries(a, b))
+
+	c := entries([]string{"LOCAL_A", "LOCAL_B"}, map[string][]string{
+		"LOCAL_A": {"a"},
+		"LOCAL_B": {"b1", "b2"},
+	})
+	AssertDeepEquals(t, "missing entry", []string{"LOCAL_C"}, diffAndroidMkEntries(a, c))
+}
+
+func TestAndroidMkShardName(t *testing.T) {
+	testCases := []struct {
+		moduleDir string
+		expected  string
+	}{
+		{"", "root"},
+		{".", "root"},
+		{"frameworks", "frameworks"},
+		{"frameworks/base/core", "frameworks"},
+		{"external/foo+bar/baz", "external"},
+		{"foo+bar/baz", "foo_bar"},
+	}
+	for _, tc := range testCases {
+		AssertStringEquals(t, tc.moduleDir, tc.expected, androidMkShardName(tc.moduleDir))
+	}
+
+	AssertStringEquals(t, "shard file", "out/soong/Android-aosp_arm64-frameworks.mk",
+		androidMkShardFile("out/soong/Android-aosp_arm64.mk", "frameworks"))
+}
+
+func TestAndroidMkMasterFile(t *testing.T) {
+	shardFiles := []string{
+		"out/soong/Android-external.mk",
+		"out/soong/Android-frameworks.mk",
+	}
+	typeStats := map[string]int{
+		"custom":    2,
+		"cc_binary": 1,
+	}
+
+	expected := "" +
+		"include out/soong/Android-external.mk\n" +
+		"include out/soong/Android-frameworks.mk\n" +
+		"\n" +
+		"STATS.SOONG_MODULE_TYPE :=\n" +
+		"STATS.SOONG_MODULE_TYPE += cc_binary\n" +
+		"STATS.SOONG_MODULE_TYPE.cc_binary := 1\n" +
+		"STATS.SOONG_MODULE_TYPE += custom\n" +
+		"STATS.SOONG_MODULE_TYPE.custom := 2\n"
+	AssertStringEquals(t, "master file", expected, string(androidMkMasterFile(shardFiles, typeStats)))
+}
+
+func TestAndroidMkShards(t *testing.T) {
+	if runtime.GOOS == "darwin" {
+		// Device modules are not exported on Mac, so this test doesn't work.
+		t.SkipNow()
+	}
+
+	result := GroupFixturePreparers(
+		PrepareForTestWithAndroidMk,
+		FixtureRegisterWithContext(func(ctx RegistrationContext) {
+			ctx.RegisterModuleType("custom", customModuleFactory)
+		}),
+		FixtureAddTextFile("frameworks/base/Android.bp", `custom { name: "foo" }`),
+		FixtureAddTextFile("frameworks/av/Android.bp", `custom { name: "bar" }`),
+		FixtureAddTextFile("external/baz/Android.bp", `custom { name: "baz" }`),
+	).RunTest(t)
+
+	readFile := func(name string) string {
+		t.Helper()
+		content, err := os.ReadFile(PathForOutput(PathContextForTesting(result.Config), name).String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(content)
+	}
+
+	frameworks := PathForOutput(PathContextForTesting(result.Config), "Android-frameworks.mk").String()
+	external := PathForOutput(PathContextForTesting(result.Config), "Android-external.mk").String()
+	master := readFile("Android.mk")
+	AssertStringDoesContain(t, "master file", master, "include "+external+"\ninclude "+frameworks+"\n")
+	AssertStringDoesContain(t, "master file", master, "STATS.SOONG_MODULE_TYPE.custom := 3\n")
+	AssertStringDoesNotContain(t, "master file", master, "LOCAL_MODULE :=")
+
+	frameworksShard := readFile("Android-frameworks.mk")
+	AssertStringDoesContain(t, "frameworks shard", frameworksShard, "LOCAL_MODULE := foo\n")
+	AssertStringDoesContain(t, "frameworks shard", frameworksShard, "LOCAL_MODULE := bar\n")
+	AssertStringDoesNotContain(t, "frameworks shard", frameworksShard, "LOCAL_MODULE := baz\n")
+
+	externalShard := readFile("Android-external.mk")
+	AssertStringDoesContain(t, "external shard", externalShard, "LOCAL_MODULE := baz\n")
+	AssertStringDoesNotContain(t, "external shard", externalShard, "LOCAL_MODULE := foo\n")
+}
+
+func TestModuleTypeStats(t *testing.T) {
+	if runtime.GOOS == "darwin" {
+		// Device modules are not exported on Mac, so this test doesn't work.
+		t.SkipNow()
+	}
+
+	bp := `
+		custom {
+			name: "foo",
+		}
+
+		custom {
+			name: "bar",
+			enabled: false,
+		}
+
+		custom_arch {
+			name: "baz",
+		}
+
+		custom_arch {
+			name: "qux",
+			arch: {
+				arm: {
+					enabled: false,
+				},
+			},
+		}
+	`
+
+	expected := `{
+  "SchemaVersion": 1,
+  "ModuleTypes": {
+    "custom": {
+      "Modules": 2,
+      "Variants": 2,
+      "EnabledVariants": 1,
+      "DisabledVariants": 1,
+      "ExportedToMakeVariants": 1,
+      "HiddenFromMakeVariants": 1
+    },
+    "custom_arch": {
+      "Modules": 2,
+      "Variants": 4,
+      "EnabledVariants": 3,
+      "DisabledVariants": 1,
+      "ExportedToMakeVariants": 3,
+      "HiddenFromMakeVariants": 1
+    }
+  }
+}`
+
+	prepareForModuleTypeStatsTest := GroupFixturePreparers(
+		PrepareForTestWithArchMutator,
+		FixtureRegisterWithContext(func(ctx RegistrationContext) {
+			ctx.RegisterModuleType("custom", customModuleFactory)
+			ctx.RegisterModuleType("custom_arch", customArchModuleFactory)
+		}),
+		FixtureWithRootAndroidBp(bp),
+	)
+
+	t.Run("kati", func(t *testing.T) {
+		result := GroupFixturePreparers(
+			prepareForModuleTypeStatsTest,
+			PrepareForTestWithAndroidMk,
+		).RunTest(t)
+
+		stats := result.SingletonForTests("androidmk").Output("module_type_stats.json")
+		AssertStringEquals(t, "module_type_stats.json", expected,
+			ContentFromFileRuleForTests(t, result.TestContext, stats))
 	})
+
+	t.Run("soong only", func(t *testing.T) {
+		result := GroupFixturePreparers(
+			prepareForModuleTypeStatsTest,
+			FixtureRegisterWithContext(RegisterAndroidMkBuildComponents),
+		).RunTest(t)
+
+		stats := result.SingletonForTests("androidmk").Output("module_type_stats.json")
+		AssertStringEquals(t, "module_type_stats.json", expected,
+			ContentFromFileRuleForTests(t, result.TestContext, stats))
+	})
+}
+
+type footerTestInfo struct {
+	phony string
+}
+
+var footerTestInfoProvider = blueprint.NewProvider[footerTestInfo]()
+
+type footerTestModule struct {
+	ModuleBase
+}
+
+func (m *footerTestModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+	SetProvider(ctx, footerTestInfoProvider, footerTestInfo{phony: ctx.ModuleName() + "_phony"})
+}
+
+func (m *footerTestModule) AndroidMkEntries() []AndroidMkEntries {
+	return []AndroidMkEntries{{
+		Class:      "FAKE",
+		OutputFile: OptionalPathForPath(PathForTesting("out")),
+		ExtraFooters: []AndroidMkExtraFootersFunc{
+			func(w io.Writer, name, prefix, moduleDir string) {
+				fmt.Fprintln(w, "# footer of", name)
+			},
+		},
+		ExtraFootersWithContext: []AndroidMkExtraFootersWithContextFunc{
+			func(ctx AndroidMkExtraEntriesContext, w io.Writer, name, prefix, moduleDir string) {
+				info, ok := ctx.Provider(footerTestInfoProvider)
+				if !ok {
+					panic(fmt.Errorf("missing footerTestInfoProvider for %s", name))
+				}
+				fmt.Fprintln(w, ".PHONY:", info.(footerTestInfo).phony)
+			},
+		},
+	}}
+}
+
+func TestAndroidMkExtraFootersWithContext(t *testing.T) {
+	result := GroupFixturePreparers(
+		PrepareForTestWithAndroidMk,
+		FixtureRegisterWithContext(func(ctx RegistrationContext) {
+			ctx.RegisterModuleType("footer_test", func() Module {
+				module := &footerTestModule{}
+				InitAndroidModule(module)
+				return module
+			})
+		}),
+	).RunTestWithBp(t, `
+		footer_test {
+			name: "foo",
+		}
+	`)
+
+	module := result.ModuleForTests("foo", "").Module()
+	entries := AndroidMkEntriesForTest(t, result.TestContext, module)[0]
+	// The footers that take a context are written after the ones that don't.
+	AssertArrayString(t, "footer", []string{
+		"include ",
+		"# footer of foo",
+		".PHONY: foo_phony",
+		"",
+	}, entries.FooterLinesForTests())
+}
+
+func TestDistsOfModulesHiddenFromMake(t *testing.T) {
+	if runtime.GOOS == "darwin" {
+		// Device modules are not exported on Mac, so this test doesn't work.
+		t.SkipNow()
+	}
+
+	result := GroupFixturePreparers(
+		PrepareForTestWithAndroidMk,
+		FixtureRegisterWithContext(func(ctx RegistrationContext) {
+			ctx.RegisterModuleType("custom", customModuleFactory)
+		}),
+		prepareForTestWithPreferredCustomPrebuilts,
+	).RunTestWithBp(t, preferredCustomPrebuiltsBp)
+
+	content, err := os.ReadFile(PathForOutput(PathContextForTesting(result.Config), "Android-root.mk").String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	shard := string(content)
+	AssertStringDoesNotContain(t, "root shard", shard, "LOCAL_MODULE := bar\n")
+	AssertStringDoesNotContain(t, "root shard", shard, "one.out:foo.out")
+	AssertStringDoesContain(t, "root shard", shard, "$(call dist-for-goals,droidcore,one.out:bar.out)\n")
+
+	foo := result.ModuleForTests("foo", "").Module()
+	AssertBoolEquals(t, "foo dist contributions", true,
+		(&AndroidMkEntries{}).getDistContributions(foo) == nil)
 }
@@ -435,6 +435,8 @@ func osMutator(bpctx blueprint.BottomUpMutatorContext) {
 		return
 	}
 
+	validateCompileMultilib(mctx, base)
+
 	// Collect a list of OSTypes supported by this module based on the HostOrDevice value
 	// passed to InitAndroidArchModule and the device_supported and host_supported properties.
 	var moduleOSList []OsType
@@ -667,6 +669,7 @@ func archMutator(bpctx blueprint.BottomUpMutatorContext) {
 	modules := mctx.CreateVariations(targetNames...)
 	for i, m := range modules {
 		addTargetProperties(m, targets[i], multiTargets, i == 0)
+		m.base().commonProperties.CompileMultipleArchVariants = len(modules) > 1
 		m.base().setArchProperties(mctx)
 
 		// Install support doesn't understand Darwin+Arm64
@@ -695,6 +698,46 @@ func addTargetProperties(m Module, target Target, multiTargets []Target, primary
 	m.base().commonProperties.CompilePrimary = primaryTarget
 }
 
+// compileMultilibValues are the values accepted by the compile_multilib properties.
+var compileMultilibValues = []string{"both", "first", "32", "64", "prefer32", "first_prefer32", "common", "common_first"}
+
+// validateCompileMultilib reports an error for each compile_multilib property with a value that
+// is not in compileMultilibValues, with a fix if the value is a recognizable misspelling of a valid
+// value, e.g. "lib64" or "Both", and for the target.host.compile_multilib and
+// target.android.compile_multilib properties of module types that are never built for the host or
+// the device respectively, as they would silently have no effect.
+func validateCompileMultilib(ctx BaseModuleContext, base *ModuleBase) {
+	hod := base.commonProperties.HostOrDeviceSupported
+	properties := []struct {
+		name      string
+		value     *string
+		supported bool
+		class     string
+	}{
+		{"compile_multilib", base.commonProperties.Compile_multilib, true, ""},
+		{"target.host.compile_multilib", base.commonProperties.Target.Host.Compile_multilib, hod&hostSupported != 0, "host"},
+		{"target.android.compile_multilib", base.commonProperties.Target.Android.Compile_multilib, hod&deviceSupported != 0, "device"},
+	}
+
+	for _, property := range properties {
+		if property.value == nil {
+			continue
+		}
+		if !InList(*property.value, compileMultilibValues) {
+			suggestion := strings.TrimPrefix(strings.ToLower(*property.value), "lib")
+			if InList(suggestion, compileMultilibValues) {
+				ctx.PropertyErrorfWithFix(ReplaceValueFix(property.name, suggestion),
+					"must be one of %q, found %q", compileMultilibValues, *property.value)
+			} else {
+				ctx.PropertyErrorf(property.name, "must be one of %q, found %q", compileMultilibValues, *property.value)
+			}
+		} else if !property.supported {
+			ctx.PropertyErrorf(property.name, "has no effect as %s modules are never built for the %s",
+				ctx.ModuleType(), property.class)
+		}
+	}
+}
+
 // decodeMultilib returns the appropriate compile_multilib property for the module, or the default
 // multilib from the factory's call to InitAndroidArchModule if none was set.  For modules that
 // called InitAndroidMultiTargetsArchModule it always returns "common" for multilib, and returns
@@ -1059,10 +1102,13 @@ func mergePropertyStruct(ctx ArchVariantContext, dst interface{}, srcValue refle
 
 	// order checks the `android:"variant_prepend"` tag to handle properties where the
 	// arch-specific value needs to come before the generic value, for example for lists of
-	// include directories.
+	// include directories, and the `android:"variant_replace"` tag to handle lists where the
+	// arch-specific value overrides the generic value, for example the owning teams.
 	order := func(dstField, srcField reflect.StructField) (proptools.Order, error) {
 		if proptools.HasTag(dstField, "android", "variant_prepend") {
 			return proptools.Prepend, nil
+		} else if proptools.HasTag(dstField, "android", "variant_replace") {
+			return proptools.Replace, nil
 		} else {
 			return proptools.Append, nil
 		}
@@ -1239,6 +1285,114 @@ func (m *ModuleBase) setOSProperties(ctx BottomUpMutatorContext) {
 	}
 }
 
+// osGroupPropertyBlocks are the target property blocks that apply to a group of OSes rather than a
+// single one, with a function that returns whether the block applies to an OS.
+var osGroupPropertyBlocks = []struct {
+	field   string
+	matches func(os OsType) bool
+}{
+	{"Host", func(os OsType) bool { return os.Class == Host }},
+	{"Android64", func(os OsType) bool { return os.Class == Device }},
+	{"Android32", func(os OsType) bool { return os.Class == Device }},
+	{"Bionic", OsType.Bionic},
+	{"Glibc", func(os OsType) bool { return os == Linux }},
+	{"Musl", func(os OsType) bool { return os == LinuxMusl }},
+	{"Linux", OsType.Linux},
+	{"Host_linux", func(os OsType) bool { return os.Linux() && os.Class == Host }},
+	{"Not_windows", func(os OsType) bool { return os.Class == Host && os != Windows }},
+}
+
+var osPropertyBlocksKey = NewOnceKey("osPropertyBlocks")
+
+type osPropertyBlock struct {
+	// The name of the block in Android.bp files, e.g. target.windows_x86_64.
+	name string
+
+	// The OSes that the block applies to.
+	oses []OsType
+}
+
+// osPropertyBlocks returns the target property blocks that apply to specific OSes, keyed by the
+// name of their field in the "target" property struct.
+func osPropertyBlocks() map[string]osPropertyBlock {
+	return archPropTypeMap.Once(osPropertyBlocksKey, func() interface{} {
+		blocks := make(map[string]osPropertyBlock)
+		add := func(field string, os OsType) {
+			block := blocks[field]
+			if block.name == "" {
+				block.name = "target." + strings.ToLower(field)
+			}
+			block.oses = append(block.oses, os)
+			blocks[field] = block
+		}
+		for _, os := range osTypeList {
+			if os.Class == Generic {
+				continue
+			}
+			add(os.Field, os)
+			for _, archType := range osArchTypeMap[os] {
+				add(GetCompoundTargetField(os, archType), os)
+			}
+			for _, group := range osGroupPropertyBlocks {
+				if group.matches(os) {
+					add(group.field, os)
+					for _, archType := range osArchTypeMap[os] {
+						add(group.field+"_"+archType.Name, os)
+					}
+				}
+			}
+		}
+		return blocks
+	}).(map[string]osPropertyBlock)
+}
+
+// osBuildable returns false if a module with the HostOrDeviceSupported value can never be built
+// for the OS.  Windows is never the build OS so it is only built as a host cross target.
+func osBuildable(os OsType, hod HostOrDeviceSupported) bool {
+	switch {
+	case os.Class == Device:
+		return hod&deviceSupported != 0
+	case os == Windows:
+		return hod&hostCrossSupported != 0
+	case os.Class == Host:
+		return hod&(hostSupported|hostCrossSupported) != 0
+	default:
+		return true
+	}
+}
+
+// unbuildableOsPropertyBlocks returns the names of the target property blocks that are set in the
+// module, e.g. target.windows, that only apply to OSes that the module's HostOrDeviceSupported value
+// can never build, and so are silently ignored.
+func (m *ModuleBase) unbuildableOsPropertyBlocks() []string {
+	hod := m.commonProperties.HostOrDeviceSupported
+	blocks := osPropertyBlocks()
+	var unbuildable []string
+	for i := range m.archProperties {
+		for _, archProperties := range m.archProperties[i] {
+			targetProp := reflect.ValueOf(archProperties).Elem().FieldByName("Target").Elem()
+			if targetProp.Kind() != reflect.Ptr || targetProp.IsNil() {
+				continue
+			}
+			targetProp = targetProp.Elem()
+			for j := 0; j < targetProp.NumField(); j++ {
+				block, ok := blocks[targetProp.Type().Field(j).Name]
+				if !ok || targetProp.Field(j).IsZero() {
+					continue
+				}
+				buildable := false
+				for _, os := range block.oses {
+					buildable = buildable || osBuildable(os, hod)
+				}
+				if !buildable {
+					unbuildable = append(unbuildable, block.name)
+				}
+			}
+		}
+	}
+	return SortedUniqueStrings(unbuildable)
+}
+
 // Returns the struct containing the properties specific to the given
 // architecture type. These look like this in Blueprint files:
 //
@@ -16,6 +16,7 @@ package android
 
 import (
 	"reflect"
+	"regexp"
 	"runtime"
 	"testing"
 
@@ -759,3 +760,270 @@ func TestArchProperties(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateCompileMultilib(t *testing.T) {
+	testCases := []struct {
+		name string
+		bp   string
+		err  string
+	}{
+		{
+			name: "valid",
+			bp: `
+				module {
+					name: "foo",
+					compile_multilib: "first",
+					target: {
+						host: {
+							compile_multilib: "64",
+						},
+						android: {
+							compile_multilib: "prefer32",
+						},
+					},
+				}
+			`,
+		},
+		{
+			name: "unknown value",
+			bp: `
+				module {
+					name: "foo",
+					compile_multilib: "both64",
+				}
+			`,
+			err: `compile_multilib: must be one of ["both" "first" "32" "64" "prefer32" "first_prefer32" "common" "common_first"], found "both64"`,
+		},
+		{
+			name: "unknown target value",
+			bp: `
+				module {
+					name: "foo",
+					target: {
+						android: {
+							compile_multilib: "lib32",
+						},
+					},
+				}
+			`,
+			err: `target.android.compile_multilib: must be one of ["both" "first" "32" "64" "prefer32" "first_prefer32" "common" "common_first"], found "lib32"`,
+		},
+		{
+			name: "host override on device only module",
+			bp: `
+				device_module {
+					name: "foo",
+					target: {
+						host: {
+							compile_multilib: "64",
+						},
+					},
+				}
+			`,
+			err: `target.host.compile_multilib: has no effect as device_module modules are never built for the host`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			errorHandler := FixtureExpectsNoErrors
+			if tc.err != "" {
+				errorHandler = FixtureExpectsAtLeastOneErrorMatchingPattern(regexp.QuoteMeta(tc.err))
+			}
+			GroupFixturePreparers(
+				prepareForArchTest,
+				FixtureRegisterWithContext(func(ctx RegistrationContext) {
+					ctx.RegisterModuleType("device_module", func() Module {
+						m := &archTestModule{}
+						m.AddProperties(&m.props)
+						InitAndroidArchModule(m, DeviceSupported, MultilibBoth)
+						return m
+					})
+				}),
+			).
+				ExtendWithErrorHandler(errorHandler).
+				RunTestWithBp(t, tc.bp)
+		})
+	}
+}
+
+type archTestFarDepsModule struct {
+	ModuleBase
+	props struct {
+		Deps []string
+	}
+}
+
+func (m *archTestFarDepsModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+}
+
+func (m *archTestFarDepsModule) DepsMutator(ctx BottomUpMutatorContext) {
+	ctx.AddFarVariationDependencies(ctx.Target().Variations(), nil, m.props.Deps...)
+}
+
+func archTestFarDepsModuleFactory() Module {
+	m := &archTestFarDepsModule{}
+	m.AddProperties(&m.props)
+	InitAndroidArchModule(m, HostAndDeviceSupported, MultilibBoth)
+	return m
+}
+
+func TestMissingArchVariantDependency(t *testing.T) {
+	GroupFixturePreparers(
+		prepareForArchTest,
+		FixtureRegisterWithContext(func(ctx RegistrationContext) {
+			ctx.RegisterModuleType("far_deps_module", archTestFarDepsModuleFactory)
+		}),
+	).ExtendWithErrorHandler(FixtureExpectsOneErrorPattern(
+		`module "foo" .*: dependency "lib64" has no android_arm_armv7-a-neon variant, `+
+			`it only has the variants \["android_arm64_armv8-a"\]; the variants are controlled by the compile_multilib, `+
+			`host_supported, device_supported and arch or target specific enabled properties of "lib64"`,
+	)).RunTestWithBp(t, `
+		module {
+			name: "lib64",
+			compile_multilib: "64",
+		}
+
+		far_deps_module {
+			name: "foo",
+			compile_multilib: "32",
+			deps: ["lib64"],
+		}
+
+		far_deps_module {
+			name: "bar",
+			compile_multilib: "64",
+			deps: ["lib64"],
+		}
+	`)
+}
+
+type archTestNoCrossModule struct {
+	ModuleBase
+	DefaultableModuleBase
+}
+
+func (m *archTestNoCrossModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+}
+
+func archTestNoCrossModuleFactory() Module {
+	m := &archTestNoCrossModule{}
+	InitAndroidArchModule(m, HostSupportedNoCross, MultilibFirst)
+	InitDefaultableModule(m)
+	return m
+}
+
+type archTestDefaults struct {
+	ModuleBase
+	DefaultsModuleBase
+}
+
+func archTestDefaultsFactory() Module {
+	m := &archTestDefaults{}
+	InitDefaultsModule(m)
+	return m
+}
+
+func TestOsPropertyBlocksChecker(t *testing.T) {
+	prepareForOsPropertyBlocksTest := GroupFixturePreparers(
+		PrepareForTestWithArchMutator,
+		PrepareForTestWithDefaults,
+		FixtureRegisterWithContext(func(ctx RegistrationContext) {
+			ctx.PreArchMutators(RegisterOsPropertyBlocksChecker)
+			ctx.RegisterModuleType("no_cross_module", archTestNoCrossModuleFactory)
+			ctx.RegisterModuleType("defaults", archTestDefaultsFactory)
+		}),
+	)
+	prepareForStrictOsPropertyBlocks := FixtureMergeEnv(map[string]string{
+		"SOONG_STRICT_OS_PROPERTY_BLOCKS": "true",
+	})
+
+	t.Run("warning by default", func(t *testing.T) {
+		result := prepareForOsPropertyBlocksTest.RunTestWithBp(t, `
+			no_cross_module {
+				name: "foo",
+				target: {
+					windows: {
+						enabled: true,
+					},
+				},
+			}
+		`)
+		AssertDeepEquals(t, "warnings", []BuildWarning{{
+			File:     "Android.bp",
+			Module:   "foo",
+			Property: "target.windows",
+			Message:  `ignored as module type "no_cross_module" can never be built for the OSes it applies to`,
+		}}, result.Config.BuildWarnings())
+	})
+
+	t.Run("error in strict mode", func(t *testing.T) {
+		GroupFixturePreparers(
+			prepareForOsPropertyBlocksTest,
+			prepareForStrictOsPropertyBlocks,
+		).ExtendWithErrorHandler(FixtureExpectsAllErrorsToMatchAPattern([]string{
+			`module "foo": target.windows: module type "no_cross_module" can never be built`,
+			`module "foo": target.windows_x86_64: module type "no_cross_module" can never be built`,
+		})).RunTestWithBp(t, `
+			no_cross_module {
+				name: "foo",
+				target: {
+					windows: {
+						enabled: true,
+					},
+					windows_x86_64: {
+						enabled: true,
+					},
+					linux_glibc: {
+						enabled: true,
+					},
+					not_windows: {
+						enabled: true,
+					},
+				},
+			}
+		`)
+	})
+
+	t.Run("device blocks in host module", func(t *testing.T) {
+		GroupFixturePreparers(
+			prepareForOsPropertyBlocksTest,
+			prepareForStrictOsPropertyBlocks,
+		).ExtendWithErrorHandler(FixtureExpectsAllErrorsToMatchAPattern([]string{
+			`module "foo": target.android: module type "no_cross_module" can never be built`,
+		})).RunTestWithBp(t, `
+			no_cross_module {
+				name: "foo",
+				target: {
+					android: {
+						enabled: true,
+					},
+					bionic: {
+						enabled: true,
+					},
+				},
+			}
+		`)
+	})
+
+	t.Run("blocks from defaults are not reported", func(t *testing.T) {
+		GroupFixturePreparers(
+			prepareForOsPropertyBlocksTest,
+			prepareForStrictOsPropertyBlocks,
+		).RunTestWithBp(t, `
+			defaults {
+				name: "foo_defaults",
+				target: {
+					windows: {
+						enabled: true,
+					},
+				},
+			}
+
+			no_cross_module {
+				name: "foo",
+				defaults: ["foo_defaults"],
+			}
+		`)
+	})
+}
@@ -262,6 +262,9 @@ func (b *baseModuleContext) provider(provider blueprint.AnyProviderKey) (any, bo
 
 func (b *baseModuleContext) setProvider(provider blueprint.AnyProviderKey, value any) {
 	b.bp.SetProvider(provider, value)
+	if dumpProvidersForModule(b.Config(), b.ModuleName()) {
+		b.Module().base().recordProviderForDump(value)
+	}
 }
 
 func (b *baseModuleContext) GetDirectDepWithTag(name string, tag blueprint.DependencyTag) blueprint.Module {
@@ -299,6 +302,21 @@ type AllowDisabledModuleDependency interface {
 	AllowDisabledModuleDependency(target Module) bool
 }
 
+// InterfaceDependencyTag is implemented by the dependency tags through which the dependent only
+// uses the interface of the dependency, e.g. its exported headers or its stub jar.  Dependencies
+// with these tags are allowed onto disabled modules that set provide_interface_when_disabled.
+type InterfaceDependencyTag interface {
+	blueprint.DependencyTag
+	InterfaceDependency() bool
+}
+
+// isInterfaceDependency returns true if the dependent only uses the interface of the dependency
+// with the tag.
+func isInterfaceDependency(tag blueprint.DependencyTag) bool {
+	t, ok := tag.(InterfaceDependencyTag)
+	return ok && t.InterfaceDependency()
+}
+
 func (b *baseModuleContext) validateAndroidModule(module blueprint.Module, tag blueprint.DependencyTag, strict bool, ignoreBlueprint bool) Module {
 	aModule, _ := module.(Module)
 
@@ -314,11 +332,23 @@ func (b *baseModuleContext) validateAndroidModule(module blueprint.Module, tag b
 	}
 
 	if !aModule.Enabled() {
+		providesInterface := aModule.base().ProvidesInterfaceWhenDisabled()
+		if providesInterface && isInterfaceDependency(tag) {
+			return aModule
+		}
 		if t, ok := tag.(AllowDisabledModuleDependency); !ok || !t.AllowDisabledModuleDependency(aModule) {
 			if b.Config().AllowMissingDependencies() {
 				b.AddMissingDependencies([]string{b.OtherModuleName(aModule)})
+			} else if providesInterface {
+				b.ModuleErrorf("depends on disabled module %q through a dependency that uses more than "+
+					"its interface, which is all that it provides when disabled", b.OtherModuleName(aModule))
 			} else {
-				b.ModuleErrorf("depends on disabled module %q", b.OtherModuleName(aModule))
+				if aModule.base().ArchSpecific() {
+					b.ModuleErrorf("depends on disabled module %q for %s, which may be disabled by an arch or "+
+						"target specific enabled property", b.OtherModuleName(aModule), aModule.Target())
+				} else {
+					b.ModuleErrorf("depends on disabled module %q", b.OtherModuleName(aModule))
+				}
 			}
 		}
 		return nil
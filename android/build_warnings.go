@@ -0,0 +1,94 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+)
+
+// Warnings are problems in the module definitions that don't fail the build.  Modules report them
+// with ModuleWarningf or PropertyWarningf, and singletons with ModuleWarningf.  soong_build prints
+// each distinct warning once after the analysis, so a warning that is reported for every variant of
+// a module is only shown once.
+
+// BuildWarning is a warning reported on a module.
+type BuildWarning struct {
+	// The blueprint file that defines the module.
+	File string
+	// The name of the module.
+	Module string
+	// The property the warning is about, if any.
+	Property string
+	// The text of the warning.
+	Message string
+}
+
+func (w BuildWarning) String() string {
+	if w.Property != "" {
+		return fmt.Sprintf("%s: module %q: %s: %s", w.File, w.Module, w.Property, w.Message)
+	}
+	return fmt.Sprintf("%s: module %q: %s", w.File, w.Module, w.Message)
+}
+
+var buildWarningsKey = NewOnceKey("buildWarnings")
+
+type buildWarnings struct {
+	sync.Mutex
+	list []BuildWarning
+}
+
+func getBuildWarnings(config Config) *buildWarnings {
+	return config.Once(buildWarningsKey, func() interface{} {
+		return &buildWarnings{}
+	}).(*buildWarnings)
+}
+
+// addBuildWarning records a warning about the module defined in file.
+func addBuildWarning(config Config, file, module, property, format string, args ...interface{}) {
+	s := getBuildWarnings(config)
+	s.Lock()
+	defer s.Unlock()
+	s.list = append(s.list, BuildWarning{
+		File:     file,
+		Module:   module,
+		Property: property,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// BuildWarnings returns the warnings reported so far, sorted by file, module, property and message.
+// A warning that was reported for multiple variants of a module is only returned once.
+func (c Config) BuildWarnings() []BuildWarning {
+	s := getBuildWarnings(c)
+	s.Lock()
+	defer s.Unlock()
+	ret := slices.Clone(s.list)
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].File != ret[j].File {
+			return ret[i].File < ret[j].File
+		}
+		if ret[i].Module != ret[j].Module {
+			return ret[i].Module < ret[j].Module
+		}
+		if ret[i].Property != ret[j].Property {
+			return ret[i].Property < ret[j].Property
+		}
+		return ret[i].Message < ret[j].Message
+	})
+	return slices.Compact(ret)
+}
@@ -0,0 +1,69 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"testing"
+)
+
+type warningModule struct {
+	ModuleBase
+
+	properties struct {
+		Deprecated_prop *string
+	}
+}
+
+func warningModuleFactory() Module {
+	module := &warningModule{}
+	module.AddProperties(&module.properties)
+	InitAndroidArchModule(module, HostAndDeviceSupported, MultilibBoth)
+	return module
+}
+
+func (m *warningModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+	if m.properties.Deprecated_prop != nil {
+		ctx.PropertyWarningf("deprecated_prop", "is deprecated")
+	}
+	ctx.ModuleWarningf("reported by %s", "every variant")
+}
+
+func TestBuildWarnings(t *testing.T) {
+	result := GroupFixturePreparers(
+		PrepareForTestWithArchMutator,
+		FixtureRegisterWithContext(func(ctx RegistrationContext) {
+			ctx.RegisterModuleType("warning_module", warningModuleFactory)
+		}),
+	).RunTestWithBp(t, `
+		warning_module {
+			name: "foo",
+			deprecated_prop: "x",
+		}
+
+		warning_module {
+			name: "bar",
+		}
+	`)
+
+	// The warnings of the variants of each module are only reported once.
+	AssertDeepEquals(t, "build warnings", []BuildWarning{
+		{File: "Android.bp", Module: "bar", Message: "reported by every variant"},
+		{File: "Android.bp", Module: "foo", Message: "reported by every variant"},
+		{File: "Android.bp", Module: "foo", Property: "deprecated_prop", Message: "is deprecated"},
+	}, result.Config.BuildWarnings())
+
+	AssertStringEquals(t, "warning string",
+		`Android.bp: module "foo": deprecated_prop: is deprecated`, result.Config.BuildWarnings()[2].String())
+}
@@ -75,6 +75,10 @@ const productVariablesFileName = "soong.variables"
 // A Config object represents the entire build configuration for Android.
 type Config struct {
 	*config
+
+	// The module type or singleton that environment variables read through this Config are
+	// attributed to in soong.environment.usage.json, if any.
+	envConsumer string
 }
 
 type SoongBuildMode int
@@ -90,10 +94,15 @@ type CmdArgs struct {
 	ModuleGraphFile   string
 	ModuleActionsFile string
 	DocFile           string
+	InstallListFile   string
 
 	BuildFromSourceStub bool
 
 	EnsureAllowlistIntegrity bool
+
+	// SerialTail runs the tail phases of soong_build that run in parallel on a single worker, for
+	// debugging.
+	SerialTail bool
 }
 
 // Build modes that soong_build can run as.
@@ -111,6 +120,10 @@ const (
 
 	// Generate a documentation file for module type definitions and exit.
 	GenerateDocFile
+
+	// Generate the build actions without writing the ninja file, then write the list of the files
+	// installed by each module and exit.
+	GenerateInstallList
 )
 
 // SoongOutDir returns the build output directory for the configuration.
@@ -240,6 +253,9 @@ type config struct {
 	// Options configurable with soong.variables
 	productVariables ProductVariables
 
+	// The digests of the top level fields of soong.variables, see ProductVariableDigests.
+	productVariableDigests map[string]string
+
 	// Only available on configs created by TestConfig
 	TestProductVariables *ProductVariables
 
@@ -274,10 +290,17 @@ type config struct {
 	envDeps   map[string]string
 	envFrozen bool
 
+	// The module types and singletons that read each environment variable.
+	envConsumers map[string]map[string]bool
+
 	// Changes behavior based on whether Kati runs after soong_build, or if soong_build
 	// runs standalone.
 	katiEnabled bool
 
+	// If true, the Android.mk entries of each module are computed twice and compared to detect
+	// non-deterministic entries.  Set for tests by PrepareForTestWithAndroidMk.
+	verifyAndroidMkEntries bool
+
 	captureBuild      bool // true for tests, saves build parameters for each module
 	ignoreEnvironment bool // true for tests, returns empty from all Getenv calls
 
@@ -300,6 +323,13 @@ type config struct {
 	// built from the source Java files, not the signature text files.
 	buildFromSourceStub bool
 
+	// If serialTail is true then the tail phases of soong_build run on a single worker.
+	serialTail bool
+
+	// If disableRequiredImageVariantCache is true then every check of the image variants of a
+	// required module asks blueprint, used by tests to compare against the cached results.
+	disableRequiredImageVariantCache bool
+
 	// If ensureAllowlistIntegrity is true, then the presence of any allowlisted
 	// modules that aren't mixed-built for at least one variant will cause a build
 	// failure
@@ -319,7 +349,26 @@ type jsonConfigurable interface {
 }
 
 func loadConfig(config *config) error {
-	return loadFromConfigFile(&config.productVariables, absolutePath(config.ProductVariablesFileName))
+	filename := absolutePath(config.ProductVariablesFileName)
+	if err := loadFromConfigFile(&config.productVariables, filename); err != nil {
+		return err
+	}
+	// loadFromConfigFile writes the file if it didn't exist.
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return fmt.Errorf("config file: could not read %s: %s", filename, err.Error())
+	}
+	config.productVariableDigests, err = productVariableDigests(data)
+	if err != nil {
+		return fmt.Errorf("config file: %s did not parse correctly: %s", filename, err.Error())
+	}
+	return nil
+}
+
+// ProductVariableDigests returns the digests of the top level fields of soong.variables, keyed by
+// field name, to find the product variables that changed between builds.
+func (c *config) ProductVariableDigests() map[string]string {
+	return c.productVariableDigests
 }
 
 // Checks if the string is a valid go identifier. This is equivalent to blueprint's definition
@@ -536,6 +585,7 @@ func NewConfig(cmdArgs CmdArgs, availableEnv map[string]string) (Config, error)
 		fs:             pathtools.NewOsFs(absSrcDir),
 
 		buildFromSourceStub: cmdArgs.BuildFromSourceStub,
+		serialTail:          cmdArgs.SerialTail,
 	}
 
 	config.deviceConfig = &deviceConfig{
@@ -571,6 +621,20 @@ func NewConfig(cmdArgs CmdArgs, availableEnv map[string]string) (Config, error)
 
 	determineBuildOS(config)
 
+	if err := validateInstallPathOverrides(config.productVariables.InstallPathOverrides); err != nil {
+		return Config{}, err
+	}
+
+	if err := validateExcludeFromDistGoals(config.productVariables.Exclude_from_dist_goals); err != nil {
+		return Config{}, err
+	}
+
+	if version := String(config.productVariables.BoardKernelVersion); version != "" {
+		if _, err := parseKernelVersion(version); err != nil {
+			return Config{}, fmt.Errorf("BoardKernelVersion: %s", err)
+		}
+	}
+
 	// Sets up the map of target OSes to the finer grained compilation targets
 	// that are configured from the product variables.
 	targets, err := decodeTargetProductVariables(config)
@@ -629,6 +693,7 @@ func NewConfig(cmdArgs CmdArgs, availableEnv map[string]string) (Config, error)
 	setBuildMode(cmdArgs.BazelQueryViewDir, GenerateQueryView)
 	setBuildMode(cmdArgs.ModuleGraphFile, GenerateModuleGraph)
 	setBuildMode(cmdArgs.DocFile, GenerateDocFile)
+	setBuildMode(cmdArgs.InstallListFile, GenerateInstallList)
 
 	// TODO(b/276958307): Replace the hardcoded list to a sdk_library local prop.
 	config.apiLibraries = map[string]struct{}{
@@ -664,9 +729,9 @@ func NewConfig(cmdArgs CmdArgs, availableEnv map[string]string) (Config, error)
 		"i18n.module.public.api":            {},
 	}
 
-	config.productVariables.Build_from_text_stub = boolPtr(config.BuildFromTextStub())
+	config.productVariables.Build_from_text_stub = boolPtr(Config{config: config}.BuildFromTextStub())
 
-	return Config{config}, err
+	return Config{config: config}, err
 }
 
 // mockFileSystem replaces all reads with accesses to the provided map of
@@ -777,6 +842,12 @@ func (c *config) CpPreserveSymlinksFlags() string {
 }
 
 func (c *config) Getenv(key string) string {
+	return c.getenv(key, "")
+}
+
+// getenv returns the value of the environment variable and records it as a dependency of the build,
+// attributing the read to the consumer if it is not empty.
+func (c *config) getenv(key, consumer string) string {
 	var val string
 	var exists bool
 	c.envLock.Lock()
@@ -791,10 +862,25 @@ func (c *config) Getenv(key string) string {
 		val, _ = c.env[key]
 		c.envDeps[key] = val
 	}
+	if consumer != "" {
+		if c.envConsumers == nil {
+			c.envConsumers = make(map[string]map[string]bool)
+		}
+		if c.envConsumers[key] == nil {
+			c.envConsumers[key] = make(map[string]bool)
+		}
+		c.envConsumers[key][consumer] = true
+	}
 	return val
 }
 
-func (c *config) GetenvWithDefault(key string, defaultValue string) string {
+// Getenv returns the value of the environment variable and records it as a dependency of the build,
+// attributing the read to the module type or singleton whose context returned the Config.
+func (c Config) Getenv(key string) string {
+	return c.config.getenv(key, c.envConsumer)
+}
+
+func (c Config) GetenvWithDefault(key string, defaultValue string) string {
 	ret := c.Getenv(key)
 	if ret == "" {
 		return defaultValue
@@ -802,16 +888,23 @@ func (c *config) GetenvWithDefault(key string, defaultValue string) string {
 	return ret
 }
 
-func (c *config) IsEnvTrue(key string) bool {
+func (c Config) IsEnvTrue(key string) bool {
 	value := c.Getenv(key)
 	return value == "1" || value == "y" || value == "yes" || value == "on" || value == "true"
 }
 
-func (c *config) IsEnvFalse(key string) bool {
+func (c Config) IsEnvFalse(key string) bool {
 	value := c.Getenv(key)
 	return value == "0" || value == "n" || value == "no" || value == "off" || value == "false"
 }
 
+// withEnvConsumer returns a copy of the Config that attributes the environment variables read
+// through it to the consumer.
+func (c Config) withEnvConsumer(consumer string) Config {
+	c.envConsumer = consumer
+	return c
+}
+
 // EnvDeps returns the environment variables this build depends on. The first
 // call to this function blocks future reads from the environment.
 func (c *config) EnvDeps() map[string]string {
@@ -821,10 +914,34 @@ func (c *config) EnvDeps() map[string]string {
 	return c.envDeps
 }
 
+// EnvUsage returns the sorted module types and singletons that read each environment variable this
+// build depends on, for soong.environment.usage.json.  Variables that were only read outside of
+// module and singleton contexts have no consumers.
+func (c *config) EnvUsage() map[string][]string {
+	c.envLock.Lock()
+	defer c.envLock.Unlock()
+	usage := make(map[string][]string, len(c.envDeps))
+	for key := range c.envDeps {
+		usage[key] = SortedKeys(c.envConsumers[key])
+		if usage[key] == nil {
+			usage[key] = []string{}
+		}
+	}
+	return usage
+}
+
 func (c *config) KatiEnabled() bool {
 	return c.katiEnabled
 }
 
+// VerifyAndroidMkEntries returns true if the Android.mk entries of each module should be computed
+// twice and compared to detect non-deterministic entries, which is always the case in tests that
+// use PrepareForTestWithAndroidMk and can be enabled in builds with
+// SOONG_VERIFY_ANDROIDMK_ENTRIES=true.
+func (c Config) VerifyAndroidMkEntries() bool {
+	return c.verifyAndroidMkEntries || c.IsEnvTrue("SOONG_VERIFY_ANDROIDMK_ENTRIES")
+}
+
 func (c *config) ProductVariables() ProductVariables {
 	return c.productVariables
 }
@@ -865,6 +982,44 @@ func (c *config) HasDeviceProduct() bool {
 	return c.productVariables.DeviceProduct != nil
 }
 
+// DistInProductDir returns true if the dists of the modules are put in a subdirectory of the dist
+// directory named after the product.
+func (c *config) DistInProductDir() bool {
+	return Bool(c.productVariables.Dist_in_product_dir) && c.HasDeviceProduct()
+}
+
+// TailJobs returns the number of workers used by the tail phases of soong_build that run in
+// parallel, which is 1 when soong_build is run with --serial-tail.
+func (c *config) TailJobs() int {
+	if c.serialTail {
+		return 1
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+// ModuleTypeDistsDisabled returns true if the dists declared by module types with
+// InitModuleTypeDists are ignored.
+func (c *config) ModuleTypeDistsDisabled() bool {
+	return Bool(c.productVariables.Disable_module_type_dists)
+}
+
+// ModuleInfoLicenses returns true if module-info.json lists the effective license kinds and
+// conditions of the modules.
+func (c *config) ModuleInfoLicenses() bool {
+	return Bool(c.productVariables.Module_info_licenses)
+}
+
+// MultilibSuffix64bit returns true if the modules that set suffix_32bit install their 64-bit
+// variant with a 64 suffix instead of their 32-bit variant with a _32 suffix.
+func (c *config) MultilibSuffix64bit() bool {
+	return Bool(c.productVariables.Multilib_suffix_64bit)
+}
+
+// PrebuiltApexPreference returns the prebuilt apex module types in order of precedence.
+func (c *config) PrebuiltApexPreference() []string {
+	return c.productVariables.Prebuilt_apex_preference
+}
+
 func (c *config) DeviceResourceOverlays() []string {
 	return c.productVariables.DeviceResourceOverlays
 }
@@ -1174,18 +1329,18 @@ func (c *config) UseRemoteBuild() bool {
 	return c.UseGoma() || c.UseRBE()
 }
 
-func (c *config) RunErrorProne() bool {
+func (c Config) RunErrorProne() bool {
 	return c.IsEnvTrue("RUN_ERROR_PRONE")
 }
 
 // XrefCorpusName returns the Kythe cross-reference corpus name.
-func (c *config) XrefCorpusName() string {
+func (c Config) XrefCorpusName() string {
 	return c.Getenv("XREF_CORPUS")
 }
 
 // XrefCuEncoding returns the compilation unit encoding to use for Kythe code
 // xrefs. Can be 'json' (default), 'proto' or 'all'.
-func (c *config) XrefCuEncoding() string {
+func (c Config) XrefCuEncoding() string {
 	if enc := c.Getenv("KYTHE_KZIP_ENCODING"); enc != "" {
 		return enc
 	}
@@ -1211,7 +1366,7 @@ func (c Config) XrefCuJavaSourceMax() string {
 
 }
 
-func (c *config) EmitXrefRules() bool {
+func (c Config) EmitXrefRules() bool {
 	return c.XrefCorpusName() != ""
 }
 
@@ -1265,6 +1420,10 @@ func (c *config) ExportedNamespaces() []string {
 	return append([]string(nil), c.productVariables.NamespacesToExport...)
 }
 
+func (c *config) EnforceRequiredNamespaceExports() bool {
+	return Bool(c.productVariables.EnforceRequiredNamespaceExports)
+}
+
 func (c *config) SourceRootDirs() []string {
 	return c.productVariables.SourceRootDirs
 }
@@ -1672,6 +1831,24 @@ func (c *config) ApexTrimEnabled() bool {
 	return Bool(c.productVariables.TrimmedApex)
 }
 
+// EnforcePrereleaseApexes returns true if only the apex_set modules returned by PrereleaseApexes
+// may extract prerelease apexes.
+func (c *config) EnforcePrereleaseApexes() bool {
+	return Bool(c.productVariables.EnforcePrereleaseApexes)
+}
+
+// PrereleaseApexesReportOnly returns true if apex_set modules that are not allowed to extract
+// prerelease apexes but set prerelease: true are only warnings.
+func (c *config) PrereleaseApexesReportOnly() bool {
+	return Bool(c.productVariables.PrereleaseApexesReportOnly)
+}
+
+// PrereleaseApexes returns the names of the apex_set modules that may extract prerelease apexes
+// when EnforcePrereleaseApexes is true.
+func (c *config) PrereleaseApexes() []string {
+	return c.productVariables.PrereleaseApexes
+}
+
 func (c *config) EnforceSystemCertificate() bool {
 	return Bool(c.productVariables.EnforceSystemCertificate)
 }
@@ -1748,6 +1925,10 @@ func (c *deviceConfig) BoardKernelModuleInterfaceVersions() []string {
 	return c.config.productVariables.BoardKernelModuleInterfaceVersions
 }
 
+func (c *deviceConfig) BoardKernelVersion() string {
+	return String(c.config.productVariables.BoardKernelVersion)
+}
+
 func (c *deviceConfig) BoardMoveRecoveryResourcesToVendorBoot() bool {
 	return Bool(c.config.productVariables.BoardMoveRecoveryResourcesToVendorBoot)
 }
@@ -1911,6 +2092,71 @@ func (c *config) BuildWarningBadOptionalUsesLibsAllowlist() []string {
 	return c.productVariables.BuildWarningBadOptionalUsesLibsAllowlist
 }
 
+// EnforceVintfFragmentsAllowlist returns true if setting the deprecated vintf_fragments property is
+// an error in the directories that are not in VintfFragmentsAllowlist.
+func (c *deviceConfig) EnforceVintfFragmentsAllowlist() bool {
+	return Bool(c.config.productVariables.EnforceVintfFragmentsAllowlist)
+}
+
+// VintfFragmentsAllowlist returns the directories in which modules may still set the deprecated
+// vintf_fragments property, including their subdirectories.
+func (c *deviceConfig) VintfFragmentsAllowlist() []string {
+	return c.config.productVariables.VintfFragmentsAllowlist
+}
+
+// CheckSymlinkTargets returns true if checkbuild checks that the symlinks installed by modules point
+// to files installed by the modules or their dependencies.
+func (c *deviceConfig) CheckSymlinkTargets() bool {
+	return Bool(c.config.productVariables.CheckSymlinkTargets)
+}
+
+// SymlinkTargetCheckExemptPrefixes returns the prefixes of the absolute symlink targets that are
+// not checked when CheckSymlinkTargets is set, in addition to the directories that only exist at
+// runtime like /apex.
+func (c *deviceConfig) SymlinkTargetCheckExemptPrefixes() []string {
+	return c.config.productVariables.SymlinkTargetCheckExemptPrefixes
+}
+
+// ModuleOwnersAllowlist returns the valid values of the owner property of modules and the
+// default_owner property of packages, or nil if they are not checked.
+func (c *deviceConfig) ModuleOwnersAllowlist() []string {
+	return c.config.productVariables.ModuleOwnersAllowlist
+}
+
+// ModuleOwnersAllowlistReportOnly returns true if owners that are not in ModuleOwnersAllowlist are
+// reported as warnings instead of errors.
+func (c *deviceConfig) ModuleOwnersAllowlistReportOnly() bool {
+	return Bool(c.config.productVariables.ModuleOwnersAllowlistReportOnly)
+}
+
+// TeamDirectoryAllowlist returns the directories that team modules may be defined in, including
+// their subdirectories, or nil if team modules may be defined anywhere.
+func (c *deviceConfig) TeamDirectoryAllowlist() []string {
+	return c.config.productVariables.TeamDirectoryAllowlist
+}
+
+// TeamDirectoryAllowlistReportOnly returns true if team modules that are not in the
+// TeamDirectoryAllowlist, and the references to them, are reported as warnings instead of errors.
+func (c *deviceConfig) TeamDirectoryAllowlistReportOnly() bool {
+	return Bool(c.config.productVariables.TeamDirectoryAllowlistReportOnly)
+}
+
+// RestrictedInstallAllowlist returns the restricted_install modules that may be installed.
+func (c *deviceConfig) RestrictedInstallAllowlist() []string {
+	return c.config.productVariables.RestrictedInstallAllowlist
+}
+
+// ProductPackages returns the modules listed in PRODUCT_PACKAGES.
+func (c *deviceConfig) ProductPackages() []string {
+	return c.config.productVariables.ProductPackages
+}
+
+// ObsoleteFiles returns the paths of the files that the product no longer installs, relative to
+// the product out directory.
+func (c *deviceConfig) ObsoleteFiles() []string {
+	return c.config.productVariables.Obsolete_files
+}
+
 func (c *deviceConfig) GenruleSandboxing() bool {
 	return Bool(c.config.productVariables.GenruleSandboxing)
 }
@@ -1958,7 +2204,7 @@ func (c *config) ApexBootJars() ConfiguredJarList {
 	return c.productVariables.ApexBootJars
 }
 
-func (c *config) RBEWrapper() string {
+func (c Config) RBEWrapper() string {
 	return c.GetenvWithDefault("RBE_WRAPPER", remoteexec.DefaultWrapperPath)
 }
 
@@ -1978,7 +2224,7 @@ func (c *config) ApiSurfacesDir(s ApiSurface, version string) string {
 		version)
 }
 
-func (c *config) JavaCoverageEnabled() bool {
+func (c Config) JavaCoverageEnabled() bool {
 	return c.IsEnvTrue("EMMA_INSTRUMENT") || c.IsEnvTrue("EMMA_INSTRUMENT_STATIC") || c.IsEnvTrue("EMMA_INSTRUMENT_FRAMEWORK")
 }
 
@@ -1986,7 +2232,7 @@ func (c *deviceConfig) BuildFromSourceStub() bool {
 	return Bool(c.config.productVariables.BuildFromSourceStub)
 }
 
-func (c *config) BuildFromTextStub() bool {
+func (c Config) BuildFromTextStub() bool {
 	// TODO: b/302320354 - Remove the coverage build specific logic once the
 	// robust solution for handling native properties in from-text stub build
 	// is implemented.
@@ -180,3 +180,55 @@ func TestConfiguredJarList(t *testing.T) {
 		assertStringEquals(t, "apex1:jarA", list5.String())
 	})
 }
+
+type envReadingModule struct {
+	ModuleBase
+}
+
+func envReadingModuleFactory() Module {
+	module := &envReadingModule{}
+	InitAndroidModule(module)
+	return module
+}
+
+func (m *envReadingModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+	ctx.Config().Getenv("BUILD_NUMBER")
+	ctx.Config().IsEnvTrue("MODULE_ONLY")
+	ctx.Config().RunErrorProne()
+}
+
+type envReadingSingleton struct{}
+
+func (s *envReadingSingleton) GenerateBuildActions(ctx SingletonContext) {
+	ctx.Config().GetenvWithDefault("BUILD_NUMBER", "eng")
+	ctx.Config().JavaCoverageEnabled()
+}
+
+func TestEnvUsage(t *testing.T) {
+	result := GroupFixturePreparers(
+		FixtureRegisterWithContext(func(ctx RegistrationContext) {
+			ctx.RegisterModuleType("env_reader", envReadingModuleFactory)
+			ctx.RegisterSingletonType("env_reading_singleton", func() Singleton {
+				return &envReadingSingleton{}
+			})
+		}),
+		FixtureMergeEnv(map[string]string{
+			"BUILD_NUMBER": "1234",
+		}),
+	).RunTestWithBp(t, `
+		env_reader {
+			name: "foo",
+		}
+	`)
+
+	result.Config.Getenv("UNATTRIBUTED")
+
+	usage := result.Config.EnvUsage()
+	AssertDeepEquals(t, "BUILD_NUMBER", []string{"env_reader", "env_reading_singleton"}, usage["BUILD_NUMBER"])
+	AssertDeepEquals(t, "MODULE_ONLY", []string{"env_reader"}, usage["MODULE_ONLY"])
+	// The env reads of the Config helpers are attributed too.
+	AssertDeepEquals(t, "RUN_ERROR_PRONE", []string{"env_reader"}, usage["RUN_ERROR_PRONE"])
+	AssertDeepEquals(t, "EMMA_INSTRUMENT", []string{"env_reading_singleton"}, usage["EMMA_INSTRUMENT"])
+	AssertDeepEquals(t, "UNATTRIBUTED", []string{}, usage["UNATTRIBUTED"])
+	AssertStringEquals(t, "used BUILD_NUMBER", "1234", result.Config.EnvDeps()["BUILD_NUMBER"])
+}
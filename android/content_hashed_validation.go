@@ -0,0 +1,97 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/blueprint"
+)
+
+// A content hashed validation only runs its command when the contents of its inputs change, not
+// whenever their timestamps do.  The rule runs a wrapper script generated next to the stamp file
+// that hashes the names and contents of the inputs, the tools and the script itself, which
+// contains the command.  If the hash matches the one in the stamp file the command is skipped and
+// the stamp file is left untouched, and as the rule is a restat rule Ninja does not rerun the
+// modules that use the stamp file as a validation either.  Adding or removing an input changes the
+// names that are hashed, so the command is run again.
+
+// contentHashedValidationScript is the wrapper script of a content hashed validation.  It is called
+// as "<script> check|update <stamp> <files...>": check succeeds if the stamp file holds the hash
+// of the files, and update writes the hash of the files to the stamp file.
+const contentHashedValidationScript = `#!/bin/bash -e
+# Generated by Soong for the content hashed validation %s of %s.
+# command: %s
+mode="$1"
+stamp="$2"
+shift 2
+hash="$( (printf '%%s\n' "$0" "$@"; sha256sum -- "$0" "$@" | cut -d' ' -f1) | sha256sum | cut -d' ' -f1)"
+case "$mode" in
+  check) [ -f "${stamp}" ] && [ "$(cat "${stamp}")" = "${hash}" ] ;;
+  update) echo "${hash}" > "${stamp}" ;;
+  *) echo "unknown mode ${mode}" >&2; exit 1 ;;
+esac
+`
+
+// ContentHashedValidationParams are the parameters of BuildContentHashedValidation.
+type ContentHashedValidationParams struct {
+	// The name of the validation, which must be unique within the module.  The stamp file is
+	// <name>.stamp and the wrapper script is <name>.sh in the module's output directory.
+	Name string
+
+	// The description of the rule.
+	Description string
+
+	// The files checked by the validation.  They are passed to Command in $in.
+	Inputs Paths
+
+	// The tools run by Command.  Their contents are hashed along with the inputs.
+	Tools Paths
+
+	// The shell command that runs the validation, which must fail if the validation does.  It is
+	// expanded by Ninja in the scope of the package context passed to
+	// BuildContentHashedValidation, and must not write to $out.
+	Command string
+}
+
+// BuildContentHashedValidation creates a rule that runs the command of the validation when the
+// contents of its inputs or tools change, and returns the stamp file to pass in
+// BuildParams.Validation.
+func BuildContentHashedValidation(ctx ModuleContext, pctx PackageContext, params ContentHashedValidationParams) Path {
+	stamp := PathForModuleOut(ctx, params.Name+".stamp")
+	script := PathForModuleOut(ctx, params.Name+".sh")
+
+	// The command is part of the script, so that changing it changes the hash.
+	WriteExecutableFileRuleVerbatim(ctx, script, fmt.Sprintf(contentHashedValidationScript,
+		params.Name, ctx.ModuleName(), strings.ReplaceAll(params.Command, "\n", " ")))
+
+	// The script hashes itself as $0.
+	hashedFiles := strings.Join(append(CopyOfPaths(params.Inputs), params.Tools...).Strings(), " ")
+
+	rule := ctx.Rule(pctx, "contentHashed_"+params.Name, blueprint.RuleParams{
+		Command: fmt.Sprintf("if ! %[1]s check $out %[2]s; then (%[3]s) && %[1]s update $out %[2]s; fi",
+			script, hashedFiles, params.Command),
+		Restat: true,
+	})
+	ctx.Build(pctx, BuildParams{
+		Rule:        rule,
+		Description: params.Description,
+		Inputs:      params.Inputs,
+		Implicits:   append(Paths{script}, params.Tools...),
+		Output:      stamp,
+	})
+	return stamp
+}
@@ -0,0 +1,124 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+type contentHashedValidationTestModule struct {
+	ModuleBase
+	properties struct {
+		Srcs []string `android:"path"`
+	}
+	stamp Path
+}
+
+func contentHashedValidationTestModuleFactory() Module {
+	m := &contentHashedValidationTestModule{}
+	m.AddProperties(&m.properties)
+	InitAndroidModule(m)
+	return m
+}
+
+func (m *contentHashedValidationTestModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+	m.stamp = BuildContentHashedValidation(ctx, pctx, ContentHashedValidationParams{
+		Name:        "check",
+		Description: "check srcs",
+		Inputs:      PathsForModuleSrc(ctx, m.properties.Srcs),
+		Tools:       Paths{PathForSource(ctx, "tools/checker")},
+		Command:     "tools/checker $in",
+	})
+}
+
+func TestContentHashedValidation(t *testing.T) {
+	result := GroupFixturePreparers(
+		FixtureRegisterWithContext(func(ctx RegistrationContext) {
+			ctx.RegisterModuleType("checked", contentHashedValidationTestModuleFactory)
+		}),
+	).RunTestWithBp(t, `
+		checked {
+			name: "foo",
+			srcs: ["a.txt", "b.txt"],
+		}
+	`)
+
+	module := result.ModuleForTests("foo", "")
+	stamp := module.Module().(*contentHashedValidationTestModule).stamp
+	AssertPathRelativeToTopEquals(t, "stamp", "out/soong/.intermediates/foo/check.stamp", stamp)
+
+	rule := module.Output("check.stamp")
+	AssertBoolEquals(t, "restat", true, rule.RuleParams.Restat)
+	AssertPathsRelativeToTopEquals(t, "inputs", []string{"a.txt", "b.txt"}, rule.Inputs)
+	AssertPathsRelativeToTopEquals(t, "implicits",
+		[]string{"out/soong/.intermediates/foo/check.sh", "tools/checker"}, rule.Implicits)
+	AssertStringEquals(t, "command",
+		"if ! out/soong/.intermediates/foo/check.sh check $out a.txt b.txt tools/checker; "+
+			"then (tools/checker $in) && out/soong/.intermediates/foo/check.sh update $out a.txt b.txt tools/checker; fi",
+		rule.RuleParams.Command)
+
+	script := ContentFromFileRuleForTests(t, result.TestContext, module.Output("check.sh"))
+	AssertStringDoesContain(t, "script", script, "# command: tools/checker $in\n")
+}
+
+// TestContentHashedValidationScript runs the wrapper script to check that it only reports the
+// stamp as up to date when neither the contents nor the list of the files changed.
+func TestContentHashedValidationScript(t *testing.T) {
+	if _, err := exec.LookPath("sha256sum"); err != nil {
+		t.Skip("sha256sum is not available")
+	}
+	dir := t.TempDir()
+	script := filepath.Join(dir, "check.sh")
+	stamp := filepath.Join(dir, "check.stamp")
+	a := filepath.Join(dir, "a.txt")
+	b := filepath.Join(dir, "b.txt")
+	writeFile := func(file, content string) {
+		if err := os.WriteFile(file, []byte(content), 0777); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(script, fmt.Sprintf(contentHashedValidationScript, "check", "foo", "true"))
+	writeFile(a, "a")
+	writeFile(b, "b")
+
+	upToDate := func(files ...string) bool {
+		return exec.Command(script, append([]string{"check", stamp}, files...)...).Run() == nil
+	}
+	update := func(files ...string) {
+		if out, err := exec.Command(script, append([]string{"update", stamp}, files...)...).CombinedOutput(); err != nil {
+			t.Fatalf("update failed: %s\n%s", err, out)
+		}
+	}
+
+	AssertBoolEquals(t, "without stamp", false, upToDate(a, b))
+	update(a, b)
+	AssertBoolEquals(t, "after update", true, upToDate(a, b))
+
+	// Rewriting a file with the same contents only changes its timestamp.
+	writeFile(a, "a")
+	AssertBoolEquals(t, "same contents", true, upToDate(a, b))
+
+	writeFile(a, "changed")
+	AssertBoolEquals(t, "changed contents", false, upToDate(a, b))
+	update(a, b)
+
+	AssertBoolEquals(t, "removed input", false, upToDate(a))
+	AssertBoolEquals(t, "added input", false, upToDate(a, b, script))
+	AssertBoolEquals(t, "same inputs", true, upToDate(a, b))
+}
@@ -0,0 +1,138 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// When SOONG_DEAD_BP_REPORT is true, out/soong/dead_bp_report.txt lists the parsed Android.bp files
+// that define no modules, or whose modules are all disabled for the current product, grouped by top
+// level directory.  The report is informational only, the files are still parsed.
+
+func init() {
+	RegisterDeadBpReportBuildComponents(InitRegistrationContext)
+}
+
+func RegisterDeadBpReportBuildComponents(ctx RegistrationContext) {
+	ctx.RegisterParallelSingletonType("dead_bp_report", deadBpReportSingletonFactory)
+}
+
+func deadBpReportEnabled(config Config) bool {
+	return config.IsEnvTrue("SOONG_DEAD_BP_REPORT")
+}
+
+func deadBpReportSingletonFactory() Singleton {
+	return &deadBpReportSingleton{}
+}
+
+// deadBpReportSingleton writes out/soong/dead_bp_report.txt.
+type deadBpReportSingleton struct{}
+
+// deadBpFile counts the modules defined by a blueprint file and how many of them are enabled.
+type deadBpFile struct {
+	modules map[string]bool
+	enabled map[string]bool
+}
+
+func (s *deadBpReportSingleton) GenerateBuildActions(ctx SingletonContext) {
+	if !deadBpReportEnabled(ctx.Config()) {
+		return
+	}
+
+	files := make(map[string]*deadBpFile)
+	for _, bpFile := range moduleListFiles(ctx) {
+		files[bpFile] = &deadBpFile{modules: make(map[string]bool), enabled: make(map[string]bool)}
+	}
+
+	ctx.VisitAllModules(func(module Module) {
+		switch module.(type) {
+		case *packageModule, *NamespaceModule:
+			// These only configure the other modules in the file.
+			return
+		}
+		bpFile := filepath.Clean(ctx.BlueprintFile(module))
+		file := files[bpFile]
+		if file == nil {
+			// A file included with build = [...] rather than listed in the module list.
+			file = &deadBpFile{modules: make(map[string]bool), enabled: make(map[string]bool)}
+			files[bpFile] = file
+		}
+		name := ctx.ModuleName(module)
+		file.modules[name] = true
+		if module.Enabled() {
+			file.enabled[name] = true
+		}
+	})
+
+	dirs := make(map[string][]string)
+	for _, bpFile := range SortedKeys(files) {
+		file := files[bpFile]
+		var reason string
+		if len(file.modules) == 0 {
+			reason = "no modules"
+		} else if len(file.enabled) == 0 {
+			reason = fmt.Sprintf("all %d modules disabled", len(file.modules))
+		} else {
+			continue
+		}
+		dir := strings.SplitN(bpFile, "/", 2)[0]
+		if dir == bpFile {
+			dir = "."
+		}
+		dirs[dir] = append(dirs[dir], fmt.Sprintf("  %s: %s", bpFile, reason))
+	}
+
+	var lines []string
+	for _, dir := range SortedKeys(dirs) {
+		lines = append(lines, fmt.Sprintf("%s: %d", dir, len(dirs[dir])))
+		lines = append(lines, dirs[dir]...)
+	}
+	WriteFileRule(ctx, PathForOutput(ctx, "dead_bp_report.txt"), strings.Join(lines, "\n"))
+}
+
+// moduleListFiles returns the blueprint files listed in the module list file that soong_build
+// parses.
+func moduleListFiles(ctx SingletonContext) []string {
+	config := ctx.Config()
+	moduleListFile := config.moduleListFile
+	if config.mockBpList != "" {
+		moduleListFile = config.mockBpList
+	}
+	if moduleListFile == "" {
+		return nil
+	}
+
+	r, err := config.fs.Open(moduleListFile)
+	if err != nil {
+		ctx.Errorf("failed to open module list file %q: %s", moduleListFile, err)
+		return nil
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		ctx.Errorf("failed to read module list file %q: %s", moduleListFile, err)
+		return nil
+	}
+
+	var bpFiles []string
+	for _, bpFile := range strings.Fields(string(data)) {
+		bpFiles = append(bpFiles, filepath.Clean(bpFile))
+	}
+	return bpFiles
+}
@@ -0,0 +1,81 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDeadBpReport(t *testing.T) {
+	result := GroupFixturePreparers(
+		PrepareForTestWithPackageModule,
+		FixtureRegisterWithContext(func(ctx RegistrationContext) {
+			ctx.RegisterModuleType("custom", customModuleFactory)
+			RegisterDeadBpReportBuildComponents(ctx)
+		}),
+		FixtureMergeEnv(map[string]string{
+			"SOONG_DEAD_BP_REPORT": "true",
+		}),
+		FixtureAddTextFile("empty/Android.bp", ""),
+		FixtureAddTextFile("vendor/dead/Android.bp", `
+			custom {
+				name: "disabled1",
+				enabled: false,
+			}
+
+			custom {
+				name: "disabled2",
+				enabled: false,
+			}
+		`),
+		FixtureAddTextFile("vendor/live/Android.bp", `
+			custom {
+				name: "disabled3",
+				enabled: false,
+			}
+
+			custom {
+				name: "live",
+			}
+		`),
+	).RunTestWithBp(t, `
+		package {
+		}
+
+		custom {
+			name: "root",
+		}
+	`)
+
+	report := result.SingletonForTests("dead_bp_report").Output("dead_bp_report.txt")
+	AssertStringEquals(t, "dead bp report", strings.Join([]string{
+		"empty: 1",
+		"  empty/Android.bp: no modules",
+		"vendor: 1",
+		"  vendor/dead/Android.bp: all 2 modules disabled",
+	}, "\n"), ContentFromFileRuleForTests(t, result.TestContext, report))
+}
+
+func TestDeadBpReportDisabledByDefault(t *testing.T) {
+	result := GroupFixturePreparers(
+		FixtureRegisterWithContext(RegisterDeadBpReportBuildComponents),
+		FixtureAddTextFile("empty/Android.bp", ""),
+	).RunTest(t)
+
+	if report := result.SingletonForTests("dead_bp_report").MaybeOutput("dead_bp_report.txt"); report.Rule != nil {
+		t.Errorf("unexpected dead_bp_report.txt without SOONG_DEAD_BP_REPORT")
+	}
+}
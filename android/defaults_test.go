@@ -170,3 +170,86 @@ func TestDefaultsPathProperties(t *testing.T) {
 	AssertStringListContains(t, "foo should depend on gen", collectDeps(foo), "gen")
 	AssertStringListDoesNotContain(t, "defaults should not depend on gen", collectDeps(defaults), "gen")
 }
+
+type defaultsRequiredTestModule struct {
+	ModuleBase
+	DefaultableModuleBase
+}
+
+func (d *defaultsRequiredTestModule) GenerateAndroidBuildActions(ctx ModuleContext) {}
+
+func (d *defaultsRequiredTestModule) AndroidMkEntries() []AndroidMkEntries {
+	return []AndroidMkEntries{{
+		Class:      "FAKE",
+		OutputFile: OptionalPathForPath(PathForTesting("out")),
+	}}
+}
+
+func defaultsRequiredTestModuleFactory() Module {
+	module := &defaultsRequiredTestModule{}
+	InitAndroidArchModule(module, HostAndDeviceSupported, MultilibCommon)
+	InitDefaultableModule(module)
+	return module
+}
+
+// TestDefaultsRequiredPerTarget verifies that the arch variant required properties provided by a
+// defaults module are only applied to the matching variants of the modules that use the defaults.
+func TestDefaultsRequiredPerTarget(t *testing.T) {
+	bp := `
+		defaults {
+			name: "defaults",
+			required: ["common_dep"],
+			target: {
+				android: {
+					required: ["device_dep"],
+				},
+				host: {
+					required: ["host_dep"],
+				},
+			},
+		}
+
+		test_arch {
+			name: "foo",
+			defaults: ["defaults"],
+			host_supported: true,
+			required: ["module_dep"],
+		}
+	`
+
+	result := GroupFixturePreparers(
+		prepareForDefaultsTest,
+		PrepareForTestWithArchMutator,
+		FixtureRegisterWithContext(func(ctx RegistrationContext) {
+			ctx.RegisterModuleType("test_arch", defaultsRequiredTestModuleFactory)
+		}),
+		FixtureWithRootAndroidBp(bp),
+	).RunTest(t)
+
+	testCases := []struct {
+		name     string
+		variant  string
+		expected []string
+	}{
+		{
+			name:     "device",
+			variant:  "android_common",
+			expected: []string{"common_dep", "device_dep", "module_dep"},
+		},
+		{
+			name:     "host",
+			variant:  result.Config.BuildOSCommonTarget.String(),
+			expected: []string{"common_dep", "host_dep", "module_dep"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			foo := result.ModuleForTests("foo", tc.variant).Module()
+			AssertArrayString(t, "RequiredModuleNames", tc.expected, SortedUniqueStrings(foo.RequiredModuleNames()))
+
+			entries := AndroidMkEntriesForTest(t, result.TestContext, foo)[0]
+			AssertArrayString(t, "LOCAL_REQUIRED_MODULES", tc.expected, SortedUniqueStrings(entries.EntryMap["LOCAL_REQUIRED_MODULES"]))
+		})
+	}
+}
@@ -76,6 +76,26 @@ var (
 		},
 		"cpFlags", "extraCmds")
 
+	// A copy rule for installs that only replaces the output when its contents differ from the
+	// input, leaving the output's timestamp alone otherwise so that rules depending on it are not
+	// rerun.
+	CpInstallIfChanged = pctx.AndroidStaticRule("CpInstallIfChanged",
+		blueprint.RuleParams{
+			Command:     "if ! cmp -s $in $out; then rm -f $out && cp $cpPreserveSymlinks $cpFlags $in $out; fi$extraCmds",
+			Description: "cp if changed $out",
+			Restat:      true,
+		},
+		"cpFlags", "extraCmds")
+
+	// The executable equivalent of CpInstallIfChanged.
+	CpExecutableInstallIfChanged = pctx.AndroidStaticRule("CpExecutableInstallIfChanged",
+		blueprint.RuleParams{
+			Command:     "if ! cmp -s $in $out; then rm -f $out && cp $cpFlags $in $out && chmod +x $out; fi$extraCmds",
+			Description: "cp if changed $out",
+			Restat:      true,
+		},
+		"cpFlags", "extraCmds")
+
 	// A timestamp touch rule.
 	Touch = pctx.AndroidStaticRule("Touch",
 		blueprint.RuleParams{
@@ -91,6 +111,15 @@ var (
 		},
 		"fromPath")
 
+	// A symlink rule that only recreates the symlink when it doesn't already point to fromPath.
+	SymlinkIfChanged = pctx.AndroidStaticRule("SymlinkIfChanged",
+		blueprint.RuleParams{
+			Command:     `if [ "$$(readlink $out)" != "$fromPath" ]; then rm -f $out && ln -f -s $fromPath $out; fi`,
+			Description: "symlink if changed $out",
+			Restat:      true,
+		},
+		"fromPath")
+
 	ErrorRule = pctx.AndroidStaticRule("Error",
 		blueprint.RuleParams{
 			Command:     `echo "$error" && false`,
@@ -0,0 +1,217 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/google/blueprint"
+)
+
+// Packages register their exported dependency tags with RegisterDependencyTag so that
+// out/soong/docs/dependency_tags.md can list the marker interfaces that each of them implements,
+// which decide how WalkDeps, installation, apexes and visibility treat the dependencies.  Setting
+// SOONG_AUDIT_DEPENDENCY_TAGS=true also lists the types of the unregistered tags of the direct
+// dependencies of every module.
+
+func init() {
+	RegisterDependencyTagsBuildComponents(InitRegistrationContext)
+
+	RegisterDependencyTag("android.DeapexerTag", "Dependency of a prebuilt apex or its contents on the deapexer module that extracts files from it.", DeapexerTag)
+	RegisterDependencyTag("android.PrebuiltDepTag", "Dependency of a source module on the prebuilt module that may replace it.", PrebuiltDepTag)
+	RegisterDependencyTag("android.ProtoPluginDepTag", "Dependency on a protoc plugin.", ProtoPluginDepTag)
+	RegisterDependencyTag("android.DarwinUniversalVariantTag", "Dependency of the x86_64 variant of a darwin universal binary on the arm64 variant.", DarwinUniversalVariantTag)
+	RegisterDependencyTag("android.licensesTag", "Dependency on the license modules listed in the licenses property.", licensesTag)
+	RegisterDependencyTag("android.licenseKindTag", "Dependency of a license module on its license_kind modules.", licenseKindTag)
+}
+
+func RegisterDependencyTagsBuildComponents(ctx RegistrationContext) {
+	ctx.RegisterParallelSingletonType("dependency_tags", dependencyTagsSingletonFactory)
+}
+
+type registeredDependencyTag struct {
+	name        string
+	description string
+	tag         blueprint.DependencyTag
+}
+
+var registeredDependencyTags []registeredDependencyTag
+
+// RegisterDependencyTag registers a dependency tag with a unique name and a description of the
+// dependencies that use it.  It must be called from an init function.
+func RegisterDependencyTag(name, description string, tag blueprint.DependencyTag) {
+	for _, registered := range registeredDependencyTags {
+		if registered.name == name {
+			panic(fmt.Errorf("dependency tag %q is already registered", name))
+		}
+	}
+	registeredDependencyTags = append(registeredDependencyTags, registeredDependencyTag{
+		name:        name,
+		description: description,
+		tag:         tag,
+	})
+}
+
+// dependencyTagMarker is an interface that changes how dependencies with a tag are treated.
+type dependencyTagMarker struct {
+	name string
+
+	// Returns true if the tag implements the interface, and the interface has a method that returns
+	// whether it applies then that it returns true.
+	implementedBy func(tag blueprint.DependencyTag) bool
+}
+
+func implements[T any](tag blueprint.DependencyTag) bool {
+	_, ok := tag.(T)
+	return ok
+}
+
+var dependencyTagMarkers = []dependencyTagMarker{
+	{"AlwaysRequireApexVariantTag", func(tag blueprint.DependencyTag) bool {
+		t, ok := tag.(AlwaysRequireApexVariantTag)
+		return ok && t.AlwaysRequireApexVariant()
+	}},
+	{"CopyDirectlyInAnyApexTag", implements[CopyDirectlyInAnyApexTag]},
+	{"ExcludeFromApexContentsTag", implements[ExcludeFromApexContentsTag]},
+	{"ExcludeFromVisibilityEnforcementTag", implements[ExcludeFromVisibilityEnforcementTag]},
+	{"InstallNeededDependencyTag", IsInstallDepNeededTag},
+	{"LicenseAnnotationsDependencyTag", implements[LicenseAnnotationsDependencyTag]},
+	{"ReplaceSourceWithPrebuilt", func(tag blueprint.DependencyTag) bool {
+		t, ok := tag.(ReplaceSourceWithPrebuilt)
+		return ok && t.ReplaceSourceWithPrebuilt()
+	}},
+	{"RequiresFilesFromPrebuiltApexTag", implements[RequiresFilesFromPrebuiltApexTag]},
+	{"SdkMemberDependencyTag", implements[SdkMemberDependencyTag]},
+	{"SkipApexAllowedDependenciesCheck", implements[SkipApexAllowedDependenciesCheck]},
+}
+
+// DependencyTagAuditEntry describes a registered dependency tag.
+type DependencyTagAuditEntry struct {
+	Name        string
+	Description string
+
+	// The type of the tag, e.g. "android.prebuiltDependencyTag".
+	Type string
+
+	// The marker interfaces that apply to the tag, sorted by name.
+	Markers []string
+}
+
+// AuditDependencyTags returns the registered dependency tags sorted by name.
+func AuditDependencyTags() []DependencyTagAuditEntry {
+	var entries []DependencyTagAuditEntry
+	for _, registered := range registeredDependencyTags {
+		entries = append(entries, auditDependencyTag(registered))
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name < entries[j].Name
+	})
+	return entries
+}
+
+func auditDependencyTag(registered registeredDependencyTag) DependencyTagAuditEntry {
+	entry := DependencyTagAuditEntry{
+		Name:        registered.name,
+		Description: registered.description,
+		Type:        reflect.TypeOf(registered.tag).String(),
+	}
+	for _, marker := range dependencyTagMarkers {
+		if marker.implementedBy(registered.tag) {
+			entry.Markers = append(entry.Markers, marker.name)
+		}
+	}
+	return entry
+}
+
+var registeredDependencyTagTypesKey = NewOnceKey("registeredDependencyTagTypes")
+
+// isRegisteredDependencyTagType returns true if a tag of the same type as the tag is registered.
+// Tags are compared by type as many tags hold values that only tell apart the properties that
+// add the dependencies.
+func isRegisteredDependencyTagType(config Config, tag blueprint.DependencyTag) bool {
+	types := config.Once(registeredDependencyTagTypesKey, func() interface{} {
+		types := make(map[reflect.Type]bool)
+		for _, registered := range registeredDependencyTags {
+			types[reflect.TypeOf(registered.tag)] = true
+		}
+		return types
+	}).(map[reflect.Type]bool)
+	return types[reflect.TypeOf(tag)]
+}
+
+// recordUnregisteredDependencyTags records the types of the unregistered tags of the direct
+// dependencies of the module if SOONG_AUDIT_DEPENDENCY_TAGS is true.
+func recordUnregisteredDependencyTags(ctx ModuleContext) {
+	if !ctx.Config().IsEnvTrue("SOONG_AUDIT_DEPENDENCY_TAGS") {
+		return
+	}
+	var unregistered []string
+	ctx.VisitDirectDepsBlueprint(func(dep blueprint.Module) {
+		tag := ctx.OtherModuleDependencyTag(dep)
+		if tag != nil && !isRegisteredDependencyTagType(ctx.Config(), tag) {
+			unregistered = append(unregistered, reflect.TypeOf(tag).String())
+		}
+	})
+	ctx.Module().base().unregisteredDependencyTags = SortedUniqueStrings(unregistered)
+}
+
+// dependencyTagsMarkdown returns the contents of dependency_tags.md.  unregistered maps the types
+// of the unregistered tags to the modules that use them, and is empty unless the audit is enabled.
+func dependencyTagsMarkdown(entries []DependencyTagAuditEntry, unregistered map[string][]string) string {
+	sb := &strings.Builder{}
+	fmt.Fprintln(sb, "# Dependency tags")
+	fmt.Fprintln(sb)
+	fmt.Fprintln(sb, "| Name | Type | Marker interfaces | Description |")
+	fmt.Fprintln(sb, "|------|------|-------------------|-------------|")
+	for _, entry := range entries {
+		fmt.Fprintf(sb, "| %s | `%s` | %s | %s |\n", entry.Name, entry.Type,
+			strings.Join(entry.Markers, ", "), entry.Description)
+	}
+
+	if len(unregistered) > 0 {
+		fmt.Fprintln(sb)
+		fmt.Fprintln(sb, "## Unregistered dependency tags")
+		fmt.Fprintln(sb)
+		for _, tagType := range SortedKeys(unregistered) {
+			fmt.Fprintf(sb, "* `%s`, used by %s\n", tagType, strings.Join(unregistered[tagType], ", "))
+		}
+	}
+	return sb.String()
+}
+
+func dependencyTagsSingletonFactory() Singleton {
+	return &dependencyTagsSingleton{}
+}
+
+// dependencyTagsSingleton writes out/soong/docs/dependency_tags.md.
+type dependencyTagsSingleton struct{}
+
+func (s *dependencyTagsSingleton) GenerateBuildActions(ctx SingletonContext) {
+	unregistered := make(map[string][]string)
+	ctx.VisitAllModules(func(module Module) {
+		for _, tagType := range module.base().unregisteredDependencyTags {
+			unregistered[tagType] = append(unregistered[tagType], ctx.ModuleName(module))
+		}
+	})
+	for tagType, modules := range unregistered {
+		unregistered[tagType] = SortedUniqueStrings(modules)
+	}
+
+	WriteFileRule(ctx, PathForOutput(ctx, "docs", "dependency_tags.md"),
+		dependencyTagsMarkdown(AuditDependencyTags(), unregistered))
+}
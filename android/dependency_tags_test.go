@@ -0,0 +1,109 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/blueprint"
+)
+
+type dependencyTagsTestTag struct {
+	blueprint.BaseDependencyTag
+	InstallAlwaysNeededDependencyTag
+}
+
+type dependencyTagsTestModule struct {
+	ModuleBase
+	properties struct {
+		Deps []string
+	}
+}
+
+func (m *dependencyTagsTestModule) DepsMutator(ctx BottomUpMutatorContext) {
+	ctx.AddDependency(m, dependencyTagsTestTag{}, m.properties.Deps...)
+}
+
+func (m *dependencyTagsTestModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+}
+
+func dependencyTagsTestModuleFactory() Module {
+	m := &dependencyTagsTestModule{}
+	m.AddProperties(&m.properties)
+	InitAndroidModule(m)
+	return m
+}
+
+func TestAuditDependencyTags(t *testing.T) {
+	entries := make(map[string]DependencyTagAuditEntry)
+	for _, entry := range AuditDependencyTags() {
+		entries[entry.Name] = entry
+	}
+
+	AssertDeepEquals(t, "android.PrebuiltDepTag", DependencyTagAuditEntry{
+		Name:        "android.PrebuiltDepTag",
+		Description: "Dependency of a source module on the prebuilt module that may replace it.",
+		Type:        "android.prebuiltDependencyTag",
+		Markers:     []string{"ExcludeFromApexContentsTag", "ExcludeFromVisibilityEnforcementTag"},
+	}, entries["android.PrebuiltDepTag"])
+	AssertDeepEquals(t, "android.licensesTag markers",
+		[]string{"LicenseAnnotationsDependencyTag", "SdkMemberDependencyTag"}, entries["android.licensesTag"].Markers)
+
+	AssertDeepEquals(t, "unregistered tag markers", []string{"InstallNeededDependencyTag"},
+		auditDependencyTag(registeredDependencyTag{name: "test", tag: dependencyTagsTestTag{}}).Markers)
+}
+
+func TestDependencyTagsSingleton(t *testing.T) {
+	bp := `
+		dependency_tags_test {
+			name: "foo",
+			deps: ["bar"],
+		}
+
+		dependency_tags_test {
+			name: "bar",
+		}
+	`
+
+	prepare := GroupFixturePreparers(
+		FixtureRegisterWithContext(func(ctx RegistrationContext) {
+			ctx.RegisterModuleType("dependency_tags_test", dependencyTagsTestModuleFactory)
+			RegisterDependencyTagsBuildComponents(ctx)
+		}),
+	)
+
+	t.Run("audit disabled", func(t *testing.T) {
+		result := prepare.RunTestWithBp(t, bp)
+		doc := result.SingletonForTests("dependency_tags").Output("docs/dependency_tags.md")
+		content := ContentFromFileRuleForTests(t, result.TestContext, doc)
+		AssertStringDoesContain(t, "registered tag", content,
+			"| android.PrebuiltDepTag | `android.prebuiltDependencyTag` | ExcludeFromApexContentsTag, ExcludeFromVisibilityEnforcementTag |")
+		AssertStringDoesNotContain(t, "unregistered tags", content, "Unregistered dependency tags")
+	})
+
+	t.Run("audit enabled", func(t *testing.T) {
+		result := GroupFixturePreparers(
+			prepare,
+			FixtureMergeEnv(map[string]string{
+				"SOONG_AUDIT_DEPENDENCY_TAGS": "true",
+			}),
+		).RunTestWithBp(t, bp)
+		doc := result.SingletonForTests("dependency_tags").Output("docs/dependency_tags.md")
+		content := ContentFromFileRuleForTests(t, result.TestContext, doc)
+		_, unregistered, _ := strings.Cut(content, "## Unregistered dependency tags\n")
+		AssertStringEquals(t, "unregistered tags", "\n* `android.dependencyTagsTestTag`, used by foo\n", unregistered)
+	})
+}
@@ -0,0 +1,108 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// The Exclude_from_dist_goals product variable lets a product stop modules that it inherits from
+// common device trees from contributing to some dist goals, without overriding the modules.  Each
+// entry is a "module:goal" pair, where the goal may be a glob pattern like "sdk*".
+
+// validateExcludeFromDistGoals checks that every entry of the Exclude_from_dist_goals product
+// variable is a valid "module:goal" pair.
+func validateExcludeFromDistGoals(exclusions []string) error {
+	for _, exclusion := range exclusions {
+		module, goal, ok := strings.Cut(exclusion, ":")
+		if !ok || module == "" || goal == "" {
+			return fmt.Errorf("Exclude_from_dist_goals: %q must be of the form <module>:<goal>", exclusion)
+		}
+		if _, err := filepath.Match(goal, ""); err != nil {
+			return fmt.Errorf("Exclude_from_dist_goals: %q: invalid goal pattern: %s", exclusion, err)
+		}
+	}
+	return nil
+}
+
+// isDistGoalExcluded returns true if the goal of the module matches an entry of the
+// Exclude_from_dist_goals product variable.
+func isDistGoalExcluded(config Config, module, goal string) bool {
+	for _, exclusion := range config.productVariables.Exclude_from_dist_goals {
+		excludedModule, pattern, _ := strings.Cut(exclusion, ":")
+		if excludedModule != module {
+			continue
+		}
+		if matched, _ := filepath.Match(pattern, goal); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// excludedDistGoals records the goals that were dropped from the dists of modules by the
+// Exclude_from_dist_goals product variable.
+type excludedDistGoals struct {
+	lock    sync.Mutex
+	dropped []string
+}
+
+var excludedDistGoalsKey = NewOnceKey("excludedDistGoals")
+
+func getExcludedDistGoals(config Config) *excludedDistGoals {
+	return config.Once(excludedDistGoalsKey, func() interface{} {
+		return &excludedDistGoals{}
+	}).(*excludedDistGoals)
+}
+
+// filterDistGoals returns the goals of the module that are not excluded by the
+// Exclude_from_dist_goals product variable, and records the ones that are.
+func filterDistGoals(config Config, module string, goals []string) []string {
+	if len(config.productVariables.Exclude_from_dist_goals) == 0 {
+		return goals
+	}
+
+	var remaining []string
+	for _, goal := range goals {
+		if !isDistGoalExcluded(config, module, goal) {
+			remaining = append(remaining, goal)
+			continue
+		}
+		excluded := getExcludedDistGoals(config)
+		excluded.lock.Lock()
+		excluded.dropped = append(excluded.dropped, module+":"+goal)
+		excluded.lock.Unlock()
+	}
+	return remaining
+}
+
+// writeExcludedDistGoalsReport writes the list of "module:goal" pairs that were dropped by the
+// Exclude_from_dist_goals product variable.
+func writeExcludedDistGoalsReport(ctx SingletonContext) {
+	if len(ctx.Config().productVariables.Exclude_from_dist_goals) == 0 {
+		return
+	}
+
+	excluded := getExcludedDistGoals(ctx.Config())
+	excluded.lock.Lock()
+	dropped := SortedUniqueStrings(excluded.dropped)
+	excluded.lock.Unlock()
+
+	report := PathForOutput(ctx, "excluded_dist_goals"+String(ctx.Config().productVariables.Make_suffix)+".txt")
+	WriteFileRule(ctx, report, strings.Join(dropped, "\n"))
+}
@@ -54,6 +54,20 @@ type EarlyModuleContext interface {
 	// PropertyErrorf reports an error at the line number of a property in the module definition.
 	PropertyErrorf(property, fmt string, args ...interface{})
 
+	// ModuleErrorfWithFix is like ModuleErrorf, and records fix as a machine-applicable fix of the
+	// error.
+	ModuleErrorfWithFix(fix FixSuggestion, fmt string, args ...interface{})
+
+	// PropertyErrorfWithFix is like PropertyErrorf for the property of fix, and records fix as a
+	// machine-applicable fix of the error.
+	PropertyErrorfWithFix(fix FixSuggestion, fmt string, args ...interface{})
+
+	// ModuleWarningf reports a warning about the module that doesn't fail the build.
+	ModuleWarningf(fmt string, args ...interface{})
+
+	// PropertyWarningf reports a warning about a property of the module that doesn't fail the build.
+	PropertyWarningf(property, fmt string, args ...interface{})
+
 	// Failed returns true if any errors have been reported.  In most cases the module can continue with generating
 	// build rules after an error, allowing it to report additional errors in a single run, but in cases where the error
 	// has prevented the module from creating necessary data it can return early when Failed returns true.
@@ -103,6 +117,24 @@ type earlyModuleContext struct {
 	config Config
 }
 
+func (e *earlyModuleContext) ModuleErrorfWithFix(fix FixSuggestion, fmt string, args ...interface{}) {
+	addFixSuggestion(e.config, e.BlueprintsFile(), e.ModuleName(), fix)
+	e.ModuleErrorf(fmt, args...)
+}
+
+func (e *earlyModuleContext) PropertyErrorfWithFix(fix FixSuggestion, fmt string, args ...interface{}) {
+	addFixSuggestion(e.config, e.BlueprintsFile(), e.ModuleName(), fix)
+	e.PropertyErrorf(fix.Property, fmt, args...)
+}
+
+func (e *earlyModuleContext) ModuleWarningf(fmt string, args ...interface{}) {
+	addBuildWarning(e.config, e.BlueprintsFile(), e.ModuleName(), "", fmt, args...)
+}
+
+func (e *earlyModuleContext) PropertyWarningf(property, fmt string, args ...interface{}) {
+	addBuildWarning(e.config, e.BlueprintsFile(), e.ModuleName(), property, fmt, args...)
+}
+
 func (e *earlyModuleContext) Glob(globPattern string, excludes []string) Paths {
 	return Glob(e, globPattern, excludes)
 }
@@ -133,7 +165,7 @@ func (e *earlyModuleContext) Module() Module {
 }
 
 func (e *earlyModuleContext) Config() Config {
-	return e.EarlyModuleContext.Config().(Config)
+	return e.EarlyModuleContext.Config().(Config).withEnvConsumer(e.ModuleType())
 }
 
 func (e *earlyModuleContext) AConfig() Config {
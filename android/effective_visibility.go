@@ -0,0 +1,159 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"encoding/json"
+	"reflect"
+	"sync"
+)
+
+func init() {
+	registerEffectiveVisibilityBuildComponents(InitRegistrationContext)
+}
+
+func registerEffectiveVisibilityBuildComponents(ctx RegistrationContext) {
+	ctx.RegisterParallelSingletonType("effective_visibility", effectiveVisibilitySingletonFactory)
+}
+
+// The sources of the effective visibility of a module.
+const (
+	// The visibility property of the module itself.
+	visibilitySourceModule = "module"
+
+	// The visibility property of the defaults of the module.
+	visibilitySourceDefaults = "defaults"
+
+	// The visibility property of both the module and its defaults.
+	visibilitySourceModuleAndDefaults = "module_and_defaults"
+
+	// The default_visibility property of the package module of the module's package or one of its
+	// ancestors.
+	visibilitySourcePackageDefault = "package_default"
+
+	// The global default visibility, as no other source provides it.
+	visibilitySourceGlobalDefault = "global_default"
+)
+
+// dumpEffectiveVisibility returns true if out/soong/effective_visibility.json should be written.
+func dumpEffectiveVisibility(config Config) bool {
+	return config.IsEnvTrue("SOONG_DUMP_EFFECTIVE_VISIBILITY")
+}
+
+var ownVisibilityMapKey = NewOnceKey("ownVisibilityMap")
+
+// The map from qualifiedModuleName to the rules of the primary visibility property set on the
+// module itself, before defaults expansion.
+func moduleToOwnVisibilityMap(config Config) *sync.Map {
+	return config.Once(ownVisibilityMapKey, func() interface{} {
+		return &sync.Map{}
+	}).(*sync.Map)
+}
+
+// recordOwnVisibility records the primary visibility property of the module before defaults are
+// applied, so that the effective_visibility singleton can tell where the rules came from.
+func recordOwnVisibility(ctx BaseModuleContext) {
+	primaryProperty := ctx.Module().base().primaryVisibilityProperty
+	if primaryProperty == nil {
+		return
+	}
+	if visibility := primaryProperty.getStrings(); visibility != nil {
+		qualified := ctx.Module().base().qualifiedModuleId(ctx)
+		moduleToOwnVisibilityMap(ctx.Config()).Store(qualified, append([]string(nil), visibility...))
+	}
+}
+
+// effectiveVisibility is the entry of a module in effective_visibility.json.
+type effectiveVisibility struct {
+	// The resolved visibility rules of the module.
+	Rules []string `json:"rules"`
+
+	// visibilitySource* constant describing where the rules came from.
+	Source string `json:"source"`
+
+	// The package whose default_visibility provided the rules, if Source is package_default.
+	Package string `json:"package,omitempty"`
+}
+
+// visibilitySource returns the source of the visibility rules that were gathered for the module,
+// given the rules of its own primary visibility property and the rules after defaults expansion.
+func visibilitySource(own, merged []string) string {
+	switch {
+	case own == nil:
+		return visibilitySourceDefaults
+	case InList("//visibility:override", own), reflect.DeepEqual(own, merged):
+		// //visibility:override discards any rules inherited from defaults.
+		return visibilitySourceModule
+	default:
+		return visibilitySourceModuleAndDefaults
+	}
+}
+
+func effectiveVisibilitySingletonFactory() Singleton {
+	return &effectiveVisibilitySingleton{}
+}
+
+// effectiveVisibilitySingleton writes out/soong/effective_visibility.json, which maps the qualified
+// id of each module to its visibility rules after defaults, package default_visibility and
+// //visibility:override processing, along with where they came from.  It is only written if
+// SOONG_DUMP_EFFECTIVE_VISIBILITY=true.
+type effectiveVisibilitySingleton struct{}
+
+func (s *effectiveVisibilitySingleton) GenerateBuildActions(ctx SingletonContext) {
+	if !dumpEffectiveVisibility(ctx.Config()) {
+		return
+	}
+
+	moduleToVisibilityRule := moduleToVisibilityRuleMap(ctx.Config())
+	moduleToOwnVisibility := moduleToOwnVisibilityMap(ctx.Config())
+
+	modules := make(map[string]effectiveVisibility)
+	ctx.VisitAllModules(func(module Module) {
+		if _, ok := module.(*packageModule); ok {
+			return
+		}
+		qualified := createQualifiedModuleName(ctx.ModuleName(module), ctx.ModuleDir(module))
+		if _, ok := modules[qualified.String()]; ok {
+			// Already visited another variant of the module.
+			return
+		}
+
+		var entry effectiveVisibility
+		if value, ok := moduleToVisibilityRule.Load(qualified); ok {
+			var own []string
+			if value, ok := moduleToOwnVisibility.Load(qualified); ok {
+				own = value.([]string)
+			}
+			entry.Rules = value.(compositeRule).Strings()
+			entry.Source = visibilitySource(own, module.base().primaryVisibilityProperty.getStrings())
+		} else if rule, pkg := packageDefaultVisibility(moduleToVisibilityRule, qualified); rule != nil {
+			entry.Rules = rule.Strings()
+			entry.Source = visibilitySourcePackageDefault
+			entry.Package = pkg.String()
+		} else {
+			entry.Rules = defaultVisibility.Strings()
+			entry.Source = visibilitySourceGlobalDefault
+		}
+		modules[qualified.String()] = entry
+	})
+
+	// Maps are marshalled with sorted keys, so the output is deterministic.
+	data, err := json.MarshalIndent(modules, "", "  ")
+	if err != nil {
+		ctx.Errorf("failed to marshal effective visibility: %s", err)
+		return
+	}
+	WriteFileRule(ctx, PathForOutput(ctx, "effective_visibility.json"), string(data))
+}
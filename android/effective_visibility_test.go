@@ -0,0 +1,130 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestEffectiveVisibilitySingleton(t *testing.T) {
+	fs := MockFS{
+		"top/Android.bp": []byte(`
+			package {
+				default_visibility: ["//top/other"],
+			}
+
+			mock_defaults {
+				name: "defaults",
+				defaults_visibility: ["//visibility:public"],
+				visibility: ["//top/a"],
+			}
+
+			mock_library {
+				name: "libmodule",
+				visibility: ["//top/d"],
+			}
+
+			mock_library {
+				name: "libdefaults",
+				defaults: ["defaults"],
+			}
+
+			mock_library {
+				name: "libboth",
+				defaults: ["defaults"],
+				visibility: ["//top/b"],
+			}
+
+			mock_library {
+				name: "liboverride",
+				defaults: ["defaults"],
+				visibility: ["//visibility:override", "//top/c"],
+			}
+
+			mock_library {
+				name: "libpackage",
+			}`),
+		"global/Android.bp": []byte(`
+			mock_library {
+				name: "libglobal",
+			}`),
+	}
+
+	result := GroupFixturePreparers(
+		PrepareForTestWithArchMutator,
+		PrepareForTestWithDefaults,
+		PrepareForTestWithPackageModule,
+		PrepareForTestWithVisibility,
+		FixtureRegisterWithContext(func(ctx RegistrationContext) {
+			ctx.RegisterModuleType("mock_library", newMockLibraryModule)
+			ctx.RegisterModuleType("mock_defaults", defaultsFactory)
+			registerEffectiveVisibilityBuildComponents(ctx)
+		}),
+		FixtureMergeEnv(map[string]string{
+			"SOONG_DUMP_EFFECTIVE_VISIBILITY": "true",
+		}),
+		fs.AddToFixture(),
+	).RunTest(t)
+
+	rule := result.SingletonForTests("effective_visibility").Output("effective_visibility.json")
+	var got map[string]effectiveVisibility
+	if err := json.Unmarshal([]byte(ContentFromFileRuleForTests(t, result.TestContext, rule)), &got); err != nil {
+		t.Fatalf("failed to parse effective_visibility.json: %s", err)
+	}
+
+	AssertDeepEquals(t, "effective visibility", map[string]effectiveVisibility{
+		"//top:defaults": {
+			Rules:  []string{"//visibility:public"},
+			Source: visibilitySourceModule,
+		},
+		"//top:libmodule": {
+			Rules:  []string{"//top/d"},
+			Source: visibilitySourceModule,
+		},
+		"//top:libdefaults": {
+			Rules:  []string{"//top/a"},
+			Source: visibilitySourceDefaults,
+		},
+		"//top:libboth": {
+			Rules:  []string{"//top/a", "//top/b"},
+			Source: visibilitySourceModuleAndDefaults,
+		},
+		"//top:liboverride": {
+			Rules:  []string{"//top/c"},
+			Source: visibilitySourceModule,
+		},
+		"//top:libpackage": {
+			Rules:   []string{"//top/other"},
+			Source:  visibilitySourcePackageDefault,
+			Package: "//top",
+		},
+		"//global:libglobal": {
+			Rules:  []string{"//visibility:public"},
+			Source: visibilitySourceGlobalDefault,
+		},
+	}, got)
+}
+
+func TestEffectiveVisibilitySingletonDisabled(t *testing.T) {
+	result := GroupFixturePreparers(
+		PrepareForTestWithVisibility,
+		FixtureRegisterWithContext(registerEffectiveVisibilityBuildComponents),
+	).RunTest(t)
+
+	if rule := result.SingletonForTests("effective_visibility").MaybeOutput("effective_visibility.json"); rule.Rule != nil {
+		t.Errorf("expected no effective_visibility.json without SOONG_DUMP_EFFECTIVE_VISIBILITY")
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/blueprint"
+)
+
+// An enablement snapshot lists every variant of every module of a product, whether it is enabled
+// and the partition it is installed in.  It is written by soong_build --enablement_snapshot_file,
+// and the snapshots of two products can be compared with diff to find the modules that are only
+// enabled on one of them, that are installed in different partitions, or that have different
+// variants.
+
+// enablementSnapshotEntry is a line of an enablement snapshot.
+type enablementSnapshotEntry struct {
+	dir       string
+	name      string
+	variant   string
+	enabled   bool
+	partition string
+}
+
+func (e enablementSnapshotEntry) String() string {
+	state := "disabled"
+	if e.enabled {
+		state = "enabled"
+	}
+	return fmt.Sprintf("%s:%s %s %s %s", e.dir, e.name, e.variant, state, e.partition)
+}
+
+// EnablementSnapshot returns the enablement snapshot of the modules, with one
+// "<dir>:<name> <variant> enabled|disabled <partition>" line per variant, sorted so that snapshots
+// of different products can be diffed.  The variant of modules that are not split into variants is
+// "-", and so is the partition of host variants.  It must be called after the mutators have run.
+func EnablementSnapshot(ctx *Context) string {
+	deviceConfig := DeviceConfig{ctx.Config().deviceConfig}
+	var entries []enablementSnapshotEntry
+	ctx.VisitAllModules(func(m blueprint.Module) {
+		module, ok := m.(Module)
+		if !ok {
+			return
+		}
+		entry := enablementSnapshotEntry{
+			dir:       ctx.ModuleDir(module),
+			name:      ctx.ModuleName(module),
+			variant:   ctx.ModuleSubDir(module),
+			enabled:   module.Enabled(),
+			partition: "-",
+		}
+		if entry.variant == "" {
+			entry.variant = "-"
+		}
+		if module.Device() {
+			entry.partition = module.PartitionTag(deviceConfig)
+		}
+		entries = append(entries, entry)
+	})
+
+	lines := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		lines = append(lines, entry.String())
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,83 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/blueprint/proptools"
+)
+
+func TestEnablementSnapshot(t *testing.T) {
+	bp := `
+		fake {
+			name: "foo",
+		}
+
+		fake {
+			name: "bundled_only",
+			product_variables: {
+				unbundled_build: {
+					enabled: false,
+				},
+			},
+		}
+
+		fake {
+			name: "product_module",
+			product_specific: true,
+		}
+
+		fake {
+			name: "disabled",
+			enabled: false,
+		}
+	`
+
+	snapshot := func(t *testing.T, preparer FixturePreparer) string {
+		t.Helper()
+		result := GroupFixturePreparers(
+			FixtureRegisterWithContext(func(ctx RegistrationContext) {
+				ctx.RegisterModuleType("fake", fakeModuleFactory)
+				ctx.PreDepsMutators(func(ctx RegisterMutatorsContext) {
+					ctx.BottomUp("variable", VariableMutator).Parallel()
+				})
+			}),
+			preparer,
+			FixtureWithRootAndroidBp(bp),
+		).RunTest(t)
+		return EnablementSnapshot(result.TestContext.Context)
+	}
+
+	bundled := snapshot(t, NullFixturePreparer)
+	AssertStringEquals(t, "bundled", strings.Join([]string{
+		".:bundled_only - enabled system",
+		".:disabled - disabled system",
+		".:foo - enabled system",
+		".:product_module - enabled product",
+	}, "\n"), bundled)
+
+	unbundled := snapshot(t, FixtureModifyProductVariables(func(variables FixtureProductVariables) {
+		variables.Unbundled_build = proptools.BoolPtr(true)
+		variables.ProductPath = proptools.StringPtr("system/product")
+	}))
+	AssertStringEquals(t, "unbundled", strings.Join([]string{
+		".:bundled_only - disabled system",
+		".:disabled - disabled system",
+		".:foo - enabled system",
+		".:product_module - enabled system",
+	}, "\n"), unbundled)
+}
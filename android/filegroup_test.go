@@ -70,3 +70,41 @@ func TestFilegroupDefaults(t *testing.T) {
 	rules := effectiveVisibilityRules(result.Config, qualifiedModuleName{pkg: "p", name: "foo"})
 	AssertDeepEquals(t, "visibility", []string{"//x", "//y"}, rules.Strings())
 }
+
+func TestFilegroupLicenseMetadataTag(t *testing.T) {
+	bp := `
+		filegroup {
+			name: "foo",
+			srcs: ["a.txt"],
+		}
+
+		filegroup {
+			name: "bar",
+			srcs: [":foo{.meta_lic}"],
+		}
+	`
+	result := GroupFixturePreparers(
+		PrepareForTestWithFilegroup,
+	).RunTestWithBp(t, bp)
+
+	bar := result.Module("bar", "").(*fileGroup)
+	AssertPathsRelativeToTopEquals(t, "srcs", []string{"out/soong/.intermediates/foo/meta_lic"}, bar.srcs)
+
+	// Modules that are exempt from the license requirements don't have license metadata files.
+	GroupFixturePreparers(
+		PrepareForTestWithFilegroup,
+		PrepareForTestWithLicenses,
+	).
+		ExtendWithErrorHandler(FixtureExpectsOneErrorPattern(
+			`path dependency ":mylicense{.meta_lic}" is not an output file producing module`)).
+		RunTestWithBp(t, `
+			license {
+				name: "mylicense",
+			}
+
+			filegroup {
+				name: "bar",
+				srcs: [":mylicense{.meta_lic}"],
+			}
+		`)
+}
@@ -0,0 +1,120 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"encoding/json"
+	"os"
+	"slices"
+	"sort"
+	"sync"
+)
+
+// Errors reported with ModuleErrorfWithFix or PropertyErrorfWithFix carry a FixSuggestion, a
+// mechanical change to the module definition that fixes the error.  soong_build writes the
+// suggestions of a run to out/soong/fix_suggestions.json, whether or not it fails, so that editors
+// and bpfix can offer to apply them.
+
+// The kinds of change of a FixSuggestion.
+const (
+	// FixReplaceValue replaces the value of the property with Value.
+	FixReplaceValue = "replace_value"
+	// FixInsertIntoList inserts Value into the list property.
+	FixInsertIntoList = "insert_into_list"
+	// FixRemoveProperty removes the property.
+	FixRemoveProperty = "remove_property"
+)
+
+// FixSuggestion is a machine-applicable fix for an error reported on a module.  File and Module are
+// filled in by ModuleErrorfWithFix and PropertyErrorfWithFix.
+type FixSuggestion struct {
+	// The blueprint file that defines the module.
+	File string `json:"file"`
+	// The name of the module.
+	Module string `json:"module"`
+	// The property to change, e.g. "compile_multilib" or "dists[1].tag".
+	Property string `json:"property"`
+	// One of FixReplaceValue, FixInsertIntoList or FixRemoveProperty.
+	Kind string `json:"kind"`
+	// The new value of the property, or the value to insert into it.  Unused by FixRemoveProperty.
+	Value string `json:"value,omitempty"`
+}
+
+// ReplaceValueFix returns a FixSuggestion that sets property to value.
+func ReplaceValueFix(property, value string) FixSuggestion {
+	return FixSuggestion{Property: property, Kind: FixReplaceValue, Value: value}
+}
+
+// InsertIntoListFix returns a FixSuggestion that adds value to the list property.
+func InsertIntoListFix(property, value string) FixSuggestion {
+	return FixSuggestion{Property: property, Kind: FixInsertIntoList, Value: value}
+}
+
+// RemovePropertyFix returns a FixSuggestion that removes property.
+func RemovePropertyFix(property string) FixSuggestion {
+	return FixSuggestion{Property: property, Kind: FixRemoveProperty}
+}
+
+var fixSuggestionsKey = NewOnceKey("fixSuggestions")
+
+type fixSuggestions struct {
+	sync.Mutex
+	list []FixSuggestion
+}
+
+func getFixSuggestions(config Config) *fixSuggestions {
+	return config.Once(fixSuggestionsKey, func() interface{} {
+		return &fixSuggestions{}
+	}).(*fixSuggestions)
+}
+
+// addFixSuggestion records the fix of an error reported on the module defined in file.
+func addFixSuggestion(config Config, file, module string, fix FixSuggestion) {
+	fix.File = file
+	fix.Module = module
+	s := getFixSuggestions(config)
+	s.Lock()
+	defer s.Unlock()
+	s.list = append(s.list, fix)
+}
+
+// FixSuggestions returns the fixes of the errors reported so far, sorted by file, module and
+// property.  A fix of an error that was reported for multiple variants of a module is only
+// returned once.
+func (c Config) FixSuggestions() []FixSuggestion {
+	s := getFixSuggestions(c)
+	s.Lock()
+	defer s.Unlock()
+	ret := append([]FixSuggestion{}, s.list...)
+	sort.SliceStable(ret, func(i, j int) bool {
+		if ret[i].File != ret[j].File {
+			return ret[i].File < ret[j].File
+		}
+		if ret[i].Module != ret[j].Module {
+			return ret[i].Module < ret[j].Module
+		}
+		return ret[i].Property < ret[j].Property
+	})
+	return slices.Compact(ret)
+}
+
+// WriteFixSuggestionsFile writes the fixes of the errors reported so far to file as JSON.
+func WriteFixSuggestionsFile(config Config, file string) error {
+	data, err := json.MarshalIndent(config.FixSuggestions(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, data, 0666)
+}
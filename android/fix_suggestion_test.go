@@ -0,0 +1,98 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var prepareForFixSuggestionTest = GroupFixturePreparers(
+	PrepareForTestWithArchMutator,
+	FixtureRegisterWithContext(func(ctx RegistrationContext) {
+		ctx.RegisterModuleType("custom", customModuleFactory)
+		ctx.RegisterModuleType("custom_arch", customArchModuleFactory)
+	}),
+)
+
+func TestFixSuggestionDistTag(t *testing.T) {
+	result := prepareForFixSuggestionTest.
+		ExtendWithErrorHandler(FixtureExpectsAllErrorsToMatchAPattern([]string{
+			`dists\[1\].tag: unsupported module reference tag ".unknown"`,
+		})).
+		RunTestWithBp(t, `
+			custom {
+				name: "foo",
+				dists: [
+					{
+						targets: ["my_goal"],
+						tag: ".multiple",
+					},
+					{
+						targets: ["my_goal"],
+						tag: ".unknown",
+					},
+				],
+			}
+		`)
+
+	AssertDeepEquals(t, "fix suggestions", []FixSuggestion{
+		{File: "Android.bp", Module: "foo", Property: "dists[1].tag", Kind: FixRemoveProperty},
+	}, result.Config.FixSuggestions())
+}
+
+func TestFixSuggestionCompileMultilib(t *testing.T) {
+	result := prepareForFixSuggestionTest.
+		ExtendWithErrorHandler(FixtureExpectsAllErrorsToMatchAPattern([]string{
+			`compile_multilib: must be .* found "lib64"`,
+			`compile_multilib: must be .* found "bogus"`,
+		})).
+		RunTestWithBp(t, `
+			custom_arch {
+				name: "misspelled",
+				compile_multilib: "lib64",
+			}
+
+			custom_arch {
+				name: "unrecognizable",
+				compile_multilib: "bogus",
+			}
+		`)
+
+	// There is no fix for an unrecognizable value.
+	expected := []FixSuggestion{
+		{File: "Android.bp", Module: "misspelled", Property: "compile_multilib", Kind: FixReplaceValue, Value: "64"},
+	}
+	AssertDeepEquals(t, "fix suggestions", expected, result.Config.FixSuggestions())
+
+	file := filepath.Join(t.TempDir(), "fix_suggestions.json")
+	if err := WriteFixSuggestionsFile(result.Config, file); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertStringEquals(t, "fix_suggestions.json", `[
+  {
+    "file": "Android.bp",
+    "module": "misspelled",
+    "property": "compile_multilib",
+    "kind": "replace_value",
+    "value": "64"
+  }
+]`, string(data))
+}
@@ -0,0 +1,159 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/google/blueprint/proptools"
+)
+
+// A change in the files matched by a glob reruns soong_build, but the globs only record their
+// patterns.  The module or singleton that created each glob, and for modules the path property
+// that contains the pattern if any, are recorded as its provenance so that --explain_rerun can
+// attribute the rerun to an Android.bp file.
+
+var globProvenanceOnceKey = NewOnceKey("glob_provenance")
+
+// GlobProvenance is a module property or a singleton that created a glob.
+type GlobProvenance struct {
+	// The module that created the glob, as "//<dir>:<name>", or the singleton, as
+	// "singleton <name>".
+	Owner string
+
+	// The path property of the module that contains the glob pattern, e.g. "srcs", or empty if the
+	// module created the glob in code or it was created by a singleton.
+	Property string `json:",omitempty"`
+}
+
+func (p GlobProvenance) String() string {
+	if p.Property == "" {
+		return p.Owner
+	}
+	return p.Owner + " " + p.Property
+}
+
+type globProvenanceKey struct {
+	pattern  string
+	excludes string
+}
+
+type globProvenances struct {
+	lock        sync.Mutex
+	provenances map[globProvenanceKey][]GlobProvenance
+}
+
+func getGlobProvenances(config Config) *globProvenances {
+	return config.Once(globProvenanceOnceKey, func() interface{} {
+		return &globProvenances{provenances: make(map[globProvenanceKey][]GlobProvenance)}
+	}).(*globProvenances)
+}
+
+func recordGlobProvenance(config Config, pattern string, excludes []string, provenance GlobProvenance) {
+	p := getGlobProvenances(config)
+	key := globProvenanceKey{pattern, strings.Join(excludes, "\x00")}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if !slices.Contains(p.provenances[key], provenance) {
+		p.provenances[key] = append(p.provenances[key], provenance)
+	}
+}
+
+// GlobProvenanceFor returns the module properties and singletons that created the glob, sorted.
+func GlobProvenanceFor(config Config, pattern string, excludes []string) []GlobProvenance {
+	p := getGlobProvenances(config)
+	key := globProvenanceKey{pattern, strings.Join(excludes, "\x00")}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	ret := slices.Clone(p.provenances[key])
+	slices.SortFunc(ret, func(a, b GlobProvenance) int {
+		return strings.Compare(a.String(), b.String())
+	})
+	return ret
+}
+
+func (e *earlyModuleContext) GlobWithDeps(pattern string, excludes []string) ([]string, error) {
+	recordGlobProvenance(e.Config(), pattern, excludes, GlobProvenance{
+		Owner:    "//" + e.ModuleDir() + ":" + e.ModuleName(),
+		Property: globPathProperty(e.Module(), e.ModuleDir(), pattern),
+	})
+	return e.EarlyModuleContext.GlobWithDeps(pattern, excludes)
+}
+
+func (s *singletonContextAdaptor) GlobWithDeps(pattern string, excludes []string) ([]string, error) {
+	recordGlobProvenance(s.Config(), pattern, excludes, GlobProvenance{Owner: "singleton " + s.name})
+	return s.SingletonContext.GlobWithDeps(pattern, excludes)
+}
+
+// globPathProperty returns the name of the path property of the module that contains the glob
+// pattern, relative to the module directory, or an empty string if there is none.
+func globPathProperty(module interface{}, moduleDir, pattern string) string {
+	m, ok := module.(Module)
+	if !ok {
+		return ""
+	}
+	rel, err := filepath.Rel(moduleDir, pattern)
+	if err != nil {
+		return ""
+	}
+	for _, ps := range m.GetProperties() {
+		v := reflect.ValueOf(ps)
+		if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+			continue
+		}
+		for _, index := range pathPropertyIndexesForPropertyStruct(ps) {
+			var values []reflect.Value
+			fieldsByIndex(v.Elem(), index, &values)
+			for _, sv := range values {
+				if !sv.IsValid() || (sv.Kind() == reflect.Ptr && sv.IsNil()) {
+					continue
+				}
+				sv = reflect.Indirect(sv)
+				var strs []string
+				switch sv.Kind() {
+				case reflect.String:
+					strs = []string{sv.String()}
+				case reflect.Slice:
+					strs, _ = sv.Interface().([]string)
+				}
+				if slices.Contains(strs, rel) {
+					return propertyNameForIndex(v.Elem().Type(), index)
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// propertyNameForIndex returns the name of the property of the field at the index in the property
+// struct type, e.g. "target.android.srcs".
+func propertyNameForIndex(t reflect.Type, index []int) string {
+	var names []string
+	for _, i := range index {
+		for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+			t = t.Elem()
+		}
+		field := t.Field(i)
+		if !field.Anonymous {
+			names = append(names, proptools.PropertyNameForField(field.Name))
+		}
+		t = field.Type
+	}
+	return strings.Join(names, ".")
+}
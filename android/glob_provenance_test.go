@@ -0,0 +1,78 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"testing"
+)
+
+type globProvenanceTestModule struct {
+	ModuleBase
+	properties struct {
+		Srcs []string `android:"path"`
+	}
+}
+
+func globProvenanceTestModuleFactory() Module {
+	m := &globProvenanceTestModule{}
+	m.AddProperties(&m.properties)
+	InitAndroidModule(m)
+	return m
+}
+
+func (m *globProvenanceTestModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+	PathsForModuleSrc(ctx, m.properties.Srcs)
+}
+
+type globProvenanceTestSingleton struct{}
+
+func (s *globProvenanceTestSingleton) GenerateBuildActions(ctx SingletonContext) {
+	if _, err := ctx.GlobWithDeps("bar/*.txt", nil); err != nil {
+		ctx.Errorf("glob failed: %s", err)
+	}
+}
+
+func TestGlobProvenance(t *testing.T) {
+	result := GroupFixturePreparers(
+		FixtureRegisterWithContext(func(ctx RegistrationContext) {
+			ctx.RegisterModuleType("glob_provenance", globProvenanceTestModuleFactory)
+			ctx.RegisterParallelSingletonType("glob_provenance_singleton", func() Singleton {
+				return &globProvenanceTestSingleton{}
+			})
+		}),
+		FixtureAddTextFile("foo/Android.bp", `
+			glob_provenance {
+				name: "foo",
+				srcs: ["*.c", "main.cpp"],
+			}
+		`),
+		FixtureAddFile("foo/a.c", nil),
+		FixtureAddFile("foo/b.c", nil),
+		FixtureAddFile("foo/main.cpp", nil),
+		FixtureAddFile("bar/a.txt", nil),
+	).RunTest(t)
+
+	config := result.Config
+	AssertDeepEquals(t, "module srcs glob provenance",
+		[]GlobProvenance{{Owner: "//foo:foo", Property: "srcs"}},
+		GlobProvenanceFor(config, "foo/*.c", nil))
+	AssertDeepEquals(t, "singleton glob provenance",
+		[]GlobProvenance{{Owner: "singleton glob_provenance_singleton"}},
+		GlobProvenanceFor(config, "bar/*.txt", nil))
+	AssertDeepEquals(t, "unknown glob provenance",
+		[]GlobProvenance(nil), GlobProvenanceFor(config, "baz/*", nil))
+	AssertStringEquals(t, "provenance string", "//foo:foo srcs",
+		GlobProvenanceFor(config, "foo/*.c", nil)[0].String())
+}
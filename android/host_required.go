@@ -0,0 +1,100 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"fmt"
+	"strings"
+)
+
+func init() {
+	RegisterHostRequiredBuildComponents(InitRegistrationContext)
+}
+
+func RegisterHostRequiredBuildComponents(ctx RegistrationContext) {
+	ctx.RegisterParallelSingletonType("host_required_check", hostRequiredCheckSingletonFactory)
+}
+
+var PrepareForTestWithHostRequiredCheck = FixtureRegisterWithContext(RegisterHostRequiredBuildComponents)
+
+func hostRequiredCheckSingletonFactory() Singleton {
+	return &hostRequiredCheckSingleton{}
+}
+
+// hostRequiredCheckSingleton verifies that every Soong module named in the host_required property
+// of a module exported to Make has an enabled variant for the OS that the build is running on.
+// Without this check a host tool that is not supported on the build OS, e.g. a tool disabled on
+// Darwin, causes a late error from Make about an unknown module.
+//
+// Names that do not match any Soong module are ignored as they may refer to modules defined in
+// Android.mk files.
+type hostRequiredCheckSingleton struct{}
+
+// hostRequiredExportedToMake returns true if the module will be written to the Android.mk file, and
+// so have its host_required property exported to Make.  It mirrors shouldSkipAndroidMkProcessing
+// but uses the configured build OS instead of the OS that soong_build is running on.
+func hostRequiredExportedToMake(config Config, module Module) bool {
+	if shouldSkipAndroidMkProcessing(module.base()) {
+		return false
+	}
+	// On Mac only host darwin modules are exposed to Make.
+	if config.BuildOS == Darwin && module.Target().Os != Darwin {
+		return false
+	}
+	return true
+}
+
+func (s *hostRequiredCheckSingleton) GenerateBuildActions(ctx SingletonContext) {
+	config := ctx.Config()
+
+	// The names of all Soong modules, and whether they have an enabled variant for the build OS.
+	supportedOnBuildOS := make(map[string]bool)
+	ctx.VisitAllModules(func(module Module) {
+		names := []string{ctx.ModuleName(module)}
+		if base := RemoveOptionalPrebuiltPrefix(names[0]); base != names[0] {
+			names = append(names, base)
+		}
+		supported := module.Enabled() && module.Target().Os == config.BuildOS && !module.Target().HostCross
+		for _, name := range names {
+			supportedOnBuildOS[name] = supportedOnBuildOS[name] || supported
+		}
+	})
+
+	var unsupported []string
+	ctx.VisitAllModules(func(module Module) {
+		if !hostRequiredExportedToMake(config, module) {
+			return
+		}
+		for _, name := range module.HostRequiredModuleNames() {
+			if supported, isSoongModule := supportedOnBuildOS[name]; !isSoongModule || supported {
+				continue
+			}
+			if config.AllowMissingDependencies() {
+				unsupported = append(unsupported,
+					fmt.Sprintf("%s: %s (%s)", ctx.ModuleName(module), name, config.BuildOS.Name))
+			} else {
+				ctx.ModuleErrorf(module, "host_required module %q has no enabled variant for the build OS %s",
+					name, config.BuildOS.Name)
+			}
+		}
+	})
+
+	if len(unsupported) > 0 {
+		// Record the unsupported host tools when missing dependencies are allowed, Make will report
+		// them if they are actually needed.
+		WriteFileRule(ctx, PathForOutput(ctx, "host_required_unsupported.txt"),
+			strings.Join(SortedUniqueStrings(unsupported), "\n"))
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"testing"
+)
+
+// prepareForTestWithDarwinBuildOS simulates a build running on a Mac by replacing the build OS
+// targets with Darwin targets.  It must be applied after PrepareForTestWithArchMutator.
+var prepareForTestWithDarwinBuildOS = FixtureModifyConfig(func(config Config) {
+	delete(config.Targets, config.BuildOS)
+	config.BuildOS = Darwin
+	config.Targets[Darwin] = []Target{
+		{Darwin, Arch{ArchType: X86_64}, NativeBridgeDisabled, "", "", false},
+	}
+	config.BuildOSTarget = config.Targets[Darwin][0]
+	config.BuildOSCommonTarget = getCommonTargets(config.Targets[Darwin])[0]
+})
+
+func TestHostRequiredCheck(t *testing.T) {
+	bp := `
+		deps {
+			name: "foo",
+			host_required: ["tool", "make_only_tool"],
+		}
+
+		deps {
+			name: "tool",
+			device_supported: false,
+			target: {
+				darwin: {
+					enabled: false,
+				},
+			},
+		}
+	`
+
+	testCases := []struct {
+		name                     string
+		preparer                 FixturePreparer
+		allowMissingDependencies bool
+		expectedError            string
+		expectedUnsupportedFile  string
+	}{
+		{
+			name:     "linux",
+			preparer: NullFixturePreparer,
+		},
+		{
+			name:          "darwin",
+			preparer:      prepareForTestWithDarwinBuildOS,
+			expectedError: `module "foo" variant "darwin_common": host_required module "tool" has no enabled variant for the build OS darwin`,
+		},
+		{
+			name:                     "darwin allow missing dependencies",
+			preparer:                 prepareForTestWithDarwinBuildOS,
+			allowMissingDependencies: true,
+			expectedUnsupportedFile:  "foo: tool (darwin)",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			errorHandler := FixtureExpectsNoErrors
+			if tc.expectedError != "" {
+				errorHandler = FixtureExpectsOneErrorPattern(tc.expectedError)
+			}
+			preparers := []FixturePreparer{
+				prepareForModuleTests,
+				PrepareForTestWithArchMutator,
+				PrepareForTestWithHostRequiredCheck,
+				tc.preparer,
+			}
+			if tc.allowMissingDependencies {
+				preparers = append(preparers, PrepareForTestWithAllowMissingDependencies)
+			}
+			result := GroupFixturePreparers(preparers...).
+				ExtendWithErrorHandler(errorHandler).
+				RunTestWithBp(t, bp)
+
+			if tc.expectedUnsupportedFile != "" {
+				output := result.SingletonForTests("host_required_check").Output("host_required_unsupported.txt")
+				AssertStringEquals(t, "host_required_unsupported.txt", tc.expectedUnsupportedFile,
+					ContentFromFileRuleForTests(t, result.TestContext, output))
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/google/blueprint"
+)
+
+func init() {
+	RegisterHostToolsUsedBuildComponents(InitRegistrationContext)
+}
+
+func RegisterHostToolsUsedBuildComponents(ctx RegistrationContext) {
+	ctx.RegisterParallelSingletonType("host_tools_used", hostToolsUsedSingletonFactory)
+}
+
+// HostToolUse records a host tool used by a module, so that hermeticity audits can tell whether the
+// module used a source built or a prebuilt tool.
+type HostToolUse struct {
+	// The name of the tool module, without any prebuilt_ prefix.
+	Tool string
+
+	// True if the tool was provided by a prebuilt module.
+	Prebuilt bool
+
+	// The path to the tool.
+	Path string
+}
+
+// NewHostToolUse returns the HostToolUse for the tool module that provides the path.  The tool
+// module must be the one that was actually used, i.e. the result of PrebuiltGetPreferred.
+func NewHostToolUse(ctx BaseModuleContext, tool blueprint.Module, path Path) HostToolUse {
+	prebuilt := false
+	if m, ok := tool.(Module); ok {
+		prebuilt = IsModulePrebuilt(m)
+	}
+	return HostToolUse{
+		Tool:     RemoveOptionalPrebuiltPrefix(ctx.OtherModuleName(tool)),
+		Prebuilt: prebuilt,
+		Path:     path.String(),
+	}
+}
+
+// HostToolsUsedInfo is provided by modules that run host tools.
+type HostToolsUsedInfo struct {
+	// The host tools used by the module, sorted by tool name, with one entry per tool.
+	Tools []HostToolUse
+}
+
+var HostToolsUsedInfoProvider = blueprint.NewProvider[HostToolsUsedInfo]()
+
+// NewHostToolsUsedInfo returns a HostToolsUsedInfo for the tools, keeping the first use of each tool.
+func NewHostToolsUsedInfo(tools []HostToolUse) HostToolsUsedInfo {
+	return HostToolsUsedInfo{Tools: dedupHostToolUses(tools)}
+}
+
+// dedupHostToolUses returns the first use of each tool sorted by tool name.
+func dedupHostToolUses(tools []HostToolUse) []HostToolUse {
+	seen := make(map[string]bool)
+	var deduped []HostToolUse
+	for _, tool := range tools {
+		if !seen[tool.Tool] {
+			seen[tool.Tool] = true
+			deduped = append(deduped, tool)
+		}
+	}
+	sort.SliceStable(deduped, func(i, j int) bool {
+		return deduped[i].Tool < deduped[j].Tool
+	})
+	return deduped
+}
+
+func hostToolsUsedSingletonFactory() Singleton {
+	return &hostToolsUsedSingleton{}
+}
+
+// hostToolsUsedSingleton writes out/soong/host_tools_used.json, which maps the name of each module
+// that provides HostToolsUsedInfo to the host tools it used across all of its variants.
+type hostToolsUsedSingleton struct{}
+
+func (s *hostToolsUsedSingleton) GenerateBuildActions(ctx SingletonContext) {
+	uses := make(map[string][]HostToolUse)
+	ctx.VisitAllModules(func(module Module) {
+		if info, ok := SingletonModuleProvider(ctx, module, HostToolsUsedInfoProvider); ok {
+			name := ctx.ModuleName(module)
+			uses[name] = append(uses[name], info.Tools...)
+		}
+	})
+
+	for name := range uses {
+		uses[name] = dedupHostToolUses(uses[name])
+	}
+
+	// Maps are marshalled with sorted keys, so the output is deterministic.
+	data, err := json.MarshalIndent(uses, "", "  ")
+	if err != nil {
+		ctx.Errorf("failed to marshal host tools used: %s", err)
+		return
+	}
+	WriteFileRule(ctx, PathForOutput(ctx, "host_tools_used.json"), string(data))
+}
@@ -66,6 +66,22 @@ const (
 
 	// DebugRamdiskVariation means a module to be installed to debug ramdisk image.
 	DebugRamdiskVariation string = "debug_ramdisk"
+
+	// VendorVariation is the variant name used for /vendor code that does not
+	// compile against the VNDK.
+	VendorVariation string = "vendor"
+
+	// VendorVariationPrefix is the variant prefix used for /vendor code that compiles
+	// against the VNDK.
+	VendorVariationPrefix string = "vendor."
+
+	// ProductVariation is the variant name used for /product code that does not
+	// compile against the VNDK.
+	ProductVariation string = "product"
+
+	// ProductVariationPrefix is the variant prefix used for /product code that compiles
+	// against the VNDK.
+	ProductVariationPrefix string = "product."
 )
 
 // imageMutator creates variants for modules that implement the ImageInterface that
@@ -0,0 +1,197 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/blueprint"
+)
+
+// InstallClosure is the transitive install closure of a module, i.e. the files that are installed
+// when the module is installed, for use by tools that analyze what installing a module drags in.
+type InstallClosure struct {
+	// Name of the module.
+	Module string
+
+	// The closures of each variant of the module.
+	Variants []InstallClosureVariant
+}
+
+// InstallClosureVariant is the transitive install closure of a variant of a module.
+type InstallClosureVariant struct {
+	// The variant of the module, e.g. "android_arm64_armv8-a_shared".
+	Variant string
+
+	// The transitive install files of the variant.
+	InstallFiles []InstallClosureFile
+
+	// The transitive packaging specs of the variant.
+	PackagingSpecs []InstallClosurePackagingSpec
+}
+
+// InstallClosureFile is a file in a transitive install closure.
+type InstallClosureFile struct {
+	// The install path of the file.
+	Path string
+
+	// The modules that install the file.
+	Owners []string
+}
+
+// InstallClosurePackagingSpec is a packaging spec in a transitive install closure.
+type InstallClosurePackagingSpec struct {
+	// The partition the file is installed in.
+	Partition string
+
+	// The path of the file relative to the root of the partition.
+	RelPathInPackage string
+
+	// The path of the built file, if the file isn't a symlink.
+	SrcPath string `json:",omitempty"`
+
+	// The target of the symlink, if the file is a symlink.
+	SymlinkTarget string `json:",omitempty"`
+
+	Executable bool `json:",omitempty"`
+
+	// The modules that install the file.
+	Owners []string
+}
+
+func packagingSpecKey(spec *PackagingSpec) string {
+	return spec.partition + ":" + spec.relPathInPackage
+}
+
+// ComputeInstallClosures returns the transitive install closures of each variant of the named
+// modules.  It must be called after the build actions have been generated.  It returns an error
+// listing similarly named modules if a module doesn't exist.
+func ComputeInstallClosures(ctx *Context, names []string) ([]InstallClosure, error) {
+	variants := make(map[string][]Module)
+	installOwners := make(map[string][]string)
+	packagingSpecOwners := make(map[string][]string)
+	var allNames []string
+
+	ctx.VisitAllModules(func(m blueprint.Module) {
+		module, ok := m.(Module)
+		if !ok {
+			return
+		}
+		name := ctx.ModuleName(module)
+		allNames = append(allNames, name)
+		if InList(name, names) {
+			variants[name] = append(variants[name], module)
+		}
+		for _, installed := range module.base().installFiles {
+			installOwners[installed.String()] = append(installOwners[installed.String()], name)
+		}
+		for i := range module.base().packagingSpecs {
+			key := packagingSpecKey(&module.base().packagingSpecs[i])
+			packagingSpecOwners[key] = append(packagingSpecOwners[key], name)
+		}
+	})
+
+	var errs []string
+	for _, name := range names {
+		if _, ok := variants[name]; ok {
+			continue
+		}
+		err := fmt.Sprintf("module %q does not exist", name)
+		if suggestions := similarNames(name, SortedUniqueStrings(allNames)); len(suggestions) > 0 {
+			err += fmt.Sprintf(", did you mean %q?", suggestions)
+		}
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("%s", strings.Join(errs, "\n"))
+	}
+
+	var closures []InstallClosure
+	for _, name := range FirstUniqueStrings(names) {
+		closure := InstallClosure{Module: name}
+		for _, module := range variants[name] {
+			variant := InstallClosureVariant{Variant: ctx.ModuleSubDir(module)}
+			for _, installed := range module.base().installFilesDepSet.ToList() {
+				variant.InstallFiles = append(variant.InstallFiles, InstallClosureFile{
+					Path:   installed.String(),
+					Owners: SortedUniqueStrings(installOwners[installed.String()]),
+				})
+			}
+			for _, spec := range module.base().packagingSpecsDepSet.ToList() {
+				closureSpec := InstallClosurePackagingSpec{
+					Partition:        spec.partition,
+					RelPathInPackage: spec.relPathInPackage,
+					SymlinkTarget:    spec.symlinkTarget,
+					Executable:       spec.executable,
+					Owners:           SortedUniqueStrings(packagingSpecOwners[packagingSpecKey(&spec)]),
+				}
+				if spec.srcPath != nil {
+					closureSpec.SrcPath = spec.srcPath.String()
+				}
+				variant.PackagingSpecs = append(variant.PackagingSpecs, closureSpec)
+			}
+			closure.Variants = append(closure.Variants, variant)
+		}
+		closures = append(closures, closure)
+	}
+	return closures, nil
+}
+
+// The maximum number of similar names suggested for an unknown name, e.g. of a module.
+const maxNameSuggestions = 5
+
+// similarNames returns the names that are within a small edit distance of the name, closest
+// first.
+func similarNames(name string, names []string) []string {
+	maxDistance := len(name)/3 + 1
+	distances := make(map[string]int)
+	var similar []string
+	for _, candidate := range names {
+		if d := editDistance(name, candidate); d <= maxDistance {
+			distances[candidate] = d
+			similar = append(similar, candidate)
+		}
+	}
+	sort.SliceStable(similar, func(i, j int) bool {
+		return distances[similar[i]] < distances[similar[j]]
+	})
+	if len(similar) > maxNameSuggestions {
+		similar = similar[:maxNameSuggestions]
+	}
+	return similar
+}
+
+// editDistance returns the Levenshtein distance between the two strings.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
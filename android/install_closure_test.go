@@ -0,0 +1,103 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestComputeInstallClosures(t *testing.T) {
+	bp := `
+		deps {
+			name: "foo",
+			deps: ["bar"],
+		}
+
+		deps {
+			name: "bar",
+			deps: ["baz"],
+		}
+
+		deps {
+			name: "baz",
+		}
+
+		deps {
+			name: "unrelated",
+		}
+	`
+
+	result := GroupFixturePreparers(
+		prepareForModuleTests,
+		PrepareForTestWithArchMutator,
+	).RunTestWithBp(t, bp)
+
+	closures, err := ComputeInstallClosures(result.TestContext.Context, []string{"bar", "foo", "bar"})
+	FailIfErrored(t, []error{err})
+	AssertIntEquals(t, "number of closures", 2, len(closures))
+	AssertStringEquals(t, "first module", "bar", closures[0].Module)
+	AssertStringEquals(t, "second module", "foo", closures[1].Module)
+
+	var device *InstallClosureVariant
+	for i, variant := range closures[1].Variants {
+		if variant.Variant == "android_common" {
+			device = &closures[1].Variants[i]
+		}
+	}
+	if device == nil {
+		t.Fatalf("missing android_common variant of foo in %v", closures[1].Variants)
+	}
+
+	var installFiles []string
+	for _, installed := range device.InstallFiles {
+		AssertArrayString(t, "owners of "+installed.Path, []string{filepath.Base(installed.Path)}, installed.Owners)
+		installFiles = append(installFiles, installed.Path)
+	}
+	AssertArrayString(t, "install files of foo", []string{
+		"out/soong/target/product/test_device/system/bar",
+		"out/soong/target/product/test_device/system/baz",
+		"out/soong/target/product/test_device/system/foo",
+		"out/soong/target/product/test_device/system/symlinks/bar",
+		"out/soong/target/product/test_device/system/symlinks/baz",
+		"out/soong/target/product/test_device/system/symlinks/foo",
+	}, SortedUniqueStrings(installFiles))
+
+	var packagingSpecs []string
+	for _, spec := range device.PackagingSpecs {
+		AssertArrayString(t, "owners of "+spec.RelPathInPackage, []string{filepath.Base(spec.RelPathInPackage)}, spec.Owners)
+		AssertStringEquals(t, "partition of "+spec.RelPathInPackage, "system", spec.Partition)
+		packagingSpecs = append(packagingSpecs, spec.RelPathInPackage)
+	}
+	AssertArrayString(t, "packaging specs of foo",
+		[]string{"bar", "baz", "foo", "symlinks/bar", "symlinks/baz", "symlinks/foo"},
+		SortedUniqueStrings(packagingSpecs))
+}
+
+func TestComputeInstallClosuresUnknownModule(t *testing.T) {
+	bp := `
+		deps {
+			name: "libfoo",
+		}
+	`
+
+	result := GroupFixturePreparers(
+		prepareForModuleTests,
+		PrepareForTestWithArchMutator,
+	).RunTestWithBp(t, bp)
+
+	_, err := ComputeInstallClosures(result.TestContext.Context, []string{"libfo"})
+	AssertErrorMessageEquals(t, "unknown module error", `module "libfo" does not exist, did you mean ["libfoo"]?`, err)
+}
@@ -0,0 +1,95 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/google/blueprint"
+)
+
+// InstallListEntry is a file that is installed by a module, for use by tools like image size
+// dashboards that need the install destinations of a product but not the actions to build them.
+type InstallListEntry struct {
+	// The install path of the file.
+	Path string
+
+	// The name of the module that installs the file.
+	Module string
+
+	// The variant of the module, e.g. "android_arm64_armv8-a_shared".
+	Variant string
+
+	// The partition the file is installed in, e.g. "system", empty for host files.
+	Partition string `json:",omitempty"`
+}
+
+// ComputeInstallList returns the files installed by all the enabled modules, sorted by path and
+// then by module and variant.  It must be called after the build actions have been generated, but
+// does not need the ninja file to have been written.
+func ComputeInstallList(ctx *Context) []InstallListEntry {
+	var entries []InstallListEntry
+	ctx.VisitAllModules(func(m blueprint.Module) {
+		module, ok := m.(Module)
+		if !ok || !module.Enabled() || module.base().IsSkipInstall() {
+			return
+		}
+		for _, installed := range module.base().installFiles {
+			entries = append(entries, InstallListEntry{
+				Path:      installed.String(),
+				Module:    ctx.ModuleName(module),
+				Variant:   ctx.ModuleSubDir(module),
+				Partition: installed.Partition(),
+			})
+		}
+	})
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Path != entries[j].Path {
+			return entries[i].Path < entries[j].Path
+		}
+		if entries[i].Module != entries[j].Module {
+			return entries[i].Module < entries[j].Module
+		}
+		return entries[i].Variant < entries[j].Variant
+	})
+	return entries
+}
+
+// WriteInstallList writes the entries as JSON if jsonFormat is true, or otherwise as one line per
+// file containing the path, module, variant and partition separated by spaces.
+func WriteInstallList(w io.Writer, entries []InstallListEntry, jsonFormat bool) error {
+	if jsonFormat {
+		data, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(append(data, '\n'))
+		return err
+	}
+	for _, entry := range entries {
+		partition := entry.Partition
+		if partition == "" {
+			partition = "-"
+		}
+		if _, err := fmt.Fprintf(w, "%s %s %s %s\n", entry.Path, entry.Module, entry.Variant, partition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestComputeInstallList(t *testing.T) {
+	bp := `
+		deps {
+			name: "foo",
+			deps: ["bar"],
+		}
+
+		deps {
+			name: "bar",
+		}
+
+		deps {
+			name: "disabled",
+			enabled: false,
+		}
+	`
+
+	result := GroupFixturePreparers(
+		prepareForModuleTests,
+		PrepareForTestWithArchMutator,
+	).RunTestWithBp(t, bp)
+
+	entries := ComputeInstallList(result.TestContext.Context)
+
+	var deviceEntries []InstallListEntry
+	for _, entry := range entries {
+		if entry.Module == "disabled" {
+			t.Errorf("unexpected entry for disabled module: %v", entry)
+		}
+		if entry.Variant == "android_common" {
+			deviceEntries = append(deviceEntries, entry)
+		}
+	}
+
+	var text bytes.Buffer
+	FailIfErrored(t, []error{WriteInstallList(&text, deviceEntries, false)})
+	AssertStringEquals(t, "text install list", strings.Join([]string{
+		"out/soong/target/product/test_device/system/bar bar android_common system",
+		"out/soong/target/product/test_device/system/foo foo android_common system",
+		"out/soong/target/product/test_device/system/symlinks/bar bar android_common system",
+		"out/soong/target/product/test_device/system/symlinks/foo foo android_common system",
+		"",
+	}, "\n"), text.String())
+
+	var jsonList bytes.Buffer
+	FailIfErrored(t, []error{WriteInstallList(&jsonList, deviceEntries, true)})
+	var decoded []InstallListEntry
+	FailIfErrored(t, []error{json.Unmarshal(jsonList.Bytes(), &decoded)})
+	AssertDeepEquals(t, "json install list", deviceEntries, decoded)
+}
@@ -0,0 +1,209 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/google/blueprint"
+)
+
+// Modules can attach the metadata that the image builders need for an installed file, like its
+// mode, owner, capabilities and SELinux label, with InstallFileWithOptions instead of listing it in
+// fs_config and file_contexts files far from the module.  The install_file_metadata singleton
+// aggregates the metadata of the installed files into an fs_config file and a file_contexts file
+// per partition, out/soong/install_file_metadata/<partition>/{fs_config,file_contexts}, which are
+// passed to Make in SOONG_FS_CONFIG_<PARTITION> and SOONG_FILE_CONTEXTS_<PARTITION>.
+
+func init() {
+	registerInstallFileMetadataBuildComponents(InitRegistrationContext)
+}
+
+func registerInstallFileMetadataBuildComponents(ctx RegistrationContext) {
+	ctx.RegisterParallelSingletonType("install_file_metadata", installFileMetadataSingletonFactory)
+}
+
+// InstallFileMetadata is the metadata of an installed file for the image builders.
+type InstallFileMetadata struct {
+	// The mode of the file, e.g. 0750.  Defaults to 0755 for executables and 0644 otherwise.
+	Mode *uint32
+
+	// The uid and gid of the file.  Default to 0 (root).
+	Uid *int
+	Gid *int
+
+	// The capabilities of the file, as the bitmask written in fs_config files.
+	Capabilities uint64
+
+	// The SELinux label of the file, e.g. "u:object_r:foo_exec:s0".
+	Selabel string
+}
+
+// InstallFileOptions are the options of ModuleContext.InstallFileWithOptions.
+type InstallFileOptions struct {
+	// Additional dependencies of the installed file.
+	Deps InstallPaths
+
+	// Whether the file is marked executable after copying.
+	Executable bool
+
+	// The metadata of the file for the image builders, or nil.
+	Metadata *InstallFileMetadata
+}
+
+// InstallFileMetadataEntry is the metadata of a file installed by a module.
+type InstallFileMetadataEntry struct {
+	// The partition the file is installed in, and its path relative to the partition.
+	Partition string
+	Path      string
+
+	Executable bool
+	Metadata   InstallFileMetadata
+}
+
+// hasFsConfig returns true if the entry sets any of the fields of fs_config files.
+func (e InstallFileMetadataEntry) hasFsConfig() bool {
+	m := e.Metadata
+	return m.Mode != nil || m.Uid != nil || m.Gid != nil || m.Capabilities != 0
+}
+
+// fsConfigLine returns the line of the entry in an fs_config file, e.g.
+// "system/bin/foo 0 2000 0750 capabilities=0x1000".
+func (e InstallFileMetadataEntry) fsConfigLine() string {
+	mode := uint32(0644)
+	if e.Executable {
+		mode = 0755
+	}
+	if e.Metadata.Mode != nil {
+		mode = *e.Metadata.Mode
+	}
+	uid, gid := 0, 0
+	if e.Metadata.Uid != nil {
+		uid = *e.Metadata.Uid
+	}
+	if e.Metadata.Gid != nil {
+		gid = *e.Metadata.Gid
+	}
+	return fmt.Sprintf("%s %d %d %04o capabilities=0x%x", filepath.Join(e.Partition, e.Path), uid, gid,
+		mode, e.Metadata.Capabilities)
+}
+
+// fileContextsLine returns the line of the entry in a file_contexts file, e.g.
+// "/system/bin/foo u:object_r:foo_exec:s0".
+func (e InstallFileMetadataEntry) fileContextsLine() string {
+	return fmt.Sprintf("%s %s", regexp.QuoteMeta("/"+filepath.Join(e.Partition, e.Path)), e.Metadata.Selabel)
+}
+
+// InstallFileMetadataInfo lists the metadata of the files installed by a module with
+// InstallFileWithOptions.
+type InstallFileMetadataInfo struct {
+	Entries []InstallFileMetadataEntry
+}
+
+var InstallFileMetadataProvider = blueprint.NewProvider[InstallFileMetadataInfo]()
+
+// installFileMetadataInfo returns the metadata of the files installed in partitions among the
+// packaging specs.  Files that are only packaged are left to the modules that package them.
+func installFileMetadataInfo(specs []PackagingSpec) InstallFileMetadataInfo {
+	var info InstallFileMetadataInfo
+	for _, spec := range specs {
+		if spec.metadata == nil || spec.skipReason != SkipReasonNone || spec.partition == "" {
+			continue
+		}
+		info.Entries = append(info.Entries, InstallFileMetadataEntry{
+			Partition:  spec.partition,
+			Path:       spec.relPathInPackage,
+			Executable: spec.executable,
+			Metadata:   *spec.metadata,
+		})
+	}
+	return info
+}
+
+func installFileMetadataSingletonFactory() Singleton {
+	return &installFileMetadataSingleton{}
+}
+
+type installFileMetadataSingleton struct {
+	// The aggregated fs_config and file_contexts files of each partition.
+	fsConfigs    map[string]WritablePath
+	fileContexts map[string]WritablePath
+}
+
+func (s *installFileMetadataSingleton) GenerateBuildActions(ctx SingletonContext) {
+	type owned struct {
+		module string
+		entry  InstallFileMetadataEntry
+	}
+	// The entries of each partition by their path.
+	partitions := make(map[string]map[string]owned)
+
+	ctx.VisitAllModules(func(module Module) {
+		info, ok := SingletonModuleProvider(ctx, module, InstallFileMetadataProvider)
+		if !ok {
+			return
+		}
+		for _, entry := range info.Entries {
+			entries := partitions[entry.Partition]
+			if entries == nil {
+				entries = make(map[string]owned)
+				partitions[entry.Partition] = entries
+			}
+			if existing, exists := entries[entry.Path]; exists {
+				if existing.entry.fsConfigLine() != entry.fsConfigLine() ||
+					existing.entry.Metadata.Selabel != entry.Metadata.Selabel {
+					ctx.Errorf("conflicting install metadata for %s: %q by %s and %q by %s",
+						filepath.Join(entry.Partition, entry.Path),
+						existing.entry.fsConfigLine()+" "+existing.entry.Metadata.Selabel, existing.module,
+						entry.fsConfigLine()+" "+entry.Metadata.Selabel, ctx.ModuleName(module))
+				}
+				continue
+			}
+			entries[entry.Path] = owned{module: ctx.ModuleName(module), entry: entry}
+		}
+	})
+
+	s.fsConfigs = make(map[string]WritablePath)
+	s.fileContexts = make(map[string]WritablePath)
+	for _, partition := range SortedKeys(partitions) {
+		entries := partitions[partition]
+		var fsConfig, fileContexts strings.Builder
+		for _, path := range SortedKeys(entries) {
+			entry := entries[path].entry
+			if entry.hasFsConfig() {
+				fsConfig.WriteString(entry.fsConfigLine() + "\n")
+			}
+			if entry.Metadata.Selabel != "" {
+				fileContexts.WriteString(entry.fileContextsLine() + "\n")
+			}
+		}
+
+		s.fsConfigs[partition] = PathForOutput(ctx, "install_file_metadata", partition, "fs_config")
+		WriteFileRuleVerbatim(ctx, s.fsConfigs[partition], fsConfig.String())
+		s.fileContexts[partition] = PathForOutput(ctx, "install_file_metadata", partition, "file_contexts")
+		WriteFileRuleVerbatim(ctx, s.fileContexts[partition], fileContexts.String())
+	}
+}
+
+func (s *installFileMetadataSingleton) MakeVars(ctx MakeVarsContext) {
+	for _, partition := range SortedKeys(s.fsConfigs) {
+		suffix := strings.ToUpper(strings.ReplaceAll(partition, "/", "_"))
+		ctx.Strict("SOONG_FS_CONFIG_"+suffix, s.fsConfigs[partition].String())
+		ctx.Strict("SOONG_FILE_CONTEXTS_"+suffix, s.fileContexts[partition].String())
+	}
+}
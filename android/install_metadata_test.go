@@ -0,0 +1,144 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"testing"
+
+	"github.com/google/blueprint/proptools"
+)
+
+type installMetadataTestModule struct {
+	ModuleBase
+	properties struct {
+		Stem         *string
+		Executable   *bool
+		Mode         *int64
+		Uid          *int64
+		Gid          *int64
+		Capabilities *int64
+		Selabel      *string
+	}
+}
+
+func installMetadataTestModuleFactory() Module {
+	m := &installMetadataTestModule{}
+	m.AddProperties(&m.properties)
+	InitAndroidArchModule(m, DeviceSupported, MultilibFirst)
+	return m
+}
+
+func (m *installMetadataTestModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+	p := m.properties
+	metadata := &InstallFileMetadata{
+		Capabilities: uint64(proptools.Int(p.Capabilities)),
+		Selabel:      proptools.String(p.Selabel),
+	}
+	if p.Mode != nil {
+		mode := uint32(*p.Mode)
+		metadata.Mode = &mode
+	}
+	if p.Uid != nil {
+		uid := int(*p.Uid)
+		metadata.Uid = &uid
+	}
+	if p.Gid != nil {
+		gid := int(*p.Gid)
+		metadata.Gid = &gid
+	}
+	ctx.InstallFileWithOptions(PathForModuleInstall(ctx, "bin"), proptools.StringDefault(p.Stem, ctx.ModuleName()),
+		PathForTesting("src"), InstallFileOptions{
+			Executable: proptools.Bool(p.Executable),
+			Metadata:   metadata,
+		})
+}
+
+var prepareForInstallMetadataTest = GroupFixturePreparers(
+	PrepareForTestWithArchMutator,
+	FixtureRegisterWithContext(func(ctx RegistrationContext) {
+		ctx.RegisterModuleType("install_metadata", installMetadataTestModuleFactory)
+		registerInstallFileMetadataBuildComponents(ctx)
+	}),
+)
+
+func TestInstallFileMetadata(t *testing.T) {
+	result := prepareForInstallMetadataTest.RunTestWithBp(t, `
+		install_metadata {
+			name: "foo",
+			executable: true,
+			gid: 2000,
+			capabilities: 4096,
+			selabel: "u:object_r:foo_exec:s0",
+		}
+
+		install_metadata {
+			name: "bar",
+			mode: 416, // 0640
+			uid: 1000,
+			vendor: true,
+		}
+
+		install_metadata {
+			name: "baz",
+			selabel: "u:object_r:baz_exec:s0",
+		}
+
+		// Installing the same file with the same metadata is not a conflict.
+		install_metadata {
+			name: "baz2",
+			stem: "baz",
+			selabel: "u:object_r:baz_exec:s0",
+		}
+	`)
+
+	spec := result.ModuleForTests("foo", "android_arm64_armv8-a").Module().base().PackagingSpecs()[0]
+	AssertStringEquals(t, "packaging spec selabel", "u:object_r:foo_exec:s0", spec.Metadata().Selabel)
+
+	singleton := result.SingletonForTests("install_file_metadata")
+	content := func(partition, file string) string {
+		return ContentFromFileRuleForTests(t, result.TestContext,
+			singleton.Output("install_file_metadata/"+partition+"/"+file))
+	}
+	AssertStringEquals(t, "system fs_config",
+		"system/bin/foo 0 2000 0755 capabilities=0x1000\n",
+		content("system", "fs_config"))
+	AssertStringEquals(t, "system file_contexts",
+		"/system/bin/baz u:object_r:baz_exec:s0\n/system/bin/foo u:object_r:foo_exec:s0\n",
+		content("system", "file_contexts"))
+	AssertStringEquals(t, "vendor fs_config",
+		"vendor/bin/bar 1000 0 0640 capabilities=0x0\n",
+		content("vendor", "fs_config"))
+	AssertStringEquals(t, "vendor file_contexts", "", content("vendor", "file_contexts"))
+}
+
+func TestInstallFileMetadataConflict(t *testing.T) {
+	prepareForInstallMetadataTest.
+		ExtendWithErrorHandler(FixtureExpectsOneErrorPattern(
+			`conflicting install metadata for system/bin/foo: `+
+				`"system/bin/foo 0 0 0644 capabilities=0x0 u:object_r:(bar|foo)_exec:s0" by (bar|foo) and `+
+				`"system/bin/foo 0 0 0644 capabilities=0x0 u:object_r:(bar|foo)_exec:s0" by (bar|foo)`)).
+		RunTestWithBp(t, `
+			install_metadata {
+				name: "foo",
+				selabel: "u:object_r:foo_exec:s0",
+			}
+
+			install_metadata {
+				name: "bar",
+				stem: "foo",
+				selabel: "u:object_r:bar_exec:s0",
+			}
+		`)
+}
@@ -0,0 +1,153 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// The InstallPathOverrides product variable relocates whole directories of files installed to
+// device partitions, e.g. all the files installed in etc/permissions, without changing the modules
+// that install them.  The override is applied when an InstallPath is constructed, so the relocated
+// path is used for the install rules, the PackagingSpecs and the Make output alike.
+
+func init() {
+	RegisterInstallPathOverrideBuildComponents(InitRegistrationContext)
+}
+
+func RegisterInstallPathOverrideBuildComponents(ctx RegistrationContext) {
+	ctx.RegisterParallelSingletonType("install_path_overrides", installPathOverridesSingletonFactory)
+}
+
+var PrepareForTestWithInstallPathOverrides = FixtureRegisterWithContext(RegisterInstallPathOverrideBuildComponents)
+
+type installPathOverride struct {
+	from string
+	to   string
+}
+
+// validateInstallPathOverrides checks that every entry of the InstallPathOverrides product variable
+// maps one clean relative directory to another, and that the entries are unambiguous, i.e. no path
+// is matched by more than one entry and no relocated path is matched again.
+func validateInstallPathOverrides(overrides map[string]string) error {
+	for _, from := range SortedKeys(overrides) {
+		to := overrides[from]
+		for _, dir := range []string{from, to} {
+			if dir == "" || filepath.IsAbs(dir) || filepath.Clean(dir) != dir || dir == "." ||
+				dir == ".." || strings.HasPrefix(dir, "../") {
+				return fmt.Errorf("InstallPathOverrides: %q -> %q: %q must be a clean relative path within the partition",
+					from, to, dir)
+			}
+		}
+		for other := range overrides {
+			if other != from && isPathWithin(from, other) {
+				return fmt.Errorf("InstallPathOverrides: %q is within %q, which is also overridden", from, other)
+			}
+			if isPathWithin(to, other) {
+				return fmt.Errorf("InstallPathOverrides: %q -> %q: %q is within %q, which is also overridden",
+					from, to, to, other)
+			}
+		}
+	}
+	return nil
+}
+
+// isPathWithin returns true if path is dir or is inside dir.
+func isPathWithin(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, dir+"/")
+}
+
+var installPathOverridesKey = NewOnceKey("installPathOverrides")
+
+// installPathOverrides returns the entries of the InstallPathOverrides product variable sorted by
+// the directory that they relocate.
+func (c Config) installPathOverrides() []installPathOverride {
+	return c.Once(installPathOverridesKey, func() interface{} {
+		var overrides []installPathOverride
+		for _, from := range SortedKeys(c.productVariables.InstallPathOverrides) {
+			overrides = append(overrides, installPathOverride{from, c.productVariables.InstallPathOverrides[from]})
+		}
+		return overrides
+	}).([]installPathOverride)
+}
+
+var relocatedInstallPathsKey = NewOnceKey("relocatedInstallPaths")
+
+// relocatedInstallPaths returns the map from the install paths that were relocated by
+// InstallPathOverrides to the paths they were relocated to.
+func relocatedInstallPaths(config Config) *sync.Map {
+	return config.Once(relocatedInstallPathsKey, func() interface{} {
+		return &sync.Map{}
+	}).(*sync.Map)
+}
+
+// withInstallPathOverride returns the path relocated according to the InstallPathOverrides product
+// variable if it is installed to a device partition, or the unchanged path otherwise.
+func (p InstallPath) withInstallPathOverride(ctx PathContext) InstallPath {
+	overrides := ctx.Config().installPathOverrides()
+	if len(overrides) == 0 || !strings.HasPrefix(p.partitionDir, "target/product/") {
+		return p
+	}
+
+	relInPartition, ok := strings.CutPrefix(p.path, p.partitionDir+"/")
+	if !ok {
+		return p
+	}
+	for _, override := range overrides {
+		if !isPathWithin(relInPartition, override.from) {
+			continue
+		}
+		relocated := p
+		relocatedRel := override.to + strings.TrimPrefix(relInPartition, override.from)
+		relocated.basePath = basePath{filepath.Join(p.partitionDir, relocatedRel), p.rel}
+		if !strings.HasSuffix(relocated.path, "/"+p.rel) {
+			// The relocated directory overlaps the last joined components.
+			relocated.rel = relocatedRel
+		}
+		relocated.fullPath = filepath.Join(strings.TrimSuffix(p.fullPath, relInPartition), relocatedRel)
+		relocatedInstallPaths(ctx.Config()).Store(p.String(), relocated.String())
+		return relocated
+	}
+	return p
+}
+
+func installPathOverridesSingletonFactory() Singleton {
+	return &installPathOverridesSingleton{}
+}
+
+// installPathOverridesSingleton writes a file listing every install path that was relocated by the
+// InstallPathOverrides product variable.
+type installPathOverridesSingleton struct{}
+
+func (s *installPathOverridesSingleton) GenerateBuildActions(ctx SingletonContext) {
+	if len(ctx.Config().installPathOverrides()) == 0 {
+		return
+	}
+
+	var lines []string
+	relocatedInstallPaths(ctx.Config()).Range(func(from, to any) bool {
+		lines = append(lines, from.(string)+" -> "+to.(string))
+		return true
+	})
+	sort.Strings(lines)
+
+	output := PathForOutput(ctx, "install_path_overrides.txt")
+	WriteFileRule(ctx, output, strings.Join(lines, "\n"))
+	ctx.Phony("install_path_overrides", output)
+}
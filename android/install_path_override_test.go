@@ -0,0 +1,157 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"testing"
+)
+
+func TestValidateInstallPathOverrides(t *testing.T) {
+	testCases := []struct {
+		name      string
+		overrides map[string]string
+		err       string
+	}{
+		{
+			name: "valid",
+			overrides: map[string]string{
+				"etc/permissions": "etc/sysconfig_alt",
+				"lib/firmware":    "vendor_firmware",
+			},
+		},
+		{
+			name:      "empty",
+			overrides: map[string]string{"": "etc"},
+			err:       `InstallPathOverrides: "" -> "etc": "" must be a clean relative path within the partition`,
+		},
+		{
+			name:      "absolute",
+			overrides: map[string]string{"etc": "/etc"},
+			err:       `InstallPathOverrides: "etc" -> "/etc": "/etc" must be a clean relative path within the partition`,
+		},
+		{
+			name:      "unclean",
+			overrides: map[string]string{"etc/": "etc_alt"},
+			err:       `InstallPathOverrides: "etc/" -> "etc_alt": "etc/" must be a clean relative path within the partition`,
+		},
+		{
+			name:      "outside partition",
+			overrides: map[string]string{"etc": "../etc"},
+			err:       `InstallPathOverrides: "etc" -> "../etc": "../etc" must be a clean relative path within the partition`,
+		},
+		{
+			name: "nested",
+			overrides: map[string]string{
+				"etc":             "etc_alt",
+				"etc/permissions": "permissions",
+			},
+			err: `InstallPathOverrides: "etc/permissions" is within "etc", which is also overridden`,
+		},
+		{
+			name: "relocated into overridden",
+			overrides: map[string]string{
+				"etc/permissions": "lib/permissions",
+				"lib":             "lib_alt",
+			},
+			err: `InstallPathOverrides: "etc/permissions" -> "lib/permissions": "lib/permissions" is within "lib", which is also overridden`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateInstallPathOverrides(tc.overrides)
+			if tc.err == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				return
+			}
+			AssertErrorMessageEquals(t, "error", tc.err, err)
+		})
+	}
+}
+
+type installPathOverrideTestModule struct {
+	ModuleBase
+}
+
+func installPathOverrideTestModuleFactory() Module {
+	module := &installPathOverrideTestModule{}
+	InitAndroidArchModule(module, DeviceSupported, MultilibCommon)
+	return module
+}
+
+func (m *installPathOverrideTestModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+	for _, dir := range []string{"permissions", "other"} {
+		src := PathForModuleOut(ctx, dir, ctx.ModuleName()+".xml")
+		WriteFileRule(ctx, src, "")
+		ctx.InstallFile(PathForModuleInstall(ctx, "etc", dir), src.Base(), src)
+	}
+}
+
+func (m *installPathOverrideTestModule) AndroidMkEntries() []AndroidMkEntries {
+	return []AndroidMkEntries{{Class: "ETC"}}
+}
+
+func TestInstallPathOverride(t *testing.T) {
+	bp := `
+		install_path_override_module {
+			name: "foo",
+		}
+	`
+
+	result := GroupFixturePreparers(
+		PrepareForTestWithArchMutator,
+		PrepareForTestWithInstallPathOverrides,
+		FixtureRegisterWithContext(func(ctx RegistrationContext) {
+			ctx.RegisterModuleType("install_path_override_module", installPathOverrideTestModuleFactory)
+		}),
+		FixtureModifyProductVariables(func(variables FixtureProductVariables) {
+			variables.InstallPathOverrides = map[string]string{
+				"etc/permissions": "etc/sysconfig_alt",
+			}
+		}),
+		FixtureModifyConfig(func(config Config) {
+			config.katiEnabled = true
+		}),
+	).RunTestWithBp(t, bp)
+
+	module := result.ModuleForTests("foo", "android_common").Module()
+
+	AssertStringPathsRelativeToTopEquals(t, "installed files", result.Config, []string{
+		"out/target/product/test_device/system/etc/sysconfig_alt/foo.xml",
+		"out/target/product/test_device/system/etc/other/foo.xml",
+	}, module.base().FilesToInstall().Strings())
+
+	var relPaths []string
+	for _, spec := range module.base().PackagingSpecs() {
+		relPaths = append(relPaths, spec.RelPathInPackage())
+	}
+	AssertDeepEquals(t, "packaging specs", []string{
+		"etc/sysconfig_alt/foo.xml",
+		"etc/other/foo.xml",
+	}, relPaths)
+
+	entries := AndroidMkEntriesForTest(t, result.TestContext, module)[0]
+	AssertStringPathsRelativeToTopEquals(t, "LOCAL_SOONG_INSTALLED_MODULE", result.Config,
+		[]string{"out/target/product/test_device/system/etc/other/foo.xml"},
+		entries.EntryMap["LOCAL_SOONG_INSTALLED_MODULE"])
+	AssertStringDoesContain(t, "LOCAL_SOONG_INSTALL_PAIRS",
+		entries.EntryMap["LOCAL_SOONG_INSTALL_PAIRS"][0], "system/etc/sysconfig_alt/foo.xml")
+
+	overrides := result.SingletonForTests("install_path_overrides").Output("install_path_overrides.txt")
+	AssertStringDoesContain(t, "install_path_overrides.txt", ContentFromFileRuleForTests(t, result.TestContext, overrides),
+		"system/etc/permissions -> ")
+}
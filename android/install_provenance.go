@@ -0,0 +1,171 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/google/blueprint"
+	"github.com/google/blueprint/pathtools"
+)
+
+// When SOONG_INSTALL_PROVENANCE is true, out/soong/install_provenance.json explains why files are
+// installed: it maps each installed path, relative to the out directory, to the shortest chain of
+// install dependencies from the modules that pull it in to the module that installs it.  To bound
+// memory, chains are only recorded for the paths that match the glob in
+// SOONG_INSTALL_PROVENANCE_FILTER if it is set, e.g. target/product/*/system/bin/**.
+
+func init() {
+	RegisterInstallProvenanceBuildComponents(InitRegistrationContext)
+}
+
+func RegisterInstallProvenanceBuildComponents(ctx RegistrationContext) {
+	ctx.RegisterParallelSingletonType("install_provenance", installProvenanceSingletonFactory)
+}
+
+// InstallProvenanceStep is a module in the chain of install dependencies that caused a file to be
+// installed.
+type InstallProvenanceStep struct {
+	Module string
+
+	// The type of the tag of the dependency on the module from the previous module in the chain,
+	// empty for the first module.
+	Tag string `json:",omitempty"`
+}
+
+// installProvenanceFilter returns the glob that the recorded paths must match, and whether
+// install provenance is recorded at all.
+func installProvenanceFilter(config Config) (string, bool) {
+	if !config.IsEnvTrue("SOONG_INSTALL_PROVENANCE") {
+		return "", false
+	}
+	return config.Getenv("SOONG_INSTALL_PROVENANCE_FILTER"), true
+}
+
+func installProvenanceMatches(filter string, path InstallPath) bool {
+	if filter == "" {
+		return true
+	}
+	match, err := pathtools.Match(filter, path.path)
+	return err == nil && match
+}
+
+// addOwnInstallProvenance records the files installed by the module itself, which always have the
+// shortest chain.
+func (m *ModuleBase) addOwnInstallProvenance(ctx ModuleContext) {
+	filter, enabled := installProvenanceFilter(ctx.Config())
+	if !enabled {
+		return
+	}
+	for _, path := range m.installFiles {
+		if installProvenanceMatches(filter, path) {
+			if m.installProvenance == nil {
+				m.installProvenance = make(map[string][]InstallProvenanceStep)
+			}
+			m.installProvenance[path.path] = []InstallProvenanceStep{{Module: ctx.ModuleName()}}
+		}
+	}
+}
+
+// addInstallProvenanceFromDep extends the chains recorded by a dependency whose installed files
+// are installed with the module, keeping the shortest chain for each path.
+func (m *ModuleBase) addInstallProvenanceFromDep(ctx ModuleContext, dep Module, tag blueprint.DependencyTag) {
+	if _, enabled := installProvenanceFilter(ctx.Config()); !enabled {
+		return
+	}
+	for _, path := range SortedKeys(dep.base().installProvenance) {
+		depChain := dep.base().installProvenance[path]
+		if existing, ok := m.installProvenance[path]; ok && len(existing) <= len(depChain)+1 {
+			continue
+		}
+		chain := make([]InstallProvenanceStep, 0, len(depChain)+1)
+		chain = append(chain, InstallProvenanceStep{Module: ctx.ModuleName()})
+		chain = append(chain, InstallProvenanceStep{Module: depChain[0].Module, Tag: reflect.TypeOf(tag).String()})
+		chain = append(chain, depChain[1:]...)
+		if m.installProvenance == nil {
+			m.installProvenance = make(map[string][]InstallProvenanceStep)
+		}
+		m.installProvenance[path] = chain
+	}
+}
+
+func installProvenanceChainString(chain []InstallProvenanceStep) string {
+	var modules []string
+	for _, step := range chain {
+		modules = append(modules, step.Module+" "+step.Tag)
+	}
+	return strings.Join(modules, ",")
+}
+
+func installProvenanceSingletonFactory() Singleton {
+	return &installProvenanceSingleton{}
+}
+
+// installProvenanceSingleton writes out/soong/install_provenance.json.
+type installProvenanceSingleton struct{}
+
+func (s *installProvenanceSingleton) GenerateBuildActions(ctx SingletonContext) {
+	filter, enabled := installProvenanceFilter(ctx.Config())
+	if !enabled {
+		return
+	}
+	if _, err := pathtools.Match(filter, ""); filter != "" && err != nil {
+		ctx.Errorf("invalid SOONG_INSTALL_PROVENANCE_FILTER %q: %s", filter, err)
+		return
+	}
+
+	chains := make(map[string]map[string][]InstallProvenanceStep)
+	ctx.VisitAllModules(func(module Module) {
+		for path, chain := range module.base().installProvenance {
+			if chains[path] == nil {
+				chains[path] = make(map[string][]InstallProvenanceStep)
+			}
+			chains[path][installProvenanceChainString(chain)] = chain
+		}
+	})
+
+	// Only keep the chains from modules that don't appear further down the chain of another module
+	// for the same path, i.e. the modules that pulled the path in.
+	provenance := make(map[string][][]InstallProvenanceStep)
+	for path, pathChains := range chains {
+		pulledIn := make(map[string]bool)
+		for _, chain := range pathChains {
+			for _, step := range chain[1:] {
+				pulledIn[step.Module] = true
+			}
+		}
+		var roots [][]InstallProvenanceStep
+		for _, key := range SortedKeys(pathChains) {
+			if chain := pathChains[key]; !pulledIn[chain[0].Module] {
+				roots = append(roots, chain)
+			}
+		}
+		sort.SliceStable(roots, func(i, j int) bool {
+			return len(roots[i]) < len(roots[j])
+		})
+		provenance[path] = roots
+	}
+
+	// Maps are marshalled with sorted keys, so the output is deterministic.
+	data, err := json.MarshalIndent(provenance, "", "  ")
+	if err != nil {
+		ctx.Errorf("failed to marshal install provenance: %s", err)
+		return
+	}
+	WriteFileRule(ctx, PathForOutput(ctx, "install_provenance.json"), string(data))
+}
@@ -0,0 +1,93 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestInstallProvenance(t *testing.T) {
+	bp := `
+		deps {
+			name: "foo",
+			deps: ["bar"],
+		}
+
+		deps {
+			name: "bar",
+			deps: ["baz"],
+		}
+
+		deps {
+			name: "baz",
+		}
+
+		deps {
+			name: "qux",
+			deps: ["baz"],
+		}
+	`
+
+	prepare := GroupFixturePreparers(
+		prepareForModuleTests,
+		PrepareForTestWithArchMutator,
+		FixtureRegisterWithContext(RegisterInstallProvenanceBuildComponents),
+	)
+
+	t.Run("disabled", func(t *testing.T) {
+		result := prepare.RunTestWithBp(t, bp)
+		rule := result.SingletonForTests("install_provenance").MaybeOutput("install_provenance.json")
+		AssertBoolEquals(t, "install_provenance.json written", false, rule.Rule != nil)
+	})
+
+	t.Run("filtered", func(t *testing.T) {
+		result := GroupFixturePreparers(
+			prepare,
+			FixtureMergeEnv(map[string]string{
+				"SOONG_INSTALL_PROVENANCE":        "true",
+				"SOONG_INSTALL_PROVENANCE_FILTER": "target/product/*/system/baz",
+			}),
+		).RunTestWithBp(t, bp)
+
+		rule := result.SingletonForTests("install_provenance").Output("install_provenance.json")
+		var provenance map[string][][]InstallProvenanceStep
+		err := json.Unmarshal([]byte(ContentFromFileRuleForTests(t, result.TestContext, rule)), &provenance)
+		FailIfErrored(t, []error{err})
+
+		AssertDeepEquals(t, "install provenance", map[string][][]InstallProvenanceStep{
+			"target/product/test_device/system/baz": {
+				{
+					{Module: "qux"},
+					{Module: "baz", Tag: "android.installDepTag"},
+				},
+				{
+					{Module: "foo"},
+					{Module: "bar", Tag: "android.installDepTag"},
+					{Module: "baz", Tag: "android.installDepTag"},
+				},
+			},
+		}, provenance)
+
+		// Only the paths matching the filter are recorded.
+		bar := result.ModuleForTests("bar", "android_common").Module().base()
+		AssertDeepEquals(t, "bar install provenance", map[string][]InstallProvenanceStep{
+			"target/product/test_device/system/baz": {
+				{Module: "bar"},
+				{Module: "baz", Tag: "android.installDepTag"},
+			},
+		}, bar.installProvenance)
+	})
+}
@@ -0,0 +1,162 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The kernel_version_constraints property lets modules that only work with some kernel versions be
+// dropped from the build of the boards whose BOARD_KERNEL_VERSION doesn't satisfy them.
+
+func init() {
+	RegisterKernelVersionConstraintsBuildComponents(InitRegistrationContext)
+}
+
+// PrepareForTestWithKernelVersionConstraints registers the mutator that applies the
+// kernel_version_constraints property and the singleton that lists the modules it disabled.
+var PrepareForTestWithKernelVersionConstraints = GroupFixturePreparers(
+	FixtureRegisterWithContext(func(ctx RegistrationContext) {
+		ctx.PreArchMutators(RegisterKernelVersionConstraintsMutator)
+	}),
+	FixtureRegisterWithContext(RegisterKernelVersionConstraintsBuildComponents),
+)
+
+func RegisterKernelVersionConstraintsBuildComponents(ctx RegistrationContext) {
+	ctx.RegisterParallelSingletonType("kernel_version_constraints", kernelVersionSkippedModulesSingletonFactory)
+}
+
+// Registers the mutator that disables the modules whose kernel_version_constraints are not
+// satisfied by the board kernel version.
+//
+// This goes after defaults expansion so that the constraints can be supplied by a defaults module.
+func RegisterKernelVersionConstraintsMutator(ctx RegisterMutatorsContext) {
+	ctx.BottomUp("kernelVersionConstraints", kernelVersionConstraintsMutator).Parallel()
+}
+
+// kernelVersion is the list of numeric components of a kernel version, e.g. [6 1 43] for
+// "6.1.43-android14".
+type kernelVersion []int
+
+// parseKernelVersion parses a kernel version of the form <major>.<minor>[.<patch>][-<suffix>].
+func parseKernelVersion(s string) (kernelVersion, error) {
+	numbers, _, _ := strings.Cut(s, "-")
+	components := strings.Split(numbers, ".")
+	if len(components) < 2 || len(components) > 3 {
+		return nil, fmt.Errorf("invalid kernel version %q, expected <major>.<minor>[.<patch>][-<suffix>]", s)
+	}
+
+	version := make(kernelVersion, 0, len(components))
+	for _, component := range components {
+		n, err := strconv.Atoi(component)
+		if err != nil || strings.HasPrefix(component, "+") {
+			return nil, fmt.Errorf("invalid kernel version %q, expected <major>.<minor>[.<patch>][-<suffix>]", s)
+		}
+		version = append(version, n)
+	}
+	return version, nil
+}
+
+// compareKernelVersions compares the components of the version that are specified by the
+// constraint, treating missing components of the version as 0.  It returns a negative number if
+// the version is below the constraint, 0 if it matches, and a positive number if it is above.
+func compareKernelVersions(version, constraint kernelVersion) int {
+	for i, c := range constraint {
+		v := 0
+		if i < len(version) {
+			v = version[i]
+		}
+		if v != c {
+			return v - c
+		}
+	}
+	return 0
+}
+
+// parseKernelVersionConstraint parses one of the kernel_version_constraints, reporting a property
+// error if it is invalid.
+func parseKernelVersionConstraint(ctx BaseModuleContext, property string, constraint *string) kernelVersion {
+	if constraint == nil {
+		return nil
+	}
+	version, err := parseKernelVersion(*constraint)
+	if err != nil {
+		ctx.PropertyErrorf(property, "%s", err)
+		return nil
+	}
+	return version
+}
+
+func kernelVersionConstraintsMutator(ctx BottomUpMutatorContext) {
+	m := ctx.Module().base()
+	constraints := m.commonProperties.Kernel_version_constraints
+	if constraints.Min == nil && constraints.Max == nil {
+		return
+	}
+
+	minVersion := parseKernelVersionConstraint(ctx, "kernel_version_constraints.min", constraints.Min)
+	maxVersion := parseKernelVersionConstraint(ctx, "kernel_version_constraints.max", constraints.Max)
+	if ctx.Failed() {
+		return
+	}
+	if minVersion != nil && maxVersion != nil && compareKernelVersions(minVersion, maxVersion) > 0 {
+		ctx.PropertyErrorf("kernel_version_constraints", "min %q is above max %q", *constraints.Min, *constraints.Max)
+		return
+	}
+
+	board := ctx.DeviceConfig().BoardKernelVersion()
+	if board == "" {
+		ctx.PropertyWarningf("kernel_version_constraints", "ignored as BOARD_KERNEL_VERSION is not set")
+		return
+	}
+	// The board kernel version is validated when the config is created.
+	boardVersion, _ := parseKernelVersion(board)
+
+	var reason string
+	if minVersion != nil && compareKernelVersions(boardVersion, minVersion) < 0 {
+		reason = fmt.Sprintf("board kernel version %s is below the minimum %s", board, *constraints.Min)
+	} else if maxVersion != nil && compareKernelVersions(boardVersion, maxVersion) > 0 {
+		reason = fmt.Sprintf("board kernel version %s is above the maximum %s", board, *constraints.Max)
+	}
+	if reason != "" {
+		m.Disable()
+		m.commonProperties.KernelVersionDisabledReason = reason
+	}
+}
+
+func kernelVersionSkippedModulesSingletonFactory() Singleton {
+	return &kernelVersionSkippedModulesSingleton{}
+}
+
+// kernelVersionSkippedModulesSingleton writes out/soong/kernel_version_skipped_modules.txt, which
+// lists the modules that were disabled by their kernel_version_constraints and why.
+type kernelVersionSkippedModulesSingleton struct{}
+
+func (s *kernelVersionSkippedModulesSingleton) GenerateBuildActions(ctx SingletonContext) {
+	skipped := make(map[string]string)
+	ctx.VisitAllModules(func(module Module) {
+		if reason := module.base().commonProperties.KernelVersionDisabledReason; reason != "" {
+			skipped[ctx.ModuleName(module)] = reason
+		}
+	})
+
+	var lines []string
+	for _, name := range SortedKeys(skipped) {
+		lines = append(lines, name+": "+skipped[name])
+	}
+	WriteFileRule(ctx, PathForOutput(ctx, "kernel_version_skipped_modules.txt"), strings.Join(lines, "\n"))
+}
@@ -0,0 +1,194 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/google/blueprint/proptools"
+)
+
+func TestParseKernelVersion(t *testing.T) {
+	testCases := []struct {
+		version  string
+		expected kernelVersion
+		err      bool
+	}{
+		{version: "5.15", expected: kernelVersion{5, 15}},
+		{version: "6.1.43", expected: kernelVersion{6, 1, 43}},
+		{version: "6.1.43-android14", expected: kernelVersion{6, 1, 43}},
+		{version: "6.6-android15-8", expected: kernelVersion{6, 6}},
+		{version: "6", err: true},
+		{version: "6.1.2.3", err: true},
+		{version: "6.x", err: true},
+		{version: "6.+1", err: true},
+		{version: "", err: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.version, func(t *testing.T) {
+			version, err := parseKernelVersion(tc.version)
+			if tc.err {
+				if err == nil {
+					t.Errorf("expected an error, got %v", version)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			AssertDeepEquals(t, "version", tc.expected, version)
+		})
+	}
+}
+
+type kernelVersionTestModule struct {
+	ModuleBase
+}
+
+func kernelVersionTestModuleFactory() Module {
+	module := &kernelVersionTestModule{}
+	InitAndroidModule(module)
+	return module
+}
+
+func (m *kernelVersionTestModule) GenerateAndroidBuildActions(ModuleContext) {}
+
+func TestKernelVersionConstraints(t *testing.T) {
+	bp := `
+		kernel_version_test_module {
+			name: "foo",
+			kernel_version_constraints: {
+				min: "5.15",
+				max: "6.6",
+			},
+		}
+
+		kernel_version_test_module {
+			name: "bar",
+		}
+	`
+
+	testCases := []struct {
+		name          string
+		boardVersion  string
+		enabled       bool
+		skippedReport string
+		warnings      []BuildWarning
+	}{
+		{
+			name:         "in range",
+			boardVersion: "6.1.43-android14",
+			enabled:      true,
+		},
+		{
+			name:         "in range of max",
+			boardVersion: "6.6.30",
+			enabled:      true,
+		},
+		{
+			name:          "below min",
+			boardVersion:  "5.10.200-android13",
+			skippedReport: "foo: board kernel version 5.10.200-android13 is below the minimum 5.15",
+		},
+		{
+			name:          "above max",
+			boardVersion:  "6.12",
+			skippedReport: "foo: board kernel version 6.12 is above the maximum 6.6",
+		},
+		{
+			name:    "missing board version",
+			enabled: true,
+			warnings: []BuildWarning{{
+				File:     "Android.bp",
+				Module:   "foo",
+				Property: "kernel_version_constraints",
+				Message:  "ignored as BOARD_KERNEL_VERSION is not set",
+			}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := GroupFixturePreparers(
+				PrepareForTestWithKernelVersionConstraints,
+				FixtureRegisterWithContext(func(ctx RegistrationContext) {
+					ctx.RegisterModuleType("kernel_version_test_module", kernelVersionTestModuleFactory)
+				}),
+				FixtureModifyProductVariables(func(variables FixtureProductVariables) {
+					if tc.boardVersion != "" {
+						variables.BoardKernelVersion = proptools.StringPtr(tc.boardVersion)
+					}
+				}),
+				FixtureWithRootAndroidBp(bp),
+			).RunTest(t)
+
+			foo := result.ModuleForTests("foo", "").Module()
+			AssertBoolEquals(t, "foo enabled", tc.enabled, foo.Enabled())
+			AssertBoolEquals(t, "bar enabled", true, result.ModuleForTests("bar", "").Module().Enabled())
+
+			skipped := result.SingletonForTests("kernel_version_constraints").Output("kernel_version_skipped_modules.txt")
+			AssertStringEquals(t, "kernel_version_skipped_modules.txt", tc.skippedReport,
+				ContentFromFileRuleForTests(t, result.TestContext, skipped))
+			AssertDeepEquals(t, "warnings", tc.warnings, result.Config.BuildWarnings())
+		})
+	}
+}
+
+func TestKernelVersionConstraintsErrors(t *testing.T) {
+	testCases := []struct {
+		name        string
+		constraints string
+		err         string
+	}{
+		{
+			name:        "malformed min",
+			constraints: `min: "5"`,
+			err:         `kernel_version_constraints.min: invalid kernel version "5", expected <major>.<minor>[.<patch>][-<suffix>]`,
+		},
+		{
+			name:        "malformed max",
+			constraints: `max: "six.six"`,
+			err:         `kernel_version_constraints.max: invalid kernel version "six.six", expected <major>.<minor>[.<patch>][-<suffix>]`,
+		},
+		{
+			name:        "min above max",
+			constraints: `min: "6.6", max: "6.1"`,
+			err:         `kernel_version_constraints: min "6.6" is above max "6.1"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			GroupFixturePreparers(
+				PrepareForTestWithKernelVersionConstraints,
+				FixtureRegisterWithContext(func(ctx RegistrationContext) {
+					ctx.RegisterModuleType("kernel_version_test_module", kernelVersionTestModuleFactory)
+				}),
+				FixtureModifyProductVariables(func(variables FixtureProductVariables) {
+					variables.BoardKernelVersion = proptools.StringPtr("6.1")
+				}),
+			).
+				ExtendWithErrorHandler(FixtureExpectsAtLeastOneErrorMatchingPattern(regexp.QuoteMeta(tc.err))).
+				RunTestWithBp(t, `
+					kernel_version_test_module {
+						name: "foo",
+						kernel_version_constraints: {`+tc.constraints+`},
+					}
+				`)
+		})
+	}
+}
@@ -69,16 +69,20 @@ func (m *licenseModule) DepsMutator(ctx BottomUpMutatorContext) {
 
 func (m *licenseModule) GenerateAndroidBuildActions(ctx ModuleContext) {
 	// license modules have no licenses, but license_kinds must refer to license_kind modules
-	mergeStringProps(&m.base().commonProperties.Effective_licenses, ctx.ModuleName())
-	namePathProps(&m.base().commonProperties.Effective_license_text, m.properties.Package_name, PathsForModuleSrc(ctx, m.properties.License_text)...)
+	licenseInfo := LicenseInfo{
+		PackageName: m.properties.Package_name,
+	}
+	mergeStringProps(&licenseInfo.EffectiveLicenses, ctx.ModuleName())
+	namePathProps(&licenseInfo.LicenseTexts, m.properties.Package_name, PathsForModuleSrc(ctx, m.properties.License_text)...)
 	for _, module := range ctx.GetDirectDepsWithTag(licenseKindTag) {
 		if lk, ok := module.(*licenseKindModule); ok {
-			mergeStringProps(&m.base().commonProperties.Effective_license_conditions, lk.properties.Conditions...)
-			mergeStringProps(&m.base().commonProperties.Effective_license_kinds, ctx.OtherModuleName(module))
+			mergeStringProps(&licenseInfo.LicenseConditions, lk.properties.Conditions...)
+			mergeStringProps(&licenseInfo.LicenseKinds, ctx.OtherModuleName(module))
 		} else {
 			ctx.ModuleErrorf("license_kinds property %q is not a license_kind module", ctx.OtherModuleName(module))
 		}
 	}
+	setLicenseInfo(ctx, licenseInfo)
 }
 
 func LicenseFactory() Module {
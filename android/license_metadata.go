@@ -62,7 +62,7 @@ func buildLicenseMetadata(ctx ModuleContext, licenseMetadataFile WritablePath) {
 	var allDepMetadataFiles Paths
 	var allDepMetadataArgs []string
 	var allDepOutputFiles Paths
-	var allDepMetadataDepSets []*DepSet[Path]
+	var allDepMetadataDepSets []*DepSet[licenseMetadataDep]
 
 	ctx.VisitDirectDepsBlueprint(func(bpdep blueprint.Module) {
 		dep, _ := bpdep.(Module)
@@ -81,19 +81,14 @@ func buildLicenseMetadata(ctx ModuleContext, licenseMetadataFile WritablePath) {
 		if info, ok := OtherModuleProvider(ctx, dep, LicenseMetadataProvider); ok {
 			allDepMetadataFiles = append(allDepMetadataFiles, info.LicenseMetadataPath)
 			if isContainer || isInstallDepNeeded(dep, ctx.OtherModuleDependencyTag(dep)) {
-				allDepMetadataDepSets = append(allDepMetadataDepSets, info.LicenseMetadataDepSet)
+				allDepMetadataDepSets = append(allDepMetadataDepSets, info.licenseMetadataDeps)
 			}
 
 			depAnnotations := licenseAnnotationsFromTag(ctx.OtherModuleDependencyTag(dep))
 
 			allDepMetadataArgs = append(allDepMetadataArgs, info.LicenseMetadataPath.String()+depAnnotations)
 
-			if depInstallFiles := dep.base().installFiles; len(depInstallFiles) > 0 {
-				allDepOutputFiles = append(allDepOutputFiles, depInstallFiles.Paths()...)
-			} else if depOutputFiles, err := outputFilesForModule(ctx, dep, ""); err == nil {
-				depOutputFiles = PathsIfNonNil(depOutputFiles...)
-				allDepOutputFiles = append(allDepOutputFiles, depOutputFiles...)
-			}
+			allDepOutputFiles = append(allDepOutputFiles, info.sourceFiles...)
 		}
 	})
 
@@ -118,25 +113,36 @@ func buildLicenseMetadata(ctx ModuleContext, licenseMetadataFile WritablePath) {
 		"-r "+proptools.NinjaAndShellEscape(ctx.ModuleDir()),
 		"-mc UNKNOWN")
 
-	if p := base.commonProperties.Effective_package_name; p != nil {
+	licenseInfo, _ := ModuleProvider(ctx, LicenseInfoProvider)
+	if p := licenseInfo.PackageName; p != nil {
 		args = append(args,
 			`-p `+proptools.NinjaAndShellEscapeIncludingSpaces(*p))
 	}
 
 	args = append(args,
-		JoinWithPrefix(proptools.NinjaAndShellEscapeListIncludingSpaces(base.commonProperties.Effective_license_kinds), "-k "))
+		JoinWithPrefix(proptools.NinjaAndShellEscapeListIncludingSpaces(licenseInfo.LicenseKinds), "-k "))
 
 	args = append(args,
-		JoinWithPrefix(proptools.NinjaAndShellEscapeListIncludingSpaces(base.commonProperties.Effective_license_conditions), "-c "))
+		JoinWithPrefix(proptools.NinjaAndShellEscapeListIncludingSpaces(licenseInfo.LicenseConditions), "-c "))
 
 	args = append(args,
-		JoinWithPrefix(proptools.NinjaAndShellEscapeListIncludingSpaces(base.commonProperties.Effective_license_text.Strings()), "-n "))
+		JoinWithPrefix(proptools.NinjaAndShellEscapeListIncludingSpaces(licenseInfo.LicenseTexts.Strings()), "-n "))
+
+	licenseMetadataDeps := NewDepSet(TOPOLOGICAL, []licenseMetadataDep{{
+		path:    licenseMetadataFile,
+		escaped: proptools.NinjaAndShellEscapeIncludingSpaces(licenseMetadataFile.String()),
+	}}, allDepMetadataDepSets)
 
 	if isContainer {
-		transitiveDeps := Paths(NewDepSet[Path](TOPOLOGICAL, nil, allDepMetadataDepSets).ToList())
-		args = append(args,
-			JoinWithPrefix(proptools.NinjaAndShellEscapeListIncludingSpaces(transitiveDeps.Strings()), "-d "))
-		orderOnlyDeps = append(orderOnlyDeps, transitiveDeps...)
+		// The module's own license metadata file is always first in the topological order, followed
+		// by the transitive dependencies.
+		transitiveDeps := licenseMetadataDeps.ToList()[1:]
+		escapedTransitiveDeps := make([]string, 0, len(transitiveDeps))
+		for _, dep := range transitiveDeps {
+			escapedTransitiveDeps = append(escapedTransitiveDeps, dep.escaped)
+			orderOnlyDeps = append(orderOnlyDeps, dep.path)
+		}
+		args = append(args, JoinWithPrefix(escapedTransitiveDeps, "-d "))
 	} else {
 		args = append(args,
 			JoinWithPrefix(proptools.NinjaAndShellEscapeListIncludingSpaces(allDepMetadataArgs), "-d "))
@@ -174,9 +180,18 @@ func buildLicenseMetadata(ctx ModuleContext, licenseMetadataFile WritablePath) {
 		},
 	})
 
+	// The files that the license metadata of dependent modules lists as the sources of this module.
+	var sourceFiles Paths
+	if len(base.installFiles) > 0 {
+		sourceFiles = base.installFiles.Paths()
+	} else if files, err := outputFilesForModule(ctx, ctx.Module(), ""); err == nil {
+		sourceFiles = PathsIfNonNil(files...)
+	}
+
 	SetProvider(ctx, LicenseMetadataProvider, &LicenseMetadataInfo{
-		LicenseMetadataPath:   licenseMetadataFile,
-		LicenseMetadataDepSet: NewDepSet(TOPOLOGICAL, Paths{licenseMetadataFile}, allDepMetadataDepSets),
+		LicenseMetadataPath: licenseMetadataFile,
+		licenseMetadataDeps: licenseMetadataDeps,
+		sourceFiles:         sourceFiles,
 	})
 }
 
@@ -203,8 +218,23 @@ var LicenseMetadataProvider = blueprint.NewProvider[*LicenseMetadataInfo]()
 
 // LicenseMetadataInfo stores the license metadata path for a module.
 type LicenseMetadataInfo struct {
-	LicenseMetadataPath   Path
-	LicenseMetadataDepSet *DepSet[Path]
+	LicenseMetadataPath Path
+
+	// The license metadata files of the module and of its transitive dependencies that are
+	// installed with it, computed once per module so that containers don't need to escape the paths
+	// of all their transitive dependencies again.
+	licenseMetadataDeps *DepSet[licenseMetadataDep]
+
+	// The files that modules depending on this one list as sources in their license metadata, i.e.
+	// the installed files of this module or its output files if it doesn't install any.
+	sourceFiles Paths
+}
+
+// licenseMetadataDep is a license metadata file along with its path escaped for the
+// build_license_metadata command line.
+type licenseMetadataDep struct {
+	path    Path
+	escaped string
 }
 
 // licenseAnnotationsFromTag returns the LicenseAnnotations for a tag (if any) converted into
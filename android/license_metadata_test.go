@@ -0,0 +1,161 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/blueprint/proptools"
+)
+
+type licenseMetadataTestModule struct {
+	ModuleBase
+	properties struct {
+		Deps []string
+
+		// Whether the module installs a zip file, which makes it a container.
+		Container bool
+	}
+}
+
+func licenseMetadataTestModuleFactory() Module {
+	m := &licenseMetadataTestModule{}
+	m.AddProperties(&m.properties)
+	InitAndroidArchModule(m, DeviceSupported, MultilibCommon)
+	return m
+}
+
+func (m *licenseMetadataTestModule) DepsMutator(ctx BottomUpMutatorContext) {
+	ctx.AddDependency(ctx.Module(), installDepTag{}, m.properties.Deps...)
+}
+
+func (m *licenseMetadataTestModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+	name := ctx.ModuleName() + ".txt"
+	if m.properties.Container {
+		name = ctx.ModuleName() + ".zip"
+	}
+	out := PathForModuleOut(ctx, name)
+	ctx.Build(pctx, BuildParams{
+		Rule:   Touch,
+		Output: out,
+	})
+	ctx.InstallFile(PathForModuleInstall(ctx), name, out)
+}
+
+var prepareForLicenseMetadataTest = GroupFixturePreparers(
+	PrepareForTestWithArchMutator,
+	FixtureRegisterWithContext(func(ctx RegistrationContext) {
+		ctx.RegisterModuleType("license_metadata_test_module", licenseMetadataTestModuleFactory)
+	}),
+)
+
+func TestLicenseMetadataDiamond(t *testing.T) {
+	bp := `
+		license_metadata_test_module {
+			name: "top",
+			deps: ["left", "right"],
+		}
+
+		license_metadata_test_module {
+			name: "container",
+			deps: ["left", "right"],
+			container: true,
+		}
+
+		license_metadata_test_module {
+			name: "left",
+			deps: ["bottom"],
+		}
+
+		license_metadata_test_module {
+			name: "right",
+			deps: ["bottom"],
+		}
+
+		license_metadata_test_module {
+			name: "bottom",
+		}
+	`
+
+	result := prepareForLicenseMetadataTest.RunTestWithBp(t, bp)
+
+	metaLic := func(name string) string {
+		return "out/soong/.intermediates/" + name + "/android_common/meta_lic"
+	}
+	installed := func(name string) string {
+		return "out/soong/target/product/test_device/system/" + name
+	}
+
+	// Modules that aren't containers list the license metadata of their direct dependencies, and
+	// the installed files of the dependencies as sources.
+	top := result.ModuleForTests("top", "android_common").Output("meta_lic")
+	AssertStringDoesContain(t, "top deps", top.Args["args"],
+		" -d "+metaLic("left")+" -d "+metaLic("right")+" -s "+installed("left.txt")+" -s "+installed("right.txt")+" ")
+	AssertStringDoesContain(t, "top installed files", top.Args["args"], " -i "+installed("top.txt"))
+	AssertStringDoesNotContain(t, "top deps", top.Args["args"], metaLic("bottom"))
+	AssertPathsRelativeToTopEquals(t, "top order only deps",
+		[]string{metaLic("left"), metaLic("right")}, top.OrderOnly)
+
+	// Containers list the license metadata of all their transitive dependencies in topological
+	// order, with the bottom of the diamond only listed once.
+	container := result.ModuleForTests("container", "android_common").Output("meta_lic")
+	AssertStringDoesContain(t, "container deps", container.Args["args"],
+		" -d "+metaLic("left")+" -d "+metaLic("right")+" -d "+metaLic("bottom")+" -s "+installed("left.txt")+" -s "+installed("right.txt")+" ")
+	AssertStringDoesContain(t, "container installed files", container.Args["args"], " -i "+installed("container.zip")+" --is_container")
+	AssertPathsRelativeToTopEquals(t, "container order only deps",
+		[]string{metaLic("left"), metaLic("right"), metaLic("bottom")}, container.OrderOnly)
+}
+
+// BenchmarkLicenseMetadataTransitiveDeps compares escaping the transitive license metadata files of
+// a container that depends on a chain of diamonds each time they are listed with listing the
+// cached escaped paths.
+func BenchmarkLicenseMetadataTransitiveDeps(b *testing.B) {
+	const depth = 1000
+
+	var pathDepSets []*DepSet[Path]
+	var cachedDepSets []*DepSet[licenseMetadataDep]
+	for i := depth - 1; i >= 0; i-- {
+		var nextPathDepSets []*DepSet[Path]
+		var nextCachedDepSets []*DepSet[licenseMetadataDep]
+		for _, side := range []string{"left", "right"} {
+			path := PathForTesting(fmt.Sprintf("out/soong/.intermediates/%s%d/android_common/meta_lic", side, i))
+			nextPathDepSets = append(nextPathDepSets, NewDepSet(TOPOLOGICAL, Paths{path}, pathDepSets))
+			nextCachedDepSets = append(nextCachedDepSets, NewDepSet(TOPOLOGICAL, []licenseMetadataDep{{
+				path:    path,
+				escaped: proptools.NinjaAndShellEscapeIncludingSpaces(path.String()),
+			}}, cachedDepSets))
+		}
+		pathDepSets, cachedDepSets = nextPathDepSets, nextCachedDepSets
+	}
+
+	b.Run("escape", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			transitiveDeps := Paths(NewDepSet[Path](TOPOLOGICAL, nil, pathDepSets).ToList())
+			_ = JoinWithPrefix(proptools.NinjaAndShellEscapeListIncludingSpaces(transitiveDeps.Strings()), "-d ")
+		}
+	})
+
+	b.Run("cached", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			transitiveDeps := NewDepSet(TOPOLOGICAL, nil, cachedDepSets).ToList()
+			escaped := make([]string, 0, len(transitiveDeps))
+			for _, dep := range transitiveDeps {
+				escaped = append(escaped, dep.escaped)
+			}
+			_ = JoinWithPrefix(escaped, "-d ")
+		}
+	})
+}
@@ -86,14 +86,12 @@ type licenseSdkMemberProperties struct {
 	License_text Paths
 }
 
-func (p *licenseSdkMemberProperties) PopulateFromVariant(_ SdkMemberContext, variant Module) {
+func (p *licenseSdkMemberProperties) PopulateFromVariant(ctx SdkMemberContext, variant Module) {
 	// Populate the properties from the variant.
 	l := variant.(*licenseModule)
 	p.License_kinds = l.properties.License_kinds
-	p.License_text = make(Paths, 0, len(l.base().commonProperties.Effective_license_text))
-	for _, np := range l.base().commonProperties.Effective_license_text {
-		p.License_text = append(p.License_text, np.Path)
-	}
+	licenseInfo, _ := OtherModuleProvider(ctx.SdkModuleContext(), variant, LicenseInfoProvider)
+	p.License_text = licenseInfo.LicenseTexts.Paths()
 }
 
 func (p *licenseSdkMemberProperties) AddToPropertySet(ctx SdkMemberContext, propertySet BpPropertySet) {
@@ -205,17 +205,18 @@ func licensesPropertyFlattener(ctx ModuleContext) {
 		return
 	}
 
-	var licenses []string
+	var licenseInfo LicenseInfo
 	for _, module := range ctx.GetDirectDepsWithTag(licensesTag) {
-		if l, ok := module.(*licenseModule); ok {
-			licenses = append(licenses, ctx.OtherModuleName(module))
-			if m.base().commonProperties.Effective_package_name == nil && l.properties.Package_name != nil {
-				m.base().commonProperties.Effective_package_name = l.properties.Package_name
+		if _, ok := module.(*licenseModule); ok {
+			licenseInfo.Licenses = append(licenseInfo.Licenses, ctx.OtherModuleName(module))
+			l, _ := OtherModuleProvider(ctx, module, LicenseInfoProvider)
+			if licenseInfo.PackageName == nil {
+				licenseInfo.PackageName = l.PackageName
 			}
-			mergeStringProps(&m.base().commonProperties.Effective_licenses, module.base().commonProperties.Effective_licenses...)
-			mergeNamedPathProps(&m.base().commonProperties.Effective_license_text, module.base().commonProperties.Effective_license_text...)
-			mergeStringProps(&m.base().commonProperties.Effective_license_kinds, module.base().commonProperties.Effective_license_kinds...)
-			mergeStringProps(&m.base().commonProperties.Effective_license_conditions, module.base().commonProperties.Effective_license_conditions...)
+			mergeStringProps(&licenseInfo.EffectiveLicenses, l.EffectiveLicenses...)
+			mergeNamedPathProps(&licenseInfo.LicenseTexts, l.LicenseTexts...)
+			mergeStringProps(&licenseInfo.LicenseKinds, l.LicenseKinds...)
+			mergeStringProps(&licenseInfo.LicenseConditions, l.LicenseConditions...)
 		} else {
 			propertyName := "licenses"
 			primaryProperty := m.base().primaryLicensesProperty
@@ -227,9 +228,13 @@ func licensesPropertyFlattener(ctx ModuleContext) {
 	}
 
 	// Make the license information available for other modules.
-	licenseInfo := LicenseInfo{
-		Licenses: licenses,
-	}
+	setLicenseInfo(ctx, licenseInfo)
+}
+
+// setLicenseInfo sets the LicenseInfoProvider of the module, and keeps a copy for
+// EffectiveLicenseKinds and EffectiveLicenseFiles.
+func setLicenseInfo(ctx ModuleContext, licenseInfo LicenseInfo) {
+	ctx.Module().base().licenseInfo = licenseInfo
 	SetProvider(ctx, LicenseInfoProvider, licenseInfo)
 }
 
@@ -298,8 +303,18 @@ func getLicenses(ctx BaseModuleContext, module Module) []string {
 	return c.getLicenses()
 }
 
+// exemptFromLicensesModule is implemented by modules outside of the allowed list in
+// exemptFromRequiredApplicableLicensesProperty that do not have or need applicable licenses either,
+// like the module types of tests.
+type exemptFromLicensesModule interface {
+	exemptFromLicenses() bool
+}
+
 // Returns whether a module is an allowed list of modules that do not have or need applicable licenses.
 func exemptFromRequiredApplicableLicensesProperty(module Module) bool {
+	if m, ok := module.(exemptFromLicensesModule); ok && m.exemptFromLicenses() {
+		return true
+	}
 	switch reflect.TypeOf(module).String() {
 	case "*android.licenseModule": // is a license, doesn't need one
 	case "*android.licenseKindModule": // is a license, doesn't need one
@@ -315,11 +330,28 @@ func exemptFromRequiredApplicableLicensesProperty(module Module) bool {
 	return true
 }
 
-// LicenseInfo contains information about licenses for a specific module.
+// LicenseInfo contains information about licenses for a specific module.  It is flattened from the
+// LicenseInfo of the license modules the module depends upon.
 type LicenseInfo struct {
 	// The list of license modules this depends upon, either explicitly or through default package
 	// configuration.
 	Licenses []string
+
+	// The license modules that apply to the module.  Equal to Licenses unless a particular module
+	// adds more.
+	EffectiveLicenses []string
+
+	// Override of the module name when reporting licenses.
+	PackageName *string
+
+	// The notice files.
+	LicenseTexts NamedPaths
+
+	// The names of the license_kind modules.
+	LicenseKinds []string
+
+	// The license conditions.
+	LicenseConditions []string
 }
 
 var LicenseInfoProvider = blueprint.NewProvider[LicenseInfo]()
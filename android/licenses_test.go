@@ -497,6 +497,55 @@ func TestLicenses(t *testing.T) {
 	}
 }
 
+// TestLicenseInfoProviderMatchesLegacyMethods checks that the LicenseInfo of a module that inherits
+// its licenses from its package agrees with EffectiveLicenseKinds and EffectiveLicenseFiles.
+func TestLicenseInfoProviderMatchesLegacyMethods(t *testing.T) {
+	result := GroupFixturePreparers(
+		prepareForLicenseTest,
+		FixtureRegisterWithContext(func(ctx RegistrationContext) {
+			ctx.RegisterModuleType("mock_library", newMockLicensesLibraryModule)
+		}),
+		MockFS{
+			"top/NOTICE": nil,
+			"top/Android.bp": []byte(`
+				package {
+					default_applicable_licenses: ["top_license"],
+				}
+
+				license_kind {
+					name: "notice",
+					conditions: ["shownotice"],
+				}
+
+				license {
+					name: "top_license",
+					license_kinds: ["notice"],
+					license_text: ["NOTICE"],
+					package_name: "Top",
+				}
+
+				mock_library {
+					name: "libexample",
+				}`),
+		}.AddToFixture(),
+	).RunTest(t)
+
+	module := result.ModuleForTests("libexample", "android_common").Module()
+	licenseInfo, ok := SingletonModuleProvider(result, module, LicenseInfoProvider)
+	AssertBoolEquals(t, "has LicenseInfoProvider", true, ok)
+
+	AssertDeepEquals(t, "Licenses", []string{"top_license"}, licenseInfo.Licenses)
+	AssertDeepEquals(t, "EffectiveLicenses", []string{"top_license"}, licenseInfo.EffectiveLicenses)
+	AssertStringEquals(t, "PackageName", "Top", String(licenseInfo.PackageName))
+	AssertDeepEquals(t, "LicenseKinds", []string{"notice"}, licenseInfo.LicenseKinds)
+	AssertDeepEquals(t, "LicenseConditions", []string{"shownotice"}, licenseInfo.LicenseConditions)
+	AssertDeepEquals(t, "LicenseTexts", []string{"top/NOTICE:Top"}, licenseInfo.LicenseTexts.Strings())
+
+	AssertDeepEquals(t, "EffectiveLicenseKinds", licenseInfo.LicenseKinds, module.EffectiveLicenseKinds())
+	AssertPathsRelativeToTopEquals(t, "EffectiveLicenseFiles", []string{"top/NOTICE"}, module.EffectiveLicenseFiles())
+	AssertPathsRelativeToTopEquals(t, "LicenseTexts paths", []string{"top/NOTICE"}, licenseInfo.LicenseTexts.Paths())
+}
+
 func checkEffectiveLicenses(t *testing.T, result *TestResult, effectiveLicenses map[string][]string) {
 	actualLicenses := make(map[string][]string)
 	result.Context.Context.VisitAllModules(func(m blueprint.Module) {
@@ -518,7 +567,8 @@ func checkEffectiveLicenses(t *testing.T, result *TestResult, effectiveLicenses
 		if base == nil {
 			return
 		}
-		actualLicenses[m.Name()] = base.commonProperties.Effective_licenses
+		licenseInfo, _ := SingletonModuleProvider(result, m, LicenseInfoProvider)
+		actualLicenses[m.Name()] = licenseInfo.EffectiveLicenses
 	})
 
 	for moduleName, expectedLicenses := range effectiveLicenses {
@@ -554,7 +604,8 @@ func checkEffectiveInheritedLicenses(t *testing.T, result *TestResult, effective
 			return
 		}
 		inherited := make(map[string]bool)
-		for _, l := range base.commonProperties.Effective_licenses {
+		licenseInfo, _ := SingletonModuleProvider(result, m, LicenseInfoProvider)
+		for _, l := range licenseInfo.EffectiveLicenses {
 			inherited[l] = true
 		}
 		result.Context.Context.VisitDepsDepthFirst(m, func(c blueprint.Module) {
@@ -576,7 +627,8 @@ func checkEffectiveInheritedLicenses(t *testing.T, result *TestResult, effective
 			if cbase == nil {
 				return
 			}
-			for _, l := range cbase.commonProperties.Effective_licenses {
+			clicenseInfo, _ := SingletonModuleProvider(result, c, LicenseInfoProvider)
+			for _, l := range clicenseInfo.EffectiveLicenses {
 				inherited[l] = true
 			}
 		})
@@ -619,10 +671,11 @@ func checkEffectivePackage(t *testing.T, result *TestResult, effectivePackage ma
 			return
 		}
 
-		if base.commonProperties.Effective_package_name == nil {
+		licenseInfo, _ := SingletonModuleProvider(result, m, LicenseInfoProvider)
+		if licenseInfo.PackageName == nil {
 			actualPackage[m.Name()] = ""
 		} else {
-			actualPackage[m.Name()] = *base.commonProperties.Effective_package_name
+			actualPackage[m.Name()] = *licenseInfo.PackageName
 		}
 	})
 
@@ -658,7 +711,8 @@ func checkEffectiveNotices(t *testing.T, result *TestResult, effectiveNotices ma
 		if base == nil {
 			return
 		}
-		actualNotices[m.Name()] = base.commonProperties.Effective_license_text.Strings()
+		licenseInfo, _ := SingletonModuleProvider(result, m, LicenseInfoProvider)
+		actualNotices[m.Name()] = licenseInfo.LicenseTexts.Strings()
 	})
 
 	for moduleName, expectedNotices := range effectiveNotices {
@@ -693,7 +747,8 @@ func checkEffectiveKinds(t *testing.T, result *TestResult, effectiveKinds map[st
 		if base == nil {
 			return
 		}
-		actualKinds[m.Name()] = base.commonProperties.Effective_license_kinds
+		licenseInfo, _ := SingletonModuleProvider(result, m, LicenseInfoProvider)
+		actualKinds[m.Name()] = licenseInfo.LicenseKinds
 	})
 
 	for moduleName, expectedKinds := range effectiveKinds {
@@ -728,7 +783,8 @@ func checkEffectiveConditions(t *testing.T, result *TestResult, effectiveConditi
 		if base == nil {
 			return
 		}
-		actualConditions[m.Name()] = base.commonProperties.Effective_license_conditions
+		licenseInfo, _ := SingletonModuleProvider(result, m, LicenseInfoProvider)
+		actualConditions[m.Name()] = licenseInfo.LicenseConditions
 	})
 
 	for moduleName, expectedConditions := range effectiveConditions {
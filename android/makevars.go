@@ -184,6 +184,7 @@ func makeVarsSingletonFunc() Singleton {
 type makeVarsSingleton struct {
 	varsForTesting     []makeVarsVariable
 	installsForTesting []byte
+	distsForTesting    []dist
 }
 
 type makeVarsProvider struct {
@@ -352,6 +353,7 @@ func (s *makeVarsSingleton) GenerateBuildActions(ctx SingletonContext) {
 	if ctx.Config().RunningInsideUnitTest() {
 		s.varsForTesting = vars
 		s.installsForTesting = installsBytes
+		s.distsForTesting = dists
 	}
 }
 
@@ -484,6 +486,11 @@ func (s *makeVarsSingleton) writeInstalls(installs, symlinks, katiVintfManifestI
 		// Write a rule for each install request in the form:
 		//  to: from [ deps ] [ | order only deps ]
 		//       cp -f -d $< $@ [ && chmod +x $@ ]
+		// or, for modules with install_if_changed, only copy when cmp finds a difference, and let
+		// Kati restat the installed file so that its dependents aren't rebuilt when it is unchanged.
+		if install.ifChanged {
+			fmt.Fprintf(buf, ".KATI_RESTAT: %s\n", install.to.String())
+		}
 		fmt.Fprintf(buf, "%s: %s", install.to.String(), install.from.String())
 		for _, dep := range install.implicitDeps {
 			fmt.Fprintf(buf, " %s", dep.String())
@@ -499,7 +506,11 @@ func (s *makeVarsSingleton) writeInstalls(installs, symlinks, katiVintfManifestI
 		}
 		fmt.Fprintln(buf)
 		fmt.Fprintln(buf, "\t@echo \"Install: $@\"")
-		fmt.Fprintf(buf, "\trm -f $@ && cp -f %s $< $@\n", preserveSymlinksFlag)
+		if install.ifChanged {
+			fmt.Fprintf(buf, "\tif ! cmp -s $< $@; then rm -f $@ && cp -f %s $< $@; fi\n", preserveSymlinksFlag)
+		} else {
+			fmt.Fprintf(buf, "\trm -f $@ && cp -f %s $< $@\n", preserveSymlinksFlag)
+		}
 		if install.executable {
 			fmt.Fprintf(buf, "\tchmod +x $@\n")
 		}
@@ -511,6 +522,9 @@ func (s *makeVarsSingleton) writeInstalls(installs, symlinks, katiVintfManifestI
 	}
 
 	for _, symlink := range symlinks {
+		if symlink.ifChanged {
+			fmt.Fprintf(buf, ".KATI_RESTAT: %s\n", symlink.to.String())
+		}
 		fmt.Fprintf(buf, "%s:", symlink.to.String())
 		if symlink.from != nil {
 			// The katiVintfManifestInstall doesn't need updating when the target is modified, but we sometimes
@@ -543,7 +557,11 @@ func (s *makeVarsSingleton) writeInstalls(installs, symlinks, katiVintfManifestI
 		}
 
 		fmt.Fprintln(buf, "\t@echo \"Symlink: $@\"")
-		fmt.Fprintf(buf, "\trm -f $@ && ln -sfn %s $@", fromStr)
+		if symlink.ifChanged {
+			fmt.Fprintf(buf, "\tif [ \"$$(readlink $@)\" != \"%s\" ]; then rm -f $@ && ln -sfn %s $@; fi", fromStr, fromStr)
+		} else {
+			fmt.Fprintf(buf, "\trm -f $@ && ln -sfn %s $@", fromStr)
+		}
 		fmt.Fprintln(buf)
 		fmt.Fprintln(buf)
 	}
@@ -641,9 +659,19 @@ func (c *makeVarsContext) DistForGoalWithFilename(goal string, path Path, filena
 }
 
 func (c *makeVarsContext) DistForGoals(goals []string, paths ...Path) {
-	c.addDist(goals, Paths(paths).Strings())
+	distPaths := Paths(paths).Strings()
+	if c.Config().DistInProductDir() {
+		// Name the destination so that it can be put in the product directory.
+		for i, path := range paths {
+			distPaths[i] = path.String() + ":" + filepath.Join(c.Config().DeviceProduct(), path.Base())
+		}
+	}
+	c.addDist(goals, distPaths)
 }
 
 func (c *makeVarsContext) DistForGoalsWithFilename(goals []string, path Path, filename string) {
+	if c.Config().DistInProductDir() {
+		filename = filepath.Join(c.Config().DeviceProduct(), filename)
+	}
 	c.addDist(goals, []string{path.String() + ":" + filename})
 }
@@ -18,6 +18,7 @@ import (
 	"bytes"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"runtime"
 	"strconv"
 	"time"
@@ -33,6 +34,7 @@ var soongMetricsOnceKey = NewOnceKey("soong metrics")
 type soongMetrics struct {
 	modules       int
 	variants      int
+	moduleTypes   map[string]int
 	perfCollector perfCollector
 }
 
@@ -57,9 +59,12 @@ type soongMetricsSingleton struct{}
 
 func (soongMetricsSingleton) GenerateBuildActions(ctx SingletonContext) {
 	metrics := getSoongMetrics(ctx.Config())
+	metrics.moduleTypes = make(map[string]int)
 	ctx.VisitAllModules(func(m Module) {
 		if ctx.PrimaryModule(m) == m {
 			metrics.modules++
+			// Counted the same way as STATS.SOONG_MODULE_TYPE in the Android.mk file.
+			metrics.moduleTypes[ctx.ModuleType(m)]++
 		}
 		metrics.variants++
 	})
@@ -74,8 +79,22 @@ func collectMetrics(config Config, eventHandler *metrics.EventHandler) *soong_me
 		metrics.Variants = proto.Uint32(uint32(soongMetrics.variants))
 	}
 
-	soongMetrics.perfCollector.stop <- true
+	// The background metrics are not collected when soong_build doesn't write metrics, e.g. when it
+	// is run manually without LOG_DIR.
+	if soongMetrics.perfCollector.stop != nil {
+		soongMetrics.perfCollector.stop <- true
+	}
 	metrics.PerfCounters = soongMetrics.perfCollector.events
+	metrics.ModuleCounters = moduleCounterGroups(config)
+
+	tailEvents, tailCounters := tailPhaseMetrics(config)
+	if tailCounters != nil {
+		metrics.PerfCounters = append(metrics.PerfCounters, tailCounters)
+	}
+
+	if config.internPathsEnabled() {
+		metrics.PerfCounters = append(metrics.PerfCounters, pathInternerPerfCounters(config))
+	}
 
 	memStats := runtime.MemStats{}
 	runtime.ReadMemStats(&memStats)
@@ -92,6 +111,7 @@ func collectMetrics(config Config, eventHandler *metrics.EventHandler) *soong_me
 		}
 		metrics.Events = append(metrics.Events, &perfInfo)
 	}
+	metrics.Events = append(metrics.Events, tailEvents...)
 
 	return metrics
 }
@@ -203,17 +223,24 @@ func readCpuTime() time.Duration {
 	return time.Duration(userCpuTicks+kernelCpuTicks) * MS_PER_HZ
 }
 
-func WriteMetrics(config Config, eventHandler *metrics.EventHandler, metricsFile string) error {
+// WriteMetrics writes the soong_build metrics to the file, and returns them so that they can also be
+// summarized with MetricsSummary.  It must only be called once, as it stops the background metrics.
+func WriteMetrics(config Config, eventHandler *metrics.EventHandler, metricsFile string) (*soong_metrics_proto.SoongBuildMetrics, error) {
 	metrics := collectMetrics(config, eventHandler)
 
 	buf, err := proto.Marshal(metrics)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	err = ioutil.WriteFile(absolutePath(metricsFile), buf, 0666)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	countersFile := filepath.Join(filepath.Dir(metricsFile), "soong_build_module_counters.json")
+	if err := writeModuleCountersJson(config, countersFile); err != nil {
+		return nil, err
 	}
 
-	return nil
+	return metrics, nil
 }
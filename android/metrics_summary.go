@@ -0,0 +1,81 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	soong_metrics_proto "android/soong/ui/metrics/metrics_proto"
+)
+
+// MetricsSummary returns a human readable summary of the metrics returned by WriteMetrics, for
+// quick inspection without decoding soong_build_metrics.pb.
+func MetricsSummary(config Config, metrics *soong_metrics_proto.SoongBuildMetrics) string {
+	return formatMetricsSummary(metrics, getSoongMetrics(config).moduleTypes)
+}
+
+// formatMetricsSummary formats the durations of the top level events, the total analysis time, the
+// number of modules of each type and the peak heap size.  Nested events, whose ids contain a ".",
+// are not listed but are included in the duration of their top level event.
+func formatMetricsSummary(metrics *soong_metrics_proto.SoongBuildMetrics, moduleTypes map[string]int) string {
+	sb := &strings.Builder{}
+
+	var events []*soong_metrics_proto.PerfInfo
+	for _, event := range metrics.GetEvents() {
+		if !strings.Contains(event.GetDescription(), ".") {
+			events = append(events, event)
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].GetStartTime() < events[j].GetStartTime()
+	})
+
+	fmt.Fprintln(sb, "Events:")
+	var total uint64
+	for _, event := range events {
+		fmt.Fprintf(sb, "  %-30s %s\n", event.GetDescription(), formatNanoseconds(event.GetRealTime()))
+		total += event.GetRealTime()
+	}
+	fmt.Fprintf(sb, "Total analysis time: %s\n", formatNanoseconds(total))
+
+	if metrics.Modules != nil {
+		fmt.Fprintf(sb, "Modules: %d (%d variants)\n", metrics.GetModules(), metrics.GetVariants())
+	}
+
+	if len(moduleTypes) > 0 {
+		types := SortedKeys(moduleTypes)
+		// Most common module types first.
+		sort.SliceStable(types, func(i, j int) bool {
+			return moduleTypes[types[i]] > moduleTypes[types[j]]
+		})
+		fmt.Fprintln(sb, "Module types:")
+		for _, moduleType := range types {
+			fmt.Fprintf(sb, "  %-30s %d\n", moduleType, moduleTypes[moduleType])
+		}
+	}
+
+	if metrics.MaxHeapSize != nil {
+		fmt.Fprintf(sb, "Peak heap size: %.1f MiB\n", float64(metrics.GetMaxHeapSize())/(1024*1024))
+	}
+
+	return sb.String()
+}
+
+func formatNanoseconds(ns uint64) string {
+	return time.Duration(ns).Round(time.Millisecond).String()
+}
@@ -0,0 +1,71 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+
+	soong_metrics_proto "android/soong/ui/metrics/metrics_proto"
+)
+
+func TestFormatMetricsSummary(t *testing.T) {
+	event := func(id string, start, realTime uint64) *soong_metrics_proto.PerfInfo {
+		return &soong_metrics_proto.PerfInfo{
+			Description: proto.String(id),
+			Name:        proto.String("soong_build"),
+			StartTime:   proto.Uint64(start),
+			RealTime:    proto.Uint64(realTime),
+		}
+	}
+
+	metrics := &soong_metrics_proto.SoongBuildMetrics{
+		Modules:     proto.Uint32(3),
+		Variants:    proto.Uint32(7),
+		MaxHeapSize: proto.Uint64(3 * 1024 * 1024 / 2),
+		Events: []*soong_metrics_proto.PerfInfo{
+			event("soong_build", 1000, 2500000000),
+			event("soong_build.parse", 1000, 1000000000),
+			event("globs_ninja_file", 500, 1234567),
+		},
+	}
+	moduleTypes := map[string]int{
+		"cc_library": 1,
+		"filegroup":  1,
+		"genrule":    2,
+	}
+
+	expected := "" +
+		"Events:\n" +
+		"  globs_ninja_file               1ms\n" +
+		"  soong_build                    2.5s\n" +
+		"Total analysis time: 2.501s\n" +
+		"Modules: 3 (7 variants)\n" +
+		"Module types:\n" +
+		"  genrule                        2\n" +
+		"  cc_library                     1\n" +
+		"  filegroup                      1\n" +
+		"Peak heap size: 1.5 MiB\n"
+
+	AssertStringEquals(t, "summary", expected, formatMetricsSummary(metrics, moduleTypes))
+
+	t.Run("empty", func(t *testing.T) {
+		expected := "" +
+			"Events:\n" +
+			"Total analysis time: 0s\n"
+		AssertStringEquals(t, "summary", expected, formatMetricsSummary(&soong_metrics_proto.SoongBuildMetrics{}, nil))
+	})
+}
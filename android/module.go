@@ -187,9 +187,15 @@ type Dist struct {
 	// If true, then the artifact file will be appended with _<product name>. For
 	// example, if the product is coral and the module is an android_app module
 	// of name foo, then the artifact would be foo_coral.apk. If false, there is
-	// no change to the artifact file name.
+	// no change to the artifact file name.  Ignored when the artifact is put in the product
+	// directory, which already names the product.
 	Append_artifact_with_product *bool `android:"arch_variant"`
 
+	// If false, then the artifact is put in the dist directory itself even when the
+	// Dist_in_product_dir product variable puts the dists in a subdirectory named after the
+	// product.  Defaults to true.
+	Product_dir *bool `android:"arch_variant"`
+
 	// A string tag to select the OutputFiles associated with the tag.
 	//
 	// If no tag is specified then it will select the default dist paths provided
@@ -197,6 +203,18 @@ type Dist struct {
 	// default output files provided by the modules, i.e. the result of calling
 	// OutputFiles("").
 	Tag *string `android:"arch_variant"`
+
+	// If true, then the license metadata file and the license texts of the module will also be
+	// copied to the licenses subdirectory of the directory containing the artifact, named after the
+	// artifact, e.g. licenses/foo.jar.meta_lic and licenses/foo.jar.0.NOTICE for foo.jar.
+	With_license *bool `android:"arch_variant"`
+
+	// If true, then the artifact file will be appended with _<os>, and with _<arch> as well if the
+	// module has more than one arch variant for the os.  For example, a host tool foo built for
+	// linux_glibc and darwin would be dist'ed as foo_linux_glibc and foo_darwin.  This is needed to
+	// dist the same artifact for more than one os, as the copies would otherwise overwrite each
+	// other.
+	Append_os *bool `android:"arch_variant"`
 }
 
 // NamedPath associates a path with a name. e.g. a license text path with a package name
@@ -225,25 +243,35 @@ func (l NamedPaths) Strings() []string {
 	return result
 }
 
+// Paths returns the paths in the list without their names.
+func (l NamedPaths) Paths() Paths {
+	result := make(Paths, 0, len(l))
+	for _, p := range l {
+		result = append(result, p.Path)
+	}
+	return result
+}
+
 // SortedUniqueNamedPaths modifies `l` in place to return the sorted unique subset.
 func SortedUniqueNamedPaths(l NamedPaths) NamedPaths {
 	if len(l) == 0 {
 		return l
 	}
-	sort.Slice(l, func(i, j int) bool {
-		return l[i].String() < l[j].String()
-	})
 	k := 0
-	for i := 1; i < len(l); i++ {
-		if l[i].String() == l[k].String() {
+	seen := make(map[string]bool, len(l))
+	for i := 0; i < len(l); i++ {
+		if seen[l[i].String()] {
 			continue
 		}
+		seen[l[i].String()] = true
+		l[k] = l[i]
 		k++
-		if k < i {
-			l[k] = l[i]
-		}
 	}
-	return l[:k+1]
+	l = l[:k]
+	sort.Slice(l, func(i, j int) bool {
+		return l[i].String() < l[j].String()
+	})
+	return l
 }
 
 // soongConfigTrace holds all references to VendorVars. Uses []string for blueprint:"mutated"
@@ -286,6 +314,22 @@ type commonProperties struct {
 	// and so prevent early detection of changes that have broken those modules.
 	Enabled *bool `android:"arch_variant"`
 
+	// If true, the interface of the module, e.g. its exported headers, is still built when the
+	// module is disabled, so that the modules that only depend on its interface still compile.
+	// Only supported by some module types.
+	Provide_interface_when_disabled *bool
+
+	// Constraints on the kernel version of the board, set by BOARD_KERNEL_VERSION. On boards whose
+	// kernel version doesn't satisfy them the module is disabled instead of failing the build.
+	Kernel_version_constraints struct {
+		// The minimum kernel version, e.g. "5.15", inclusive.
+		Min *string
+
+		// The maximum kernel version, e.g. "6.6", inclusive. Only the components that are specified
+		// are compared, so "6.6" allows "6.6.30".
+		Max *string
+	}
+
 	// Controls the visibility of this module to other modules. Allowable values are one or more of
 	// these formats:
 	//
@@ -334,17 +378,6 @@ type commonProperties struct {
 	// Describes the licenses applicable to this module. Must reference license modules.
 	Licenses []string
 
-	// Flattened from direct license dependencies. Equal to Licenses unless particular module adds more.
-	Effective_licenses []string `blueprint:"mutated"`
-	// Override of module name when reporting licenses
-	Effective_package_name *string `blueprint:"mutated"`
-	// Notice files
-	Effective_license_text NamedPaths `blueprint:"mutated"`
-	// License names
-	Effective_license_kinds []string `blueprint:"mutated"`
-	// License conditions
-	Effective_license_conditions []string `blueprint:"mutated"`
-
 	// control whether this module compiles for 32-bit, 64-bit, or both.  Possible values
 	// are "32" (compile for 32-bit only), "64" (compile for 64-bit only), "both" (compile for both
 	// architectures), or "first" (compile for 64-bit on a 64-bit platform, and 32-bit on a 32-bit
@@ -418,7 +451,9 @@ type commonProperties struct {
 	// VINTF manifest fragments to be installed if this module is installed
 	Vintf_fragments []string `android:"path"`
 
-	// names of other modules to install if this module is installed
+	// names of other modules to install if this module is installed.  Like other arch variant
+	// properties it can be restricted to some variants, e.g. with target: { android: { required } },
+	// including when it is set in a defaults module.
 	Required []string `android:"arch_variant"`
 
 	// names of other modules to install on host if this module is installed
@@ -427,6 +462,31 @@ type commonProperties struct {
 	// names of other modules to install on target if this module is installed
 	Target_required []string `android:"arch_variant"`
 
+	// If set to true, the install rules for this module only update an installed file or symlink
+	// when its contents change, keeping its timestamp otherwise so that rules depending on it, such
+	// as partition images, are not rerun after rebuilding an identical file. Only applies to
+	// install rules written by Soong, not to those embedded in Make. Defaults to false.
+	Install_if_changed *bool
+
+	// If set, the variant for the secondary architecture of a module that is compiled for both
+	// 32-bit and 64-bit architectures is installed with a _32 suffix, e.g. foo and foo_32, so that
+	// both variants can be installed into the same directory.  Products that set
+	// Multilib_suffix_64bit install the 64-bit variant with a 64 suffix instead, e.g. foo64 and foo.
+	// Only applies to module types that name their installed files with MultilibInstallSuffix.
+	Suffix_32bit *bool
+
+	// If set to true, the module may only be installed when it is listed in the
+	// RestrictedInstallAllowlist product variable, and it is an error for another module to list it
+	// in required, host_required or target_required or for it to be in PRODUCT_PACKAGES otherwise.
+	// For security sensitive tools that must only be installed by an explicit per build opt-in.
+	Restricted_install *bool
+
+	// Paths of files that the module no longer installs, relative to the product out directory,
+	// e.g. "system/bin/oldname" after renaming a binary.  They are listed in obsolete_files.txt so
+	// that stale copies are deleted from incremental builds and devices, and it is an error for an
+	// enabled module to still install them.
+	Obsolete_files []string
+
 	// The OsType of artifacts that this module variant is responsible for creating.
 	//
 	// Set by osMutator
@@ -457,6 +517,11 @@ type commonProperties struct {
 	// Set by archMutator
 	CompilePrimary bool `blueprint:"mutated"`
 
+	// True if the module was split into more than one arch variant for its os.
+	//
+	// Set by archMutator
+	CompileMultipleArchVariants bool `blueprint:"mutated"`
+
 	// Set by InitAndroidModule
 	HostOrDeviceSupported HostOrDeviceSupported `blueprint:"mutated"`
 	ArchSpecific          bool                  `blueprint:"mutated"`
@@ -498,8 +563,14 @@ type commonProperties struct {
 	// Disabled by mutators. If set to true, it overrides Enabled property.
 	ForcedDisabled bool `blueprint:"mutated"`
 
+	// The reason the module was disabled by its kernel_version_constraints, if it was.
+	KernelVersionDisabledReason string `blueprint:"mutated"`
+
 	NamespaceExportedToMake bool `blueprint:"mutated"`
 
+	// The path of the namespace that contains the module.
+	NamespacePath string `blueprint:"mutated"`
+
 	MissingDeps        []string `blueprint:"mutated"`
 	CheckedMissingDeps bool     `blueprint:"mutated"`
 
@@ -521,10 +592,31 @@ type commonProperties struct {
 	SoongConfigTrace     soongConfigTrace `blueprint:"mutated"`
 	SoongConfigTraceHash string           `blueprint:"mutated"`
 
-	// The team (defined by the owner/vendor) who owns the property.
-	Team *string `android:"path"`
+	// The team (defined by the owner/vendor) who owns the property.  Combined with teams when both
+	// are set.
+	Team *string `android:"arch_variant,path"`
+
+	// The teams (defined by the owner/vendor) who co-own the module.  A list set in an arch-, os- or
+	// target-specific block replaces the generic list for those variants, so that, for example,
+	// target: { windows: { teams: ["windows_port_team"] } } gives the windows variants a different
+	// owner.
+	Teams []string `android:"arch_variant,variant_replace"`
+
+	// An identifier of the module that is kept when the module is renamed, so that module-info.json,
+	// stable_ids.json, all_teams.pb and the dist manifest can be joined across renames.  It must be
+	// unique across the tree.  Defaults to the name of the module.  It is not in soong_build_metrics,
+	// which has no per-module entries to join it with.
+	Stable_id *string
+
+	// The default_team and default_owner of the closest ancestor package that sets them, used when
+	// the module does not set team or owner.
+	Package_default_team  *string `blueprint:"mutated"`
+	Package_default_owner *string `blueprint:"mutated"`
 }
 
+// distProperties are arch_variant, so the arch-, os- and target-specific dist and dists properties
+// of arch-specific modules are merged into the properties of each variant by the arch mutator, and
+// Dists() returns the merged values.
 type distProperties struct {
 	// configuration to distribute output files from this module to the distribution
 	// directory (default: $OUT/dist, configurable with $DIST_DIR)
@@ -533,6 +625,12 @@ type distProperties struct {
 	// a list of configurations to distribute output files from this module to the
 	// distribution directory (default: $OUT/dist, configurable with $DIST_DIR)
 	Dists []Dist `android:"arch_variant"`
+
+	// If true, the module still distributes its output files when it is hidden from Make, e.g.
+	// because it was replaced by its prebuilt.  By default only the module that is visible to Make
+	// contributes to the dist, so that a source module and its preferred prebuilt don't both dist
+	// their artifacts.
+	Dist_when_hidden *bool
 }
 
 type TeamDepTagType struct {
@@ -562,6 +660,13 @@ func (t *CommonTestOptions) SetAndroidMkEntries(entries *AndroidMkEntries) {
 	}
 }
 
+// SetModuleInfoJSON sets the test related fields of ModuleInfoJSON according to the value of base
+// `test_options`, mirroring what SetAndroidMkEntries exports to Make.
+func (t *CommonTestOptions) SetModuleInfoJSON(moduleInfoJSON *ModuleInfoJSON) {
+	moduleInfoJSON.IsUnitTest = moduleInfoJSON.IsUnitTest || Bool(t.Unit_test)
+	moduleInfoJSON.TestOptionsTags = append(moduleInfoJSON.TestOptionsTags, t.Tags...)
+}
+
 // The key to use in TaggedDistFiles when a Dist structure does not specify a
 // tag property. This intentionally does not use "" as the default because that
 // would mean that an empty tag would have a different meaning when used in a dist
@@ -581,11 +686,17 @@ func (t TaggedDistFiles) addPathsForTag(tag string, paths ...Path) TaggedDistFil
 		t = make(TaggedDistFiles)
 	}
 
+	existing := len(t[tag])
 	for _, distFile := range paths {
-		if distFile != nil && !t[tag].containsPath(distFile) {
+		if distFile != nil {
 			t[tag] = append(t[tag], distFile)
 		}
 	}
+	// The existing paths are already unique, so only the appended paths can be removed.  This
+	// switches to a map when there are many paths, as modules can dist hundreds of files.
+	if len(t[tag]) > existing {
+		t[tag] = FirstUniquePaths(t[tag])
+	}
 
 	return t
 }
@@ -694,6 +805,15 @@ func initAndroidModuleBase(m Module) {
 	m.base().module = m
 }
 
+// InitModuleTypeDists declares dists that apply to every module of the module type, and must be
+// called by the module factory.  A dist of the module type is only used if the dist and dists
+// properties of the module don't contain a dist with the same tag, and none are used if the
+// Disable_module_type_dists product variable is set.
+func InitModuleTypeDists(m Module, dists ...Dist) {
+	base := m.base()
+	base.moduleTypeDists = append(base.moduleTypeDists, dists...)
+}
+
 // InitAndroidModule initializes the Module as an Android module that is not architecture-specific.
 // It adds the common properties, for example "name" and "enabled".
 func InitAndroidModule(m Module) {
@@ -818,6 +938,7 @@ type ModuleBase struct {
 	nameProperties          nameProperties
 	commonProperties        commonProperties
 	distProperties          distProperties
+	moduleTypeDists         []Dist
 	variableProperties      interface{}
 	hostAndDeviceProperties hostAndDeviceProperties
 
@@ -857,6 +978,28 @@ type ModuleBase struct {
 	katiSymlinks       katiInstalls
 	testData           []DataPath
 
+	// The providers set on the module if SOONG_DUMP_PROVIDERS lists it.
+	providerDumps []providerDump
+
+	// The LicenseInfo set in LicenseInfoProvider, kept for EffectiveLicenseKinds and
+	// EffectiveLicenseFiles.
+	licenseInfo LicenseInfo
+
+	// The types of the unregistered tags of the direct dependencies if SOONG_AUDIT_DEPENDENCY_TAGS
+	// is true.
+	unregisteredDependencyTags []string
+
+	// The required modules that have no image variant compatible with this module, which are not
+	// exported to Make.
+	skippedRequired []string
+
+	// The lines added to the variant skip report if SOONG_VARIANT_SKIP_REPORT is true.
+	variantSkipDecisions []string
+
+	// The shortest chain of install dependencies for each installed path, relative to the out
+	// directory, if SOONG_INSTALL_PROVENANCE is true.
+	installProvenance map[string][]InstallProvenanceStep
+
 	// The files to copy to the dist as explicitly specified in the .bp file.
 	distFiles TaggedDistFiles
 
@@ -866,6 +1009,15 @@ type ModuleBase struct {
 	checkbuildTarget WritablePath
 	blueprintDir     string
 
+	// Used by buildTargetSingleton to create the short phony targets of modules in namespaces
+	// Only set on the final variant of each module
+	moduleTarget    WritablePath
+	inRootNamespace bool
+
+	// The prefix of the phony targets of the module, used to create its -dist phony target.  Only
+	// set on the final variant of each module
+	phonyPrefix string
+
 	hooks hooks
 
 	registerProps []interface{}
@@ -881,6 +1033,9 @@ type ModuleBase struct {
 	installedInitRcPaths         InstallPaths
 	installedVintfFragmentsPaths InstallPaths
 
+	// vintfFragmentsByBase maps the base names of the installed vintf fragments to their paths.
+	vintfFragmentsByBase map[string]Path
+
 	// set of dependency module:location mappings used to populate the license metadata for
 	// apex containers.
 	licenseInstallMap []string
@@ -1003,8 +1158,24 @@ func (m *ModuleBase) ComponentDepsMutator(BottomUpMutatorContext) {}
 func (m *ModuleBase) DepsMutator(BottomUpMutatorContext) {}
 
 func (m *ModuleBase) baseDepsMutator(ctx BottomUpMutatorContext) {
-	if m.Team() != "" {
-		ctx.AddDependency(ctx.Module(), teamDepTag, m.Team())
+	// The package default team is checked by a dependency from the package module.
+	ctx.AddDependency(ctx.Module(), teamDepTag, m.declaredTeams()...)
+
+	CheckNotSelfReferenced(ctx, "required", m.commonProperties.Required)
+	CheckNotSelfReferenced(ctx, "host_required", m.commonProperties.Host_required)
+	CheckNotSelfReferenced(ctx, "target_required", m.commonProperties.Target_required)
+	m.pruneRequiredImageVariants(ctx)
+}
+
+// CheckNotSelfReferenced reports an error on the property if the module names listed in it include
+// the module itself, by its name or its base module name.  Such names usually come from a defaults
+// module or were copied from another module, and would make the module require or override itself.
+func CheckNotSelfReferenced(ctx BaseModuleContext, property string, names []string) {
+	self := []string{ctx.ModuleName(), ctx.Module().base().BaseModuleName()}
+	for _, name := range FirstUniqueStrings(names) {
+		if InList(name, self) {
+			ctx.PropertyErrorf(property, "%q refers to the module itself", name)
+		}
 	}
 }
 
@@ -1104,14 +1275,53 @@ func (m *ModuleBase) Dists() []Dist {
 	}
 }
 
+// distsWithModuleTypeDists returns the dists of the module followed by the dists declared by its
+// module type with InitModuleTypeDists whose tags are not used by any of the dists of the module.
+func (m *ModuleBase) distsWithModuleTypeDists(config Config) []Dist {
+	dists := m.Dists()
+	if len(m.moduleTypeDists) == 0 || config.ModuleTypeDistsDisabled() {
+		return dists
+	}
+
+	tags := make(map[string]bool)
+	for _, dist := range dists {
+		tags[proptools.StringDefault(dist.Tag, DefaultDistTag)] = true
+	}
+	ret := append([]Dist(nil), dists...)
+	for _, dist := range m.moduleTypeDists {
+		if !tags[proptools.StringDefault(dist.Tag, DefaultDistTag)] {
+			ret = append(ret, dist)
+		}
+	}
+	return ret
+}
+
 func (m *ModuleBase) GenerateTaggedDistFiles(ctx BaseModuleContext) TaggedDistFiles {
 	var distFiles TaggedDistFiles
-	for _, dist := range m.Dists() {
+	numDists := len(m.Dists())
+	for i, dist := range m.distsWithModuleTypeDists(ctx.Config()) {
+		// Unsupported tags are reported with a fix that removes the tag, which dists the default
+		// outputs of the module instead.  The dists of the module type are not in the Android.bp
+		// file, so they are reported on the module.
+		reportTagError := func(format string, args ...interface{}) {
+			if i >= numDists {
+				ctx.ModuleErrorf("module type dist: "+format, args...)
+				return
+			}
+			tagProperty := "dist.tag"
+			if i < len(m.distProperties.Dists) {
+				tagProperty = fmt.Sprintf("dists[%d].tag", i)
+			}
+			ctx.PropertyErrorfWithFix(RemovePropertyFix(tagProperty), format, args...)
+		}
+
 		// If no tag is specified then it means to use the default dist paths so use
 		// the special tag name which represents that.
 		tag := proptools.StringDefault(dist.Tag, DefaultDistTag)
 
-		if outputFileProducer, ok := m.module.(OutputFileProducer); ok {
+		if builtInFiles, ok := builtInOutputFiles(m.module, tag); ok {
+			distFiles = distFiles.addPathsForTag(tag, builtInFiles...)
+		} else if outputFileProducer, ok := m.module.(OutputFileProducer); ok {
 			// Call the OutputFiles(tag) method to get the paths associated with the tag.
 			distFilesForTag, err := outputFileProducer.OutputFiles(tag)
 
@@ -1119,7 +1329,7 @@ func (m *ModuleBase) GenerateTaggedDistFiles(ctx BaseModuleContext) TaggedDistFi
 			// Failing to find paths for DefaultDistTag is not an error. It just means
 			// that the module type requires the legacy behavior.
 			if err != nil && tag != DefaultDistTag {
-				ctx.PropertyErrorf("dist.tag", "%s", err.Error())
+				reportTagError("%s", err.Error())
 			}
 
 			distFiles = distFiles.addPathsForTag(tag, distFilesForTag...)
@@ -1127,8 +1337,7 @@ func (m *ModuleBase) GenerateTaggedDistFiles(ctx BaseModuleContext) TaggedDistFi
 			// If the tag was specified then it is an error if the module does not
 			// implement OutputFileProducer because there is no other way of accessing
 			// the paths for the specified tag.
-			ctx.PropertyErrorf("dist.tag",
-				"tag %s not supported because the module does not implement OutputFileProducer", tag)
+			reportTagError("tag %s not supported because the module does not implement OutputFileProducer", tag)
 		}
 	}
 
@@ -1297,6 +1506,25 @@ func (m *ModuleBase) Disable() {
 	m.commonProperties.ForcedDisabled = true
 }
 
+// InterfaceWhenDisabledModule is implemented by the module types that support
+// provide_interface_when_disabled.
+type InterfaceWhenDisabledModule interface {
+	Module
+
+	// GenerateInterfaceBuildActions is called instead of GenerateAndroidBuildActions when the
+	// module is disabled and sets provide_interface_when_disabled.  It only builds the interface
+	// of the module, and sets the providers read by the dependents that depend on it through an
+	// InterfaceDependencyTag.
+	GenerateInterfaceBuildActions(ctx ModuleContext)
+}
+
+// ProvidesInterfaceWhenDisabled returns true if the module is disabled but still builds its
+// interface for the dependents that depend on it through an InterfaceDependencyTag.
+func (m *ModuleBase) ProvidesInterfaceWhenDisabled() bool {
+	_, ok := m.module.(InterfaceWhenDisabledModule)
+	return ok && !m.Enabled() && Bool(m.commonProperties.Provide_interface_when_disabled)
+}
+
 // HideFromMake marks this variant so that it is not emitted in the generated Android.mk file.
 func (m *ModuleBase) HideFromMake() {
 	m.commonProperties.HideFromMake = true
@@ -1341,16 +1569,16 @@ func (m *ModuleBase) ExportedToMake() bool {
 	return m.commonProperties.NamespaceExportedToMake
 }
 
+// EffectiveLicenseKinds returns the LicenseKinds of the LicenseInfo of the module.  New code should
+// read LicenseInfoProvider instead.
 func (m *ModuleBase) EffectiveLicenseKinds() []string {
-	return m.commonProperties.Effective_license_kinds
+	return m.licenseInfo.LicenseKinds
 }
 
+// EffectiveLicenseFiles returns the paths of the LicenseTexts of the LicenseInfo of the module.
+// New code should read LicenseInfoProvider instead.
 func (m *ModuleBase) EffectiveLicenseFiles() Paths {
-	result := make(Paths, 0, len(m.commonProperties.Effective_license_text))
-	for _, p := range m.commonProperties.Effective_license_text {
-		result = append(result, p.Path)
-	}
-	return result
+	return m.licenseInfo.LicenseTexts.Paths()
 }
 
 // computeInstallDeps finds the installed paths of all dependencies that have a dependency
@@ -1364,6 +1592,7 @@ func (m *ModuleBase) computeInstallDeps(ctx ModuleContext) ([]*DepSet[InstallPat
 			// installable.
 			if !dep.IsHideFromMake() && !dep.IsSkipInstall() {
 				installDeps = append(installDeps, dep.base().installFilesDepSet)
+				m.addInstallProvenanceFromDep(ctx, dep, ctx.OtherModuleDependencyTag(dep))
 			}
 			// Add packaging deps even when the dependency is not installed so that uninstallable
 			// modules can still be packaged.  Often the package will be installed instead.
@@ -1449,12 +1678,42 @@ func (m *ModuleBase) InstallForceOS() (*OsType, *ArchType) {
 	return nil, nil
 }
 
+// Owner returns the owner of the module, or the default_owner of its package if the module does not
+// specify one.
 func (m *ModuleBase) Owner() string {
-	return String(m.commonProperties.Owner)
+	if m.commonProperties.Owner != nil {
+		return *m.commonProperties.Owner
+	}
+	return String(m.commonProperties.Package_default_owner)
+}
+
+// declaredTeams returns the teams set on the module variant by the team and teams properties.
+func (m *ModuleBase) declaredTeams() []string {
+	var teams []string
+	if team := String(m.commonProperties.Team); team != "" {
+		teams = append(teams, team)
+	}
+	return FirstUniqueStrings(append(teams, m.commonProperties.Teams...))
+}
+
+// Teams returns the teams of the module variant, or the default_team of its package if the module
+// variant does not specify any.
+func (m *ModuleBase) Teams() []string {
+	if teams := m.declaredTeams(); len(teams) > 0 {
+		return teams
+	}
+	if team := String(m.commonProperties.Package_default_team); team != "" {
+		return []string{team}
+	}
+	return nil
 }
 
-func (m *ModuleBase) Team() string {
-	return String(m.commonProperties.Team)
+// StableId returns the stable_id of the module, or its name if it does not set one.
+func (m *ModuleBase) StableId() string {
+	if id := m.commonProperties.Stable_id; id != nil {
+		return *id
+	}
+	return m.Name()
 }
 
 func (m *ModuleBase) setImageVariation(variant string) {
@@ -1506,6 +1765,64 @@ func (m *ModuleBase) TargetRequiredModuleNames() []string {
 	return m.base().commonProperties.Target_required
 }
 
+// InstallInitRc installs the init.rc files into the etc/init directory of the partition of the
+// device module, in the same way as the files listed in its init_rc property, so that they are
+// exported to Make in LOCAL_FULL_INIT_RC and installed files of the module depend on them.  It is
+// used for init.rc files that are not sources, e.g. ones extracted from a prebuilt, and must be
+// called before the module installs any files.  Init.rc files are not installed for modules in the
+// vendor ramdisk.
+func (m *ModuleBase) InstallInitRc(ctx ModuleContext, srcs Paths) {
+	if !m.Device() || m.InVendorRamdisk() || len(srcs) == 0 {
+		return
+	}
+	m.initRcPaths = append(m.initRcPaths, srcs...)
+	rcDir := PathForModuleInstall(ctx, "etc", "init")
+	for _, src := range srcs {
+		installedInitRc := rcDir.Join(ctx, src.Base())
+		m.katiInitRcInstalls = append(m.katiInitRcInstalls, katiInstall{
+			from: src,
+			to:   installedInitRc,
+		})
+		ctx.PackageFile(rcDir, src.Base(), src)
+		m.installedInitRcPaths = append(m.installedInitRcPaths, installedInitRc)
+	}
+}
+
+// InstallVintfFragments installs the vintf fragments into the etc/vintf/manifest directory of the
+// partition of the device module, in the same way as the files listed in its vintf_fragments
+// property.  It is used for vintf fragments that are not sources, e.g. ones extracted from a
+// prebuilt, and must be called before the module installs any files.
+func (m *ModuleBase) InstallVintfFragments(ctx ModuleContext, srcs Paths) {
+	if !m.Device() || len(srcs) == 0 {
+		return
+	}
+	vintfDir := PathForModuleInstall(ctx, "etc", "vintf", "manifest")
+	for _, src := range srcs {
+		// Fragments are installed by their base name, so two different fragments with the same base
+		// name, e.g. one from the vintf_fragments property and one extracted from a prebuilt, would
+		// be installed to the same path.
+		if existing, ok := m.vintfFragmentsByBase[src.Base()]; ok {
+			if existing.String() != src.String() {
+				ctx.ModuleErrorf("vintf fragment %q is installed from both %s and %s", src.Base(), existing, src)
+			}
+			continue
+		}
+		if m.vintfFragmentsByBase == nil {
+			m.vintfFragmentsByBase = make(map[string]Path)
+		}
+		m.vintfFragmentsByBase[src.Base()] = src
+		m.vintfFragmentsPaths = append(m.vintfFragmentsPaths, src)
+
+		installedVintfFragment := vintfDir.Join(ctx, src.Base())
+		m.katiVintfInstalls = append(m.katiVintfInstalls, katiInstall{
+			from: src,
+			to:   installedVintfFragment,
+		})
+		ctx.PackageFile(vintfDir, src.Base(), src)
+		m.installedVintfFragmentsPaths = append(m.installedVintfFragmentsPaths, installedVintfFragment)
+	}
+}
+
 func (m *ModuleBase) InitRc() Paths {
 	return append(Paths{}, m.initRcPaths...)
 }
@@ -1541,10 +1858,8 @@ func (m *ModuleBase) generateModuleTarget(ctx ModuleContext) {
 
 	var deps Paths
 
-	namespacePrefix := ctx.Namespace().id
-	if namespacePrefix != "" {
-		namespacePrefix = namespacePrefix + "-"
-	}
+	namespacePrefix := ctx.Namespace().phonyPrefix()
+	m.phonyPrefix = namespacePrefix
 
 	if len(allInstalledFiles) > 0 {
 		name := namespacePrefix + ctx.ModuleName() + "-install"
@@ -1566,7 +1881,10 @@ func (m *ModuleBase) generateModuleTarget(ctx ModuleContext) {
 			suffix = "-soong"
 		}
 
-		ctx.Phony(namespacePrefix+ctx.ModuleName()+suffix, deps...)
+		name := namespacePrefix + ctx.ModuleName() + suffix
+		ctx.Phony(name, deps...)
+		m.moduleTarget = PathForPhony(ctx, name)
+		m.inRootNamespace = namespacePrefix == ""
 
 		m.blueprintDir = ctx.ModuleDir()
 	}
@@ -1719,12 +2037,18 @@ func (m *ModuleBase) GenerateBuildActions(blueprintCtx blueprint.ModuleContext)
 	}
 	ctx.Variable(pctx, "moduleDescSuffix", s)
 
+	if _, ok := m.module.(InterfaceWhenDisabledModule); !ok && m.commonProperties.Provide_interface_when_disabled != nil {
+		ctx.PropertyErrorf("provide_interface_when_disabled", "not supported by this module type")
+	}
+
 	// Some common property checks for properties that will be used later in androidmk.go
 	checkDistProperties(ctx, "dist", &m.distProperties.Dist)
 	for i := range m.distProperties.Dists {
 		checkDistProperties(ctx, fmt.Sprintf("dists[%d]", i), &m.distProperties.Dists[i])
 	}
 
+	checkTeamReferences(ctx)
+
 	if m.Enabled() {
 		// ensure all direct android.Module deps are enabled
 		ctx.VisitDirectDepsBlueprint(func(bm blueprint.Module) {
@@ -1741,31 +2065,8 @@ func (m *ModuleBase) GenerateBuildActions(blueprintCtx blueprint.ModuleContext)
 			// The full list of all init.rc and vintf fragment install rules will be deduplicated later
 			// so only a single rule is created for each init.rc or vintf fragment file.
 
-			if !m.InVendorRamdisk() {
-				m.initRcPaths = PathsForModuleSrc(ctx, m.commonProperties.Init_rc)
-				rcDir := PathForModuleInstall(ctx, "etc", "init")
-				for _, src := range m.initRcPaths {
-					installedInitRc := rcDir.Join(ctx, src.Base())
-					m.katiInitRcInstalls = append(m.katiInitRcInstalls, katiInstall{
-						from: src,
-						to:   installedInitRc,
-					})
-					ctx.PackageFile(rcDir, src.Base(), src)
-					m.installedInitRcPaths = append(m.installedInitRcPaths, installedInitRc)
-				}
-			}
-
-			m.vintfFragmentsPaths = PathsForModuleSrc(ctx, m.commonProperties.Vintf_fragments)
-			vintfDir := PathForModuleInstall(ctx, "etc", "vintf", "manifest")
-			for _, src := range m.vintfFragmentsPaths {
-				installedVintfFragment := vintfDir.Join(ctx, src.Base())
-				m.katiVintfInstalls = append(m.katiVintfInstalls, katiInstall{
-					from: src,
-					to:   installedVintfFragment,
-				})
-				ctx.PackageFile(vintfDir, src.Base(), src)
-				m.installedVintfFragmentsPaths = append(m.installedVintfFragmentsPaths, installedVintfFragment)
-			}
+			m.InstallInitRc(ctx, PathsForModuleSrc(ctx, m.commonProperties.Init_rc))
+			m.InstallVintfFragments(ctx, PathsForModuleSrc(ctx, m.commonProperties.Vintf_fragments))
 		}
 
 		licensesPropertyFlattener(ctx)
@@ -1802,9 +2103,26 @@ func (m *ModuleBase) GenerateBuildActions(blueprintCtx blueprint.ModuleContext)
 		m.installFiles = append(m.installFiles, ctx.installFiles...)
 		m.checkbuildFiles = append(m.checkbuildFiles, ctx.checkbuildFiles...)
 		m.packagingSpecs = append(m.packagingSpecs, ctx.packagingSpecs...)
+		if info := installFileMetadataInfo(ctx.packagingSpecs); len(info.Entries) > 0 {
+			SetProvider(ctx, InstallFileMetadataProvider, info)
+		}
 		m.katiInstalls = append(m.katiInstalls, ctx.katiInstalls...)
 		m.katiSymlinks = append(m.katiSymlinks, ctx.katiSymlinks...)
 		m.testData = append(m.testData, ctx.testData...)
+		m.checkSymlinkTargets(ctx, dependencyInstallFiles)
+
+		if ctx.Config().internPathsEnabled() {
+			interner := getPathInterner(ctx.Config())
+			m.installFiles = interner.internInstallPaths(m.installFiles)
+			m.checkbuildFiles = interner.intern(m.checkbuildFiles)
+		}
+	} else if m.ProvidesInterfaceWhenDisabled() {
+		// Only build the interface of the module, for the dependents that depend on it through an
+		// InterfaceDependencyTag.
+		m.module.(InterfaceWhenDisabledModule).GenerateInterfaceBuildActions(ctx)
+		if ctx.Failed() {
+			return
+		}
 	} else if ctx.Config().AllowMissingDependencies() {
 		// If the module is not enabled it will not create any build rules, nothing will call
 		// ctx.GetMissingDependencies(), and blueprint will consider the missing dependencies to be unhandled
@@ -1821,6 +2139,7 @@ func (m *ModuleBase) GenerateBuildActions(blueprintCtx blueprint.ModuleContext)
 	}
 
 	m.installFilesDepSet = NewDepSet[InstallPath](TOPOLOGICAL, m.installFiles, dependencyInstallFiles)
+	m.addOwnInstallProvenance(ctx)
 	m.packagingSpecsDepSet = NewDepSet[PackagingSpec](TOPOLOGICAL, m.packagingSpecs, dependencyPackagingSpecs)
 
 	buildLicenseMetadata(ctx, m.licenseMetadataFile)
@@ -1860,14 +2179,22 @@ func (m *ModuleBase) GenerateBuildActions(blueprintCtx blueprint.ModuleContext)
 			Path:               []string{ctx.ModuleDir()},
 			Installed:          installedStrings,
 			ModuleName:         m.BaseModuleName() + m.moduleInfoJSON.SubName,
+			StableId:           String(m.commonProperties.Stable_id),
 			SupportedVariants:  []string{m.moduleInfoVariant(ctx)},
 			TargetDependencies: targetRequired,
 			HostDependencies:   hostRequired,
 			Data:               data,
 		}
+		if ctx.Config().ModuleInfoLicenses() && !exemptFromRequiredApplicableLicensesProperty(m.module) {
+			m.moduleInfoJSON.core.LicenseKinds = m.licenseInfo.LicenseKinds
+			m.moduleInfoJSON.core.LicenseConditions = m.licenseInfo.LicenseConditions
+		}
 		SetProvider(ctx, ModuleInfoJSONProvider, m.moduleInfoJSON)
 	}
 
+	writeProviderDump(ctx)
+	recordUnregisteredDependencyTags(ctx)
+
 	m.buildParams = ctx.buildParams
 	m.ruleParams = ctx.ruleParams
 	m.variables = ctx.variables
@@ -1889,19 +2216,50 @@ func (m *ModuleBase) moduleInfoRegisterName(ctx ModuleContext, subName string) s
 			prefix = "host_cross_"
 		}
 	}
-	suffix := ""
+	return prefix + name + subName + secondaryArchSuffix(ctx)
+}
+
+// secondaryArchSuffix returns _32 or _64 if the variant is not for the primary architecture of its
+// OS, and "" otherwise.
+func secondaryArchSuffix(ctx BaseModuleContext) string {
 	arches := slices.Clone(ctx.Config().Targets[ctx.Os()])
 	arches = slices.DeleteFunc(arches, func(target Target) bool {
 		return target.NativeBridge != ctx.Target().NativeBridge
 	})
 	if len(arches) > 0 && ctx.Arch().ArchType != arches[0].Arch.ArchType {
 		if ctx.Arch().ArchType.Multilib == "lib32" {
-			suffix = "_32"
+			return "_32"
 		} else {
-			suffix = "_64"
+			return "_64"
+		}
+	}
+	return ""
+}
+
+// MultilibInstallSuffix returns the suffix that tells apart the files installed by the variants of a
+// module that sets suffix_32bit, is compiled for both 32-bit and 64-bit architectures and installs
+// them into the same directory, e.g. foo and foo_32 in /system/bin.  It is _32 or _64 for the
+// variant for the secondary architecture, and "" for the primary architecture, if the module is only
+// compiled for one architecture or if suffix_32bit is not set.  On products that set
+// Multilib_suffix_64bit it is 64 for the 64-bit variant and "" for the 32-bit variant instead, e.g.
+// foo64 and foo.
+func MultilibInstallSuffix(ctx BaseModuleContext) string {
+	m := ctx.Module().base()
+	if !m.Suffix32bit() || !m.commonProperties.CompileMultipleArchVariants {
+		return ""
+	}
+	if ctx.Config().MultilibSuffix64bit() {
+		if ctx.Arch().ArchType.Multilib == "lib64" {
+			return "64"
 		}
+		return ""
 	}
-	return prefix + name + subName + suffix
+	return secondaryArchSuffix(ctx)
+}
+
+// Suffix32bit returns true if the module sets suffix_32bit.
+func (m *ModuleBase) Suffix32bit() bool {
+	return Bool(m.commonProperties.Suffix_32bit)
 }
 
 func (m *ModuleBase) moduleInfoVariant(ctx ModuleContext) string {
@@ -1950,6 +2308,8 @@ type katiInstall struct {
 	orderOnlyDeps Paths
 	executable    bool
 	extraFiles    *extraFilesZip
+	// ifChanged makes the install rule leave the installed file alone if it is unchanged.
+	ifChanged bool
 
 	absFrom string
 }
@@ -2152,6 +2512,25 @@ type OutputFileProducer interface {
 	OutputFiles(tag string) (Paths, error)
 }
 
+// LicenseMetadataTag is the tag of the license metadata file of a module.  It is supported for every
+// module that is not exempt from the license requirements, in addition to the tags supported by its
+// OutputFileProducer, so that the file can be referenced with ":module{.meta_lic}" or disted with
+// dist: { tag: ".meta_lic" }.
+const LicenseMetadataTag = ".meta_lic"
+
+// builtInOutputFiles returns the output files for the tags that are supported by every module, and
+// whether the tag is one of them for the module.
+func builtInOutputFiles(module blueprint.Module, tag string) (Paths, bool) {
+	if tag != LicenseMetadataTag {
+		return nil, false
+	}
+	m, ok := module.(Module)
+	if !ok || exemptFromRequiredApplicableLicensesProperty(m) || m.base().licenseMetadataFile == nil {
+		return nil, false
+	}
+	return Paths{m.base().licenseMetadataFile}, true
+}
+
 // OutputFilesForModule returns the paths from an OutputFileProducer with the given tag.  On error, including if the
 // module produced zero paths, it reports errors to the ctx and returns nil.
 func OutputFilesForModule(ctx PathContext, module blueprint.Module, tag string) Paths {
@@ -2199,7 +2578,9 @@ func OutputFileForModule(ctx PathContext, module blueprint.Module, tag string) P
 }
 
 func outputFilesForModule(ctx PathContext, module blueprint.Module, tag string) (Paths, error) {
-	if outputFileProducer, ok := module.(OutputFileProducer); ok {
+	if builtInFiles, ok := builtInOutputFiles(module, tag); ok {
+		return builtInFiles, nil
+	} else if outputFileProducer, ok := module.(OutputFileProducer); ok {
 		paths, err := outputFileProducer.OutputFiles(tag)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get output file from module %q: %s",
@@ -2280,11 +2661,36 @@ func (c *buildTargetSingleton) GenerateBuildActions(ctx SingletonContext) {
 
 	modulesInDir := make(map[string]Paths)
 
+	// The modules that generate each phony target, and the main phony targets of the modules in
+	// non-root namespaces with each name.
+	phonyTargetModules := make(map[string][]string)
+	namespacedModuleTargets := make(map[string]Paths)
+	rootNamespaceModules := make(map[string]bool)
+	var namespaceDirs []string
+
 	ctx.VisitAllModules(func(module Module) {
+		if _, ok := module.(*NamespaceModule); ok {
+			namespaceDirs = append(namespaceDirs, ctx.ModuleDir(module))
+		}
+
 		blueprintDir := module.base().blueprintDir
 		installTarget := module.base().installTarget
 		checkbuildTarget := module.base().checkbuildTarget
 
+		if moduleTarget := module.base().moduleTarget; moduleTarget != nil {
+			description := fmt.Sprintf("%q in %s", ctx.ModuleName(module), ctx.ModuleDir(module))
+			for _, target := range []WritablePath{moduleTarget, installTarget, checkbuildTarget} {
+				if target != nil {
+					phonyTargetModules[target.String()] = append(phonyTargetModules[target.String()], description)
+				}
+			}
+			if module.base().inRootNamespace {
+				rootNamespaceModules[ctx.ModuleName(module)] = true
+			} else {
+				namespacedModuleTargets[ctx.ModuleName(module)] = append(namespacedModuleTargets[ctx.ModuleName(module)], moduleTarget)
+			}
+		}
+
 		if checkbuildTarget != nil {
 			checkbuildDeps = append(checkbuildDeps, checkbuildTarget)
 			modulesInDir[blueprintDir] = append(modulesInDir[blueprintDir], checkbuildTarget)
@@ -2300,14 +2706,44 @@ func (c *buildTargetSingleton) GenerateBuildActions(ctx SingletonContext) {
 		suffix = "-soong"
 	}
 
+	// Modules in different namespaces must not merge their dependencies into the same phony target.
+	for _, target := range SortedKeys(phonyTargetModules) {
+		if modules := SortedUniqueStrings(phonyTargetModules[target]); len(modules) > 1 {
+			ctx.Errorf("phony target %q is generated by multiple modules: %s", target, strings.Join(modules, ", "))
+		}
+	}
+
 	// Create a top-level checkbuild target that depends on all modules
 	ctx.Phony("checkbuild"+suffix, checkbuildDeps...)
 
-	// Make will generate the MODULES-IN-* targets
+	// Make will generate the MODULES-IN-* targets and the targets named after modules
 	if ctx.Config().KatiEnabled() {
 		return
 	}
 
+	// Create a target named after each module in a non-root namespace, unless the name is used by a
+	// module in the root namespace, whose target is already named after it.  If the name is used in
+	// multiple namespaces then building the target reports the targets that could be built instead.
+	for _, name := range SortedKeys(namespacedModuleTargets) {
+		if rootNamespaceModules[name] {
+			continue
+		}
+		targets := namespacedModuleTargets[name]
+		if len(targets) == 1 {
+			ctx.Phony(name, targets...)
+			continue
+		}
+		ctx.Build(pctx, BuildParams{
+			Rule:        ErrorRule,
+			Description: "ambiguous module name " + name,
+			Output:      PathForPhony(ctx, name),
+			Args: map[string]string{
+				"error": fmt.Sprintf("module %s is defined in multiple namespaces, build one of %s instead",
+					name, strings.Join(SortedUniqueStrings(targets.Strings()), ", ")),
+			},
+		})
+	}
+
 	dirs, _ := AddAncestors(ctx, modulesInDir, mmTarget)
 
 	// Create a MODULES-IN-<directory> target that depends on all modules in a directory, and
@@ -2317,6 +2753,25 @@ func (c *buildTargetSingleton) GenerateBuildActions(ctx SingletonContext) {
 		ctx.Phony(mmTarget(dir), modulesInDir[dir]...)
 	}
 
+	// Create a short mma-<alias> target for the MODULES-IN-* target of the root directory of each
+	// namespace.  An alias that is shared by multiple namespaces or that is used by another phony
+	// target is not created, the MODULES-IN-* target can be used instead.  The phony singleton
+	// checks for other phony targets with the name as they may be added by any other singleton.
+	namespacesWithAlias := make(map[string][]string)
+	for dir, alias := range namespaceAliases(namespaceDirs) {
+		name := "mma-" + alias
+		namespacesWithAlias[name] = append(namespacesWithAlias[name], dir)
+	}
+	for _, name := range SortedKeys(namespacesWithAlias) {
+		dirs := namespacesWithAlias[name]
+		if len(dirs) > 1 {
+			continue
+		}
+		if _, exists := modulesInDir[dirs[0]]; exists {
+			addPhonyAlias(ctx.Config(), name, PathForPhony(ctx, mmTarget(dirs[0])))
+		}
+	}
+
 	// Create (host|host-cross|target)-<OS> phony rules to build a reduced checkbuild.
 	type osAndCross struct {
 		os        OsType
@@ -2359,6 +2814,31 @@ func (c *buildTargetSingleton) GenerateBuildActions(ctx SingletonContext) {
 	}
 }
 
+// namespaceAliases returns the alias of each of the namespace directories, which is the shortest
+// trailing part of the directory that no other directory ends with, with "/" replaced by "-", e.g.
+// "y" for vendor/x/y, or "x-y" if vendor/z/y is also a namespace.
+func namespaceAliases(dirs []string) map[string]string {
+	aliases := make(map[string]string)
+	for _, dir := range dirs {
+		parts := strings.Split(dir, "/")
+		for n := 1; n <= len(parts); n++ {
+			suffix := strings.Join(parts[len(parts)-n:], "/")
+			unique := true
+			for _, other := range dirs {
+				if other != dir && (other == suffix || strings.HasSuffix(other, "/"+suffix)) {
+					unique = false
+					break
+				}
+			}
+			if unique || n == len(parts) {
+				aliases[dir] = strings.ReplaceAll(suffix, "/", "-")
+				break
+			}
+		}
+	}
+	return aliases
+}
+
 // Collect information for opening IDE project files in java/jdeps.go.
 type IDEInfo interface {
 	IDEInfo(ideInfo *IdeInfo)
@@ -2385,6 +2865,46 @@ type IdeInfo struct {
 	Paths             []string `json:"path,omitempty"`
 	Static_libs       []string `json:"static_libs,omitempty"`
 	Libs              []string `json:"libs,omitempty"`
+
+	// The variants of the module.  The image variation and partition differ between the variants so
+	// they are listed per variant instead of being merged like the other fields.
+	Variants []IdeVariantInfo `json:"variants,omitempty"`
+}
+
+// IdeVariantInfo describes a variant of a module for opening IDE project files.
+type IdeVariantInfo struct {
+	Variant        string `json:"variant"`
+	ImageVariation string `json:"image_variation,omitempty"`
+	PartitionTag   string `json:"partition,omitempty"`
+}
+
+// NewIdeVariantInfo returns the IdeVariantInfo for the module variant with the given variant
+// string.
+func NewIdeVariantInfo(module Module, variant string, config Config) IdeVariantInfo {
+	imageVariation := module.base().commonProperties.ImageVariation
+	return IdeVariantInfo{
+		Variant:        variant,
+		ImageVariation: imageVariation,
+		PartitionTag:   imageVariationPartitionTag(module, imageVariation, DeviceConfig{config.deviceConfig}),
+	}
+}
+
+// imageVariationPartitionTag returns the partition that the image variation of the module is
+// built for, or the partition tag of the module if the image variation does not imply one.
+func imageVariationPartitionTag(module Module, imageVariation string, config DeviceConfig) string {
+	switch {
+	case imageVariation == RecoveryVariation, imageVariation == RamdiskVariation,
+		imageVariation == VendorRamdiskVariation, imageVariation == DebugRamdiskVariation:
+		return imageVariation
+	case imageVariation == VendorVariation || strings.HasPrefix(imageVariation, VendorVariationPrefix):
+		if module.base().DeviceSpecific() {
+			return "odm"
+		}
+		return "vendor"
+	case imageVariation == ProductVariation || strings.HasPrefix(imageVariation, ProductVariationPrefix):
+		return "product"
+	}
+	return module.PartitionTag(config)
 }
 
 func CheckBlueprintSyntax(ctx BaseModuleContext, filename string, contents string) []error {
@@ -132,6 +132,16 @@ type ModuleContext interface {
 	// for which IsInstallDepNeeded returns true.
 	InstallFileWithExtraFilesZip(installPath InstallPath, name string, srcPath Path, extraZip Path, deps ...InstallPath) InstallPath
 
+	// InstallFileWithOptions creates a rule to copy srcPath to name in the installPath directory,
+	// like InstallFile or InstallExecutable, and attaches the metadata in the options to the
+	// installed file for the image builders.
+	//
+	// The installed file will be returned by FilesToInstall(), and the PackagingSpec for the
+	// installed file will be returned by PackagingSpecs() on this module or by
+	// TransitivePackagingSpecs() on modules that depend on this module through dependency tags
+	// for which IsInstallDepNeeded returns true.
+	InstallFileWithOptions(installPath InstallPath, name string, srcPath Path, opts InstallFileOptions) InstallPath
+
 	// InstallSymlink creates a rule to create a symlink from src srcPath to name in the installPath
 	// directory.
 	//
@@ -212,6 +222,11 @@ type ModuleContext interface {
 	// GenerateAndroidBuildActions.  If it is called then the struct will be written out and included in
 	// the module-info.json generated by Make, and Make will not generate its own data for this module.
 	ModuleInfoJSON() *ModuleInfoJSON
+
+	// IncrementMetric adds delta to the build health counter name of the module type, e.g. the
+	// number of deprecated API usages found.  The counters are summed per module type and across
+	// all module types in soong_build_metrics.pb.
+	IncrementMetric(name string, delta int)
 }
 
 type moduleContext struct {
@@ -226,6 +241,9 @@ type moduleContext struct {
 	katiInstalls []katiInstall
 	katiSymlinks []katiInstall
 
+	// The symlinks installed by the module, for checkSymlinkTargets.
+	installedSymlinks []installedSymlink
+
 	testData []DataPath
 
 	// For tests
@@ -423,33 +441,36 @@ func (m *moduleContext) InstallInVendor() bool {
 	return m.module.InstallInVendor()
 }
 
-func (m *moduleContext) skipInstall() bool {
-	if m.module.base().commonProperties.SkipInstall {
-		return true
-	}
-
-	if m.module.base().commonProperties.HideFromMake {
-		return true
-	}
-
-	// We'll need a solution for choosing which of modules with the same name in different
-	// namespaces to install.  For now, reuse the list of namespaces exported to Make as the
-	// list of namespaces to install in a Soong-only build.
-	if !m.module.base().commonProperties.NamespaceExportedToMake {
-		return true
+// installSkipReason returns why the files that the module installs are only packaged, or
+// SkipReasonNone if they are installed.
+func (m *moduleContext) installSkipReason() SkipReason {
+	props := &m.module.base().commonProperties
+	switch {
+	case props.UninstallableApexPlatformVariant:
+		return SkipReasonUninstallableApexPlatformVariant
+	case props.ReplacedByPrebuilt:
+		return SkipReasonReplacedByPrebuilt
+	case props.SkipInstall:
+		return SkipReasonSkipInstall
+	case props.HideFromMake:
+		return SkipReasonHideFromMake
+	case !props.NamespaceExportedToMake:
+		// We'll need a solution for choosing which of modules with the same name in different
+		// namespaces to install.  For now, reuse the list of namespaces exported to Make as the
+		// list of namespaces to install in a Soong-only build.
+		return SkipReasonNamespaceNotExported
 	}
-
-	return false
+	return SkipReasonNone
 }
 
 func (m *moduleContext) InstallFile(installPath InstallPath, name string, srcPath Path,
 	deps ...InstallPath) InstallPath {
-	return m.installFile(installPath, name, srcPath, deps, false, true, nil)
+	return m.installFile(installPath, name, srcPath, deps, false, true, nil, nil)
 }
 
 func (m *moduleContext) InstallExecutable(installPath InstallPath, name string, srcPath Path,
 	deps ...InstallPath) InstallPath {
-	return m.installFile(installPath, name, srcPath, deps, true, true, nil)
+	return m.installFile(installPath, name, srcPath, deps, true, true, nil, nil)
 }
 
 func (m *moduleContext) InstallFileWithExtraFilesZip(installPath InstallPath, name string, srcPath Path,
@@ -457,16 +478,23 @@ func (m *moduleContext) InstallFileWithExtraFilesZip(installPath InstallPath, na
 	return m.installFile(installPath, name, srcPath, deps, false, true, &extraFilesZip{
 		zip: extraZip,
 		dir: installPath,
-	})
+	}, nil)
+}
+
+func (m *moduleContext) InstallFileWithOptions(installPath InstallPath, name string, srcPath Path,
+	opts InstallFileOptions) InstallPath {
+	return m.installFile(installPath, name, srcPath, opts.Deps, opts.Executable, true, nil, opts.Metadata)
 }
 
 func (m *moduleContext) PackageFile(installPath InstallPath, name string, srcPath Path) PackagingSpec {
 	fullInstallPath := installPath.Join(m, name)
-	return m.packageFile(fullInstallPath, srcPath, false)
+	return m.packageFile(fullInstallPath, srcPath, false, SkipReasonPackageOnly, nil)
 }
 
-func (m *moduleContext) packageFile(fullInstallPath InstallPath, srcPath Path, executable bool) PackagingSpec {
-	licenseFiles := m.Module().EffectiveLicenseFiles()
+func (m *moduleContext) packageFile(fullInstallPath InstallPath, srcPath Path, executable bool, skipReason SkipReason,
+	metadata *InstallFileMetadata) PackagingSpec {
+	licenseInfo, _ := ModuleProvider(m, LicenseInfoProvider)
+	licenseFiles := licenseInfo.LicenseTexts.Paths()
 	spec := PackagingSpec{
 		relPathInPackage:      Rel(m, fullInstallPath.PartitionDir(), fullInstallPath.String()),
 		srcPath:               srcPath,
@@ -474,20 +502,23 @@ func (m *moduleContext) packageFile(fullInstallPath InstallPath, srcPath Path, e
 		executable:            executable,
 		effectiveLicenseFiles: &licenseFiles,
 		partition:             fullInstallPath.partition,
+		skipReason:            skipReason,
+		metadata:              metadata,
 	}
 	m.packagingSpecs = append(m.packagingSpecs, spec)
 	return spec
 }
 
 func (m *moduleContext) installFile(installPath InstallPath, name string, srcPath Path, deps []InstallPath,
-	executable bool, hooks bool, extraZip *extraFilesZip) InstallPath {
+	executable bool, hooks bool, extraZip *extraFilesZip, metadata *InstallFileMetadata) InstallPath {
 
 	fullInstallPath := installPath.Join(m, name)
 	if hooks {
 		m.module.base().hooks.runInstallHooks(m, srcPath, fullInstallPath, false)
 	}
 
-	if !m.skipInstall() {
+	skipReason := m.installSkipReason()
+	if skipReason == SkipReasonNone {
 		deps = append(deps, InstallPaths(m.module.base().installFilesDepSet.ToList())...)
 		deps = append(deps, m.module.base().installedInitRcPaths...)
 		deps = append(deps, m.module.base().installedVintfFragmentsPaths...)
@@ -513,12 +544,19 @@ func (m *moduleContext) installFile(installPath InstallPath, name string, srcPat
 				orderOnlyDeps: orderOnlyDeps,
 				executable:    executable,
 				extraFiles:    extraZip,
+				ifChanged:     m.installIfChanged(),
 			})
 		} else {
 			rule := Cp
 			if executable {
 				rule = CpExecutable
 			}
+			if m.installIfChanged() {
+				rule = CpInstallIfChanged
+				if executable {
+					rule = CpExecutableInstallIfChanged
+				}
+			}
 
 			extraCmds := ""
 			if extraZip != nil {
@@ -545,13 +583,27 @@ func (m *moduleContext) installFile(installPath InstallPath, name string, srcPat
 		m.installFiles = append(m.installFiles, fullInstallPath)
 	}
 
-	m.packageFile(fullInstallPath, srcPath, executable)
+	m.packageFile(fullInstallPath, srcPath, executable, skipReason, metadata)
 
 	m.checkbuildFiles = append(m.checkbuildFiles, srcPath)
 
 	return fullInstallPath
 }
 
+// installIfChanged returns true if the module's install rules should only update installed files
+// whose contents have changed.
+func (m *moduleContext) installIfChanged() bool {
+	return proptools.Bool(m.module.base().commonProperties.Install_if_changed)
+}
+
+// symlinkRule returns the rule used to install symlinks for the module.
+func (m *moduleContext) symlinkRule() blueprint.Rule {
+	if m.installIfChanged() {
+		return SymlinkIfChanged
+	}
+	return Symlink
+}
+
 func (m *moduleContext) InstallSymlink(installPath InstallPath, name string, srcPath InstallPath) InstallPath {
 	fullInstallPath := installPath.Join(m, name)
 	m.module.base().hooks.runInstallHooks(m, srcPath, fullInstallPath, true)
@@ -560,15 +612,17 @@ func (m *moduleContext) InstallSymlink(installPath InstallPath, name string, src
 	if err != nil {
 		panic(fmt.Sprintf("Unable to generate symlink between %q and %q: %s", fullInstallPath.Base(), srcPath.Base(), err))
 	}
-	if !m.skipInstall() {
+	skipReason := m.installSkipReason()
+	if skipReason == SkipReasonNone {
 
 		if m.Config().KatiEnabled() {
 			// When creating the symlink rule in Soong but embedding in Make, write the rule to a
 			// makefile instead of directly to the ninja file so that main.mk can add the
 			// dependencies from the `required` property that are hard to resolve in Soong.
 			m.katiSymlinks = append(m.katiSymlinks, katiInstall{
-				from: srcPath,
-				to:   fullInstallPath,
+				from:      srcPath,
+				to:        fullInstallPath,
+				ifChanged: m.installIfChanged(),
 			})
 		} else {
 			// The symlink doesn't need updating when the target is modified, but we sometimes
@@ -576,7 +630,7 @@ func (m *moduleContext) InstallSymlink(installPath InstallPath, name string, src
 			// the mtime of the symlink must be updated when the binary is modified, so use a
 			// normal dependency here instead of an order-only dependency.
 			m.Build(pctx, BuildParams{
-				Rule:        Symlink,
+				Rule:        m.symlinkRule(),
 				Description: "install symlink " + fullInstallPath.Base(),
 				Output:      fullInstallPath,
 				Input:       srcPath,
@@ -589,6 +643,10 @@ func (m *moduleContext) InstallSymlink(installPath InstallPath, name string, src
 
 		m.installFiles = append(m.installFiles, fullInstallPath)
 		m.checkbuildFiles = append(m.checkbuildFiles, srcPath)
+		m.installedSymlinks = append(m.installedSymlinks, installedSymlink{
+			path:   fullInstallPath,
+			target: srcPath,
+		})
 	}
 
 	m.packagingSpecs = append(m.packagingSpecs, PackagingSpec{
@@ -597,6 +655,7 @@ func (m *moduleContext) InstallSymlink(installPath InstallPath, name string, src
 		symlinkTarget:    relPath,
 		executable:       false,
 		partition:        fullInstallPath.partition,
+		skipReason:       skipReason,
 	})
 
 	return fullInstallPath
@@ -608,18 +667,20 @@ func (m *moduleContext) InstallAbsoluteSymlink(installPath InstallPath, name str
 	fullInstallPath := installPath.Join(m, name)
 	m.module.base().hooks.runInstallHooks(m, nil, fullInstallPath, true)
 
-	if !m.skipInstall() {
+	skipReason := m.installSkipReason()
+	if skipReason == SkipReasonNone {
 		if m.Config().KatiEnabled() {
 			// When creating the symlink rule in Soong but embedding in Make, write the rule to a
 			// makefile instead of directly to the ninja file so that main.mk can add the
 			// dependencies from the `required` property that are hard to resolve in Soong.
 			m.katiSymlinks = append(m.katiSymlinks, katiInstall{
-				absFrom: absPath,
-				to:      fullInstallPath,
+				absFrom:   absPath,
+				to:        fullInstallPath,
+				ifChanged: m.installIfChanged(),
 			})
 		} else {
 			m.Build(pctx, BuildParams{
-				Rule:        Symlink,
+				Rule:        m.symlinkRule(),
 				Description: "install symlink " + fullInstallPath.Base() + " -> " + absPath,
 				Output:      fullInstallPath,
 				Default:     !m.Config().KatiEnabled(),
@@ -630,6 +691,10 @@ func (m *moduleContext) InstallAbsoluteSymlink(installPath InstallPath, name str
 		}
 
 		m.installFiles = append(m.installFiles, fullInstallPath)
+		m.installedSymlinks = append(m.installedSymlinks, installedSymlink{
+			path:      fullInstallPath,
+			absTarget: absPath,
+		})
 	}
 
 	m.packagingSpecs = append(m.packagingSpecs, PackagingSpec{
@@ -638,6 +703,7 @@ func (m *moduleContext) InstallAbsoluteSymlink(installPath InstallPath, name str
 		symlinkTarget:    absPath,
 		executable:       false,
 		partition:        fullInstallPath.partition,
+		skipReason:       skipReason,
 	})
 
 	return fullInstallPath
@@ -649,7 +715,7 @@ func (m *moduleContext) InstallTestData(installPath InstallPath, data []DataPath
 	ret := make(InstallPaths, 0, len(data))
 	for _, d := range data {
 		relPath := d.ToRelativeInstallPath()
-		installed := m.installFile(installPath, relPath, d.SrcPath, nil, false, false, nil)
+		installed := m.installFile(installPath, relPath, d.SrcPath, nil, false, false, nil, nil)
 		ret = append(ret, installed)
 	}
 
@@ -677,6 +743,10 @@ func (m *moduleContext) ModuleInfoJSON() *ModuleInfoJSON {
 	return moduleInfoJSON
 }
 
+func (m *moduleContext) IncrementMetric(name string, delta int) {
+	getModuleCounters(m.Config()).increment(m.ModuleType(), name, delta)
+}
+
 // Returns a list of paths expanded from globs and modules referenced using ":module" syntax.  The property must
 // be tagged with `android:"path" to support automatic source module dependency resolution.
 //
@@ -0,0 +1,148 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+
+	soong_metrics_proto "android/soong/ui/metrics/metrics_proto"
+)
+
+// Modules report build health counters, e.g. the number of uses of deprecated APIs that they
+// found, with ModuleContext.IncrementMetric.  The counters are summed per module type and across
+// all module types, and written to the module_counters field of soong_build_metrics.pb and to
+// soong_build_module_counters.json in LOG_DIR.
+
+// maxModuleCounterNames is the maximum number of distinct counter names, to bound the size of
+// soong_build_metrics.pb.  Increments of counters with other names are dropped, and the number of
+// dropped names is reported in the moduleCountersDroppedName counter of the "all" group.
+const maxModuleCounterNames = 100
+
+const (
+	// moduleCountersAllGroup is the name of the group that sums the counters across module types.
+	moduleCountersAllGroup = "all"
+	// moduleCountersDroppedName is the name of the counter of the names that were dropped because
+	// there were more than maxModuleCounterNames of them.
+	moduleCountersDroppedName = "dropped_counter_names"
+)
+
+// moduleCounters collects the counters reported by modules.  It is shared by all modules, whose
+// GenerateAndroidBuildActions run in parallel.
+type moduleCounters struct {
+	lock sync.Mutex
+	// The counters of each module type, by module type and then by name.
+	byModuleType map[string]map[string]int64
+	names        map[string]bool
+	dropped      map[string]bool
+}
+
+var moduleCountersKey = NewOnceKey("moduleCounters")
+
+func getModuleCounters(config Config) *moduleCounters {
+	return config.Once(moduleCountersKey, func() interface{} {
+		return &moduleCounters{
+			byModuleType: make(map[string]map[string]int64),
+			names:        make(map[string]bool),
+			dropped:      make(map[string]bool),
+		}
+	}).(*moduleCounters)
+}
+
+// increment adds delta to the counter of the module type.
+func (c *moduleCounters) increment(moduleType, name string, delta int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if !c.names[name] {
+		if len(c.names) >= maxModuleCounterNames {
+			c.dropped[name] = true
+			return
+		}
+		c.names[name] = true
+	}
+	counters := c.byModuleType[moduleType]
+	if counters == nil {
+		counters = make(map[string]int64)
+		c.byModuleType[moduleType] = counters
+	}
+	counters[name] += int64(delta)
+}
+
+// totals returns the counters of each module type, and the "all" group that sums them, by group
+// and then by name.
+func (c *moduleCounters) totals() map[string]map[string]int64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if len(c.names) == 0 {
+		return nil
+	}
+	all := make(map[string]int64)
+	ret := map[string]map[string]int64{moduleCountersAllGroup: all}
+	for moduleType, counters := range c.byModuleType {
+		group := make(map[string]int64, len(counters))
+		for name, value := range counters {
+			group[name] = value
+			all[name] += value
+		}
+		ret[moduleType] = group
+	}
+	if len(c.dropped) > 0 {
+		all[moduleCountersDroppedName] = int64(len(c.dropped))
+	}
+	return ret
+}
+
+// moduleCounterGroups returns the counters reported by modules as one PerfCounterGroup per module
+// type, sorted by module type, followed by the "all" group.
+func moduleCounterGroups(config Config) []*soong_metrics_proto.PerfCounterGroup {
+	totals := getModuleCounters(config).totals()
+	var ret []*soong_metrics_proto.PerfCounterGroup
+	toGroup := func(name string) *soong_metrics_proto.PerfCounterGroup {
+		group := &soong_metrics_proto.PerfCounterGroup{Name: proto.String(name)}
+		for _, counter := range SortedKeys(totals[name]) {
+			group.Counters = append(group.Counters, &soong_metrics_proto.PerfCounter{
+				Name:  proto.String(counter),
+				Value: proto.Int64(totals[name][counter]),
+			})
+		}
+		return group
+	}
+	for _, moduleType := range SortedKeys(totals) {
+		if moduleType != moduleCountersAllGroup {
+			ret = append(ret, toGroup(moduleType))
+		}
+	}
+	if totals != nil {
+		ret = append(ret, toGroup(moduleCountersAllGroup))
+	}
+	return ret
+}
+
+// writeModuleCountersJson writes the counters reported by modules to file as a JSON object of
+// counters by group and then by name.
+func writeModuleCountersJson(config Config, file string) error {
+	totals := getModuleCounters(config).totals()
+	if totals == nil {
+		totals = map[string]map[string]int64{}
+	}
+	data, err := json.MarshalIndent(totals, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(absolutePath(file), data, 0666)
+}
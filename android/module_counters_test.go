@@ -0,0 +1,122 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type counterTestModule struct {
+	ModuleBase
+	properties struct {
+		Counters []string
+	}
+}
+
+func counterTestModuleFactory() Module {
+	m := &counterTestModule{}
+	m.AddProperties(&m.properties)
+	InitAndroidModule(m)
+	return m
+}
+
+func (m *counterTestModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+	for _, counter := range m.properties.Counters {
+		ctx.IncrementMetric(counter, 2)
+	}
+}
+
+var prepareForModuleCountersTest = FixtureRegisterWithContext(func(ctx RegistrationContext) {
+	ctx.RegisterModuleType("counter_a", counterTestModuleFactory)
+	ctx.RegisterModuleType("counter_b", counterTestModuleFactory)
+})
+
+// moduleCountersForTest returns the module counters of the proto as a map by group and then by name.
+func moduleCountersForTest(config Config) map[string]map[string]int64 {
+	ret := make(map[string]map[string]int64)
+	for _, group := range moduleCounterGroups(config) {
+		counters := make(map[string]int64)
+		for _, counter := range group.Counters {
+			counters[counter.GetName()] = counter.GetValue()
+		}
+		ret[group.GetName()] = counters
+	}
+	return ret
+}
+
+func TestModuleCounters(t *testing.T) {
+	result := prepareForModuleCountersTest.RunTestWithBp(t, `
+		counter_a {
+			name: "foo",
+			counters: ["files_processed", "deprecated_apis"],
+		}
+		counter_a {
+			name: "bar",
+			counters: ["files_processed"],
+		}
+		counter_b {
+			name: "baz",
+			counters: ["files_processed"],
+		}
+	`)
+
+	expected := map[string]map[string]int64{
+		"counter_a": {"files_processed": 4, "deprecated_apis": 2},
+		"counter_b": {"files_processed": 2},
+		"all":       {"files_processed": 6, "deprecated_apis": 2},
+	}
+	AssertDeepEquals(t, "module counters", expected, moduleCountersForTest(result.Config))
+
+	groups := moduleCounterGroups(result.Config)
+	AssertStringEquals(t, "last group", "all", groups[len(groups)-1].GetName())
+
+	file := filepath.Join(t.TempDir(), "soong_build_module_counters.json")
+	if err := writeModuleCountersJson(result.Config, file); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actual map[string]map[string]int64
+	if err := json.Unmarshal(data, &actual); err != nil {
+		t.Fatal(err)
+	}
+	AssertDeepEquals(t, "soong_build_module_counters.json", expected, actual)
+}
+
+func TestModuleCountersNameLimit(t *testing.T) {
+	result := prepareForModuleCountersTest.RunTestWithBp(t, `
+		counter_a {
+			name: "foo",
+		}
+	`)
+
+	counters := getModuleCounters(result.Config)
+	for i := 0; i < maxModuleCounterNames+2; i++ {
+		counters.increment("counter_a", fmt.Sprintf("counter_%03d", i), 1)
+	}
+	// Counters whose names are already known are still incremented.
+	counters.increment("counter_b", "counter_000", 1)
+
+	all := moduleCountersForTest(result.Config)["all"]
+	AssertIntEquals(t, "number of counters", maxModuleCounterNames+1, len(all))
+	AssertDeepEquals(t, "known counter", int64(2), all["counter_000"])
+	AssertDeepEquals(t, "dropped names", int64(2), all[moduleCountersDroppedName])
+}
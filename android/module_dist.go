@@ -0,0 +1,116 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/google/blueprint"
+)
+
+// Each module that has dists gets a <module>-dist phony target, which copies just the dist files of
+// the module, for any goal, to the dist directory, without building the global dist goal.  The files
+// are staged in out/soong/dist first, and then copied to $DIST_DIR together.
+
+var distToDistDir = pctx.AndroidStaticRule("distToDistDir",
+	blueprint.RuleParams{
+		Command: `for f in $dests; do ` +
+			`mkdir -p "$distDir/$$(dirname $$f)" && cp -f "$stagingDir/$$f" "$distDir/$$f" || exit 1; ` +
+			`done && touch $out`,
+		Description: "dist $out",
+	},
+	"stagingDir", "distDir", "dests")
+
+// moduleDistCopies collects the dist copies of all variants of each module for its -dist phony
+// target.
+type moduleDistCopies struct {
+	// The copies of each module and the module, keyed by the name of its -dist phony target.
+	copies  map[string][]distCopy
+	modules map[string]blueprint.Module
+}
+
+func newModuleDistCopies() *moduleDistCopies {
+	return &moduleDistCopies{
+		copies:  make(map[string][]distCopy),
+		modules: make(map[string]blueprint.Module),
+	}
+}
+
+// add adds the copies in the contributions of the module variant to the copies of the module.
+func (c *moduleDistCopies) add(ctx SingletonContext, mod blueprint.Module, contributions *distContributions) {
+	phony := ctx.FinalModule(mod.(Module)).base().phonyPrefix + ctx.ModuleName(mod) + "-dist"
+	c.modules[phony] = mod
+	for _, copies := range contributions.copiesForGoals {
+		c.copies[phony] = append(c.copies[phony], copies.copies...)
+	}
+}
+
+// build creates the -dist phony target of each module, and the rules that copy the files to the
+// dist staging directory and from there to $DIST_DIR.  copied are the copies to the dist staging
+// directory that are already made for other reasons, keyed by their destination, which are built
+// here as well.  A copy of a module to a destination that another file is already copied to is left
+// out of its phony target.
+func (c *moduleDistCopies) build(ctx SingletonContext, copied map[string]Path) {
+	if copied == nil {
+		copied = make(map[string]Path)
+	}
+	distDir := PathForOutput(ctx, "dist")
+	realDistDir := ctx.Config().GetenvWithDefault("DIST_DIR", filepath.Join(ctx.Config().OutDir(), "dist"))
+	for _, phony := range SortedKeys(c.copies) {
+		var dests []string
+		for _, cp := range c.copies[phony] {
+			if from, ok := copied[cp.dest]; !ok {
+				copied[cp.dest] = cp.from
+			} else if from.String() != cp.from.String() {
+				ctx.ModuleWarningf(c.modules[phony], "dist of %s to %q is left out of %s as %s is copied there",
+					cp.from, cp.dest, phony, from)
+				continue
+			}
+			dests = append(dests, cp.dest)
+		}
+		dests = FirstUniqueStrings(dests)
+		if len(dests) == 0 {
+			ctx.Phony(phony)
+			continue
+		}
+
+		var staged Paths
+		for _, dest := range dests {
+			staged = append(staged, distDir.Join(ctx, dest))
+		}
+		// $DIST_DIR is in the command, so the files are copied again when it changes.
+		stamp := PathForOutput(ctx, "module_dist", phony+".stamp")
+		ctx.Build(pctx, BuildParams{
+			Rule:      distToDistDir,
+			Output:    stamp,
+			Implicits: staged,
+			Args: map[string]string{
+				"stagingDir": distDir.String(),
+				"distDir":    realDistDir,
+				"dests":      strings.Join(dests, " "),
+			},
+		})
+		ctx.Phony(phony, stamp)
+	}
+
+	for _, dest := range SortedKeys(copied) {
+		ctx.Build(pctx, BuildParams{
+			Rule:   Cp,
+			Input:  copied[dest],
+			Output: distDir.Join(ctx, dest),
+		})
+	}
+}
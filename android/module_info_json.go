@@ -13,10 +13,13 @@ type CoreModuleInfoJSON struct {
 	Path               []string `json:"path,omitempty"`                // $(sort $(ALL_MODULES.$(m).PATH))
 	Installed          []string `json:"installed,omitempty"`           // $(sort $(ALL_MODULES.$(m).INSTALLED))
 	ModuleName         string   `json:"module_name,omitempty"`         // $(ALL_MODULES.$(m).MODULE_NAME)
+	StableId           string   `json:"stable_id,omitempty"`           // Not set by Make
 	SupportedVariants  []string `json:"supported_variants,omitempty"`  // $(sort $(ALL_MODULES.$(m).SUPPORTED_VARIANTS))
 	HostDependencies   []string `json:"host_dependencies,omitempty"`   // $(sort $(ALL_MODULES.$(m).HOST_REQUIRED_FROM_TARGET))
 	TargetDependencies []string `json:"target_dependencies,omitempty"` // $(sort $(ALL_MODULES.$(m).TARGET_REQUIRED_FROM_HOST))
 	Data               []string `json:"data,omitempty"`                // $(sort $(ALL_MODULES.$(m).TEST_DATA))
+	LicenseKinds       []string `json:"license_kinds,omitempty"`       // Not set by Make, only with Module_info_licenses
+	LicenseConditions  []string `json:"license_conditions,omitempty"`  // Not set by Make, only with Module_info_licenses
 }
 
 type ModuleInfoJSON struct {
@@ -77,6 +80,8 @@ func encodeModuleInfoJSON(w io.Writer, moduleInfoJSON *ModuleInfoJSON) error {
 	sortAndUnique(&moduleInfoJSONCopy.core.HostDependencies)
 	sortAndUnique(&moduleInfoJSONCopy.core.TargetDependencies)
 	sortAndUnique(&moduleInfoJSONCopy.core.Data)
+	sortAndUnique(&moduleInfoJSONCopy.core.LicenseKinds)
+	sortAndUnique(&moduleInfoJSONCopy.core.LicenseConditions)
 
 	sortAndUnique(&moduleInfoJSONCopy.Class)
 	sortAndUnique(&moduleInfoJSONCopy.Tags)
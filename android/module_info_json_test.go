@@ -0,0 +1,185 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/blueprint/proptools"
+)
+
+type moduleInfoJSONTestModule struct {
+	ModuleBase
+
+	properties struct {
+		Test_options CommonTestOptions
+	}
+}
+
+func moduleInfoJSONTestModuleFactory() Module {
+	module := &moduleInfoJSONTestModule{}
+	module.AddProperties(&module.properties)
+	InitAndroidArchModule(module, HostAndDeviceSupported, MultilibCommon)
+	return module
+}
+
+func (m *moduleInfoJSONTestModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+	moduleInfoJSON := ctx.ModuleInfoJSON()
+	moduleInfoJSON.Class = []string{"NATIVE_TESTS"}
+	m.properties.Test_options.SetModuleInfoJSON(moduleInfoJSON)
+}
+
+func TestModuleInfoJSONTestOptions(t *testing.T) {
+	bp := `
+		test_options_module {
+			name: "foo",
+			test_options: {
+				unit_test: true,
+				tags: ["b", "a", "b"],
+			},
+		}
+
+		test_options_module {
+			name: "bar",
+		}
+	`
+
+	result := GroupFixturePreparers(
+		PrepareForTestWithArchMutator,
+		FixtureRegisterWithContext(func(ctx RegistrationContext) {
+			ctx.RegisterModuleType("test_options_module", moduleInfoJSONTestModuleFactory)
+		}),
+	).RunTestWithBp(t, bp)
+
+	encode := func(name string) string {
+		t.Helper()
+		module := result.ModuleForTests(name, "android_common").Module()
+		moduleInfoJSON, ok := SingletonModuleProvider(result, module, ModuleInfoJSONProvider)
+		if !ok {
+			t.Fatalf("missing ModuleInfoJSONProvider for %q", name)
+		}
+		buf := &strings.Builder{}
+		if err := encodeModuleInfoJSON(buf, moduleInfoJSON); err != nil {
+			t.Fatal(err)
+		}
+		return strings.TrimSpace(buf.String())
+	}
+
+	AssertStringEquals(t, "foo module-info.json",
+		`{"path":["."],"module_name":"foo","supported_variants":["DEVICE"],"class":["NATIVE_TESTS"],"is_unit_test":true,"test_options_tags":["a","b"]}`,
+		encode("foo"))
+	AssertStringEquals(t, "bar module-info.json",
+		`{"path":["."],"module_name":"bar","supported_variants":["DEVICE"],"class":["NATIVE_TESTS"]}`,
+		encode("bar"))
+}
+
+// moduleInfoJSONExemptTestModule is a moduleInfoJSONTestModule that is exempt from licenses.
+type moduleInfoJSONExemptTestModule struct {
+	moduleInfoJSONTestModule
+}
+
+func (m *moduleInfoJSONExemptTestModule) exemptFromLicenses() bool {
+	return true
+}
+
+func moduleInfoJSONExemptTestModuleFactory() Module {
+	module := &moduleInfoJSONExemptTestModule{}
+	module.AddProperties(&module.properties)
+	InitAndroidArchModule(module, HostAndDeviceSupported, MultilibCommon)
+	return module
+}
+
+func TestModuleInfoJSONLicenses(t *testing.T) {
+	bp := `
+		license_kind {
+			name: "notice_kind",
+			conditions: ["notice"],
+		}
+
+		license_kind {
+			name: "restricted_kind",
+			conditions: ["restricted", "notice"],
+		}
+
+		license {
+			name: "foo_license",
+			license_kinds: ["restricted_kind", "notice_kind"],
+		}
+
+		test_options_module {
+			name: "foo",
+			licenses: ["foo_license"],
+		}
+
+		exempt_test_options_module {
+			name: "exempt",
+			licenses: ["foo_license"],
+		}
+	`
+
+	encode := func(t *testing.T, result *TestResult, name string) string {
+		t.Helper()
+		module := result.ModuleForTests(name, "android_common").Module()
+		moduleInfoJSON, ok := SingletonModuleProvider(result, module, ModuleInfoJSONProvider)
+		if !ok {
+			t.Fatalf("missing ModuleInfoJSONProvider for %q", name)
+		}
+		buf := &strings.Builder{}
+		if err := encodeModuleInfoJSON(buf, moduleInfoJSON); err != nil {
+			t.Fatal(err)
+		}
+		return strings.TrimSpace(buf.String())
+	}
+
+	for _, tc := range []struct {
+		name     string
+		enabled  bool
+		expected string
+	}{
+		{
+			name:     "disabled",
+			expected: `{"path":["."],"module_name":"foo","supported_variants":["DEVICE"],"class":["NATIVE_TESTS"]}`,
+		},
+		{
+			name:    "enabled",
+			enabled: true,
+			expected: `{"path":["."],"module_name":"foo","supported_variants":["DEVICE"],` +
+				`"license_kinds":["notice_kind","restricted_kind"],"license_conditions":["notice","restricted"],` +
+				`"class":["NATIVE_TESTS"]}`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			result := GroupFixturePreparers(
+				PrepareForTestWithArchMutator,
+				PrepareForTestWithLicenses,
+				FixtureRegisterWithContext(func(ctx RegistrationContext) {
+					ctx.RegisterModuleType("test_options_module", moduleInfoJSONTestModuleFactory)
+					ctx.RegisterModuleType("exempt_test_options_module", moduleInfoJSONExemptTestModuleFactory)
+				}),
+				FixtureModifyProductVariables(func(variables FixtureProductVariables) {
+					variables.Module_info_licenses = proptools.BoolPtr(tc.enabled)
+				}),
+			).RunTestWithBp(t, bp)
+
+			AssertStringEquals(t, "foo module-info.json", tc.expected, encode(t, result, "foo"))
+
+			// Modules that are exempt from licenses never get the license fields.
+			AssertStringEquals(t, "exempt module-info.json",
+				`{"path":["."],"module_name":"exempt","supported_variants":["DEVICE"],"class":["NATIVE_TESTS"]}`,
+				encode(t, result, "exempt"))
+		})
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"fmt"
+)
+
+// The owner property is free-form text, but it is used as LOCAL_MODULE_OWNER to partition the
+// modules by vendor.  When the ModuleOwnersAllowlist product variable is set, the owner of every
+// module and the default_owner of every package must be in it, and misspelled owners are reported
+// along with the similar allowed owners.  When ModuleOwnersAllowlistReportOnly is set the errors
+// become warnings.
+
+// Registers the mutator that checks owners against the ModuleOwnersAllowlist.
+func RegisterModuleOwnersChecker(ctx RegisterMutatorsContext) {
+	ctx.BottomUp("moduleOwnersChecker", moduleOwnersChecker).Parallel()
+}
+
+// PrepareForTestWithModuleOwnersChecker registers the mutator that checks owners against the
+// ModuleOwnersAllowlist.
+var PrepareForTestWithModuleOwnersChecker = FixtureRegisterWithContext(func(ctx RegistrationContext) {
+	ctx.PreArchMutators(RegisterModuleOwnersChecker)
+})
+
+func moduleOwnersChecker(ctx BottomUpMutatorContext) {
+	allowlist := ctx.DeviceConfig().ModuleOwnersAllowlist()
+	if len(allowlist) == 0 {
+		return
+	}
+
+	var property string
+	var owner *string
+	if p, ok := ctx.Module().(*packageModule); ok {
+		property, owner = "default_owner", p.properties.Default_owner
+	} else if m, ok := ctx.Module().(Module); ok {
+		property, owner = "owner", m.base().commonProperties.Owner
+	}
+	if owner == nil || InList(*owner, allowlist) {
+		return
+	}
+
+	msg := fmt.Sprintf("%q is not in ModuleOwnersAllowlist", *owner)
+	if suggestions := similarNames(*owner, allowlist); len(suggestions) > 0 {
+		msg += fmt.Sprintf(", did you mean %q?", suggestions)
+	}
+	if ctx.DeviceConfig().ModuleOwnersAllowlistReportOnly() {
+		ctx.PropertyWarningf(property, "%s", msg)
+	} else {
+		ctx.PropertyErrorf(property, "%s", msg)
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"testing"
+
+	"github.com/google/blueprint/proptools"
+)
+
+var prepareForModuleOwnersTest = GroupFixturePreparers(
+	PrepareForTestWithPackageModule,
+	PrepareForTestWithModuleOwnersChecker,
+	FixtureRegisterWithContext(func(ctx RegistrationContext) {
+		ctx.RegisterModuleType("fake", fakeModuleFactory)
+	}),
+	FixtureAddTextFile("vendor/acme/Android.bp", `
+		package {
+			default_owner: "acme",
+		}
+		fake {
+			name: "default_owner",
+		}
+		fake {
+			name: "valid_owner",
+			owner: "initech",
+		}
+	`),
+)
+
+func withModuleOwnersAllowlist(reportOnly bool, owners ...string) FixturePreparer {
+	return FixtureModifyProductVariables(func(variables FixtureProductVariables) {
+		variables.ModuleOwnersAllowlist = owners
+		variables.ModuleOwnersAllowlistReportOnly = proptools.BoolPtr(reportOnly)
+	})
+}
+
+func TestModuleOwnersUnconfigured(t *testing.T) {
+	GroupFixturePreparers(
+		prepareForModuleOwnersTest,
+		FixtureAddTextFile("vendor/other/Android.bp", `
+			fake {
+				name: "misspelled_owner",
+				owner: "Initech",
+			}
+		`),
+	).RunTest(t)
+}
+
+func TestModuleOwnersValid(t *testing.T) {
+	GroupFixturePreparers(
+		prepareForModuleOwnersTest,
+		withModuleOwnersAllowlist(false, "acme", "initech"),
+	).RunTest(t)
+}
+
+func TestModuleOwnersInvalid(t *testing.T) {
+	GroupFixturePreparers(
+		prepareForModuleOwnersTest,
+		withModuleOwnersAllowlist(false, "acme", "initech", "umbrella"),
+		FixtureAddTextFile("vendor/other/Android.bp", `
+			package {
+				default_owner: "unknown_vendor",
+			}
+			fake {
+				name: "misspelled_owner",
+				owner: "Initech",
+			}
+		`),
+	).ExtendWithErrorHandler(FixtureExpectsAllErrorsToMatchAPattern([]string{
+		`module "misspelled_owner".*: owner: "Initech" is not in ModuleOwnersAllowlist, did you mean \["initech"\]\?`,
+		`module "//vendor/other".*: default_owner: "unknown_vendor" is not in ModuleOwnersAllowlist`,
+	})).RunTest(t)
+}
+
+func TestModuleOwnersReportOnly(t *testing.T) {
+	result := GroupFixturePreparers(
+		prepareForModuleOwnersTest,
+		withModuleOwnersAllowlist(true, "acme", "initech"),
+		FixtureAddTextFile("vendor/other/Android.bp", `
+			fake {
+				name: "misspelled_owner",
+				owner: "Initech",
+			}
+		`),
+	).RunTest(t)
+
+	AssertDeepEquals(t, "warnings", []BuildWarning{{
+		File:     "vendor/other/Android.bp",
+		Module:   "misspelled_owner",
+		Property: "owner",
+		Message:  `"Initech" is not in ModuleOwnersAllowlist, did you mean ["initech"]?`,
+	}}, result.Config.BuildWarnings())
+}
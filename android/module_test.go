@@ -15,6 +15,7 @@
 package android
 
 import (
+	"fmt"
 	"path/filepath"
 	"runtime"
 	"testing"
@@ -245,6 +246,102 @@ func TestErrorDependsOnDisabledModule(t *testing.T) {
 		RunTestWithBp(t, bp)
 }
 
+type interfaceDependencyTag struct {
+	blueprint.BaseDependencyTag
+}
+
+func (interfaceDependencyTag) InterfaceDependency() bool { return true }
+
+// interfaceModule supports provide_interface_when_disabled, and depends on the modules in
+// interface_deps only through their interface.
+type interfaceModule struct {
+	ModuleBase
+	props struct {
+		Deps           []string
+		Interface_deps []string
+	}
+
+	visitedDeps    []string
+	interfaceBuilt bool
+}
+
+func (m *interfaceModule) DepsMutator(ctx BottomUpMutatorContext) {
+	ctx.AddDependency(ctx.Module(), installDepTag{}, m.props.Deps...)
+	ctx.AddDependency(ctx.Module(), interfaceDependencyTag{}, m.props.Interface_deps...)
+}
+
+func (m *interfaceModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+	ctx.VisitDirectDeps(func(dep Module) {
+		m.visitedDeps = append(m.visitedDeps, ctx.OtherModuleName(dep))
+	})
+}
+
+func (m *interfaceModule) GenerateInterfaceBuildActions(ctx ModuleContext) {
+	m.interfaceBuilt = true
+}
+
+func interfaceModuleFactory() Module {
+	m := &interfaceModule{}
+	m.AddProperties(&m.props)
+	InitAndroidModule(m)
+	return m
+}
+
+var prepareForInterfaceWhenDisabledTests = GroupFixturePreparers(
+	prepareForModuleTests,
+	FixtureRegisterWithContext(func(ctx RegistrationContext) {
+		ctx.RegisterModuleType("interface_module", interfaceModuleFactory)
+	}),
+)
+
+func TestInterfaceDependencyOnDisabledModule(t *testing.T) {
+	result := prepareForInterfaceWhenDisabledTests.RunTestWithBp(t, `
+		interface_module {
+			name: "foo",
+			interface_deps: ["bar"],
+		}
+		interface_module {
+			name: "bar",
+			enabled: false,
+			provide_interface_when_disabled: true,
+		}
+	`)
+
+	foo := result.ModuleForTests("foo", "").Module().(*interfaceModule)
+	AssertDeepEquals(t, "visited deps of foo", []string{"bar"}, foo.visitedDeps)
+	bar := result.ModuleForTests("bar", "").Module().(*interfaceModule)
+	AssertBoolEquals(t, "interface of bar is built", true, bar.interfaceBuilt)
+}
+
+func TestErrorImplementationDependencyOnDisabledModule(t *testing.T) {
+	prepareForInterfaceWhenDisabledTests.
+		ExtendWithErrorHandler(FixtureExpectsAtLeastOneErrorMatchingPattern(
+			`module "foo": depends on disabled module "bar" through a dependency that uses more than its interface`)).
+		RunTestWithBp(t, `
+			interface_module {
+				name: "foo",
+				deps: ["bar"],
+			}
+			interface_module {
+				name: "bar",
+				enabled: false,
+				provide_interface_when_disabled: true,
+			}
+		`)
+}
+
+func TestErrorProvideInterfaceWhenDisabledNotSupported(t *testing.T) {
+	prepareForModuleTests.
+		ExtendWithErrorHandler(FixtureExpectsAtLeastOneErrorMatchingPattern(
+			`provide_interface_when_disabled: not supported by this module type`)).
+		RunTestWithBp(t, `
+			deps {
+				name: "foo",
+				provide_interface_when_disabled: false,
+			}
+		`)
+}
+
 func TestDistErrorChecking(t *testing.T) {
 	bp := `
 		deps {
@@ -399,6 +496,168 @@ func TestInstall(t *testing.T) {
 	assertOrderOnlys(symlinkRule("foo"))
 }
 
+func TestInstallIfChanged(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("requires linux")
+	}
+
+	testCases := []struct {
+		name        string
+		prop        string
+		installRule blueprint.Rule
+		symlinkRule blueprint.Rule
+	}{
+		{
+			name:        "default",
+			prop:        "",
+			installRule: Cp,
+			symlinkRule: Symlink,
+		},
+		{
+			name:        "disabled",
+			prop:        "install_if_changed: false,",
+			installRule: Cp,
+			symlinkRule: Symlink,
+		},
+		{
+			name:        "enabled",
+			prop:        "install_if_changed: true,",
+			installRule: CpInstallIfChanged,
+			symlinkRule: SymlinkIfChanged,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			bp := fmt.Sprintf(`
+				deps {
+					name: "foo",
+					%s
+				}
+			`, tc.prop)
+
+			result := GroupFixturePreparers(
+				prepareForModuleTests,
+				PrepareForTestWithArchMutator,
+			).RunTestWithBp(t, bp)
+
+			module := result.ModuleForTests("foo", "android_common")
+			install := module.Output("out/soong/target/product/test_device/system/foo")
+			symlink := module.Output("out/soong/target/product/test_device/system/symlinks/foo")
+
+			AssertStringEquals(t, "install rule", tc.installRule.String(), install.Rule.String())
+			AssertStringEquals(t, "symlink rule", tc.symlinkRule.String(), symlink.Rule.String())
+		})
+	}
+}
+
+func TestSelfReferencedRequired(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		bp       string
+		property string
+	}{
+		{
+			name: "required",
+			bp: `
+				test {
+					name: "foo",
+					required: ["bar", "foo"],
+				}
+			`,
+			property: "required",
+		},
+		{
+			name: "host_required",
+			bp: `
+				test {
+					name: "foo",
+					host_required: ["foo"],
+				}
+			`,
+			property: "host_required",
+		},
+		{
+			name: "required from defaults",
+			bp: `
+				defaults {
+					name: "foo_defaults",
+					required: ["foo"],
+				}
+
+				test {
+					name: "foo",
+					defaults: ["foo_defaults"],
+				}
+			`,
+			property: "required",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			GroupFixturePreparers(
+				prepareForDefaultsTest,
+				FixtureWithRootAndroidBp(tc.bp),
+			).ExtendWithErrorHandler(FixtureExpectsOneErrorPattern(
+				`module "foo": ` + tc.property + `: "foo" refers to the module itself`,
+			)).RunTest(t)
+		})
+	}
+}
+
+func TestInstallIfChangedKatiEnabled(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("requires linux")
+	}
+	bp := `
+		deps {
+			name: "foo",
+			install_if_changed: true,
+		}
+
+		deps {
+			name: "bar",
+		}
+	`
+
+	result := GroupFixturePreparers(
+		prepareForModuleTests,
+		PrepareForTestWithArchMutator,
+		FixtureModifyConfig(SetKatiEnabledForTests),
+		PrepareForTestWithMakevars,
+	).RunTestWithBp(t, bp)
+
+	rules := result.InstallMakeRulesForTesting(t)
+
+	recipeFor := func(output string) string {
+		t.Helper()
+		for _, rule := range rules {
+			if rule.Target == output {
+				return rule.Recipe
+			}
+		}
+		t.Fatalf("no make install rule for %s", output)
+		return ""
+	}
+
+	const device = "out/target/product/test_device/system"
+	AssertStringDoesContain(t, "foo install recipe",
+		recipeFor(device+"/foo"), "if ! cmp -s $< $@; then rm -f $@ && cp -f -d $< $@; fi")
+	AssertStringDoesContain(t, "foo symlink recipe",
+		recipeFor(device+"/symlinks/foo"), `if [ "$$(readlink $@)" != "../foo" ]; then`)
+	AssertStringDoesNotContain(t, "bar install recipe", recipeFor(device+"/bar"), "cmp -s")
+	AssertStringDoesNotContain(t, "bar symlink recipe", recipeFor(device+"/symlinks/bar"), "readlink")
+
+	// Kati restats the installed files and symlinks of foo, so that its dependents aren't rebuilt
+	// when the install rule leaves them alone.
+	var restat []string
+	for _, rule := range rules {
+		if rule.Target == ".KATI_RESTAT" {
+			restat = append(restat, rule.Deps...)
+		}
+	}
+	AssertArrayString(t, ".KATI_RESTAT", []string{device + "/foo", device + "/symlinks/foo"}, restat)
+}
+
 func TestInstallKatiEnabled(t *testing.T) {
 	if runtime.GOOS != "linux" {
 		t.Skip("requires linux")
@@ -1019,3 +1278,130 @@ func TestOutputFileForModule(t *testing.T) {
 		AssertArrayString(t, "expected missing deps", tt.missingDeps, ctx.missingDeps)
 	}
 }
+
+type outputFilesTestModule struct {
+	ModuleBase
+	output Path
+}
+
+func (m *outputFilesTestModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+	m.output = PathForModuleOut(ctx, "out.txt")
+	WriteFileRule(ctx, m.output, "")
+}
+
+func (m *outputFilesTestModule) OutputFiles(tag string) (Paths, error) {
+	if tag != "" {
+		return nil, fmt.Errorf("unsupported tag %q", tag)
+	}
+	return Paths{m.output}, nil
+}
+
+func outputFilesTestModuleFactory() Module {
+	module := &outputFilesTestModule{}
+	InitAndroidModule(module)
+	return module
+}
+
+// vendorSingletonContext is a context type of a singleton outside of this package, which only
+// implements PathContext and reports errors through Errorf.
+type vendorSingletonContext struct {
+	config Config
+	errs   []string
+}
+
+func (c *vendorSingletonContext) Config() Config                  { return c.config }
+func (c *vendorSingletonContext) AddNinjaFileDeps(deps ...string) {}
+func (c *vendorSingletonContext) Errorf(format string, args ...interface{}) {
+	c.errs = append(c.errs, fmt.Sprintf(format, args...))
+}
+
+type outputFilesTestSingleton struct {
+	outputs []string
+	errs    []string
+}
+
+func (s *outputFilesTestSingleton) GenerateBuildActions(ctx SingletonContext) {
+	vendorCtx := &vendorSingletonContext{config: ctx.Config()}
+	ctx.VisitAllModules(func(module Module) {
+		if _, ok := module.(*outputFilesTestModule); !ok {
+			return
+		}
+		s.outputs = append(s.outputs, OutputFilesForModule(ctx, module, "").Strings()...)
+		s.outputs = append(s.outputs, OutputFilesForModule(vendorCtx, module, "").Strings()...)
+		OutputFilesForModule(vendorCtx, module, ".unknown")
+	})
+	s.errs = vendorCtx.errs
+}
+
+func TestOutputFilesForModuleFromSingleton(t *testing.T) {
+	singleton := &outputFilesTestSingleton{}
+	result := GroupFixturePreparers(
+		FixtureRegisterWithContext(func(ctx RegistrationContext) {
+			ctx.RegisterModuleType("output_files_test_module", outputFilesTestModuleFactory)
+			ctx.RegisterParallelSingletonType("output_files_test_singleton", func() Singleton {
+				return singleton
+			})
+		}),
+	).RunTestWithBp(t, `
+		output_files_test_module {
+			name: "foo",
+		}
+	`)
+
+	AssertStringPathsRelativeToTopEquals(t, "outputs", result.Config, []string{
+		"out/soong/.intermediates/foo/out.txt",
+		"out/soong/.intermediates/foo/out.txt",
+	}, singleton.outputs)
+	AssertArrayString(t, "errors", []string{
+		`failed to get output file from module "foo": unsupported tag ".unknown"`,
+	}, singleton.errs)
+}
+
+type outputFilesTestDepTag struct {
+	blueprint.BaseDependencyTag
+}
+
+type outputFilesTestUserModule struct {
+	ModuleBase
+	props struct {
+		Deps []string
+	}
+}
+
+func (m *outputFilesTestUserModule) DepsMutator(ctx BottomUpMutatorContext) {
+	ctx.AddDependency(ctx.Module(), outputFilesTestDepTag{}, m.props.Deps...)
+}
+
+func (m *outputFilesTestUserModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+	ctx.VisitDirectDepsWithTag(outputFilesTestDepTag{}, func(dep Module) {
+		OutputFilesForModule(ctx, dep, ".unknown")
+	})
+}
+
+func outputFilesTestUserModuleFactory() Module {
+	module := &outputFilesTestUserModule{}
+	module.AddProperties(&module.props)
+	InitAndroidModule(module)
+	return module
+}
+
+func TestOutputFilesForModuleFromModule(t *testing.T) {
+	GroupFixturePreparers(
+		FixtureRegisterWithContext(func(ctx RegistrationContext) {
+			ctx.RegisterModuleType("output_files_test_module", outputFilesTestModuleFactory)
+			ctx.RegisterModuleType("output_files_test_user", outputFilesTestUserModuleFactory)
+		}),
+	).
+		ExtendWithErrorHandler(FixtureExpectsAllErrorsToMatchAPattern([]string{
+			`module "bar": failed to get output file from module "foo": unsupported tag "\.unknown"`,
+		})).
+		RunTestWithBp(t, `
+		output_files_test_module {
+			name: "foo",
+		}
+		output_files_test_user {
+			name: "bar",
+			deps: ["foo"],
+		}
+	`)
+}
@@ -0,0 +1,106 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"encoding/json"
+
+	"github.com/google/blueprint"
+)
+
+// The version of the schema of module_type_stats.json, incremented when fields are removed or
+// their meaning changes.
+const moduleTypeStatsSchemaVersion = 1
+
+// moduleTypeStats are the statistics of the modules of a module type.
+type moduleTypeStats struct {
+	// The number of modules, counting each module once regardless of its number of variants.
+	Modules int
+
+	// The number of variants of the modules.
+	Variants int
+
+	EnabledVariants  int
+	DisabledVariants int
+
+	// The number of variants that are exported to Make, and the number that are not, e.g. because
+	// they are disabled or set hide_from_make.
+	ExportedToMakeVariants int
+	HiddenFromMakeVariants int
+}
+
+// moduleTypeStatsFile is the contents of out/soong/module_type_stats.json.
+type moduleTypeStatsFile struct {
+	SchemaVersion int
+
+	// The statistics for each module type.  Maps are marshalled with sorted keys, so the output
+	// is deterministic.
+	ModuleTypes map[string]*moduleTypeStats
+}
+
+// moduleTypeStatsCollector accumulates the statistics of the module types as the modules are
+// visited.
+type moduleTypeStatsCollector map[string]*moduleTypeStats
+
+func (c moduleTypeStatsCollector) add(ctx SingletonContext, mod blueprint.Module) {
+	module, ok := mod.(Module)
+	if !ok {
+		return
+	}
+	moduleType := ctx.ModuleType(module)
+	stats := c[moduleType]
+	if stats == nil {
+		stats = &moduleTypeStats{}
+		c[moduleType] = stats
+	}
+
+	if ctx.PrimaryModule(module) == module {
+		stats.Modules++
+	}
+	stats.Variants++
+	if module.Enabled() {
+		stats.EnabledVariants++
+	} else {
+		stats.DisabledVariants++
+	}
+	if shouldSkipAndroidMkProcessing(module.base()) {
+		stats.HiddenFromMakeVariants++
+	} else {
+		stats.ExportedToMakeVariants++
+	}
+}
+
+// makeTypeStats returns the number of modules of each module type, for the
+// STATS.SOONG_MODULE_TYPE variables in the Android.mk file.
+func (c moduleTypeStatsCollector) makeTypeStats() map[string]int {
+	typeStats := make(map[string]int)
+	for moduleType, stats := range c {
+		typeStats[moduleType] = stats.Modules
+	}
+	return typeStats
+}
+
+// writeModuleTypeStats writes out/soong/module_type_stats.json.
+func writeModuleTypeStats(ctx SingletonContext, c moduleTypeStatsCollector) {
+	data, err := json.MarshalIndent(moduleTypeStatsFile{
+		SchemaVersion: moduleTypeStatsSchemaVersion,
+		ModuleTypes:   c,
+	}, "", "  ")
+	if err != nil {
+		ctx.Errorf("failed to marshal module type stats: %s", err)
+		return
+	}
+	WriteFileRule(ctx, PathForOutput(ctx, "module_type_stats.json"), string(data))
+}
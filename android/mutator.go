@@ -108,6 +108,15 @@ var preArch = []RegisterMutatorFunc{
 	// This must run before the defaults so that defaults modules can pick up the package default.
 	RegisterLicensesPackageMapper,
 
+	// Record the default_team and default_owner for each package.
+	RegisterPackageDefaultsMapper,
+
+	// Report the target property blocks for OSes that modules can never be built for.
+	//
+	// This must run before the defaults mutators so that blocks supplied by defaults modules, which
+	// may be shared between module types that support different OSes, are not reported.
+	RegisterOsPropertyBlocksChecker,
+
 	// Apply properties from defaults modules to the referencing modules.
 	//
 	// Any mutators that are added before this will not see any modules created by
@@ -140,11 +149,30 @@ var preArch = []RegisterMutatorFunc{
 	// in a defaults module has been successfully applied before the rules are gathered.
 	RegisterLicensesPropertyGatherer,
 
+	// Apply the package default_team and default_owner to modules that do not set their own.
+	//
+	// This must come after the defaults mutators so that a team or owner supplied by a defaults
+	// module takes precedence over the package default.
+	RegisterPackageDefaultsGatherer,
+
+	// Check the owner of modules and the default_owner of packages against the product's
+	// ModuleOwnersAllowlist.
+	//
+	// This must come after the defaults mutators so that an owner supplied by a defaults module is
+	// checked.
+	RegisterModuleOwnersChecker,
+
 	// Gather the visibility rules for all modules for us during visibility enforcement.
 	//
 	// This must come after the defaults mutators to ensure that any visibility supplied
 	// in a defaults module has been successfully applied before the rules are gathered.
 	RegisterVisibilityRuleGatherer,
+
+	// Disable the modules whose kernel_version_constraints are not satisfied by the board.
+	//
+	// This must come after the defaults mutators so that the constraints can be supplied by a
+	// defaults module.
+	RegisterKernelVersionConstraintsMutator,
 }
 
 func registerArchMutator(ctx RegisterMutatorsContext) {
@@ -784,7 +812,61 @@ func (b *bottomUpMutatorContext) AddFarVariationDependencies(variations []bluepr
 		panic("Adding deps not allowed after checking for missing deps")
 	}
 
-	return b.bp.AddFarVariationDependencies(variations, tag, names...)
+	if b.Config().AllowMissingDependencies() {
+		return b.bp.AddFarVariationDependencies(variations, tag, names...)
+	}
+
+	// Report dependencies on modules that lack the requested arch variant with a more helpful
+	// message than blueprint's variant mismatch error, keeping a nil entry for each of them.
+	deps := make([]blueprint.Module, 0, len(names))
+	for _, name := range names {
+		if b.checkArchVariantExists(variations, name) {
+			deps = append(deps, b.bp.AddFarVariationDependencies(variations, tag, name)...)
+		} else {
+			deps = append(deps, nil)
+		}
+	}
+	return deps
+}
+
+// checkArchVariantExists returns true unless the named module exists but has no variant for the os
+// and arch in the variations while it does have variants for other targets, in which case it
+// reports an error listing those variants and the properties that control them.
+func (b *bottomUpMutatorContext) checkArchVariantExists(variations []blueprint.Variation, name string) bool {
+	var os, arch string
+	var others []blueprint.Variation
+	for _, v := range variations {
+		switch v.Mutator {
+		case "os":
+			os = v.Variation
+		case "arch":
+			arch = v.Variation
+		default:
+			others = append(others, v)
+		}
+	}
+	if arch == "" || !b.OtherModuleExists(name) || b.OtherModuleFarDependencyVariantExists(variations, name) {
+		return true
+	}
+
+	var existing []string
+	for _, osType := range osTypeList {
+		targets := b.Config().Targets[osType]
+		for _, target := range append(CopyOf(targets), getCommonTargets(targets)...) {
+			if b.OtherModuleFarDependencyVariantExists(append(CopyOf(others), target.Variations()...), name) {
+				existing = append(existing, target.String())
+			}
+		}
+	}
+	if len(existing) == 0 {
+		// The variant is missing for some other reason, leave it to blueprint to report.
+		return true
+	}
+
+	b.ModuleErrorf("dependency %q has no %s_%s variant, it only has the variants %q; the variants are "+
+		"controlled by the compile_multilib, host_supported, device_supported and arch or target "+
+		"specific enabled properties of %q", name, os, arch, FirstUniqueStrings(existing), name)
+	return false
 }
 
 func (b *bottomUpMutatorContext) AddInterVariantDependency(tag blueprint.DependencyTag, from, to blueprint.Module) {
@@ -32,6 +32,7 @@ func init() {
 
 func registerNamespaceBuildComponents(ctx RegistrationContext) {
 	ctx.RegisterModuleType("soong_namespace", NamespaceFactory)
+	ctx.RegisterParallelSingletonType("namespace_exports", namespaceExportsSingletonFactory)
 }
 
 // threadsafe sorted list
@@ -222,6 +223,7 @@ func (r *NameResolver) NewModule(ctx blueprint.NamespaceContext, moduleGroup blu
 	if ok {
 		// inform the module whether its namespace is one that we want to export to Make
 		amod.base().commonProperties.NamespaceExportedToMake = ns.exportToKati
+		amod.base().commonProperties.NamespacePath = ns.Path
 		amod.base().commonProperties.DebugName = module.Name()
 	}
 
@@ -408,6 +410,19 @@ type Namespace struct {
 	moduleContainer blueprint.NameInterface
 }
 
+// phonyPrefix returns the prefix of the phony targets of the modules in the namespace.  It is empty
+// for the root namespace so that the phony targets of its modules are their names, and unique for
+// every other namespace, even if its id hasn't been chosen.
+func (n *Namespace) phonyPrefix() string {
+	if n.Path == "." {
+		return ""
+	}
+	if n.id != "" {
+		return n.id + "-"
+	}
+	return strings.ReplaceAll(n.Path, "/", "-") + "-"
+}
+
 func NewNamespace(path string) *Namespace {
 	return &Namespace{Path: path, moduleContainer: blueprint.NewSimpleNameInterface()}
 }
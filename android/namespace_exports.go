@@ -0,0 +1,105 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"fmt"
+	"strings"
+)
+
+func namespaceExportsSingletonFactory() Singleton {
+	return &namespaceExportsSingleton{}
+}
+
+// namespaceExportsSingleton writes out/soong/unexported_namespaces.txt, which lists the namespaces
+// that contain enabled modules but are not exported to Make, because their modules silently don't
+// exist in Make.  If EnforceRequiredNamespaceExports is set it also reports modules exported to
+// Make that require modules only defined in those namespaces.
+type namespaceExportsSingleton struct{}
+
+func (s *namespaceExportsSingleton) GenerateBuildActions(ctx SingletonContext) {
+	// Only Make cares about the exported namespaces.
+	if !ctx.Config().KatiEnabled() {
+		return
+	}
+
+	// The names of the enabled modules in each namespace that is not exported to Make, and the
+	// names of the modules that are exported to Make.
+	unexportedModules := make(map[string]map[string]bool)
+	exportedModules := make(map[string]bool)
+	var exporters []Module
+
+	ctx.VisitAllModules(func(module Module) {
+		base := module.base()
+		if !base.Enabled() {
+			return
+		}
+		if base.ExportedToMake() {
+			exportedModules[ctx.ModuleName(module)] = true
+			exporters = append(exporters, module)
+			return
+		}
+		namespace := base.commonProperties.NamespacePath
+		if namespace == "" {
+			// The module was not created through a namespace, so there is no namespace that could
+			// be added to PRODUCT_SOONG_NAMESPACES to export it.
+			return
+		}
+		if unexportedModules[namespace] == nil {
+			unexportedModules[namespace] = make(map[string]bool)
+		}
+		unexportedModules[namespace][ctx.ModuleName(module)] = true
+	})
+
+	var lines []string
+	for _, namespace := range SortedKeys(unexportedModules) {
+		lines = append(lines, fmt.Sprintf("%s: %d enabled modules not exported to Make",
+			namespace, len(unexportedModules[namespace])))
+	}
+	if len(lines) > 0 {
+		lines = append([]string{"# Add the namespaces to PRODUCT_SOONG_NAMESPACES to export their modules to Make."}, lines...)
+	}
+	WriteFileRule(ctx, PathForOutput(ctx, "unexported_namespaces.txt"), strings.Join(lines, "\n"))
+
+	if !ctx.Config().EnforceRequiredNamespaceExports() {
+		return
+	}
+
+	// The namespaces that are not exported to Make that define each module name.
+	unexportedNamespaces := make(map[string][]string)
+	for _, namespace := range SortedKeys(unexportedModules) {
+		for name := range unexportedModules[namespace] {
+			unexportedNamespaces[name] = append(unexportedNamespaces[name], namespace)
+		}
+	}
+
+	for _, module := range exporters {
+		base := module.base()
+		var required []string
+		required = append(required, base.RequiredModuleNames()...)
+		required = append(required, base.HostRequiredModuleNames()...)
+		required = append(required, base.TargetRequiredModuleNames()...)
+		for _, name := range FirstUniqueStrings(required) {
+			// Make looks the name up in the modules exported to it, so a name that any exported
+			// module defines is found there, and a name that no unexported namespace defines is not
+			// this check's concern.
+			if exportedModules[name] || len(unexportedNamespaces[name]) == 0 {
+				continue
+			}
+			ctx.ModuleErrorf(module, "requires %q, which is only defined in namespaces that are not exported to Make: %s, add them to PRODUCT_SOONG_NAMESPACES",
+				name, strings.Join(unexportedNamespaces[name], ", "))
+		}
+	}
+}
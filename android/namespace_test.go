@@ -17,6 +17,7 @@ package android
 import (
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/google/blueprint"
@@ -635,6 +636,266 @@ func TestNamespace_Exports(t *testing.T) {
 	AssertBoolEquals(t, "b not exported", false, bModule.ExportedToMake())
 }
 
+func TestSameNameInTwoNamespacesPhonyTargets(t *testing.T) {
+	result := GroupFixturePreparers(
+		prepareForTestWithNamespace,
+		FixtureRegisterWithContext(func(ctx RegistrationContext) {
+			ctx.RegisterModuleType("checkbuild_test_module", newCheckbuildTestModule)
+			ctx.RegisterParallelSingletonType("buildtarget", BuildTargetSingleton)
+		}),
+		dirBpToPreparer(map[string]string{
+			"dir1": `
+				soong_namespace {
+				}
+				checkbuild_test_module {
+					name: "foo",
+				}
+				checkbuild_test_module {
+					name: "bar",
+				}
+			`,
+			"dir2": `
+				soong_namespace {
+				}
+				checkbuild_test_module {
+					name: "foo",
+				}
+			`,
+			"dir3": `
+				checkbuild_test_module {
+					name: "baz",
+				}
+			`,
+		}),
+	).RunTest(t)
+
+	phonies := getPhonyMap(result.Config)
+	AssertPathsRelativeToTopEquals(t, "1-foo", []string{"1-foo-checkbuild"}, phonies["1-foo"])
+	AssertPathsRelativeToTopEquals(t, "2-foo", []string{"2-foo-checkbuild"}, phonies["2-foo"])
+	AssertPathsRelativeToTopEquals(t, "bar", []string{"1-bar"}, phonies["bar"])
+	AssertPathsRelativeToTopEquals(t, "baz", []string{"baz-checkbuild"}, phonies["baz"])
+	if _, exists := phonies["foo"]; exists {
+		t.Errorf("unexpected phony target for ambiguous module name foo: %s", phonies["foo"])
+	}
+
+	foo := result.SingletonForTests("buildtarget").Output("foo")
+	if foo.Rule != ErrorRule {
+		t.Errorf("expected foo to be built with ErrorRule, got %s", foo.Rule)
+	}
+	AssertStringEquals(t, "foo error",
+		"module foo is defined in multiple namespaces, build one of 1-foo, 2-foo instead", foo.Args["error"])
+}
+
+func TestPhonyTargetGeneratedByMultipleModules(t *testing.T) {
+	GroupFixturePreparers(
+		prepareForTestWithNamespace,
+		FixtureRegisterWithContext(func(ctx RegistrationContext) {
+			ctx.RegisterModuleType("checkbuild_test_module", newCheckbuildTestModule)
+			ctx.RegisterParallelSingletonType("buildtarget", BuildTargetSingleton)
+		}),
+		dirBpToPreparer(map[string]string{
+			"dir1": `
+				soong_namespace {
+				}
+				checkbuild_test_module {
+					name: "foo",
+				}
+			`,
+			"dir2": `
+				checkbuild_test_module {
+					name: "1-foo",
+				}
+			`,
+		}),
+	).
+		ExtendWithErrorHandler(FixtureExpectsAtLeastOneErrorMatchingPattern(
+			`phony target "1-foo" is generated by multiple modules: "1-foo" in dir2, "foo" in dir1`,
+		)).
+		RunTest(t)
+}
+
+func TestNamespaceAliasPhonyTargets(t *testing.T) {
+	result := GroupFixturePreparers(
+		prepareForTestWithNamespace,
+		FixtureRegisterWithContext(func(ctx RegistrationContext) {
+			ctx.RegisterModuleType("checkbuild_test_module", newCheckbuildTestModule)
+			ctx.RegisterParallelSingletonType("buildtarget", BuildTargetSingleton)
+			ctx.RegisterSingletonType("phony", phonySingletonFactory)
+		}),
+		dirBpToPreparer(map[string]string{
+			"vendor/x/y": `
+				soong_namespace {
+				}
+				checkbuild_test_module {
+					name: "foo",
+				}
+			`,
+			"vendor/z/y": `
+				soong_namespace {
+				}
+				checkbuild_test_module {
+					name: "bar",
+				}
+			`,
+			"vendor/a/b": `
+				soong_namespace {
+				}
+				checkbuild_test_module {
+					name: "baz",
+				}
+			`,
+			"vendor/a/b/c": `
+				soong_namespace {
+				}
+				checkbuild_test_module {
+					name: "qux",
+				}
+			`,
+		}),
+	).RunTest(t)
+
+	checkbuildTarget := func(name string) []string {
+		return PathsRelativeToTop(Paths{result.Module(name, "").base().checkbuildTarget})
+	}
+
+	phonies := getPhonyMap(result.Config)
+	// The namespaces rooted at vendor/x/y and vendor/z/y need two directories to be told apart.
+	AssertPathsRelativeToTopEquals(t, "mma-x-y", []string{"MODULES-IN-vendor-x-y"}, phonies["mma-x-y"])
+	AssertPathsRelativeToTopEquals(t, "mma-z-y", []string{"MODULES-IN-vendor-z-y"}, phonies["mma-z-y"])
+	AssertPathsRelativeToTopEquals(t, "MODULES-IN-vendor-x-y", checkbuildTarget("foo"), phonies["MODULES-IN-vendor-x-y"])
+	AssertPathsRelativeToTopEquals(t, "MODULES-IN-vendor-z-y", checkbuildTarget("bar"), phonies["MODULES-IN-vendor-z-y"])
+
+	// The alias of a namespace builds the namespaces nested in it too.
+	AssertPathsRelativeToTopEquals(t, "mma-b", []string{"MODULES-IN-vendor-a-b"}, phonies["mma-b"])
+	AssertPathsRelativeToTopEquals(t, "mma-c", []string{"MODULES-IN-vendor-a-b-c"}, phonies["mma-c"])
+	AssertPathsRelativeToTopEquals(t, "MODULES-IN-vendor-a-b",
+		append([]string{"MODULES-IN-vendor-a-b-c"}, checkbuildTarget("baz")...), phonies["MODULES-IN-vendor-a-b"])
+
+	if _, exists := phonies["mma-y"]; exists {
+		t.Errorf("unexpected phony target for ambiguous alias mma-y: %s", phonies["mma-y"])
+	}
+}
+
+// mmaZPhonySingleton adds a phony target with the alias of the vendor/z namespace.
+type mmaZPhonySingleton struct{}
+
+func (s *mmaZPhonySingleton) GenerateBuildActions(ctx SingletonContext) {
+	ctx.Phony("mma-z", PathForPhony(ctx, "other"))
+}
+
+func TestNamespaceAliasCollidesWithPhonyTarget(t *testing.T) {
+	result := GroupFixturePreparers(
+		prepareForTestWithNamespace,
+		FixtureRegisterWithContext(func(ctx RegistrationContext) {
+			ctx.RegisterModuleType("checkbuild_test_module", newCheckbuildTestModule)
+			ctx.RegisterParallelSingletonType("buildtarget", BuildTargetSingleton)
+			ctx.RegisterParallelSingletonType("mma_z_phony", func() Singleton {
+				return &mmaZPhonySingleton{}
+			})
+			ctx.RegisterSingletonType("phony", phonySingletonFactory)
+		}),
+		dirBpToPreparer(map[string]string{
+			"vendor/x": `
+				soong_namespace {
+				}
+				checkbuild_test_module {
+					name: "foo",
+				}
+			`,
+			"vendor/z": `
+				soong_namespace {
+				}
+				checkbuild_test_module {
+					name: "bar",
+				}
+			`,
+			"dir": `
+				checkbuild_test_module {
+					name: "mma-x",
+				}
+			`,
+		}),
+	).RunTest(t)
+
+	// The phony target of the mma-x module is not replaced by the alias of vendor/x.
+	phonies := getPhonyMap(result.Config)
+	AssertPathsRelativeToTopEquals(t, "mma-x", []string{"mma-x-checkbuild"}, phonies["mma-x"])
+	// Neither is the mma-z phony target of a singleton replaced by the alias of vendor/z.
+	AssertPathsRelativeToTopEquals(t, "mma-z", []string{"other"}, phonies["mma-z"])
+}
+
+func TestNamespace_UnexportedNamespaces(t *testing.T) {
+	bps := dirBpToPreparer(map[string]string{
+		"dir1": `
+			soong_namespace {
+			}
+			test_module {
+				name: "a",
+				required: ["b", "c", "d"],
+			}
+		`,
+		"dir2": `
+			soong_namespace {
+			}
+			test_module {
+				name: "b",
+			}
+			test_module {
+				name: "c",
+			}
+			test_module {
+				name: "disabled",
+				enabled: false,
+			}
+		`,
+		"dir3": `
+			soong_namespace {
+			}
+			test_module {
+				name: "c",
+			}
+		`,
+		"dir4": `
+			test_module {
+				name: "d",
+			}
+		`,
+	})
+
+	preparer := GroupFixturePreparers(
+		prepareForTestWithNamespace,
+		FixtureModifyConfig(SetKatiEnabledForTests),
+		FixtureModifyProductVariables(func(variables FixtureProductVariables) {
+			variables.NamespacesToExport = []string{"dir1"}
+		}),
+		bps,
+	)
+
+	t.Run("report", func(t *testing.T) {
+		result := preparer.RunTest(t)
+		report := result.SingletonForTests("namespace_exports").Output("unexported_namespaces.txt")
+		AssertStringEquals(t, "unexported_namespaces.txt", strings.Join([]string{
+			"# Add the namespaces to PRODUCT_SOONG_NAMESPACES to export their modules to Make.",
+			"dir2: 2 enabled modules not exported to Make",
+			"dir3: 1 enabled modules not exported to Make",
+		}, "\n"), strings.TrimSpace(ContentFromFileRuleForTests(t, result.TestContext, report)))
+	})
+
+	t.Run("enforce required", func(t *testing.T) {
+		GroupFixturePreparers(
+			preparer,
+			FixtureModifyProductVariables(func(variables FixtureProductVariables) {
+				variables.EnforceRequiredNamespaceExports = boolPtr(true)
+			}),
+		).
+			ExtendWithErrorHandler(FixtureExpectsAllErrorsToMatchAPattern([]string{
+				`requires "b", which is only defined in namespaces that are not exported to Make: dir2, add them to PRODUCT_SOONG_NAMESPACES`,
+				`requires "c", which is only defined in namespaces that are not exported to Make: dir2, dir3, add them to PRODUCT_SOONG_NAMESPACES`,
+			})).
+			RunTest(t)
+	})
+}
+
 // some utils to support the tests
 
 var prepareForTestWithNamespace = GroupFixturePreparers(
@@ -735,6 +996,22 @@ func newTestModule() Module {
 	return m
 }
 
+type checkbuildTestModule struct {
+	ModuleBase
+}
+
+func (m *checkbuildTestModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+	out := PathForModuleOut(ctx, "out")
+	WriteFileRule(ctx, out, "")
+	ctx.CheckbuildFile(out)
+}
+
+func newCheckbuildTestModule() Module {
+	m := &checkbuildTestModule{}
+	InitAndroidModule(m)
+	return m
+}
+
 type blueprintTestModule struct {
 	blueprint.SimpleName
 	properties struct {
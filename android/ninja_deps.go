@@ -19,6 +19,14 @@ import (
 	"sort"
 )
 
+// AddNinjaFileDeps adds dependencies on the specified files to the rule that creates the ninja
+// manifest.  It can be used by code that reads files during analysis without access to a module or
+// singleton context, e.g. when loading a configuration file in a OncePer callback, so that changes
+// to those files rerun soong_build.  Duplicate files are only added once.
+func (c Config) AddNinjaFileDeps(deps ...string) {
+	c.addNinjaFileDeps(deps...)
+}
+
 func (c *config) addNinjaFileDeps(deps ...string) {
 	for _, dep := range deps {
 		c.ninjaFileDepsSet.Store(dep, true)
@@ -41,3 +41,42 @@ func TestNinjaDeps(t *testing.T) {
 		t.Errorf("expected %q in %q", w, g)
 	}
 }
+
+type testModuleNinjaDepsModule struct {
+	ModuleBase
+}
+
+func (m *testModuleNinjaDepsModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+	ctx.Config().AddNinjaFileDeps("module_config.json")
+}
+
+func testModuleNinjaDepsModuleFactory() Module {
+	m := &testModuleNinjaDepsModule{}
+	InitAndroidModule(m)
+	return m
+}
+
+func TestNinjaDepsFromModulesAreDeduplicated(t *testing.T) {
+	result := GroupFixturePreparers(
+		FixtureRegisterWithContext(func(ctx RegistrationContext) {
+			ctx.RegisterModuleType("test_ninja_deps_module", testModuleNinjaDepsModuleFactory)
+		}),
+		PrepareForTestWithNinjaDeps,
+		FixtureWithRootAndroidBp(`
+			test_ninja_deps_module {
+				name: "foo",
+			}
+			test_ninja_deps_module {
+				name: "bar",
+			}
+		`),
+	).RunTest(t)
+
+	count := 0
+	for _, dep := range result.NinjaDeps {
+		if dep == "module_config.json" {
+			count++
+		}
+	}
+	AssertIntEquals(t, "module_config.json count in ninja deps", 1, count)
+}
@@ -0,0 +1,115 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// When a module stops installing a file, e.g. because a binary was renamed, the stale copy stays in
+// incremental out directories and on devices that are synced or flashed with dirty images.  Modules
+// list such files in their obsolete_files property, and products in the Obsolete_files product
+// variable, as paths relative to the product out directory like "system/bin/oldname".  The
+// obsolete_files singleton aggregates them into out/soong/obsolete_files.txt, one path per line,
+// which is passed to Make in SOONG_OBSOLETE_FILES for the image build and adb sync to delete them.
+// An obsolete file that is still installed by an enabled module would be deleted after installing
+// it, so that is an error.
+
+func init() {
+	registerObsoleteFilesBuildComponents(InitRegistrationContext)
+}
+
+func registerObsoleteFilesBuildComponents(ctx RegistrationContext) {
+	ctx.RegisterParallelSingletonType("obsolete_files", obsoleteFilesSingletonFactory)
+}
+
+func obsoleteFilesSingletonFactory() Singleton {
+	return &obsoleteFilesSingleton{}
+}
+
+type obsoleteFilesSingleton struct {
+	obsoleteFiles WritablePath
+}
+
+// productObsoleteFilesOwner describes the Obsolete_files product variable in errors.
+const productObsoleteFilesOwner = "the Obsolete_files product variable"
+
+// validObsoleteFile returns the cleaned obsolete file path, and false if it is not a path to a
+// file in the product out directory.
+func validObsoleteFile(path string) (string, bool) {
+	if filepath.IsAbs(path) {
+		return "", false
+	}
+	cleaned, err := validatePath(path)
+	return cleaned, err == nil && cleaned != "" && cleaned != "."
+}
+
+func (s *obsoleteFilesSingleton) GenerateBuildActions(ctx SingletonContext) {
+	// The modules, or the product, that list each obsolete file.
+	obsolete := make(map[string][]string)
+	// The first module that installs each file.
+	installed := make(map[string]string)
+
+	for _, path := range ctx.DeviceConfig().ObsoleteFiles() {
+		cleaned, ok := validObsoleteFile(path)
+		if !ok {
+			ctx.Errorf("invalid path %q in %s", path, productObsoleteFilesOwner)
+			continue
+		}
+		obsolete[cleaned] = append(obsolete[cleaned], productObsoleteFilesOwner)
+	}
+
+	ctx.VisitAllModules(func(module Module) {
+		name := ctx.ModuleName(module)
+		for _, path := range module.base().commonProperties.Obsolete_files {
+			cleaned, ok := validObsoleteFile(path)
+			if !ok {
+				ctx.ModuleErrorf(module, "invalid path %q in obsolete_files", path)
+				continue
+			}
+			obsolete[cleaned] = append(obsolete[cleaned], "module "+name)
+		}
+
+		if !module.Enabled() {
+			return
+		}
+		for _, spec := range module.base().PackagingSpecs() {
+			if spec.Partition() == "" || spec.SkipReason() != SkipReasonNone {
+				continue
+			}
+			path := filepath.Join(spec.Partition(), spec.RelPathInPackage())
+			if _, exists := installed[path]; !exists {
+				installed[path] = name
+			}
+		}
+	})
+
+	var lines []string
+	for _, path := range SortedKeys(obsolete) {
+		if installer, exists := installed[path]; exists {
+			ctx.Errorf("obsolete file %q listed by %s is installed by module %q", path,
+				strings.Join(FirstUniqueStrings(obsolete[path]), ", "), installer)
+		}
+		lines = append(lines, path+"\n")
+	}
+
+	s.obsoleteFiles = PathForOutput(ctx, "obsolete_files.txt")
+	WriteFileRuleVerbatim(ctx, s.obsoleteFiles, strings.Join(lines, ""))
+}
+
+func (s *obsoleteFilesSingleton) MakeVars(ctx MakeVarsContext) {
+	ctx.Strict("SOONG_OBSOLETE_FILES", s.obsoleteFiles.String())
+}
@@ -0,0 +1,107 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"testing"
+
+	"github.com/google/blueprint/proptools"
+)
+
+type obsoleteFilesTestModule struct {
+	ModuleBase
+	properties struct {
+		Stem *string
+	}
+}
+
+func obsoleteFilesTestModuleFactory() Module {
+	m := &obsoleteFilesTestModule{}
+	m.AddProperties(&m.properties)
+	InitAndroidArchModule(m, DeviceSupported, MultilibFirst)
+	return m
+}
+
+func (m *obsoleteFilesTestModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+	ctx.InstallFile(PathForModuleInstall(ctx, "bin"), proptools.StringDefault(m.properties.Stem, ctx.ModuleName()),
+		PathForTesting("src"))
+}
+
+var prepareForObsoleteFilesTest = GroupFixturePreparers(
+	PrepareForTestWithArchMutator,
+	FixtureRegisterWithContext(func(ctx RegistrationContext) {
+		ctx.RegisterModuleType("obsolete_files_test", obsoleteFilesTestModuleFactory)
+		registerObsoleteFilesBuildComponents(ctx)
+	}),
+)
+
+func TestObsoleteFiles(t *testing.T) {
+	result := GroupFixturePreparers(
+		prepareForObsoleteFilesTest,
+		FixtureModifyProductVariables(func(variables FixtureProductVariables) {
+			variables.Obsolete_files = []string{"vendor/etc/old.conf", "system/bin/oldfoo"}
+		}),
+	).RunTestWithBp(t, `
+		// foo was renamed from oldfoo.
+		obsolete_files_test {
+			name: "foo",
+			obsolete_files: ["system/bin/oldfoo", "system/lib64/liboldfoo.so"],
+		}
+
+		// A disabled module still installing a file doesn't conflict with it being obsolete.
+		obsolete_files_test {
+			name: "bar",
+			stem: "oldbar",
+			obsolete_files: ["system/bin/oldbar"],
+			enabled: false,
+		}
+	`)
+
+	content := ContentFromFileRuleForTests(t, result.TestContext,
+		result.SingletonForTests("obsolete_files").Output("obsolete_files.txt"))
+	AssertStringEquals(t, "obsolete_files.txt",
+		"system/bin/oldbar\nsystem/bin/oldfoo\nsystem/lib64/liboldfoo.so\nvendor/etc/old.conf\n",
+		content)
+}
+
+func TestObsoleteFilesInstalled(t *testing.T) {
+	prepareForObsoleteFilesTest.
+		ExtendWithErrorHandler(FixtureExpectsOneErrorPattern(
+			`obsolete file "system/bin/oldfoo" listed by module foo is installed by module "oldfoo"`)).
+		RunTestWithBp(t, `
+			obsolete_files_test {
+				name: "foo",
+				obsolete_files: ["system/bin/oldfoo"],
+			}
+
+			obsolete_files_test {
+				name: "oldfoo",
+			}
+		`)
+}
+
+func TestObsoleteFilesInvalidPath(t *testing.T) {
+	prepareForObsoleteFilesTest.
+		ExtendWithErrorHandler(FixtureExpectsAllErrorsToMatchAPattern([]string{
+			`invalid path "/system/bin/oldfoo" in obsolete_files`,
+			`invalid path "../oldfoo" in obsolete_files`,
+		})).
+		RunTestWithBp(t, `
+			obsolete_files_test {
+				name: "foo",
+				obsolete_files: ["/system/bin/oldfoo", "../oldfoo"],
+			}
+		`)
+}
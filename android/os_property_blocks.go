@@ -0,0 +1,44 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+// RegisterOsPropertyBlocksChecker registers the mutator that reports the target property blocks for
+// OSes that the modules can never be built for, e.g. target.windows in a module type that doesn't
+// support host cross compilation.  They are warnings unless SOONG_STRICT_OS_PROPERTY_BLOCKS is true.
+//
+// This must run before the defaults mutators so that only the blocks set in the module itself are
+// reported, and not those set in defaults modules that are shared between multiple module types.
+func RegisterOsPropertyBlocksChecker(ctx RegisterMutatorsContext) {
+	ctx.BottomUp("checkOsPropertyBlocks", checkOsPropertyBlocksMutator).Parallel()
+}
+
+func checkOsPropertyBlocksMutator(ctx BottomUpMutatorContext) {
+	if _, ok := ctx.Module().(Defaults); ok {
+		return
+	}
+	m := ctx.Module().base()
+	if m.commonProperties.HostOrDeviceSupported == 0 {
+		return
+	}
+	strict := ctx.Config().IsEnvTrue("SOONG_STRICT_OS_PROPERTY_BLOCKS")
+	for _, block := range m.unbuildableOsPropertyBlocks() {
+		if strict {
+			ctx.PropertyErrorf(block, "module type %q can never be built for the OSes this applies to", ctx.ModuleType())
+		} else {
+			ctx.PropertyWarningf(block, "ignored as module type %q can never be built for the OSes it applies to",
+				ctx.ModuleType())
+		}
+	}
+}
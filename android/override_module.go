@@ -69,6 +69,14 @@ type OverrideModuleProperties struct {
 	// Name of the base module to be overridden
 	Base *string
 
+	// Names of modules to be overridden by the overriding module in addition to the ones
+	// overridden by the base module.  The base module itself is always overridden.
+	Overrides []string
+
+	// Names of modules overridden by the base module that are not to be overridden by the
+	// overriding module.
+	Remove_overrides []string
+
 	// TODO(jungjw): Add an optional override_name bool flag.
 }
 
@@ -134,6 +142,9 @@ type OverridableModule interface {
 type overridableModuleProperties struct {
 	OverriddenBy          string `blueprint:"mutated"`
 	OverriddenByModuleDir string `blueprint:"mutated"`
+
+	// The modules overridden by the override variant, set by override().
+	Overrides []string `blueprint:"mutated"`
 }
 
 // Base module struct for overridable module types
@@ -192,6 +203,12 @@ func (b *OverridableModuleBase) setOverridesProperty(overridesProperty *[]string
 
 // Overrides a base module with the given OverrideModule.
 func (b *OverridableModuleBase) override(ctx BaseModuleContext, m Module, o OverrideModule) {
+	// Save the modules overridden by the base module before the overriding properties, which may
+	// include the overrides property, replace them.
+	var overrides []string
+	if b.overridesProperty != nil {
+		overrides = CopyOf(*b.overridesProperty)
+	}
 
 	for _, p := range b.overridableProperties {
 		for _, op := range o.getOverridingProperties() {
@@ -207,10 +224,17 @@ func (b *OverridableModuleBase) override(ctx BaseModuleContext, m Module, o Over
 			}
 		}
 	}
-	// Adds the base module to the overrides property, if exists, of the overriding module. See the
-	// comment on OverridableModuleBase.overridesProperty for details.
+	// The overriding module overrides the modules overridden by the base module, adjusted by its
+	// overrides and remove_overrides properties, and the base module itself.
+	props := o.getOverrideModuleProperties()
+	overrides = append(overrides, props.Overrides...)
+	overrides = RemoveListFromList(overrides, props.Remove_overrides)
+	overrides = FirstUniqueStrings(append(overrides, ctx.ModuleName()))
+	b.overridableModuleProperties.Overrides = overrides
+	// Sets the overrides property, if exists, of the overriding module. See the comment on
+	// OverridableModuleBase.overridesProperty for details.
 	if b.overridesProperty != nil {
-		*b.overridesProperty = append(*b.overridesProperty, ctx.ModuleName())
+		*b.overridesProperty = CopyOf(overrides)
 	}
 	b.overridableModuleProperties.OverriddenBy = o.Name()
 	b.overridableModuleProperties.OverriddenByModuleDir = o.ModuleDir()
@@ -228,6 +252,14 @@ func (b *OverridableModuleBase) GetOverriddenByModuleDir() string {
 	return b.overridableModuleProperties.OverriddenByModuleDir
 }
 
+// GetOverriddenModules returns the names of the modules overridden by the local variant created for
+// an override module, which include the base module and the modules overridden by it as adjusted by
+// the overrides and remove_overrides properties of the override module.  It returns nil when called
+// from the original variant.
+func (b *OverridableModuleBase) GetOverriddenModules() []string {
+	return b.overridableModuleProperties.Overrides
+}
+
 func (b *OverridableModuleBase) OverridablePropertiesDepsMutator(ctx BottomUpMutatorContext) {
 }
 
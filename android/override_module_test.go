@@ -0,0 +1,128 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"testing"
+)
+
+type overridableTestModuleProperties struct {
+	Overrides []string
+}
+
+type overridableTestModule struct {
+	ModuleBase
+	OverridableModuleBase
+
+	properties overridableTestModuleProperties
+}
+
+func (m *overridableTestModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+}
+
+func (m *overridableTestModule) AndroidMkEntries() []AndroidMkEntries {
+	return []AndroidMkEntries{{
+		Class:      "FAKE",
+		OutputFile: OptionalPathForPath(PathForTesting("out")),
+	}}
+}
+
+// newOverridableTestModule returns an overridable test module.  If withOverridesProperty is true,
+// its overrides property is registered with InitOverridableModule.
+func newOverridableTestModule(withOverridesProperty bool) Module {
+	m := &overridableTestModule{}
+	m.AddProperties(&m.properties)
+	InitAndroidModule(m)
+	if withOverridesProperty {
+		InitOverridableModule(m, &m.properties.Overrides)
+	} else {
+		InitOverridableModule(m, nil)
+	}
+	return m
+}
+
+type overrideTestModule struct {
+	ModuleBase
+	OverrideModuleBase
+}
+
+func (m *overrideTestModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+}
+
+func newOverrideTestModule() Module {
+	m := &overrideTestModule{}
+	InitAndroidModule(m)
+	InitOverrideModule(m)
+	return m
+}
+
+func TestOverrideModuleOverrides(t *testing.T) {
+	bp := `
+		overridable {
+			name: "foo",
+			overrides: ["bar", "baz"],
+		}
+
+		override_overridable {
+			name: "foo_override",
+			base: "foo",
+			overrides: ["qux"],
+			remove_overrides: ["bar"],
+		}
+
+		overridable_without_overrides {
+			name: "lib",
+		}
+
+		override_overridable {
+			name: "lib_override",
+			base: "lib",
+			overrides: ["old_lib"],
+		}
+	`
+
+	result := GroupFixturePreparers(
+		PrepareForTestWithOverrides,
+		FixtureRegisterWithContext(func(ctx RegistrationContext) {
+			ctx.RegisterModuleType("overridable", func() Module {
+				return newOverridableTestModule(true)
+			})
+			ctx.RegisterModuleType("overridable_without_overrides", func() Module {
+				return newOverridableTestModule(false)
+			})
+			ctx.RegisterModuleType("override_overridable", newOverrideTestModule)
+		}),
+		FixtureWithRootAndroidBp(bp),
+	).RunTest(t)
+
+	overrides := func(variant string) []string {
+		t.Helper()
+		return result.ModuleForTests("foo", variant).Module().(*overridableTestModule).properties.Overrides
+	}
+	AssertDeepEquals(t, "base overrides", []string{"bar", "baz"}, overrides(""))
+	AssertDeepEquals(t, "override overrides", []string{"baz", "qux", "foo"}, overrides("foo_override"))
+
+	localOverridesModules := func(name, variant string) []string {
+		t.Helper()
+		module := result.ModuleForTests(name, variant).Module()
+		return AndroidMkEntriesForTest(t, result.TestContext, module)[0].EntryMap["LOCAL_OVERRIDES_MODULES"]
+	}
+	AssertDeepEquals(t, "base LOCAL_OVERRIDES_MODULES", []string(nil), localOverridesModules("lib", ""))
+	AssertDeepEquals(t, "override LOCAL_OVERRIDES_MODULES", []string{"old_lib", "lib"},
+		localOverridesModules("lib", "lib_override"))
+	// Module types with their own overrides property export it themselves.
+	AssertDeepEquals(t, "override with overrides property LOCAL_OVERRIDES_MODULES", []string(nil),
+		localOverridesModules("foo", "foo_override"))
+}
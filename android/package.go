@@ -15,6 +15,8 @@
 package android
 
 import (
+	"sync"
+
 	"github.com/google/blueprint"
 	"github.com/google/blueprint/proptools"
 )
@@ -30,12 +32,25 @@ func RegisterPackageBuildComponents(ctx RegistrationContext) {
 	ctx.RegisterModuleType("package", PackageFactory)
 }
 
+// PrepareForTestWithPackageDefaults registers the mutators that apply the package default_team and
+// default_owner properties.  It must be applied after PrepareForTestWithDefaults, if used, so that
+// the package defaults are applied after the defaults modules.
+var PrepareForTestWithPackageDefaults = FixtureRegisterWithContext(func(ctx RegistrationContext) {
+	ctx.PreArchMutators(RegisterPackageDefaultsMapper)
+	ctx.PreArchMutators(RegisterPackageDefaultsGatherer)
+})
+
 type packageProperties struct {
 	// Specifies the default visibility for all modules defined in this package.
 	Default_visibility []string
 	// Specifies the default license terms for all modules defined in this package.
 	Default_applicable_licenses []string
-	Default_team                *string `android:"path"`
+	// Specifies the default team for all modules defined in this package, and in any sub-packages
+	// that do not define their own default_team.
+	Default_team *string `android:"path"`
+	// Specifies the default owner for all modules defined in this package, and in any sub-packages
+	// that do not define their own default_owner.
+	Default_owner *string
 }
 
 type packageModule struct {
@@ -64,6 +79,89 @@ func (p *packageModule) qualifiedModuleId(ctx BaseModuleContext) qualifiedModule
 	return newPackageId(ctx.ModuleDir())
 }
 
+var packageDefaultsMap = NewOnceKey("packageDefaultsMap")
+
+// The map from package id to the properties of the package module in that package.
+func moduleToPackageDefaultsMap(config Config) *sync.Map {
+	return config.Once(packageDefaultsMap, func() interface{} {
+		return &sync.Map{}
+	}).(*sync.Map)
+}
+
+// Registers the function that maps each package to its default_team and default_owner.
+func RegisterPackageDefaultsMapper(ctx RegisterMutatorsContext) {
+	ctx.BottomUp("packageDefaultsMapper", packageDefaultsMapper).Parallel()
+}
+
+// Registers the function that applies the package default_team and default_owner to each module.
+//
+// This goes after defaults expansion so that a team or owner supplied by a defaults module takes
+// precedence over the package default.
+func RegisterPackageDefaultsGatherer(ctx RegisterMutatorsContext) {
+	ctx.BottomUp("packageDefaultsGatherer", packageDefaultsGatherer).Parallel()
+}
+
+// packageIdForDir returns the id of the package for the directory, treating the top level
+// directory as the root package.
+func packageIdForDir(dir string) qualifiedModuleName {
+	if dir == "." {
+		dir = ""
+	}
+	return newPackageId(dir)
+}
+
+// Maps each package to its properties.
+func packageDefaultsMapper(ctx BottomUpMutatorContext) {
+	p, ok := ctx.Module().(*packageModule)
+	if !ok {
+		return
+	}
+
+	moduleToPackageDefaultsMap(ctx.Config()).Store(packageIdForDir(ctx.ModuleDir()), &p.properties)
+}
+
+// Applies the default_team and default_owner of the closest ancestor package that sets them to
+// modules that do not set their own team or owner.
+func packageDefaultsGatherer(ctx BottomUpMutatorContext) {
+	m, ok := ctx.Module().(Module)
+	if !ok {
+		return
+	}
+	if _, ok := m.(*packageModule); ok {
+		return
+	}
+
+	base := m.base()
+	packageDefaults := moduleToPackageDefaultsMap(ctx.Config())
+	// The package default team is recorded even when the module sets a team, as the teams of some
+	// variants may be replaced by an empty list by the arch mutator.
+	base.commonProperties.Package_default_team = packageDefault(packageDefaults, ctx.ModuleDir(),
+		func(p *packageProperties) *string { return p.Default_team })
+	if base.commonProperties.Owner == nil {
+		base.commonProperties.Package_default_owner = packageDefault(packageDefaults, ctx.ModuleDir(),
+			func(p *packageProperties) *string { return p.Default_owner })
+	}
+}
+
+// packageDefault returns the value of the property of the closest package to the directory that
+// sets it, walking up through the containing packages.
+func packageDefault(packageDefaults *sync.Map, dir string, property func(*packageProperties) *string) *string {
+	packageId := packageIdForDir(dir)
+	for {
+		if value, ok := packageDefaults.Load(packageId); ok {
+			if v := property(value.(*packageProperties)); v != nil {
+				return v
+			}
+		}
+
+		if packageId.isRootPackage() {
+			return nil
+		}
+
+		packageId = packageId.getContainingPackageId()
+	}
+}
+
 func PackageFactory() Module {
 	module := &packageModule{}
 
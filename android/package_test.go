@@ -70,3 +70,74 @@ func TestPackage(t *testing.T) {
 		})
 	}
 }
+
+func TestPackageDefaultTeamAndOwner(t *testing.T) {
+	result := GroupFixturePreparers(
+		PrepareForTestWithArchMutator,
+		PrepareForTestWithPackageModule,
+		PrepareForTestWithPackageDefaults,
+		PrepareForTestWithTeamBuildComponents,
+		FixtureRegisterWithContext(func(ctx RegistrationContext) {
+			ctx.RegisterModuleType("fake", fakeModuleFactory)
+		}),
+		MockFS{
+			"Android.bp": []byte(`
+				package {
+					default_team: "root_team",
+					default_owner: "root_owner",
+				}
+				team {
+					name: "root_team",
+					trendy_team_id: "root",
+				}
+				team {
+					name: "other_team",
+					trendy_team_id: "other",
+				}
+				fake {
+					name: "direct",
+				}`),
+			"inherited/deeper/Android.bp": []byte(`
+				fake {
+					name: "inherited",
+				}`),
+			"overridden/Android.bp": []byte(`
+				package {
+					default_owner: "overridden_owner",
+				}
+				fake {
+					name: "overridden_package",
+				}
+				fake {
+					name: "overridden_module",
+					team: "other_team",
+					owner: "module_owner",
+				}`),
+			"overridden/deeper/Android.bp": []byte(`
+				package {
+					default_team: "other_team",
+				}
+				fake {
+					name: "overridden_deeper",
+				}`),
+		}.AddToFixture(),
+	).RunTest(t)
+
+	testCases := []struct {
+		module        string
+		expectedTeam  string
+		expectedOwner string
+	}{
+		{module: "direct", expectedTeam: "root_team", expectedOwner: "root_owner"},
+		{module: "inherited", expectedTeam: "root_team", expectedOwner: "root_owner"},
+		{module: "overridden_package", expectedTeam: "root_team", expectedOwner: "overridden_owner"},
+		{module: "overridden_module", expectedTeam: "other_team", expectedOwner: "module_owner"},
+		{module: "overridden_deeper", expectedTeam: "other_team", expectedOwner: "overridden_owner"},
+	}
+
+	for _, tc := range testCases {
+		module := result.ModuleForTests(tc.module, "").Module().base()
+		AssertArrayString(t, tc.module+" team", []string{tc.expectedTeam}, module.Teams())
+		AssertStringEquals(t, tc.module+" owner", tc.expectedOwner, module.Owner())
+	}
+}
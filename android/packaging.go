@@ -43,6 +43,56 @@ type PackagingSpec struct {
 	effectiveLicenseFiles *Paths
 
 	partition string
+
+	// Why the file is only packaged and not installed, or SkipReasonNone if it is installed.
+	skipReason SkipReason
+
+	// The metadata of the file for the image builders, or nil.
+	metadata *InstallFileMetadata
+}
+
+// SkipReason is the reason why the file of a PackagingSpec is not installed, e.g. why a file that
+// is included in a filesystem image is missing from the installed partition.
+type SkipReason int
+
+const (
+	// SkipReasonNone means that the file is installed.
+	SkipReasonNone SkipReason = iota
+	// SkipReasonPackageOnly means that the file was packaged with PackageFile.
+	SkipReasonPackageOnly
+	// SkipReasonSkipInstall means that SkipInstall was called on the module.
+	SkipReasonSkipInstall
+	// SkipReasonHideFromMake means that HideFromMake was called on the module.
+	SkipReasonHideFromMake
+	// SkipReasonReplacedByPrebuilt means that the module was replaced by its prebuilt.
+	SkipReasonReplacedByPrebuilt
+	// SkipReasonUninstallableApexPlatformVariant means that the module is the platform variant of
+	// a module in an apex, which MakeUninstallable was called on.
+	SkipReasonUninstallableApexPlatformVariant
+	// SkipReasonNamespaceNotExported means that the namespace of the module is not exported to
+	// Make.
+	SkipReasonNamespaceNotExported
+)
+
+func (r SkipReason) String() string {
+	switch r {
+	case SkipReasonNone:
+		return "installed"
+	case SkipReasonPackageOnly:
+		return "package_only"
+	case SkipReasonSkipInstall:
+		return "skip_install"
+	case SkipReasonHideFromMake:
+		return "hide_from_make"
+	case SkipReasonReplacedByPrebuilt:
+		return "replaced_by_prebuilt"
+	case SkipReasonUninstallableApexPlatformVariant:
+		return "uninstallable_apex_platform_variant"
+	case SkipReasonNamespaceNotExported:
+		return "namespace_not_exported"
+	default:
+		panic(fmt.Errorf("unknown SkipReason %d", r))
+	}
 }
 
 // Get file name of installed package
@@ -74,6 +124,28 @@ func (p *PackagingSpec) Partition() string {
 	return p.partition
 }
 
+// SkipReason returns why the file is only packaged and not installed, or SkipReasonNone if it is
+// installed.
+func (p *PackagingSpec) SkipReason() SkipReason {
+	return p.skipReason
+}
+
+// Metadata returns the metadata of the file for the image builders that was passed to
+// InstallFileWithOptions, or nil.
+func (p *PackagingSpec) Metadata() *InstallFileMetadata {
+	return p.metadata
+}
+
+// SrcPath returns the path to the built artifact, or nil if the spec is a symlink.
+func (p *PackagingSpec) SrcPath() Path {
+	return p.srcPath
+}
+
+// SymlinkTarget returns the target of the symlink, or "" if the spec is not a symlink.
+func (p *PackagingSpec) SymlinkTarget() string {
+	return p.symlinkTarget
+}
+
 type PackageModule interface {
 	Module
 	packagingBase() *PackagingBase
@@ -25,8 +25,12 @@ import (
 type componentTestModule struct {
 	ModuleBase
 	props struct {
-		Deps         []string
-		Skip_install *bool
+		Deps               []string
+		Skip_install       *bool
+		Hide_from_make     *bool
+		Make_uninstallable *bool
+		Replaced           *bool
+		Package_only       *bool
 	}
 }
 
@@ -54,6 +58,19 @@ func (m *componentTestModule) GenerateAndroidBuildActions(ctx ModuleContext) {
 	if proptools.Bool(m.props.Skip_install) {
 		m.SkipInstall()
 	}
+	if proptools.Bool(m.props.Hide_from_make) {
+		m.HideFromMake()
+	}
+	if proptools.Bool(m.props.Make_uninstallable) {
+		m.MakeUninstallable()
+	}
+	if proptools.Bool(m.props.Replaced) {
+		m.ReplacedByPrebuilt()
+	}
+	if proptools.Bool(m.props.Package_only) {
+		ctx.PackageFile(installDir, m.Name(), builtFile)
+		return
+	}
 	ctx.InstallFile(installDir, m.Name(), builtFile)
 }
 
@@ -398,3 +415,52 @@ func TestPackagingWithSkipInstallDeps(t *testing.T) {
 		}
 		`, []string{"lib64/foo", "lib64/bar", "lib64/baz"})
 }
+
+func TestPackagingSpecSkipReason(t *testing.T) {
+	result := GroupFixturePreparers(
+		PrepareForTestWithArchMutator,
+		FixtureRegisterWithContext(func(ctx RegistrationContext) {
+			ctx.RegisterModuleType("component", componentTestModuleFactory)
+		}),
+		FixtureWithRootAndroidBp(`
+			component {
+				name: "installed",
+			}
+			component {
+				name: "skip_install",
+				skip_install: true,
+			}
+			component {
+				name: "hide_from_make",
+				hide_from_make: true,
+			}
+			component {
+				name: "uninstallable",
+				make_uninstallable: true,
+			}
+			component {
+				name: "replaced",
+				replaced: true,
+			}
+			component {
+				name: "package_only",
+				package_only: true,
+			}
+		`),
+	).RunTest(t)
+
+	for name, expected := range map[string]SkipReason{
+		"installed":      SkipReasonNone,
+		"skip_install":   SkipReasonSkipInstall,
+		"hide_from_make": SkipReasonHideFromMake,
+		"uninstallable":  SkipReasonUninstallableApexPlatformVariant,
+		"replaced":       SkipReasonReplacedByPrebuilt,
+		"package_only":   SkipReasonPackageOnly,
+	} {
+		specs := result.ModuleForTests(name, "android_arm64_armv8-a").Module().base().packagingSpecs
+		if len(specs) != 1 {
+			t.Fatalf("%s: expected 1 packaging spec, got %d", name, len(specs))
+		}
+		AssertStringEquals(t, name, expected.String(), specs[0].SkipReason().String())
+	}
+}
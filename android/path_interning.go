@@ -0,0 +1,190 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+	"unsafe"
+
+	"google.golang.org/protobuf/proto"
+
+	soong_metrics_proto "android/soong/ui/metrics/metrics_proto"
+)
+
+// The interner deduplicates identical Paths and InstallPaths slices that are stored on a module
+// once its GenerateAndroidBuildActions has completed, e.g. the installed and checkbuild files.
+// Many variants of the same module produce slices with the same contents, holding one copy of
+// each reduces the peak memory usage of soong_build.
+//
+// Interned slices are shared between modules so they must be treated as read-only.  The slices
+// returned by the interner have their capacity clipped to their length so that an append by a
+// caller always reallocates instead of writing into the shared backing array, but writing to an
+// element of an interned slice is a bug.
+var pathInternerOnceKey = NewOnceKey("path interner")
+
+// internPathsEnabled returns true if the installed and checkbuild files of modules should be
+// interned.  It is controlled by SOONG_INTERN_MODULE_PATHS while the feature is being rolled out.
+func (c Config) internPathsEnabled() bool {
+	return c.IsEnvTrue("SOONG_INTERN_MODULE_PATHS")
+}
+
+func getPathInterner(config Config) *pathInterner {
+	return config.Once(pathInternerOnceKey, func() interface{} {
+		return newPathInterner()
+	}).(*pathInterner)
+}
+
+type pathInterner struct {
+	lock         sync.Mutex
+	paths        map[string][]Path
+	installPaths map[string][]InstallPath
+	stats        pathInternerStats
+}
+
+// pathInternerStats is a rough estimate of the memory held by the paths passed to the interner,
+// reported in the soong_build metrics.
+type pathInternerStats struct {
+	// The number of paths passed to the interner.
+	pathCount int64
+	// The number of paths that were replaced by an existing identical copy.
+	dedupedPathCount int64
+	// The estimated size in bytes of all of the paths passed to the interner.
+	totalBytes int64
+	// The estimated size in bytes of the paths that were replaced by an existing identical copy.
+	dedupedBytes int64
+}
+
+func newPathInterner() *pathInterner {
+	return &pathInterner{
+		paths:        make(map[string][]Path),
+		installPaths: make(map[string][]InstallPath),
+	}
+}
+
+// internPathsKey returns a hash of the types and string values of the paths.  The hash is only
+// used to find candidates, elements are compared for equality before a candidate is used.
+func internPathsKey[T Path](paths []T) string {
+	h := md5.New()
+	for _, p := range paths {
+		h.Write([]byte(reflect.TypeOf(p).String()))
+		h.Write([]byte{0})
+		h.Write([]byte(p.String()))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// equalInternPaths returns true if both slices contain identical paths.  Paths whose dynamic type
+// is not comparable are never considered identical.
+func equalInternPaths[T Path](a, b []T) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		var x, y Path = a[i], b[i]
+		if reflect.TypeOf(x) != reflect.TypeOf(y) || !reflect.TypeOf(x).Comparable() || x != y {
+			return false
+		}
+	}
+	return true
+}
+
+// estimatePathsSize returns an estimate of the number of bytes held by a slice of paths.
+func estimatePathsSize[T Path](paths []T) int64 {
+	var zero T
+	size := int64(cap(paths)) * int64(unsafe.Sizeof(zero))
+	for _, p := range paths {
+		size += int64(reflect.TypeOf(p).Size()) + int64(len(p.String()))
+	}
+	return size
+}
+
+func internSlice[T Path](interner *pathInterner, table map[string][]T, paths []T) []T {
+	if len(paths) == 0 {
+		return paths
+	}
+	for _, p := range paths {
+		if reflect.ValueOf(p).Kind() == reflect.Invalid {
+			// Don't try to intern slices containing nil paths.
+			return paths
+		}
+	}
+	key := internPathsKey(paths)
+	size := estimatePathsSize(paths)
+
+	interner.lock.Lock()
+	defer interner.lock.Unlock()
+
+	interner.stats.pathCount += int64(len(paths))
+	interner.stats.totalBytes += size
+
+	if existing, ok := table[key]; ok {
+		if equalInternPaths(existing, paths) {
+			interner.stats.dedupedPathCount += int64(len(paths))
+			interner.stats.dedupedBytes += size
+			return existing
+		}
+		// A hash collision between different contents, keep the caller's copy.
+		return paths
+	}
+
+	// Store a copy so that later modifications to the caller's backing array cannot affect the
+	// shared copy, and clip it so that appends by users of the shared copy reallocate.
+	interned := slices.Clip(slices.Clone(paths))
+	table[key] = interned
+	return interned
+}
+
+// intern returns a slice with the same contents as paths that may be shared with other callers.
+func (i *pathInterner) intern(paths Paths) Paths {
+	return internSlice(i, i.paths, []Path(paths))
+}
+
+// internInstallPaths returns a slice with the same contents as paths that may be shared with other
+// callers.
+func (i *pathInterner) internInstallPaths(paths InstallPaths) InstallPaths {
+	return internSlice(i, i.installPaths, []InstallPath(paths))
+}
+
+func (i *pathInterner) getStats() pathInternerStats {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	return i.stats
+}
+
+// pathInternerPerfCounters returns the interner statistics as a PerfCounters entry for the
+// soong_build metrics.
+func pathInternerPerfCounters(config Config) *soong_metrics_proto.PerfCounters {
+	stats := getPathInterner(config).getStats()
+	return &soong_metrics_proto.PerfCounters{
+		Time: proto.Uint64(uint64(time.Now().UnixNano())),
+		Groups: []*soong_metrics_proto.PerfCounterGroup{
+			{
+				Name: proto.String("path_interning"),
+				Counters: []*soong_metrics_proto.PerfCounter{
+					{Name: proto.String("path_count"), Value: proto.Int64(stats.pathCount)},
+					{Name: proto.String("deduped_path_count"), Value: proto.Int64(stats.dedupedPathCount)},
+					{Name: proto.String("total_bytes"), Value: proto.Int64(stats.totalBytes)},
+					{Name: proto.String("deduped_bytes"), Value: proto.Int64(stats.dedupedBytes)},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"testing"
+)
+
+func TestPathInterner(t *testing.T) {
+	interner := newPathInterner()
+
+	a := PathsForTesting("a", "b", "c")
+	b := PathsForTesting("a", "b", "c")
+	c := PathsForTesting("a", "b")
+
+	internedA := interner.intern(a)
+	internedB := interner.intern(b)
+	internedC := interner.intern(c)
+
+	AssertDeepEquals(t, "interned a", a, internedA)
+	AssertDeepEquals(t, "interned b", b, internedB)
+	AssertDeepEquals(t, "interned c", c, internedC)
+
+	if &internedA[0] != &internedB[0] {
+		t.Errorf("expected identical slices to share a backing array")
+	}
+	if &internedA[0] == &a[0] {
+		t.Errorf("expected the interned slice to be a copy of the original")
+	}
+
+	// Modifying the original slice must not affect the interned copy.
+	a[0] = PathForTesting("z")
+	AssertDeepEquals(t, "interned a after modifying original", b, internedA)
+
+	// Appending to an interned slice must not write into the shared backing array.
+	appended := append(internedA, PathForTesting("d"))
+	AssertDeepEquals(t, "interned b after appending to interned a", PathsForTesting("a", "b", "c"), internedB)
+	AssertDeepEquals(t, "appended", PathsForTesting("a", "b", "c", "d"), appended)
+
+	stats := interner.getStats()
+	AssertIntEquals(t, "path count", 8, int(stats.pathCount))
+	AssertIntEquals(t, "deduped path count", 3, int(stats.dedupedPathCount))
+	if stats.dedupedBytes <= 0 || stats.dedupedBytes >= stats.totalBytes {
+		t.Errorf("unexpected byte estimates %d of %d", stats.dedupedBytes, stats.totalBytes)
+	}
+}
+
+func TestPathInternerDistinguishesTypes(t *testing.T) {
+	interner := newPathInterner()
+	config := TestConfig(t.TempDir(), nil, "", nil)
+	ctx := PathContextForTesting(config)
+
+	source := Paths{PathForSource(ctx, "foo")}
+	// A testPath with the same string as the SourcePath.
+	other := Paths{PathForTesting(source[0].String())}
+
+	internedSource := interner.intern(source)
+	internedOther := interner.intern(other)
+
+	AssertDeepEquals(t, "interned source", source, internedSource)
+	AssertDeepEquals(t, "interned other", other, internedOther)
+}
+
+func TestInternModulePaths(t *testing.T) {
+	bp := `
+		deps {
+			name: "foo",
+			host_supported: true,
+		}
+	`
+
+	run := func(intern bool) *TestResult {
+		env := map[string]string{}
+		if intern {
+			env["SOONG_INTERN_MODULE_PATHS"] = "true"
+		}
+		return GroupFixturePreparers(
+			prepareForModuleTests,
+			PrepareForTestWithArchMutator,
+			FixtureMergeEnv(env),
+		).RunTestWithBp(t, bp)
+	}
+
+	withoutInterning := run(false)
+	withInterning := run(true)
+
+	for _, variant := range withoutInterning.ModuleVariantsForTests("foo") {
+		expected := withoutInterning.ModuleForTests("foo", variant).Module().base()
+		actual := withInterning.ModuleForTests("foo", variant).Module().base()
+		AssertDeepEquals(t, variant+" installFiles", expected.installFiles, actual.installFiles)
+		AssertDeepEquals(t, variant+" checkbuildFiles", expected.checkbuildFiles, actual.checkbuildFiles)
+	}
+
+	stats := getPathInterner(withInterning.Config).getStats()
+	if stats.pathCount == 0 {
+		t.Errorf("expected paths to be passed to the interner")
+	}
+}
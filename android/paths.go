@@ -215,7 +215,8 @@ func pathContextName(ctx PathContext, module blueprint.Module) string {
 	} else if x, ok := ctx.(interface{ OtherModuleName(blueprint.Module) string }); ok {
 		return x.OtherModuleName(module)
 	}
-	return "unknown"
+	// Contexts of singletons defined outside of this package may not implement either method.
+	return module.Name()
 }
 
 type Path interface {
@@ -409,15 +410,6 @@ func (p Paths) RelativeToTop() Paths {
 	return ret
 }
 
-func (paths Paths) containsPath(path Path) bool {
-	for _, p := range paths {
-		if p == path {
-			return true
-		}
-	}
-	return false
-}
-
 // PathsForSource returns Paths rooted from SrcDir, *not* rooted from the module's local source
 // directory
 func PathsForSource(ctx PathContext, paths []string) Paths {
@@ -553,7 +545,9 @@ func getPathsFromModuleDep(ctx ModuleWithDepsPathContext, path, moduleName, tag
 	if aModule, ok := module.(Module); ok && !aModule.Enabled() {
 		return nil, missingDependencyError{[]string{moduleName}}
 	}
-	if outProducer, ok := module.(OutputFileProducer); ok {
+	if builtInFiles, ok := builtInOutputFiles(module, tag); ok {
+		return builtInFiles, nil
+	} else if outProducer, ok := module.(OutputFileProducer); ok {
 		outputFiles, err := outProducer.OutputFiles(tag)
 		if err != nil {
 			return nil, fmt.Errorf("path dependency %q: %s", path, err)
@@ -1773,7 +1767,7 @@ func (p InstallPath) Join(ctx PathContext, paths ...string) InstallPath {
 	if err != nil {
 		reportPathError(ctx, err)
 	}
-	return p.withRel(path)
+	return p.withRel(path).withInstallPathOverride(ctx)
 }
 
 func (p InstallPath) withRel(rel string) InstallPath {
@@ -2026,6 +2020,12 @@ func validateSafePath(pathComponents ...string) (string, error) {
 	return validatePathInternal(true, pathComponents...)
 }
 
+// ValidateSafePath is validateSafePath for use by module types in other packages that need to check
+// a path from a property before using it.
+func ValidateSafePath(pathComponents ...string) (string, error) {
+	return validateSafePath(pathComponents...)
+}
+
 // validatePath validates that a path does not include ninja variables, and that
 // each path component does not attempt to leave its component. Returns a joined
 // version of each path component.
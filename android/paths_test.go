@@ -1677,3 +1677,74 @@ func BenchmarkFirstUniquePaths(b *testing.B) {
 		})
 	}
 }
+
+func TestTaggedDistFilesMerge(t *testing.T) {
+	dists := TaggedDistFiles{
+		"": PathsForTesting("b", "a"),
+	}
+	dists = dists.merge(TaggedDistFiles{
+		"":     PathsForTesting("c", "a", "c"),
+		".tag": PathsForTesting("a"),
+	})
+
+	AssertArrayString(t, "default tag", []string{"b", "a", "c"}, dists[""].Strings())
+	AssertArrayString(t, ".tag", []string{"a"}, dists[".tag"].Strings())
+}
+
+func BenchmarkTaggedDistFilesMerge(b *testing.B) {
+	// addPathsForTagList is the implementation of TaggedDistFiles.addPathsForTag before it used
+	// FirstUniquePaths.
+	addPathsForTagList := func(t TaggedDistFiles, tag string, paths ...Path) TaggedDistFiles {
+		if t == nil {
+			t = make(TaggedDistFiles)
+		}
+		for _, distFile := range paths {
+			if distFile == nil {
+				continue
+			}
+			found := false
+			for _, p := range t[tag] {
+				if p == distFile {
+					found = true
+					break
+				}
+			}
+			if !found {
+				t[tag] = append(t[tag], distFile)
+			}
+		}
+		return t
+	}
+
+	implementations := []struct {
+		name string
+		f    func(TaggedDistFiles, string, ...Path) TaggedDistFiles
+	}{
+		{
+			name: "list",
+			f:    addPathsForTagList,
+		},
+		{
+			name: "unique",
+			f:    TaggedDistFiles.addPathsForTag,
+		},
+	}
+
+	const maxSize = 1024
+	paths := make(Paths, maxSize)
+	for i := range paths {
+		paths[i] = PathForTesting(strconv.Itoa(i))
+	}
+
+	for _, size := range []int{16, maxSize} {
+		for _, implementation := range implementations {
+			b.Run(fmt.Sprintf("%s_%d", implementation.name, size), func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					dists := implementation.f(nil, "", paths[:size/2]...)
+					implementation.f(dists, "", paths[:size]...)
+				}
+			})
+		}
+	}
+}
@@ -39,6 +39,24 @@ func addPhony(config Config, name string, deps ...Path) {
 	phonyMap[name] = append(phonyMap[name], deps...)
 }
 
+var phonyAliasMapOnceKey = NewOnceKey("phonyAlias")
+
+func getPhonyAliasMap(config Config) phonyMap {
+	return config.Once(phonyAliasMapOnceKey, func() interface{} {
+		return make(phonyMap)
+	}).(phonyMap)
+}
+
+// addPhonyAlias adds a phony target with the name that depends on deps, unless the name is used by
+// another phony target.  The aliases are added by the phony singleton once all the other phony
+// targets are known.
+func addPhonyAlias(config Config, name string, deps ...Path) {
+	phonyAliasMap := getPhonyAliasMap(config)
+	phonyMapLock.Lock()
+	defer phonyMapLock.Unlock()
+	phonyAliasMap[name] = append(phonyAliasMap[name], deps...)
+}
+
 type phonySingleton struct {
 	phonyMap  phonyMap
 	phonyList []string
@@ -48,6 +66,11 @@ var _ SingletonMakeVarsProvider = (*phonySingleton)(nil)
 
 func (p *phonySingleton) GenerateBuildActions(ctx SingletonContext) {
 	p.phonyMap = getPhonyMap(ctx.Config())
+	for name, deps := range getPhonyAliasMap(ctx.Config()) {
+		if _, exists := p.phonyMap[name]; !exists {
+			p.phonyMap[name] = deps
+		}
+	}
 	p.phonyList = SortedKeys(p.phonyMap)
 	for _, phony := range p.phonyList {
 		p.phonyMap[phony] = SortedUniquePaths(p.phonyMap[phony])
@@ -31,6 +31,31 @@ func RegisterPrebuiltMutators(ctx RegistrationContext) {
 	ctx.PostDepsMutators(RegisterPrebuiltsPostDepsMutators)
 }
 
+// PrebuiltInfo is provided by modules that may be replaced by prebuilts, both source and prebuilt,
+// and is collected into prebuilt_info.json.
+type PrebuiltInfo struct {
+	// Name of the module, without the prebuilt_ prefix.
+	Name string
+
+	// Whether the module is a prebuilt.
+	Is_prebuilt bool
+
+	// Path to the prebuilt_info file of the prebuilt module, if any.
+	Prebuilt_info_file_path string `json:",omitempty"`
+
+	// The architecture that the prebuilt_info file was selected for, if any.
+	Prebuilt_info_arch string `json:",omitempty"`
+
+	// Whether prerelease: true of the prebuilt was ignored because the product restricts prerelease
+	// apexes to the ones in the PrereleaseApexes product variable.
+	Ignored_prerelease bool `json:",omitempty"`
+
+	// Why the prebuilt was force disabled in favor of another prebuilt, if it was.
+	Force_disable_reason string `json:",omitempty"`
+}
+
+var PrebuiltInfoProvider = blueprint.NewProvider[PrebuiltInfo]()
+
 // Marks a dependency tag as possibly preventing a reference to a source from being
 // replaced with the prebuilt.
 type ReplaceSourceWithPrebuilt interface {
@@ -0,0 +1,78 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// A change to any product variable changes soong.variables as a whole, which reruns soong_build.
+// The digest of each top level field of soong.variables identifies the product variables that
+// actually changed, for the rerun explanation, and is the foundation for invalidating less than the
+// whole analysis when only some of them change.  The fields are parsed without ProductVariables so
+// that unknown fields are digested too, and the values are canonicalized so that the digests don't
+// depend on the order of the fields or on whitespace.
+
+// productVariableDigests returns the sha256 digest of the canonical JSON of each top level field of
+// the soong.variables file content, keyed by field name.
+func productVariableDigests(data []byte) (map[string]string, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	digests := make(map[string]string, len(fields))
+	for name, value := range fields {
+		canonical, err := canonicalJSON(value)
+		if err != nil {
+			return nil, err
+		}
+		digest := sha256.Sum256(canonical)
+		digests[name] = hex.EncodeToString(digest[:])
+	}
+	return digests, nil
+}
+
+// canonicalJSON returns the JSON value re-encoded with the keys of objects sorted and without
+// insignificant whitespace.  Numbers are kept as written.
+func canonicalJSON(value json.RawMessage) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(value))
+	decoder.UseNumber()
+	var v interface{}
+	if err := decoder.Decode(&v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// ChangedProductVariables returns the sorted names of the product variables whose digests differ
+// between the previous and the current ProductVariableDigests, including the ones that were added
+// or removed.
+func ChangedProductVariables(previous, current map[string]string) []string {
+	var changed []string
+	for name, digest := range current {
+		if previousDigest, ok := previous[name]; !ok || previousDigest != digest {
+			changed = append(changed, name)
+		}
+	}
+	for name := range previous {
+		if _, ok := current[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+	return SortedUniqueStrings(changed)
+}
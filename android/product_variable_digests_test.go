@@ -0,0 +1,72 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"testing"
+)
+
+func TestProductVariableDigests(t *testing.T) {
+	digests := func(t *testing.T, data string) map[string]string {
+		t.Helper()
+		ret, err := productVariableDigests([]byte(data))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		return ret
+	}
+
+	previous := digests(t, `{
+		"Platform_sdk_version": 34,
+		"ProductDistBootJars": ["a", "b"],
+		"VendorVars": {"ns": {"x": "1", "y": "2"}},
+		"Unknown_future_variable": {"z": 1.50}
+	}`)
+
+	t.Run("single key change", func(t *testing.T) {
+		current := digests(t, `{
+			"Platform_sdk_version": 34,
+			"ProductDistBootJars": ["a", "b", "c"],
+			"VendorVars": {"ns": {"x": "1", "y": "2"}},
+			"Unknown_future_variable": {"z": 1.50}
+		}`)
+		AssertDeepEquals(t, "changed", []string{"ProductDistBootJars"},
+			ChangedProductVariables(previous, current))
+	})
+
+	t.Run("reordered keys", func(t *testing.T) {
+		current := digests(t, `{"Unknown_future_variable":{"z":1.50},"VendorVars":{"ns":{"y":"2","x":"1"}},
+			"ProductDistBootJars":["a","b"],"Platform_sdk_version":34}`)
+		AssertDeepEquals(t, "digests", previous, current)
+		AssertDeepEquals(t, "changed", []string(nil), ChangedProductVariables(previous, current))
+	})
+
+	t.Run("added and removed keys", func(t *testing.T) {
+		current := digests(t, `{
+			"Platform_sdk_version": 34,
+			"ProductDistBootJars": ["a", "b"],
+			"VendorVars": {"ns": {"x": "1", "y": "2"}},
+			"Added": true
+		}`)
+		AssertDeepEquals(t, "changed", []string{"Added", "Unknown_future_variable"},
+			ChangedProductVariables(previous, current))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		if _, err := productVariableDigests([]byte(`{"a": `)); err == nil {
+			t.Errorf("expected an error")
+		}
+	})
+}
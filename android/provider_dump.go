@@ -0,0 +1,171 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Setting SOONG_DUMP_PROVIDERS to a comma or space separated list of module names writes the
+// providers set on each variant of those modules to
+// out/soong/provider_dumps/<module>/<variant>.json after its build actions have been generated, to
+// help debug the migration of modules from methods to providers.
+
+const (
+	// The maximum depth of nested values that is rendered in a provider dump.
+	providerDumpMaxDepth = 10
+
+	// The maximum number of elements of a slice, array or map that is rendered in a provider dump.
+	providerDumpMaxElements = 100
+)
+
+var providerDumpModulesKey = NewOnceKey("providerDumpModules")
+
+// dumpProvidersForModule returns true if the providers of the named module should be dumped.
+func dumpProvidersForModule(config Config, name string) bool {
+	modules := config.Once(providerDumpModulesKey, func() interface{} {
+		modules := make(map[string]bool)
+		for _, module := range strings.FieldsFunc(config.Getenv("SOONG_DUMP_PROVIDERS"), func(r rune) bool {
+			return r == ',' || r == ' '
+		}) {
+			modules[module] = true
+		}
+		return modules
+	}).(map[string]bool)
+	return modules[name]
+}
+
+// providerDump is a provider value that was set on a module whose providers are dumped.
+type providerDump struct {
+	// The type of the provider value, e.g. "*android.LicenseMetadataInfo".
+	Provider string
+
+	// The rendering of the provider value.
+	Value any
+}
+
+// recordProviderForDump records a provider value set on the module, whose providers are dumped.
+func (m *ModuleBase) recordProviderForDump(value any) {
+	m.providerDumps = append(m.providerDumps, providerDump{
+		Provider: reflect.TypeOf(value).String(),
+		Value:    renderProviderValue(reflect.ValueOf(value), 0),
+	})
+}
+
+// writeProviderDump writes out/soong/provider_dumps/<module>/<variant>.json if the providers of the
+// module are dumped.
+func writeProviderDump(ctx ModuleContext) {
+	if !dumpProvidersForModule(ctx.Config(), ctx.ModuleName()) {
+		return
+	}
+	variant := ctx.ModuleSubDir()
+	if variant == "" {
+		variant = "default"
+	}
+
+	dumps := ctx.Module().base().providerDumps
+	if dumps == nil {
+		dumps = []providerDump{}
+	}
+	data, err := json.MarshalIndent(dumps, "", "  ")
+	if err != nil {
+		ctx.ModuleErrorf("failed to marshal providers: %s", err)
+		return
+	}
+	WriteFileRule(ctx, PathForOutput(ctx, "provider_dumps", ctx.ModuleName(), variant+".json"), string(data))
+}
+
+// renderProviderValue converts a provider value into a value that can be marshalled to JSON.  Values
+// that implement fmt.Stringer, e.g. paths, are rendered as strings, structs are rendered as maps
+// from their field names, including unexported ones, to their values, and values that cannot be
+// serialized, e.g. functions, are rendered as a placeholder.  Nested values beyond
+// providerDumpMaxDepth and elements beyond providerDumpMaxElements are elided.
+func renderProviderValue(v reflect.Value, depth int) any {
+	if !v.IsValid() {
+		return nil
+	}
+	if depth > providerDumpMaxDepth {
+		return fmt.Sprintf("<elided %s, too deep>", v.Type())
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+		if v.IsNil() {
+			return nil
+		}
+	}
+
+	// The methods of values read from unexported fields can't be called.
+	if v.CanInterface() {
+		if stringer, ok := v.Interface().(fmt.Stringer); ok {
+			return stringer.String()
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint()
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	case reflect.String:
+		return v.String()
+	case reflect.Ptr, reflect.Interface:
+		return renderProviderValue(v.Elem(), depth+1)
+	case reflect.Slice, reflect.Array:
+		var elements []any
+		for i := 0; i < v.Len() && i < providerDumpMaxElements; i++ {
+			elements = append(elements, renderProviderValue(v.Index(i), depth+1))
+		}
+		if v.Len() > providerDumpMaxElements {
+			elements = append(elements, fmt.Sprintf("<elided %d more elements>", v.Len()-providerDumpMaxElements))
+		}
+		return elements
+	case reflect.Map:
+		elements := make(map[string]any)
+		var keys []string
+		values := make(map[string]reflect.Value)
+		iter := v.MapRange()
+		for iter.Next() {
+			key := fmt.Sprint(renderProviderValue(iter.Key(), depth+1))
+			keys = append(keys, key)
+			values[key] = iter.Value()
+		}
+		sort.Strings(keys)
+		for i, key := range keys {
+			if i == providerDumpMaxElements {
+				elements["<elided>"] = fmt.Sprintf("%d more elements", len(keys)-providerDumpMaxElements)
+				break
+			}
+			elements[key] = renderProviderValue(values[key], depth+1)
+		}
+		return elements
+	case reflect.Struct:
+		fields := make(map[string]any)
+		for i := 0; i < v.NumField(); i++ {
+			fields[v.Type().Field(i).Name] = renderProviderValue(v.Field(i), depth+1)
+		}
+		return fields
+	default:
+		return fmt.Sprintf("<not serializable %s>", v.Type())
+	}
+}
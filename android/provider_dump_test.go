@@ -0,0 +1,115 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/google/blueprint"
+)
+
+type providerDumpTestInfo struct {
+	OutputFiles  Paths
+	InstallFiles InstallPaths
+	Tags         map[string]int
+	tag          string
+	callback     func()
+}
+
+var providerDumpTestInfoProvider = blueprint.NewProvider[providerDumpTestInfo]()
+
+type providerDumpTestModule struct {
+	ModuleBase
+}
+
+func (m *providerDumpTestModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+	outputFile := PathForModuleOut(ctx, ctx.ModuleName()+".out")
+	ctx.Build(pctx, BuildParams{
+		Rule:   Touch,
+		Output: outputFile,
+	})
+	installFile := ctx.InstallFile(PathForModuleInstall(ctx, "bin"), ctx.ModuleName(), outputFile)
+	SetProvider(ctx, providerDumpTestInfoProvider, providerDumpTestInfo{
+		OutputFiles:  Paths{outputFile},
+		InstallFiles: InstallPaths{installFile},
+		Tags:         map[string]int{"b": 2, "a": 1},
+		tag:          "mytag",
+		callback:     func() {},
+	})
+}
+
+func providerDumpTestModuleFactory() Module {
+	m := &providerDumpTestModule{}
+	InitAndroidArchModule(m, DeviceSupported, MultilibCommon)
+	return m
+}
+
+func TestProviderDump(t *testing.T) {
+	bp := `
+		provider_dump_test {
+			name: "foo",
+		}
+
+		provider_dump_test {
+			name: "bar",
+		}
+	`
+
+	result := GroupFixturePreparers(
+		PrepareForTestWithArchMutator,
+		FixtureRegisterWithContext(func(ctx RegistrationContext) {
+			ctx.RegisterModuleType("provider_dump_test", providerDumpTestModuleFactory)
+		}),
+		FixtureMergeEnv(map[string]string{
+			"SOONG_DUMP_PROVIDERS": "foo,other",
+		}),
+	).RunTestWithBp(t, bp)
+
+	AssertBoolEquals(t, "bar is not dumped", false,
+		result.ModuleForTests("bar", "android_common").MaybeOutput("provider_dumps/bar/android_common.json").Rule != nil)
+
+	dump := result.ModuleForTests("foo", "android_common").Output("provider_dumps/foo/android_common.json")
+	var dumps []providerDump
+	err := json.Unmarshal([]byte(ContentFromFileRuleForTests(t, result.TestContext, dump)), &dumps)
+	FailIfErrored(t, []error{err})
+
+	values := make(map[string]map[string]any)
+	for _, d := range dumps {
+		values[d.Provider], _ = d.Value.(map[string]any)
+	}
+
+	info := values["android.providerDumpTestInfo"]
+	if info == nil {
+		t.Fatalf("missing android.providerDumpTestInfo in %v", dumps)
+	}
+	outputFiles, _ := info["OutputFiles"].([]any)
+	AssertIntEquals(t, "number of output files", 1, len(outputFiles))
+	AssertBoolEquals(t, "output file", true, strings.HasSuffix(outputFiles[0].(string), "/foo/android_common/foo.out"))
+	installFiles, _ := info["InstallFiles"].([]any)
+	AssertIntEquals(t, "number of install files", 1, len(installFiles))
+	AssertBoolEquals(t, "install file", true, strings.HasSuffix(installFiles[0].(string), "/system/bin/foo"))
+	AssertDeepEquals(t, "map", map[string]any{"a": float64(1), "b": float64(2)}, info["Tags"])
+	AssertDeepEquals(t, "unexported field", "mytag", info["tag"])
+	AssertDeepEquals(t, "unserializable field", "<not serializable func()>", info["callback"])
+
+	licenseMetadata := values["*android.LicenseMetadataInfo"]
+	if licenseMetadata == nil {
+		t.Fatalf("missing *android.LicenseMetadataInfo in %v", dumps)
+	}
+	AssertBoolEquals(t, "license metadata path", true,
+		strings.HasSuffix(licenseMetadata["LicenseMetadataPath"].(string), "/foo/android_common/meta_lic"))
+}
@@ -15,6 +15,7 @@
 package android
 
 import (
+	"bufio"
 	"crypto/sha1"
 	"encoding/hex"
 	"fmt"
@@ -47,6 +48,21 @@ func WriteExecutableFileRuleVerbatim(ctx BuilderContext, outputFile WritablePath
 	writeFileRule(ctx, outputFile, content, false, true)
 }
 
+// WriteFileRuleStreaming is the same as WriteFileRuleVerbatim, but the contents are written by
+// writeContent directly to the file in out/soong/raw-${TARGET_PRODUCT} instead of being passed as a
+// string, so that very large generated files like module-info.json are never held in memory in
+// full.  Only the rule to copy the file into place is emitted into the ninja file.  If writeContent
+// returns an error no rule is created and the error is returned.
+func WriteFileRuleStreaming(ctx BuilderContext, outputFile WritablePath, writeContent func(w io.Writer) error) error {
+	tempFile, hash, err := writeToTempFileAndHash(ctx, writeContent)
+	if err != nil {
+		tempFile.remove()
+		return err
+	}
+	rawFileRule(ctx, outputFile, tempFile, hash, false)
+	return nil
+}
+
 // tempFile provides a testable wrapper around a file in out/soong/.temp.  It writes to a temporary file when
 // not in tests, but writes to a buffer in memory when used in tests.
 type tempFile struct {
@@ -107,27 +123,61 @@ func (t *tempFile) remove() error {
 	return nil
 }
 
-func writeContentToTempFileAndHash(ctx BuilderContext, content string, newline bool) (*tempFile, string) {
+// writeToTempFileAndHash writes the contents written by writeContent to a temporary file while
+// computing their hash.  Errors writing the temporary file itself panic, errors returned by
+// writeContent are returned.
+func writeToTempFileAndHash(ctx BuilderContext, writeContent func(w io.Writer) error) (*tempFile, string, error) {
 	tempFile := newTempFile(ctx, "raw", ctx.Config().captureBuild)
 	defer tempFile.close()
 
 	hash := sha1.New()
-	w := io.MultiWriter(tempFile, hash)
+	w := &tempFileWriter{bufio.NewWriter(io.MultiWriter(tempFile, hash)), nil}
 
-	_, err := io.WriteString(w, content)
-	if err == nil && newline {
-		_, err = io.WriteString(w, "\n")
+	err := writeContent(w)
+	if w.err == nil {
+		w.err = w.Flush()
 	}
-	if err != nil {
-		panic(fmt.Errorf("failed to write to temporary raw file %s: %w", tempFile.name(), err))
+	if w.err != nil {
+		panic(fmt.Errorf("failed to write to temporary raw file %s: %w", tempFile.name(), w.err))
+	}
+	return tempFile, hex.EncodeToString(hash.Sum(nil)), err
+}
+
+// tempFileWriter records the first error writing to the temporary file, so that it is not
+// mistaken for an error of the content.
+type tempFileWriter struct {
+	*bufio.Writer
+	err error
+}
+
+func (w *tempFileWriter) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	if err != nil && w.err == nil {
+		w.err = err
 	}
-	return tempFile, hex.EncodeToString(hash.Sum(nil))
+	return n, err
+}
+
+func writeContentToTempFileAndHash(ctx BuilderContext, content string, newline bool) (*tempFile, string) {
+	tempFile, hash, _ := writeToTempFileAndHash(ctx, func(w io.Writer) error {
+		io.WriteString(w, content)
+		if newline {
+			io.WriteString(w, "\n")
+		}
+		return nil
+	})
+	return tempFile, hash
 }
 
 func writeFileRule(ctx BuilderContext, outputFile WritablePath, content string, newline bool, executable bool) {
 	// Write the contents to a temporary file while computing its hash.
 	tempFile, hash := writeContentToTempFileAndHash(ctx, content, newline)
+	rawFileRule(ctx, outputFile, tempFile, hash, executable)
+}
 
+// rawFileRule moves the temporary file with the hash into the raw directory and creates a ninja
+// rule to copy it to outputFile.
+func rawFileRule(ctx BuilderContext, outputFile WritablePath, tempFile *tempFile, hash string, executable bool) {
 	// Shard the final location of the raw file into a subdirectory based on the first two characters of the
 	// hash to avoid making the raw directory too large and slowing down accesses.
 	relPath := filepath.Join(hash[0:2], hash)
@@ -0,0 +1,79 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+type rawFilesTestSingleton struct{}
+
+func (rawFilesTestSingleton) GenerateBuildActions(ctx SingletonContext) {
+	var content strings.Builder
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(&content, "line %d\n", i)
+	}
+	WriteFileRuleVerbatim(ctx, PathForOutput(ctx, "verbatim.txt"), content.String())
+
+	err := WriteFileRuleStreaming(ctx, PathForOutput(ctx, "streamed.txt"), func(w io.Writer) error {
+		for i := 0; i < 1000; i++ {
+			fmt.Fprintf(w, "line %d\n", i)
+		}
+		return nil
+	})
+	if err != nil {
+		ctx.Errorf("unexpected error: %s", err)
+	}
+
+	err = WriteFileRuleStreaming(ctx, PathForOutput(ctx, "failed.txt"), func(w io.Writer) error {
+		io.WriteString(w, "partial")
+		return errors.New("content error")
+	})
+	if err == nil || err.Error() != "content error" {
+		ctx.Errorf("expected content error, got %v", err)
+	}
+}
+
+func TestWriteFileRuleStreaming(t *testing.T) {
+	result := FixtureRegisterWithContext(func(ctx RegistrationContext) {
+		ctx.RegisterParallelSingletonType("raw_files_test", func() Singleton {
+			return &rawFilesTestSingleton{}
+		})
+	}).RunTest(t)
+
+	singleton := result.SingletonForTests("raw_files_test")
+	verbatim := singleton.Output("verbatim.txt")
+	streamed := singleton.Output("streamed.txt")
+
+	AssertStringEquals(t, "streamed content",
+		ContentFromFileRuleForTests(t, result.TestContext, verbatim),
+		ContentFromFileRuleForTests(t, result.TestContext, streamed))
+
+	// The content is only in the raw file, the ninja file only copies it into place.
+	if streamed.Rule != rawFileCopy {
+		t.Errorf("expected rule rawFileCopy, got %q", streamed.Rule)
+	}
+	AssertIntEquals(t, "number of args", 0, len(streamed.Args))
+	AssertStringEquals(t, "raw file", verbatim.Input.String(), streamed.Input.String())
+	AssertStringDoesNotContain(t, "rule command", streamed.RuleParams.Command, "line 0")
+
+	if failed := singleton.MaybeOutput("failed.txt"); failed.Rule != nil {
+		t.Errorf("expected no rule for failed.txt, got %q", failed.Rule)
+	}
+}
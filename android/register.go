@@ -77,7 +77,7 @@ func (s singleton) componentName() string {
 }
 
 func (s singleton) register(ctx *Context) {
-	adaptor := SingletonFactoryAdaptor(ctx, s.factory)
+	adaptor := SingletonFactoryAdaptor(ctx, s.name, s.factory)
 	ctx.RegisterSingletonType(s.name, adaptor, s.parallel)
 }
 
@@ -109,14 +109,14 @@ func ModuleFactoryAdaptor(factory ModuleFactory) blueprint.ModuleFactory {
 type SingletonFactory func() Singleton
 
 // SingletonFactoryAdaptor wraps a SingletonFactory into a blueprint.SingletonFactory by converting
-// a Singleton into a blueprint.Singleton
-func SingletonFactoryAdaptor(ctx *Context, factory SingletonFactory) blueprint.SingletonFactory {
+// a Singleton into a blueprint.Singleton, whose environment variable reads are attributed to name.
+func SingletonFactoryAdaptor(ctx *Context, name string, factory SingletonFactory) blueprint.SingletonFactory {
 	return func() blueprint.Singleton {
 		singleton := factory()
 		if makevars, ok := singleton.(SingletonMakeVarsProvider); ok {
 			ctx.registerSingletonMakeVarsProvider(makevars)
 		}
-		return &singletonAdaptor{Singleton: singleton}
+		return &singletonAdaptor{Singleton: singleton, name: name}
 	}
 }
 
@@ -0,0 +1,139 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"sync"
+
+	"github.com/google/blueprint"
+)
+
+// The image variations that are checked to decide whether a required module has image variants.
+var requiredImageVariations = []string{
+	CoreVariation,
+	RamdiskVariation,
+	VendorRamdiskVariation,
+	DebugRamdiskVariation,
+	RecoveryVariation,
+}
+
+var requiredImageVariantCacheOnceKey = NewOnceKey("required_image_variant_cache")
+
+// requiredImageVariantKey identifies a check of whether a required module has a variant for an OS
+// and an image.  The names are resolved in the namespace of the requiring module, so modules in
+// different namespaces don't share the results for the same name.
+type requiredImageVariantKey struct {
+	namespace string
+	name      string
+	os        string
+	image     string
+}
+
+// requiredImageVariantCache caches the checks of the image variants of required modules for the
+// whole analysis.  Every device module probes up to all the image variations of each module it
+// requires, and OtherModuleFarDependencyVariantExists searches all the variants of the module each
+// time, which adds up for widely required modules.  The os and image variants are all created
+// before the deps mutator, so the results don't change while it runs.
+type requiredImageVariantCache struct {
+	results sync.Map
+}
+
+func getRequiredImageVariantCache(config Config) *requiredImageVariantCache {
+	return config.Once(requiredImageVariantCacheOnceKey, func() interface{} {
+		return &requiredImageVariantCache{}
+	}).(*requiredImageVariantCache)
+}
+
+// exists returns the cached result of the check, calling probe to compute it the first time.
+func (c *requiredImageVariantCache) exists(key requiredImageVariantKey, probe func() bool) bool {
+	if result, ok := c.results.Load(key); ok {
+		return result.(bool)
+	}
+	// Concurrent misses may both probe, they get the same result.
+	result, _ := c.results.LoadOrStore(key, probe())
+	return result.(bool)
+}
+
+// requiredImageVariantExists returns true if the module has a variant for the image and the OS of
+// the current module.
+func requiredImageVariantExists(ctx BottomUpMutatorContext, name, image string) bool {
+	osVariation := ctx.Target().OsVariation()
+	probe := func() bool {
+		return ctx.OtherModuleFarDependencyVariantExists([]blueprint.Variation{
+			{Mutator: "os", Variation: osVariation},
+			{Mutator: "image", Variation: image},
+		}, name)
+	}
+	if ctx.Config().disableRequiredImageVariantCache {
+		return probe()
+	}
+	key := requiredImageVariantKey{
+		namespace: ctx.Namespace().Path,
+		name:      name,
+		os:        osVariation,
+		image:     image,
+	}
+	return getRequiredImageVariantCache(ctx.Config()).exists(key, probe)
+}
+
+// pruneRequiredImageVariants checks that each Soong module listed in the required property of a
+// device module has a variant that can be installed alongside it.  The variant for the same image as
+// the module is preferred, followed by the core variant.  Required modules that have image variants
+// but none of them compatible, e.g. a vendor_ramdisk only module required by a system module, are
+// skipped with a warning in the variant skip report and are not exported to Make.
+//
+// Required modules that don't have any image variants, e.g. java libraries, are treated as core
+// modules.  Names that do not match any Soong module are kept as they may refer to modules defined
+// in Android.mk files.
+func (m *ModuleBase) pruneRequiredImageVariants(ctx BottomUpMutatorContext) {
+	if ctx.Os() != Android {
+		return
+	}
+	image := m.commonProperties.ImageVariation
+	imageName := image
+	if image == CoreVariation {
+		imageName = "core"
+	}
+
+	for _, name := range ctx.Module().RequiredModuleNames() {
+		if !ctx.OtherModuleExists(name) {
+			continue
+		}
+		if requiredImageVariantExists(ctx, name, image) {
+			recordVariantSkipDecision(ctx, "required %q: using the %s variant", name, imageName)
+			continue
+		}
+		if image != CoreVariation && requiredImageVariantExists(ctx, name, CoreVariation) {
+			recordVariantSkipDecision(ctx, "required %q: no %s variant, using the core variant", name, imageName)
+			continue
+		}
+
+		hasImageVariants := false
+		for _, variation := range requiredImageVariations {
+			if requiredImageVariantExists(ctx, name, variation) {
+				hasImageVariants = true
+				break
+			}
+		}
+		if hasImageVariants {
+			if image == CoreVariation {
+				recordVariantSkipDecision(ctx, "warning: required %q: skipped, no core variant", name)
+			} else {
+				recordVariantSkipDecision(ctx, "warning: required %q: skipped, no %s or core variant", name, imageName)
+			}
+			m.skippedRequired = append(m.skippedRequired, name)
+		}
+	}
+}
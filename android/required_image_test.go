@@ -0,0 +1,307 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type requiredImageTestModule struct {
+	ModuleBase
+	properties struct {
+		Core_available           *bool
+		Recovery_available       *bool
+		Vendor_ramdisk_available *bool
+	}
+}
+
+func (m *requiredImageTestModule) GenerateAndroidBuildActions(ctx ModuleContext) {}
+
+func (m *requiredImageTestModule) AndroidMkEntries() []AndroidMkEntries {
+	return []AndroidMkEntries{{
+		Class:      "FAKE",
+		OutputFile: OptionalPathForPath(PathForTesting("out")),
+	}}
+}
+
+func (m *requiredImageTestModule) ImageMutatorBegin(ctx BaseModuleContext) {}
+
+func (m *requiredImageTestModule) CoreVariantNeeded(ctx BaseModuleContext) bool {
+	return BoolDefault(m.properties.Core_available, true)
+}
+
+func (m *requiredImageTestModule) RamdiskVariantNeeded(ctx BaseModuleContext) bool {
+	return false
+}
+
+func (m *requiredImageTestModule) VendorRamdiskVariantNeeded(ctx BaseModuleContext) bool {
+	return Bool(m.properties.Vendor_ramdisk_available)
+}
+
+func (m *requiredImageTestModule) DebugRamdiskVariantNeeded(ctx BaseModuleContext) bool {
+	return false
+}
+
+func (m *requiredImageTestModule) RecoveryVariantNeeded(ctx BaseModuleContext) bool {
+	return Bool(m.properties.Recovery_available)
+}
+
+func (m *requiredImageTestModule) ExtraImageVariations(ctx BaseModuleContext) []string {
+	return nil
+}
+
+func (m *requiredImageTestModule) SetImageVariation(ctx BaseModuleContext, variation string, module Module) {
+}
+
+func requiredImageTestModuleFactory() Module {
+	m := &requiredImageTestModule{}
+	m.AddProperties(&m.properties)
+	InitAndroidArchModule(m, DeviceSupported, MultilibCommon)
+	return m
+}
+
+var prepareForRequiredImageTest = GroupFixturePreparers(
+	PrepareForTestWithArchMutator,
+	FixtureRegisterWithContext(func(ctx RegistrationContext) {
+		ctx.RegisterModuleType("image_test", requiredImageTestModuleFactory)
+		ctx.RegisterModuleType("deps", depsModuleFactory)
+		RegisterVariantSkipReportBuildComponents(ctx)
+	}),
+	FixtureMergeEnv(map[string]string{
+		"SOONG_VARIANT_SKIP_REPORT": "true",
+	}),
+)
+
+func TestRequiredImageVariants(t *testing.T) {
+	bp := `
+		image_test {
+			name: "core_only",
+		}
+
+		image_test {
+			name: "core_and_recovery",
+			recovery_available: true,
+		}
+
+		image_test {
+			name: "recovery_only",
+			core_available: false,
+			recovery_available: true,
+		}
+
+		image_test {
+			name: "vendor_ramdisk_only",
+			core_available: false,
+			vendor_ramdisk_available: true,
+		}
+
+		// A module without image variants is treated as a core module.
+		deps {
+			name: "no_image",
+		}
+
+		image_test {
+			name: "core_requirer",
+			required: ["core_only", "core_and_recovery", "recovery_only", "vendor_ramdisk_only", "no_image", "make_module"],
+		}
+
+		image_test {
+			name: "recovery_requirer",
+			core_available: false,
+			recovery_available: true,
+			required: ["core_only", "core_and_recovery", "recovery_only", "vendor_ramdisk_only", "no_image", "make_module"],
+		}
+
+		image_test {
+			name: "vendor_ramdisk_requirer",
+			core_available: false,
+			vendor_ramdisk_available: true,
+			required: ["core_only", "core_and_recovery", "recovery_only", "vendor_ramdisk_only", "no_image", "make_module"],
+		}
+	`
+
+	result := prepareForRequiredImageTest.RunTestWithBp(t, bp)
+
+	testCases := []struct {
+		name     string
+		variant  string
+		expected []string
+	}{
+		{
+			name:     "core_requirer",
+			variant:  "android_common",
+			expected: []string{"core_and_recovery", "core_only", "make_module", "no_image"},
+		},
+		{
+			name:     "recovery_requirer",
+			variant:  "android_recovery_common",
+			expected: []string{"core_and_recovery", "core_only", "make_module", "no_image", "recovery_only"},
+		},
+		{
+			name:     "vendor_ramdisk_requirer",
+			variant:  "android_vendor_ramdisk_common",
+			expected: []string{"core_and_recovery", "core_only", "make_module", "no_image", "vendor_ramdisk_only"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			module := result.ModuleForTests(tc.name, tc.variant).Module()
+			entries := AndroidMkEntriesForTest(t, result.TestContext, module)[0]
+			AssertArrayString(t, "LOCAL_REQUIRED_MODULES", tc.expected, SortedUniqueStrings(entries.EntryMap["LOCAL_REQUIRED_MODULES"]))
+		})
+	}
+
+	report := result.SingletonForTests("variant_skip_report").Output("variant_skip_report.txt")
+	AssertStringEquals(t, "variant skip report", strings.Join([]string{
+		`core_requirer (android_common): required "core_and_recovery": using the core variant`,
+		`core_requirer (android_common): required "core_only": using the core variant`,
+		`core_requirer (android_common): warning: required "recovery_only": skipped, no core variant`,
+		`core_requirer (android_common): warning: required "vendor_ramdisk_only": skipped, no core variant`,
+		`recovery_requirer (android_recovery_common): required "core_and_recovery": using the recovery variant`,
+		`recovery_requirer (android_recovery_common): required "core_only": no recovery variant, using the core variant`,
+		`recovery_requirer (android_recovery_common): required "recovery_only": using the recovery variant`,
+		`recovery_requirer (android_recovery_common): warning: required "vendor_ramdisk_only": skipped, no recovery or core variant`,
+		`vendor_ramdisk_requirer (android_vendor_ramdisk_common): required "core_and_recovery": no vendor_ramdisk variant, using the core variant`,
+		`vendor_ramdisk_requirer (android_vendor_ramdisk_common): required "core_only": no vendor_ramdisk variant, using the core variant`,
+		`vendor_ramdisk_requirer (android_vendor_ramdisk_common): required "vendor_ramdisk_only": using the vendor_ramdisk variant`,
+		`vendor_ramdisk_requirer (android_vendor_ramdisk_common): warning: required "recovery_only": skipped, no vendor_ramdisk or core variant`,
+	}, "\n"), ContentFromFileRuleForTests(t, result.TestContext, report))
+}
+
+func TestRequiredImageVariantCache(t *testing.T) {
+	// The same names are required from two namespaces in which they refer to different modules, so
+	// the results of one namespace must not be reused in the other.
+	namespaceBp := func(recovery bool) string {
+		return fmt.Sprintf(`
+			soong_namespace {}
+
+			image_test {
+				name: "dup",
+				core_available: %t,
+				recovery_available: %t,
+			}
+
+			image_test {
+				name: "dup_requirer",
+				required: ["dup", "shared"],
+			}
+
+			image_test {
+				name: "dup_recovery_requirer",
+				core_available: false,
+				recovery_available: true,
+				required: ["dup", "shared"],
+			}
+		`, !recovery, recovery)
+	}
+	bp := `
+		image_test {
+			name: "shared",
+			recovery_available: true,
+		}
+	`
+
+	run := func(t *testing.T, disableCache bool) *TestResult {
+		return GroupFixturePreparers(
+			prepareForRequiredImageTest,
+			PrepareForTestWithNamespace,
+			FixtureAddTextFile("core/Android.bp", namespaceBp(false)),
+			FixtureAddTextFile("recovery/Android.bp", namespaceBp(true)),
+			FixtureModifyConfig(func(config Config) {
+				config.disableRequiredImageVariantCache = disableCache
+			}),
+		).RunTestWithBp(t, bp)
+	}
+	cached := run(t, false)
+	uncached := run(t, true)
+
+	for _, tc := range []struct {
+		name    string
+		variant string
+	}{
+		{"//core:dup_requirer", "android_common"},
+		{"//core:dup_recovery_requirer", "android_recovery_common"},
+		{"//recovery:dup_requirer", "android_common"},
+		{"//recovery:dup_recovery_requirer", "android_recovery_common"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			required := func(result *TestResult) []string {
+				module := result.ModuleForTests(tc.name, tc.variant).Module()
+				entries := AndroidMkEntriesForTest(t, result.TestContext, module)[0]
+				return SortedUniqueStrings(entries.EntryMap["LOCAL_REQUIRED_MODULES"])
+			}
+			AssertArrayString(t, "LOCAL_REQUIRED_MODULES", required(uncached), required(cached))
+		})
+	}
+
+	report := func(result *TestResult) string {
+		return ContentFromFileRuleForTests(t, result.TestContext,
+			result.SingletonForTests("variant_skip_report").Output("variant_skip_report.txt"))
+	}
+	AssertStringEquals(t, "variant skip report", report(uncached), report(cached))
+	AssertStringDoesContain(t, "variant skip report", report(cached),
+		`dup_requirer (android_common): warning: required "dup": skipped, no core variant`)
+	AssertStringDoesContain(t, "variant skip report", report(cached),
+		`dup_requirer (android_common): required "dup": using the core variant`)
+}
+
+// BenchmarkPruneRequiredImageVariants resolves the dependencies of many core and recovery modules
+// that require the same modules, with and without caching the image variant checks.
+func BenchmarkPruneRequiredImageVariants(b *testing.B) {
+	const requirers = 500
+	const requiredModules = 20
+
+	bp := &strings.Builder{}
+	var required []string
+	for i := 0; i < requiredModules; i++ {
+		name := fmt.Sprintf("required%d", i)
+		required = append(required, fmt.Sprintf("%q", name))
+		fmt.Fprintf(bp, "image_test {\n\tname: %q,\n\trecovery_available: %t,\n}\n", name, i%2 == 0)
+	}
+	for i := 0; i < requirers; i++ {
+		fmt.Fprintf(bp, "image_test {\n\tname: \"requirer%d\",\n\trecovery_available: true,\n\trequired: [%s],\n}\n",
+			i, strings.Join(required, ", "))
+	}
+
+	for _, disableCache := range []bool{false, true} {
+		name := "cached"
+		if disableCache {
+			name = "uncached"
+		}
+		b.Run(name, func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				b.StopTimer()
+				config := TestArchConfig(b.TempDir(), nil, bp.String(), nil)
+				config.disableRequiredImageVariantCache = disableCache
+				ctx := NewTestArchContext(config)
+				ctx.RegisterModuleType("image_test", requiredImageTestModuleFactory)
+				ctx.Register()
+				_, errs := ctx.ParseFileList(".", []string{"Android.bp"})
+				if len(errs) > 0 {
+					b.Fatal(errs)
+				}
+				b.StartTimer()
+
+				// The required modules are pruned while resolving the dependencies.
+				if _, errs := ctx.ResolveDependencies(config); len(errs) > 0 {
+					b.Fatal(errs)
+				}
+			}
+		})
+	}
+}
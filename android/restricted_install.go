@@ -0,0 +1,79 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+// Modules with restricted_install: true, e.g. security sensitive debug tools, must only be installed
+// by an explicit per build opt-in through the RestrictedInstallAllowlist product variable.  Unless
+// they are in it, it is an error for them to be required by another module or to be listed in
+// PRODUCT_PACKAGES, as both would install them.
+
+func init() {
+	RegisterRestrictedInstallBuildComponents(InitRegistrationContext)
+}
+
+func RegisterRestrictedInstallBuildComponents(ctx RegistrationContext) {
+	ctx.RegisterParallelSingletonType("restricted_install", restrictedInstallSingletonFactory)
+}
+
+// PrepareForTestWithRestrictedInstall registers the singleton that checks the installation of
+// restricted_install modules.
+var PrepareForTestWithRestrictedInstall = FixtureRegisterWithContext(RegisterRestrictedInstallBuildComponents)
+
+func restrictedInstallSingletonFactory() Singleton {
+	return &restrictedInstallSingleton{}
+}
+
+// restrictedInstallSingleton reports the modules and product packages that install
+// restricted_install modules that are not in the RestrictedInstallAllowlist.
+type restrictedInstallSingleton struct{}
+
+func (s *restrictedInstallSingleton) GenerateBuildActions(ctx SingletonContext) {
+	allowlist := ctx.DeviceConfig().RestrictedInstallAllowlist()
+	restricted := make(map[string]bool)
+	ctx.VisitAllModules(func(module Module) {
+		name := ctx.ModuleName(module)
+		if Bool(module.base().commonProperties.Restricted_install) && !InList(name, allowlist) {
+			restricted[name] = true
+		}
+	})
+	if len(restricted) == 0 {
+		return
+	}
+
+	reported := make(map[string]bool)
+	ctx.VisitAllModules(func(module Module) {
+		if !module.Enabled() {
+			return
+		}
+		required := Concat(module.RequiredModuleNames(), module.HostRequiredModuleNames())
+		required = append(required, module.TargetRequiredModuleNames()...)
+		for _, name := range FirstUniqueStrings(required) {
+			key := ctx.ModuleName(module) + ":" + name
+			if !restricted[name] || reported[key] {
+				continue
+			}
+			reported[key] = true
+			ctx.ModuleErrorf(module, "requires %q, which has restricted_install: true and is not in "+
+				"RestrictedInstallAllowlist", name)
+		}
+	})
+
+	for _, name := range FirstUniqueStrings(ctx.DeviceConfig().ProductPackages()) {
+		if restricted[name] {
+			ctx.Errorf("PRODUCT_PACKAGES contains %q, which has restricted_install: true and is not in "+
+				"RestrictedInstallAllowlist", name)
+		}
+	}
+}
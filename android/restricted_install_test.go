@@ -0,0 +1,70 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"testing"
+)
+
+var prepareForRestrictedInstallTest = GroupFixturePreparers(
+	PrepareForTestWithRestrictedInstall,
+	FixtureRegisterWithContext(func(ctx RegistrationContext) {
+		ctx.RegisterModuleType("fake", fakeModuleFactory)
+	}),
+	FixtureWithRootAndroidBp(`
+		fake {
+			name: "debug_tool",
+			restricted_install: true,
+		}
+
+		fake {
+			name: "requirer",
+			required: ["debug_tool"],
+		}
+	`),
+)
+
+func withRestrictedInstallProductVariables(allowlist, packages []string) FixturePreparer {
+	return FixtureModifyProductVariables(func(variables FixtureProductVariables) {
+		variables.RestrictedInstallAllowlist = allowlist
+		variables.ProductPackages = packages
+	})
+}
+
+func TestRestrictedInstallAllowed(t *testing.T) {
+	GroupFixturePreparers(
+		prepareForRestrictedInstallTest,
+		withRestrictedInstallProductVariables([]string{"debug_tool"}, []string{"debug_tool"}),
+	).RunTest(t)
+}
+
+func TestRestrictedInstallDeniedViaRequired(t *testing.T) {
+	GroupFixturePreparers(
+		prepareForRestrictedInstallTest,
+		withRestrictedInstallProductVariables(nil, nil),
+	).ExtendWithErrorHandler(FixtureExpectsOneErrorPattern(
+		`module "requirer": requires "debug_tool", which has restricted_install: true and is not in RestrictedInstallAllowlist`)).
+		RunTest(t)
+}
+
+func TestRestrictedInstallDeniedViaPackages(t *testing.T) {
+	GroupFixturePreparers(
+		prepareForRestrictedInstallTest,
+		withRestrictedInstallProductVariables(nil, []string{"debug_tool"}),
+	).ExtendWithErrorHandler(FixtureExpectsAllErrorsToMatchAPattern([]string{
+		`module "requirer": requires "debug_tool"`,
+		`PRODUCT_PACKAGES contains "debug_tool", which has restricted_install: true and is not in RestrictedInstallAllowlist`,
+	})).RunTest(t)
+}
@@ -39,6 +39,8 @@ type SingletonContext interface {
 
 	ModuleErrorf(module blueprint.Module, format string, args ...interface{})
 	Errorf(format string, args ...interface{})
+	// ModuleWarningf reports a warning about the module that doesn't fail the build.
+	ModuleWarningf(module blueprint.Module, format string, args ...interface{})
 	Failed() bool
 
 	Variable(pctx PackageContext, name, value string)
@@ -92,6 +94,8 @@ type SingletonContext interface {
 type singletonAdaptor struct {
 	Singleton
 
+	name string
+
 	buildParams []BuildParams
 	ruleParams  map[blueprint.Rule]blueprint.RuleParams
 }
@@ -99,7 +103,7 @@ type singletonAdaptor struct {
 var _ testBuildProvider = (*singletonAdaptor)(nil)
 
 func (s *singletonAdaptor) GenerateBuildActions(ctx blueprint.SingletonContext) {
-	sctx := &singletonContextAdaptor{SingletonContext: ctx}
+	sctx := &singletonContextAdaptor{SingletonContext: ctx, name: s.name}
 	if sctx.Config().captureBuild {
 		sctx.ruleParams = make(map[blueprint.Rule]blueprint.RuleParams)
 	}
@@ -125,6 +129,9 @@ type Singleton interface {
 type singletonContextAdaptor struct {
 	blueprint.SingletonContext
 
+	// The name the singleton was registered with.
+	name string
+
 	buildParams []BuildParams
 	ruleParams  map[blueprint.Rule]blueprint.RuleParams
 }
@@ -134,13 +141,17 @@ func (s *singletonContextAdaptor) blueprintSingletonContext() blueprint.Singleto
 }
 
 func (s *singletonContextAdaptor) Config() Config {
-	return s.SingletonContext.Config().(Config)
+	return s.SingletonContext.Config().(Config).withEnvConsumer(s.name)
 }
 
 func (s *singletonContextAdaptor) DeviceConfig() DeviceConfig {
 	return DeviceConfig{s.Config().deviceConfig}
 }
 
+func (s *singletonContextAdaptor) ModuleWarningf(module blueprint.Module, format string, args ...interface{}) {
+	addBuildWarning(s.Config(), s.BlueprintFile(module), s.ModuleName(module), "", format, args...)
+}
+
 func (s *singletonContextAdaptor) Variable(pctx PackageContext, name, value string) {
 	s.SingletonContext.Variable(pctx.PackageContext, name, value)
 }
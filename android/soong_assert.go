@@ -0,0 +1,163 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+func init() {
+	RegisterSoongAssertBuildComponents(InitRegistrationContext)
+}
+
+func RegisterSoongAssertBuildComponents(ctx RegistrationContext) {
+	ctx.RegisterModuleType("soong_assert", SoongAssertFactory)
+	ctx.RegisterParallelSingletonType("soong_assert", soongAssertSingletonFactory)
+}
+
+var PrepareForTestWithSoongAssert = FixtureRegisterWithContext(RegisterSoongAssertBuildComponents)
+
+type soongAssertProperties struct {
+	// Whether the assertions apply, defaults to true.  It is usually set from a soong config
+	// variable through a soong_config_module_type so that the assertions only apply to some
+	// products.
+	Condition *bool
+
+	// The message of the error reported when an assertion fails.
+	Message *string
+
+	// Modules that must have an installed variant.
+	Module_installed []string
+
+	// Modules that must not have any installed variant.
+	Module_not_installed []string
+
+	// Product variables that must have a value, as NAME=VALUE where NAME is the name of the
+	// variable in soong.variables, e.g. DeviceName=generic.  Lists are compared as space separated
+	// values and unset variables as empty strings.
+	Variable_equals []string
+}
+
+type soongAssertVariable struct {
+	name  string
+	value string
+}
+
+type soongAssertModule struct {
+	ModuleBase
+
+	properties soongAssertProperties
+
+	// The parsed variable_equals property.
+	variables []soongAssertVariable
+}
+
+// soong_assert checks invariants of the product configuration, e.g. that a module is installed
+// when a soong config variable is set, and fails the build with its message if one doesn't hold.
+// The assertions are checked after all modules have been analyzed, so modules are considered
+// installed if they have an enabled variant that installs files; whether Make installs them in the
+// product is not checked.
+func SoongAssertFactory() Module {
+	module := &soongAssertModule{}
+	module.AddProperties(&module.properties)
+	InitAndroidModule(module)
+	return module
+}
+
+func (m *soongAssertModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+	m.variables = nil
+	for _, assignment := range m.properties.Variable_equals {
+		name, value, ok := strings.Cut(assignment, "=")
+		if !ok {
+			ctx.PropertyErrorf("variable_equals", "%q must be NAME=VALUE", assignment)
+			continue
+		}
+		if _, found := productVariableString(ctx.Config(), name); !found {
+			ctx.PropertyErrorf("variable_equals", "unknown product variable %q", name)
+			continue
+		}
+		m.variables = append(m.variables, soongAssertVariable{name: name, value: value})
+	}
+}
+
+func (m *soongAssertModule) message() string {
+	return StringDefault(m.properties.Message, "soong_assert failed")
+}
+
+// productVariableString returns the value of the product variable with the name, as it is
+// written in soong.variables, formatted as a string.
+func productVariableString(config Config, name string) (string, bool) {
+	v := reflect.ValueOf(config.productVariables).FieldByName(name)
+	if !v.IsValid() {
+		return "", false
+	}
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return "", true
+		}
+		v = v.Elem()
+	}
+	if strs, ok := v.Interface().([]string); ok {
+		return strings.Join(strs, " "), true
+	}
+	return fmt.Sprint(v.Interface()), true
+}
+
+func soongAssertSingletonFactory() Singleton {
+	return &soongAssertSingleton{}
+}
+
+// soongAssertSingleton checks the assertions of the soong_assert modules.
+type soongAssertSingleton struct{}
+
+func (s *soongAssertSingleton) GenerateBuildActions(ctx SingletonContext) {
+	// The names of all Soong modules, and whether they have an installed variant.
+	installed := make(map[string]bool)
+	var asserts []*soongAssertModule
+	ctx.VisitAllModules(func(module Module) {
+		if m, ok := module.(*soongAssertModule); ok {
+			asserts = append(asserts, m)
+			return
+		}
+		base := module.base()
+		isInstalled := module.Enabled() && !base.IsHideFromMake() && !base.IsSkipInstall() &&
+			len(base.FilesToInstall()) > 0
+		name := RemoveOptionalPrebuiltPrefix(ctx.ModuleName(module))
+		installed[name] = installed[name] || isInstalled
+	})
+
+	for _, m := range asserts {
+		if !m.Enabled() || !BoolDefault(m.properties.Condition, true) {
+			continue
+		}
+		for _, name := range m.properties.Module_installed {
+			if !installed[name] {
+				ctx.ModuleErrorf(m, "%s: module_installed: %q is not installed", m.message(), name)
+			}
+		}
+		for _, name := range m.properties.Module_not_installed {
+			if installed[name] {
+				ctx.ModuleErrorf(m, "%s: module_not_installed: %q is installed", m.message(), name)
+			}
+		}
+		for _, variable := range m.variables {
+			if value, _ := productVariableString(ctx.Config(), variable.name); value != variable.value {
+				ctx.ModuleErrorf(m, "%s: variable_equals: %s is %q, expected %q", m.message(), variable.name, value, variable.value)
+			}
+		}
+	}
+}
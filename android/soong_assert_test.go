@@ -0,0 +1,184 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/google/blueprint/proptools"
+)
+
+type soongAssertTestModule struct {
+	ModuleBase
+	properties struct {
+		No_install *bool
+	}
+}
+
+func (m *soongAssertTestModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+	if !Bool(m.properties.No_install) {
+		ctx.InstallFile(PathForModuleInstall(ctx, "bin"), ctx.ModuleName(), PathForTesting("src"))
+	}
+}
+
+func soongAssertTestModuleFactory() Module {
+	m := &soongAssertTestModule{}
+	m.AddProperties(&m.properties)
+	InitAndroidArchModule(m, DeviceSupported, MultilibCommon)
+	return m
+}
+
+func TestSoongAssert(t *testing.T) {
+	modules := `
+		soong_assert_test {
+			name: "installed",
+		}
+
+		soong_assert_test {
+			name: "not_installed",
+			no_install: true,
+		}
+
+		soong_assert_test {
+			name: "disabled",
+			enabled: false,
+		}
+
+		soong_config_module_type {
+			name: "acme_soong_assert",
+			module_type: "soong_assert",
+			config_namespace: "acme",
+			bool_variables: ["feature"],
+			properties: ["condition"],
+		}
+	`
+
+	testCases := []struct {
+		name          string
+		bp            string
+		feature       bool
+		expectedError string
+	}{
+		{
+			name: "passing",
+			bp: `
+				soong_assert {
+					name: "assert",
+					module_installed: ["installed"],
+					module_not_installed: ["not_installed", "disabled", "unknown"],
+					variable_equals: ["DeviceName=test_device", "Platform_sdk_codename=S", "DeviceAbi="],
+				}
+			`,
+		},
+		{
+			name: "module_installed",
+			bp: `
+				soong_assert {
+					name: "assert",
+					message: "the feature needs not_installed",
+					module_installed: ["not_installed", "disabled"],
+				}
+			`,
+			expectedError: `module "assert": the feature needs not_installed: module_installed: "not_installed" is not installed`,
+		},
+		{
+			name: "module_not_installed",
+			bp: `
+				soong_assert {
+					name: "assert",
+					module_not_installed: ["installed"],
+				}
+			`,
+			expectedError: `module "assert": soong_assert failed: module_not_installed: "installed" is installed`,
+		},
+		{
+			name: "variable_equals",
+			bp: `
+				soong_assert {
+					name: "assert",
+					message: "wrong device",
+					variable_equals: ["DeviceName=other_device"],
+				}
+			`,
+			expectedError: `module "assert": wrong device: variable_equals: DeviceName is "test_device", expected "other_device"`,
+		},
+		{
+			name: "unknown variable",
+			bp: `
+				soong_assert {
+					name: "assert",
+					variable_equals: ["NotAVariable=true"],
+				}
+			`,
+			expectedError: `module "assert": variable_equals: unknown product variable "NotAVariable"`,
+		},
+		{
+			name: "condition false",
+			bp: `
+				acme_soong_assert {
+					name: "assert",
+					condition: false,
+					soong_config_variables: {
+						feature: {
+							condition: true,
+						},
+					},
+					module_installed: ["not_installed"],
+				}
+			`,
+		},
+		{
+			name: "condition true",
+			bp: `
+				acme_soong_assert {
+					name: "assert",
+					condition: false,
+					soong_config_variables: {
+						feature: {
+							condition: true,
+						},
+					},
+					module_installed: ["not_installed"],
+				}
+			`,
+			feature:       true,
+			expectedError: `module "assert": soong_assert failed: module_installed: "not_installed" is not installed`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			errorHandler := FixtureExpectsNoErrors
+			if tc.expectedError != "" {
+				errorHandler = FixtureExpectsOneErrorPattern(regexp.QuoteMeta(tc.expectedError))
+			}
+			GroupFixturePreparers(
+				PrepareForTestWithArchMutator,
+				PrepareForTestWithSoongConfigModuleBuildComponents,
+				PrepareForTestWithSoongAssert,
+				FixtureRegisterWithContext(func(ctx RegistrationContext) {
+					ctx.RegisterModuleType("soong_assert_test", soongAssertTestModuleFactory)
+				}),
+				FixtureModifyProductVariables(func(variables FixtureProductVariables) {
+					variables.DeviceName = proptools.StringPtr("test_device")
+					if tc.feature {
+						variables.VendorVars = map[string]map[string]string{"acme": {"feature": "true"}}
+					}
+				}),
+			).ExtendWithErrorHandler(errorHandler).RunTestWithBp(t, modules+tc.bp)
+		})
+	}
+}
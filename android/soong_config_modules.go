@@ -272,6 +272,11 @@ type soongConfigModuleTypeModule struct {
 //	SOONG_CONFIG_acme_width := 200
 //
 // Then libacme_foo would build with cflags "-DGENERIC -DSOC_A -DFEATURE".
+//
+// The properties common to all modules, e.g. dist, dists and required, can be made conditional
+// in the same way as the properties of the module type.  They are applied before the arch
+// variants, so arch specific values such as target.android.dist.dir still apply to a dist that
+// is enabled by a variable, and value variables are substituted into each entry of dists.
 func SoongConfigModuleTypeFactory() Module {
 	module := &soongConfigModuleTypeModule{}
 
@@ -17,6 +17,8 @@ package android
 import (
 	"fmt"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
 )
 
@@ -342,6 +344,122 @@ func TestSoongConfigModule(t *testing.T) {
 	})
 }
 
+func TestSoongConfigModuleCommonProperties(t *testing.T) {
+	if runtime.GOOS == "darwin" {
+		// Device modules are not exported on Mac, so this test doesn't work.
+		t.SkipNow()
+	}
+
+	bp := `
+		soong_config_module_type {
+			name: "acme_custom_arch",
+			module_type: "custom_arch",
+			config_namespace: "acme",
+			bool_variables: ["feature"],
+			value_variables: ["board"],
+			properties: ["dist", "dists", "required"],
+		}
+
+		acme_custom_arch {
+			name: "foo",
+			required: ["common_dep"],
+			target: {
+				android: {
+					dist: {
+						dir: "device",
+					},
+					required: ["device_dep"],
+				},
+			},
+			soong_config_variables: {
+				feature: {
+					dist: {
+						targets: ["feature_goal"],
+					},
+					required: ["feature_dep"],
+					conditions_default: {
+						required: ["default_dep"],
+					},
+				},
+				board: {
+					dists: [
+						{
+							targets: ["board_goal"],
+							dest: "%s.out",
+						},
+					],
+				},
+			},
+		}
+	`
+
+	run := func(t *testing.T, vars map[string]string) *TestResult {
+		t.Helper()
+		return GroupFixturePreparers(
+			PrepareForTestWithAndroidMk,
+			PrepareForTestWithArchMutator,
+			PrepareForTestWithSoongConfigModuleBuildComponents,
+			FixtureRegisterWithContext(func(ctx RegistrationContext) {
+				ctx.RegisterModuleType("custom_arch", customArchModuleFactory)
+			}),
+			FixtureModifyProductVariables(func(variables FixtureProductVariables) {
+				variables.VendorVars = map[string]map[string]string{"acme": vars}
+			}),
+			FixtureWithRootAndroidBp(bp),
+		).RunTest(t)
+	}
+
+	makeText := func(t *testing.T, result *TestResult, variant string) string {
+		t.Helper()
+		module := result.ModuleForTests("foo", variant).Module()
+		buf := &strings.Builder{}
+		for _, entries := range AndroidMkEntriesForTest(t, result.TestContext, module) {
+			entries.write(buf)
+		}
+		return buf.String()
+	}
+
+	device := "android_arm64_armv8-a"
+
+	t.Run("set", func(t *testing.T) {
+		result := run(t, map[string]string{"feature": "true", "board": "soc_a"})
+		host := result.Config.BuildOSTarget.String()
+
+		foo := result.ModuleForTests("foo", device).Module()
+		AssertArrayString(t, "device required", []string{"common_dep", "device_dep", "feature_dep"},
+			SortedUniqueStrings(foo.RequiredModuleNames()))
+		fooHost := result.ModuleForTests("foo", host).Module()
+		AssertArrayString(t, "host required", []string{"common_dep", "feature_dep"},
+			SortedUniqueStrings(fooHost.RequiredModuleNames()))
+
+		AssertDistsTo(t, result, "foo", device, "feature_goal", "device/one.out")
+		AssertDistsTo(t, result, "foo", host, "feature_goal", "one.out")
+		AssertDistsTo(t, result, "foo", device, "board_goal", "soc_a.out")
+
+		mk := makeText(t, result, device)
+		AssertStringDoesContain(t, "make dist", mk, "$(call dist-for-goals,feature_goal,one.out:device/one.out)\n")
+		AssertStringDoesContain(t, "make dists", mk, "$(call dist-for-goals,board_goal,one.out:soc_a.out)\n")
+		AssertStringDoesContain(t, "make required", mk, "feature_dep")
+		AssertStringDoesNotContain(t, "make required", mk, "default_dep")
+	})
+
+	t.Run("unset", func(t *testing.T) {
+		result := run(t, map[string]string{})
+
+		foo := result.ModuleForTests("foo", device).Module()
+		AssertArrayString(t, "device required", []string{"common_dep", "default_dep", "device_dep"},
+			SortedUniqueStrings(foo.RequiredModuleNames()))
+
+		AssertDeepEquals(t, "goals", []TestingDistCopiesForGoals(nil),
+			DistContributionsForTest(t, result, "foo", device).CopiesForGoals)
+
+		mk := makeText(t, result, device)
+		AssertStringDoesNotContain(t, "make dist", mk, "dist-for-goals")
+		AssertStringDoesContain(t, "make required", mk, "default_dep")
+		AssertStringDoesNotContain(t, "make required", mk, "feature_dep")
+	})
+}
+
 func TestNonExistentPropertyInSoongConfigModule(t *testing.T) {
 	bp := `
 		soong_config_module_type {
@@ -0,0 +1,168 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/blueprint"
+)
+
+// When Kati runs, the dist copies of the modules are translated to dist-for-goals calls and Make
+// decides which of them to run.  In Soong only builds the goals being built are passed in
+// SOONG_DIST_GOALS instead, and the soong_dist singleton copies the files of the dists for those
+// goals to out/soong/dist, and lists them with the stable_id of the module that dists them in
+// out/soong/dist/dist_manifest.txt.  As with Make's $(filter), a goal of a dist may contain a %
+// wildcard, and the dist is active if any of the goals being built matches it.
+//
+// In all builds, each module that has dists also gets a <module>-dist phony target, which copies just
+// the dist files of the module, for any goal, through the dist staging directory to $DIST_DIR.  They
+// are created by the soong_dist singleton in Soong only builds, and by the androidmk singleton when
+// Kati runs, as the Android.mk entries of the modules are only filled in by one singleton.
+
+func init() {
+	RegisterSoongDistBuildComponents(InitRegistrationContext)
+}
+
+func RegisterSoongDistBuildComponents(ctx RegistrationContext) {
+	ctx.RegisterParallelSingletonType("soong_dist", soongDistSingletonFactory)
+}
+
+func soongDistSingletonFactory() Singleton {
+	return &soongDistSingleton{}
+}
+
+// soongDistCopy is the source of a file in the dist staging directory.
+type soongDistCopy struct {
+	from     Path
+	stableId string
+}
+
+// soongDistSingleton copies the files of the active dists of Soong only builds to the dist staging
+// directory.
+type soongDistSingleton struct{}
+
+// activeDistGoals returns the goals whose dists are copied in a Soong only build.
+func activeDistGoals(config Config) []string {
+	return strings.Fields(config.Getenv("SOONG_DIST_GOALS"))
+}
+
+// isDistGoalActive returns true if any of the active goals matches the goal of a dist, using the
+// semantics of Make's $(filter).
+func isDistGoalActive(goal string, activeGoals []string) bool {
+	for _, active := range activeGoals {
+		if matchPattern(goal, active) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *soongDistSingleton) GenerateBuildActions(ctx SingletonContext) {
+	if ctx.Config().KatiEnabled() {
+		// Make decides which dists are copied.
+		return
+	}
+	activeGoals := activeDistGoals(ctx.Config())
+
+	sources := make(map[string]soongDistCopy)
+	moduleCopies := newModuleDistCopies()
+	ctx.VisitAllModulesBlueprint(func(mod blueprint.Module) {
+		contributions := moduleDistContributions(ctx, mod)
+		if contributions == nil {
+			return
+		}
+		moduleCopies.add(ctx, mod, contributions)
+		for _, copies := range contributions.copiesForGoals {
+			active := false
+			for _, goal := range strings.Fields(copies.goals) {
+				if isDistGoalActive(goal, activeGoals) {
+					active = true
+					break
+				}
+			}
+			if !active {
+				continue
+			}
+			for _, cp := range copies.copies {
+				if other, ok := sources[cp.dest]; ok {
+					if other.from.String() != cp.from.String() {
+						ctx.ModuleErrorf(mod, "dist of %s to %q conflicts with the dist of %s", cp.from, cp.dest, other.from)
+					}
+					continue
+				}
+				sources[cp.dest] = soongDistCopy{cp.from, mod.(Module).base().StableId()}
+			}
+		}
+	})
+
+	activeCopies := make(map[string]Path)
+	for dest, cp := range sources {
+		activeCopies[dest] = cp.from
+	}
+	moduleCopies.build(ctx, activeCopies)
+
+	if len(activeGoals) == 0 {
+		return
+	}
+
+	distDir := PathForOutput(ctx, "dist")
+	var manifest []string
+	var outputs Paths
+	for _, dest := range SortedKeys(sources) {
+		outputs = append(outputs, distDir.Join(ctx, dest))
+		manifest = append(manifest, fmt.Sprintf("%s %s %s", dest, sources[dest].from, sources[dest].stableId))
+	}
+
+	manifestFile := distDir.Join(ctx, "dist_manifest.txt")
+	WriteFileRule(ctx, manifestFile, strings.Join(manifest, "\n"))
+	ctx.Phony("soong_dist", append(outputs, manifestFile)...)
+}
+
+// moduleDistContributions computes the dist contributions of the module variant the same way as
+// when it is translated to Android.mk, without writing the Android.mk entries.  It is also used for
+// the modules that are hidden from Make but still dist their output files because they set
+// dist_when_hidden.
+func moduleDistContributions(ctx SingletonContext, mod blueprint.Module) *distContributions {
+	module, ok := mod.(Module)
+	if !ok || isExcludedFromAndroidMk(module.base()) || distsHiddenFromMake(module.base()) {
+		return nil
+	}
+
+	switch x := mod.(type) {
+	case AndroidMkDataProvider:
+		data := x.AndroidMk()
+		data.fillInData(ctx, mod)
+		if data.Entries.disabled() {
+			return nil
+		}
+		return data.Entries.distContributions
+	case AndroidMkEntriesProvider:
+		var ret *distContributions
+		for _, entries := range x.AndroidMkEntries() {
+			entries.fillInEntries(ctx, mod)
+			if entries.disabled() || entries.distContributions == nil {
+				continue
+			}
+			if ret == nil {
+				ret = &distContributions{licenseMetadataFile: entries.distContributions.licenseMetadataFile}
+			}
+			ret.copiesForGoals = append(ret.copiesForGoals, entries.distContributions.copiesForGoals...)
+		}
+		return ret
+	}
+	return nil
+}
@@ -0,0 +1,264 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/blueprint/proptools"
+)
+
+var prepareForSoongDistTest = GroupFixturePreparers(
+	FixtureRegisterWithContext(func(ctx RegistrationContext) {
+		ctx.RegisterModuleType("custom", customModuleFactory)
+		RegisterSoongDistBuildComponents(ctx)
+	}),
+	FixtureModifyProductVariables(func(variables FixtureProductVariables) {
+		variables.DeviceProduct = proptools.StringPtr("bar")
+	}),
+)
+
+const soongDistTestBp = `
+	custom {
+		name: "foo",
+		dists: [
+			{
+				targets: ["droidcore", "sdk"],
+			},
+			{
+				targets: ["sdk_%"],
+				tag: ".another-tag",
+			},
+			{
+				targets: ["other_goal"],
+				dest: "foo_other.out",
+			},
+		],
+	}
+
+	custom {
+		name: "bar",
+		stable_id: "bar_id",
+		dists: [
+			{
+				targets: ["droidcore"],
+			},
+			{
+				targets: ["sdk", "my_goal"],
+				tag: ".multiple",
+				dir: "bar",
+			},
+		],
+	}
+`
+
+func TestSoongDist(t *testing.T) {
+	result := GroupFixturePreparers(
+		prepareForSoongDistTest,
+		FixtureMergeEnv(map[string]string{
+			"SOONG_DIST_GOALS": "sdk sdk_addon",
+		}),
+	).RunTestWithBp(t, soongDistTestBp)
+
+	singleton := result.SingletonForTests("soong_dist")
+
+	// foo and bar both dist one.out for droidcore, but only foo dists it for sdk.
+	AssertStringEquals(t, "one.out", "one.out", singleton.Output("dist/one.out").Input.String())
+	// sdk_addon matches the sdk_% goal.
+	AssertStringEquals(t, "another.out", "another.out", singleton.Output("dist/another.out").Input.String())
+	AssertStringEquals(t, "bar/two.out", "two.out", singleton.Output("dist/bar/two.out").Input.String())
+	AssertStringEquals(t, "bar/four.out", "three/four.out", singleton.Output("dist/bar/four.out").Input.String())
+	// foo_other.out is only copied for foo-dist.
+	AssertPathsRelativeToTopEquals(t, "soong_dist", []string{
+		"out/soong/dist/another.out",
+		"out/soong/dist/bar/four.out",
+		"out/soong/dist/bar/two.out",
+		"out/soong/dist/one.out",
+		"out/soong/dist/dist_manifest.txt",
+	}, getPhonyMap(result.Config)["soong_dist"])
+
+	manifest := ContentFromFileRuleForTests(t, result.TestContext, singleton.Output("dist/dist_manifest.txt"))
+	AssertStringEquals(t, "manifest", strings.Join([]string{
+		"another.out another.out foo",
+		"bar/four.out three/four.out bar_id",
+		"bar/two.out two.out bar_id",
+		"one.out one.out foo",
+	}, "\n"), manifest)
+}
+
+func TestSoongDistNoGoals(t *testing.T) {
+	result := prepareForSoongDistTest.RunTestWithBp(t, soongDistTestBp)
+
+	singleton := result.SingletonForTests("soong_dist")
+	AssertBoolEquals(t, "no manifest", true, singleton.MaybeOutput("dist/dist_manifest.txt").Rule == nil)
+	AssertBoolEquals(t, "no soong_dist phony", false, getPhonyMap(result.Config)["soong_dist"] != nil)
+}
+
+func TestSoongDistModulePhony(t *testing.T) {
+	result := GroupFixturePreparers(
+		prepareForSoongDistTest,
+		FixtureMergeEnv(map[string]string{"DIST_DIR": "/tmp/dist"}),
+	).RunTestWithBp(t, soongDistTestBp)
+
+	// The -dist phony targets copy the dists of the module for all goals to the dist staging
+	// directory, and from there to $DIST_DIR.
+	phonies := getPhonyMap(result.Config)
+	AssertPathsRelativeToTopEquals(t, "foo-dist", []string{"out/soong/module_dist/foo-dist.stamp"}, phonies["foo-dist"])
+	AssertPathsRelativeToTopEquals(t, "bar-dist", []string{"out/soong/module_dist/bar-dist.stamp"}, phonies["bar-dist"])
+
+	singleton := result.SingletonForTests("soong_dist")
+	cp := singleton.Output("dist/foo_other.out")
+	AssertStringEquals(t, "rule", Cp.String(), cp.Rule.String())
+	AssertStringEquals(t, "input", "one.out", cp.Input.String())
+
+	fooDist := singleton.Output("module_dist/foo-dist.stamp")
+	AssertPathsRelativeToTopEquals(t, "foo-dist staged", []string{
+		"out/soong/dist/one.out",
+		"out/soong/dist/another.out",
+		"out/soong/dist/foo_other.out",
+	}, fooDist.Implicits)
+	AssertStringEquals(t, "foo-dist distDir", "/tmp/dist", fooDist.Args["distDir"])
+	AssertStringEquals(t, "foo-dist dests", "one.out another.out foo_other.out", fooDist.Args["dests"])
+
+	barDist := singleton.Output("module_dist/bar-dist.stamp")
+	AssertPathsRelativeToTopEquals(t, "bar-dist staged", []string{
+		"out/soong/dist/one.out",
+		"out/soong/dist/bar/two.out",
+		"out/soong/dist/bar/four.out",
+	}, barDist.Implicits)
+}
+
+func TestSoongDistModulePhonyConflict(t *testing.T) {
+	result := prepareForSoongDistTest.RunTestWithBp(t, `
+		custom {
+			name: "foo",
+			dists: [
+				{
+					targets: ["droidcore"],
+					dest: "foo.out",
+				},
+			],
+		}
+
+		custom {
+			name: "bar",
+			dists: [
+				{
+					targets: ["sdk"],
+					tag: ".another-tag",
+					dest: "foo.out",
+				},
+			],
+		}
+	`)
+
+	// The goals of the dists are never built together, but the dist staging directory can only
+	// hold one of the files.
+	phonies := getPhonyMap(result.Config)
+	AssertPathsRelativeToTopEquals(t, "bar-dist", []string{"out/soong/module_dist/bar-dist.stamp"}, phonies["bar-dist"])
+	AssertPathsRelativeToTopEquals(t, "foo-dist", nil, phonies["foo-dist"])
+	singleton := result.SingletonForTests("soong_dist")
+	AssertPathsRelativeToTopEquals(t, "bar-dist staged", []string{"out/soong/dist/foo.out"},
+		singleton.Output("module_dist/bar-dist.stamp").Implicits)
+	AssertBoolEquals(t, "no foo-dist copy", true, singleton.MaybeOutput("module_dist/foo-dist.stamp").Rule == nil)
+	AssertDeepEquals(t, "warnings", []BuildWarning{{
+		File:    "Android.bp",
+		Module:  "foo",
+		Message: `dist of one.out to "foo.out" is left out of foo-dist as another.out is copied there`,
+	}}, result.Config.BuildWarnings())
+}
+
+func TestSoongDistKatiEnabled(t *testing.T) {
+	result := GroupFixturePreparers(
+		prepareForSoongDistTest,
+		FixtureModifyConfig(SetKatiEnabledForTests),
+		FixtureMergeEnv(map[string]string{
+			"SOONG_DIST_GOALS": "droidcore",
+		}),
+	).RunTestWithBp(t, soongDistTestBp)
+
+	singleton := result.SingletonForTests("soong_dist")
+	AssertBoolEquals(t, "no manifest", true, singleton.MaybeOutput("dist/dist_manifest.txt").Rule == nil)
+	AssertBoolEquals(t, "no soong_dist phony", false, getPhonyMap(result.Config)["soong_dist"] != nil)
+}
+
+func TestSoongDistConflict(t *testing.T) {
+	GroupFixturePreparers(
+		prepareForSoongDistTest,
+		FixtureMergeEnv(map[string]string{
+			"SOONG_DIST_GOALS": "droidcore",
+		}),
+	).
+		ExtendWithErrorHandler(FixtureExpectsOneErrorPattern(`dist of .* to "foo.out" conflicts with the dist of`)).
+		RunTestWithBp(t, `
+			custom {
+				name: "foo",
+				dists: [
+					{
+						targets: ["droidcore"],
+						dest: "foo.out",
+					},
+				],
+			}
+
+			custom {
+				name: "bar",
+				dists: [
+					{
+						targets: ["droid%"],
+						tag: ".another-tag",
+						dest: "foo.out",
+					},
+				],
+			}
+		`)
+}
+
+func TestSoongDistInProductDir(t *testing.T) {
+	result := GroupFixturePreparers(
+		prepareForSoongDistTest,
+		FixtureModifyProductVariables(func(variables FixtureProductVariables) {
+			variables.Dist_in_product_dir = proptools.BoolPtr(true)
+		}),
+		FixtureMergeEnv(map[string]string{
+			"SOONG_DIST_GOALS": "other_goal my_goal",
+		}),
+	).RunTestWithBp(t, soongDistTestBp)
+
+	singleton := result.SingletonForTests("soong_dist")
+	AssertStringEquals(t, "bar/foo_other.out", "one.out", singleton.Output("dist/bar/foo_other.out").Input.String())
+
+	manifest := ContentFromFileRuleForTests(t, result.TestContext, singleton.Output("dist/dist_manifest.txt"))
+	AssertStringEquals(t, "manifest", strings.Join([]string{
+		"bar/bar/four.out three/four.out bar_id",
+		"bar/bar/two.out two.out bar_id",
+		"bar/foo_other.out one.out foo",
+	}, "\n"), manifest)
+}
+
+func TestSoongDistHiddenFromMake(t *testing.T) {
+	result := GroupFixturePreparers(
+		prepareForSoongDistTest,
+		prepareForTestWithPreferredCustomPrebuilts,
+		FixtureMergeEnv(map[string]string{
+			"SOONG_DIST_GOALS": "droidcore",
+		}),
+	).RunTestWithBp(t, preferredCustomPrebuiltsBp)
+
+	singleton := result.SingletonForTests("soong_dist")
+	AssertBoolEquals(t, "foo.out is not copied", true, singleton.MaybeOutput("dist/foo.out").Rule == nil)
+	AssertStringEquals(t, "bar.out", "one.out", singleton.Output("dist/bar.out").Input.String())
+}
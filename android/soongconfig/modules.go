@@ -708,6 +708,15 @@ func (s *valueVariable) printfIntoPropertyRecursive(fieldName []string, propStru
 			}
 		case reflect.Slice:
 			for j := 0; j < field.Len(); j++ {
+				if elem := field.Index(j); elem.Kind() == reflect.Struct {
+					// Lists of structs, e.g. dists, are substituted into element by element.
+					fieldName = append(fieldName, fmt.Sprintf("%s[%d]", propStruct.Type().Field(i).Name, j))
+					if err := s.printfIntoPropertyRecursive(fieldName, elem, configValue); err != nil {
+						return err
+					}
+					fieldName = fieldName[:len(fieldName)-1]
+					continue
+				}
 				err := printfIntoProperty(field.Index(j), configValue)
 				if err != nil {
 					fieldName = append(fieldName, propStruct.Type().Field(i).Name)
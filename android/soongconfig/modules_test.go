@@ -499,6 +499,63 @@ func Test_PropertiesToApply_Value_Nested(t *testing.T) {
 	}
 }
 
+func Test_PropertiesToApply_Value_ListOfStructs(t *testing.T) {
+	mt, _ := newModuleType(&ModuleTypeProperties{
+		Module_type:      "foo",
+		Config_namespace: "bar",
+		Value_variables:  []string{"my_value_var"},
+		Properties:       []string{"dists"},
+	})
+	type dist struct {
+		Targets []string
+		Dest    *string
+		Append  *bool
+	}
+	type properties struct {
+		Dists []dist
+	}
+	type valueVarProps struct {
+		Dists              []dist
+		Conditions_default *properties
+	}
+	actualProps := &struct {
+		Soong_config_variables valueSoongConfigVars
+	}{
+		Soong_config_variables: valueSoongConfigVars{
+			My_value_var: &valueVarProps{
+				Dists: []dist{
+					{Targets: []string{"goal_%s"}, Dest: proptools.StringPtr("%s.out")},
+					{Targets: []string{"other_goal"}, Append: proptools.BoolPtr(true)},
+				},
+			},
+		},
+	}
+	props := reflect.ValueOf(actualProps)
+
+	gotProps, err := PropertiesToApply(mt, props, Config(map[string]string{"my_value_var": "Hello"}))
+	if err != nil {
+		t.Fatalf("Unexpected error in PropertiesToApply: %s", err)
+	}
+	wantProps := []interface{}{&properties{
+		Dists: []dist{
+			{Targets: []string{"goal_Hello"}, Dest: proptools.StringPtr("Hello.out")},
+			{Targets: []string{"other_goal"}, Append: proptools.BoolPtr(true)},
+		},
+	}}
+	if !reflect.DeepEqual(gotProps, wantProps) {
+		t.Errorf("Expected %s, got %s", wantProps, gotProps)
+	}
+
+	actualProps.Soong_config_variables.My_value_var.(*valueVarProps).Dists[0].Dest = proptools.StringPtr("%d")
+	_, err = PropertiesToApply(mt, props, Config(map[string]string{"my_value_var": "Hello"}))
+	expected := "soong_config_variables.my_value_var.Dists[0].Dest: unsupported % in value variable property"
+	if err == nil {
+		t.Fatalf("Expected an error, got nil")
+	} else if err.Error() != expected {
+		t.Fatalf("Error message was not correct, expected %q, got %q", expected, err.Error())
+	}
+}
+
 func Test_PropertiesToApply_String_Error(t *testing.T) {
 	mt, _ := newModuleType(&ModuleTypeProperties{
 		Module_type:      "foo",
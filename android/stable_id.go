@@ -0,0 +1,94 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"encoding/json"
+)
+
+// The stable_id of a module, or its name if it does not set one, identifies the module in build
+// metrics across renames.  The stable_ids singleton checks that the stable ids are unique across
+// the tree, except between modules with the same name in different namespaces that do not set
+// stable_id, and writes out/soong/stable_ids.json that maps the name of every module to its stable
+// id, so that metrics keyed on module names can be joined across renames.
+
+func init() {
+	RegisterStableIdBuildComponents(InitRegistrationContext)
+}
+
+func RegisterStableIdBuildComponents(ctx RegistrationContext) {
+	ctx.RegisterParallelSingletonType("stable_ids", stableIdSingletonFactory)
+}
+
+func stableIdSingletonFactory() Singleton {
+	return &stableIdSingleton{}
+}
+
+// stableIdSingleton checks that the stable ids of the modules are unique.
+type stableIdSingleton struct{}
+
+// stableIdDefiner is the module that defines a stable id.
+type stableIdDefiner struct {
+	name   string
+	bpFile string
+	// Whether the stable id is set with stable_id rather than being the name of the module.
+	explicit bool
+}
+
+func (d stableIdDefiner) String() string {
+	return d.name + " in " + d.bpFile
+}
+
+type stableIdJSON struct {
+	Name     string `json:"name"`
+	Path     string `json:"path"`
+	StableId string `json:"stable_id"`
+}
+
+func (s *stableIdSingleton) GenerateBuildActions(ctx SingletonContext) {
+	definers := make(map[string]stableIdDefiner)
+	var ids []stableIdJSON
+	ctx.VisitAllModules(func(module Module) {
+		if ctx.PrimaryModule(module) != module {
+			return
+		}
+		switch module.(type) {
+		case *packageModule, *NamespaceModule:
+			return
+		}
+		m := module.base()
+		if id := m.commonProperties.Stable_id; id != nil && *id == "" {
+			ctx.ModuleErrorf(module, "stable_id must not be empty")
+			return
+		}
+
+		id := m.StableId()
+		definer := stableIdDefiner{ctx.ModuleName(module), ctx.BlueprintFile(module), m.commonProperties.Stable_id != nil}
+		if other, ok := definers[id]; !ok {
+			definers[id] = definer
+		} else if other.explicit || definer.explicit {
+			ctx.ModuleErrorf(module, "stable_id %q is defined by both %s and %s", id, other, definer)
+			return
+		}
+		ids = append(ids, stableIdJSON{definer.name, definer.bpFile, id})
+	})
+
+	data, err := json.MarshalIndent(ids, "", "  ")
+	if err != nil {
+		ctx.Errorf("failed to marshal stable ids: %s", err)
+		return
+	}
+	WriteFileRule(ctx, PathForOutput(ctx, "stable_ids.json"), string(data))
+}
@@ -0,0 +1,101 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+var prepareForStableIdTest = GroupFixturePreparers(
+	PrepareForTestWithTeamBuildComponents,
+	FixtureRegisterWithContext(func(ctx RegistrationContext) {
+		ctx.RegisterModuleType("fake", fakeModuleFactory)
+		ctx.RegisterParallelSingletonType("all_teams", AllTeamsFactory)
+		RegisterStableIdBuildComponents(ctx)
+	}),
+)
+
+func TestStableId(t *testing.T) {
+	t.Parallel()
+	result := prepareForStableIdTest.RunTestWithBp(t, `
+		fake {
+			name: "renamed",
+			stable_id: "b3f5c1a2",
+		}
+
+		fake {
+			name: "absent",
+		}
+	`)
+
+	var ids []stableIdJSON
+	content := ContentFromFileRuleForTests(t, result.TestContext,
+		result.SingletonForTests("stable_ids").Output("stable_ids.json"))
+	if err := json.Unmarshal([]byte(content), &ids); err != nil {
+		t.Fatal(err)
+	}
+	actual := make(map[string]string)
+	for _, id := range ids {
+		actual[id.Name] = id.StableId
+	}
+	AssertDeepEquals(t, "stable ids", map[string]string{
+		"renamed": "b3f5c1a2",
+		"absent":  "absent",
+	}, actual)
+
+	// Only the explicit stable_id is recorded in the ownership manifest.
+	teams := make(map[string]*string)
+	for _, team := range getTeamProtoOutput(t, result).Teams {
+		teams[team.GetTargetName()] = team.StableId
+	}
+	AssertStringEquals(t, "renamed", "b3f5c1a2", String(teams["renamed"]))
+	AssertBoolEquals(t, "absent has no stable_id", true, teams["absent"] == nil)
+}
+
+func TestStableIdDuplicate(t *testing.T) {
+	t.Parallel()
+	prepareForStableIdTest.
+		ExtendWithErrorHandler(FixtureExpectsOneErrorPattern(
+			`stable_id "b3f5c1a2" is defined by both (one|two) in Android.bp and (one|two) in Android.bp`)).
+		RunTestWithBp(t, `
+			fake {
+				name: "one",
+				stable_id: "b3f5c1a2",
+			}
+
+			fake {
+				name: "two",
+				stable_id: "b3f5c1a2",
+			}
+		`)
+}
+
+func TestStableIdDuplicateOfName(t *testing.T) {
+	t.Parallel()
+	prepareForStableIdTest.
+		ExtendWithErrorHandler(FixtureExpectsOneErrorPattern(
+			`stable_id "old_name" is defined by both (old_name|new_name) in Android.bp and (old_name|new_name) in Android.bp`)).
+		RunTestWithBp(t, `
+			fake {
+				name: "old_name",
+			}
+
+			fake {
+				name: "new_name",
+				stable_id: "old_name",
+			}
+		`)
+}
@@ -0,0 +1,103 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// When the CheckSymlinkTargets product variable is set, each module that installs symlinks gets a
+// validation action that is built by checkbuild and fails if any of the symlinks is dangling, i.e.
+// doesn't point to a file installed by the module or its transitive install dependencies.
+
+// Absolute symlink targets under these directories only exist at runtime, so they are never
+// checked.  More can be added with the SymlinkTargetCheckExemptPrefixes product variable.
+var defaultSymlinkTargetCheckExemptPrefixes = []string{
+	"/apex/",
+	"/data/",
+	"/dev/",
+	"/proc/",
+	"/sys/",
+}
+
+// installedSymlink is a symlink installed by a module.
+type installedSymlink struct {
+	path InstallPath
+
+	// The installed file that a relative symlink points to.
+	target InstallPath
+
+	// The target of an absolute symlink, empty for relative symlinks.
+	absTarget string
+}
+
+func symlinkTargetCheckExempt(ctx ModuleContext, absTarget string) bool {
+	return HasAnyPrefix(absTarget, defaultSymlinkTargetCheckExemptPrefixes) ||
+		HasAnyPrefix(absTarget, ctx.DeviceConfig().SymlinkTargetCheckExemptPrefixes())
+}
+
+// checkSymlinkTargets creates the validation action for the symlinks installed by the module,
+// whose result is added to the checkbuild files of the module.  Dangling symlinks are reported when
+// the action is built rather than during analysis so that they don't break builds that don't
+// build the module.
+func (m *ModuleBase) checkSymlinkTargets(ctx *moduleContext, dependencyInstallFiles []*DepSet[InstallPath]) {
+	if !ctx.DeviceConfig().CheckSymlinkTargets() || len(ctx.installedSymlinks) == 0 {
+		return
+	}
+
+	installed := make(map[string]bool)
+	for _, path := range NewDepSet(TOPOLOGICAL, m.installFiles, dependencyInstallFiles).ToList() {
+		installed[path.String()] = true
+	}
+	// Absolute symlinks on the device are resolved relative to the product out directory.
+	productDir := pathForPartitionInstallDir(ctx, "", filepath.Join("target", "product", ctx.Config().DeviceName()),
+		ctx.Config().KatiEnabled()).String()
+
+	var dangling []string
+	for _, symlink := range ctx.installedSymlinks {
+		target := symlink.target.String()
+		if symlink.absTarget != "" {
+			if !ctx.Device() || symlinkTargetCheckExempt(ctx, symlink.absTarget) {
+				continue
+			}
+			target = filepath.Join(productDir, symlink.absTarget)
+		}
+		if !installed[target] {
+			dangling = append(dangling, fmt.Sprintf("%s -> %s", symlink.path, target))
+		}
+	}
+
+	stamp := PathForModuleOut(ctx, "symlink_check.stamp")
+	if len(dangling) > 0 {
+		ctx.Build(pctx, BuildParams{
+			Rule:        ErrorRule,
+			Output:      stamp,
+			Description: "check symlinks of " + ctx.ModuleName(),
+			Args: map[string]string{
+				"error": fmt.Sprintf("module %q installs dangling symlinks, their targets are not installed "+
+					"by the module or its dependencies: %s", ctx.ModuleName(), strings.Join(dangling, ", ")),
+			},
+		})
+	} else {
+		ctx.Build(pctx, BuildParams{
+			Rule:        Touch,
+			Output:      stamp,
+			Description: "check symlinks of " + ctx.ModuleName(),
+		})
+	}
+	m.checkbuildFiles = append(m.checkbuildFiles, stamp)
+}
@@ -0,0 +1,145 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/blueprint/proptools"
+)
+
+type symlinkCheckTestModule struct {
+	ModuleBase
+	properties struct {
+		Deps []string
+
+		// Symlinks to install in bin, as NAME=TARGET where TARGET is relative to bin.
+		Symlinks []string
+
+		// Absolute symlinks to install in bin, as NAME=TARGET.
+		Absolute_symlinks []string
+	}
+}
+
+func symlinkCheckTestModuleFactory() Module {
+	m := &symlinkCheckTestModule{}
+	m.AddProperties(&m.properties)
+	InitAndroidArchModule(m, DeviceSupported, MultilibCommon)
+	return m
+}
+
+func (m *symlinkCheckTestModule) DepsMutator(ctx BottomUpMutatorContext) {
+	ctx.AddDependency(ctx.Module(), installDepTag{}, m.properties.Deps...)
+}
+
+func (m *symlinkCheckTestModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+	out := PathForModuleOut(ctx, ctx.ModuleName())
+	ctx.Build(pctx, BuildParams{
+		Rule:   Touch,
+		Output: out,
+	})
+	binDir := PathForModuleInstall(ctx, "bin")
+	ctx.InstallFile(binDir, ctx.ModuleName(), out)
+	for _, symlink := range m.properties.Symlinks {
+		name, target, _ := strings.Cut(symlink, "=")
+		ctx.InstallSymlink(binDir, name, binDir.Join(ctx, target))
+	}
+	for _, symlink := range m.properties.Absolute_symlinks {
+		name, target, _ := strings.Cut(symlink, "=")
+		ctx.InstallAbsoluteSymlink(binDir, name, target)
+	}
+}
+
+var prepareForSymlinkCheckTest = GroupFixturePreparers(
+	PrepareForTestWithArchMutator,
+	FixtureRegisterWithContext(func(ctx RegistrationContext) {
+		ctx.RegisterModuleType("symlink_check_test_module", symlinkCheckTestModuleFactory)
+	}),
+	FixtureModifyProductVariables(func(variables FixtureProductVariables) {
+		variables.CheckSymlinkTargets = proptools.BoolPtr(true)
+		variables.SymlinkTargetCheckExemptPrefixes = []string{"/vendor_dlkm/"}
+	}),
+)
+
+func TestCheckSymlinkTargets(t *testing.T) {
+	bp := `
+		symlink_check_test_module {
+			name: "foo",
+			symlinks: ["foo_link=foo"],
+		}
+
+		symlink_check_test_module {
+			name: "valid",
+			deps: ["foo"],
+			symlinks: ["valid_link=foo", "valid_self_link=valid"],
+			absolute_symlinks: ["valid_abs_link=/system/bin/foo"],
+		}
+
+		symlink_check_test_module {
+			name: "dangling",
+			symlinks: ["dangling_link=missing"],
+			absolute_symlinks: ["dangling_abs_link=/system/bin/foo"],
+		}
+
+		symlink_check_test_module {
+			name: "exempt",
+			absolute_symlinks: [
+				"apex_link=/apex/com.android.foo/bin/foo",
+				"vendor_dlkm_link=/vendor_dlkm/lib/modules/foo.ko",
+			],
+		}
+	`
+
+	result := prepareForSymlinkCheckTest.RunTestWithBp(t, bp)
+
+	check := func(name string) TestingBuildParams {
+		return result.ModuleForTests(name, "android_common").Output("symlink_check.stamp")
+	}
+
+	AssertSame(t, "foo symlink check rule", Touch, check("foo").Rule)
+	AssertSame(t, "valid symlink check rule", Touch, check("valid").Rule)
+	AssertSame(t, "exempt symlink check rule", Touch, check("exempt").Rule)
+
+	dangling := check("dangling")
+	AssertSame(t, "dangling symlink check rule", ErrorRule, dangling.Rule)
+	AssertStringEquals(t, "dangling symlink check error",
+		`module "dangling" installs dangling symlinks, their targets are not installed by the module or its dependencies: `+
+			"out/soong/target/product/test_device/system/bin/dangling_link -> out/soong/target/product/test_device/system/bin/missing, "+
+			"out/soong/target/product/test_device/system/bin/dangling_abs_link -> out/soong/target/product/test_device/system/bin/foo",
+		dangling.Args["error"])
+
+	// The checks are built by checkbuild.
+	checkbuildFiles := result.ModuleForTests("dangling", "android_common").Module().base().checkbuildFiles
+	AssertStringListContains(t, "checkbuild files", checkbuildFiles.RelativeToTop().Strings(),
+		"out/soong/.intermediates/dangling/android_common/symlink_check.stamp")
+}
+
+func TestCheckSymlinkTargetsDisabled(t *testing.T) {
+	result := GroupFixturePreparers(
+		prepareForSymlinkCheckTest,
+		FixtureModifyProductVariables(func(variables FixtureProductVariables) {
+			variables.CheckSymlinkTargets = nil
+		}),
+	).RunTestWithBp(t, `
+		symlink_check_test_module {
+			name: "dangling",
+			symlinks: ["dangling_link=missing"],
+		}
+	`)
+
+	check := result.ModuleForTests("dangling", "android_common").MaybeOutput("symlink_check.stamp")
+	AssertSame(t, "symlink check rule", nil, check.Rule)
+}
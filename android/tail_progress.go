@@ -0,0 +1,198 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	soong_metrics_proto "android/soong/ui/metrics/metrics_proto"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// The tail of soong_build, the singletons that run after the modules have generated their build
+// actions and the files written after the ninja file, can take more than a minute without any
+// output.  Its phases are reported as tail phases, which count the items they process.  Each tail
+// phase is recorded in the soong_build metrics as a separate event named by tailEventName along
+// with its count, and the running phases are printed periodically by the tail progress ticker
+// that soong_build starts when SOONG_TAIL_PROGRESS is set.
+//
+// The tail phase events are recorded separately from the events of the blueprint EventHandler,
+// which only supports nested events on a single goroutine, while the phases of the singletons can
+// run in parallel.  The phases that already had EventHandler events keep them under their old
+// names, alongside their tail phase events.
+
+var tailProgressOnceKey = NewOnceKey("tail_progress")
+
+// TailProgress collects the tail phases of soong_build.
+type TailProgress struct {
+	lock   sync.Mutex
+	active []*TailPhase
+	done   []*TailPhase
+}
+
+// TailPhase is a running or completed tail phase.
+type TailPhase struct {
+	name     string
+	unit     string
+	start    time.Time
+	duration time.Duration
+	count    atomic.Int64
+}
+
+// GetTailProgress returns the tail progress of soong_build.
+func GetTailProgress(config Config) *TailProgress {
+	return config.Once(tailProgressOnceKey, func() interface{} {
+		return &TailProgress{}
+	}).(*TailProgress)
+}
+
+// BeginTailPhase starts a tail phase that counts its items in the given unit, e.g. "modules", and
+// returns it so that the items can be counted with Add and the phase completed with End.
+func BeginTailPhase(config Config, name, unit string) *TailPhase {
+	p := GetTailProgress(config)
+	phase := &TailPhase{name: name, unit: unit, start: time.Now()}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.active = append(p.active, phase)
+	return phase
+}
+
+// Add counts n more items processed by the phase.  It may be called concurrently.
+func (phase *TailPhase) Add(n int) {
+	phase.count.Add(int64(n))
+}
+
+// End completes the phase.
+func (phase *TailPhase) End(config Config) {
+	p := GetTailProgress(config)
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	phase.duration = time.Since(phase.start)
+	for i, active := range p.active {
+		if active == phase {
+			p.active = append(p.active[:i:i], p.active[i+1:]...)
+			break
+		}
+	}
+	p.done = append(p.done, phase)
+}
+
+// tailEventName returns the name of the soong_build metrics event of the tail phase.
+func tailEventName(phase string) string {
+	return "soong_build.tail." + phase
+}
+
+// formatTailProgress returns the line printed by the tail progress ticker for the running phases,
+// e.g. "soong_build tail: androidmk 12000 modules (3s), globs_ninja_file 500 globs (1s)".
+func formatTailProgress(phases []*TailPhase, now time.Time) string {
+	if len(phases) == 0 {
+		return ""
+	}
+	parts := make([]string, 0, len(phases))
+	for _, phase := range phases {
+		parts = append(parts, fmt.Sprintf("%s %d %s (%s)", phase.name, phase.count.Load(), phase.unit,
+			now.Sub(phase.start).Round(time.Second)))
+	}
+	return "soong_build tail: " + strings.Join(parts, ", ")
+}
+
+// StartTailProgressTicker prints the running tail phases to w at every interval until the returned
+// function is called.
+func StartTailProgressTicker(config Config, w io.Writer, interval time.Duration) (stop func()) {
+	p := GetTailProgress(config)
+	ticker := time.NewTicker(interval)
+	done := make(chan bool)
+	go func() {
+		for {
+			select {
+			case <-done:
+				ticker.Stop()
+				return
+			case now := <-ticker.C:
+				p.lock.Lock()
+				line := formatTailProgress(p.active, now)
+				p.lock.Unlock()
+				if line != "" {
+					fmt.Fprintln(w, line)
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// tailPhaseMetrics returns the events and the counters of the completed tail phases.
+func tailPhaseMetrics(config Config) ([]*soong_metrics_proto.PerfInfo, *soong_metrics_proto.PerfCounters) {
+	p := GetTailProgress(config)
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if len(p.done) == 0 {
+		return nil, nil
+	}
+
+	var events []*soong_metrics_proto.PerfInfo
+	group := &soong_metrics_proto.PerfCounterGroup{Name: proto.String("tail")}
+	for _, phase := range p.done {
+		events = append(events, &soong_metrics_proto.PerfInfo{
+			Description: proto.String(tailEventName(phase.name)),
+			Name:        proto.String("soong_build"),
+			StartTime:   proto.Uint64(uint64(phase.start.UnixNano())),
+			RealTime:    proto.Uint64(uint64(phase.duration.Nanoseconds())),
+		})
+		group.Counters = append(group.Counters, &soong_metrics_proto.PerfCounter{
+			Name:  proto.String(phase.name + "_" + phase.unit),
+			Value: proto.Int64(phase.count.Load()),
+		})
+	}
+	counters := &soong_metrics_proto.PerfCounters{
+		Time:   proto.Uint64(uint64(time.Now().UnixNano())),
+		Groups: []*soong_metrics_proto.PerfCounterGroup{group},
+	}
+	return events, counters
+}
+
+// runTailJobs calls fn for each index from 0 to n-1 in a pool of config.TailJobs() workers, and
+// returns the error of the lowest index that failed.
+func runTailJobs(config Config, n int, fn func(i int) error) error {
+	errs := make([]error, n)
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(config.TailJobs(), n); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				errs[i] = fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
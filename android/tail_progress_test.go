@@ -0,0 +1,85 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestTailPhaseEventNames(t *testing.T) {
+	config := TestConfig(t.TempDir(), nil, "", nil)
+
+	phase := BeginTailPhase(config, "ninja_deps", "deps")
+	phase.Add(3)
+	phase.End(config)
+
+	phase = BeginTailPhase(config, "androidmk", "modules")
+	phase.Add(2)
+	phase.Add(5)
+	phase.End(config)
+
+	events, counters := tailPhaseMetrics(config)
+	var actualEvents []string
+	for _, event := range events {
+		actualEvents = append(actualEvents, event.GetDescription())
+	}
+	AssertArrayString(t, "events", []string{"soong_build.tail.ninja_deps", "soong_build.tail.androidmk"},
+		actualEvents)
+
+	AssertStringEquals(t, "counter group", "tail", counters.Groups[0].GetName())
+	actualCounters := make(map[string]int64)
+	for _, counter := range counters.Groups[0].Counters {
+		actualCounters[counter.GetName()] = counter.GetValue()
+	}
+	AssertDeepEquals(t, "counters", map[string]int64{"ninja_deps_deps": 3, "androidmk_modules": 7},
+		actualCounters)
+}
+
+func TestFormatTailProgress(t *testing.T) {
+	now := time.Now()
+	androidmk := &TailPhase{name: "androidmk", unit: "modules", start: now.Add(-3200 * time.Millisecond)}
+	androidmk.Add(12000)
+	globs := &TailPhase{name: "globs_ninja_file", unit: "globs", start: now.Add(-time.Second)}
+	globs.Add(500)
+
+	AssertStringEquals(t, "no running phases", "", formatTailProgress(nil, now))
+	AssertStringEquals(t, "running phases",
+		"soong_build tail: androidmk 12000 modules (3s), globs_ninja_file 500 globs (1s)",
+		formatTailProgress([]*TailPhase{androidmk, globs}, now))
+}
+
+func TestRunTailJobs(t *testing.T) {
+	for _, serial := range []bool{false, true} {
+		t.Run(fmt.Sprintf("serial=%t", serial), func(t *testing.T) {
+			config := TestConfig(t.TempDir(), nil, "", nil)
+			config.serialTail = serial
+
+			done := make([]bool, 10)
+			err := runTailJobs(config, len(done), func(i int) error {
+				done[i] = true
+				if i == 7 || i == 3 {
+					return fmt.Errorf("job %d failed", i)
+				}
+				return nil
+			})
+			AssertErrorMessageEquals(t, "error", "job 3 failed", err)
+			for i, d := range done {
+				AssertBoolEquals(t, fmt.Sprintf("job %d done", i), true, d)
+			}
+		})
+	}
+}
@@ -14,6 +14,18 @@
 
 package android
 
+import (
+	"fmt"
+	"strings"
+)
+
+// When the TeamDirectoryAllowlist product variable is set, team modules may only be defined in the
+// directories it lists or their subdirectories, so that teams defined in test fixtures can't be
+// used by production modules.  The team and teams properties of modules that reference other
+// teams are reported, and so are the team modules themselves.  When
+// TeamDirectoryAllowlistReportOnly is set the errors become warnings, but the references are still
+// listed in the disallowed_team field of all_teams.pb.
+
 func init() {
 	RegisterTeamBuildComponents(InitRegistrationContext)
 }
@@ -39,7 +51,65 @@ type teamModule struct {
 
 // Real work is done for the module that depends on us.
 // If needed, the team can serialize the config to json/proto file as well.
-func (t *teamModule) GenerateAndroidBuildActions(ctx ModuleContext) {}
+func (t *teamModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+	if !isTeamDirectoryAllowed(ctx.DeviceConfig().TeamDirectoryAllowlist(), ctx.ModuleDir()) {
+		reportTeamLocation(ctx, "", "team %q is defined in %q, which is not in TeamDirectoryAllowlist",
+			ctx.ModuleName(), ctx.ModuleDir())
+	}
+}
+
+// isTeamDirectoryAllowed returns true if team modules may be defined in the directory according to
+// the TeamDirectoryAllowlist.
+func isTeamDirectoryAllowed(allowlist []string, dir string) bool {
+	if len(allowlist) == 0 {
+		return true
+	}
+	for _, allowed := range allowlist {
+		allowed = strings.TrimSuffix(allowed, "/")
+		if dir == allowed || strings.HasPrefix(dir, allowed+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// checkTeamReferences reports the teams referenced by the team and teams properties of the module
+// that are defined outside of the TeamDirectoryAllowlist.
+func checkTeamReferences(ctx ModuleContext) {
+	allowlist := ctx.DeviceConfig().TeamDirectoryAllowlist()
+	if len(allowlist) == 0 {
+		return
+	}
+	ctx.VisitDirectDepsWithTag(teamDepTag, func(team Module) {
+		dir := ctx.OtherModuleDir(team)
+		if isTeamDirectoryAllowed(allowlist, dir) {
+			return
+		}
+		property := "teams"
+		if String(ctx.Module().base().commonProperties.Team) == team.Name() {
+			property = "team"
+		}
+		reportTeamLocation(ctx, property, "team %q is defined in %q, which is not in TeamDirectoryAllowlist",
+			team.Name(), dir)
+	})
+}
+
+// reportTeamLocation reports a team defined outside of the TeamDirectoryAllowlist as an error on the
+// property, or on the module if property is empty, or as a warning in report-only mode.
+func reportTeamLocation(ctx ModuleContext, property string, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	if ctx.DeviceConfig().TeamDirectoryAllowlistReportOnly() {
+		if property != "" {
+			ctx.PropertyWarningf(property, "%s", msg)
+		} else {
+			ctx.ModuleWarningf("%s", msg)
+		}
+	} else if property != "" {
+		ctx.PropertyErrorf(property, "%s", msg)
+	} else {
+		ctx.ModuleErrorf("%s", msg)
+	}
+}
 
 func (t *teamModule) TrendyTeamId(ctx ModuleContext) string {
 	return *t.properties.Trendy_team_id
@@ -59,9 +59,100 @@ func TestTeam(t *testing.T) {
 
 	// Assert the rule from GenerateAndroidBuildActions exists.
 	m := ctx.ModuleForTests("main_test", "")
-	AssertStringEquals(t, "msg", m.Module().base().Team(), "someteam")
+	AssertArrayString(t, "msg", []string{"someteam"}, m.Module().base().Teams())
 	m = ctx.ModuleForTests("tool", "")
-	AssertStringEquals(t, "msg", m.Module().base().Team(), "team2")
+	AssertArrayString(t, "msg", []string{"team2"}, m.Module().base().Teams())
+}
+
+func TestMultipleTeams(t *testing.T) {
+	t.Parallel()
+	ctx := GroupFixturePreparers(
+		PrepareForTestWithTeamBuildComponents,
+		FixtureRegisterWithContext(func(ctx RegistrationContext) {
+			ctx.RegisterModuleType("fake", fakeModuleFactory)
+		}),
+	).RunTestWithBp(t, `
+		team {
+			name: "team1",
+			trendy_team_id: "11111",
+		}
+
+		team {
+			name: "team2",
+			trendy_team_id: "22222",
+		}
+
+		fake {
+			name: "teams",
+			teams: ["team1", "team2"],
+		}
+
+		fake {
+			name: "team_and_teams",
+			team: "team2",
+			teams: ["team1", "team2"],
+		}
+	`)
+
+	m := ctx.ModuleForTests("teams", "")
+	AssertArrayString(t, "teams", []string{"team1", "team2"}, m.Module().base().Teams())
+	m = ctx.ModuleForTests("team_and_teams", "")
+	AssertArrayString(t, "team and teams", []string{"team2", "team1"}, m.Module().base().Teams())
+}
+
+func TestPerVariantTeams(t *testing.T) {
+	t.Parallel()
+	ctx := GroupFixturePreparers(
+		prepareForModuleTests,
+		PrepareForTestWithArchMutator,
+		PrepareForTestWithTeamBuildComponents,
+	).RunTestWithBp(t, `
+		team {
+			name: "team1",
+			trendy_team_id: "11111",
+		}
+
+		team {
+			name: "team2",
+			trendy_team_id: "22222",
+		}
+
+		team {
+			name: "host_team",
+			trendy_team_id: "33333",
+		}
+
+		deps {
+			name: "foo",
+			teams: ["team1", "team2"],
+			target: {
+				host: {
+					teams: ["host_team"],
+				},
+			},
+		}
+
+		deps {
+			name: "bar",
+			team: "team1",
+			target: {
+				host: {
+					team: "host_team",
+				},
+			},
+		}
+	`)
+
+	hostVariant := ctx.Config.BuildOSCommonTarget.String()
+	foo := ctx.ModuleForTests("foo", "android_common").Module().base()
+	AssertArrayString(t, "foo device teams", []string{"team1", "team2"}, foo.Teams())
+	foo = ctx.ModuleForTests("foo", hostVariant).Module().base()
+	AssertArrayString(t, "foo host teams", []string{"host_team"}, foo.Teams())
+
+	bar := ctx.ModuleForTests("bar", "android_common").Module().base()
+	AssertArrayString(t, "bar device teams", []string{"team1"}, bar.Teams())
+	bar = ctx.ModuleForTests("bar", hostVariant).Module().base()
+	AssertArrayString(t, "bar host teams", []string{"host_team"}, bar.Teams())
 }
 
 func TestMissingTeamFails(t *testing.T) {
@@ -73,7 +73,7 @@ func TestConfig(buildDir string, env map[string]string, bp string, fs map[string
 
 	determineBuildOS(config)
 
-	return Config{config}
+	return Config{config: config}
 }
 
 func modifyTestConfigToSupportArchMutator(testConfig Config) {
@@ -0,0 +1,137 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+
+	"github.com/google/blueprint"
+)
+
+// out/soong/test_suites.json maps each compatibility test suite to the modules in it and their
+// outputs, for the suite owners that don't want to read the LOCAL_COMPATIBILITY_SUITE variables of
+// Make.  The suites of a module are the ones it passes to AndroidMkEntries.AddCompatibilityTestSuites,
+// which are only known once its Android.mk entries are filled in, including the full mts and mcts
+// suites implied by the partial mts-* and mcts-* ones.  When Kati runs, the androidmk singleton
+// collects them while the modules are translated to Android.mk, so the entries are only filled in
+// once.  Soong only builds don't translate the modules, so the test_suites_json singleton fills in
+// the entries itself.
+
+func init() {
+	registerTestSuitesJSONBuildComponents(InitRegistrationContext)
+}
+
+func registerTestSuitesJSONBuildComponents(ctx RegistrationContext) {
+	ctx.RegisterParallelSingletonType("test_suites_json", testSuitesJSONSingletonFactory)
+}
+
+func testSuitesJSONSingletonFactory() Singleton {
+	return &testSuitesJSONSingleton{}
+}
+
+type testSuitesJSONSingleton struct{}
+
+func (s *testSuitesJSONSingleton) GenerateBuildActions(ctx SingletonContext) {
+	if ctx.Config().KatiEnabled() {
+		// The androidmk singleton writes test_suites.json.
+		return
+	}
+
+	testSuites := make(testSuitesJSON)
+	ctx.VisitAllModulesBlueprint(func(mod blueprint.Module) {
+		module, ok := mod.(Module)
+		if !ok || shouldSkipAndroidMkProcessing(module.base()) {
+			return
+		}
+		provider, ok := mod.(AndroidMkEntriesProvider)
+		if !ok {
+			// The deprecated AndroidMkData can't add compatibility test suites.
+			return
+		}
+		for _, entries := range provider.AndroidMkEntries() {
+			entries.fillInEntries(ctx, mod)
+			if !entries.disabled() {
+				testSuites.add(ctx, mod, &entries)
+			}
+		}
+	})
+
+	if err := testSuites.write(ctx); err != nil {
+		ctx.Errorf("failed to write test_suites.json: %s", err)
+	}
+}
+
+// testSuiteModuleJSON is a module variant in a test suite in test_suites.json.
+type testSuiteModuleJSON struct {
+	// The name of the module variant in Make, e.g. "foo_32".
+	RegisterName string `json:"register_name"`
+
+	// The primary output file of the module variant.
+	Output string `json:"output,omitempty"`
+
+	// The files installed by the module variant.
+	Installed []string `json:"installed,omitempty"`
+}
+
+// testSuitesJSON collects the module variants in each compatibility test suite.
+type testSuitesJSON map[string][]testSuiteModuleJSON
+
+// add adds the module variant of the filled in Android.mk entries to their compatibility test
+// suites.
+func (s testSuitesJSON) add(ctx SingletonContext, mod blueprint.Module, entries *AndroidMkEntries) {
+	// null-suite is the placeholder of the tests that are not in any suite.
+	suites := RemoveListFromList(FirstUniqueStrings(entries.EntryMap["LOCAL_COMPATIBILITY_SUITE"]),
+		[]string{"null-suite"})
+	if len(suites) == 0 {
+		return
+	}
+	module := testSuiteModuleJSON{
+		RegisterName: testSuiteModuleRegisterName(ctx, mod, entries),
+		Installed:    mod.(Module).FilesToInstall().Strings(),
+	}
+	if entries.OutputFile.Valid() {
+		module.Output = entries.OutputFile.String()
+	}
+	for _, suite := range suites {
+		s[suite] = append(s[suite], module)
+	}
+}
+
+// testSuiteModuleRegisterName returns the name of the module variant of the entries in Make.
+func testSuiteModuleRegisterName(ctx SingletonContext, mod blueprint.Module, entries *AndroidMkEntries) string {
+	if moduleInfoJSON, ok := SingletonModuleProvider(ctx, mod, ModuleInfoJSONProvider); ok &&
+		moduleInfoJSON.SubName == entries.SubName && moduleInfoJSON.core.RegisterName != "" {
+		return moduleInfoJSON.core.RegisterName
+	}
+	return ctx.ModuleName(mod) + entries.SubName
+}
+
+// write writes test_suites.json.
+func (s testSuitesJSON) write(ctx SingletonContext) error {
+	for _, modules := range s {
+		sort.SliceStable(modules, func(i, j int) bool {
+			return modules[i].RegisterName < modules[j].RegisterName
+		})
+	}
+
+	// The suites are sorted by the encoder.
+	return WriteFileRuleStreaming(ctx, PathForOutput(ctx, "test_suites.json"), func(w io.Writer) error {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(s)
+	})
+}
@@ -0,0 +1,175 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"testing"
+)
+
+type testSuitesJSONTestModule struct {
+	ModuleBase
+	properties struct {
+		Test_suites []string
+	}
+
+	output Path
+}
+
+func testSuitesJSONTestModuleFactory() Module {
+	m := &testSuitesJSONTestModule{}
+	m.AddProperties(&m.properties)
+	InitAndroidArchModule(m, DeviceSupported, MultilibFirst)
+	return m
+}
+
+func (m *testSuitesJSONTestModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+	m.output = PathForModuleOut(ctx, ctx.ModuleName())
+	ctx.InstallFile(PathForModuleInstall(ctx, "nativetest"), ctx.ModuleName(), m.output)
+	ctx.ModuleInfoJSON().Class = []string{"NATIVE_TESTS"}
+}
+
+func (m *testSuitesJSONTestModule) AndroidMkEntries() []AndroidMkEntries {
+	return []AndroidMkEntries{{
+		Class:      "NATIVE_TESTS",
+		OutputFile: OptionalPathForPath(m.output),
+		ExtraEntries: []AndroidMkExtraEntriesFunc{
+			func(ctx AndroidMkExtraEntriesContext, entries *AndroidMkEntries) {
+				if len(m.properties.Test_suites) > 0 {
+					entries.AddCompatibilityTestSuites(m.properties.Test_suites...)
+				} else {
+					entries.AddCompatibilityTestSuites("null-suite")
+				}
+			},
+		},
+	}}
+}
+
+var prepareForTestSuitesJSONTest = GroupFixturePreparers(
+	PrepareForTestWithArchMutator,
+	PrepareForTestWithAndroidMk,
+	FixtureRegisterWithContext(func(ctx RegistrationContext) {
+		ctx.RegisterModuleType("suite_test", testSuitesJSONTestModuleFactory)
+		registerTestSuitesJSONBuildComponents(ctx)
+	}),
+)
+
+const testSuitesJSONTestBp = `
+		// A partial mts suite implies the full mts suite.
+		suite_test {
+			name: "foo",
+			test_suites: ["mts-art", "general-tests"],
+		}
+
+		// The full mcts suite is not listed twice.
+		suite_test {
+			name: "bar",
+			test_suites: ["mcts-wifi", "mcts"],
+		}
+
+		suite_test {
+			name: "baz",
+			test_suites: ["general-tests"],
+		}
+
+		// Tests that are not in any suite are left out.
+		suite_test {
+			name: "no_suite",
+		}
+
+		suite_test {
+			name: "disabled",
+			test_suites: ["general-tests"],
+			enabled: false,
+		}
+`
+
+const testSuitesJSONTestExpected = `{
+  "general-tests": [
+    {
+      "register_name": "baz",
+      "output": "out/soong/.intermediates/baz/android_arm64_armv8-a/baz",
+      "installed": [
+        "out/soong/target/product/test_device/system/nativetest/baz"
+      ]
+    },
+    {
+      "register_name": "foo",
+      "output": "out/soong/.intermediates/foo/android_arm64_armv8-a/foo",
+      "installed": [
+        "out/soong/target/product/test_device/system/nativetest/foo"
+      ]
+    }
+  ],
+  "mcts": [
+    {
+      "register_name": "bar",
+      "output": "out/soong/.intermediates/bar/android_arm64_armv8-a/bar",
+      "installed": [
+        "out/soong/target/product/test_device/system/nativetest/bar"
+      ]
+    }
+  ],
+  "mcts-wifi": [
+    {
+      "register_name": "bar",
+      "output": "out/soong/.intermediates/bar/android_arm64_armv8-a/bar",
+      "installed": [
+        "out/soong/target/product/test_device/system/nativetest/bar"
+      ]
+    }
+  ],
+  "mts": [
+    {
+      "register_name": "foo",
+      "output": "out/soong/.intermediates/foo/android_arm64_armv8-a/foo",
+      "installed": [
+        "out/soong/target/product/test_device/system/nativetest/foo"
+      ]
+    }
+  ],
+  "mts-art": [
+    {
+      "register_name": "foo",
+      "output": "out/soong/.intermediates/foo/android_arm64_armv8-a/foo",
+      "installed": [
+        "out/soong/target/product/test_device/system/nativetest/foo"
+      ]
+    }
+  ]
+}
+`
+
+func TestTestSuitesJSON(t *testing.T) {
+	result := GroupFixturePreparers(
+		prepareForTestSuitesJSONTest,
+		FixtureModifyConfig(SetKatiEnabledForTests),
+	).RunTestWithBp(t, testSuitesJSONTestBp)
+
+	// When Kati runs, the suites are collected while translating the modules to Android.mk.
+	content := ContentFromFileRuleForTests(t, result.TestContext,
+		result.SingletonForTests("androidmk").Output("test_suites.json"))
+	AssertStringEquals(t, "test_suites.json", testSuitesJSONTestExpected, content)
+	AssertBoolEquals(t, "test_suites_json singleton", true,
+		result.SingletonForTests("test_suites_json").MaybeOutput("test_suites.json").Rule == nil)
+}
+
+func TestTestSuitesJSONKatiDisabled(t *testing.T) {
+	result := prepareForTestSuitesJSONTest.RunTestWithBp(t, testSuitesJSONTestBp)
+
+	// Soong only builds don't translate the modules to Android.mk, but still write the same file.
+	content := ContentFromFileRuleForTests(t, result.TestContext,
+		result.SingletonForTests("test_suites_json").Output("test_suites.json"))
+	AssertStringEquals(t, "test_suites.json", testSuitesJSONTestExpected, content)
+}
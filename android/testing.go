@@ -126,6 +126,12 @@ var PrepareForTestWithMakevars = FixtureRegisterWithContext(func(ctx Registratio
 	ctx.RegisterSingletonType("makevars", makeVarsSingletonFunc)
 })
 
+// PrepareForTestWithNinjaDeps registers the singleton that adds the files passed to
+// Config.AddNinjaFileDeps to the TestResult.NinjaDeps.
+var PrepareForTestWithNinjaDeps = FixtureRegisterWithContext(func(ctx RegistrationContext) {
+	ctx.RegisterSingletonType("ninjadeps", ninjaDepsSingletonFactory)
+})
+
 // Test fixture preparer that will register most java build components.
 //
 // Singletons and mutators should only be added here if they are needed for a majority of java
@@ -604,6 +610,7 @@ type InstallMakeRule struct {
 	Target        string
 	Deps          []string
 	OrderOnlyDeps []string
+	Recipe        string
 }
 
 func parseMkRules(t *testing.T, config Config, nodes []mkparser.Node) []InstallMakeRule {
@@ -636,6 +643,7 @@ func parseMkRules(t *testing.T, config Config, nodes []mkparser.Node) []InstallM
 				*prereqList = append(*prereqList, normalizeStringRelativeToTop(config, prereq.Value(nil)))
 			}
 
+			rule.Recipe = mkParserRule.Recipe
 			rules = append(rules, rule)
 		}
 	}
@@ -1107,11 +1115,27 @@ func SetKatiEnabledForTests(config Config) {
 	config.katiEnabled = true
 }
 
+func SetVerifyAndroidMkEntriesForTests(config Config) {
+	config.verifyAndroidMkEntries = true
+}
+
 func SetTrimmedApexEnabledForTests(config Config) {
 	config.productVariables.TrimmedApex = new(bool)
 	*config.productVariables.TrimmedApex = true
 }
 
+// androidMkTestContext is the fillInEntriesContext used by AndroidMkEntriesForTest and
+// AndroidMkDataForTest, which reports errors to the test.
+type androidMkTestContext struct {
+	*TestContext
+	t *testing.T
+}
+
+func (ctx androidMkTestContext) Errorf(format string, args ...interface{}) {
+	ctx.t.Helper()
+	ctx.t.Errorf(format, args...)
+}
+
 func AndroidMkEntriesForTest(t *testing.T, ctx *TestContext, mod blueprint.Module) []AndroidMkEntries {
 	t.Helper()
 	var p AndroidMkEntriesProvider
@@ -1120,10 +1144,11 @@ func AndroidMkEntriesForTest(t *testing.T, ctx *TestContext, mod blueprint.Modul
 		t.Errorf("module does not implement AndroidMkEntriesProvider: " + mod.Name())
 	}
 
+	amCtx := androidMkTestContext{ctx, t}
 	entriesList := p.AndroidMkEntries()
-	aconfigUpdateAndroidMkEntries(ctx, mod.(Module), &entriesList)
+	aconfigUpdateAndroidMkEntries(amCtx, mod.(Module), &entriesList)
 	for i, _ := range entriesList {
-		entriesList[i].fillInEntries(ctx, mod)
+		entriesList[i].fillInEntries(amCtx, mod)
 	}
 	return entriesList
 }
@@ -1135,12 +1160,113 @@ func AndroidMkDataForTest(t *testing.T, ctx *TestContext, mod blueprint.Module)
 	if p, ok = mod.(AndroidMkDataProvider); !ok {
 		t.Fatalf("module does not implement AndroidMkDataProvider: " + mod.Name())
 	}
+	amCtx := androidMkTestContext{ctx, t}
 	data := p.AndroidMk()
-	data.fillInData(ctx, mod)
-	aconfigUpdateAndroidMkData(ctx, mod.(Module), &data)
+	data.fillInData(amCtx, mod)
+	aconfigUpdateAndroidMkData(amCtx, mod.(Module), &data)
 	return data
 }
 
+// TestingDistCopy is an instruction to copy a file into the dist directory, as returned by
+// DistContributionsForTest.
+type TestingDistCopy struct {
+	// The path of the file to copy, relative to the top of the tree.
+	From string
+
+	// The destination within the dist directory.
+	Dest string
+}
+
+// TestingDistCopiesForGoals is a set of dist copy instructions and the goals that run them.
+type TestingDistCopiesForGoals struct {
+	Goals  []string
+	Copies []TestingDistCopy
+}
+
+// TestingDistContributions is the contributions that a module makes to the dist, in a form that
+// can be compared by tests.
+type TestingDistContributions struct {
+	// The license metadata file of the copied files relative to the top of the tree, or empty if
+	// the module is exempt from license metadata.
+	LicenseMetadataFile string
+
+	CopiesForGoals []TestingDistCopiesForGoals
+}
+
+// DestsForGoal returns the destinations of the files that are copied into the dist directory when
+// the goal is built.
+func (d *TestingDistContributions) DestsForGoal(goal string) []string {
+	if d == nil {
+		return nil
+	}
+	var dests []string
+	for _, copies := range d.CopiesForGoals {
+		if InList(goal, copies.Goals) {
+			for _, c := range copies.Copies {
+				dests = append(dests, c.Dest)
+			}
+		}
+	}
+	return dests
+}
+
+// DistContributionsForTest returns the contributions that the variant of the module makes to
+// the dist, computed from its Android.mk entries in the same way as when they are written to
+// Make, or nil if it doesn't dist anything.  Modules that implement AndroidMkEntriesProvider are
+// inspected through their entries, others through their AndroidMkData.
+func DistContributionsForTest(t *testing.T, result *TestResult, name, variant string) *TestingDistContributions {
+	t.Helper()
+	module := result.ModuleForTests(name, variant).Module()
+
+	var contributions *distContributions
+	if _, ok := module.(AndroidMkEntriesProvider); ok {
+		entries := AndroidMkEntriesForTest(t, result.TestContext, module)
+		if len(entries) == 0 {
+			return nil
+		}
+		contributions = entries[0].distContributions
+	} else {
+		data := AndroidMkDataForTest(t, result.TestContext, module)
+		contributions = data.Entries.distContributions
+	}
+	if contributions == nil {
+		return nil
+	}
+
+	ret := &TestingDistContributions{}
+	if contributions.licenseMetadataFile != nil {
+		ret.LicenseMetadataFile = PathRelativeToTop(contributions.licenseMetadataFile)
+	}
+	for _, copiesForGoals := range contributions.copiesForGoals {
+		copies := TestingDistCopiesForGoals{Goals: strings.Fields(copiesForGoals.goals)}
+		for _, c := range copiesForGoals.copies {
+			copies.Copies = append(copies.Copies, TestingDistCopy{From: PathRelativeToTop(c.from), Dest: c.dest})
+		}
+		ret.CopiesForGoals = append(ret.CopiesForGoals, copies)
+	}
+	return ret
+}
+
+// AssertDistsTo checks that the variant of the module copies a file to dest in the dist directory
+// when the goal is built.
+func AssertDistsTo(t *testing.T, result *TestResult, name, variant, goal, dest string) {
+	t.Helper()
+	dests := DistContributionsForTest(t, result, name, variant).DestsForGoal(goal)
+	if !InList(dest, dests) {
+		t.Errorf("%s: expected to dist %q for goal %q, found %q", name, dest, goal, dests)
+	}
+}
+
+// AssertDoesNotDistTo checks that the variant of the module does not copy a file to dest in the
+// dist directory when the goal is built.
+func AssertDoesNotDistTo(t *testing.T, result *TestResult, name, variant, goal, dest string) {
+	t.Helper()
+	dests := DistContributionsForTest(t, result, name, variant).DestsForGoal(goal)
+	if InList(dest, dests) {
+		t.Errorf("%s: expected not to dist %q for goal %q, found %q", name, dest, goal, dests)
+	}
+}
+
 // Normalize the path for testing.
 //
 // If the path is relative to the build directory then return the relative path
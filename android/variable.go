@@ -373,6 +373,10 @@ type ProductVariables struct {
 
 	NamespacesToExport []string `json:",omitempty"`
 
+	// If true, modules exported to Make may not list modules that are only defined in namespaces
+	// that aren't exported to Make in their required properties.
+	EnforceRequiredNamespaceExports *bool `json:",omitempty"`
+
 	PgoAdditionalProfileDirs []string `json:",omitempty"`
 
 	VndkUseCoreVariant         *bool `json:",omitempty"`
@@ -426,6 +430,13 @@ type ProductVariables struct {
 
 	ApexGlobalMinSdkVersionOverride *string `json:",omitempty"`
 
+	// If true, only the apex_set modules listed in PrereleaseApexes may extract prerelease apexes.
+	// Other apex_set modules that set prerelease: true are errors, or warnings if
+	// PrereleaseApexesReportOnly is also true.
+	EnforcePrereleaseApexes    *bool    `json:",omitempty"`
+	PrereleaseApexesReportOnly *bool    `json:",omitempty"`
+	PrereleaseApexes           []string `json:",omitempty"`
+
 	EnforceSystemCertificate          *bool    `json:",omitempty"`
 	EnforceSystemCertificateAllowList []string `json:",omitempty"`
 
@@ -448,6 +459,9 @@ type ProductVariables struct {
 	BoardKernelBinaries                []string `json:",omitempty"`
 	BoardKernelModuleInterfaceVersions []string `json:",omitempty"`
 
+	// The kernel version of the board, checked against the kernel_version_constraints of modules.
+	BoardKernelVersion *string `json:",omitempty"`
+
 	BoardMoveRecoveryResourcesToVendorBoot *bool `json:",omitempty"`
 
 	PrebuiltHiddenApiDir *string `json:",omitempty"`
@@ -469,6 +483,20 @@ type ProductVariables struct {
 
 	BuildWarningBadOptionalUsesLibsAllowlist []string `json:",omitempty"`
 
+	EnforceVintfFragmentsAllowlist *bool    `json:",omitempty"`
+	VintfFragmentsAllowlist        []string `json:",omitempty"`
+
+	ModuleOwnersAllowlist           []string `json:",omitempty"`
+	ModuleOwnersAllowlistReportOnly *bool    `json:",omitempty"`
+
+	TeamDirectoryAllowlist           []string `json:",omitempty"`
+	TeamDirectoryAllowlistReportOnly *bool    `json:",omitempty"`
+
+	RestrictedInstallAllowlist []string `json:",omitempty"`
+
+	CheckSymlinkTargets              *bool    `json:",omitempty"`
+	SymlinkTargetCheckExemptPrefixes []string `json:",omitempty"`
+
 	BuildDebugfsRestrictionsEnabled bool `json:",omitempty"`
 
 	RequiresInsecureExecmemForSwiftshader bool `json:",omitempty"`
@@ -517,6 +545,43 @@ type ProductVariables struct {
 	HiddenapiExportableStubs *bool `json:",omitempty"`
 
 	ExportRuntimeApis *bool `json:",omitempty"`
+
+	// InstallPathOverrides maps install directories relative to a device partition, e.g.
+	// "etc/permissions", to the directories that the files that would be installed in them should
+	// be installed in instead.
+	InstallPathOverrides map[string]string `json:",omitempty"`
+
+	// Exclude_from_dist_goals is a list of <module>:<goal> pairs of dist goals that the modules
+	// should not contribute to, e.g. "foo:droidcore" or "foo:sdk*".
+	Exclude_from_dist_goals []string `json:",omitempty"`
+
+	// Dist_in_product_dir puts the dists of all modules in a subdirectory of the dist directory
+	// named after the product, unless the dist sets product_dir: false, so that the dists of
+	// multiple products can share a dist directory.  It also applies to the dists created with
+	// DistForGoal and DistForGoals in MakeVarsContext.
+	Dist_in_product_dir *bool `json:",omitempty"`
+
+	// Disable_module_type_dists ignores the dists that module types declare with
+	// InitModuleTypeDists, so that only the dist and dists properties of the modules are used.
+	Disable_module_type_dists *bool `json:",omitempty"`
+
+	// Module_info_licenses adds the effective license kinds and conditions of the modules to
+	// module-info.json.
+	Module_info_licenses *bool `json:",omitempty"`
+
+	// Multilib_suffix_64bit makes the modules that set suffix_32bit tell apart the variants they
+	// install into the same directory by installing the 64-bit variant with a 64 suffix instead of
+	// the 32-bit variant with a _32 suffix, e.g. foo64 and foo instead of foo and foo_32.
+	Multilib_suffix_64bit *bool `json:",omitempty"`
+
+	// Prebuilt_apex_preference lists the prebuilt apex module types, "apex_set" and
+	// "prebuilt_apex", in order of precedence, to choose between the modules of both types that
+	// provide the same apex.
+	Prebuilt_apex_preference []string `json:",omitempty"`
+
+	// Obsolete_files lists the paths of files that the product no longer installs, relative to the
+	// product out directory, like the obsolete_files property of modules.
+	Obsolete_files []string `json:",omitempty"`
 }
 
 type PartitionQualifiedVariablesType struct {
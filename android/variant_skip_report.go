@@ -0,0 +1,67 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"fmt"
+	"strings"
+)
+
+// When SOONG_VARIANT_SKIP_REPORT is true, out/soong/variant_skip_report.txt lists the decisions made
+// when choosing which variant of another module a module uses, e.g. the image variant of each of
+// its required modules, including the ones that were skipped because no compatible variant exists.
+
+func init() {
+	RegisterVariantSkipReportBuildComponents(InitRegistrationContext)
+}
+
+func RegisterVariantSkipReportBuildComponents(ctx RegistrationContext) {
+	ctx.RegisterParallelSingletonType("variant_skip_report", variantSkipReportSingletonFactory)
+}
+
+func variantSkipReportEnabled(config Config) bool {
+	return config.IsEnvTrue("SOONG_VARIANT_SKIP_REPORT")
+}
+
+// recordVariantSkipDecision adds a line to the variant skip report for the module if
+// SOONG_VARIANT_SKIP_REPORT is true.
+func recordVariantSkipDecision(ctx BaseModuleContext, format string, args ...interface{}) {
+	if !variantSkipReportEnabled(ctx.Config()) {
+		return
+	}
+	m := ctx.Module().base()
+	m.variantSkipDecisions = append(m.variantSkipDecisions, fmt.Sprintf(format, args...))
+}
+
+func variantSkipReportSingletonFactory() Singleton {
+	return &variantSkipReportSingleton{}
+}
+
+// variantSkipReportSingleton writes out/soong/variant_skip_report.txt.
+type variantSkipReportSingleton struct{}
+
+func (s *variantSkipReportSingleton) GenerateBuildActions(ctx SingletonContext) {
+	if !variantSkipReportEnabled(ctx.Config()) {
+		return
+	}
+
+	var lines []string
+	ctx.VisitAllModules(func(module Module) {
+		for _, decision := range module.base().variantSkipDecisions {
+			lines = append(lines, fmt.Sprintf("%s (%s): %s", ctx.ModuleName(module), ctx.ModuleSubDir(module), decision))
+		}
+	})
+	WriteFileRule(ctx, PathForOutput(ctx, "variant_skip_report.txt"), strings.Join(SortedUniqueStrings(lines), "\n"))
+}
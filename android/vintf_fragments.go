@@ -0,0 +1,86 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"sort"
+	"strings"
+)
+
+// The vintf_fragments property is deprecated.  Modules that set it are listed in
+// out/soong/deprecated_vintf_fragments.txt for burndown, and get a warning unless they are in a
+// directory in the VintfFragmentsAllowlist product variable.  When EnforceVintfFragmentsAllowlist
+// is set the warning becomes an error.
+
+func init() {
+	RegisterVintfFragmentsBuildComponents(InitRegistrationContext)
+}
+
+func RegisterVintfFragmentsBuildComponents(ctx RegistrationContext) {
+	ctx.RegisterParallelSingletonType("deprecated_vintf_fragments", deprecatedVintfFragmentsSingletonFactory)
+}
+
+// inVintfFragmentsAllowlist returns true if the directory or one of its parents is in the
+// allowlist.
+func inVintfFragmentsAllowlist(dir string, allowlist []string) bool {
+	for _, allowed := range allowlist {
+		if dir == allowed || strings.HasPrefix(dir, allowed+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func deprecatedVintfFragmentsSingletonFactory() Singleton {
+	return &deprecatedVintfFragmentsSingleton{}
+}
+
+// deprecatedVintfFragmentsSingleton reports the modules that set the deprecated vintf_fragments
+// property and writes out/soong/deprecated_vintf_fragments.txt.
+type deprecatedVintfFragmentsSingleton struct{}
+
+func (s *deprecatedVintfFragmentsSingleton) GenerateBuildActions(ctx SingletonContext) {
+	allowlist := ctx.DeviceConfig().VintfFragmentsAllowlist()
+	enforce := ctx.DeviceConfig().EnforceVintfFragmentsAllowlist()
+
+	// The property is not arch variant, so only list the first variant of each module.
+	listed := make(map[string]bool)
+	var lines []string
+	ctx.VisitAllModules(func(module Module) {
+		if !module.Enabled() || len(module.base().commonProperties.Vintf_fragments) == 0 {
+			return
+		}
+		dir := ctx.ModuleDir(module)
+		line := dir + " " + ctx.ModuleName(module)
+		if listed[line] {
+			return
+		}
+		listed[line] = true
+		lines = append(lines, line)
+
+		if inVintfFragmentsAllowlist(dir, allowlist) {
+			return
+		}
+		if enforce {
+			ctx.ModuleErrorf(module, "vintf_fragments is deprecated and is only allowed in the "+
+				"directories in VintfFragmentsAllowlist")
+		} else {
+			ctx.ModuleWarningf(module, "vintf_fragments is deprecated")
+		}
+	})
+
+	sort.Strings(lines)
+	WriteFileRule(ctx, PathForOutput(ctx, "deprecated_vintf_fragments.txt"), strings.Join(lines, "\n"))
+}
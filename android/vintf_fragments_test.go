@@ -0,0 +1,115 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"testing"
+
+	"github.com/google/blueprint/proptools"
+)
+
+type vintfFragmentsTestModule struct {
+	ModuleBase
+	properties struct {
+		// Vintf fragments installed through InstallVintfFragments, like the fragments extracted from a
+		// prebuilt.
+		Extracted_vintf_fragments []string `android:"path"`
+	}
+}
+
+func vintfFragmentsTestModuleFactory() Module {
+	m := &vintfFragmentsTestModule{}
+	m.AddProperties(&m.properties)
+	InitAndroidArchModule(m, DeviceSupported, MultilibCommon)
+	return m
+}
+
+func (m *vintfFragmentsTestModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+	m.InstallVintfFragments(ctx, PathsForModuleSrc(ctx, m.properties.Extracted_vintf_fragments))
+}
+
+var prepareForVintfFragmentsTest = GroupFixturePreparers(
+	PrepareForTestWithArchMutator,
+	FixtureRegisterWithContext(func(ctx RegistrationContext) {
+		ctx.RegisterModuleType("vintf_fragments_module", vintfFragmentsTestModuleFactory)
+		RegisterVintfFragmentsBuildComponents(ctx)
+	}),
+	FixtureAddTextFile("vendor/foo/Android.bp", `
+		vintf_fragments_module {
+			name: "foo",
+			vintf_fragments: ["foo.xml"],
+		}
+	`),
+	FixtureAddTextFile("system/bar/Android.bp", `
+		vintf_fragments_module {
+			name: "bar",
+			vintf_fragments: ["bar.xml"],
+		}
+
+		vintf_fragments_module {
+			name: "baz",
+			extracted_vintf_fragments: ["baz.xml"],
+		}
+	`),
+	FixtureMergeMockFs(MockFS{
+		"vendor/foo/foo.xml": nil,
+		"system/bar/bar.xml": nil,
+		"system/bar/baz.xml": nil,
+	}),
+)
+
+func TestDeprecatedVintfFragments(t *testing.T) {
+	result := prepareForVintfFragmentsTest.RunTest(t)
+
+	burndown := result.SingletonForTests("deprecated_vintf_fragments").Output("deprecated_vintf_fragments.txt")
+	AssertStringEquals(t, "deprecated_vintf_fragments.txt",
+		"system/bar bar\nvendor/foo foo", ContentFromFileRuleForTests(t, result.TestContext, burndown))
+
+	AssertDeepEquals(t, "build warnings", []BuildWarning{
+		{File: "system/bar/Android.bp", Module: "bar", Message: "vintf_fragments is deprecated"},
+		{File: "vendor/foo/Android.bp", Module: "foo", Message: "vintf_fragments is deprecated"},
+	}, result.Config.BuildWarnings())
+}
+
+func TestDeprecatedVintfFragmentsAllowlist(t *testing.T) {
+	GroupFixturePreparers(
+		prepareForVintfFragmentsTest,
+		FixtureModifyProductVariables(func(variables FixtureProductVariables) {
+			variables.EnforceVintfFragmentsAllowlist = proptools.BoolPtr(true)
+			variables.VintfFragmentsAllowlist = []string{"system"}
+		}),
+	).ExtendWithErrorHandler(FixtureExpectsOneErrorPattern(
+		`module "foo".*: vintf_fragments is deprecated and is only allowed in the directories in VintfFragmentsAllowlist`,
+	)).RunTest(t)
+}
+
+func TestDuplicateVintfFragments(t *testing.T) {
+	GroupFixturePreparers(
+		prepareForVintfFragmentsTest,
+		FixtureAddTextFile("system/dup/Android.bp", `
+			vintf_fragments_module {
+				name: "dup",
+				vintf_fragments: ["manifest.xml"],
+				extracted_vintf_fragments: ["extracted/manifest.xml"],
+			}
+		`),
+		FixtureMergeMockFs(MockFS{
+			"system/dup/manifest.xml":           nil,
+			"system/dup/extracted/manifest.xml": nil,
+		}),
+	).ExtendWithErrorHandler(FixtureExpectsOneErrorPattern(
+		`module "dup".*: vintf fragment "manifest.xml" is installed from both system/dup/manifest.xml and system/dup/extracted/manifest.xml`,
+	)).RunTest(t)
+}
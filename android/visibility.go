@@ -283,6 +283,10 @@ func visibilityRuleChecker(ctx BottomUpMutatorContext) {
 			checkRules(ctx, ctx.ModuleDir(), p.getName(), visibility)
 		}
 	}
+
+	if dumpEffectiveVisibility(ctx.Config()) {
+		recordOwnVisibility(ctx)
+	}
 }
 
 func checkRules(ctx BaseModuleContext, currentPkg, property string, visibility []string) {
@@ -507,7 +511,7 @@ func effectiveVisibilityRules(config Config, qualified qualifiedModuleName) comp
 	if ok {
 		rule = value.(compositeRule)
 	} else {
-		rule = packageDefaultVisibility(moduleToVisibilityRule, qualified)
+		rule, _ = packageDefaultVisibility(moduleToVisibilityRule, qualified)
 	}
 
 	// If no rule is specified then return the default visibility rule to avoid
@@ -523,16 +527,18 @@ func createQualifiedModuleName(moduleName, dir string) qualifiedModuleName {
 	return qualified
 }
 
-func packageDefaultVisibility(moduleToVisibilityRule *sync.Map, moduleId qualifiedModuleName) compositeRule {
+// packageDefaultVisibility returns the default_visibility rules of the closest package containing
+// the module that specifies them, along with the id of that package.
+func packageDefaultVisibility(moduleToVisibilityRule *sync.Map, moduleId qualifiedModuleName) (compositeRule, qualifiedModuleName) {
 	packageQualifiedId := moduleId.getContainingPackageId()
 	for {
 		value, ok := moduleToVisibilityRule.Load(packageQualifiedId)
 		if ok {
-			return value.(compositeRule)
+			return value.(compositeRule), packageQualifiedId
 		}
 
 		if packageQualifiedId.isRootPackage() {
-			return nil
+			return nil, qualifiedModuleName{}
 		}
 
 		packageQualifiedId = packageQualifiedId.getContainingPackageId()
@@ -39,6 +39,7 @@ import (
 
 func init() {
 	registerApexBuildComponents(android.InitRegistrationContext)
+	registerApexDependencyTags()
 }
 
 func registerApexBuildComponents(ctx android.RegistrationContext) {
@@ -65,6 +66,9 @@ func RegisterPreDepsMutators(ctx android.RegisterMutatorsContext) {
 }
 
 func RegisterPostDepsMutators(ctx android.RegisterMutatorsContext) {
+	// Run after the prebuilt mutators have selected between the source apexes and their prebuilts.
+	ctx.BottomUp("prebuilt_apex_candidates", prebuiltApexCandidatesMutator).Parallel()
+	ctx.BottomUp("prebuilt_apex_precedence", prebuiltApexPrecedenceMutator).Parallel()
 	ctx.TopDown("apex_info", apexInfoMutator).Parallel()
 	ctx.BottomUp("apex_unique", apexUniqueVariationsMutator).Parallel()
 	ctx.BottomUp("apex_test_for_deps", apexTestForDepsMutator).Parallel()
@@ -75,6 +79,7 @@ func RegisterPostDepsMutators(ctx android.RegisterMutatorsContext) {
 	ctx.BottomUp("apex", apexMutator).Parallel()
 	ctx.BottomUp("apex_directly_in_any", apexDirectlyInAnyMutator).Parallel()
 	ctx.BottomUp("apex_dcla_deps", apexDCLADepsMutator).Parallel()
+	ctx.BottomUp("apex_compat_symlink_deps", apexCompatSymlinkDepsMutator).Parallel()
 	// Register after apex_info mutator so that it can use ApexVariationName
 	ctx.TopDown("apex_strict_updatability_lint", apexStrictUpdatibilityLintMutator).Parallel()
 }
@@ -128,6 +133,10 @@ type apexBundleProperties struct {
 	// List of platform_compat_config files that are embedded inside this APEX bundle.
 	Compat_configs []string
 
+	// List of compat_symlink modules to install along with this APEX bundle, in addition to the
+	// compat symlinks that are built into Soong for some APEXes.
+	Compat_symlinks []string
+
 	// List of filesystem images that are embedded inside this APEX bundle.
 	Filesystems []string
 
@@ -468,6 +477,9 @@ type apexBundle struct {
 	// Installed locations of symlinks for backward compatibility.
 	compatSymlinks android.InstallPaths
 
+	// Names of the compat_symlink modules listed in the compat_symlinks property.
+	compatSymlinkModules []string
+
 	// Text file having the list of individual files that are included in this APEX. Used for
 	// debugging purpose.
 	installedFilesFile android.WritablePath
@@ -695,6 +707,15 @@ var (
 	shBinaryTag     = &dependencyTag{name: "shBinary", payload: true}
 )
 
+func registerApexDependencyTags() {
+	android.RegisterDependencyTag("apex.javaLibTag", "Dependency of an apex on a java library in its payload.", javaLibTag)
+	android.RegisterDependencyTag("apex.bcpfTag", "Dependency of an apex on a bootclasspath_fragment, which is never replaced by a prebuilt.", bcpfTag)
+	android.RegisterDependencyTag("apex.keyTag", "Dependency of an apex on its apex_key.", keyTag)
+	android.RegisterDependencyTag("apex.exportedJavaLibTag", "Dependency of a prebuilt apex on a java library that it exports.", exportedJavaLibTag)
+	android.RegisterDependencyTag("apex.compatSymlinkOverriddenTag", "Dependency of a prebuilt apex on the apexes listed in its overrides property.", compatSymlinkOverriddenTag)
+	android.RegisterDependencyTag("apex.compatSymlinkTag", "Dependency of an apex or a prebuilt apex on the compat_symlink modules listed in its compat_symlinks property.", compatSymlinkTag)
+}
+
 // TODO(jiyong): shorten this function signature
 func addDependenciesForNativeModules(ctx android.BottomUpMutatorContext, nativeModules ApexNativeDependencies, target android.Target, imageVariation string) {
 	binVariations := target.Variations()
@@ -779,6 +800,7 @@ func (a *apexBundle) DepsMutator(ctx android.BottomUpMutatorContext) {
 	imageVariation := a.getImageVariation(ctx)
 
 	a.combineProperties(ctx)
+	android.CheckNotSelfReferenced(ctx, "overrides", a.overridableProperties.Overrides)
 
 	has32BitTarget := false
 	for _, target := range targets {
@@ -2348,6 +2370,11 @@ func (a *apexBundle) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 	if !a.commonBuildActions(ctx) {
 		return
 	}
+	// Let prebuilt_info.json tell the source apex apart from a missing one.
+	android.SetProvider(ctx, android.PrebuiltInfoProvider, android.PrebuiltInfo{
+		Name:        a.BaseModuleName(),
+		Is_prebuilt: false,
+	})
 	////////////////////////////////////////////////////////////////////////////////////////////
 	// 2) traverse the dependency tree to collect apexFile structs from them.
 
@@ -2412,6 +2439,7 @@ func (a *apexBundle) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 	a.setPayloadFsType(ctx)
 	a.setSystemLibLink(ctx)
 	a.compatSymlinks = makeCompatSymlinks(a.BaseModuleName(), ctx)
+	a.compatSymlinkModules = compatSymlinkModules(ctx)
 
 	////////////////////////////////////////////////////////////////////////////////////////////
 	// 4) generate the build rules to create the APEX. This is done in builder.go.
@@ -2419,6 +2447,7 @@ func (a *apexBundle) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 	a.buildApex(ctx)
 	a.buildApexDependencyInfo(ctx)
 	a.buildLintReports(ctx)
+	a.checkPayloadMatchesPrebuilt(ctx)
 
 	// Set a provider for dexpreopt of bootjars
 	a.provideApexExportsInfo(ctx)
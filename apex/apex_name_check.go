@@ -0,0 +1,136 @@
+// Copyright (C) 2024 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apex
+
+import (
+	"fmt"
+	"strings"
+
+	"android/soong/android"
+)
+
+func init() {
+	registerApexNameCheckComponents(android.InitRegistrationContext)
+}
+
+func registerApexNameCheckComponents(ctx android.RegistrationContext) {
+	ctx.RegisterParallelSingletonType("apex_name_check", apexNameCheckSingletonFactory)
+}
+
+func apexNameCheckSingletonFactory() android.Singleton {
+	return &apexNameCheckSingleton{}
+}
+
+// apexNameCheckSingleton checks that at most one selected module uses each apex name.
+//
+// The apex name of a module is the name that it is known by in the apexkeys.txt file, the compat
+// symlinks and the Android.mk output, i.e. the BaseModuleName() of an apex or prebuilt apex, which
+// for a prebuilt apex is its source_apex_name when set, or the name of the override_apex for an
+// overridden apex.  Two selected modules with the same apex name would be silently merged in
+// those outputs, so report them instead.
+type apexNameCheckSingleton struct{}
+
+// apexNameClaimant is a module that uses an apex name.
+type apexNameClaimant struct {
+	// The name of the module, or of the override_apex for an overridden apex.
+	name string
+
+	// Whether the module is a prebuilt_apex or apex_set.
+	prebuilt bool
+
+	// Whether the module is enabled, preferred over its source or prebuilt counterpart, and exported
+	// to Make.
+	selected bool
+}
+
+func (c apexNameClaimant) String() string {
+	kind := "source"
+	if c.prebuilt {
+		kind = "prebuilt"
+	}
+	preferred := "preferred"
+	if !c.selected {
+		preferred = "not preferred"
+	}
+	return fmt.Sprintf("%q (%s, %s)", c.name, kind, preferred)
+}
+
+// apexNameClaimantFor returns the apex name used by the module, and the claimant for that name, or
+// false if the module is not an apex.
+func apexNameClaimantFor(ctx android.SingletonContext, module android.Module) (string, apexNameClaimant, bool) {
+	var apexName string
+	claimant := apexNameClaimant{name: ctx.ModuleName(module)}
+	switch m := module.(type) {
+	case *apexBundle:
+		apexName = m.BaseModuleName()
+		if overriddenBy := m.GetOverriddenBy(); overriddenBy != "" {
+			apexName = overriddenBy
+			claimant.name = overriddenBy
+		}
+	case *Prebuilt:
+		apexName = m.BaseModuleName()
+		claimant.prebuilt = true
+	case *ApexSet:
+		apexName = m.BaseModuleName()
+		claimant.prebuilt = true
+	default:
+		return "", apexNameClaimant{}, false
+	}
+	claimant.selected = module.Enabled() && android.IsModulePreferred(module) && !module.IsHideFromMake()
+	return apexName, claimant, true
+}
+
+func (s *apexNameCheckSingleton) GenerateBuildActions(ctx android.SingletonContext) {
+	claimants := make(map[string][]apexNameClaimant)
+	ctx.VisitAllModules(func(module android.Module) {
+		apexName, claimant, ok := apexNameClaimantFor(ctx, module)
+		if !ok {
+			return
+		}
+		// Only record each module once, even if it has multiple variants.
+		for i, c := range claimants[apexName] {
+			if c.name == claimant.name {
+				claimants[apexName][i].selected = c.selected || claimant.selected
+				return
+			}
+		}
+		claimants[apexName] = append(claimants[apexName], claimant)
+	})
+
+	for _, err := range apexNameCollisions(claimants) {
+		ctx.Errorf("%s", err)
+	}
+}
+
+// apexNameCollisions returns an error message for each apex name that is used by more than one
+// selected module, listing all the modules that use the name.
+func apexNameCollisions(claimants map[string][]apexNameClaimant) []string {
+	var errs []string
+	for _, apexName := range android.SortedKeys(claimants) {
+		selected := 0
+		var descriptions []string
+		for _, c := range claimants[apexName] {
+			if c.selected {
+				selected++
+			}
+			descriptions = append(descriptions, c.String())
+		}
+		if selected > 1 {
+			errs = append(errs, fmt.Sprintf("apex name %q is used by multiple selected modules, at most one of them may be preferred: %s",
+				apexName, strings.Join(android.SortedUniqueStrings(descriptions), ", ")))
+		}
+	}
+	return errs
+}
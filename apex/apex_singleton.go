@@ -17,6 +17,10 @@
 package apex
 
 import (
+	"encoding/json"
+	"sort"
+	"strings"
+
 	"github.com/google/blueprint"
 
 	"android/soong/android"
@@ -28,6 +32,7 @@ func init() {
 
 func registerApexDepsInfoComponents(ctx android.RegistrationContext) {
 	ctx.RegisterParallelSingletonType("apex_depsinfo_singleton", apexDepsInfoSingletonFactory)
+	ctx.RegisterParallelSingletonType("apex_prebuiltinfo_singleton", apexPrebuiltInfoSingletonFactory)
 }
 
 type apexDepsInfoSingleton struct {
@@ -129,3 +134,183 @@ func (s *apexDepsInfoSingleton) MakeVars(ctx android.MakeVarsContext) {
 	// Export check result to Make. The path is added to droidcore.
 	ctx.Strict("APEX_ALLOWED_DEPS_CHECK", s.allowedApexDepsInfoCheckResult.String())
 }
+
+// The selection states of an apex in prebuilt_info.json.
+const (
+	// The source apex module is used.
+	apexSelectionSource = "source"
+
+	// A prebuilt apex module is used.
+	apexSelectionPrebuilt = "prebuilt"
+
+	// None of the modules of the apex are used, e.g. because they are all disabled.
+	apexSelectionNone = "none"
+)
+
+// apexPrebuiltInfo is the entry of an apex in prebuilt_info.json.
+type apexPrebuiltInfo struct {
+	// Name of the apex, without the prebuilt_ prefix.
+	Name string
+
+	// One of the apexSelection* constants.
+	Selection string
+
+	// The module that won the source/prebuilt arbitration, if any.
+	Selected_module string `json:",omitempty"`
+
+	// Path to the prebuilt_info file of the prebuilt apex, if any.
+	Prebuilt_info_file_path string `json:",omitempty"`
+
+	// The prebuilt_info files selected by each variant of the prebuilts of the apex, sorted by module
+	// and variant.
+	Prebuilt_info_files []apexPrebuiltInfoFile `json:",omitempty"`
+
+	// Whether prerelease: true of a prebuilt of the apex was ignored because the apex is not in the
+	// PrereleaseApexes product variable.
+	Ignored_prerelease bool `json:",omitempty"`
+
+	// Why the prebuilts of the apex that were force disabled in favor of a prebuilt of another type
+	// were, sorted by module.
+	Force_disabled []apexForceDisabledPrebuilt `json:",omitempty"`
+
+	// The prefer arbitration between the prebuilts of the apex, if more than one prebuilt shadows it.
+	Prebuilt_arbitration *apexPrebuiltArbitration `json:",omitempty"`
+}
+
+// apexPrebuiltArbitration records which of the prebuilts that shadow the same source apex set
+// prefer: true.
+type apexPrebuiltArbitration struct {
+	// The prebuilt that sets prefer: true.
+	Winner string
+
+	// The other prebuilts of the apex.
+	Losers []string
+}
+
+// apexForceDisabledPrebuilt is a prebuilt of an apex that was force disabled.
+type apexForceDisabledPrebuilt struct {
+	Module string
+	Reason string
+}
+
+// apexPrebuiltInfoFile is the prebuilt_info file selected by a variant of a prebuilt apex.
+type apexPrebuiltInfoFile struct {
+	// The prebuilt module and its variant.
+	Module  string
+	Variant string
+
+	// The architecture of the variant, which selected the prebuilt_info file.
+	Arch string `json:",omitempty"`
+
+	// Path to the prebuilt_info file.
+	Path string
+}
+
+type apexPrebuiltInfoSingleton struct {
+	out android.OutputPath
+}
+
+func apexPrebuiltInfoSingletonFactory() android.Singleton {
+	return &apexPrebuiltInfoSingleton{}
+}
+
+// GenerateBuildActions writes prebuilt_info.json, which lists every source and prebuilt apex in the
+// product together with the module that is used for it.
+func (s *apexPrebuiltInfoSingleton) GenerateBuildActions(ctx android.SingletonContext) {
+	apexes := make(map[string]*apexPrebuiltInfo)
+	// The enabled prebuilts of each apex, and the ones among them that set prefer: true.
+	prebuilts := make(map[string][]string)
+	preferred := make(map[string][]string)
+	// The apexes that have a source module.
+	hasSource := make(map[string]bool)
+	ctx.VisitAllModules(func(module android.Module) {
+		info, ok := android.SingletonModuleProvider(ctx, module, android.PrebuiltInfoProvider)
+		if !ok {
+			return
+		}
+		apex := apexes[info.Name]
+		if apex == nil {
+			apex = &apexPrebuiltInfo{Name: info.Name, Selection: apexSelectionNone}
+			apexes[info.Name] = apex
+		}
+		if !info.Is_prebuilt {
+			hasSource[info.Name] = true
+		}
+		if info.Ignored_prerelease {
+			apex.Ignored_prerelease = true
+		}
+		if info.Force_disable_reason != "" && ctx.PrimaryModule(module) == module {
+			apex.Force_disabled = append(apex.Force_disabled, apexForceDisabledPrebuilt{
+				Module: ctx.ModuleName(module),
+				Reason: info.Force_disable_reason,
+			})
+		}
+		if info.Is_prebuilt && module.Enabled() && ctx.PrimaryModule(module) == module {
+			name := ctx.ModuleName(module)
+			prebuilts[info.Name] = append(prebuilts[info.Name], name)
+			if android.GetEmbeddedPrebuilt(module).Prefer() {
+				preferred[info.Name] = append(preferred[info.Name], name)
+			}
+		}
+		if info.Prebuilt_info_file_path != "" {
+			apex.Prebuilt_info_file_path = info.Prebuilt_info_file_path
+			apex.Prebuilt_info_files = append(apex.Prebuilt_info_files, apexPrebuiltInfoFile{
+				Module:  ctx.ModuleName(module),
+				Variant: ctx.ModuleSubDir(module),
+				Arch:    info.Prebuilt_info_arch,
+				Path:    info.Prebuilt_info_file_path,
+			})
+		}
+		// The modules that lost the arbitration, or that were force disabled, are hidden from Make.
+		if android.IsModulePreferred(module) && !module.IsHideFromMake() {
+			apex.Selected_module = ctx.ModuleName(module)
+			if info.Is_prebuilt {
+				apex.Selection = apexSelectionPrebuilt
+			} else {
+				apex.Selection = apexSelectionSource
+			}
+		}
+	})
+
+	// More than one prebuilt with prefer: true for an apex with a source module is already reported
+	// by the prebuilt mutators, which only check the prebuilts of source modules.
+	for _, name := range android.SortedKeys(prebuilts) {
+		if len(preferred[name]) > 1 {
+			if !hasSource[name] {
+				ctx.Errorf("apex %q has no source module and more than one prebuilt with prefer: true: %s",
+					name, strings.Join(android.SortedUniqueStrings(preferred[name]), ", "))
+			}
+		} else if len(preferred[name]) == 1 && len(prebuilts[name]) > 1 {
+			winner := preferred[name][0]
+			apexes[name].Prebuilt_arbitration = &apexPrebuiltArbitration{
+				Winner: winner,
+				Losers: android.SortedUniqueStrings(android.RemoveListFromList(prebuilts[name], []string{winner})),
+			}
+		}
+	}
+
+	var infos []*apexPrebuiltInfo
+	for _, name := range android.SortedKeys(apexes) {
+		files := apexes[name].Prebuilt_info_files
+		sort.Slice(files, func(i, j int) bool {
+			if files[i].Module != files[j].Module {
+				return files[i].Module < files[j].Module
+			}
+			return files[i].Variant < files[j].Variant
+		})
+		disabled := apexes[name].Force_disabled
+		sort.Slice(disabled, func(i, j int) bool { return disabled[i].Module < disabled[j].Module })
+		infos = append(infos, apexes[name])
+	}
+	data, err := json.MarshalIndent(infos, "", "  ")
+	if err != nil {
+		ctx.Errorf("failed to marshal prebuilt_info.json: %s", err)
+		return
+	}
+	s.out = android.PathForOutput(ctx, "prebuilt_info.json")
+	android.WriteFileRule(ctx, s.out, string(data))
+}
+
+func (s *apexPrebuiltInfoSingleton) MakeVars(ctx android.MakeVarsContext) {
+	ctx.DistForGoal("droidcore", s.out)
+}
@@ -15,6 +15,7 @@
 package apex
 
 import (
+	"encoding/json"
 	"fmt"
 	"path"
 	"path/filepath"
@@ -5287,6 +5288,329 @@ func TestPrebuiltMissingSrc(t *testing.T) {
 	`)
 }
 
+func TestPrebuiltSanitized(t *testing.T) {
+	bp := `
+		prebuilt_apex {
+			name: "myapex",
+			src: "myapex-arm64.apex",
+			sanitized: {
+				address: {
+					arch: {
+						arm64: {
+							src: "myapex.asan-arm64.apex",
+						},
+					},
+				},
+				hwaddress: {
+					src: "myapex.hwasan.apex",
+				},
+			},
+		}
+
+		prebuilt_apex {
+			name: "otherapex",
+			src: "myapex-arm64.apex",
+			sanitized: {
+				address: {
+					arch: {
+						x86_64: {
+							src: "myapex.asan-x86_64.apex",
+						},
+					},
+				},
+			},
+		}
+	`
+
+	testCases := []struct {
+		name       string
+		module     string
+		sanitizers []string
+		inputApex  string
+		sanitized  bool
+	}{
+		{
+			name:      "not sanitized",
+			module:    "myapex",
+			inputApex: "myapex-arm64.apex",
+		},
+		{
+			name:       "address",
+			module:     "myapex",
+			sanitizers: []string{"address"},
+			inputApex:  "myapex.asan-arm64.apex",
+			sanitized:  true,
+		},
+		{
+			name:       "hwaddress",
+			module:     "myapex",
+			sanitizers: []string{"hwaddress"},
+			inputApex:  "myapex.hwasan.apex",
+			sanitized:  true,
+		},
+		{
+			name:       "address without source for arch",
+			module:     "otherapex",
+			sanitizers: []string{"address"},
+			inputApex:  "myapex-arm64.apex",
+		},
+		{
+			name:       "hwaddress without source",
+			module:     "otherapex",
+			sanitizers: []string{"hwaddress"},
+			inputApex:  "myapex-arm64.apex",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := testApex(t, bp,
+				android.FixtureMergeMockFs(android.MockFS{
+					"myapex.asan-arm64.apex":  nil,
+					"myapex.asan-x86_64.apex": nil,
+					"myapex.hwasan.apex":      nil,
+				}),
+				android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+					variables.SanitizeDevice = tc.sanitizers
+				}),
+			)
+
+			prebuilt := ctx.ModuleForTests(tc.module, "android_common_"+tc.module).Module().(*Prebuilt)
+			android.AssertStringEquals(t, "inputApex", tc.inputApex, prebuilt.inputApex.String())
+			for _, sanitizer := range tc.sanitizers {
+				android.AssertBoolEquals(t, "hasSanitizedSource", tc.sanitized, prebuilt.hasSanitizedSource(sanitizer))
+			}
+		})
+	}
+}
+
+func TestApexPrebuiltInfo(t *testing.T) {
+	ctx := testApex(t, `
+		apex {
+			name: "myapex",
+			key: "myapex.key",
+			updatable: false,
+		}
+
+		prebuilt_apex {
+			name: "myapex",
+			src: "myapex-arm64.apex",
+			prebuilt_info: "myapex.prebuilt_info",
+		}
+
+		apex {
+			name: "otherapex",
+			key: "myapex.key",
+			updatable: false,
+		}
+
+		prebuilt_apex {
+			name: "otherapex",
+			prefer: true,
+			src: "myapex-arm64.apex",
+			prebuilt_info: "otherapex.prebuilt_info",
+		}
+
+		apex_key {
+			name: "myapex.key",
+			public_key: "testkey.avbpubkey",
+			private_key: "testkey.pem",
+		}
+	`, android.FixtureMergeMockFs(android.MockFS{
+		"myapex.prebuilt_info":    nil,
+		"otherapex.prebuilt_info": nil,
+	}))
+
+	out := ctx.SingletonForTests("apex_prebuiltinfo_singleton").Output("prebuilt_info.json")
+	var infos []apexPrebuiltInfo
+	if err := json.Unmarshal([]byte(android.ContentFromFileRuleForTests(t, ctx, out)), &infos); err != nil {
+		t.Fatalf("failed to parse prebuilt_info.json: %s", err)
+	}
+	byName := make(map[string]apexPrebuiltInfo)
+	for _, info := range infos {
+		byName[info.Name] = info
+	}
+
+	android.AssertDeepEquals(t, "myapex", apexPrebuiltInfo{
+		Name:                    "myapex",
+		Selection:               apexSelectionSource,
+		Selected_module:         "myapex",
+		Prebuilt_info_file_path: "myapex.prebuilt_info",
+		Prebuilt_info_files: []apexPrebuiltInfoFile{{
+			Module:  "prebuilt_myapex",
+			Variant: "android_common_myapex",
+			Arch:    "arm64",
+			Path:    "myapex.prebuilt_info",
+		}},
+	}, byName["myapex"])
+	android.AssertDeepEquals(t, "otherapex", apexPrebuiltInfo{
+		Name:                    "otherapex",
+		Selection:               apexSelectionPrebuilt,
+		Selected_module:         "prebuilt_otherapex",
+		Prebuilt_info_file_path: "otherapex.prebuilt_info",
+		Prebuilt_info_files: []apexPrebuiltInfoFile{{
+			Module:  "prebuilt_otherapex",
+			Variant: "android_common_otherapex",
+			Arch:    "arm64",
+			Path:    "otherapex.prebuilt_info",
+		}},
+	}, byName["otherapex"])
+}
+
+func TestApexPrebuiltInfoArch(t *testing.T) {
+	bp := `
+		prebuilt_apex {
+			name: "myapex",
+			src: "myapex-arm64.apex",
+			prebuilt_info: "myapex.prebuilt_info",
+			arch: {
+				arm64: {
+					src: "myapex-arm64.apex",
+					prebuilt_info: "myapex-arm64.prebuilt_info",
+				},
+				x86_64: {
+					src: "myapex-x86_64.apex",
+					prebuilt_info: "myapex-x86_64.prebuilt_info",
+				},
+			},
+		}
+
+		apex_set {
+			name: "otherapex",
+			set: "otherapex.apks",
+			prebuilt_info: "otherapex.prebuilt_info",
+			arch: {
+				arm64: {
+					prebuilt_info: "otherapex-arm64.prebuilt_info",
+				},
+			},
+		}
+	`
+	fs := android.FixtureMergeMockFs(android.MockFS{
+		"myapex-x86_64.apex":            nil,
+		"myapex.prebuilt_info":          nil,
+		"myapex-arm64.prebuilt_info":    nil,
+		"myapex-x86_64.prebuilt_info":   nil,
+		"otherapex.apks":                nil,
+		"otherapex.prebuilt_info":       nil,
+		"otherapex-arm64.prebuilt_info": nil,
+	})
+
+	testCases := []struct {
+		name          string
+		arch          android.ArchType
+		archVariant   string
+		myapexInfo    string
+		otherapexInfo string
+	}{
+		{
+			name:          "arm64",
+			arch:          android.Arm64,
+			archVariant:   "armv8-a",
+			myapexInfo:    "myapex-arm64.prebuilt_info",
+			otherapexInfo: "otherapex-arm64.prebuilt_info",
+		},
+		{
+			// otherapex has no x86_64 prebuilt_info, so it falls back to the generic one.
+			name:          "x86_64",
+			arch:          android.X86_64,
+			myapexInfo:    "myapex-x86_64.prebuilt_info",
+			otherapexInfo: "otherapex.prebuilt_info",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := testApex(t, bp, fs, withTargets(map[android.OsType][]android.Target{
+				android.Android: {
+					{Os: android.Android, Arch: android.Arch{ArchType: tc.arch, ArchVariant: tc.archVariant}},
+				},
+			}))
+
+			out := ctx.SingletonForTests("apex_prebuiltinfo_singleton").Output("prebuilt_info.json")
+			var infos []apexPrebuiltInfo
+			if err := json.Unmarshal([]byte(android.ContentFromFileRuleForTests(t, ctx, out)), &infos); err != nil {
+				t.Fatalf("failed to parse prebuilt_info.json: %s", err)
+			}
+			byName := make(map[string]apexPrebuiltInfo)
+			for _, info := range infos {
+				byName[info.Name] = info
+			}
+
+			android.AssertDeepEquals(t, "myapex prebuilt_info files", []apexPrebuiltInfoFile{{
+				Module:  "prebuilt_myapex",
+				Variant: "android_common_myapex",
+				Arch:    tc.arch.String(),
+				Path:    tc.myapexInfo,
+			}}, byName["myapex"].Prebuilt_info_files)
+			android.AssertStringEquals(t, "myapex prebuilt_info", tc.myapexInfo,
+				byName["myapex"].Prebuilt_info_file_path)
+			android.AssertDeepEquals(t, "otherapex prebuilt_info files", []apexPrebuiltInfoFile{{
+				Module:  "prebuilt_otherapex",
+				Variant: "android_common_otherapex",
+				Arch:    tc.arch.String(),
+				Path:    tc.otherapexInfo,
+			}}, byName["otherapex"].Prebuilt_info_files)
+		})
+	}
+}
+
+func TestApexPrebuiltInfoArbitration(t *testing.T) {
+	bp := func(preferV2, withSource bool) string {
+		source := ""
+		if withSource {
+			source = `
+				apex {
+					name: "myapex",
+					key: "myapex.key",
+					updatable: false,
+				}
+			`
+		}
+		return source + fmt.Sprintf(`
+			prebuilt_apex {
+				name: "myapex.v1",
+				source_apex_name: "myapex",
+				src: "myapex-arm64.apex",
+				prefer: true,
+			}
+
+			prebuilt_apex {
+				name: "myapex.v2",
+				source_apex_name: "myapex",
+				src: "myapex-arm64.apex",
+				prefer: %t,
+			}
+
+			apex_key {
+				name: "myapex.key",
+				public_key: "testkey.avbpubkey",
+				private_key: "testkey.pem",
+			}
+		`, preferV2)
+	}
+
+	// The conflict between the prebuilts of a source apex is reported by the prebuilt mutators.
+	t.Run("conflict without source", func(t *testing.T) {
+		testApexError(t, `apex "myapex" has no source module and more than one prebuilt with prefer: true: prebuilt_myapex.v1, prebuilt_myapex.v2`,
+			bp(true, false))
+	})
+
+	t.Run("single winner", func(t *testing.T) {
+		ctx := testApex(t, bp(false, true))
+
+		out := ctx.SingletonForTests("apex_prebuiltinfo_singleton").Output("prebuilt_info.json")
+		var infos []apexPrebuiltInfo
+		if err := json.Unmarshal([]byte(android.ContentFromFileRuleForTests(t, ctx, out)), &infos); err != nil {
+			t.Fatalf("failed to parse prebuilt_info.json: %s", err)
+		}
+		android.AssertIntEquals(t, "number of apexes", 1, len(infos))
+		android.AssertDeepEquals(t, "arbitration", &apexPrebuiltArbitration{
+			Winner: "prebuilt_myapex.v1",
+			Losers: []string{"prebuilt_myapex.v2"},
+		}, infos[0].Prebuilt_arbitration)
+	})
+}
+
 func TestPrebuiltFilenameOverride(t *testing.T) {
 	ctx := testApex(t, `
 		prebuilt_apex {
@@ -5339,6 +5663,67 @@ func TestApexSetFilenameOverride(t *testing.T) {
 	`)
 }
 
+func TestPrebuiltFilenameSoongConfigVariable(t *testing.T) {
+	bp := `
+		soong_config_module_type {
+			name: "carrier_prebuilt_apex",
+			module_type: "prebuilt_apex",
+			config_namespace: "carrier",
+			variables: ["variant"],
+			properties: ["filename"],
+		}
+
+		soong_config_string_variable {
+			name: "variant",
+			values: ["red", "blue"],
+		}
+
+		carrier_prebuilt_apex {
+			name: "myapex",
+			src: "myapex-arm.apex",
+			soong_config_variables: {
+				variant: {
+					red: {
+						filename: "myapex.red.apex",
+					},
+					blue: {
+						filename: "myapex.blue.apex",
+					},
+				},
+			},
+		}
+	`
+
+	for _, variant := range []string{"red", "blue"} {
+		t.Run(variant, func(t *testing.T) {
+			ctx := testApex(t, bp,
+				android.PrepareForTestWithSoongConfigModuleBuildComponents,
+				android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+					variables.VendorVars = map[string]map[string]string{
+						"carrier": {"variant": variant},
+					}
+				}),
+			)
+
+			filename := "myapex." + variant + ".apex"
+			testingModule := ctx.ModuleForTests("myapex", "android_common_myapex")
+			p := testingModule.Module().(*Prebuilt)
+
+			android.AssertStringEquals(t, "installFilename", filename, p.installFilename)
+			android.AssertPathRelativeToTopEquals(t, "outputApex",
+				"out/soong/.intermediates/myapex/android_common_myapex/"+filename, p.outputApex)
+			android.AssertPathRelativeToTopEquals(t, "installedFile",
+				"out/soong/target/product/test_device/system/apex/"+filename, p.installedFile)
+
+			content := android.ContentFromFileRuleForTests(t, ctx, testingModule.Output("apexkeys.txt"))
+			ensureContains(t, content, fmt.Sprintf(`name=%q public_key="PRESIGNED"`, filename))
+
+			entries := android.AndroidMkEntriesForTest(t, ctx, p)[0]
+			android.AssertStringEquals(t, "LOCAL_MODULE_STEM", filename, entries.EntryMap["LOCAL_MODULE_STEM"][0])
+		})
+	}
+}
+
 func TestPrebuiltOverrides(t *testing.T) {
 	ctx := testApex(t, `
 		prebuilt_apex {
@@ -5365,6 +5750,52 @@ func TestPrebuiltOverrides(t *testing.T) {
 	android.AssertStringEquals(t, "Invalid args", "/system/apex/myapex.prebuilt.apex", rule.Args["install_path"])
 }
 
+func TestPrebuiltExportInitRc(t *testing.T) {
+	ctx := testApex(t, `
+		prebuilt_apex {
+			name: "myapex",
+			src: "myapex-arm.apex",
+			export_init_rc: ["etc/myapex.rc"],
+			export_vintf_fragments: ["etc/vintf/myapex.xml"],
+		}
+
+		prebuilt_apex {
+			name: "otherapex",
+			src: "myapex-arm.apex",
+		}
+	`)
+
+	// The files are extracted from the apex by the deapexer.
+	ensureExactDeapexedContents(t, ctx, "prebuilt_myapex", "android_common", []string{
+		"etc/myapex.rc",
+		"etc/vintf/myapex.xml",
+	})
+	extractedInitRc := "out/soong/.intermediates/prebuilt_myapex.deapexer/android_common/deapexer/etc/myapex.rc"
+	extractedVintf := "out/soong/.intermediates/prebuilt_myapex.deapexer/android_common/deapexer/etc/vintf/myapex.xml"
+
+	p := ctx.ModuleForTests("myapex", "android_common_myapex").Module().(*Prebuilt)
+	android.AssertPathsRelativeToTopEquals(t, "init rc", []string{extractedInitRc}, p.InitRc())
+	android.AssertPathsRelativeToTopEquals(t, "vintf fragments", []string{extractedVintf}, p.VintfFragments())
+
+	// They are installed outside the apex like the files listed in init_rc and vintf_fragments.
+	var installed []string
+	for _, spec := range p.PackagingSpecs() {
+		installed = append(installed, spec.RelPathInPackage())
+	}
+	android.AssertStringListContains(t, "installed init rc", installed, "etc/init/myapex.rc")
+	android.AssertStringListContains(t, "installed vintf fragment", installed, "etc/vintf/manifest/myapex.xml")
+
+	entries := android.AndroidMkEntriesForTest(t, ctx, p)[0]
+	android.AssertStringPathsRelativeToTopEquals(t, "LOCAL_FULL_INIT_RC", ctx.Config(),
+		[]string{extractedInitRc}, entries.EntryMap["LOCAL_FULL_INIT_RC"])
+	android.AssertStringPathsRelativeToTopEquals(t, "LOCAL_FULL_VINTF_FRAGMENTS", ctx.Config(),
+		[]string{extractedVintf}, entries.EntryMap["LOCAL_FULL_VINTF_FRAGMENTS"])
+
+	// No deapexer is created for a prebuilt apex that doesn't export anything.
+	android.AssertDeepEquals(t, "otherapex deapexer variants", []string(nil),
+		ctx.ModuleVariantsForTests("prebuilt_otherapex.deapexer"))
+}
+
 func TestPrebuiltApexName(t *testing.T) {
 	testApex(t, `
 		prebuilt_apex {
@@ -5383,6 +5814,80 @@ func TestPrebuiltApexName(t *testing.T) {
 	`).ModuleForTests("com.company.android.myapex", "android_common_com.android.myapex")
 }
 
+func TestPrebuiltApexMinSdkVersion(t *testing.T) {
+	checkMinSdkVersion := func(t *testing.T, ctx *android.TestContext, name, expected string) {
+		t.Helper()
+		module := ctx.ModuleForTests(name, "android_common_myapex")
+		check := module.MaybeOutput("min_sdk_version_check.stamp")
+		cp := module.Rule("android/soong/android.Cp")
+		if expected == "" {
+			android.AssertBoolEquals(t, "no min_sdk_version check", true, check.Rule == nil)
+			android.AssertBoolEquals(t, "no validation", true, cp.Validation == nil)
+			return
+		}
+		// The check of the payload runs at ninja time and fails if its min sdk version is different.
+		android.AssertStringDoesContain(t, "min_sdk_version", check.RuleParams.Command, `!= "`+expected+`"`)
+		android.AssertStringEquals(t, "validation", check.Output.String(), cp.Validation.String())
+		android.AssertStringEquals(t, "checked apex", cp.Input.String(), check.Input.String())
+	}
+
+	checkApexInfo := func(t *testing.T, ctx *android.TestContext, expected android.ApiLevel) {
+		t.Helper()
+		libfoo := ctx.ModuleForTests("libfoo", "android_common_myapex").Module()
+		apexInfo, _ := android.SingletonModuleProvider(ctx, libfoo, android.ApexInfoProvider)
+		android.AssertStringEquals(t, "MinSdkVersion of apex variant", expected.String(), apexInfo.MinSdkVersion.String())
+	}
+
+	t.Run("prebuilt_apex", func(t *testing.T) {
+		ctx := testDexpreoptWithApexes(t, `
+			prebuilt_apex {
+				name: "myapex",
+				src: "myapex-arm64.apex",
+				min_sdk_version: "31",
+				exported_java_libs: ["libfoo"],
+			}
+
+			java_import {
+				name: "libfoo",
+				jars: ["libfoo.jar"],
+			}
+		`, "", android.NullFixturePreparer)
+
+		checkMinSdkVersion(t, ctx, "myapex", "31")
+		checkApexInfo(t, ctx, android.ApiLevelForTest("31"))
+	})
+
+	t.Run("apex_set", func(t *testing.T) {
+		ctx := testApex(t, `
+			apex_set {
+				name: "myapex",
+				set: "myapex.apks",
+				min_sdk_version: "30",
+			}
+		`)
+
+		checkMinSdkVersion(t, ctx, "myapex", "30")
+	})
+
+	t.Run("absent", func(t *testing.T) {
+		ctx := testDexpreoptWithApexes(t, `
+			prebuilt_apex {
+				name: "myapex",
+				src: "myapex-arm64.apex",
+				exported_java_libs: ["libfoo"],
+			}
+
+			java_import {
+				name: "libfoo",
+				jars: ["libfoo.jar"],
+			}
+		`, "", android.NullFixturePreparer)
+
+		checkMinSdkVersion(t, ctx, "myapex", "")
+		checkApexInfo(t, ctx, android.ApiLevel{})
+	})
+}
+
 func TestPrebuiltApexNameWithPlatformBootclasspath(t *testing.T) {
 	_ = android.GroupFixturePreparers(
 		java.PrepareForTestWithJavaDefaultModules,
@@ -7439,7 +7944,7 @@ func TestOverrideApex(t *testing.T) {
 	ensureContains(t, androidMk, "LOCAL_MODULE := override_app.override_myapex")
 	ensureContains(t, androidMk, "LOCAL_MODULE := overrideBpf.o.override_myapex")
 	ensureContains(t, androidMk, "LOCAL_MODULE_STEM := override_myapex.apex")
-	ensureContains(t, androidMk, "LOCAL_OVERRIDES_MODULES := unknownapex myapex")
+	ensureContains(t, androidMk, "LOCAL_OVERRIDES_MODULES := oldapex unknownapex myapex")
 	ensureNotContains(t, androidMk, "LOCAL_MODULE := app.myapex")
 	ensureNotContains(t, androidMk, "LOCAL_MODULE := bpf.myapex")
 	ensureNotContains(t, androidMk, "LOCAL_MODULE := override_app.myapex")
@@ -9317,28 +9822,344 @@ func TestApexSet(t *testing.T) {
 	}
 }
 
-func TestApexSet_NativeBridge(t *testing.T) {
-	ctx := testApex(t, `
+func TestPrebuiltApexAndApexSetPrecedence(t *testing.T) {
+	bp := `
+		prebuilt_apex {
+			name: "myapex.dev",
+			apex_name: "myapex",
+			src: "myapex-arm64.apex",
+		}
+
 		apex_set {
-			name: "myapex",
+			name: "myapex.release",
+			apex_name: "myapex",
 			set: "myapex.apks",
-			filename: "foo_v2.apex",
-			overrides: ["foo"],
 		}
-	`,
-		android.FixtureModifyConfig(func(config android.Config) {
-			config.Targets[android.Android] = []android.Target{
-				{Os: android.Android, Arch: android.Arch{ArchType: android.X86_64, ArchVariant: "", Abi: []string{"x86_64"}}},
-				{Os: android.Android, Arch: android.Arch{ArchType: android.Arm64, ArchVariant: "armv8-a", Abi: []string{"arm64-v8a"}}, NativeBridge: android.NativeBridgeEnabled},
-			}
-		}),
-	)
 
-	m := ctx.ModuleForTests("prebuilt_myapex.apex.extractor", "android_common")
+		apex_set {
+			name: "myapex.beta",
+			apex_name: "myapex",
+			set: "myapex.apks",
+		}
+	`
 
-	// Check extract_apks tool parameters. No native bridge arch expected
-	extractedApex := m.Output("extracted/myapex.apks")
-	android.AssertStringEquals(t, "abis", "X86_64", extractedApex.Args["abis"])
+	preference := func(types ...string) android.FixturePreparer {
+		return android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+			variables.Prebuilt_apex_preference = types
+		})
+	}
+
+	t.Run("unconfigured", func(t *testing.T) {
+		android.GroupFixturePreparers(prepareForApexTest).
+			ExtendWithErrorHandler(android.FixtureExpectsAllErrorsToMatchAPattern([]string{
+				`apex_set "prebuilt_myapex.beta" and prebuilt_apex "prebuilt_myapex.dev" both provide the apex "myapex", ` +
+					`set Prebuilt_apex_preference to choose between them`,
+				`prebuilt_apex "prebuilt_myapex.dev" and apex_set "prebuilt_myapex.release" both provide the apex "myapex", ` +
+					`set Prebuilt_apex_preference to choose between them`,
+			})).
+			RunTestWithBp(t, bp)
+	})
+
+	testCases := []struct {
+		name       string
+		preference []string
+		disabled   map[string]string
+		enabled    []string
+	}{
+		{
+			name:       "apex_set preferred",
+			preference: []string{"apex_set", "prebuilt_apex"},
+			disabled: map[string]string{
+				"myapex.dev": `Prebuilt_apex_preference prefers apex_set "prebuilt_myapex.beta", ` +
+					`apex_set "prebuilt_myapex.release" for the apex "myapex"`,
+			},
+			enabled: []string{"myapex.beta", "myapex.release"},
+		},
+		{
+			name:       "prebuilt_apex preferred",
+			preference: []string{"prebuilt_apex", "apex_set"},
+			disabled: map[string]string{
+				"myapex.beta":    `Prebuilt_apex_preference prefers prebuilt_apex "prebuilt_myapex.dev" for the apex "myapex"`,
+				"myapex.release": `Prebuilt_apex_preference prefers prebuilt_apex "prebuilt_myapex.dev" for the apex "myapex"`,
+			},
+			enabled: []string{"myapex.dev"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := testApex(t, bp, preference(tc.preference...))
+
+			out := ctx.SingletonForTests("apex_prebuiltinfo_singleton").Output("prebuilt_info.json")
+			var infos []apexPrebuiltInfo
+			if err := json.Unmarshal([]byte(android.ContentFromFileRuleForTests(t, ctx, out)), &infos); err != nil {
+				t.Fatalf("failed to parse prebuilt_info.json: %s", err)
+			}
+			reasons := make(map[string]string)
+			for _, info := range infos {
+				for _, disabled := range info.Force_disabled {
+					reasons[info.Name] = disabled.Reason
+				}
+			}
+			android.AssertDeepEquals(t, "force disable reasons in prebuilt_info.json", tc.disabled, reasons)
+
+			for name, reason := range tc.disabled {
+				disabled := prebuiltApexCommon(ctx.ModuleForTests(name, "android_common_myapex").Module())
+				android.AssertBoolEquals(t, name+" is force disabled", true, disabled.isForceDisabled())
+				android.AssertBoolEquals(t, name+" is hidden from Make", true, disabled.IsHideFromMake())
+				android.AssertStringEquals(t, name+" force disable reason", reason,
+					disabled.prebuiltCommonProperties.ForceDisableReason)
+			}
+
+			for _, name := range tc.enabled {
+				enabled := prebuiltApexCommon(ctx.ModuleForTests(name, "android_common_myapex").Module())
+				android.AssertBoolEquals(t, name+" is force disabled", false, enabled.isForceDisabled())
+				android.AssertBoolEquals(t, name+" is hidden from Make", false, enabled.IsHideFromMake())
+			}
+		})
+	}
+
+	// The source apex still replaces the prebuilts that shadow it without a preference.
+	t.Run("source apex", func(t *testing.T) {
+		ctx := testApex(t, `
+			prebuilt_apex {
+				name: "myapex.dev",
+				source_apex_name: "myapex",
+				src: "myapex-arm64.apex",
+			}
+
+			apex_set {
+				name: "myapex.release",
+				source_apex_name: "myapex",
+				set: "myapex.apks",
+			}
+
+			apex {
+				name: "myapex",
+				key: "myapex.key",
+				updatable: false,
+			}
+
+			apex_key {
+				name: "myapex.key",
+				public_key: "testkey.avbpubkey",
+				private_key: "testkey.pem",
+			}
+		`)
+		for _, name := range []string{"prebuilt_myapex.dev", "prebuilt_myapex.release"} {
+			prebuilt := prebuiltApexCommon(ctx.ModuleForTests(name, "android_common_myapex").Module())
+			android.AssertBoolEquals(t, name+" is force disabled", false, prebuilt.isForceDisabled())
+			android.AssertBoolEquals(t, name+" is hidden from Make", true, prebuilt.IsHideFromMake())
+		}
+	})
+}
+
+func TestApexSet_NativeBridge(t *testing.T) {
+	ctx := testApex(t, `
+		apex_set {
+			name: "myapex",
+			set: "myapex.apks",
+			filename: "foo_v2.apex",
+			overrides: ["foo"],
+		}
+	`,
+		android.FixtureModifyConfig(func(config android.Config) {
+			config.Targets[android.Android] = []android.Target{
+				{Os: android.Android, Arch: android.Arch{ArchType: android.X86_64, ArchVariant: "", Abi: []string{"x86_64"}}},
+				{Os: android.Android, Arch: android.Arch{ArchType: android.Arm64, ArchVariant: "armv8-a", Abi: []string{"arm64-v8a"}}, NativeBridge: android.NativeBridgeEnabled},
+			}
+		}),
+	)
+
+	m := ctx.ModuleForTests("prebuilt_myapex.apex.extractor", "android_common")
+
+	// Check extract_apks tool parameters. No native bridge arch expected
+	extractedApex := m.Output("extracted/myapex.apks")
+	android.AssertStringEquals(t, "abis", "X86_64", extractedApex.Args["abis"])
+}
+
+func TestApexSetPrerelease(t *testing.T) {
+	bp := func(prerelease string) string {
+		return `
+			apex_set {
+				name: "myapex",
+				set: "myapex.apks",
+				` + prerelease + `
+			}
+		`
+	}
+
+	enforce := func(reportOnly bool, allowed ...string) android.FixturePreparer {
+		return android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+			variables.EnforcePrereleaseApexes = proptools.BoolPtr(true)
+			variables.PrereleaseApexesReportOnly = proptools.BoolPtr(reportOnly)
+			variables.PrereleaseApexes = allowed
+		})
+	}
+
+	envDefault := android.FixtureMergeEnv(map[string]string{
+		"SOONG_ALLOW_PRERELEASE_APEXES": "true",
+	})
+
+	testCases := []struct {
+		name       string
+		prerelease string
+		preparer   android.FixturePreparer
+		expected   string
+	}{
+		{
+			name:     "env default without enforcement",
+			preparer: envDefault,
+			expected: "true",
+		},
+		{
+			name:       "property without enforcement",
+			prerelease: "prerelease: true,",
+			expected:   "true",
+		},
+		{
+			name:       "allowed",
+			prerelease: "prerelease: true,",
+			preparer:   enforce(false, "myapex"),
+			expected:   "true",
+		},
+		{
+			name:     "env default allowed",
+			preparer: android.GroupFixturePreparers(envDefault, enforce(false, "myapex")),
+			expected: "true",
+		},
+		{
+			name:       "allowed but disabled by property",
+			prerelease: "prerelease: false,",
+			preparer:   android.GroupFixturePreparers(envDefault, enforce(false, "myapex")),
+			expected:   "false",
+		},
+		{
+			name:     "env default not allowed",
+			preparer: android.GroupFixturePreparers(envDefault, enforce(false, "otherapex")),
+			expected: "false",
+		},
+		{
+			name:       "denied in report only mode",
+			prerelease: "prerelease: true,",
+			preparer:   enforce(true, "otherapex"),
+			expected:   "false",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			preparer := android.NullFixturePreparer
+			if tc.preparer != nil {
+				preparer = tc.preparer
+			}
+			ctx := testApex(t, bp(tc.prerelease), preparer)
+			extractedApex := ctx.ModuleForTests("prebuilt_myapex.apex.extractor", "android_common").Output("extracted/myapex.apks")
+			android.AssertStringEquals(t, "allow-prereleased", tc.expected, extractedApex.Args["allow-prereleased"])
+		})
+	}
+
+	t.Run("denied", func(t *testing.T) {
+		testApexError(t, `prerelease: "myapex" may not use prerelease: true as it is not in the PrereleaseApexes product variable`,
+			bp("prerelease: true,"), enforce(false, "otherapex"))
+	})
+
+	t.Run("ignored in report only mode", func(t *testing.T) {
+		ctx := testApex(t, bp("prerelease: true,"), enforce(true, "otherapex"))
+
+		android.AssertDeepEquals(t, "warnings", []android.BuildWarning{{
+			File:     "Android.bp",
+			Module:   "prebuilt_myapex",
+			Property: "prerelease",
+			Message:  `ignoring prerelease: true as "myapex" is not in the PrereleaseApexes product variable`,
+		}}, ctx.Config().BuildWarnings())
+
+		out := ctx.SingletonForTests("apex_prebuiltinfo_singleton").Output("prebuilt_info.json")
+		var infos []apexPrebuiltInfo
+		if err := json.Unmarshal([]byte(android.ContentFromFileRuleForTests(t, ctx, out)), &infos); err != nil {
+			t.Fatalf("failed to parse prebuilt_info.json: %s", err)
+		}
+		if len(infos) != 1 || !infos[0].Ignored_prerelease {
+			t.Errorf("expected prebuilt_info.json to record the ignored prerelease: true, got %+v", infos)
+		}
+	})
+
+	t.Run("not ignored when allowed", func(t *testing.T) {
+		ctx := testApex(t, bp("prerelease: true,"), enforce(true, "myapex"))
+		android.AssertDeepEquals(t, "warnings", []android.BuildWarning(nil), ctx.Config().BuildWarnings())
+	})
+
+	// The apex_set is allowed by its own name rather than its source_apex_name, both when extracting
+	// the apex and when reporting the ignored prerelease: true.
+	sourceApexNameBp := `
+		apex_set {
+			name: "myapex.v2",
+			source_apex_name: "myapex",
+			set: "myapex.apks",
+			prerelease: true,
+		}
+	`
+
+	t.Run("allowed by module name with source_apex_name", func(t *testing.T) {
+		ctx := testApex(t, sourceApexNameBp, enforce(false, "myapex.v2"))
+		extractedApex := ctx.ModuleForTests("prebuilt_myapex.v2.apex.extractor", "android_common").Output("extracted/myapex.apks")
+		android.AssertStringEquals(t, "allow-prereleased", "true", extractedApex.Args["allow-prereleased"])
+		android.AssertDeepEquals(t, "warnings", []android.BuildWarning(nil), ctx.Config().BuildWarnings())
+	})
+
+	t.Run("ignored by module name with source_apex_name", func(t *testing.T) {
+		ctx := testApex(t, sourceApexNameBp, enforce(true, "myapex"))
+		extractedApex := ctx.ModuleForTests("prebuilt_myapex.v2.apex.extractor", "android_common").Output("extracted/myapex.apks")
+		android.AssertStringEquals(t, "allow-prereleased", "false", extractedApex.Args["allow-prereleased"])
+		android.AssertDeepEquals(t, "warnings", []android.BuildWarning{{
+			File:     "Android.bp",
+			Module:   "prebuilt_myapex.v2",
+			Property: "prerelease",
+			Message:  `ignoring prerelease: true as "myapex.v2" is not in the PrereleaseApexes product variable`,
+		}}, ctx.Config().BuildWarnings())
+	})
+}
+
+func TestApexSetSrcs(t *testing.T) {
+	bp := func(srcs string) string {
+		return `
+			apex_set {
+				name: "myapex",
+				` + srcs + `
+			}
+		`
+	}
+
+	t.Run("no sources", func(t *testing.T) {
+		testApexError(t, `module "prebuilt_myapex.apex.extractor" .*: set: missing prebuilt source file`,
+			bp(`sanitized: { hwaddress: { set: "myapex.hwasan.apks" } },`))
+	})
+
+	t.Run("two sources", func(t *testing.T) {
+		testApexError(t, regexp.QuoteMeta(`set: multiple prebuilt source files apply with SANITIZE_TARGET="": set: "myapex.apks", sanitized.none.set: "myapex.none.apks", set only one of them`),
+			bp(`set: "myapex.apks", sanitized: { none: { set: "myapex.none.apks" } },`))
+	})
+
+	t.Run("sanitized source conflicts with set", func(t *testing.T) {
+		testApexError(t, regexp.QuoteMeta(`set: multiple prebuilt source files apply with SANITIZE_TARGET="hwaddress": set: "myapex.apks", sanitized.hwaddress.set: "myapex.hwasan.apks", set only one of them`),
+			bp(`set: "myapex.apks", sanitized: { hwaddress: { set: "myapex.hwasan.apks" } },`),
+			prepareForTestWithSantitizeHwaddress)
+	})
+
+	t.Run("sanitized source replaces none", func(t *testing.T) {
+		ctx := testApex(t, bp(`sanitized: { none: { set: "myapex.apks" }, hwaddress: { set: "myapex.hwasan.apks" } },`),
+			prepareForTestWithSantitizeHwaddress)
+		extractedApex := ctx.ModuleForTests("prebuilt_myapex.apex.extractor", "android_common").Output("extracted/myapex.hwasan.apks")
+		android.AssertArrayString(t, "extractor input", []string{"myapex.hwasan.apks"}, extractedApex.Inputs.Strings())
+	})
+
+	t.Run("missing source with AllowMissingDependencies", func(t *testing.T) {
+		ctx := testApex(t, bp(`set: "missing.apks",`), android.PrepareForTestWithAllowMissingDependencies)
+		extractedApex := ctx.ModuleForTests("prebuilt_myapex.apex.extractor", "android_common").Output("extracted/missing.apks")
+		if extractedApex.Rule != android.ErrorRule {
+			t.Fatalf("expected ErrorRule for the extracted apex, got %s", extractedApex.Rule.String())
+		}
+		android.AssertStringDoesContain(t, "extractor error", extractedApex.Args["error"], "missing.apks")
+	})
 }
 
 func TestNoStaticLinkingToStubsLib(t *testing.T) {
@@ -9443,6 +10264,118 @@ func TestApexKeysTxtOverrides(t *testing.T) {
 	ensureContains(t, content, `name="myapex_set.apex" public_key="PRESIGNED" private_key="PRESIGNED" container_certificate="PRESIGNED" container_private_key="PRESIGNED" partition="system"`)
 }
 
+func TestApexKeysTxtSkipsHiddenPrebuilts(t *testing.T) {
+	bp := `
+		apex {
+			name: "myapex",
+			key: "myapex.key",
+			updatable: false,
+		}
+
+		apex_key {
+			name: "myapex.key",
+			public_key: "testkey.avbpubkey",
+			private_key: "testkey.pem",
+		}
+
+		prebuilt_apex {
+			name: "myapex",
+			prefer: %t,
+			src: "myapex-arm64.apex",
+		}
+	`
+
+	testCases := []struct {
+		name         string
+		prefer       bool
+		preparer     android.FixturePreparer
+		expectedKeys bool
+	}{
+		{
+			name:         "preferred",
+			prefer:       true,
+			preparer:     android.NullFixturePreparer,
+			expectedKeys: true,
+		},
+		{
+			name:     "not preferred",
+			prefer:   false,
+			preparer: android.NullFixturePreparer,
+		},
+		{
+			name:     "force disabled",
+			prefer:   true,
+			preparer: withUnbundledBuild,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := testApex(t, fmt.Sprintf(bp, tc.prefer), tc.preparer)
+
+			module := ctx.ModuleForTests("prebuilt_myapex", "android_common_myapex")
+			prebuilt := module.Module().(*Prebuilt)
+			apexKeys := module.MaybeOutput("apexkeys.txt")
+			android.AssertBoolEquals(t, "writes apexkeys.txt", tc.expectedKeys, apexKeys.Rule != nil)
+			android.AssertBoolEquals(t, "has apexKeysPath", tc.expectedKeys, prebuilt.apexKeysPath != nil)
+			if tc.expectedKeys {
+				content := android.ContentFromFileRuleForTests(t, ctx, apexKeys)
+				ensureContains(t, content, `name="myapex.apex" public_key="PRESIGNED"`)
+			}
+		})
+	}
+}
+
+func TestPrebuiltApexInstallSubdir(t *testing.T) {
+	ctx := testApex(t, `
+		prebuilt_apex {
+			name: "com.android.foo",
+			src: "myapex-arm64.apex",
+		}
+
+		prebuilt_apex {
+			name: "com.android.foo.staged",
+			src: "myapex-arm64.apex",
+			filename: "com.android.foo.apex",
+			install_subdir: "staging",
+		}
+	`)
+
+	for _, tc := range []struct {
+		module      string
+		installPath string
+		apexKeyName string
+	}{
+		{"com.android.foo", "out/soong/target/product/test_device/system/apex/com.android.foo.apex", "com.android.foo.apex"},
+		{"com.android.foo.staged", "out/soong/target/product/test_device/system/apex/staging/com.android.foo.apex", "staging/com.android.foo.apex"},
+	} {
+		t.Run(tc.module, func(t *testing.T) {
+			module := ctx.ModuleForTests(tc.module, "android_common_"+tc.module)
+			prebuilt := module.Module().(*Prebuilt)
+
+			android.AssertPathRelativeToTopEquals(t, "installed file", tc.installPath, prebuilt.installedFile)
+			android.AssertDeepEquals(t, "compat symlinks", android.InstallPaths(nil), prebuilt.compatSymlinks)
+
+			entries := android.AndroidMkEntriesForTest(t, ctx, prebuilt)[0]
+			android.AssertStringPathRelativeToTopEquals(t, "LOCAL_MODULE_PATH", ctx.Config(),
+				filepath.Dir(tc.installPath), entries.EntryMap["LOCAL_MODULE_PATH"][0])
+
+			content := android.ContentFromFileRuleForTests(t, ctx, module.Output("apexkeys.txt"))
+			ensureContains(t, content, `name="`+tc.apexKeyName+`" public_key="PRESIGNED"`)
+		})
+	}
+}
+
+func TestPrebuiltApexInstallSubdirOutsideApexDir(t *testing.T) {
+	testApexError(t, `module "com.android.foo" .*: install_subdir: Path is outside directory: \.\./staging`, `
+		prebuilt_apex {
+			name: "com.android.foo",
+			src: "myapex-arm64.apex",
+			install_subdir: "../staging",
+		}
+	`)
+}
+
 func TestAllowedFiles(t *testing.T) {
 	ctx := testApex(t, `
 		apex {
@@ -10047,7 +10980,6 @@ func TestAndroidMk_RequiredModules(t *testing.T) {
 			key: "myapex.key",
 			updatable: false,
 			java_libs: ["foo"],
-			required: ["otherapex"],
 		}
 
 		apex_key {
@@ -11930,3 +12862,337 @@ func TestInstallationRulesForMultipleApexPrebuilts(t *testing.T) {
 		checkHideFromMake(t, ctx, tc.expectedVisibleModuleName, tc.expectedHiddenModuleNames)
 	}
 }
+
+func TestApexNameCollisions(t *testing.T) {
+	testCases := []struct {
+		desc          string
+		claimants     map[string][]apexNameClaimant
+		expectedError string
+	}{
+		{
+			desc: "source and preferred prebuilt",
+			claimants: map[string][]apexNameClaimant{
+				"myapex": {
+					{name: "myapex", selected: false},
+					{name: "prebuilt_myapex", prebuilt: true, selected: true},
+				},
+			},
+		},
+		{
+			desc: "source and prebuilt with source_apex_name both selected",
+			claimants: map[string][]apexNameClaimant{
+				"myapex": {
+					{name: "myapex", selected: true},
+					{name: "prebuilt_myapex.v2", prebuilt: true, selected: true},
+				},
+			},
+			expectedError: `apex name "myapex" is used by multiple selected modules, at most one of them may be preferred: "myapex" (source, preferred), "prebuilt_myapex.v2" (prebuilt, preferred)`,
+		},
+		{
+			desc: "two prebuilts selected",
+			claimants: map[string][]apexNameClaimant{
+				"myapex": {
+					{name: "myapex", selected: false},
+					{name: "prebuilt_myapex.v1", prebuilt: true, selected: true},
+					{name: "prebuilt_myapex.v2", prebuilt: true, selected: true},
+				},
+			},
+			expectedError: `apex name "myapex" is used by multiple selected modules, at most one of them may be preferred: "myapex" (source, not preferred), "prebuilt_myapex.v1" (prebuilt, preferred), "prebuilt_myapex.v2" (prebuilt, preferred)`,
+		},
+		{
+			desc: "override apex and prebuilt selected",
+			claimants: map[string][]apexNameClaimant{
+				"com.android.foo": {
+					{name: "com.android.foo", selected: true},
+				},
+				"com.google.android.foo": {
+					{name: "com.google.android.foo", selected: true},
+					{name: "prebuilt_com.google.android.foo.v2", prebuilt: true, selected: true},
+				},
+			},
+			expectedError: `apex name "com.google.android.foo" is used by multiple selected modules, at most one of them may be preferred: "com.google.android.foo" (source, preferred), "prebuilt_com.google.android.foo.v2" (prebuilt, preferred)`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			var expected []string
+			if tc.expectedError != "" {
+				expected = []string{tc.expectedError}
+			}
+			android.AssertDeepEquals(t, "errors", expected, apexNameCollisions(tc.claimants))
+		})
+	}
+}
+
+func TestPrebuiltApexOverriddenCompatSymlinks(t *testing.T) {
+	sourceApexBp := `
+		apex {
+			name: "com.android.i18n",
+			key: "myapex.key",
+			updatable: false,
+		}
+
+		apex_key {
+			name: "myapex.key",
+			public_key: "testkey.avbpubkey",
+			private_key: "testkey.pem",
+		}
+	`
+
+	prebuiltBps := map[string]string{
+		"prebuilt_apex": `
+			prebuilt_apex {
+				name: "com.company.android.i18n",
+				src: "myapex-arm.apex",
+				overrides: ["com.android.i18n"],
+			}
+		`,
+		"apex_set": `
+			apex_set {
+				name: "com.company.android.i18n",
+				set: "myapex.apks",
+				overrides: ["com.android.i18n"],
+			}
+		`,
+	}
+
+	icuSymlink := "out/soong/target/product/test_device/system/usr/icu"
+
+	for _, moduleType := range android.SortedKeys(prebuiltBps) {
+		for _, conflicting := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s conflicting=%t", moduleType, conflicting), func(t *testing.T) {
+				bp := prebuiltBps[moduleType]
+				if conflicting {
+					bp += sourceApexBp
+				}
+				ctx := testApex(t, bp, android.FixtureMergeMockFs(android.MockFS{
+					"system/sepolicy/apex/com.android.i18n-file_contexts": nil,
+				}))
+
+				module := ctx.ModuleForTests("com.company.android.i18n", "android_common_com.company.android.i18n").Module()
+				var p *prebuiltCommon
+				switch m := module.(type) {
+				case *Prebuilt:
+					p = &m.prebuiltCommon
+				case *ApexSet:
+					p = &m.prebuiltCommon
+				}
+
+				var expectedSymlinks []string
+				if !conflicting {
+					expectedSymlinks = []string{icuSymlink}
+				}
+				android.AssertPathsRelativeToTopEquals(t, "compat symlinks", expectedSymlinks, p.compatSymlinks.Paths())
+
+				warnings := ctx.Config().BuildWarnings()
+				if !conflicting {
+					android.AssertDeepEquals(t, "warnings", []android.BuildWarning(nil), warnings)
+				} else {
+					android.AssertIntEquals(t, "number of warnings", 1, len(warnings))
+					android.AssertStringEquals(t, "warning module", "prebuilt_com.company.android.i18n", warnings[0].Module)
+					android.AssertStringEquals(t, "warning property", "overrides", warnings[0].Property)
+					android.AssertStringDoesContain(t, "skipped compat symlink warning",
+						android.StringRelativeToTop(ctx.Config(), warnings[0].Message),
+						`skipping compat symlink `+icuSymlink+` for overridden apex "com.android.i18n" as "com.android.i18n" still installs it`)
+				}
+
+				if conflicting {
+					// The overridden apex still installs its own compat symlink.
+					source := ctx.ModuleForTests("com.android.i18n", "android_common_com.android.i18n").Module().(*apexBundle)
+					android.AssertPathsRelativeToTopEquals(t, "source compat symlinks", []string{icuSymlink}, source.compatSymlinks.Paths())
+				}
+			})
+		}
+	}
+}
+
+func TestApexCompatSymlinkModules(t *testing.T) {
+	bp := `
+		apex {
+			name: "myapex",
+			key: "myapex.key",
+			updatable: false,
+			compat_symlinks: ["myapex_symlink"],
+		}
+
+		prebuilt_apex {
+			name: "com.company.android.myapex",
+			src: "myapex-arm.apex",
+			compat_symlinks: ["myapex_symlink"],
+		}
+
+		apex_key {
+			name: "myapex.key",
+			public_key: "testkey.avbpubkey",
+			private_key: "testkey.pem",
+		}
+
+		compat_symlink {
+			name: "myapex_symlink",
+			installed_location: "etc/myapex",
+			symlink_target: "/apex/myapex/etc",
+			allow_absolute_target: true,
+		}
+	`
+	ctx := testApex(t, bp, android.FixtureRegisterWithContext(prebuilt_etc.RegisterInstallSymlinkBuildComponents))
+
+	apexBundle := ctx.ModuleForTests("myapex", "android_common_myapex").Module().(*apexBundle)
+	data := android.AndroidMkDataForTest(t, ctx, apexBundle)
+	var builder strings.Builder
+	data.Custom(&builder, apexBundle.BaseModuleName(), "TARGET_", "", data)
+	ensureMatches(t, builder.String(), `LOCAL_REQUIRED_MODULES := .*\bmyapex_symlink\b`)
+
+	prebuilt := ctx.ModuleForTests("com.company.android.myapex", "android_common_com.company.android.myapex").Module()
+	entries := android.AndroidMkEntriesForTest(t, ctx, prebuilt)[0]
+	android.AssertStringListContains(t, "LOCAL_REQUIRED_MODULES", entries.EntryMap["LOCAL_REQUIRED_MODULES"], "myapex_symlink")
+
+	// The symlink is packaged along with the apexes by Soong too.
+	for _, module := range []android.Module{apexBundle, prebuilt} {
+		var packaged []string
+		for _, spec := range module.TransitivePackagingSpecs() {
+			packaged = append(packaged, spec.Partition()+"/"+spec.RelPathInPackage())
+		}
+		android.AssertStringListContains(t, "transitive packaging specs", packaged, "system/etc/myapex")
+	}
+}
+
+func TestApexCompatSymlinksMustBeCompatSymlinkModules(t *testing.T) {
+	testApexError(t, `"myapex.key" is not a compat_symlink module`, `
+		apex {
+			name: "myapex",
+			key: "myapex.key",
+			updatable: false,
+			compat_symlinks: ["myapex.key"],
+		}
+
+		apex_key {
+			name: "myapex.key",
+			public_key: "testkey.avbpubkey",
+			private_key: "testkey.pem",
+		}
+	`)
+}
+
+func TestApexDependencyTagsAudit(t *testing.T) {
+	entries := make(map[string]android.DependencyTagAuditEntry)
+	for _, entry := range android.AuditDependencyTags() {
+		entries[entry.Name] = entry
+	}
+
+	testCases := []struct {
+		name     string
+		typeName string
+		markers  []string
+	}{
+		{
+			name:     "apex.javaLibTag",
+			typeName: "*apex.dependencyTag",
+			markers:  []string{"ReplaceSourceWithPrebuilt", "SdkMemberDependencyTag"},
+		},
+		{
+			// Bootclasspath fragments are never replaced by prebuilts.
+			name:     "apex.bcpfTag",
+			typeName: "*apex.dependencyTag",
+			markers:  []string{"SdkMemberDependencyTag"},
+		},
+		{
+			name:     "apex.exportedJavaLibTag",
+			typeName: "apex.exportedDependencyTag",
+			markers:  []string{"ExcludeFromVisibilityEnforcementTag", "RequiresFilesFromPrebuiltApexTag"},
+		},
+		{
+			name:     "apex.compatSymlinkOverriddenTag",
+			typeName: "apex.compatSymlinkOverriddenDependencyTag",
+			markers:  []string{"ExcludeFromApexContentsTag", "ExcludeFromVisibilityEnforcementTag"},
+		},
+		{
+			name:     "apex.compatSymlinkTag",
+			typeName: "apex.compatSymlinkDependencyTag",
+			markers:  []string{"ExcludeFromApexContentsTag", "InstallNeededDependencyTag"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			entry, ok := entries[tc.name]
+			android.AssertBoolEquals(t, "registered", true, ok)
+			android.AssertStringEquals(t, "type", tc.typeName, entry.Type)
+			android.AssertDeepEquals(t, "markers", tc.markers, entry.Markers)
+		})
+	}
+}
+
+func TestPrebuiltApexPayloadDiffCheck(t *testing.T) {
+	bp := `
+		apex {
+			name: "myapex",
+			key: "myapex.key",
+			updatable: false,
+		}
+
+		apex_key {
+			name: "myapex.key",
+			public_key: "testkey.avbpubkey",
+			private_key: "testkey.pem",
+		}
+
+		prebuilt_apex {
+			name: "myapex",
+			prefer: true,
+			src: "myapex-arm64.apex",
+			%s
+		}
+	`
+
+	t.Run("enabled", func(t *testing.T) {
+		ctx := testApex(t, fmt.Sprintf(bp, `
+			payload_diff_check: {
+				enabled: true,
+				allowed_differences: ["\\.prof$", "^./etc/boot-image.bprof$"],
+			},
+		`))
+
+		prebuilt := ctx.ModuleForTests("prebuilt_myapex", "android_common_myapex")
+		prebuiltFiles := prebuilt.Output("payload_files.txt")
+		android.AssertPathRelativeToTopEquals(t, "listed prebuilt apex",
+			"out/soong/.intermediates/prebuilt_myapex/android_common_myapex/myapex.apex", prebuiltFiles.Input)
+
+		source := ctx.ModuleForTests("myapex", "android_common_myapex")
+		sourceFiles := source.Output("payload_files.txt")
+		android.AssertPathRelativeToTopEquals(t, "listed source apex",
+			"out/soong/.intermediates/myapex/android_common_myapex/myapex.apex", sourceFiles.Input)
+
+		diff := source.Output("payload_diff_prebuilt_myapex.timestamp")
+		android.AssertPathsRelativeToTopEquals(t, "diffed listings",
+			android.PathsRelativeToTop(android.Paths{sourceFiles.Output, prebuiltFiles.Output}), diff.Inputs)
+		android.AssertStringEquals(t, "filter",
+			`{ grep -v -E -e '\.prof$$' -e '^./etc/boot-image.bprof$$' || test $$? -eq 1; }`, diff.Args["filter"])
+		android.AssertStringEquals(t, "on_difference", "exit 1", diff.Args["on_difference"])
+	})
+
+	t.Run("warn only", func(t *testing.T) {
+		ctx := testApex(t, fmt.Sprintf(bp, `
+			payload_diff_check: {
+				enabled: true,
+				warn_only: true,
+			},
+		`))
+
+		diff := ctx.ModuleForTests("myapex", "android_common_myapex").Output("payload_diff_prebuilt_myapex.timestamp")
+		android.AssertStringEquals(t, "filter", "cat", diff.Args["filter"])
+		android.AssertStringEquals(t, "level", "warning", diff.Args["level"])
+		android.AssertStringEquals(t, "on_difference", "true", diff.Args["on_difference"])
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		ctx := testApex(t, fmt.Sprintf(bp, ""))
+
+		prebuilt := ctx.ModuleForTests("prebuilt_myapex", "android_common_myapex")
+		android.AssertBoolEquals(t, "prebuilt payload is not listed", true,
+			prebuilt.MaybeOutput("payload_files.txt").Rule == nil)
+		source := ctx.ModuleForTests("myapex", "android_common_myapex")
+		android.AssertBoolEquals(t, "payloads are not diffed", true,
+			source.MaybeOutput("payload_diff_prebuilt_myapex.timestamp").Rule == nil)
+	})
+}
@@ -49,11 +49,7 @@ func init() {
 	// projects, and hence cannot build 'aapt2'. Use the SDK prebuilt instead.
 	hostBinToolVariableWithPrebuilt := func(name, prebuiltDir, tool string) {
 		pctx.VariableFunc(name, func(ctx android.PackageVarContext) string {
-			if !ctx.Config().FrameworksBaseDirExists(ctx) {
-				return filepath.Join(prebuiltDir, runtime.GOOS, "bin", tool)
-			} else {
-				return ctx.Config().HostToolPath(ctx, tool).String()
-			}
+			return hostBinToolPathWithPrebuilt(ctx, prebuiltDir, tool).String()
 		})
 	}
 	hostBinToolVariableWithPrebuilt("aapt2", "prebuilts/sdk/tools", "aapt2")
@@ -262,6 +258,21 @@ var (
 	}, "tool_path", "unwanted")
 )
 
+// hostBinToolPathWithPrebuilt returns the path of a host tool, or of its prebuilt in prebuiltDir
+// when the tool can't be built.  It is the value of the variables created by
+// hostBinToolVariableWithPrebuilt.
+func hostBinToolPathWithPrebuilt(ctx android.PathGlobContext, prebuiltDir, tool string) android.Path {
+	if !ctx.Config().FrameworksBaseDirExists(ctx) {
+		return android.PathForSource(ctx, prebuiltDir, runtime.GOOS, "bin", tool)
+	}
+	return ctx.Config().HostToolPath(ctx, tool)
+}
+
+// aapt2Path returns the path of the aapt2 tool in the aapt2 variable.
+func aapt2Path(ctx android.PathGlobContext) android.Path {
+	return hostBinToolPathWithPrebuilt(ctx, "prebuilts/sdk/tools", "aapt2")
+}
+
 // buildManifest creates buile rules to modify the input apex_manifest.json to add information
 // gathered by the build system such as provided/required native libraries. Two output files having
 // different formats are generated. a.manifestJsonOut is JSON format for Q devices, and
@@ -1244,3 +1255,61 @@ func runApexElfCheckerUnwanted(ctx android.ModuleContext, apexFile android.Outpu
 	})
 	return timestamp
 }
+
+// checkPayloadMatchesPrebuilt compares the files in the payload of the apex with the files in the
+// payload of each prebuilt of it that enables payload_diff_check.  The dependency onto the
+// prebuilt already exists for the source/prebuilt selection, so the check is done here rather than
+// in the prebuilt, which cannot depend on the source apex.
+func (a *apexBundle) checkPayloadMatchesPrebuilt(ctx android.ModuleContext) {
+	if a.outputApexFile == nil {
+		return
+	}
+	var payloadFiles android.WritablePath
+	ctx.VisitDirectDepsWithTag(android.PrebuiltDepTag, func(prebuilt android.Module) {
+		info, ok := android.OtherModuleProvider(ctx, prebuilt, prebuiltApexPayloadInfoProvider)
+		if !ok {
+			return
+		}
+		if payloadFiles == nil {
+			payloadFiles = android.PathForModuleOut(ctx, "payload_files.txt")
+			ctx.Build(pctx, android.BuildParams{
+				Rule:   listApexPayloadRule,
+				Input:  a.outputApexFile,
+				Output: payloadFiles,
+			})
+		}
+
+		filter := "cat"
+		if len(info.AllowedDifferences) > 0 {
+			// grep exits with 1 when every file is allowed to differ.
+			var patterns []string
+			for _, pattern := range info.AllowedDifferences {
+				patterns = append(patterns, "-e "+proptools.NinjaAndShellEscape(pattern))
+			}
+			filter = "{ grep -v -E " + strings.Join(patterns, " ") + " || test $$? -eq 1; }"
+		}
+		level, onDifference := "error", "exit 1"
+		if info.WarnOnly {
+			level, onDifference = "warning", "true"
+		}
+
+		prebuiltName := ctx.OtherModuleName(prebuilt)
+		timestamp := android.PathForModuleOut(ctx, "payload_diff_"+prebuiltName+".timestamp")
+		ctx.Build(pctx, android.BuildParams{
+			Rule:   diffApexPayloadRule,
+			Inputs: android.Paths{payloadFiles, info.PayloadFiles},
+			Output: timestamp,
+			Args: map[string]string{
+				"filter":          filter,
+				"source_files":    payloadFiles.String(),
+				"prebuilt_files":  info.PayloadFiles.String(),
+				"source_module":   ctx.ModuleName(),
+				"prebuilt_module": prebuiltName,
+				"level":           level,
+				"on_difference":   onDifference,
+			},
+		})
+		ctx.Phony("apex-payload-diff-check", timestamp)
+		ctx.CheckbuildFile(timestamp)
+	})
+}
@@ -135,13 +135,13 @@ func apexKeyEntryFor(ctx android.ModuleContext, module android.Module) apexKeyEn
 		}
 	case *Prebuilt:
 		return apexKeyEntry{
-			name:      m.InstallFilename(),
+			name:      m.apexKeysName(),
 			presigned: true,
 			partition: m.PartitionTag(ctx.DeviceConfig()),
 		}
 	case *ApexSet:
 		return apexKeyEntry{
-			name:      m.InstallFilename(),
+			name:      m.apexKeysName(),
 			presigned: true,
 			partition: m.PartitionTag(ctx.DeviceConfig()),
 		}
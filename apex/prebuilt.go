@@ -23,6 +23,7 @@ import (
 
 	"android/soong/android"
 	"android/soong/dexpreopt"
+	prebuilt_etc "android/soong/etc"
 	"android/soong/java"
 	"android/soong/provenance"
 
@@ -42,6 +43,34 @@ var (
 			CommandDeps: []string{"${extract_apks}"},
 		},
 		"abis", "allow-prereleased", "sdk-version", "skip-sdk-check")
+
+	// Lists the files in the payload of an apex, one path per line, for diffApexPayloadRule.
+	listApexPayloadRule = pctx.StaticRule(
+		"listApexPayloadRule",
+		blueprint.RuleParams{
+			Command: `rm -f $out && ` +
+				`${deapexer} --debugfs_path ${debugfs_static} --fsckerofs_path ${fsck_erofs} list $in > $out.unsorted && ` +
+				`sort $out.unsorted > $out`,
+			CommandDeps: []string{"${deapexer}", "${debugfs_static}", "${fsck_erofs}"},
+			Description: "list payload of $in",
+		})
+
+	// Compares the payload listings of a source apex and its prebuilt after passing them through
+	// filter, and reports the differences as errors, or as warnings if on_difference is "true".
+	diffApexPayloadRule = pctx.StaticRule(
+		"diffApexPayloadRule",
+		blueprint.RuleParams{
+			Command: `rm -f $out && ` +
+				`${filter} < ${source_files} > $out.source && ` +
+				`${filter} < ${prebuilt_files} > $out.prebuilt && ` +
+				`if ! diff $out.source $out.prebuilt > $out.diff; then ` +
+				`echo "${level}: the payload of ${prebuilt_module} differs from the payload of ${source_module}` +
+				` (< only in ${source_module}, > only in ${prebuilt_module}):" >&2 && ` +
+				`cat $out.diff >&2 && ${on_difference}; fi && ` +
+				`touch $out`,
+			Description: "diff payload of ${source_module} and ${prebuilt_module}",
+		},
+		"filter", "source_files", "prebuilt_files", "source_module", "prebuilt_module", "level", "on_difference")
 )
 
 type prebuilt interface {
@@ -56,6 +85,7 @@ type prebuiltCommon struct {
 
 	// Properties common to both prebuilt_apex and apex_set.
 	prebuiltCommonProperties *PrebuiltCommonProperties
+	prebuiltInfoProperties   PrebuiltInfoProperties
 
 	installDir      android.InstallPath
 	installFilename string
@@ -95,13 +125,31 @@ type PrebuiltCommonProperties struct {
 
 	ForceDisable bool `blueprint:"mutated"`
 
+	// Why the prebuilt was force disabled by prebuiltApexPrecedenceMutator, if it was.
+	ForceDisableReason string `blueprint:"mutated"`
+
 	// whether the extracted apex file is installable.
 	Installable *bool
 
 	// optional name for the installed apex. If unspecified, name of the
-	// module is used as the file name
+	// module is used as the file name.  It can be set per product with the
+	// soong_config_variables of a soong_config_module_type.
 	Filename *string
 
+	// optional subdirectory of the apex directory of the partition to install the apex in, e.g.
+	// "staging" to install it as apex/staging/<filename> so that it is not activated. No compat
+	// symlinks are created for an apex installed in a subdirectory.
+	Install_subdir *string
+
+	// The min_sdk_version of the apex, e.g. "31". When set, it is used like the min_sdk_version of a
+	// source apex for the checks of the apex variants of the modules exported by the prebuilt, and it
+	// is checked against the min sdk version in the AndroidManifest.xml of the selected apex.
+	Min_sdk_version *string
+
+	// compat_symlink modules to install along with the apex, in addition to the compat symlinks
+	// that are built into Soong for some apexes.  Ignored when install_subdir is set.
+	Compat_symlinks []string
+
 	// names of modules to be overridden. Listed modules can only be other binaries
 	// (in Make or Soong).
 	// This does not completely prevent installation of the overridden binaries, but if both
@@ -121,12 +169,89 @@ type PrebuiltCommonProperties struct {
 	// List of systemserverclasspath fragments inside this prebuilt APEX bundle and for which this
 	// APEX bundle will create an APEX variant.
 	Exported_systemserverclasspath_fragments []string
+
+	// List of init.rc files inside this prebuilt APEX bundle, relative to its root, e.g.
+	// "etc/init.rc", that are extracted from it and installed outside of it like the files listed
+	// in init_rc, e.g. for use in early boot before apexd activates the APEX.
+	Export_init_rc []string
+
+	// List of vintf fragments inside this prebuilt APEX bundle, relative to its root, that are
+	// extracted from it and installed outside of it like the files listed in vintf_fragments.
+	Export_vintf_fragments []string
+
+	// Properties of the check that the payload of this prebuilt APEX bundle contains the same files
+	// as the payload of the source apex that it shadows.
+	Payload_diff_check struct {
+		// If true, and the source apex exists, the files in the payloads of the source apex and of
+		// this prebuilt are listed with deapexer and compared.  The check is run by the
+		// apex-payload-diff-check goal and by checkbuild.  Defaults to false.
+		Enabled *bool
+
+		// Extended regular expressions matching the paths of the files that are expected to differ
+		// between the payloads, e.g. "\\.prof$".
+		Allowed_differences []string
+
+		// If true, differences are reported as warnings instead of failing the build.
+		Warn_only *bool
+	}
+}
+
+// PrebuiltInfoProperties are the properties that specify the prebuilt_info file of the prebuilt
+// apex, either for all architectures or for each architecture.  They are separate from
+// PrebuiltCommonProperties as the Arch struct of prebuilt_apex is in ApexFileSrcProperties.
+type PrebuiltInfoProperties struct {
+	// path to the prebuilt_info file of the prebuilt apex, which lists the build that produced it.
+	// Its path is included in prebuilt_info.json.
+	//
+	// Like src of prebuilt_apex this cannot be marked as `android:"arch_variant"`, the prebuilt_info
+	// file of the architecture of the apex is selected from the Arch struct instead.
+	Prebuilt_info *string `android:"path"`
+	Arch          struct {
+		Arm struct {
+			Prebuilt_info *string `android:"path"`
+		}
+		Arm64 struct {
+			Prebuilt_info *string `android:"path"`
+		}
+		Riscv64 struct {
+			Prebuilt_info *string `android:"path"`
+		}
+		X86 struct {
+			Prebuilt_info *string `android:"path"`
+		}
+		X86_64 struct {
+			Prebuilt_info *string `android:"path"`
+		}
+	}
+}
+
+// prebuiltInfo returns the prebuilt_info file for the architecture, falling back to the
+// Prebuilt_info property, or "" if neither is specified.
+func (p *PrebuiltInfoProperties) prebuiltInfo(archType android.ArchType) string {
+	var prebuiltInfo string
+	switch archType {
+	case android.Arm:
+		prebuiltInfo = String(p.Arch.Arm.Prebuilt_info)
+	case android.Arm64:
+		prebuiltInfo = String(p.Arch.Arm64.Prebuilt_info)
+	case android.Riscv64:
+		prebuiltInfo = String(p.Arch.Riscv64.Prebuilt_info)
+	case android.X86:
+		prebuiltInfo = String(p.Arch.X86.Prebuilt_info)
+	case android.X86_64:
+		prebuiltInfo = String(p.Arch.X86_64.Prebuilt_info)
+	}
+	if prebuiltInfo == "" {
+		prebuiltInfo = String(p.Prebuilt_info)
+	}
+	return prebuiltInfo
 }
 
 // initPrebuiltCommon initializes the prebuiltCommon structure and performs initialization of the
 // module that is common to Prebuilt and ApexSet.
 func (p *prebuiltCommon) initPrebuiltCommon(module android.Module, properties *PrebuiltCommonProperties) {
 	p.prebuiltCommonProperties = properties
+	module.AddProperties(&p.prebuiltInfoProperties)
 	android.InitSingleSourcePrebuiltModule(module.(android.PrebuiltInterface), properties, "Selected_apex")
 	android.InitAndroidMultiTargetsArchModule(module, android.DeviceSupported, android.MultilibCommon)
 }
@@ -148,6 +273,11 @@ func (p *prebuiltCommon) isForceDisabled() bool {
 }
 
 func (p *prebuiltCommon) checkForceDisable(ctx android.ModuleContext) bool {
+	// Already force disabled in favor of a prebuilt of the other type for the same apex.
+	if p.prebuiltCommonProperties.ForceDisable {
+		return true
+	}
+
 	forceDisable := false
 
 	// Force disable the prebuilts when we are doing unbundled build. We do unbundled build
@@ -166,10 +296,69 @@ func (p *prebuiltCommon) checkForceDisable(ctx android.ModuleContext) bool {
 	return false
 }
 
+// writeApexKeysIfVisible writes the apexkeys.txt fragment for the prebuilt apex unless it has
+// been hidden from Make, either because the source apex (or another prebuilt) was selected or
+// because it was force disabled. Hidden prebuilts are not installed, so listing their keys in
+// the aggregated apexkeys.txt would describe apexes that are not part of the build.
+func (p *prebuiltCommon) writeApexKeysIfVisible(ctx android.ModuleContext) {
+	if ctx.Module().IsHideFromMake() {
+		return
+	}
+	p.apexKeysPath = writeApexKeys(ctx, ctx.Module())
+}
+
 func (p *prebuiltCommon) InstallFilename() string {
 	return proptools.StringDefault(p.prebuiltCommonProperties.Filename, p.BaseModuleName()+imageApexSuffix)
 }
 
+// providePrebuiltInfo sets the PrebuiltInfoProvider of the prebuilt apex.
+func (p *prebuiltCommon) providePrebuiltInfo(ctx android.ModuleContext) {
+	android.SetProvider(ctx, android.PrebuiltInfoProvider, p.newPrebuiltInfo(ctx))
+}
+
+// newPrebuiltInfo returns the PrebuiltInfo of the prebuilt apex.
+func (p *prebuiltCommon) newPrebuiltInfo(ctx android.ModuleContext) android.PrebuiltInfo {
+	info := android.PrebuiltInfo{
+		Name:                 p.BaseModuleName(),
+		Is_prebuilt:          true,
+		Force_disable_reason: p.prebuiltCommonProperties.ForceDisableReason,
+	}
+	var archType android.ArchType
+	if multiTargets := ctx.Module().MultiTargets(); len(multiTargets) == 1 {
+		archType = multiTargets[0].Arch.ArchType
+	}
+	if prebuiltInfo := p.prebuiltInfoProperties.prebuiltInfo(archType); prebuiltInfo != "" {
+		info.Prebuilt_info_file_path = android.PathForModuleSrc(ctx, prebuiltInfo).String()
+		info.Prebuilt_info_arch = archType.String()
+	}
+	return info
+}
+
+// installSubdir returns the validated install_subdir property, or "" if the apex is installed in
+// the apex directory itself.
+func (p *prebuiltCommon) installSubdir(ctx android.ModuleContext) string {
+	subdir := proptools.String(p.prebuiltCommonProperties.Install_subdir)
+	if subdir == "" {
+		return ""
+	}
+	cleaned, err := android.ValidateSafePath(subdir)
+	if err != nil {
+		ctx.PropertyErrorf("install_subdir", "%s", err)
+		return ""
+	}
+	if cleaned == "." {
+		return ""
+	}
+	return cleaned
+}
+
+// apexKeysName returns the name of the apex in apexkeys.txt, which is its install path relative to
+// the apex directory so that apexes with the same filename in different subdirectories don't
+// collide.  The install_subdir property is validated by installSubdir.
+func (p *prebuiltCommon) apexKeysName() string {
+	return filepath.Join(proptools.String(p.prebuiltCommonProperties.Install_subdir), p.installFilename)
+}
+
 func (p *prebuiltCommon) Name() string {
 	return p.prebuilt.Name(p.ModuleBase.Name())
 }
@@ -222,6 +411,54 @@ func (p *prebuiltCommon) dexpreoptSystemServerJars(ctx android.ModuleContext) {
 		}
 		p.Dexpreopter.DexpreoptPrebuiltApexSystemServerJars(ctx, sscpJar, di)
 	}
+
+	p.checkExportedSystemServerJars(ctx, apexName, dc)
+}
+
+// systemServerClasspathContents is implemented by systemserverclasspath fragments.
+type systemServerClasspathContents interface {
+	SystemServerClasspathContents() (contents, standaloneContents []string)
+}
+
+// checkExportedSystemServerJars reports the contents of the exported systemserverclasspath
+// fragments that are missing from the dexpreopt global config, as they are silently not
+// dexpreopted and fall back to JIT.  It is a warning unless
+// SOONG_STRICT_PREBUILT_APEX_SYSTEM_SERVER_JARS is true.
+func (p *prebuiltCommon) checkExportedSystemServerJars(ctx android.ModuleContext, apexName string, dc *dexpreopt.GlobalConfig) {
+	configured := make(map[string]bool)
+	for _, pair := range dc.AllApexSystemServerJars(ctx).CopyOfApexJarPairs() {
+		configured[pair] = true
+	}
+
+	strict := ctx.Config().IsEnvTrue("SOONG_STRICT_PREBUILT_APEX_SYSTEM_SERVER_JARS")
+	ctx.VisitDirectDepsWithTag(exportedSystemserverclasspathFragmentTag, func(dep android.Module) {
+		fragment, ok := dep.(systemServerClasspathContents)
+		if !ok {
+			return
+		}
+		contents, standaloneContents := fragment.SystemServerClasspathContents()
+		check := func(modules []string, variable string) {
+			var missing []string
+			for _, module := range modules {
+				if pair := apexName + ":" + module; !configured[pair] {
+					missing = append(missing, pair)
+				}
+			}
+			if len(missing) == 0 {
+				return
+			}
+			fragmentName := android.RemoveOptionalPrebuiltPrefix(ctx.OtherModuleName(dep))
+			if strict {
+				ctx.ModuleErrorf("apex %q exports systemserverclasspath fragment %q whose jars are not dexpreopted, add %q to %s",
+					apexName, fragmentName, missing, variable)
+			} else {
+				ctx.ModuleWarningf("apex %q exports systemserverclasspath fragment %q whose jars are not dexpreopted, add %q to %s",
+					apexName, fragmentName, missing, variable)
+			}
+		}
+		check(contents, "PRODUCT_APEX_SYSTEM_SERVER_JARS")
+		check(standaloneContents, "PRODUCT_APEX_STANDALONE_SYSTEM_SERVER_JARS")
+	})
 }
 
 func (p *prebuiltCommon) addRequiredModules(entries *android.AndroidMkEntries) {
@@ -250,7 +487,9 @@ func (p *prebuiltCommon) AndroidMkEntries() []android.AndroidMkEntries {
 					entries.AddStrings("LOCAL_SOONG_INSTALL_SYMLINKS", p.compatSymlinks.Strings()...)
 					entries.SetBoolIfTrue("LOCAL_UNINSTALLABLE_MODULE", !p.installable())
 					entries.AddStrings("LOCAL_OVERRIDES_MODULES", p.prebuiltCommonProperties.Overrides...)
-					entries.SetString("LOCAL_APEX_KEY_PATH", p.apexKeysPath.String())
+					if p.apexKeysPath != nil {
+						entries.SetString("LOCAL_APEX_KEY_PATH", p.apexKeysPath.String())
+					}
 					p.addRequiredModules(entries)
 				},
 			},
@@ -297,8 +536,8 @@ func (p *prebuiltCommon) createEntriesForApexFile(fi apexFile, apexName string)
 				entries.SetString("LOCAL_DEX_PREOPT", "false")
 			},
 		},
-		ExtraFooters: []android.AndroidMkExtraFootersFunc{
-			func(w io.Writer, name, prefix, moduleDir string) {
+		ExtraFootersWithContext: []android.AndroidMkExtraFootersWithContextFunc{
+			func(ctx android.AndroidMkExtraEntriesContext, w io.Writer, name, prefix, moduleDir string) {
 				// m <module_name> will build <module_name>.<apex_name> as well.
 				if fi.androidMkModuleName != moduleName {
 					fmt.Fprintf(w, ".PHONY: %s\n", fi.androidMkModuleName)
@@ -336,6 +575,41 @@ func (p *prebuiltCommon) hasExportedDeps() bool {
 		len(p.prebuiltCommonProperties.Exported_systemserverclasspath_fragments) > 0
 }
 
+// hasExportedFiles returns true if files are extracted from the prebuilt apex to be installed
+// outside of it.
+func (p *prebuiltCommon) hasExportedFiles() bool {
+	return len(p.prebuiltCommonProperties.Export_init_rc) > 0 ||
+		len(p.prebuiltCommonProperties.Export_vintf_fragments) > 0
+}
+
+// needsDeapexer returns true if files need to be extracted from the prebuilt apex.
+func (p *prebuiltCommon) needsDeapexer() bool {
+	return p.hasExportedDeps() || p.hasExportedFiles()
+}
+
+// installExportedFiles installs the init.rc files and vintf fragments extracted from the prebuilt
+// apex by the deapexer module.  It must be called before the prebuilt apex is installed so that it
+// depends on them.
+func (p *prebuiltCommon) installExportedFiles(ctx android.ModuleContext) {
+	if !p.hasExportedFiles() {
+		return
+	}
+	di, err := android.FindDeapexerProviderForModule(ctx)
+	if err != nil {
+		ctx.ModuleErrorf("%s", err.Error())
+		return
+	}
+	extracted := func(paths []string) android.Paths {
+		var ret android.Paths
+		for _, path := range paths {
+			ret = append(ret, di.PrebuiltExportPath(path))
+		}
+		return ret
+	}
+	p.InstallInitRc(ctx, extracted(p.prebuiltCommonProperties.Export_init_rc))
+	p.InstallVintfFragments(ctx, extracted(p.prebuiltCommonProperties.Export_vintf_fragments))
+}
+
 // prebuiltApexContentsDeps adds dependencies onto the prebuilt apex module's contents.
 func (p *prebuiltCommon) prebuiltApexContentsDeps(ctx android.BottomUpMutatorContext) {
 	module := ctx.Module()
@@ -455,6 +729,9 @@ func (p *prebuiltCommon) apexInfoMutator(mctx android.TopDownMutatorContext) {
 		ApexContents:      []*android.ApexContents{apexContents},
 		ForPrebuiltApex:   true,
 	}
+	if minSdkVersion := p.minSdkVersion(mctx); !minSdkVersion.IsNone() {
+		apexInfo.MinSdkVersion = minSdkVersion
+	}
 
 	// Mark the dependencies of this module as requiring a variant for this module.
 	for _, am := range dependencies {
@@ -462,6 +739,68 @@ func (p *prebuiltCommon) apexInfoMutator(mctx android.TopDownMutatorContext) {
 	}
 }
 
+// minSdkVersion returns the min_sdk_version of the prebuilt apex, or NoneApiLevel if it does not
+// set one.
+func (p *prebuiltCommon) minSdkVersion(ctx android.EarlyModuleContext) android.ApiLevel {
+	return android.MinSdkVersionFromValue(ctx, proptools.String(p.prebuiltCommonProperties.Min_sdk_version))
+}
+
+// checkMinSdkVersion creates a rule that checks the min sdk version in the AndroidManifest.xml of
+// the apex file against the min_sdk_version property, and returns its output for use as a
+// validation, or nil if the property is not set.  The check only runs again when the contents of
+// the apex file change, as the prebuilt apex file is often touched without being changed.
+func (p *prebuiltCommon) checkMinSdkVersion(ctx android.ModuleContext, apex android.Path) android.Path {
+	minSdkVersion := p.minSdkVersion(ctx)
+	if minSdkVersion.IsNone() {
+		return nil
+	}
+	return android.BuildContentHashedValidation(ctx, pctx, android.ContentHashedValidationParams{
+		Name:        "min_sdk_version_check",
+		Description: "check min_sdk_version of " + apex.Base(),
+		Inputs:      android.Paths{apex},
+		Tools:       android.Paths{aapt2Path(ctx)},
+		Command: `actual=$$(${aapt2} dump badging $in | sed -n "s/^sdkVersion:'\(.*\)'$$/\1/p") && ` +
+			`if [ "$$actual" != "` + minSdkVersion.String() + `" ]; then ` +
+			`echo "$in: min_sdk_version is ` + minSdkVersion.String() + `, but the min sdk version of the apex is $$actual" >&2; ` +
+			`exit 1; fi`,
+	})
+}
+
+// prebuiltApexPayloadInfo is provided by a prebuilt apex that enables payload_diff_check, for the
+// source apex, which depends on it through android.PrebuiltDepTag, to compare its payload with.
+type prebuiltApexPayloadInfo struct {
+	// The sorted list of the files in the payload of the prebuilt apex.
+	PayloadFiles android.Path
+
+	// The payload_diff_check.allowed_differences property.
+	AllowedDifferences []string
+
+	// Whether differences are reported as warnings instead of errors.
+	WarnOnly bool
+}
+
+var prebuiltApexPayloadInfoProvider = blueprint.NewProvider[prebuiltApexPayloadInfo]()
+
+// providePayloadFiles lists the files in the payload of the apex file and provides the listing for
+// the source apex if payload_diff_check is enabled and the source apex exists.
+func (p *prebuiltCommon) providePayloadFiles(ctx android.ModuleContext, apex android.Path) {
+	check := &p.prebuiltCommonProperties.Payload_diff_check
+	if !proptools.Bool(check.Enabled) || !p.prebuilt.SourceExists() {
+		return
+	}
+	payloadFiles := android.PathForModuleOut(ctx, "payload_files.txt")
+	ctx.Build(pctx, android.BuildParams{
+		Rule:   listApexPayloadRule,
+		Input:  apex,
+		Output: payloadFiles,
+	})
+	android.SetProvider(ctx, prebuiltApexPayloadInfoProvider, prebuiltApexPayloadInfo{
+		PayloadFiles:       payloadFiles,
+		AllowedDifferences: check.Allowed_differences,
+		WarnOnly:           proptools.Bool(check.Warn_only),
+	})
+}
+
 // prebuiltApexSelectorModule is a private module type that is only created by the prebuilt_apex
 // module. It selects the apex to use and makes it available for use by prebuilt_apex and the
 // deapexer.
@@ -503,7 +842,9 @@ type Prebuilt struct {
 	mergedAconfigFiles map[string]android.Paths
 }
 
-type ApexFileProperties struct {
+// ApexFileSrcProperties are the properties that specify the prebuilt .apex file to import, either
+// for all architectures or for each architecture.
+type ApexFileSrcProperties struct {
 	// the path to the prebuilt .apex file to import.
 	//
 	// This cannot be marked as `android:"arch_variant"` because the `prebuilt_apex` is only mutated
@@ -529,20 +870,11 @@ type ApexFileProperties struct {
 	}
 }
 
-// prebuiltApexSelector selects the correct prebuilt APEX file for the build target.
-//
-// The ctx parameter can be for any module not just the prebuilt module so care must be taken not
-// to use methods on it that are specific to the current module.
-//
-// See the ApexFileProperties.Src property.
-func (p *ApexFileProperties) prebuiltApexSelector(ctx android.BaseModuleContext, prebuilt android.Module) []string {
-	multiTargets := prebuilt.MultiTargets()
-	if len(multiTargets) != 1 {
-		ctx.OtherModuleErrorf(prebuilt, "compile_multilib shouldn't be \"both\" for prebuilt_apex")
-		return nil
-	}
+// src returns the .apex file for the architecture, falling back to the Src property, or "" if
+// neither is specified.
+func (p *ApexFileSrcProperties) src(archType android.ArchType) string {
 	var src string
-	switch multiTargets[0].Arch.ArchType {
+	switch archType {
 	case android.Arm:
 		src = String(p.Arch.Arm.Src)
 	case android.Arm64:
@@ -561,6 +893,61 @@ func (p *ApexFileProperties) prebuiltApexSelector(ctx android.BaseModuleContext,
 	if src == "" {
 		src = String(p.Src)
 	}
+	return src
+}
+
+type ApexFileProperties struct {
+	ApexFileSrcProperties
+
+	// the prebuilt .apex files to import instead of the ones above when the device is built with
+	// SANITIZE_TARGET set to the sanitizer.  If the sanitizer doesn't specify a file for the
+	// target architecture then the file of None is used, if specified, and then the ones above.
+	Sanitized struct {
+		None      ApexFileSrcProperties
+		Address   ApexFileSrcProperties
+		Hwaddress ApexFileSrcProperties
+	}
+}
+
+// sanitizedSrc returns the .apex file to use for the sanitizer and the architecture, or "" if none
+// is specified.
+func (p *ApexFileProperties) sanitizedSrc(sanitizer string, archType android.ArchType) string {
+	switch sanitizer {
+	case "address":
+		return p.Sanitized.Address.src(archType)
+	case "hwaddress":
+		return p.Sanitized.Hwaddress.src(archType)
+	}
+	return ""
+}
+
+// prebuiltApexSelector selects the correct prebuilt APEX file for the build target.
+//
+// The ctx parameter can be for any module not just the prebuilt module so care must be taken not
+// to use methods on it that are specific to the current module.
+//
+// See the ApexFileProperties.Src and ApexFileProperties.Sanitized properties.
+func (p *ApexFileProperties) prebuiltApexSelector(ctx android.BaseModuleContext, prebuilt android.Module) []string {
+	multiTargets := prebuilt.MultiTargets()
+	if len(multiTargets) != 1 {
+		ctx.OtherModuleErrorf(prebuilt, "compile_multilib shouldn't be \"both\" for prebuilt_apex")
+		return nil
+	}
+	archType := multiTargets[0].Arch.ArchType
+
+	sanitizers := ctx.Config().SanitizeDevice()
+	var src string
+	if android.InList("address", sanitizers) {
+		src = p.sanitizedSrc("address", archType)
+	} else if android.InList("hwaddress", sanitizers) {
+		src = p.sanitizedSrc("hwaddress", archType)
+	}
+	if src == "" {
+		src = p.Sanitized.None.src(archType)
+	}
+	if src == "" {
+		src = p.ApexFileSrcProperties.src(archType)
+	}
 
 	if src == "" {
 		if ctx.Config().AllowMissingDependencies() {
@@ -581,8 +968,12 @@ type PrebuiltProperties struct {
 	PrebuiltCommonProperties
 }
 
-func (a *Prebuilt) hasSanitizedSource(sanitizer string) bool {
-	return false
+func (p *Prebuilt) hasSanitizedSource(sanitizer string) bool {
+	multiTargets := p.MultiTargets()
+	if len(multiTargets) != 1 {
+		return false
+	}
+	return p.properties.sanitizedSrc(sanitizer, multiTargets[0].Arch.ArchType) != ""
 }
 
 func (p *Prebuilt) OutputFiles(tag string) (android.Paths, error) {
@@ -620,10 +1011,11 @@ func createApexSelectorModule(ctx android.TopDownMutatorContext, name string, ap
 //
 // A deapexer module is only needed when the prebuilt apex specifies one or more modules in either
 // the `exported_java_libs` or `exported_bootclasspath_fragments` properties as that indicates that
-// the listed modules need access to files from within the prebuilt .apex file.
+// the listed modules need access to files from within the prebuilt .apex file, or one or more files
+// in the `export_init_rc` or `export_vintf_fragments` properties.
 func (p *prebuiltCommon) createDeapexerModuleIfNeeded(ctx android.TopDownMutatorContext, deapexerName string, apexFileSource string) {
 	// Only create the deapexer module if it is needed.
-	if !p.hasExportedDeps() {
+	if !p.needsDeapexer() {
 		return
 	}
 
@@ -631,6 +1023,8 @@ func (p *prebuiltCommon) createDeapexerModuleIfNeeded(ctx android.TopDownMutator
 	commonModules := []string{}
 	dexpreoptProfileGuidedModules := []string{}
 	exportedFiles := []string{}
+	exportedFiles = append(exportedFiles, p.prebuiltCommonProperties.Export_init_rc...)
+	exportedFiles = append(exportedFiles, p.prebuiltCommonProperties.Export_vintf_fragments...)
 	ctx.WalkDeps(func(child, parent android.Module) bool {
 		tag := ctx.OtherModuleDependencyTag(child)
 
@@ -779,13 +1173,124 @@ func (p *Prebuilt) ComponentDepsMutator(ctx android.BottomUpMutatorContext) {
 }
 
 func (p *prebuiltCommon) DepsMutator(ctx android.BottomUpMutatorContext) {
-	if p.hasExportedDeps() {
+	android.CheckNotSelfReferenced(ctx, "overrides", p.prebuiltCommonProperties.Overrides)
+	if p.needsDeapexer() {
 		// Create a dependency from the prebuilt apex (prebuilt_apex/apex_set) to the internal deapexer module
 		// The deapexer will return a provider that will be bubbled up to the rdeps of apexes (e.g. dex_bootjars)
 		ctx.AddDependency(ctx.Module(), android.DeapexerTag, deapexerModuleName(p.Name()))
 	}
 }
 
+// compatSymlinkOverriddenDependencyTag is the tag for dependencies from a prebuilt apex onto the
+// apexes that it overrides, used to find the compat symlinks that they still install.
+type compatSymlinkOverriddenDependencyTag struct {
+	blueprint.BaseDependencyTag
+}
+
+func (compatSymlinkOverriddenDependencyTag) ExcludeFromVisibilityEnforcement() {}
+
+func (compatSymlinkOverriddenDependencyTag) ExcludeFromApexContents() {}
+
+var _ android.ExcludeFromVisibilityEnforcementTag = compatSymlinkOverriddenDependencyTag{}
+var _ android.ExcludeFromApexContentsTag = compatSymlinkOverriddenDependencyTag{}
+
+var compatSymlinkOverriddenTag = compatSymlinkOverriddenDependencyTag{}
+
+// compatSymlinkDependencyTag is the tag for dependencies from an apex or a prebuilt apex onto the
+// compat_symlink modules listed in its compat_symlinks property, which are installed along with it.
+type compatSymlinkDependencyTag struct {
+	blueprint.BaseDependencyTag
+	android.InstallAlwaysNeededDependencyTag
+}
+
+func (compatSymlinkDependencyTag) ExcludeFromApexContents() {}
+
+var _ android.ExcludeFromApexContentsTag = compatSymlinkDependencyTag{}
+
+var compatSymlinkTag = compatSymlinkDependencyTag{}
+
+// apexCompatSymlinkDepsMutator adds dependencies from prebuilt apexes onto the apexes listed in
+// their overrides property, and from apexes and prebuilt apexes onto the compat_symlink modules
+// listed in their compat_symlinks property. It runs after the apex mutator, like
+// apexDCLADepsMutator, so that it does not affect the apex variations.
+func apexCompatSymlinkDepsMutator(mctx android.BottomUpMutatorContext) {
+	switch m := mctx.Module().(type) {
+	case *apexBundle:
+		addCompatSymlinkDeps(mctx, m.properties.Compat_symlinks)
+	case *Prebuilt:
+		m.addOverriddenApexDeps(mctx)
+		if proptools.String(m.prebuiltCommonProperties.Install_subdir) == "" {
+			addCompatSymlinkDeps(mctx, m.prebuiltCommonProperties.Compat_symlinks)
+		}
+	case *ApexSet:
+		m.addOverriddenApexDeps(mctx)
+		if proptools.String(m.prebuiltCommonProperties.Install_subdir) == "" {
+			addCompatSymlinkDeps(mctx, m.prebuiltCommonProperties.Compat_symlinks)
+		}
+	}
+}
+
+func addCompatSymlinkDeps(mctx android.BottomUpMutatorContext, names []string) {
+	mctx.AddFarVariationDependencies(mctx.Config().AndroidCommonTarget.Variations(), compatSymlinkTag, names...)
+}
+
+// compatSymlinkModules returns the names of the compat_symlink modules listed in the
+// compat_symlinks property, which are required by the apex in Make so that they are installed along
+// with it.
+func compatSymlinkModules(ctx android.ModuleContext) []string {
+	var names []string
+	ctx.VisitDirectDepsWithTag(compatSymlinkTag, func(dep android.Module) {
+		if _, ok := android.OtherModuleProvider(ctx, dep, prebuilt_etc.CompatSymlinkInfoProvider); !ok {
+			ctx.PropertyErrorf("compat_symlinks", "%q is not a compat_symlink module", ctx.OtherModuleName(dep))
+			return
+		}
+		names = append(names, ctx.OtherModuleName(dep))
+	})
+	return names
+}
+
+func (p *prebuiltCommon) addOverriddenApexDeps(mctx android.BottomUpMutatorContext) {
+	commonVariation := mctx.Config().AndroidCommonTarget.Variations()
+	for _, overridden := range p.prebuiltCommonProperties.Overrides {
+		// The overridden module may be defined in Make, or not have a common variant if it is not an
+		// apex, neither of which can install compat symlinks.
+		if overridden == p.BaseModuleName() || !mctx.OtherModuleFarDependencyVariantExists(commonVariation, overridden) {
+			continue
+		}
+		mctx.AddFarVariationDependencies(commonVariation, compatSymlinkOverriddenTag, overridden)
+	}
+}
+
+// makeOverriddenCompatSymlinks installs the compat symlinks of the apexes listed in the overrides
+// property. A symlink is skipped, with a warning, when an enabled overridden apex still installs a
+// file to the same path, as that means that the overridden apex has not actually been removed from
+// the product, and installing both would conflict.
+func (p *prebuiltCommon) makeOverriddenCompatSymlinks(ctx android.ModuleContext) android.InstallPaths {
+	stillInstalled := make(map[string]string)
+	ctx.VisitDirectDepsWithTag(compatSymlinkOverriddenTag, func(dep android.Module) {
+		if !dep.Enabled() {
+			return
+		}
+		for _, installed := range dep.FilesToInstall() {
+			stillInstalled[installed.String()] = ctx.OtherModuleName(dep)
+		}
+	})
+
+	var symlinks android.InstallPaths
+	for _, overridden := range p.prebuiltCommonProperties.Overrides {
+		for _, symlink := range compatSymlinksFor(overridden, ctx) {
+			installPath := symlink.installPath(ctx)
+			if installer, ok := stillInstalled[installPath.String()]; ok {
+				ctx.PropertyWarningf("overrides", "skipping compat symlink %s for overridden apex %q as %q still installs it",
+					installPath, overridden, installer)
+				continue
+			}
+			symlinks = append(symlinks, symlink.install(ctx))
+		}
+	}
+	return symlinks
+}
+
 var _ ApexInfoMutator = (*Prebuilt)(nil)
 
 func (p *Prebuilt) ApexInfoMutator(mctx android.TopDownMutatorContext) {
@@ -820,19 +1325,23 @@ func (p *prebuiltCommon) provideApexExportsInfo(ctx android.ModuleContext) {
 }
 
 func (p *Prebuilt) GenerateAndroidBuildActions(ctx android.ModuleContext) {
-	p.apexKeysPath = writeApexKeys(ctx, p)
-	// TODO(jungjw): Check the key validity.
-	p.inputApex = android.OptionalPathForModuleSrc(ctx, p.prebuiltCommonProperties.Selected_apex).Path()
-	p.installDir = android.PathForModuleInstall(ctx, "apex")
+	// The install filename is used by apexkeys.txt, the output apex and LOCAL_MODULE_STEM, so it is
+	// computed once before any of them.
 	p.installFilename = p.InstallFilename()
 	if !strings.HasSuffix(p.installFilename, imageApexSuffix) {
 		ctx.ModuleErrorf("filename should end in %s for prebuilt_apex", imageApexSuffix)
 	}
+	p.providePrebuiltInfo(ctx)
+	// TODO(jungjw): Check the key validity.
+	p.inputApex = android.OptionalPathForModuleSrc(ctx, p.prebuiltCommonProperties.Selected_apex).Path()
+	installSubdir := p.installSubdir(ctx)
+	p.installDir = android.PathForModuleInstall(ctx, "apex", installSubdir)
 	p.outputApex = android.PathForModuleOut(ctx, p.installFilename)
 	ctx.Build(pctx, android.BuildParams{
-		Rule:   android.Cp,
-		Input:  p.inputApex,
-		Output: p.outputApex,
+		Rule:       android.Cp,
+		Input:      p.inputApex,
+		Output:     p.outputApex,
+		Validation: p.checkMinSdkVersion(ctx, p.inputApex),
 	})
 
 	if p.prebuiltCommon.checkForceDisable(ctx) {
@@ -840,6 +1349,10 @@ func (p *Prebuilt) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 		return
 	}
 
+	p.providePayloadFiles(ctx, p.outputApex)
+
+	p.writeApexKeysIfVisible(ctx)
+
 	// dexpreopt any system server jars if present
 	p.dexpreoptSystemServerJars(ctx)
 
@@ -849,11 +1362,16 @@ func (p *Prebuilt) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 	// Save the files that need to be made available to Make.
 	p.initApexFilesForAndroidMk(ctx)
 
-	// in case that prebuilt_apex replaces source apex (using prefer: prop)
-	p.compatSymlinks = makeCompatSymlinks(p.BaseModuleName(), ctx)
-	// or that prebuilt_apex overrides other apexes (using overrides: prop)
-	for _, overridden := range p.prebuiltCommonProperties.Overrides {
-		p.compatSymlinks = append(p.compatSymlinks, makeCompatSymlinks(overridden, ctx)...)
+	// Install the init.rc files and vintf fragments extracted from the apex.
+	p.installExportedFiles(ctx)
+
+	// Staged apexes are not activated, so they don't need compat symlinks.
+	if installSubdir == "" {
+		// in case that prebuilt_apex replaces source apex (using prefer: prop)
+		p.compatSymlinks = makeCompatSymlinks(p.BaseModuleName(), ctx)
+		// or that prebuilt_apex overrides other apexes (using overrides: prop)
+		p.compatSymlinks = append(p.compatSymlinks, p.makeOverriddenCompatSymlinks(ctx)...)
+		p.requiredModuleNames = append(p.requiredModuleNames, compatSymlinkModules(ctx)...)
 	}
 
 	if p.installable() {
@@ -876,6 +1394,9 @@ type prebuiltApexExtractorModule struct {
 	properties ApexExtractorProperties
 
 	extractedApex android.WritablePath
+
+	// Whether prerelease apexes may be extracted from the apex set.
+	allowPrerelease bool
 }
 
 func privateApexExtractorModuleFactory() android.Module {
@@ -891,12 +1412,30 @@ func (p *prebuiltApexExtractorModule) Srcs() android.Paths {
 	return android.Paths{p.extractedApex}
 }
 
+// allowPrerelease returns whether prerelease apexes may be extracted from the apex set.  This is
+// the prerelease property, which defaults to SOONG_ALLOW_PRERELEASE_APEXES, unless the product
+// restricts prerelease apexes to the apex_set modules listed in PrereleaseApexes.  ignored is true
+// if an explicit prerelease: true was overridden by the product.
+func (e *ApexExtractorProperties) allowPrerelease(ctx android.BaseModuleContext, apexSetName string) (allow, ignored bool) {
+	defaultAllowPrerelease := ctx.Config().IsEnvTrue("SOONG_ALLOW_PRERELEASE_APEXES")
+	allow = proptools.BoolDefault(e.Prerelease, defaultAllowPrerelease)
+	if !allow || !ctx.Config().EnforcePrereleaseApexes() {
+		return allow, false
+	}
+
+	if android.InList(apexSetName, ctx.Config().PrereleaseApexes()) {
+		return true, false
+	}
+	return false, proptools.Bool(e.Prerelease)
+}
+
 func (p *prebuiltApexExtractorModule) GenerateAndroidBuildActions(ctx android.ModuleContext) {
-	srcsSupplier := func(ctx android.BaseModuleContext, prebuilt android.Module) []string {
-		return p.properties.prebuiltSrcs(ctx)
+	apexSetName := android.RemoveOptionalPrebuiltPrefix(strings.TrimSuffix(ctx.ModuleName(), apexExtractorModuleName("")))
+	p.allowPrerelease, _ = p.properties.allowPrerelease(ctx, apexSetName)
+	apexSet := p.properties.selectSet(ctx)
+	if apexSet == nil {
+		return
 	}
-	defaultAllowPrerelease := ctx.Config().IsEnvTrue("SOONG_ALLOW_PRERELEASE_APEXES")
-	apexSet := android.SingleSourcePathFromSupplier(ctx, srcsSupplier, "set")
 	p.extractedApex = android.PathForModuleOut(ctx, "extracted", apexSet.Base())
 	// Filter out NativeBridge archs (b/260115309)
 	abis := java.SupportedAbis(ctx, true)
@@ -908,7 +1447,7 @@ func (p *prebuiltApexExtractorModule) GenerateAndroidBuildActions(ctx android.Mo
 			Output:      p.extractedApex,
 			Args: map[string]string{
 				"abis":              strings.Join(abis, ","),
-				"allow-prereleased": strconv.FormatBool(proptools.BoolDefault(p.properties.Prerelease, defaultAllowPrerelease)),
+				"allow-prereleased": strconv.FormatBool(p.allowPrerelease),
 				"sdk-version":       ctx.Config().PlatformSdkVersion().String(),
 				"skip-sdk-check":    strconv.FormatBool(ctx.Config().IsEnvTrue("SOONG_SKIP_APPSET_SDK_CHECK")),
 			},
@@ -941,25 +1480,66 @@ type ApexExtractorProperties struct {
 	Prerelease *bool
 }
 
-func (e *ApexExtractorProperties) prebuiltSrcs(ctx android.BaseModuleContext) []string {
-	var srcs []string
+// apexSetSrc is a .apks file that applies to the product, along with the property that set it.
+type apexSetSrc struct {
+	property string
+	path     string
+}
+
+func (s apexSetSrc) String() string {
+	return fmt.Sprintf("%s: %q", s.property, s.path)
+}
+
+// prebuiltSrcs returns the .apks files that apply to the product.  The set property always applies,
+// while at most one of the sanitized.*.set properties applies, depending on the sanitizers enabled
+// for the device.
+func (e *ApexExtractorProperties) prebuiltSrcs(ctx android.BaseModuleContext) []apexSetSrc {
+	var srcs []apexSetSrc
 	if e.Set != nil {
-		srcs = append(srcs, *e.Set)
+		srcs = append(srcs, apexSetSrc{"set", *e.Set})
 	}
 
 	sanitizers := ctx.Config().SanitizeDevice()
 
 	if android.InList("address", sanitizers) && e.Sanitized.Address.Set != nil {
-		srcs = append(srcs, *e.Sanitized.Address.Set)
+		srcs = append(srcs, apexSetSrc{"sanitized.address.set", *e.Sanitized.Address.Set})
 	} else if android.InList("hwaddress", sanitizers) && e.Sanitized.Hwaddress.Set != nil {
-		srcs = append(srcs, *e.Sanitized.Hwaddress.Set)
+		srcs = append(srcs, apexSetSrc{"sanitized.hwaddress.set", *e.Sanitized.Hwaddress.Set})
 	} else if e.Sanitized.None.Set != nil {
-		srcs = append(srcs, *e.Sanitized.None.Set)
+		srcs = append(srcs, apexSetSrc{"sanitized.none.set", *e.Sanitized.None.Set})
 	}
 
 	return srcs
 }
 
+// selectSet returns the .apks file to extract the apex from, reporting an error and returning nil if
+// not exactly one file applies to the product.
+//
+// With AllowMissingDependencies a missing .apks file is reported as a missing dependency, so that
+// the build only fails if the apex is used.
+func (e *ApexExtractorProperties) selectSet(ctx android.ModuleContext) android.Path {
+	srcs := e.prebuiltSrcs(ctx)
+	if len(srcs) == 0 {
+		ctx.PropertyErrorf("set", "missing prebuilt source file, one of set or sanitized.none.set must be set")
+		return nil
+	}
+	if len(srcs) > 1 {
+		var sources []string
+		for _, src := range srcs {
+			sources = append(sources, src.String())
+		}
+		ctx.PropertyErrorf("set", "multiple prebuilt source files apply with SANITIZE_TARGET=%q: %s, set only one of them",
+			strings.Join(ctx.Config().SanitizeDevice(), " "), strings.Join(sources, ", "))
+		return nil
+	}
+
+	src := srcs[0].path
+	if android.SrcIsModule(src) == "" && ctx.Config().AllowMissingDependencies() {
+		return android.PathForSource(ctx, ctx.ModuleDir(), src)
+	}
+	return android.PathForModuleSrc(ctx, src)
+}
+
 type ApexSetProperties struct {
 	ApexExtractorProperties
 
@@ -1043,18 +1623,21 @@ func (a *ApexSet) ApexInfoMutator(mctx android.TopDownMutatorContext) {
 }
 
 func (a *ApexSet) GenerateAndroidBuildActions(ctx android.ModuleContext) {
-	a.apexKeysPath = writeApexKeys(ctx, a)
 	a.installFilename = a.InstallFilename()
 	if !strings.HasSuffix(a.installFilename, imageApexSuffix) && !strings.HasSuffix(a.installFilename, imageCapexSuffix) {
 		ctx.ModuleErrorf("filename should end in %s or %s for apex_set", imageApexSuffix, imageCapexSuffix)
 	}
+	info := a.newPrebuiltInfo(ctx)
+	info.Ignored_prerelease = a.checkPrerelease(ctx)
+	android.SetProvider(ctx, android.PrebuiltInfoProvider, info)
 
 	inputApex := android.OptionalPathForModuleSrc(ctx, a.prebuiltCommonProperties.Selected_apex).Path()
 	a.outputApex = android.PathForModuleOut(ctx, a.installFilename)
 	ctx.Build(pctx, android.BuildParams{
-		Rule:   android.Cp,
-		Input:  inputApex,
-		Output: a.outputApex,
+		Rule:       android.Cp,
+		Input:      inputApex,
+		Output:     a.outputApex,
+		Validation: a.checkMinSdkVersion(ctx, inputApex),
 	})
 
 	if a.prebuiltCommon.checkForceDisable(ctx) {
@@ -1062,6 +1645,10 @@ func (a *ApexSet) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 		return
 	}
 
+	a.providePayloadFiles(ctx, a.outputApex)
+
+	a.writeApexKeysIfVisible(ctx)
+
 	// dexpreopt any system server jars if present
 	a.dexpreoptSystemServerJars(ctx)
 
@@ -1071,17 +1658,41 @@ func (a *ApexSet) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 	// Save the files that need to be made available to Make.
 	a.initApexFilesForAndroidMk(ctx)
 
-	a.installDir = android.PathForModuleInstall(ctx, "apex")
+	// Install the init.rc files and vintf fragments extracted from the apex.
+	a.installExportedFiles(ctx)
+
+	installSubdir := a.installSubdir(ctx)
+	a.installDir = android.PathForModuleInstall(ctx, "apex", installSubdir)
 	if a.installable() {
 		a.installedFile = ctx.InstallFile(a.installDir, a.installFilename, a.outputApex)
 	}
 
-	// in case that apex_set replaces source apex (using prefer: prop)
-	a.compatSymlinks = makeCompatSymlinks(a.BaseModuleName(), ctx)
-	// or that apex_set overrides other apexes (using overrides: prop)
-	for _, overridden := range a.prebuiltCommonProperties.Overrides {
-		a.compatSymlinks = append(a.compatSymlinks, makeCompatSymlinks(overridden, ctx)...)
+	// Staged apexes are not activated, so they don't need compat symlinks.
+	if installSubdir == "" {
+		// in case that apex_set replaces source apex (using prefer: prop)
+		a.compatSymlinks = makeCompatSymlinks(a.BaseModuleName(), ctx)
+		// or that apex_set overrides other apexes (using overrides: prop)
+		a.compatSymlinks = append(a.compatSymlinks, a.makeOverriddenCompatSymlinks(ctx)...)
+		a.requiredModuleNames = append(a.requiredModuleNames, compatSymlinkModules(ctx)...)
+	}
+}
+
+// checkPrerelease reports an explicit prerelease: true that the product overrides because the
+// apex_set is not in the PrereleaseApexes product variable, and returns whether it was ignored.
+func (a *ApexSet) checkPrerelease(ctx android.ModuleContext) bool {
+	// The extractor module checks the name of the apex_set module, not its source_apex_name.
+	name := android.RemoveOptionalPrebuiltPrefix(a.Name())
+	_, ignored := a.properties.allowPrerelease(ctx, name)
+	if ignored {
+		if ctx.Config().PrereleaseApexesReportOnly() {
+			ctx.PropertyWarningf("prerelease", "ignoring prerelease: true as %q is not in the PrereleaseApexes product variable",
+				name)
+		} else {
+			ctx.PropertyErrorf("prerelease", "%q may not use prerelease: true as it is not in the PrereleaseApexes product variable",
+				name)
+		}
 	}
+	return ignored
 }
 
 type systemExtContext struct {
@@ -0,0 +1,140 @@
+// Copyright (C) 2024 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apex
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"android/soong/android"
+)
+
+// A tree can carry both a prebuilt_apex and an apex_set for the same apex, e.g. a .apex file for
+// local development and a .apks set for release.  When both are used, i.e. neither was replaced by
+// the source apex, they would install the same apex and list the same keys, so the
+// Prebuilt_apex_preference product variable has to list the module types in order of precedence,
+// e.g. ["apex_set", "prebuilt_apex"].  The prebuilts of the types that are not preferred are force
+// disabled like the prebuilts of an unbundled build, and otherwise the conflict is an error.
+// The selection between the source apex and its prebuilts is not affected as only the prebuilts
+// that were selected take part.
+
+var prebuiltApexCandidatesKey = android.NewOnceKey("prebuilt_apex_candidates")
+
+// prebuiltApexCandidate is a prebuilt_apex or apex_set module that was selected over its source
+// apex.
+type prebuiltApexCandidate struct {
+	name       string
+	moduleType string
+}
+
+// prebuiltApexCandidates collects the candidates of every apex in the prebuilt_apex_candidates
+// mutator so that the prebuilt_apex_precedence mutator, which runs after it has visited every
+// module, can see all of them.  The candidates of an apex are not related by dependencies as they
+// need not share a source apex.
+type prebuiltApexCandidates struct {
+	lock sync.Mutex
+	// The module types of the candidates by their name, by the name of their apex.  The candidates
+	// are keyed by name so that running the mutator again doesn't add them twice.
+	candidates map[string]map[string]string
+}
+
+func getPrebuiltApexCandidates(config android.Config) *prebuiltApexCandidates {
+	return config.Once(prebuiltApexCandidatesKey, func() interface{} {
+		return &prebuiltApexCandidates{candidates: make(map[string]map[string]string)}
+	}).(*prebuiltApexCandidates)
+}
+
+func (c *prebuiltApexCandidates) add(apexName string, candidate prebuiltApexCandidate) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.candidates[apexName] == nil {
+		c.candidates[apexName] = make(map[string]string)
+	}
+	c.candidates[apexName][candidate.name] = candidate.moduleType
+}
+
+// get returns the candidates of the apex sorted by name.
+func (c *prebuiltApexCandidates) get(apexName string) []prebuiltApexCandidate {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	var ret []prebuiltApexCandidate
+	for _, name := range android.SortedKeys(c.candidates[apexName]) {
+		ret = append(ret, prebuiltApexCandidate{name: name, moduleType: c.candidates[apexName][name]})
+	}
+	return ret
+}
+
+// prebuiltApexCommon returns the prebuiltCommon of prebuilt_apex and apex_set modules.
+func prebuiltApexCommon(module android.Module) *prebuiltCommon {
+	switch m := module.(type) {
+	case *Prebuilt:
+		return &m.prebuiltCommon
+	case *ApexSet:
+		return &m.prebuiltCommon
+	}
+	return nil
+}
+
+// prebuiltApexCandidatesMutator records the prebuilt_apex and apex_set modules that are used.
+func prebuiltApexCandidatesMutator(ctx android.BottomUpMutatorContext) {
+	p := prebuiltApexCommon(ctx.Module())
+	if p == nil || ctx.Module().IsHideFromMake() {
+		return
+	}
+	getPrebuiltApexCandidates(ctx.Config()).add(p.ApexVariationName(), prebuiltApexCandidate{
+		name:       ctx.ModuleName(),
+		moduleType: ctx.ModuleType(),
+	})
+}
+
+// prebuiltApexPrecedenceMutator force disables the prebuilt_apex or apex_set module if any module
+// of another type is used for the same apex and Prebuilt_apex_preference prefers it.  The reason
+// is recorded in prebuilt_info.json.
+func prebuiltApexPrecedenceMutator(ctx android.BottomUpMutatorContext) {
+	p := prebuiltApexCommon(ctx.Module())
+	if p == nil || ctx.Module().IsHideFromMake() {
+		return
+	}
+
+	preference := ctx.Config().PrebuiltApexPreference()
+	rank := android.IndexList(ctx.ModuleType(), preference)
+	var preferred []string
+	for _, other := range getPrebuiltApexCandidates(ctx.Config()).get(p.ApexVariationName()) {
+		if other.moduleType == ctx.ModuleType() {
+			continue
+		}
+		otherRank := android.IndexList(other.moduleType, preference)
+		if rank == -1 || otherRank == -1 {
+			// Report each conflict once, from the module that sorts first.
+			if ctx.ModuleName() < other.name {
+				ctx.ModuleErrorf("%s %q and %s %q both provide the apex %q, set Prebuilt_apex_preference "+
+					"to choose between them, e.g. [%q, %q]", ctx.ModuleType(), ctx.ModuleName(),
+					other.moduleType, other.name, p.ApexVariationName(), other.moduleType, ctx.ModuleType())
+			}
+			continue
+		}
+		if otherRank < rank {
+			preferred = append(preferred, fmt.Sprintf("%s %q", other.moduleType, other.name))
+		}
+	}
+	if len(preferred) > 0 {
+		p.prebuiltCommonProperties.ForceDisable = true
+		p.prebuiltCommonProperties.ForceDisableReason = fmt.Sprintf(
+			"Prebuilt_apex_preference prefers %s for the apex %q", strings.Join(preferred, ", "),
+			p.ApexVariationName())
+		ctx.Module().HideFromMake()
+	}
+}
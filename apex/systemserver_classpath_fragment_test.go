@@ -462,3 +462,85 @@ func assertProfileGuidedPrebuilt(t *testing.T, ctx *android.TestContext, apexNam
 		t.Fatalf("Expected profile-guided to be %v, got %v", expected, actual)
 	}
 }
+
+func TestPrebuiltSystemserverclasspathFragmentMissingFromGlobalConfig(t *testing.T) {
+	bp := `
+		prebuilt_apex {
+			name: "myapex",
+			src: "myapex-arm64.apex",
+			exported_systemserverclasspath_fragments: ["mysystemserverclasspathfragment"],
+		}
+
+		java_import {
+			name: "foo",
+			jars: ["foo.jar"],
+			apex_available: ["myapex"],
+		}
+
+		java_import {
+			name: "bar",
+			jars: ["bar.jar"],
+			apex_available: ["myapex"],
+		}
+
+		prebuilt_systemserverclasspath_fragment {
+			name: "mysystemserverclasspathfragment",
+			prefer: true,
+			contents: ["foo"],
+			standalone_contents: ["bar"],
+			apex_available: ["myapex"],
+		}
+	`
+
+	warning := func(jars, variable string) android.BuildWarning {
+		return android.BuildWarning{
+			File:   "Android.bp",
+			Module: "prebuilt_myapex",
+			Message: `apex "myapex" exports systemserverclasspath fragment "mysystemserverclasspathfragment" ` +
+				`whose jars are not dexpreopted, add ` + jars + ` to ` + variable,
+		}
+	}
+
+	t.Run("matching", func(t *testing.T) {
+		result := android.GroupFixturePreparers(
+			prepareForTestWithSystemserverclasspathFragment,
+			prepareForTestWithMyapex,
+			dexpreopt.FixtureSetApexSystemServerJars("myapex:foo"),
+			dexpreopt.FixtureSetApexStandaloneSystemServerJars("myapex:bar"),
+		).RunTestWithBp(t, bp)
+
+		android.AssertDeepEquals(t, "warnings", []android.BuildWarning(nil), result.Config.BuildWarnings())
+	})
+
+	// The jars are configured for a differently named apex, so they are not dexpreopted.
+	missing := android.GroupFixturePreparers(
+		prepareForTestWithSystemserverclasspathFragment,
+		prepareForTestWithMyapex,
+		dexpreopt.FixtureSetApexSystemServerJars("com.android.myapex:foo"),
+		dexpreopt.FixtureSetApexStandaloneSystemServerJars("com.android.myapex:bar"),
+	)
+
+	t.Run("missing", func(t *testing.T) {
+		result := missing.RunTestWithBp(t, bp)
+
+		// The warnings are only reported once for all variants of the apex.
+		android.AssertDeepEquals(t, "warnings", []android.BuildWarning{
+			warning(`["myapex:bar"]`, "PRODUCT_APEX_STANDALONE_SYSTEM_SERVER_JARS"),
+			warning(`["myapex:foo"]`, "PRODUCT_APEX_SYSTEM_SERVER_JARS"),
+		}, result.Config.BuildWarnings())
+	})
+
+	t.Run("missing strict", func(t *testing.T) {
+		android.GroupFixturePreparers(
+			missing,
+			android.FixtureMergeEnv(map[string]string{
+				"SOONG_STRICT_PREBUILT_APEX_SYSTEM_SERVER_JARS": "true",
+			}),
+		).
+			ExtendWithErrorHandler(android.FixtureExpectsAllErrorsToMatchAPattern([]string{
+				`apex "myapex" exports systemserverclasspath fragment "mysystemserverclasspathfragment" whose jars are not dexpreopted, add \["myapex:foo"\] to PRODUCT_APEX_SYSTEM_SERVER_JARS`,
+				`apex "myapex" exports systemserverclasspath fragment "mysystemserverclasspathfragment" whose jars are not dexpreopted, add \["myapex:bar"\] to PRODUCT_APEX_STANDALONE_SYSTEM_SERVER_JARS`,
+			})).
+			RunTestWithBp(t, bp)
+	})
+}
@@ -119,11 +119,37 @@ func apexVndkDepsMutator(mctx android.BottomUpMutatorContext) {
 	}
 }
 
+// compatSymlink is a symlink installed for compatibility with code that uses paths from before the
+// files were moved into an apex.
+type compatSymlink struct {
+	dir      android.InstallPath
+	linkName string
+	target   string
+}
+
+// installPath returns the path where the symlink will be installed.
+func (s compatSymlink) installPath(ctx android.ModuleContext) android.InstallPath {
+	return s.dir.Join(ctx, s.linkName)
+}
+
+// install installs the symlink and returns the path where it was installed.
+func (s compatSymlink) install(ctx android.ModuleContext) android.InstallPath {
+	return ctx.InstallAbsoluteSymlink(s.dir, s.linkName, s.target)
+}
+
 // name is module.BaseModuleName() which is used as LOCAL_MODULE_NAME and also LOCAL_OVERRIDES_*
 func makeCompatSymlinks(name string, ctx android.ModuleContext) (symlinks android.InstallPaths) {
-	// small helper to add symlink commands
+	for _, s := range compatSymlinksFor(name, ctx) {
+		symlinks = append(symlinks, s.install(ctx))
+	}
+	return symlinks
+}
+
+// compatSymlinksFor returns the compat symlinks needed for the apex with the given name.
+func compatSymlinksFor(name string, ctx android.ModuleContext) (symlinks []compatSymlink) {
+	// small helper to add symlinks
 	addSymlink := func(target string, dir android.InstallPath, linkName string) {
-		symlinks = append(symlinks, ctx.InstallAbsoluteSymlink(dir, linkName, target))
+		symlinks = append(symlinks, compatSymlink{dir: dir, linkName: linkName, target: target})
 	}
 
 	// TODO(b/142911355): [VNDK APEX] Fix hard-coded references to /system/lib/vndk
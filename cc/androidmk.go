@@ -51,6 +51,7 @@ type AndroidMkContext interface {
 	InRecovery() bool
 	NotInPlatform() bool
 	InVendorOrProduct() bool
+	Suffix32bit() bool
 }
 
 type subAndroidMkProvider interface {
@@ -340,6 +341,15 @@ func (binary *binaryDecorator) AndroidMkEntries(ctx AndroidMkContext, entries *a
 			entries.AddStrings("LOCAL_MODULE_SYMLINKS", binary.symlinks...)
 		}
 
+		if ctx.Suffix32bit() && entries.OutputFile.Valid() {
+			// Export the suffixed stem the same way as the multilib modules defined in Make.
+			bits := "64"
+			if ctx.Arch().ArchType.Multilib == "lib32" {
+				bits = "32"
+			}
+			entries.SetString("LOCAL_MODULE_STEM_"+bits, entries.OutputFile.Path().Base())
+		}
+
 		if binary.coverageOutputFile.Valid() {
 			entries.SetString("LOCAL_PREBUILT_COVERAGE_ARCHIVE", binary.coverageOutputFile.String())
 		}
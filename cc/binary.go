@@ -138,7 +138,7 @@ func (binary *binaryDecorator) getStemWithoutSuffix(ctx BaseModuleContext) strin
 // getStem returns the full name to use for the symlink of the main output file of this binary
 // module. This may be derived from the module name and/or other property overrides.
 func (binary *binaryDecorator) getStem(ctx BaseModuleContext) string {
-	return binary.getStemWithoutSuffix(ctx) + String(binary.Properties.Suffix)
+	return binary.getStemWithoutSuffix(ctx) + String(binary.Properties.Suffix) + android.MultilibInstallSuffix(ctx)
 }
 
 // linkerDeps augments and returns the given `deps` to contain dependencies on
@@ -40,3 +40,213 @@ func TestBinaryLinkerScripts(t *testing.T) {
 	android.AssertStringDoesContain(t, "missing flag for linker_scripts",
 		binFoo.Args["ldFlags"], "-Wl,--script,bar.ld")
 }
+
+func TestBinarySuffix32bit(t *testing.T) {
+	t.Parallel()
+	result := PrepareForIntegrationTestWithCc.RunTestWithBp(t, `
+		cc_binary {
+			name: "foo",
+			srcs: ["foo.cc"],
+			compile_multilib: "both",
+			suffix_32bit: true,
+		}
+
+		cc_binary {
+			name: "bar",
+			srcs: ["bar.cc"],
+			compile_multilib: "32",
+			suffix_32bit: true,
+		}
+
+		cc_binary {
+			name: "baz",
+			srcs: ["baz.cc"],
+			compile_multilib: "both",
+			symlink_preferred_arch: true,
+			multilib: {
+				lib32: {
+					suffix: "32",
+				},
+				lib64: {
+					suffix: "64",
+				},
+			},
+		}
+	`)
+
+	installed := func(name, variant string) android.Paths {
+		return result.ModuleForTests(name, variant).Module().FilesToInstall().Paths()
+	}
+	stemEntries := func(name, variant string) map[string][]string {
+		t.Helper()
+		module := result.ModuleForTests(name, variant).Module()
+		entries := android.AndroidMkEntriesForTest(t, result.TestContext, module)[0]
+		stems := make(map[string][]string)
+		for _, entry := range []string{"LOCAL_MODULE_STEM_32", "LOCAL_MODULE_STEM_64"} {
+			if value, ok := entries.EntryMap[entry]; ok {
+				stems[entry] = value
+			}
+		}
+		return stems
+	}
+
+	// The variants of a binary compiled for both architectures install into the same directory, so
+	// the variant for the secondary architecture is suffixed to avoid a conflict.
+	android.AssertPathsRelativeToTopEquals(t, "foo 64-bit", []string{"out/soong/target/product/test_device/system/bin/foo"},
+		installed("foo", "android_arm64_armv8-a"))
+	android.AssertPathsRelativeToTopEquals(t, "foo 32-bit", []string{"out/soong/target/product/test_device/system/bin/foo_32"},
+		installed("foo", "android_arm_armv7-a-neon"))
+	android.AssertDeepEquals(t, "foo 64-bit stems", map[string][]string{"LOCAL_MODULE_STEM_64": {"foo"}},
+		stemEntries("foo", "android_arm64_armv8-a"))
+	android.AssertDeepEquals(t, "foo 32-bit stems", map[string][]string{"LOCAL_MODULE_STEM_32": {"foo_32"}},
+		stemEntries("foo", "android_arm_armv7-a-neon"))
+
+	// There is no conflict to avoid when only one architecture is compiled.
+	android.AssertPathsRelativeToTopEquals(t, "bar 32-bit", []string{"out/soong/target/product/test_device/system/bin/bar"},
+		installed("bar", "android_arm_armv7-a-neon"))
+
+	// The existing convention of arch specific suffixes and a symlink to the preferred arch.
+	android.AssertPathsRelativeToTopEquals(t, "baz 64-bit", []string{
+		"out/soong/target/product/test_device/system/bin/baz64",
+		"out/soong/target/product/test_device/system/bin/baz",
+	}, installed("baz", "android_arm64_armv8-a"))
+	android.AssertPathsRelativeToTopEquals(t, "baz 32-bit", []string{"out/soong/target/product/test_device/system/bin/baz32"},
+		installed("baz", "android_arm_armv7-a-neon"))
+	android.AssertDeepEquals(t, "baz stems", map[string][]string{}, stemEntries("baz", "android_arm64_armv8-a"))
+}
+
+func TestBinarySuffix64bit(t *testing.T) {
+	t.Parallel()
+	result := android.GroupFixturePreparers(
+		PrepareForIntegrationTestWithCc,
+		android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+			variables.Multilib_suffix_64bit = BoolPtr(true)
+		}),
+	).RunTestWithBp(t, `
+		cc_binary {
+			name: "foo",
+			srcs: ["foo.cc"],
+			compile_multilib: "both",
+			suffix_32bit: true,
+		}
+
+		cc_binary {
+			name: "bar",
+			srcs: ["bar.cc"],
+			compile_multilib: "64",
+			suffix_32bit: true,
+		}
+	`)
+
+	installed := func(name, variant string) android.Paths {
+		return result.ModuleForTests(name, variant).Module().FilesToInstall().Paths()
+	}
+	stemEntries := func(name, variant string) map[string][]string {
+		t.Helper()
+		module := result.ModuleForTests(name, variant).Module()
+		entries := android.AndroidMkEntriesForTest(t, result.TestContext, module)[0]
+		stems := make(map[string][]string)
+		for _, entry := range []string{"LOCAL_MODULE_STEM_32", "LOCAL_MODULE_STEM_64"} {
+			if value, ok := entries.EntryMap[entry]; ok {
+				stems[entry] = value
+			}
+		}
+		return stems
+	}
+
+	// The product suffixes the 64-bit variant instead of the 32-bit one.
+	android.AssertPathsRelativeToTopEquals(t, "foo 64-bit", []string{"out/soong/target/product/test_device/system/bin/foo64"},
+		installed("foo", "android_arm64_armv8-a"))
+	android.AssertPathsRelativeToTopEquals(t, "foo 32-bit", []string{"out/soong/target/product/test_device/system/bin/foo"},
+		installed("foo", "android_arm_armv7-a-neon"))
+	android.AssertDeepEquals(t, "foo 64-bit stems", map[string][]string{"LOCAL_MODULE_STEM_64": {"foo64"}},
+		stemEntries("foo", "android_arm64_armv8-a"))
+	android.AssertDeepEquals(t, "foo 32-bit stems", map[string][]string{"LOCAL_MODULE_STEM_32": {"foo"}},
+		stemEntries("foo", "android_arm_armv7-a-neon"))
+
+	// There is no conflict to avoid when only one architecture is compiled.
+	android.AssertPathsRelativeToTopEquals(t, "bar 64-bit", []string{"out/soong/target/product/test_device/system/bin/bar"},
+		installed("bar", "android_arm64_armv8-a"))
+}
+
+func TestBinarySelfOverride(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		name string
+		bp   string
+	}{
+		{
+			name: "direct",
+			bp: `
+				cc_binary {
+					name: "foo",
+					srcs: ["foo.cc"],
+					overrides: ["foo"],
+				}
+			`,
+		},
+		{
+			name: "defaults",
+			bp: `
+				cc_defaults {
+					name: "foo_defaults",
+					overrides: ["foo"],
+				}
+
+				cc_binary {
+					name: "foo",
+					defaults: ["foo_defaults"],
+					srcs: ["foo.cc"],
+				}
+			`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			PrepareForIntegrationTestWithCc.
+				ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+					`module "foo" .*: overrides: "foo" refers to the module itself`)).
+				RunTestWithBp(t, tc.bp)
+		})
+	}
+}
+
+func TestBinarySuffix32bitInstallCollision(t *testing.T) {
+	t.Parallel()
+	result := PrepareForIntegrationTestWithCc.RunTestWithBp(t, `
+		cc_binary {
+			name: "foo",
+			srcs: ["foo.cc"],
+			compile_multilib: "both",
+		}
+
+		cc_binary {
+			name: "bar",
+			srcs: ["bar.cc"],
+			compile_multilib: "both",
+			suffix_32bit: true,
+		}
+	`)
+
+	// installedByVariants returns the variants of the module that install each file.
+	installedByVariants := func(name string) map[string][]string {
+		installed := make(map[string][]string)
+		for _, variant := range []string{"android_arm64_armv8-a", "android_arm_armv7-a-neon"} {
+			for _, path := range result.ModuleForTests(name, variant).Module().FilesToInstall() {
+				relPath := android.StringPathRelativeToTop(result.Config.SoongOutDir(), path.String())
+				installed[relPath] = append(installed[relPath], variant)
+			}
+		}
+		return installed
+	}
+
+	// Both variants of a binary compiled for both architectures install the same file.
+	android.AssertDeepEquals(t, "foo", map[string][]string{
+		"out/soong/target/product/test_device/system/bin/foo": {"android_arm64_armv8-a", "android_arm_armv7-a-neon"},
+	}, installedByVariants("foo"))
+
+	// suffix_32bit tells them apart.
+	android.AssertDeepEquals(t, "bar", map[string][]string{
+		"out/soong/target/product/test_device/system/bin/bar":    {"android_arm64_armv8-a"},
+		"out/soong/target/product/test_device/system/bin/bar_32": {"android_arm_armv7-a-neon"},
+	}, installedByVariants("bar"))
+}
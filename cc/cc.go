@@ -766,6 +766,14 @@ func (d libraryDependencyTag) InstallDepNeeded() bool {
 
 var _ android.InstallNeededDependencyTag = libraryDependencyTag{}
 
+// InterfaceDependency returns true for header libraries, as only their exported headers are used,
+// so that they can be used when they are disabled but set provide_interface_when_disabled.
+func (d libraryDependencyTag) InterfaceDependency() bool {
+	return d.header()
+}
+
+var _ android.InterfaceDependencyTag = libraryDependencyTag{}
+
 // dependencyTag is used for tagging miscellaneous dependency types that don't fit into
 // libraryDependencyTag.  Each tag object is created globally and reused for multiple
 // dependencies (although since the object contains no references, assigning a tag to a
@@ -2562,6 +2570,8 @@ func (c *Module) DepsMutator(actx android.BottomUpMutatorContext) {
 	}
 	ctx.ctx = ctx
 
+	android.CheckNotSelfReferenced(actx, "overrides", c.overriddenModules())
+
 	deps := c.deps(ctx)
 	apiImportInfo := GetApiImports(c, actx)
 
@@ -3384,8 +3394,11 @@ func (c *Module) depsToPaths(ctx android.ModuleContext) PathDeps {
 			makeLibName := MakeLibName(ctx, c, ccDep, ccDep.BaseModuleName()) + libDepTag.makeSuffix
 			switch {
 			case libDepTag.header():
-				c.Properties.AndroidMkHeaderLibs = append(
-					c.Properties.AndroidMkHeaderLibs, makeLibName)
+				// A disabled header library that only provides its headers is not in module-info.json.
+				if dep.Enabled() {
+					c.Properties.AndroidMkHeaderLibs = append(
+						c.Properties.AndroidMkHeaderLibs, makeLibName)
+				}
 			case libDepTag.shared():
 				if lib := moduleLibraryInterface(dep); lib != nil {
 					if lib.buildStubs() && dep.(android.ApexModule).InAnyApex() {
@@ -44,19 +44,19 @@ const (
 const (
 	// VendorVariation is the variant name used for /vendor code that does not
 	// compile against the VNDK.
-	VendorVariation = "vendor"
+	VendorVariation = android.VendorVariation
 
 	// VendorVariationPrefix is the variant prefix used for /vendor code that compiles
 	// against the VNDK.
-	VendorVariationPrefix = "vendor."
+	VendorVariationPrefix = android.VendorVariationPrefix
 
 	// ProductVariation is the variant name used for /product code that does not
 	// compile against the VNDK.
-	ProductVariation = "product"
+	ProductVariation = android.ProductVariation
 
 	// ProductVariationPrefix is the variant prefix used for /product code that compiles
 	// against the VNDK.
-	ProductVariationPrefix = "product."
+	ProductVariationPrefix = android.ProductVariationPrefix
 )
 
 func (ctx *moduleContextImpl) inProduct() bool {
@@ -63,3 +63,21 @@ func prebuiltLibraryHeaderFactory() android.Module {
 	library.HeaderOnly()
 	return module.Init()
 }
+
+var _ android.InterfaceWhenDisabledModule = (*Module)(nil)
+
+// GenerateInterfaceBuildActions exports the include directories of a cc_library_headers module
+// that is disabled but sets provide_interface_when_disabled, so that the modules that use it
+// through header_libs still compile.  The headers of its own header_libs are not re-exported.
+func (c *Module) GenerateInterfaceBuildActions(actx android.ModuleContext) {
+	library, ok := c.linker.(*libraryDecorator)
+	if !ok || !library.header() {
+		actx.PropertyErrorf("provide_interface_when_disabled", "only supported by cc_library_headers")
+		return
+	}
+	ctx := moduleContextFromAndroidModuleContext(actx, c)
+	library.exportIncludes(ctx)
+	library.exportExtraFlags(ctx)
+	library.flagExporter.setProvider(actx)
+	android.SetProvider(actx, HeaderLibraryInfoProvider, HeaderLibraryInfo{})
+}
@@ -98,3 +98,27 @@ func TestPrebuiltLibraryHeadersPreferred(t *testing.T) {
 		})
 	}
 }
+
+func TestDisabledLibraryHeadersProvideInterface(t *testing.T) {
+	t.Parallel()
+	ctx := testCc(t, `
+		cc_library_headers {
+			name: "headers",
+			export_include_dirs: ["my_include"],
+			enabled: false,
+			provide_interface_when_disabled: true,
+		}
+		cc_library_static {
+			name: "lib",
+			srcs: ["foo.c"],
+			header_libs: ["headers"],
+		}
+	`)
+
+	// The headers are still exported to lib, but the disabled module is not a dependency of lib in
+	// module-info.json.
+	lib := ctx.ModuleForTests("lib", "android_arm64_armv8-a_static")
+	android.AssertStringDoesContain(t, "cFlags for lib module", lib.Rule("cc").Args["cFlags"], " -Imy_include ")
+	android.AssertDeepEquals(t, "AndroidMkHeaderLibs", []string(nil),
+		lib.Module().(*Module).Properties.AndroidMkHeaderLibs)
+}
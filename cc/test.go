@@ -366,7 +366,7 @@ func (test *testBinary) moduleInfoJSON(ctx ModuleContext, moduleInfoJSON *androi
 	if ctx.Host() && Bool(test.Properties.Test_options.Unit_test) {
 		moduleInfoJSON.CompatibilitySuites = append(moduleInfoJSON.CompatibilitySuites, "host-unit-tests")
 	}
-	moduleInfoJSON.TestOptionsTags = append(moduleInfoJSON.TestOptionsTags, test.Properties.Test_options.Tags...)
+	test.Properties.Test_options.CommonTestOptions.SetModuleInfoJSON(moduleInfoJSON)
 	moduleInfoJSON.TestMainlineModules = append(moduleInfoJSON.TestMainlineModules, test.Properties.Test_mainline_modules...)
 	if test.testConfig != nil {
 		if _, ok := test.testConfig.(android.WritablePath); ok {
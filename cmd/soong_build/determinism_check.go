@@ -0,0 +1,203 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"android/soong/android"
+	"android/soong/shared"
+
+	"github.com/google/blueprint/bootstrap"
+)
+
+// The --self_check_determinism mode runs the analysis a second time in the same process with a
+// fresh config and context, and fails if the ninja file or the globs of the second run differ from
+// those of the first run.  The ninja file of the second run is written next to the first one and
+// compared by streaming both files, so that neither has to be kept in memory.  It is kept for
+// debugging if it differs.
+
+const (
+	determinismCheckSuffix = ".determinism_check"
+
+	// The maximum number of differing lines that are reported.
+	determinismDiffSampleSize = 10
+)
+
+// hashFile returns the sha256 hash of the contents of the file.
+func hashFile(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// hashGlobs returns the sha256 hash of the globs of the run and the files they matched, which are
+// written to the .globs files.
+func hashGlobs(ctx *android.Context) []byte {
+	globs := make(map[string][]string)
+	for _, glob := range ctx.Globs() {
+		globs[globKey(glob.Pattern, glob.Excludes)] = glob.Matches
+	}
+
+	h := sha256.New()
+	for _, key := range android.SortedKeys(globs) {
+		fmt.Fprintf(h, "%s\x00%s\x00", key, strings.Join(globs[key], "\x00"))
+	}
+	return h.Sum(nil)
+}
+
+// diffSample returns up to max lines that differ between the two readers, formatted as a line
+// number followed by the line from each reader.
+func diffSample(a, b io.Reader, max int) ([]string, error) {
+	scannerA := bufio.NewScanner(a)
+	scannerA.Buffer(nil, 64*1024*1024)
+	scannerB := bufio.NewScanner(b)
+	scannerB.Buffer(nil, 64*1024*1024)
+
+	var sample []string
+	for line := 1; len(sample) < max; line++ {
+		okA, okB := scannerA.Scan(), scannerB.Scan()
+		if !okA && !okB {
+			break
+		}
+		lineA, lineB := "<end of file>", "<end of file>"
+		if okA {
+			lineA = scannerA.Text()
+		}
+		if okB {
+			lineB = scannerB.Text()
+		}
+		if lineA != lineB {
+			sample = append(sample, fmt.Sprintf("line %d:\n- %s\n+ %s", line, lineA, lineB))
+		}
+	}
+
+	if err := scannerA.Err(); err != nil {
+		return nil, err
+	}
+	if err := scannerB.Err(); err != nil {
+		return nil, err
+	}
+	return sample, nil
+}
+
+// diffFileSample returns up to max lines that differ between the two files.
+func diffFileSample(pathA, pathB string, max int) ([]string, error) {
+	a, err := os.Open(pathA)
+	if err != nil {
+		return nil, err
+	}
+	defer a.Close()
+	b, err := os.Open(pathB)
+	if err != nil {
+		return nil, err
+	}
+	defer b.Close()
+	return diffSample(a, b, max)
+}
+
+// runDeterminismCheck reruns the analysis with a fresh config and context and exits with an error if
+// the ninja file or the globs differ from those of the first run, whose context is passed in.
+func runDeterminismCheck(firstCtx *android.Context, availableEnv map[string]string) {
+	failures, err := checkDeterminism(firstCtx, topDir, cmdlineArgs, func(args android.CmdArgs) (*android.Context, error) {
+		return rerunAnalysis(args, availableEnv)
+	})
+	maybeQuit(err, "")
+	if len(failures) > 0 {
+		fmt.Fprintf(os.Stderr, "soong_build is not deterministic:\n%s\n", strings.Join(failures, "\n"))
+		os.Exit(1)
+	}
+}
+
+// rerunAnalysis runs the analysis again with a fresh config and context, writing the ninja file to
+// args.OutFile.
+func rerunAnalysis(args android.CmdArgs, availableEnv map[string]string) (*android.Context, error) {
+	configuration, err := android.NewConfig(args, availableEnv)
+	if err != nil {
+		return nil, err
+	}
+	if configuration.Getenv("ALLOW_MISSING_DEPENDENCIES") == "true" {
+		configuration.SetAllowMissingDependencies()
+	}
+	ctx := newContext(configuration)
+	ctx.Register()
+	_, err = bootstrap.RunBlueprint(args.Args, bootstrap.DoEverything, ctx.Context, ctx.Config())
+	return ctx, err
+}
+
+// checkDeterminism reruns the analysis with rerun, writing the ninja file next to the one of the
+// first run, and returns the ways in which the ninja file or the globs differ from those of the
+// first run, whose context is passed in.  The first context is not used once its globs have been
+// hashed, so that it can be garbage collected during the second run.  The second ninja file is
+// removed unless it differs, so that it can be used for debugging.
+func checkDeterminism(firstCtx *android.Context, topDir string, args android.CmdArgs,
+	rerun func(android.CmdArgs) (*android.Context, error)) ([]string, error) {
+
+	firstGlobs := hashGlobs(firstCtx)
+	firstNinjaFile := shared.JoinPath(topDir, args.OutFile)
+	firstNinja, err := hashFile(firstNinjaFile)
+	if err != nil {
+		return nil, fmt.Errorf("error hashing '%s': %w", firstNinjaFile, err)
+	}
+
+	secondArgs := args
+	secondArgs.OutFile = args.OutFile + determinismCheckSuffix
+	secondNinjaFile := shared.JoinPath(topDir, secondArgs.OutFile)
+
+	ctx, err := rerun(secondArgs)
+	if err != nil {
+		return nil, err
+	}
+
+	secondNinja, err := hashFile(secondNinjaFile)
+	if err != nil {
+		return nil, fmt.Errorf("error hashing '%s': %w", secondNinjaFile, err)
+	}
+
+	var failures []string
+	if !bytes.Equal(firstNinja, secondNinja) {
+		sample, err := diffFileSample(firstNinjaFile, secondNinjaFile, determinismDiffSampleSize)
+		if err != nil {
+			return nil, fmt.Errorf("error comparing '%s' and '%s': %w", firstNinjaFile, secondNinjaFile, err)
+		}
+		failures = append(failures, fmt.Sprintf("%s differs from %s, first differing lines:\n%s",
+			args.OutFile, secondArgs.OutFile, strings.Join(sample, "\n")))
+	}
+	if !bytes.Equal(firstGlobs, hashGlobs(ctx)) {
+		failures = append(failures, "the globs differ between runs")
+	}
+	if len(failures) > 0 {
+		return failures, nil
+	}
+
+	if err := os.Remove(secondNinjaFile); err != nil {
+		return nil, fmt.Errorf("error removing '%s': %w", secondNinjaFile, err)
+	}
+	return nil, nil
+}
@@ -0,0 +1,149 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"android/soong/android"
+)
+
+// testNondeterministicSingleton writes a file whose contents depend on the order of iteration of a
+// map, which is randomized.
+type testNondeterministicSingleton struct{}
+
+func (testNondeterministicSingleton) GenerateBuildActions(ctx android.SingletonContext) {
+	m := make(map[string]bool)
+	for i := 0; i < 64; i++ {
+		m[fmt.Sprintf("dep%d", i)] = true
+	}
+	var lines []string
+	for dep := range m {
+		lines = append(lines, dep)
+	}
+	android.WriteFileRule(ctx, android.PathForOutput(ctx, "nondeterministic.txt"), strings.Join(lines, "\n"))
+}
+
+func runNondeterministicSingleton(t *testing.T) string {
+	_, content := runNondeterministicSingletonWithContext(t)
+	return content
+}
+
+func runNondeterministicSingletonWithContext(t *testing.T) (*android.Context, string) {
+	result := android.GroupFixturePreparers(
+		android.FixtureRegisterWithContext(func(ctx android.RegistrationContext) {
+			ctx.RegisterSingletonType("test_nondeterministic_singleton", func() android.Singleton {
+				return testNondeterministicSingleton{}
+			})
+		}),
+	).RunTest(t)
+
+	rule := result.SingletonForTests("test_nondeterministic_singleton").Output("nondeterministic.txt")
+	return result.TestContext.Context, android.ContentFromFileRuleForTests(t, result.TestContext, rule)
+}
+
+func TestDiffSample(t *testing.T) {
+	sample, err := diffSample(strings.NewReader("a\nb\nc\nd\n"), strings.NewReader("a\nB\nc\nD\ne\n"), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	android.AssertDeepEquals(t, "sample", []string{"line 2:\n- b\n+ B", "line 4:\n- d\n+ D"}, sample)
+
+	sample, err = diffSample(strings.NewReader("a\nb\n"), strings.NewReader("a\nb\nc\n"), determinismDiffSampleSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	android.AssertDeepEquals(t, "sample", []string{"line 3:\n- <end of file>\n+ c"}, sample)
+
+	sample, err = diffSample(strings.NewReader("a\nb\n"), strings.NewReader("a\nb\n"), determinismDiffSampleSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	android.AssertDeepEquals(t, "sample", []string(nil), sample)
+}
+
+func TestDiffSampleDetectsNondeterministicSingleton(t *testing.T) {
+	first := runNondeterministicSingleton(t)
+
+	// The order of iteration of a 64 entry map is very unlikely to be the same in several runs, but
+	// try a few times to avoid flakes.
+	for i := 0; i < 10; i++ {
+		second := runNondeterministicSingleton(t)
+		sample, err := diffSample(strings.NewReader(first), strings.NewReader(second), determinismDiffSampleSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(sample) > 0 {
+			return
+		}
+	}
+	t.Errorf("expected the outputs of the nondeterministic singleton to differ between runs")
+}
+
+func TestCheckDeterminism(t *testing.T) {
+	topDir := t.TempDir()
+	var args android.CmdArgs
+	args.OutFile = "build.ninja"
+	exists := func(file string) bool {
+		_, err := os.Stat(filepath.Join(topDir, file))
+		return err == nil
+	}
+
+	// rerun uses the output of the nondeterministic singleton as the ninja file of the run.
+	rerun := func(args android.CmdArgs) (*android.Context, error) {
+		ctx, content := runNondeterministicSingletonWithContext(t)
+		return ctx, os.WriteFile(filepath.Join(topDir, args.OutFile), []byte(content), 0666)
+	}
+
+	// The order of iteration of a 64 entry map is very unlikely to be the same in several runs, but
+	// try a few times to avoid flakes.
+	var failures []string
+	for i := 0; i < 10 && len(failures) == 0; i++ {
+		firstCtx, err := rerun(args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		failures, err = checkDeterminism(firstCtx, topDir, args, rerun)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(failures) != 1 {
+		t.Fatalf("expected the ninja files of the nondeterministic singleton to differ, got %q", failures)
+	}
+	android.AssertStringDoesContain(t, "failure", failures[0],
+		"build.ninja differs from build.ninja.determinism_check, first differing lines:\nline ")
+	android.AssertBoolEquals(t, "second ninja file is kept", true, exists("build.ninja.determinism_check"))
+
+	// A run that writes the same ninja file passes the check and removes the second ninja file.
+	sameContent := func(args android.CmdArgs) (*android.Context, error) {
+		ctx, _ := runNondeterministicSingletonWithContext(t)
+		return ctx, os.WriteFile(filepath.Join(topDir, args.OutFile), []byte("rule cp\n"), 0666)
+	}
+	firstCtx, err := sameContent(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	failures, err = checkDeterminism(firstCtx, topDir, args, sameContent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	android.AssertDeepEquals(t, "failures", []string(nil), failures)
+	android.AssertBoolEquals(t, "second ninja file is removed", false, exists("build.ninja.determinism_check"))
+}
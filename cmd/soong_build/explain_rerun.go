@@ -0,0 +1,226 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"android/soong/android"
+	"android/soong/shared"
+)
+
+// The --explain_rerun mode saves the inputs that cause soong_build to rerun at the end of every
+// run, and reports which of them changed compared to the inputs saved by the previous run.
+
+const (
+	rerunInputsFileName      = "soong_build_rerun_inputs.json"
+	rerunExplanationFileName = "soong_build_rerun_explanation.txt"
+
+	// The version of the format of the rerun inputs file.  Fields are only added, so that readers
+	// of older versions ignore them.  Version 3 added GlobProvenance, version 2 added
+	// VariableDigests, version 1 files don't have a Version field.
+	rerunInputsVersion = 3
+)
+
+// rerunInputs are the inputs of a soong_build run that may cause it to rerun.
+type rerunInputs struct {
+	Version int `json:",omitempty"`
+
+	// The values of the environment variables read by soong_build.
+	Env map[string]string
+
+	// The files matched by each glob, keyed by the glob pattern and its excludes.
+	Globs map[string][]string
+
+	// The module properties and singletons that created each glob, e.g. "//foo:bar srcs", keyed
+	// like Globs.
+	GlobProvenance map[string][]string `json:",omitempty"`
+
+	// The top level fields of the soong.variables file.
+	Variables map[string]json.RawMessage
+
+	// The digests of the top level fields of the soong.variables file, see
+	// android.Config.ProductVariableDigests.
+	VariableDigests map[string]string `json:",omitempty"`
+}
+
+func globKey(pattern string, excludes []string) string {
+	if len(excludes) == 0 {
+		return pattern
+	}
+	return pattern + " excluding " + strings.Join(excludes, ",")
+}
+
+// collectRerunInputs collects the rerun inputs for the current run.
+func collectRerunInputs(ctx *android.Context) (rerunInputs, error) {
+	inputs := rerunInputs{
+		Version:         rerunInputsVersion,
+		Env:             ctx.Config().EnvDeps(),
+		Globs:           make(map[string][]string),
+		GlobProvenance:  make(map[string][]string),
+		Variables:       make(map[string]json.RawMessage),
+		VariableDigests: ctx.Config().ProductVariableDigests(),
+	}
+
+	for _, glob := range ctx.Globs() {
+		key := globKey(glob.Pattern, glob.Excludes)
+		inputs.Globs[key] = glob.Matches
+		for _, provenance := range android.GlobProvenanceFor(ctx.Config(), glob.Pattern, glob.Excludes) {
+			inputs.GlobProvenance[key] = append(inputs.GlobProvenance[key], provenance.String())
+		}
+	}
+
+	data, err := os.ReadFile(shared.JoinPath(topDir, ctx.Config().ProductVariablesFileName))
+	if err != nil {
+		return rerunInputs{}, err
+	}
+	if err := json.Unmarshal(data, &inputs.Variables); err != nil {
+		return rerunInputs{}, fmt.Errorf("failed to parse %s: %w", ctx.Config().ProductVariablesFileName, err)
+	}
+
+	return inputs, nil
+}
+
+// describeRerunInputChanges returns a human readable description of the differences between the inputs of the
+// previous run and the current run.
+func describeRerunInputChanges(previous, current rerunInputs) []string {
+	var lines []string
+
+	for _, key := range android.SortedUniqueStrings(append(android.SortedKeys(previous.Env), android.SortedKeys(current.Env)...)) {
+		oldValue, oldOk := previous.Env[key]
+		newValue, newOk := current.Env[key]
+		switch {
+		case !oldOk:
+			lines = append(lines, fmt.Sprintf("environment variable %s is newly used (%q)", key, newValue))
+		case !newOk:
+			lines = append(lines, fmt.Sprintf("environment variable %s is no longer used (was %q)", key, oldValue))
+		case oldValue != newValue:
+			lines = append(lines, fmt.Sprintf("environment variable %s changed (%q -> %q)", key, oldValue, newValue))
+		}
+	}
+
+	for _, key := range android.SortedUniqueStrings(append(android.SortedKeys(previous.Globs), android.SortedKeys(current.Globs)...)) {
+		oldMatches, oldOk := previous.Globs[key]
+		newMatches, newOk := current.Globs[key]
+		switch {
+		case !oldOk:
+			lines = append(lines, fmt.Sprintf("glob %s is new", key))
+		case !newOk:
+			lines = append(lines, fmt.Sprintf("glob %s is no longer used", key))
+		default:
+			differ, added, removed := android.ListSetDifference(newMatches, oldMatches)
+			if !differ {
+				break
+			}
+			if provenances := current.GlobProvenance[key]; len(provenances) > 0 {
+				// e.g. "glob for //foo:bar srcs changed: +2 files (foo/*.c)"
+				var counts []string
+				if len(added) > 0 {
+					counts = append(counts, fmt.Sprintf("+%d", len(added)))
+				}
+				if len(removed) > 0 {
+					counts = append(counts, fmt.Sprintf("-%d", len(removed)))
+				}
+				for _, provenance := range provenances {
+					lines = append(lines, fmt.Sprintf("glob for %s changed: %s files (%s)",
+						provenance, strings.Join(counts, " "), key))
+				}
+			} else {
+				lines = append(lines, fmt.Sprintf("glob %s changed (added: %s, removed: %s)",
+					key, strings.Join(added, " "), strings.Join(removed, " ")))
+			}
+		}
+	}
+
+	// The digests don't depend on the order of the fields of objects, but inputs saved before they
+	// were added only have the values.
+	variableChanged := func(key string) bool {
+		return compactJSON(previous.Variables[key]) != compactJSON(current.Variables[key])
+	}
+	if previous.VariableDigests != nil && current.VariableDigests != nil {
+		changed := android.ChangedProductVariables(previous.VariableDigests, current.VariableDigests)
+		variableChanged = func(key string) bool {
+			return android.InList(key, changed)
+		}
+	}
+	for _, key := range android.SortedUniqueStrings(append(android.SortedKeys(previous.Variables), android.SortedKeys(current.Variables)...)) {
+		oldValue, oldOk := previous.Variables[key]
+		newValue, newOk := current.Variables[key]
+		switch {
+		case !oldOk:
+			lines = append(lines, fmt.Sprintf("product variable %s was added (%s)", key, compactJSON(newValue)))
+		case !newOk:
+			lines = append(lines, fmt.Sprintf("product variable %s was removed (was %s)", key, compactJSON(oldValue)))
+		case variableChanged(key):
+			lines = append(lines, fmt.Sprintf("product variable %s changed (%s -> %s)",
+				key, compactJSON(oldValue), compactJSON(newValue)))
+		}
+	}
+
+	return lines
+}
+
+// compactJSON returns the JSON value without insignificant whitespace so that values written with
+// different indentation compare equal.
+func compactJSON(value json.RawMessage) string {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, value); err != nil {
+		return string(value)
+	}
+	return buf.String()
+}
+
+// runExplainRerun writes a report of the inputs that changed since the previous run to the
+// soong output directory and prints it, then saves the inputs of this run for the next one.
+func runExplainRerun(ctx *android.Context) {
+	ctx.EventHandler.Begin("explain_rerun")
+	defer ctx.EventHandler.End("explain_rerun")
+
+	current, err := collectRerunInputs(ctx)
+	maybeQuit(err, "error collecting soong_build inputs")
+
+	inputsFile := shared.JoinPath(topDir, ctx.Config().SoongOutDir(), rerunInputsFileName)
+	reportFile := shared.JoinPath(topDir, ctx.Config().SoongOutDir(), rerunExplanationFileName)
+
+	var report string
+	if data, err := os.ReadFile(inputsFile); os.IsNotExist(err) {
+		report = "no inputs were saved by a previous soong_build run with --explain_rerun\n"
+	} else {
+		maybeQuit(err, "error reading '%s'", inputsFile)
+		var previous rerunInputs
+		err = json.Unmarshal(data, &previous)
+		maybeQuit(err, "error parsing '%s'", inputsFile)
+		if lines := describeRerunInputChanges(previous, current); len(lines) > 0 {
+			report = strings.Join(lines, "\n") + "\n"
+		} else {
+			report = "no inputs changed since the previous soong_build run\n"
+		}
+	}
+
+	err = os.WriteFile(reportFile, []byte(report), 0666)
+	maybeQuit(err, "error writing '%s'", reportFile)
+	fmt.Fprintf(os.Stderr, "soong_build rerun explanation (also written to %s):\n%s",
+		filepath.Join(ctx.Config().SoongOutDir(), rerunExplanationFileName), report)
+
+	data, err := json.MarshalIndent(current, "", "    ")
+	maybeQuit(err, "error serializing soong_build inputs")
+	err = os.WriteFile(inputsFile, data, 0666)
+	maybeQuit(err, "error writing '%s'", inputsFile)
+}
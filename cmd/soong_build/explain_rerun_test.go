@@ -0,0 +1,120 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"android/soong/android"
+)
+
+func TestDescribeRerunInputChanges(t *testing.T) {
+	previous := rerunInputs{
+		Env: map[string]string{
+			"CHANGED": "old",
+			"REMOVED": "gone",
+			"SAME":    "same",
+		},
+		Globs: map[string][]string{
+			"a/*.go":                         {"a/a.go", "a/b.go"},
+			"b/*.go":                         {"b/b.go"},
+			globKey("c/**/*", []string{"x"}): {"c/c"},
+			"e/*.go":                         {"e/a.go"},
+		},
+		Variables: map[string]json.RawMessage{
+			"Platform_sdk_version": json.RawMessage(`34`),
+			"DeviceName":           json.RawMessage(`"old_device"`),
+			"Removed":              json.RawMessage(`true`),
+			"Reformatted":          json.RawMessage(`[ "a", "b" ]`),
+		},
+	}
+
+	current := rerunInputs{
+		Env: map[string]string{
+			"ADDED":   "new",
+			"CHANGED": "new",
+			"SAME":    "same",
+		},
+		Globs: map[string][]string{
+			"a/*.go":                         {"a/a.go", "a/c.go"},
+			"d/*.go":                         nil,
+			globKey("c/**/*", []string{"x"}): {"c/c"},
+			"e/*.go":                         {"e/a.go", "e/b.go", "e/c.go"},
+		},
+		GlobProvenance: map[string][]string{
+			"e/*.go": {"//e:lib srcs", "singleton e_files"},
+		},
+		Variables: map[string]json.RawMessage{
+			"Platform_sdk_version": json.RawMessage(`34`),
+			"DeviceName":           json.RawMessage(`"new_device"`),
+			"Added":                json.RawMessage(`{"a": 1}`),
+			"Reformatted":          json.RawMessage(`["a","b"]`),
+		},
+	}
+
+	expected := []string{
+		`environment variable ADDED is newly used ("new")`,
+		`environment variable CHANGED changed ("old" -> "new")`,
+		`environment variable REMOVED is no longer used (was "gone")`,
+		`glob a/*.go changed (added: a/c.go, removed: a/b.go)`,
+		`glob b/*.go is no longer used`,
+		`glob d/*.go is new`,
+		`glob for //e:lib srcs changed: +2 files (e/*.go)`,
+		`glob for singleton e_files changed: +2 files (e/*.go)`,
+		`product variable Added was added ({"a":1})`,
+		`product variable DeviceName changed ("old_device" -> "new_device")`,
+		`product variable Removed was removed (was true)`,
+	}
+
+	android.AssertDeepEquals(t, "changes", expected, describeRerunInputChanges(previous, current))
+	android.AssertDeepEquals(t, "no changes", []string(nil), describeRerunInputChanges(current, current))
+}
+
+func TestDescribeRerunInputChangesWithDigests(t *testing.T) {
+	previous := rerunInputs{
+		Variables: map[string]json.RawMessage{
+			"Reordered": json.RawMessage(`{"a": 1, "b": 2}`),
+			"Changed":   json.RawMessage(`"old"`),
+		},
+		VariableDigests: map[string]string{
+			"Reordered": "1111",
+			"Changed":   "2222",
+		},
+	}
+
+	current := rerunInputs{
+		Variables: map[string]json.RawMessage{
+			"Reordered": json.RawMessage(`{"b": 2, "a": 1}`),
+			"Changed":   json.RawMessage(`"new"`),
+		},
+		VariableDigests: map[string]string{
+			"Reordered": "1111",
+			"Changed":   "3333",
+		},
+	}
+
+	// Only the variable with a different digest is reported.
+	android.AssertDeepEquals(t, "changes", []string{
+		`product variable Changed changed ("old" -> "new")`,
+	}, describeRerunInputChanges(previous, current))
+
+	// Without the digests of the previous run the values are compared.
+	previous.VariableDigests = nil
+	android.AssertDeepEquals(t, "changes without digests", []string{
+		`product variable Changed changed ("old" -> "new")`,
+		`product variable Reordered changed ({"a":1,"b":2} -> {"b":2,"a":1})`,
+	}, describeRerunInputChanges(previous, current))
+}
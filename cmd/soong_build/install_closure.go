@@ -0,0 +1,88 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"android/soong/android"
+	"android/soong/shared"
+)
+
+// stringListFlag is a flag that can be passed multiple times, collecting each value.
+type stringListFlag []string
+
+func (l *stringListFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringListFlag) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// writeInstallClosures writes the transitive install closures of the modules passed with
+// --install_closure_module to the --install_closure_out file as JSON.
+func writeInstallClosures(ctx *android.Context) {
+	if len(installClosureModules) == 0 {
+		return
+	}
+	if installClosureOut == "" {
+		fmt.Fprintf(os.Stderr, "--install_closure_module requires --install_closure_out\n")
+		os.Exit(1)
+	}
+	phase := android.BeginTailPhase(ctx.Config(), "install_closures", "modules")
+	defer phase.End(ctx.Config())
+	phase.Add(len(installClosureModules))
+
+	closures, err := android.ComputeInstallClosures(ctx, installClosureModules)
+	maybeQuit(err, "error computing install closures")
+
+	data, err := json.MarshalIndent(closures, "", "  ")
+	maybeQuit(err, "error marshalling install closures")
+	err = os.WriteFile(shared.JoinPath(topDir, installClosureOut), data, 0666)
+	maybeQuit(err, "error writing '%s'", installClosureOut)
+}
+
+// writeInstallList writes the install paths of all modules to the --install_list_file file, as
+// JSON if the file name ends in .json and as text otherwise.
+func writeInstallList(ctx *android.Context, installListFile string) {
+	ctx.EventHandler.Begin("install_list")
+	defer ctx.EventHandler.End("install_list")
+
+	f, err := os.Create(shared.JoinPath(topDir, installListFile))
+	maybeQuit(err, "error creating '%s'", installListFile)
+	defer f.Close()
+	err = android.WriteInstallList(f, android.ComputeInstallList(ctx), strings.HasSuffix(installListFile, ".json"))
+	maybeQuit(err, "error writing '%s'", installListFile)
+}
+
+// writeEnablementSnapshot writes the enablement snapshot of the modules to the
+// --enablement_snapshot_file file, if it is set.
+func writeEnablementSnapshot(ctx *android.Context) {
+	if enablementSnapshotFile == "" {
+		return
+	}
+	phase := android.BeginTailPhase(ctx.Config(), "enablement_snapshot", "variants")
+	defer phase.End(ctx.Config())
+
+	snapshot := android.EnablementSnapshot(ctx)
+	phase.Add(strings.Count(snapshot, "\n") + 1)
+	err := os.WriteFile(shared.JoinPath(topDir, enablementSnapshotFile), []byte(snapshot+"\n"), 0666)
+	maybeQuit(err, "error writing '%s'", enablementSnapshotFile)
+}
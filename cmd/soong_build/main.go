@@ -21,6 +21,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -46,6 +48,18 @@ var (
 	delveListen string
 	delvePath   string
 
+	explainRerun         bool
+	selfCheckDeterminism bool
+	printMetricsSummary  bool
+	metricsDirFlag       string
+
+	installClosureModules stringListFlag
+	installClosureOut     string
+
+	enablementSnapshotFile string
+
+	moduleListExclude string
+
 	cmdlineArgs android.CmdArgs
 )
 
@@ -59,6 +73,7 @@ func init() {
 	flag.StringVar(&globListDir, "globListDir", "", "the directory containing the glob list files")
 	flag.StringVar(&cmdlineArgs.OutDir, "out", "", "the ninja builddir directory")
 	flag.StringVar(&cmdlineArgs.ModuleListFile, "l", "", "file that lists filepaths to parse")
+	flag.StringVar(&moduleListExclude, "module_list_exclude", "", "file that lists path prefixes to drop from the -l file")
 
 	// Debug flags
 	flag.StringVar(&delveListen, "delve_listen", "", "Delve port to listen on for debugging")
@@ -67,18 +82,27 @@ func init() {
 	flag.StringVar(&cmdlineArgs.TraceFile, "trace", "", "write trace to file")
 	flag.StringVar(&cmdlineArgs.Memprofile, "memprofile", "", "write memory profile to file")
 	flag.BoolVar(&cmdlineArgs.NoGC, "nogc", false, "turn off GC for debugging")
+	flag.BoolVar(&cmdlineArgs.SerialTail, "serial-tail", false, "run the phases after the build actions are generated that normally use a pool of workers on a single worker")
 
 	// Flags representing various modes soong_build can run in
 	flag.StringVar(&cmdlineArgs.ModuleGraphFile, "module_graph_file", "", "JSON module graph file to output")
 	flag.StringVar(&cmdlineArgs.ModuleActionsFile, "module_actions_file", "", "JSON file to output inputs/outputs of actions of modules")
 	flag.StringVar(&cmdlineArgs.DocFile, "soong_docs", "", "build documentation file to output")
+	flag.StringVar(&cmdlineArgs.InstallListFile, "install_list_file", "", "file to output the install paths of all modules to, as JSON if it ends in .json, without writing the ninja file")
 	flag.StringVar(&cmdlineArgs.BazelQueryViewDir, "bazel_queryview_dir", "", "path to the bazel queryview directory relative to --top")
 	flag.StringVar(&cmdlineArgs.OutFile, "o", "build.ninja", "the Ninja file to output")
 	flag.StringVar(&cmdlineArgs.SoongVariables, "soong_variables", "soong.variables", "the file contains all build variables")
 	flag.BoolVar(&cmdlineArgs.EmptyNinjaFile, "empty-ninja-file", false, "write out a 0-byte ninja file")
 	flag.BoolVar(&cmdlineArgs.BuildFromSourceStub, "build-from-source-stub", false, "build Java stubs from source files instead of API text files")
-	flag.BoolVar(&cmdlineArgs.EnsureAllowlistIntegrity, "ensure-allowlist-integrity", false, "verify that allowlisted modules are mixed-built")
+	flag.BoolVar(&cmdlineArgs.EnsureAllowlistIntegrity, "ensure-allowlist-integrity", false, "verify that allowlisted modules are mixed-built and that the ninja hint allowlist matches module types")
 	flag.StringVar(&cmdlineArgs.ModuleDebugFile, "soong_module_debug", "", "soong module debug info file to write")
+	flag.BoolVar(&explainRerun, "explain_rerun", false, "report the environment variables, globs and product variables that changed since the previous run")
+	flag.BoolVar(&selfCheckDeterminism, "self_check_determinism", false, "run the analysis twice and fail if the ninja file or the globs differ between the runs")
+	flag.BoolVar(&printMetricsSummary, "print_metrics_summary", false, "print a human readable summary of the soong_build metrics and write it to $LOG_DIR/soong_build_metrics.txt")
+	flag.StringVar(&metricsDirFlag, "metrics_dir", "", "directory to write the soong_build metrics to, for running soong_build outside soong_ui (default: $LOG_DIR)")
+	flag.Var(&installClosureModules, "install_closure_module", "module whose transitive install closure is written to --install_closure_out, can be repeated")
+	flag.StringVar(&installClosureOut, "install_closure_out", "", "JSON file to output the transitive install closures of the --install_closure_module modules")
+	flag.StringVar(&enablementSnapshotFile, "enablement_snapshot_file", "", "file to output whether each variant of each module is enabled and its partition to, for diffing between products")
 	// Flags that probably shouldn't be flags of soong_build, but we haven't found
 	// the time to remove them yet
 	flag.BoolVar(&cmdlineArgs.RunGoTests, "t", false, "build and run go tests during bootstrap")
@@ -136,19 +160,12 @@ func writeNinjaHint(ctx *android.Context) error {
 	predicate := func(j *blueprint.JsonModule) (prioritized bool, weight int) {
 		prioritized = false
 		weight = 0
-		for prefix, w := range allowlists.HugeModuleTypePrefixMap {
-			if strings.HasPrefix(j.Type, prefix) {
-				prioritized = true
-				weight = w
-				return
-			}
-		}
-		dep_count := len(j.Deps)
-		src_count := 0
-		for _, a := range j.Module["Actions"].([]blueprint.JSONAction) {
-			src_count += len(a.Inputs)
+		if w, ok := hugeModuleTypeWeight(j.Type); ok {
+			prioritized = true
+			weight = w
+			return
 		}
-		input_size := dep_count + src_count
+		input_size := ninjaHintInputSize(j)
 
 		// Current threshold is an arbitrary value which only consider recall rather than accuracy.
 		if input_size > allowlists.INPUT_SIZE_THRESHOLD {
@@ -163,28 +180,94 @@ func writeNinjaHint(ctx *android.Context) error {
 		return
 	}
 
-	outputsMap := ctx.Context.GetWeightedOutputsFromPredicate(predicate)
-	var outputBuilder strings.Builder
-	for output, weight := range outputsMap {
-		outputBuilder.WriteString(fmt.Sprintf("%s,%d\n", output, weight))
+	maxEntries := defaultNinjaWeightListMaxEntries
+	if s := ctx.Config().Getenv("SOONG_NINJA_HINT_MAX_ENTRIES"); s != "" {
+		var err error
+		if maxEntries, err = strconv.Atoi(s); err != nil || maxEntries <= 0 {
+			return fmt.Errorf("SOONG_NINJA_HINT_MAX_ENTRIES must be a positive integer, got %q", s)
+		}
 	}
+
+	outputsMap := ctx.Context.GetWeightedOutputsFromPredicate(predicate)
 	weightListFile := filepath.Join(topDir, ctx.Config().OutDir(), ".ninja_weight_list")
 
-	err := os.WriteFile(weightListFile, []byte(outputBuilder.String()), 0644)
+	err := os.WriteFile(weightListFile, []byte(ninjaWeightListContents(outputsMap, maxEntries)), 0644)
 	if err != nil {
 		return fmt.Errorf("could not write ninja weight list file %s", err)
 	}
 	return nil
 }
 
+const (
+	// The version of the format of .ninja_weight_list, written in its first line.
+	ninjaWeightListVersion = 1
+
+	// The default maximum number of outputs in .ninja_weight_list, overridden by
+	// SOONG_NINJA_HINT_MAX_ENTRIES.  Ninja gains little from the weights of the outputs beyond the
+	// ones with the largest weights.
+	defaultNinjaWeightListMaxEntries = 5000
+)
+
+// ninjaWeightListContents returns the contents of .ninja_weight_list: a version header followed by
+// at most maxEntries "output,weight" lines sorted by descending weight and then by output, so that
+// the file only changes when the weights do.
+func ninjaWeightListContents(outputsMap map[string]int, maxEntries int) string {
+	outputs := make([]string, 0, len(outputsMap))
+	for output := range outputsMap {
+		outputs = append(outputs, output)
+	}
+	sort.Slice(outputs, func(i, j int) bool {
+		if wi, wj := outputsMap[outputs[i]], outputsMap[outputs[j]]; wi != wj {
+			return wi > wj
+		}
+		return outputs[i] < outputs[j]
+	})
+	if len(outputs) > maxEntries {
+		outputs = outputs[:maxEntries]
+	}
+
+	var outputBuilder strings.Builder
+	outputBuilder.WriteString(fmt.Sprintf("# ninja_weight_list version %d\n", ninjaWeightListVersion))
+	for _, output := range outputs {
+		outputBuilder.WriteString(fmt.Sprintf("%s,%d\n", output, outputsMap[output]))
+	}
+	return outputBuilder.String()
+}
+
+// getMetricsDir returns the directory to write the soong_build metrics to: the --metrics_dir flag,
+// or LOG_DIR, which soong_ui always sets.  When soong_build is run manually, e.g. for debugging with
+// --delve_listen, neither may be set, and it returns an empty string so that the metrics are
+// skipped, unless the metrics summary was explicitly requested.
+func getMetricsDir(flagDir string, availableEnv map[string]string, summaryRequested bool) (string, error) {
+	if flagDir != "" {
+		return flagDir, nil
+	}
+	// Bypass configuration.Getenv, as LOG_DIR does not need to be dependency tracked. By definition, it will
+	// change between every CI build, so tracking it would require re-running Soong for every build.
+	if logDir := availableEnv["LOG_DIR"]; logDir != "" {
+		return logDir, nil
+	}
+	if summaryRequested {
+		return "", fmt.Errorf("--print_metrics_summary requires LOG_DIR or --metrics_dir")
+	}
+	return "", nil
+}
+
 func writeMetrics(configuration android.Config, eventHandler *metrics.EventHandler, metricsDir string) {
-	if len(metricsDir) < 1 {
-		fmt.Fprintf(os.Stderr, "\nMissing required env var for generating soong metrics: LOG_DIR\n")
-		os.Exit(1)
+	if metricsDir == "" {
+		return
 	}
 	metricsFile := filepath.Join(metricsDir, "soong_build_metrics.pb")
-	err := android.WriteMetrics(configuration, eventHandler, metricsFile)
+	soongMetrics, err := android.WriteMetrics(configuration, eventHandler, metricsFile)
 	maybeQuit(err, "error writing soong_build metrics %s", metricsFile)
+
+	if printMetricsSummary {
+		summary := android.MetricsSummary(configuration, soongMetrics)
+		fmt.Print(summary)
+		summaryFile := filepath.Join(metricsDir, "soong_build_metrics.txt")
+		err := os.WriteFile(summaryFile, []byte(summary), 0666)
+		maybeQuit(err, "error writing soong_build metrics summary %s", summaryFile)
+	}
 }
 
 func writeJsonModuleGraphAndActions(ctx *android.Context, cmdArgs android.CmdArgs) {
@@ -200,6 +283,9 @@ func writeJsonModuleGraphAndActions(ctx *android.Context, cmdArgs android.CmdArg
 func writeBuildGlobsNinjaFile(ctx *android.Context) {
 	ctx.EventHandler.Begin("globs_ninja_file")
 	defer ctx.EventHandler.End("globs_ninja_file")
+	phase := android.BeginTailPhase(ctx.Config(), "globs_ninja_file", "globs")
+	defer phase.End(ctx.Config())
+	phase.Add(len(ctx.Globs()))
 
 	globDir := bootstrap.GlobDirectory(ctx.Config().SoongOutDir(), globListDir)
 	err := bootstrap.WriteBuildGlobsNinjaFile(&bootstrap.GlobSingleton{
@@ -211,10 +297,15 @@ func writeBuildGlobsNinjaFile(ctx *android.Context) {
 	maybeQuit(err, "")
 }
 
-func writeDepFile(outputFile string, eventHandler *metrics.EventHandler, ninjaDeps []string) {
+func writeDepFile(config android.Config, outputFile string, eventHandler *metrics.EventHandler, ninjaDeps []string) {
 	eventHandler.Begin("ninja_deps")
 	defer eventHandler.End("ninja_deps")
+	phase := android.BeginTailPhase(config, "ninja_deps", "deps")
+	defer phase.End(config)
 	depFile := shared.JoinPath(topDir, outputFile+".d")
+	// Files may be added as dependencies by multiple modules and singletons, only list them once.
+	ninjaDeps = android.FirstUniqueStrings(ninjaDeps)
+	phase.Add(len(ninjaDeps))
 	err := deptools.WriteDepFile(depFile, outputFile, ninjaDeps)
 	maybeQuit(err, "error writing depfile '%s'", depFile)
 }
@@ -226,7 +317,7 @@ func runSoongOnlyBuild(ctx *android.Context, extraNinjaDeps []string) string {
 
 	var stopBefore bootstrap.StopBefore
 	switch ctx.Config().BuildMode {
-	case android.GenerateModuleGraph:
+	case android.GenerateModuleGraph, android.GenerateInstallList:
 		stopBefore = bootstrap.StopBeforeWriteNinja
 	case android.GenerateQueryView, android.GenerateDocFile:
 		stopBefore = bootstrap.StopBeforePrepareBuildActions
@@ -235,6 +326,14 @@ func runSoongOnlyBuild(ctx *android.Context, extraNinjaDeps []string) string {
 	}
 
 	ninjaDeps, err := bootstrap.RunBlueprint(cmdlineArgs.Args, stopBefore, ctx.Context, ctx.Config())
+	// The fix suggestions are most useful when the analysis failed, so they are written first.
+	fixSuggestionsFile := shared.JoinPath(topDir, ctx.Config().SoongOutDir(), "fix_suggestions.json")
+	if fixErr := android.WriteFixSuggestionsFile(ctx.Config(), fixSuggestionsFile); fixErr != nil {
+		fmt.Fprintf(os.Stderr, "error writing %s: %s\n", fixSuggestionsFile, fixErr)
+	}
+	for _, warning := range ctx.Config().BuildWarnings() {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", warning)
+	}
 	maybeQuit(err, "")
 	ninjaDeps = append(ninjaDeps, extraNinjaDeps...)
 
@@ -245,27 +344,36 @@ func runSoongOnlyBuild(ctx *android.Context, extraNinjaDeps []string) string {
 	case android.GenerateQueryView:
 		queryviewMarkerFile := cmdlineArgs.BazelQueryViewDir + ".marker"
 		runQueryView(cmdlineArgs.BazelQueryViewDir, queryviewMarkerFile, ctx)
-		writeDepFile(queryviewMarkerFile, ctx.EventHandler, ninjaDeps)
+		writeDepFile(ctx.Config(), queryviewMarkerFile, ctx.EventHandler, ninjaDeps)
 		return queryviewMarkerFile
 	case android.GenerateModuleGraph:
 		writeJsonModuleGraphAndActions(ctx, cmdlineArgs)
-		writeDepFile(cmdlineArgs.ModuleGraphFile, ctx.EventHandler, ninjaDeps)
+		writeDepFile(ctx.Config(), cmdlineArgs.ModuleGraphFile, ctx.EventHandler, ninjaDeps)
 		return cmdlineArgs.ModuleGraphFile
+	case android.GenerateInstallList:
+		writeInstallList(ctx, cmdlineArgs.InstallListFile)
+		writeDepFile(ctx.Config(), cmdlineArgs.InstallListFile, ctx.EventHandler, ninjaDeps)
+		return cmdlineArgs.InstallListFile
 	case android.GenerateDocFile:
 		// TODO: we could make writeDocs() return the list of documentation files
 		// written and add them to the .d file. Then soong_docs would be re-run
 		// whenever one is deleted.
 		err := writeDocs(ctx, shared.JoinPath(topDir, cmdlineArgs.DocFile))
 		maybeQuit(err, "error building Soong documentation")
-		writeDepFile(cmdlineArgs.DocFile, ctx.EventHandler, ninjaDeps)
+		writeDepFile(ctx.Config(), cmdlineArgs.DocFile, ctx.EventHandler, ninjaDeps)
 		return cmdlineArgs.DocFile
 	default:
 		// The actual output (build.ninja) was written in the RunBlueprint() call
 		// above
-		writeDepFile(cmdlineArgs.OutFile, ctx.EventHandler, ninjaDeps)
+		writeDepFile(ctx.Config(), cmdlineArgs.OutFile, ctx.EventHandler, ninjaDeps)
 		if needToWriteNinjaHint(ctx) {
-			writeNinjaHint(ctx)
+			maybeQuit(writeNinjaHint(ctx), "error writing ninja hint")
 		}
+		if cmdlineArgs.EnsureAllowlistIntegrity {
+			checkNinjaHintAllowlist(ctx)
+		}
+		writeInstallClosures(ctx)
+		writeEnablementSnapshot(ctx)
 		return cmdlineArgs.OutFile
 	}
 }
@@ -283,6 +391,10 @@ func runSoongOnlyBuild(ctx *android.Context, extraNinjaDeps []string) string {
 //
 // The dependency of build.ninja on soong.environment.used is declared in
 // build.ninja.d
+//
+// Next to it, soong.environment.usage.json lists the module types and
+// singletons that read each of the used environment variables, to diagnose
+// which of them caused a rerun when a variable changed.
 func parseAvailableEnv() map[string]string {
 	if availableEnvFile == "" {
 		fmt.Fprintf(os.Stderr, "--available_env not set\n")
@@ -299,6 +411,14 @@ func main() {
 	shared.ReexecWithDelveMaybe(delveListen, delvePath)
 	android.InitSandbox(topDir)
 
+	var originalModuleListFile string
+	if moduleListExclude != "" {
+		originalModuleListFile = cmdlineArgs.ModuleListFile
+		filtered, err := applyModuleListExclusions(cmdlineArgs.ModuleListFile, moduleListExclude)
+		maybeQuit(err, "error applying --module_list_exclude file '%s'", moduleListExclude)
+		cmdlineArgs.ModuleListFile = filtered
+	}
+
 	availableEnv := parseAvailableEnv()
 	configuration, err := android.NewConfig(cmdlineArgs, availableEnv)
 	maybeQuit(err, "")
@@ -307,24 +427,44 @@ func main() {
 	}
 
 	extraNinjaDeps := []string{configuration.ProductVariablesFileName, usedEnvFile}
+	if moduleListExclude != "" {
+		// Rerun when the exclusions or the unfiltered module list change.
+		extraNinjaDeps = append(extraNinjaDeps, moduleListExclude, originalModuleListFile)
+	}
 	if shared.IsDebugging() {
 		// Add a non-existent file to the dependencies so that soong_build will rerun when the debugger is
 		// enabled even if it completed successfully.
 		extraNinjaDeps = append(extraNinjaDeps, filepath.Join(configuration.SoongOutDir(), "always_rerun_for_delve"))
 	}
 
-	// Bypass configuration.Getenv, as LOG_DIR does not need to be dependency tracked. By definition, it will
-	// change between every CI build, so tracking it would require re-running Soong for every build.
-	metricsDir := availableEnv["LOG_DIR"]
+	metricsDir, err := getMetricsDir(metricsDirFlag, availableEnv, printMetricsSummary)
+	maybeQuit(err, "")
 
 	ctx := newContext(configuration)
-	android.StartBackgroundMetrics(configuration)
+	if metricsDir != "" {
+		android.StartBackgroundMetrics(configuration)
+	} else {
+		fmt.Fprintln(os.Stderr, "warning: LOG_DIR is not set and --metrics_dir was not passed, not writing soong_build metrics")
+	}
+	// Like LOG_DIR, SOONG_TAIL_PROGRESS only affects the output of soong_build, so it is not tracked.
+	if availableEnv["SOONG_TAIL_PROGRESS"] == "true" {
+		stopTailProgressTicker := android.StartTailProgressTicker(configuration, os.Stderr, 5*time.Second)
+		defer stopTailProgressTicker()
+	}
 
 	ctx.Register()
 	finalOutputFile := runSoongOnlyBuild(ctx, extraNinjaDeps)
+	if explainRerun {
+		runExplainRerun(ctx)
+	}
 	writeMetrics(configuration, ctx.EventHandler, metricsDir)
 
 	writeUsedEnvironmentFile(configuration)
+	writeEnvUsageFile(configuration)
+
+	if selfCheckDeterminism && configuration.BuildMode == android.AnalysisNoBazel {
+		runDeterminismCheck(ctx, availableEnv)
+	}
 
 	// Touch the output file so that it's the newest file created by soong_build.
 	// This is necessary because, if soong_build generated any files which
@@ -354,6 +494,29 @@ func writeUsedEnvironmentFile(configuration android.Config) {
 	maybeQuit(err, "error writing used environment file '%s'", usedEnvFile)
 }
 
+// envUsageFile returns the path of the file that lists the consumers of the environment variables in
+// the used environment file, e.g. soong.environment.usage.build.json for soong.environment.used.build.
+func envUsageFile(usedEnvFile string) string {
+	dir, base := filepath.Split(usedEnvFile)
+	if strings.Contains(base, "environment.used") {
+		return filepath.Join(dir, strings.Replace(base, "environment.used", "environment.usage", 1)+".json")
+	}
+	return usedEnvFile + ".usage.json"
+}
+
+func writeEnvUsageFile(configuration android.Config) {
+	if usedEnvFile == "" {
+		return
+	}
+
+	file := envUsageFile(usedEnvFile)
+	data, err := shared.EnvUsageFileContents(configuration.EnvUsage())
+	maybeQuit(err, "error writing environment usage file '%s'\n", file)
+
+	err = os.WriteFile(shared.JoinPath(topDir, file), data, 0666)
+	maybeQuit(err, "error writing environment usage file '%s'", file)
+}
+
 func touch(path string) {
 	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
 	maybeQuit(err, "Error touching '%s'", path)
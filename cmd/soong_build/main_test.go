@@ -0,0 +1,153 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"android/soong/android"
+
+	"github.com/google/blueprint/metrics"
+)
+
+type testNinjaDepsSingleton struct{}
+
+func (testNinjaDepsSingleton) GenerateBuildActions(ctx android.SingletonContext) {
+	ctx.Config().AddNinjaFileDeps("device/foo/config.json")
+	// Adding the same file twice should only list it once in the depfile.
+	ctx.Config().AddNinjaFileDeps("device/foo/config.json", "device/foo/version.txt")
+}
+
+func TestWriteDepFileIncludesSingletonDeps(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		android.FixtureRegisterWithContext(func(ctx android.RegistrationContext) {
+			ctx.RegisterSingletonType("test_ninja_deps_singleton", func() android.Singleton {
+				return testNinjaDepsSingleton{}
+			})
+		}),
+		android.PrepareForTestWithNinjaDeps,
+	).RunTest(t)
+
+	oldTopDir := topDir
+	topDir = t.TempDir()
+	defer func() { topDir = oldTopDir }()
+
+	writeDepFile(result.Config, "build.ninja", &metrics.EventHandler{}, result.NinjaDeps)
+
+	contents, err := os.ReadFile(filepath.Join(topDir, "build.ninja.d"))
+	if err != nil {
+		t.Fatalf("failed to read depfile: %s", err)
+	}
+
+	for _, dep := range []string{"device/foo/config.json", "device/foo/version.txt"} {
+		if count := strings.Count(string(contents), dep); count != 1 {
+			t.Errorf("expected %q once in depfile, found %d times:\n%s", dep, count, contents)
+		}
+	}
+}
+
+func TestNinjaWeightListContents(t *testing.T) {
+	outputs := map[string]int{
+		"out/b": 10,
+		"out/a": 10,
+		"out/c": 30,
+		"out/d": 1,
+	}
+
+	testCases := []struct {
+		name       string
+		maxEntries int
+		expected   []string
+	}{
+		{
+			name:       "sorted by weight then name",
+			maxEntries: 10,
+			expected:   []string{"out/c,30", "out/a,10", "out/b,10", "out/d,1"},
+		},
+		{
+			name:       "capped",
+			maxEntries: 2,
+			expected:   []string{"out/c,30", "out/a,10"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			lines := strings.Split(strings.TrimSuffix(ninjaWeightListContents(outputs, tc.maxEntries), "\n"), "\n")
+			android.AssertStringEquals(t, "header", "# ninja_weight_list version 1", lines[0])
+			android.AssertDeepEquals(t, "entries", tc.expected, lines[1:])
+		})
+	}
+
+	// The contents don't depend on the iteration order of the map.
+	expected := ninjaWeightListContents(outputs, 10)
+	for i := 0; i < 10; i++ {
+		android.AssertStringEquals(t, "deterministic", expected, ninjaWeightListContents(outputs, 10))
+	}
+}
+
+func TestGetMetricsDir(t *testing.T) {
+	testCases := []struct {
+		name             string
+		flagDir          string
+		env              map[string]string
+		summaryRequested bool
+		expected         string
+		expectedErr      string
+	}{
+		{
+			name: "unset",
+		},
+		{
+			name:             "unset with summary",
+			summaryRequested: true,
+			expectedErr:      "--print_metrics_summary requires LOG_DIR or --metrics_dir",
+		},
+		{
+			name:             "flag",
+			flagDir:          "/tmp/metrics",
+			summaryRequested: true,
+			expected:         "/tmp/metrics",
+		},
+		{
+			name:     "env",
+			env:      map[string]string{"LOG_DIR": "out/logs"},
+			expected: "out/logs",
+		},
+		{
+			name:     "flag overrides env",
+			flagDir:  "/tmp/metrics",
+			env:      map[string]string{"LOG_DIR": "out/logs"},
+			expected: "/tmp/metrics",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := getMetricsDir(tc.flagDir, tc.env, tc.summaryRequested)
+			if tc.expectedErr != "" {
+				android.AssertErrorMessageEquals(t, "error", tc.expectedErr, err)
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			android.AssertStringEquals(t, "metrics dir", tc.expected, dir)
+		})
+	}
+}
@@ -0,0 +1,177 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"android/soong/shared"
+
+	"github.com/google/blueprint/parser"
+	"github.com/google/blueprint/pathtools"
+)
+
+// The --module_list_exclude flag names a file of path prefixes, one per line, whose Android.bp files
+// are dropped from the module list passed with -l before blueprint parses it.  This lets partial
+// checkouts with stub directories parse everything except some trees.  The filtered module list is
+// written to the soong out directory and used in place of the original one.
+
+const filteredModuleListName = "Android.bp.list.filtered"
+
+// parseModuleListExclusions parses the contents of a --module_list_exclude file.  Blank lines and
+// lines starting with # are ignored.
+func parseModuleListExclusions(data []byte) []string {
+	var prefixes []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		prefixes = append(prefixes, filepath.Clean(line))
+	}
+	return prefixes
+}
+
+// isExcludedFromModuleList returns true if the path is one of the prefixes or is in a directory
+// that is one of the prefixes.
+func isExcludedFromModuleList(path string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// filterModuleList splits the paths in the module list into those that are kept and those that
+// are excluded by the prefixes.
+func filterModuleList(paths, prefixes []string) (kept, excluded []string) {
+	for _, path := range paths {
+		if isExcludedFromModuleList(filepath.Clean(path), prefixes) {
+			excluded = append(excluded, path)
+		} else {
+			kept = append(kept, path)
+		}
+	}
+	return kept, excluded
+}
+
+// buildIncludes returns the files listed in the build = [...] assignments of the blueprint file,
+// relative to the directory of the file.  Assignments that aren't lists of string literals are
+// ignored.
+func buildIncludes(path string, data []byte) ([]string, []error) {
+	file, errs := parser.Parse(path, bytes.NewReader(data), parser.NewScope(nil))
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	var includes []string
+	for _, def := range file.Defs {
+		assignment, ok := def.(*parser.Assignment)
+		if !ok || assignment.Name != "build" {
+			continue
+		}
+		list, ok := assignment.Value.(*parser.List)
+		if !ok {
+			continue
+		}
+		for _, value := range list.Values {
+			if s, ok := value.(*parser.String); ok {
+				includes = append(includes, s.Value)
+			}
+		}
+	}
+	return includes, nil
+}
+
+// checkExcludedIncludes returns an error for each excluded file that is included by a kept file
+// through a build = [...] assignment, as blueprint would fail to find it.  Only the kept files in
+// the directories of the excluded files or their ancestors are read.
+func checkExcludedIncludes(kept, excluded []string, readFile func(string) ([]byte, error)) []error {
+	if len(excluded) == 0 {
+		return nil
+	}
+
+	var errs []error
+	for _, includer := range kept {
+		dir := filepath.Dir(includer)
+		var candidates []string
+		for _, path := range excluded {
+			if dir == "." || strings.HasPrefix(path, dir+"/") {
+				candidates = append(candidates, path)
+			}
+		}
+		if len(candidates) == 0 {
+			continue
+		}
+
+		data, err := readFile(includer)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		includes, parseErrs := buildIncludes(includer, data)
+		errs = append(errs, parseErrs...)
+		for _, include := range includes {
+			pattern := filepath.Join(dir, include)
+			for _, path := range candidates {
+				if match, err := pathtools.Match(pattern, path); err != nil {
+					errs = append(errs, fmt.Errorf("%s: invalid build pattern %q: %s", includer, include, err))
+				} else if match {
+					errs = append(errs, fmt.Errorf("--module_list_exclude removes %s, which %s includes with build = [%q]",
+						path, includer, include))
+				}
+			}
+		}
+	}
+	return errs
+}
+
+// applyModuleListExclusions writes the module list without the files excluded by the
+// --module_list_exclude file to the soong out directory, and returns its path.
+func applyModuleListExclusions(moduleListFile, excludeFile string) (string, error) {
+	moduleList, err := os.ReadFile(shared.JoinPath(topDir, moduleListFile))
+	if err != nil {
+		return "", err
+	}
+	exclusions, err := os.ReadFile(shared.JoinPath(topDir, excludeFile))
+	if err != nil {
+		return "", err
+	}
+
+	paths := strings.Fields(string(moduleList))
+	kept, excluded := filterModuleList(paths, parseModuleListExclusions(exclusions))
+	readFile := func(path string) ([]byte, error) {
+		return os.ReadFile(shared.JoinPath(topDir, path))
+	}
+	if errs := checkExcludedIncludes(kept, excluded, readFile); len(errs) > 0 {
+		var messages []string
+		for _, err := range errs {
+			messages = append(messages, err.Error())
+		}
+		return "", fmt.Errorf("%s", strings.Join(messages, "\n"))
+	}
+
+	filtered := filepath.Join(cmdlineArgs.SoongOutDir, filteredModuleListName)
+	err = pathtools.WriteFileIfChanged(shared.JoinPath(topDir, filtered), []byte(strings.Join(kept, "\n")+"\n"), 0666)
+	if err != nil {
+		return "", err
+	}
+	return filtered, nil
+}
@@ -0,0 +1,149 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"android/soong/android"
+)
+
+var testModuleList = []string{
+	"Android.bp",
+	"build/soong/Android.bp",
+	"vendor/foo/Android.bp",
+	"vendor/foo/sub/Android.bp",
+	"vendor/foobar/Android.bp",
+	"vendor/lib/Android.bp",
+	"vendor/lib/stubs/Android.bp",
+}
+
+func TestFilterModuleList(t *testing.T) {
+	prefixes := parseModuleListExclusions([]byte(`
+# Trees that aren't checked out.
+vendor/foo/
+
+vendor/lib/stubs/Android.bp
+`))
+	android.AssertArrayString(t, "prefixes", []string{"vendor/foo", "vendor/lib/stubs/Android.bp"}, prefixes)
+
+	kept, excluded := filterModuleList(testModuleList, prefixes)
+	android.AssertArrayString(t, "kept", []string{
+		"Android.bp",
+		"build/soong/Android.bp",
+		"vendor/foobar/Android.bp",
+		"vendor/lib/Android.bp",
+	}, kept)
+	android.AssertArrayString(t, "excluded", []string{
+		"vendor/foo/Android.bp",
+		"vendor/foo/sub/Android.bp",
+		"vendor/lib/stubs/Android.bp",
+	}, excluded)
+}
+
+func TestCheckExcludedIncludes(t *testing.T) {
+	testCases := []struct {
+		name     string
+		files    map[string]string
+		prefixes []string
+		expected []string
+	}{
+		{
+			name: "no includes",
+			files: map[string]string{
+				"Android.bp":               `foo { name: "foo" }`,
+				"vendor/lib/Android.bp":    `foo { name: "lib" }`,
+				"vendor/foobar/Android.bp": `foo { name: "foobar" }`,
+			},
+			prefixes: []string{"vendor/foo", "vendor/lib/stubs"},
+		},
+		{
+			name: "included by a kept file",
+			files: map[string]string{
+				"Android.bp":            `foo { name: "foo" }`,
+				"vendor/lib/Android.bp": `build = ["stubs/Android.bp"]`,
+			},
+			prefixes: []string{"vendor/lib/stubs"},
+			expected: []string{
+				`--module_list_exclude removes vendor/lib/stubs/Android.bp, which vendor/lib/Android.bp includes with build = ["stubs/Android.bp"]`,
+			},
+		},
+		{
+			name: "included by a glob",
+			files: map[string]string{
+				"Android.bp": `build = ["vendor/foo/**/Android.bp"]`,
+			},
+			prefixes: []string{"vendor/foo/sub"},
+			expected: []string{
+				`--module_list_exclude removes vendor/foo/sub/Android.bp, which Android.bp includes with build = ["vendor/foo/**/Android.bp"]`,
+			},
+		},
+		{
+			name: "included by an excluded file",
+			files: map[string]string{
+				"Android.bp":            `foo { name: "foo" }`,
+				"vendor/foo/Android.bp": `build = ["sub/Android.bp"]`,
+			},
+			prefixes: []string{"vendor/foo"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			kept, excluded := filterModuleList(testModuleList, tc.prefixes)
+			readFile := func(path string) ([]byte, error) {
+				if contents, ok := tc.files[path]; ok {
+					return []byte(contents), nil
+				}
+				// Files that aren't in the test case have no includes.
+				return nil, nil
+			}
+
+			var errs []string
+			for _, err := range checkExcludedIncludes(kept, excluded, readFile) {
+				errs = append(errs, err.Error())
+			}
+			android.AssertArrayString(t, "errors", tc.expected, errs)
+		})
+	}
+}
+
+func TestCheckExcludedIncludesParseError(t *testing.T) {
+	kept, excluded := filterModuleList(testModuleList, []string{"vendor/foo"})
+	readFile := func(path string) ([]byte, error) {
+		return []byte(`build = [`), nil
+	}
+
+	errs := checkExcludedIncludes(kept, excluded, readFile)
+	if len(errs) == 0 {
+		t.Fatalf("expected a parse error")
+	}
+	android.AssertStringMatches(t, "parse error", errs[0].Error(), `^Android\.bp:`)
+}
+
+func TestCheckExcludedIncludesReadError(t *testing.T) {
+	kept, excluded := filterModuleList(testModuleList, []string{"vendor/lib/stubs"})
+	readFile := func(path string) ([]byte, error) {
+		return nil, fmt.Errorf("cannot read %s", path)
+	}
+
+	var errs []string
+	for _, err := range checkExcludedIncludes(kept, excluded, readFile) {
+		errs = append(errs, err.Error())
+	}
+	// Only the files in the ancestors of the excluded directory are read.
+	android.AssertArrayString(t, "errors", []string{"cannot read Android.bp", "cannot read vendor/lib/Android.bp"}, errs)
+}
@@ -0,0 +1,151 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"android/soong/android"
+	"android/soong/android/allowlists"
+	"android/soong/shared"
+
+	"github.com/google/blueprint"
+)
+
+const (
+	ninjaHintAllowlistReportFileName = "ninja_hint_allowlist_report.txt"
+
+	// Modules with more than this many times allowlists.INPUT_SIZE_THRESHOLD inputs whose module
+	// type isn't in allowlists.HugeModuleTypePrefixMap are reported as candidates for the allowlist.
+	hugeInputSizeFactor = 10
+)
+
+// hugeModuleTypeWeight returns the weight of the module type if it matches a prefix in
+// allowlists.HugeModuleTypePrefixMap.
+func hugeModuleTypeWeight(moduleType string) (int, bool) {
+	for prefix, w := range allowlists.HugeModuleTypePrefixMap {
+		if strings.HasPrefix(moduleType, prefix) {
+			return w, true
+		}
+	}
+	return 0, false
+}
+
+// ninjaHintInputSize returns the number of dependencies and action inputs of the module, which is
+// used to predict its build time.
+func ninjaHintInputSize(j *blueprint.JsonModule) int {
+	inputSize := len(j.Deps)
+	for _, a := range j.Module["Actions"].([]blueprint.JSONAction) {
+		inputSize += len(a.Inputs)
+	}
+	return inputSize
+}
+
+// deadHugeModuleTypePrefixes returns the prefixes that don't match any of the module types.
+func deadHugeModuleTypePrefixes(prefixes map[string]int, moduleTypes []string) []string {
+	var dead []string
+	for _, prefix := range android.SortedKeys(prefixes) {
+		matched := false
+		for _, moduleType := range moduleTypes {
+			if strings.HasPrefix(moduleType, prefix) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			dead = append(dead, prefix)
+		}
+	}
+	return dead
+}
+
+// ninjaHintModule is a module that is much larger than the ninja hint threshold but whose module
+// type isn't prioritized by allowlists.HugeModuleTypePrefixMap.
+type ninjaHintModule struct {
+	name       string
+	moduleType string
+	inputSize  int
+}
+
+// unprioritizedHugeModules returns the modules sorted by decreasing input size, keeping only the
+// largest variant of each module.
+func unprioritizedHugeModules(modules []ninjaHintModule) []ninjaHintModule {
+	largest := make(map[string]ninjaHintModule)
+	for _, m := range modules {
+		if prev, ok := largest[m.name]; !ok || m.inputSize > prev.inputSize {
+			largest[m.name] = m
+		}
+	}
+
+	var ret []ninjaHintModule
+	for _, name := range android.SortedKeys(largest) {
+		ret = append(ret, largest[name])
+	}
+	sort.SliceStable(ret, func(i, j int) bool {
+		return ret[i].inputSize > ret[j].inputSize
+	})
+	return ret
+}
+
+// ninjaHintAllowlistReport returns the report of the dead prefixes and the huge modules that aren't
+// prioritized.
+func ninjaHintAllowlistReport(dead []string, huge []ninjaHintModule) string {
+	var sb strings.Builder
+	for _, prefix := range dead {
+		fmt.Fprintf(&sb, "dead HugeModuleTypePrefixMap entry %q does not match any registered module type\n", prefix)
+	}
+	for _, m := range huge {
+		fmt.Fprintf(&sb, "module %q of type %q has %d inputs but is not prioritized by HugeModuleTypePrefixMap\n",
+			m.name, m.moduleType, m.inputSize)
+	}
+	return sb.String()
+}
+
+// checkNinjaHintAllowlist verifies that every prefix of allowlists.HugeModuleTypePrefixMap still
+// matches a registered module type, and lists the modules that are large enough to be added to it.
+// The report is written to the soong output directory, and soong_build fails if any prefix is dead.
+func checkNinjaHintAllowlist(ctx *android.Context) {
+	ctx.BeginEvent("ninja_hint_allowlist")
+	defer ctx.EndEvent("ninja_hint_allowlist")
+
+	var huge []ninjaHintModule
+	ctx.Context.GetWeightedOutputsFromPredicate(func(j *blueprint.JsonModule) (bool, int) {
+		if _, ok := hugeModuleTypeWeight(j.Type); ok {
+			return false, 0
+		}
+		if inputSize := ninjaHintInputSize(j); inputSize > hugeInputSizeFactor*allowlists.INPUT_SIZE_THRESHOLD {
+			huge = append(huge, ninjaHintModule{j.Name, j.Type, inputSize})
+		}
+		return false, 0
+	})
+
+	dead := deadHugeModuleTypePrefixes(allowlists.HugeModuleTypePrefixMap,
+		android.SortedKeys(android.ModuleTypeFactories()))
+	report := ninjaHintAllowlistReport(dead, unprioritizedHugeModules(huge))
+
+	reportFile := shared.JoinPath(topDir, ctx.Config().SoongOutDir(), ninjaHintAllowlistReportFileName)
+	err := os.WriteFile(reportFile, []byte(report), 0666)
+	maybeQuit(err, "error writing '%s'", reportFile)
+
+	if len(dead) > 0 {
+		fmt.Fprintf(os.Stderr, "HugeModuleTypePrefixMap has entries that don't match any module type, see %s:\n%s",
+			filepath.Join(ctx.Config().SoongOutDir(), ninjaHintAllowlistReportFileName), report)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"android/soong/android"
+)
+
+func TestDeadHugeModuleTypePrefixes(t *testing.T) {
+	prefixes := map[string]int{
+		"rust_":       1,
+		"droidstubs":  1,
+		"art_":        1,
+		"ndk_library": 1,
+	}
+	moduleTypes := []string{
+		"cc_library",
+		"droidstubs",
+		"ndk_headers",
+		"rust_binary",
+		"rust_library",
+	}
+
+	android.AssertDeepEquals(t, "dead prefixes", []string{"art_", "ndk_library"},
+		deadHugeModuleTypePrefixes(prefixes, moduleTypes))
+	android.AssertDeepEquals(t, "no dead prefixes", []string(nil),
+		deadHugeModuleTypePrefixes(prefixes, append(moduleTypes, "art_cc_library", "ndk_library")))
+}
+
+func TestNinjaHintAllowlistReport(t *testing.T) {
+	huge := unprioritizedHugeModules([]ninjaHintModule{
+		{"libfoo", "cc_library", 600},
+		{"libbar", "cc_library", 900},
+		{"libfoo", "cc_library", 700},
+		{"framework", "java_library", 900},
+	})
+
+	android.AssertDeepEquals(t, "huge modules", []ninjaHintModule{
+		{"framework", "java_library", 900},
+		{"libbar", "cc_library", 900},
+		{"libfoo", "cc_library", 700},
+	}, huge)
+
+	android.AssertStringEquals(t, "report", ``+
+		`dead HugeModuleTypePrefixMap entry "art_" does not match any registered module type`+"\n"+
+		`module "framework" of type "java_library" has 900 inputs but is not prioritized by HugeModuleTypePrefixMap`+"\n"+
+		`module "libbar" of type "cc_library" has 900 inputs but is not prioritized by HugeModuleTypePrefixMap`+"\n"+
+		`module "libfoo" of type "cc_library" has 700 inputs but is not prioritized by HugeModuleTypePrefixMap`+"\n",
+		ninjaHintAllowlistReport([]string{"art_"}, huge))
+}
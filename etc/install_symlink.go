@@ -18,6 +18,9 @@ import (
 	"android/soong/android"
 	"path/filepath"
 	"strings"
+
+	"github.com/google/blueprint"
+	"github.com/google/blueprint/proptools"
 )
 
 func init() {
@@ -26,6 +29,7 @@ func init() {
 
 func RegisterInstallSymlinkBuildComponents(ctx android.RegistrationContext) {
 	ctx.RegisterModuleType("install_symlink", InstallSymlinkFactory)
+	ctx.RegisterModuleType("compat_symlink", CompatSymlinkFactory)
 }
 
 // install_symlink can be used to install an symlink with an arbitrary target to an arbitrary path
@@ -46,14 +50,51 @@ type InstallSymlinkProperties struct {
 	Symlink_target string
 }
 
+// compat_symlink is an install_symlink that installs a symlink from a legacy path to the new
+// location of a file, e.g. from /system/usr/icu to /apex/com.android.i18n/etc/icu, so that adding a
+// compat symlink does not require a Go change.  Apexes and prebuilt apexes list the compat_symlink
+// modules that must be installed along with them in their compat_symlinks property.  Unlike
+// install_symlink, symlink_target is relative to the directory containing the symlink unless
+// allow_absolute_target is set, and a relative target must not point outside of the partition.
+func CompatSymlinkFactory() android.Module {
+	module := &InstallSymlink{compat: true}
+	module.AddProperties(&module.properties, &module.compatProperties)
+	android.InitAndroidMultiTargetsArchModule(module, android.DeviceSupported, android.MultilibCommon)
+	return module
+}
+
+type CompatSymlinkProperties struct {
+	// Whether symlink_target may be an absolute path on the device, e.g. in /apex.  Defaults to
+	// false.
+	Allow_absolute_target *bool
+}
+
 type InstallSymlink struct {
 	android.ModuleBase
 	properties InstallSymlinkProperties
 
+	// Whether the module is a compat_symlink.
+	compat           bool
+	compatProperties CompatSymlinkProperties
+
 	output        android.Path
 	installedPath android.InstallPath
 }
 
+// CompatSymlinkInfo is provided by compat_symlink modules.
+type CompatSymlinkInfo struct {
+	// The path the symlink is installed to.
+	InstalledPath android.InstallPath
+
+	// The target of the symlink.
+	Target string
+}
+
+var CompatSymlinkInfoProvider = blueprint.NewProvider[CompatSymlinkInfo]()
+
+// The partitions that a compat_symlink can be installed in.
+var compatSymlinkPartitions = []string{"system", "system_ext", "product", "vendor", "odm"}
+
 func (m *InstallSymlink) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 	if filepath.Clean(m.properties.Symlink_target) != m.properties.Symlink_target {
 		ctx.PropertyErrorf("symlink_target", "Should be a clean filepath")
@@ -68,13 +109,55 @@ func (m *InstallSymlink) GenerateAndroidBuildActions(ctx android.ModuleContext)
 		return
 	}
 
+	name := filepath.Base(m.properties.Installed_location)
+	installDir := android.PathForModuleInstall(ctx, filepath.Dir(m.properties.Installed_location))
+	if m.compat && !m.checkCompatSymlink(ctx, installDir) {
+		return
+	}
+
 	out := android.PathForModuleOut(ctx, "out.txt")
 	android.WriteFileRuleVerbatim(ctx, out, "")
 	m.output = out
 
-	name := filepath.Base(m.properties.Installed_location)
-	installDir := android.PathForModuleInstall(ctx, filepath.Dir(m.properties.Installed_location))
 	m.installedPath = ctx.InstallAbsoluteSymlink(installDir, name, m.properties.Symlink_target)
+
+	if m.compat {
+		android.SetProvider(ctx, CompatSymlinkInfoProvider, CompatSymlinkInfo{
+			InstalledPath: m.installedPath,
+			Target:        m.properties.Symlink_target,
+		})
+	}
+}
+
+// checkCompatSymlink reports the errors in the properties of a compat_symlink that installs its
+// symlink in installDir, and returns whether there were none.
+func (m *InstallSymlink) checkCompatSymlink(ctx android.ModuleContext, installDir android.InstallPath) bool {
+	location := m.properties.Installed_location
+	target := m.properties.Symlink_target
+	partition := installDir.Partition()
+
+	if !android.InList(partition, compatSymlinkPartitions) {
+		ctx.ModuleErrorf("must be installed in one of the %q partitions, got %q", compatSymlinkPartitions, partition)
+		return false
+	}
+	if location == ".." {
+		ctx.PropertyErrorf("installed_location", "Should not start with / or ../")
+		return false
+	}
+	if target == "" {
+		ctx.PropertyErrorf("symlink_target", "Should be a clean filepath")
+		return false
+	}
+	if filepath.IsAbs(target) {
+		if !proptools.Bool(m.compatProperties.Allow_absolute_target) {
+			ctx.PropertyErrorf("symlink_target", "%q is absolute, set allow_absolute_target: true to allow it", target)
+			return false
+		}
+	} else if resolved := filepath.Join(filepath.Dir(location), target); resolved == ".." || strings.HasPrefix(resolved, "../") {
+		ctx.PropertyErrorf("symlink_target", "%q points outside of the %s partition", target, partition)
+		return false
+	}
+	return true
 }
 
 func (m *InstallSymlink) AndroidMkEntries() []android.AndroidMkEntries {
@@ -133,3 +133,86 @@ func TestErrorOnInstalledPathStartingWithSlash(t *testing.T) {
 		}
 	`)
 }
+
+func TestCompatSymlink(t *testing.T) {
+	result := prepareForInstallSymlinkTest.RunTestWithBp(t, `
+		compat_symlink {
+			name: "icu",
+			installed_location: "usr/icu",
+			symlink_target: "/apex/com.android.i18n/etc/icu",
+			allow_absolute_target: true,
+		}
+
+		compat_symlink {
+			name: "vendor_lib",
+			installed_location: "lib/legacy.so",
+			symlink_target: "../lib64/legacy.so",
+			vendor: true,
+		}
+	`)
+
+	icu := result.ModuleForTests("icu", "android_common").Module()
+	info, _ := android.SingletonModuleProvider(result, icu, CompatSymlinkInfoProvider)
+	android.AssertPathRelativeToTopEquals(t, "installed path",
+		"out/soong/target/product/test_device/system/usr/icu", info.InstalledPath)
+	android.AssertStringEquals(t, "target", "/apex/com.android.i18n/etc/icu", info.Target)
+
+	entries := android.AndroidMkEntriesForTest(t, result.TestContext, icu)[0]
+	android.AssertStringPathsRelativeToTopEquals(t, "LOCAL_SOONG_INSTALL_SYMLINKS", result.Config,
+		[]string{"out/soong/target/product/test_device/system/usr/icu"}, entries.EntryMap["LOCAL_SOONG_INSTALL_SYMLINKS"])
+
+	vendorLib := result.ModuleForTests("vendor_lib", "android_common").Module()
+	specs := vendorLib.PackagingSpecs()
+	if len(specs) != 1 {
+		t.Fatalf("expected 1 packaging spec, got %d", len(specs))
+	}
+	android.AssertStringEquals(t, "partition", "vendor", specs[0].Partition())
+	android.AssertStringEquals(t, "path in package", "lib/legacy.so", specs[0].RelPathInPackage())
+}
+
+func TestCompatSymlinkErrors(t *testing.T) {
+	testCases := []struct {
+		name          string
+		properties    string
+		expectedError string
+	}{
+		{
+			name:          "absolute target without flag",
+			properties:    `installed_location: "usr/icu", symlink_target: "/apex/com.android.i18n/etc/icu"`,
+			expectedError: `"/apex/com.android.i18n/etc/icu" is absolute, set allow_absolute_target: true to allow it`,
+		},
+		{
+			name:          "target escapes partition",
+			properties:    `installed_location: "lib/legacy.so", symlink_target: "../../lib64/legacy.so"`,
+			expectedError: `"../../lib64/legacy.so" points outside of the system partition`,
+		},
+		{
+			name:          "location escapes partition",
+			properties:    `installed_location: "../lib/legacy.so", symlink_target: "legacy.so"`,
+			expectedError: `Should not start with / or \.\./`,
+		},
+		{
+			name:          "target escapes vendor partition",
+			properties:    `installed_location: "lib/legacy.so", symlink_target: "../../lib64/legacy.so", vendor: true`,
+			expectedError: `"../../lib64/legacy.so" points outside of the vendor partition`,
+		},
+		{
+			name:          "unsupported partition",
+			properties:    `installed_location: "lib/legacy.so", symlink_target: "legacy.so", debug_ramdisk: true`,
+			expectedError: `must be installed in one of the .* partitions, got "debug_ramdisk"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			prepareForInstallSymlinkTest.
+				ExtendWithErrorHandler(android.FixtureExpectsOneErrorPattern(tc.expectedError)).
+				RunTestWithBp(t, `
+				compat_symlink {
+					name: "foo",
+					`+tc.properties+`,
+				}
+			`)
+		})
+	}
+}
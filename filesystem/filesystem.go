@@ -109,6 +109,11 @@ type filesystemProperties struct {
 
 	// Mount point for this image. Default is "/"
 	Mount_point *string
+
+	// When set to true, the files packaged into the image are printed when the image is built,
+	// along with the reason why they are not installed, e.g. skip_install or hide_from_make, if
+	// they aren't.  Default is false.
+	Verbose *bool
 }
 
 // android_filesystem packages a set of modules and their transitive dependencies into a filesystem
@@ -253,9 +258,11 @@ func (f *filesystem) buildRootZip(ctx android.ModuleContext) android.OutputPath
 
 func (f *filesystem) buildImageUsingBuildImage(ctx android.ModuleContext) android.OutputPath {
 	depsZipFile := android.PathForModuleOut(ctx, "deps.zip").OutputPath
-	f.entries = f.CopyDepsToZip(ctx, f.gatherFilteredPackagingSpecs(ctx), depsZipFile)
+	specs := f.gatherFilteredPackagingSpecs(ctx)
+	f.entries = f.CopyDepsToZip(ctx, specs, depsZipFile)
 
 	builder := android.NewRuleBuilder(pctx, ctx)
+	f.printPackagingSpecs(ctx, builder, specs)
 	depsBase := proptools.StringDefault(f.properties.Base_dir, ".")
 	rebasedDepsZip := android.PathForModuleOut(ctx, "rebased_deps.zip").OutputPath
 	builder.Command().
@@ -399,9 +406,11 @@ func (f *filesystem) buildCpioImage(ctx android.ModuleContext, compressed bool)
 	}
 
 	depsZipFile := android.PathForModuleOut(ctx, "deps.zip").OutputPath
-	f.entries = f.CopyDepsToZip(ctx, f.gatherFilteredPackagingSpecs(ctx), depsZipFile)
+	specs := f.gatherFilteredPackagingSpecs(ctx)
+	f.entries = f.CopyDepsToZip(ctx, specs, depsZipFile)
 
 	builder := android.NewRuleBuilder(pctx, ctx)
+	f.printPackagingSpecs(ctx, builder, specs)
 	depsBase := proptools.StringDefault(f.properties.Base_dir, ".")
 	rebasedDepsZip := android.PathForModuleOut(ctx, "rebased_deps.zip").OutputPath
 	builder.Command().
@@ -497,6 +506,26 @@ func (f *filesystem) gatherFilteredPackagingSpecs(ctx android.ModuleContext) map
 	return specs
 }
 
+// printPackagingSpecs makes the image print its packaging specs with their skip reasons when it is
+// built, if verbose is set.
+func (f *filesystem) printPackagingSpecs(ctx android.ModuleContext, builder *android.RuleBuilder, specs map[string]android.PackagingSpec) {
+	if !proptools.Bool(f.properties.Verbose) {
+		return
+	}
+	var lines []string
+	for _, k := range android.SortedKeys(specs) {
+		spec := specs[k]
+		source := "-> " + spec.SymlinkTarget()
+		if spec.SymlinkTarget() == "" {
+			source = spec.SrcPath().String()
+		}
+		lines = append(lines, fmt.Sprintf("%s\t%s\t%s", spec.RelPathInPackage(), source, spec.SkipReason()))
+	}
+	specsFile := android.PathForModuleOut(ctx, "packaging_specs.txt")
+	android.WriteFileRule(ctx, specsFile, strings.Join(lines, "\n"))
+	builder.Command().Text("cat").Input(specsFile)
+}
+
 func sha1sum(values []string) string {
 	h := sha256.New()
 	for _, value := range values {
@@ -16,6 +16,7 @@ package filesystem
 
 import (
 	"os"
+	"strings"
 	"testing"
 
 	"android/soong/android"
@@ -140,10 +141,14 @@ type component struct {
 	android.ModuleBase
 	properties struct {
 		Install_copy_in_data []string
+		Skip_install         *bool
 	}
 }
 
 func (c *component) GenerateAndroidBuildActions(ctx android.ModuleContext) {
+	if proptools.Bool(c.properties.Skip_install) {
+		c.SkipInstall()
+	}
 	output := android.PathForModuleOut(ctx, c.Name())
 	dir := android.PathForModuleInstall(ctx, "components")
 	ctx.InstallFile(dir, c.Name(), output)
@@ -176,6 +181,37 @@ func TestFileSystemGathersItemsOnlyInSystemPartition(t *testing.T) {
 	android.AssertDeepEquals(t, "entries should have foo only", []string{"components/foo"}, module.entries)
 }
 
+func TestFileSystemVerbosePrintsSkipReasons(t *testing.T) {
+	f := android.GroupFixturePreparers(fixture, android.FixtureRegisterWithContext(registerComponent))
+	result := f.RunTestWithBp(t, `
+		android_filesystem {
+			name: "myfilesystem",
+			deps: ["foo", "bar"],
+			verbose: true,
+		}
+		component {
+			name: "foo",
+		}
+		component {
+			name: "bar",
+			skip_install: true,
+		}
+	`)
+
+	module := result.ModuleForTests("myfilesystem", "android_common")
+	specs := android.ContentFromFileRuleForTests(t, result.TestContext, module.Output("packaging_specs.txt"))
+	lines := strings.Split(specs, "\n")
+	android.AssertIntEquals(t, "number of specs", 2, len(lines))
+	android.AssertStringDoesContain(t, "bar", lines[0], "components/bar\t")
+	android.AssertStringDoesContain(t, "bar", lines[0], "\tskip_install")
+	android.AssertStringDoesContain(t, "foo", lines[1], "components/foo\t")
+	android.AssertStringDoesContain(t, "foo", lines[1], "\tinstalled")
+
+	// The specs are printed by the rule that builds the image.
+	android.AssertStringDoesContain(t, "image command",
+		module.Output("myfilesystem.img").RuleParams.Command, "cat "+module.Output("packaging_specs.txt").Output.String())
+}
+
 func TestAvbGenVbmetaImage(t *testing.T) {
 	result := fixture.RunTestWithBp(t, `
 		avb_gen_vbmeta_image {
@@ -281,6 +281,7 @@ func (g *Module) generateCommonBuildActions(ctx android.ModuleContext) {
 
 	var tools android.Paths
 	var packagedTools []android.PackagingSpec
+	var toolUses []android.HostToolUse
 	if len(g.properties.Tools) > 0 {
 		seenTools := make(map[string]bool)
 
@@ -312,6 +313,7 @@ func (g *Module) generateCommonBuildActions(ctx android.ModuleContext) {
 						ctx.ModuleErrorf("host tool %q missing output file", tool)
 						return
 					}
+					toolUses = append(toolUses, android.NewHostToolUse(ctx, module, path.Path()))
 					if specs := t.TransitivePackagingSpecs(); specs != nil {
 						// If the HostToolProvider has PackgingSpecs, which are definitions of the
 						// required relative locations of the tool and its dependencies, use those
@@ -328,6 +330,7 @@ func (g *Module) generateCommonBuildActions(ctx android.ModuleContext) {
 					// A GoBinaryTool provides the install path to a tool, which will be copied.
 					p := android.PathForGoBinary(ctx, t)
 					tools = append(tools, p)
+					toolUses = append(toolUses, android.HostToolUse{Tool: tool, Path: p.String()})
 					addLocationLabel(tag.label, toolLocation{android.Paths{p}})
 				default:
 					ctx.ModuleErrorf("%q is not a host tool provider", tool)
@@ -356,6 +359,10 @@ func (g *Module) generateCommonBuildActions(ctx android.ModuleContext) {
 		return
 	}
 
+	if len(toolUses) > 0 {
+		android.SetProvider(ctx, android.HostToolsUsedInfoProvider, android.NewHostToolsUsedInfo(toolUses))
+	}
+
 	for _, toolFile := range g.properties.Tool_files {
 		paths := android.PathsForModuleSrc(ctx, []string{toolFile})
 		tools = append(tools, paths...)
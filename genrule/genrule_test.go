@@ -15,6 +15,7 @@
 package genrule
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"regexp"
@@ -957,6 +958,57 @@ func TestPrebuiltTool(t *testing.T) {
 	}
 }
 
+func TestHostToolsUsed(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForGenRuleTest,
+		android.FixtureRegisterWithContext(android.RegisterHostToolsUsedBuildComponents),
+	).RunTestWithBp(t, `
+		tool { name: "tool" }
+		tool { name: "tool2" }
+		prebuilt_tool { name: "tool2", prefer: true }
+
+		genrule {
+			name: "gen",
+			tools: ["tool2", "tool"],
+			out: ["foo"],
+			cmd: "$(location tool) $(location tool2)",
+		}
+
+		genrule {
+			name: "gen2",
+			tools: ["tool2"],
+			out: ["bar"],
+			cmd: "$(location tool2)",
+		}
+
+		genrule {
+			name: "gen3",
+			out: ["baz"],
+			cmd: "touch $(out)",
+		}
+	`)
+
+	hostVariant := result.Config.BuildOSTarget.String()
+	toolPath := result.ModuleForTests("tool", hostVariant).Module().(*testTool).HostToolPath().Path().String()
+	prebuiltToolPath := result.ModuleForTests("prebuilt_tool2", hostVariant).Module().(*prebuiltTestTool).HostToolPath().Path().String()
+
+	output := result.SingletonForTests("host_tools_used").Output("host_tools_used.json")
+	var uses map[string][]android.HostToolUse
+	if err := json.Unmarshal([]byte(android.ContentFromFileRuleForTests(t, result.TestContext, output)), &uses); err != nil {
+		t.Fatal(err)
+	}
+
+	android.AssertDeepEquals(t, "host tools used", map[string][]android.HostToolUse{
+		"gen": {
+			{Tool: "tool", Prebuilt: false, Path: toolPath},
+			{Tool: "tool2", Prebuilt: true, Path: prebuiltToolPath},
+		},
+		"gen2": {
+			{Tool: "tool2", Prebuilt: true, Path: prebuiltToolPath},
+		},
+	}, uses)
+}
+
 func TestGenruleWithGlobPaths(t *testing.T) {
 	testcases := []struct {
 		name            string
@@ -305,8 +305,8 @@ func (binary *Binary) AndroidMkEntries() []android.AndroidMkEntries {
 					android.SetAconfigFileMkEntries(&binary.ModuleBase, entries, binary.mergedAconfigFiles)
 				},
 			},
-			ExtraFooters: []android.AndroidMkExtraFootersFunc{
-				func(w io.Writer, name, prefix, moduleDir string) {
+			ExtraFootersWithContext: []android.AndroidMkExtraFootersWithContextFunc{
+				func(ctx android.AndroidMkExtraEntriesContext, w io.Writer, name, prefix, moduleDir string) {
 					fmt.Fprintln(w, "jar_installed_module := $(LOCAL_INSTALLED_MODULE)")
 				},
 			},
@@ -322,8 +322,8 @@ func (binary *Binary) AndroidMkEntries() []android.AndroidMkEntries {
 					entries.SetBool("LOCAL_STRIP_MODULE", false)
 				},
 			},
-			ExtraFooters: []android.AndroidMkExtraFootersFunc{
-				func(w io.Writer, name, prefix, moduleDir string) {
+			ExtraFootersWithContext: []android.AndroidMkExtraFootersWithContextFunc{
+				func(ctx android.AndroidMkExtraEntriesContext, w io.Writer, name, prefix, moduleDir string) {
 					// Ensure that the wrapper script timestamp is always updated when the jar is updated
 					fmt.Fprintln(w, "$(LOCAL_INSTALLED_MODULE): $(jar_installed_module)")
 					fmt.Fprintln(w, "jar_installed_module :=")
@@ -459,8 +459,8 @@ func (app *AndroidApp) AndroidMkEntries() []android.AndroidMkEntries {
 				}
 			},
 		},
-		ExtraFooters: []android.AndroidMkExtraFootersFunc{
-			func(w io.Writer, name, prefix, moduleDir string) {
+		ExtraFootersWithContext: []android.AndroidMkExtraFootersWithContextFunc{
+			func(ctx android.AndroidMkExtraEntriesContext, w io.Writer, name, prefix, moduleDir string) {
 				if app.javaApiUsedByOutputFile.String() != "" {
 					fmt.Fprintf(w, "$(call dist-for-goals,%s,%s:%s/$(notdir %s))\n",
 						app.installApkName, app.javaApiUsedByOutputFile.String(), "java_apis_used_by_apex", app.javaApiUsedByOutputFile.String())
@@ -610,8 +610,8 @@ func (dstubs *Droidstubs) AndroidMkEntries() []android.AndroidMkEntries {
 				}
 			},
 		},
-		ExtraFooters: []android.AndroidMkExtraFootersFunc{
-			func(w io.Writer, name, prefix, moduleDir string) {
+		ExtraFootersWithContext: []android.AndroidMkExtraFootersWithContextFunc{
+			func(ctx android.AndroidMkExtraEntriesContext, w io.Writer, name, prefix, moduleDir string) {
 				if dstubs.apiFile != nil {
 					fmt.Fprintf(w, ".PHONY: %s %s.txt\n", dstubs.Name(), dstubs.Name())
 					fmt.Fprintf(w, "%s %s.txt: %s\n", dstubs.Name(), dstubs.Name(), dstubs.apiFile)
@@ -253,6 +253,7 @@ func (c Certificate) AndroidMkString() string {
 
 func (a *AndroidApp) DepsMutator(ctx android.BottomUpMutatorContext) {
 	a.Module.deps(ctx)
+	android.CheckNotSelfReferenced(ctx, "overrides", a.overridableAppProperties.Overrides)
 
 	if String(a.appProperties.Stl) == "c++_shared" && !a.SdkVersion(ctx).Specified() {
 		ctx.PropertyErrorf("stl", "sdk_version must be set in order to use c++_shared")
@@ -219,6 +219,7 @@ func MergePropertiesFromVariant(ctx android.EarlyModuleContext,
 }
 
 func (a *AndroidAppImport) DepsMutator(ctx android.BottomUpMutatorContext) {
+	android.CheckNotSelfReferenced(ctx, "overrides", a.properties.Overrides)
 	cert := android.SrcIsModule(String(a.properties.Certificate))
 	if cert != "" {
 		ctx.AddDependency(ctx.Module(), certificateTag, cert)
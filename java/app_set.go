@@ -118,6 +118,7 @@ func SupportedAbis(ctx android.ModuleContext, excludeNativeBridgeAbis bool) []st
 }
 
 func (as *AndroidAppSet) GenerateAndroidBuildActions(ctx android.ModuleContext) {
+	android.CheckNotSelfReferenced(ctx, "overrides", as.properties.Overrides)
 	as.packedOutput = android.PathForModuleOut(ctx, ctx.ModuleName()+".zip")
 	as.primaryOutput = android.PathForModuleOut(ctx, as.BaseModuleName()+".apk")
 	as.apkcertsFile = android.PathForModuleOut(ctx, "apkcerts.txt")
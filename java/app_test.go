@@ -2739,7 +2739,7 @@ func TestOverrideAndroidAppOverrides(t *testing.T) {
 			name:        "foo",
 			moduleName:  "foo_override",
 			variantName: "android_common_foo_override",
-			overrides:   []string{"bar", "foo"},
+			overrides:   []string{"qux", "bar", "foo"},
 		},
 	}
 	for _, expected := range expectedVariants {
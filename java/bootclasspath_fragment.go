@@ -783,19 +783,24 @@ func (b *BootclasspathFragmentModule) produceBootImageProfileFromSource(ctx andr
 }
 
 func (b *BootclasspathFragmentModule) AndroidMkEntries() []android.AndroidMkEntries {
-	// Use the generated classpath proto as the output.
-	outputFile := b.outputFilepath
-	// Create a fake entry that will cause this to be added to the module-info.json file.
+	// Create a fake entry that will cause this to be added to the module-info.json file.  The
+	// OutputFile is needed before the providers can be read, so it is the only part of the entry that
+	// is taken from the module.
 	entriesList := []android.AndroidMkEntries{{
 		Class:      "FAKE",
-		OutputFile: android.OptionalPathForPath(outputFile),
+		OutputFile: android.OptionalPathForPath(b.outputFilepath),
 		Include:    "$(BUILD_PHONY_PACKAGE)",
-		ExtraFooters: []android.AndroidMkExtraFootersFunc{
-			func(w io.Writer, name, prefix, moduleDir string) {
+		ExtraFootersWithContext: []android.AndroidMkExtraFootersWithContextFunc{
+			func(ctx android.AndroidMkExtraEntriesContext, w io.Writer, name, prefix, moduleDir string) {
 				// Allow the bootclasspath_fragment to be built by simply passing its name on the command
-				// line.
+				// line, using the generated classpath proto as the output.
+				info, ok := ctx.Provider(ClasspathFragmentProtoContentInfoProvider)
+				if !ok {
+					return
+				}
+				output := info.(ClasspathFragmentProtoContentInfo).ClasspathFragmentProtoOutput
 				fmt.Fprintln(w, ".PHONY:", b.Name())
-				fmt.Fprintln(w, b.Name()+":", outputFile.String())
+				fmt.Fprintln(w, b.Name()+":", output.String())
 			},
 		},
 	}}
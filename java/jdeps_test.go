@@ -15,7 +15,11 @@
 package java
 
 import (
+	"encoding/json"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 
 	"android/soong/android"
@@ -85,3 +89,49 @@ func TestCollectJavaLibraryPropertiesAddJarjarRules(t *testing.T) {
 		t.Errorf("Library.IDEInfo() Jarjar_rules = %v, want %v", dpInfo.Jarjar_rules[0], expected)
 	}
 }
+
+func TestJdepsIdeVariantInfo(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForJavaTest,
+		android.FixtureRegisterWithContext(func(ctx android.RegistrationContext) {
+			ctx.RegisterParallelSingletonType("jdeps_generator", jDepsGeneratorSingleton)
+		}),
+	).RunTestWithBp(t, `
+		cc_genrule {
+			name: "gen",
+			tool_files: ["tool"],
+			cmd: "$(location tool) $(in) $(out)",
+			out: ["out"],
+			vendor_available: true,
+			recovery_available: true,
+		}
+	`)
+
+	// module_bp_java_deps.json is written by Soong rather than by a rule.
+	content, err := os.ReadFile(filepath.Join(result.Config.SoongOutDir(), jdepsJsonFileName))
+	if err != nil {
+		t.Fatalf("%s has not been generated: %s", jdepsJsonFileName, err)
+	}
+	var moduleInfos map[string]android.IdeInfo
+	if err := json.Unmarshal(content, &moduleInfos); err != nil {
+		t.Fatalf("failed to parse %s: %s", jdepsJsonFileName, err)
+	}
+
+	variants := result.ModuleVariantsForTests("gen")
+	partitions := make(map[string]string)
+	for _, variant := range moduleInfos["gen"].Variants {
+		if !android.InList(variant.Variant, variants) {
+			t.Errorf("unexpected variant %q, expected one of %q", variant.Variant, variants)
+		}
+		imageVariation := variant.ImageVariation
+		if strings.HasPrefix(imageVariation, android.VendorVariationPrefix) {
+			imageVariation = android.VendorVariation
+		}
+		partitions[imageVariation] = variant.PartitionTag
+	}
+	android.AssertDeepEquals(t, "partitions", map[string]string{
+		android.CoreVariation:     "system",
+		android.VendorVariation:   "vendor",
+		android.RecoveryVariation: "recovery",
+	}, partitions)
+}
@@ -326,8 +326,8 @@ func (r *robolectricTest) AndroidMkEntries() []android.AndroidMkEntries {
 			}
 		})
 
-	entries.ExtraFooters = []android.AndroidMkExtraFootersFunc{
-		func(w io.Writer, name, prefix, moduleDir string) {
+	entries.ExtraFootersWithContext = []android.AndroidMkExtraFootersWithContextFunc{
+		func(ctx android.AndroidMkExtraEntriesContext, w io.Writer, name, prefix, moduleDir string) {
 			if s := r.robolectricProperties.Test_options.Shards; s != nil && *s > 1 {
 				numShards := int(*s)
 				shardSize := (len(r.tests) + numShards - 1) / numShards
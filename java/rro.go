@@ -110,6 +110,7 @@ func rroPartition(ctx android.ModuleContext) string {
 }
 
 func (r *RuntimeResourceOverlay) DepsMutator(ctx android.BottomUpMutatorContext) {
+	android.CheckNotSelfReferenced(ctx, "overrides", r.properties.Overrides)
 	sdkDep := decodeSdkDep(ctx, android.SdkContext(r))
 	if sdkDep.hasFrameworkLibs() {
 		r.aapt.deps(ctx, sdkDep)
@@ -128,6 +128,12 @@ func (s *SystemServerClasspathModule) GenerateAndroidBuildActions(ctx android.Mo
 	s.classpathFragmentBase().generateClasspathProtoBuildActions(ctx, configuredJars, classpathJars)
 }
 
+// SystemServerClasspathContents returns the modules listed in the contents and standalone_contents
+// properties.
+func (s *SystemServerClasspathModule) SystemServerClasspathContents() (contents, standaloneContents []string) {
+	return s.properties.Contents, s.properties.Standalone_contents
+}
+
 func (s *SystemServerClasspathModule) configuredJars(ctx android.ModuleContext) android.ConfiguredJarList {
 	global := dexpreopt.GetGlobalConfig(ctx)
 
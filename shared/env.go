@@ -55,6 +55,29 @@ func EnvFileContents(envDeps map[string]string) ([]byte, error) {
 	return data, nil
 }
 
+// Serializes the module types and singletons that read each environment variable into JSON
+// formatted bytes, for diagnosing which consumer caused soong_build to rerun when a variable changed.
+//
+// e.g. BUILD_NUMBER read by the cc_binary module type and the androidmk singleton
+// is converted to:
+//
+//	{
+//	    "BUILD_NUMBER": [
+//	        "androidmk",
+//	        "cc_binary"
+//	    ]
+//	}
+func EnvUsageFileContents(envUsage map[string][]string) ([]byte, error) {
+	data, err := json.MarshalIndent(envUsage, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+
+	data = append(data, '\n')
+
+	return data, nil
+}
+
 // Reads and deserializes a Soong environment file located at the given file
 // path to determine its staleness. If any environment variable values have
 // changed, it prints and returns changed environment variable values and